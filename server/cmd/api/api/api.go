@@ -2,34 +2,353 @@ package api
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 
+	"github.com/onkernel/kernel-images/server/lib/crashreport"
+	"github.com/onkernel/kernel-images/server/lib/devtoolsproxy"
+	"github.com/onkernel/kernel-images/server/lib/display"
+	"github.com/onkernel/kernel-images/server/lib/displaylock"
+	"github.com/onkernel/kernel-images/server/lib/extensionmanifest"
+	"github.com/onkernel/kernel-images/server/lib/exttrust"
 	"github.com/onkernel/kernel-images/server/lib/logger"
+	"github.com/onkernel/kernel-images/server/lib/nekoclient"
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/profilesnapshot"
+	"github.com/onkernel/kernel-images/server/lib/profilestore"
+	"github.com/onkernel/kernel-images/server/lib/proofsession"
+	"github.com/onkernel/kernel-images/server/lib/ratelimit"
+	"github.com/onkernel/kernel-images/server/lib/reclaimauth"
 	"github.com/onkernel/kernel-images/server/lib/recorder"
+	"github.com/onkernel/kernel-images/server/lib/scaletozero"
+	"github.com/onkernel/kernel-images/server/lib/supervisorrpc"
+	"github.com/onkernel/kernel-images/server/lib/uploadsession"
+	"github.com/onkernel/kernel-images/server/lib/vfs"
 )
 
+// uploadSessionDir is where resumable extension upload sessions are persisted, so a client can
+// resume after a network blip or a server restart. See extension_upload.go.
+const uploadSessionDir = "/var/lib/kernel/uploads"
+
+// fsUploadSessionDir is where resumable WriteFile upload sessions are persisted. See
+// fs_upload.go.
+const fsUploadSessionDir = "/var/lib/kernel/fs-uploads"
+
+// proofSessionDBPath is where asynchronous reclaim proof sessions are persisted. See
+// reclaim.go.
+const proofSessionDBPath = "/var/lib/kernel/reclaim/sessions.db"
+
+// extensionsDir/extensionManifestPath locate installed extensions and the manifest tracking
+// them. See extensions_crud.go.
+const (
+	extensionsDir         = "/home/kernel/extensions"
+	extensionManifestPath = "/home/kernel/extensions/manifest.json"
+)
+
+// profileChunkDir/profileMetaDir are the local ChunkStore/MetaStore locations profile_store.go
+// uses by default. Operators wanting incremental snapshots deduped against an S3-compatible
+// bucket instead swap the profilestore.NewLocalChunkStore call below for
+// profilestore.NewS3ChunkStore - see server/lib/profilestore's ChunkStore interface.
+const (
+	profileChunkDir = "/var/lib/kernel/profile-store/chunks"
+	profileMetaDir  = "/var/lib/kernel/profile-store/snapshots"
+)
+
+// nekoBaseURL is the address of the in-container Neko instance. It's not configurable today
+// because Neko, like chromium, only ever runs as a loopback sidecar next to this API server.
+const nekoBaseURL = "http://127.0.0.1:8080"
+
+// xvfbSupervisorConfigPath locates the Xvfb supervisor program config this process rewrites to
+// apply a geometry change. xvfbSupervisorSocketPath is supervisord's own control socket - not
+// Xvfb-specific despite the name, it's just where display.go first needed it - shared by
+// supervisorClient for controlling every managed program (see supervisor.go).
+const (
+	xvfbSupervisorConfigPath = "/etc/supervisor/conf.d/services/xvfb.conf"
+	xvfbSupervisorSocketPath = "/var/run/supervisor.sock"
+)
+
+// chromiumDevToolsPort is the --remote-debugging-port chromium-launcher starts Chromium's CDP
+// endpoint on internally (see cmd/chromium-launcher/main.go's internalPort); devtoolsproxy then
+// fronts it on the container's public 9222. GetBrowserReady (supervisor.go) dials this port
+// in-process instead of a client shelling out to curl.
+const chromiumDevToolsPort = "9223"
+
+// chromiumLogPath is supervisord's log for the chromium program - the same path main.go points
+// devtoolsproxy.NewUpstreamManager at. collectCrashes (crashes.go) tails it for the stderr a
+// crashed Chromium left behind.
+const chromiumLogPath = "/var/log/supervisord/chromium"
+
+// crashReportDir is where crashreport.Store persists captured crash metadata and minidumps,
+// following profileChunkDir/profileMetaDir's /var/lib/kernel placement convention.
+const crashReportDir = "/var/lib/kernel/crash-reports"
+
 type ApiService struct {
 	// defaultRecorderID is used whenever the caller doesn't specify an explicit ID.
 	defaultRecorderID string
 
 	recordManager recorder.RecordManager
 	factory       recorder.FFmpegRecorderFactory
+
+	upstreamMgr *devtoolsproxy.UpstreamManager
+	stz         scaletozero.ScaleToZeroer
+
+	nekoClient *nekoclient.AuthClient
+	nekoEvents *nekoclient.EventTracker
+
+	// nekoToken/nekoTokenMu cache a bearer token obtained by display.go's own direct HTTP
+	// calls to Neko's REST API, kept separate from nekoClient's own token cache.
+	nekoTokenMu sync.RWMutex
+	nekoToken   string
+
+	// displayMode/displayDriver are resolved once at construction (see selectDisplayDriver)
+	// rather than detected on every PatchDisplay call.
+	displayMode   string
+	displayDriver display.Driver
+
+	// displayLock arbitrates PatchDisplay's write lease against the read leases held for the
+	// duration of an active recording or connected Neko viewer, closing the check-then-act race
+	// a one-shot "is anything active?" sample leaves open. PutDisplayLayout does not take it; it
+	// still samples active viewers and recordings once before applying a layout.
+	displayLock *displaylock.DisplayLock
+
+	// nekoViewerLeaseMu/nekoViewerLeaseRelease track the single read lease representing "at
+	// least one Neko viewer is connected", toggled from nekoEvents.OnChange.
+	nekoViewerLeaseMu      sync.Mutex
+	nekoViewerLeaseRelease func()
+
+	// recordingLeaseMu/recordingLeases hold one read lease per active recorder ID, acquired
+	// in StartRecording and released in StopRecording.
+	recordingLeaseMu sync.Mutex
+	recordingLeases  map[string]func()
+
+	// displayEventSubs holds one channel per subscriber of GET /display/events, keyed by a
+	// generated subscription ID so StreamDisplayEvents can clean up its own entry on exit.
+	displayEventMu   sync.RWMutex
+	displayEventSubs map[string]chan oapi.DisplayEvent
+
+	// uploadSessions backs the resumable chunked extension upload endpoints in
+	// extension_upload.go.
+	uploadSessions *uploadsession.Store
+
+	// fsUploadSessions backs the resumable chunked WriteFile upload endpoints in fs_upload.go.
+	fsUploadSessions *uploadsession.Store
+
+	// extensions backs the extension lifecycle CRUD endpoints in extensions_crud.go.
+	extensions *extensionmanifest.Manifest
+
+	// trustPolicy gates extension installs (upload, URL/CRX/CWS) on signature verification. See
+	// chromium.go, extension_install.go, and exttrust.LoadPolicyFromEnv.
+	trustPolicy *exttrust.Policy
+
+	// mounts resolves the filesystem handlers in fs.go against local disk or a configured
+	// S3/WebDAV/SFTP backend, selected via a scheme-prefixed path (e.g. "s3://bucket/key") or the
+	// X-Kernel-Mount header. See vfs.LoadMountsFromEnv.
+	mounts *vfs.Registry
+
+	// proofSessions backs the asynchronous reclaim proof endpoints in reclaim.go.
+	proofSessions proofsession.Store
+
+	// reclaimAuth authenticates and authorizes ReclaimProve/GetReclaimSession callers. See
+	// reclaim.go and reclaimauth.LoadAuthenticatorFromEnv.
+	reclaimAuth *reclaimauth.Authenticator
+
+	// reclaimEvents fans out proof session lifecycle events to StreamReclaimSessionEvents/
+	// HandleReclaimSessionEventsWS subscribers. See reclaim_events.go.
+	reclaimEvents *reclaimEventHub
+
+	// playwrightExecutions tracks in-flight ExecutePlaywrightCode/
+	// HandleExecutePlaywrightCodeStream runs so CancelPlaywrightExecution can abort one by ID.
+	// See playwright.go.
+	playwrightExecutions *playwrightExecutionRegistry
+
+	// playwrightSessions tracks long-lived playwright worker processes created via
+	// HandleCreatePlaywrightSession, each owning its own Playwright BrowserContext. See
+	// playwright_session.go.
+	playwrightSessions *playwrightSessionRegistry
+
+	// xdotoolMgr owns the persistent xdotool script-mode process HandleBatchComputerActions runs
+	// batched input actions against, so a batch doesn't pay one xdotool process launch per
+	// action. See computer_batch.go.
+	xdotoolMgr *xdotoolManager
+
+	// execLimiter caps the rate and concurrency of the endpoints that spawn a tsx process or
+	// drive xdotool (ClickMouse, ExecutePlaywrightCode, and their raw streaming/batch
+	// counterparts), per client. See exec_ratelimit.go.
+	execLimiter *execRateLimiter
+
+	// profileSnapshotSigner signs/verifies profile_snapshot.go's manifests. It's nil (disabled)
+	// unless PROFILE_SNAPSHOT_SIGNING_KEY is configured; see profilesnapshot.LoadSigningKeyFromEnv.
+	profileSnapshotSigner ed25519.PrivateKey
+
+	// profileChunks/profileSnapshots back the incremental, content-addressed profile checkpoints
+	// in profile_store.go - see server/lib/profilestore.
+	profileChunks    profilestore.ChunkStore
+	profileSnapshots profilestore.MetaStore
+
+	// supervisorClient backs the structured process control and restartChromium in
+	// supervisor.go/display_layout.go, replacing the supervisorctl-over-ProcessExec shell-outs
+	// those used previously.
+	supervisorClient *supervisorrpc.Client
+
+	// supervisorFatalWebhookURL is where StartSupervisorFatalWatcher POSTs when a supervised
+	// program (chromium, xvfb, ...) transitions into supervisord's FATAL state. Loaded from
+	// SUPERVISOR_FATAL_WEBHOOK_URL; empty disables the watcher. See supervisor.go.
+	supervisorFatalWebhookURL string
+
+	// crashStore/crashSinks back collectCrashes (crashes.go), called from restartChromium just
+	// before it restarts chromium: crashStore always persists a captured report locally so GET
+	// /browser/crashes/{id} has something to serve; crashSinks, configured via
+	// crashreport.LoadSinksFromEnv, additionally ship a copy wherever the deployment wants one.
+	crashStore *crashreport.Store
+	crashSinks []crashreport.Sink
 }
 
-func New(recordManager recorder.RecordManager, factory recorder.FFmpegRecorderFactory) (*ApiService, error) {
+func New(
+	recordManager recorder.RecordManager,
+	factory recorder.FFmpegRecorderFactory,
+	upstreamMgr *devtoolsproxy.UpstreamManager,
+	stz scaletozero.ScaleToZeroer,
+	nekoClient *nekoclient.AuthClient,
+	trustPolicy *exttrust.Policy,
+	reclaimAuth *reclaimauth.Authenticator,
+	execLimits ratelimit.Limits,
+) (*ApiService, error) {
 	switch {
 	case recordManager == nil:
 		return nil, fmt.Errorf("recordManager cannot be nil")
 	case factory == nil:
 		return nil, fmt.Errorf("factory cannot be nil")
+	case upstreamMgr == nil:
+		return nil, fmt.Errorf("upstreamMgr cannot be nil")
+	case stz == nil:
+		return nil, fmt.Errorf("stz cannot be nil")
+	case nekoClient == nil:
+		return nil, fmt.Errorf("nekoClient cannot be nil")
+	case trustPolicy == nil:
+		return nil, fmt.Errorf("trustPolicy cannot be nil")
+	case reclaimAuth == nil:
+		return nil, fmt.Errorf("reclaimAuth cannot be nil")
 	}
 
-	return &ApiService{
-		recordManager:     recordManager,
-		factory:           factory,
-		defaultRecorderID: "main",
-	}, nil
+	uploadSessions, err := uploadsession.NewStore(uploadSessionDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload session store: %w", err)
+	}
+
+	fsUploadSessions, err := uploadsession.NewStore(fsUploadSessionDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fs upload session store: %w", err)
+	}
+
+	extensions, err := extensionmanifest.Load(extensionManifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load extension manifest: %w", err)
+	}
+
+	mounts, err := vfs.LoadMountsFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load VFS mounts: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(proofSessionDBPath), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create proof session db dir: %w", err)
+	}
+	proofSessions, err := proofsession.NewBoltStore(proofSessionDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proof session store: %w", err)
+	}
+
+	profileSnapshotSigner, err := profilesnapshot.LoadSigningKeyFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load profile snapshot signing key: %w", err)
+	}
+
+	profileChunks := profilestore.NewLocalChunkStore(profileChunkDir)
+	profileSnapshots := profilestore.NewLocalMetaStore(profileMetaDir)
+
+	supervisorClient := supervisorrpc.NewClient(xvfbSupervisorSocketPath)
+
+	crashSinks, err := crashreport.LoadSinksFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load crash report sinks: %w", err)
+	}
+
+	svc := &ApiService{
+		recordManager:             recordManager,
+		factory:                   factory,
+		defaultRecorderID:         "main",
+		upstreamMgr:               upstreamMgr,
+		stz:                       stz,
+		nekoClient:                nekoClient,
+		displayLock:               displaylock.New(),
+		recordingLeases:           make(map[string]func()),
+		displayEventSubs:          make(map[string]chan oapi.DisplayEvent),
+		uploadSessions:            uploadSessions,
+		fsUploadSessions:          fsUploadSessions,
+		extensions:                extensions,
+		trustPolicy:               trustPolicy,
+		mounts:                    mounts,
+		proofSessions:             proofSessions,
+		reclaimAuth:               reclaimAuth,
+		reclaimEvents:             newReclaimEventHub(),
+		playwrightExecutions:      newPlaywrightExecutionRegistry(),
+		playwrightSessions:        newPlaywrightSessionRegistry(),
+		xdotoolMgr:                newXdotoolManager(displayFromEnv),
+		execLimiter:               newExecRateLimiter(execLimits),
+		profileSnapshotSigner:     profileSnapshotSigner,
+		profileChunks:             profileChunks,
+		profileSnapshots:          profileSnapshots,
+		supervisorClient:          supervisorClient,
+		supervisorFatalWebhookURL: loadSupervisorFatalWebhookURL(),
+		crashStore:                crashreport.NewStore(crashReportDir),
+		crashSinks:                crashSinks,
+	}
+
+	// Reuse getNekoToken (display.go) rather than nekoClient's own token cache so both the
+	// REST calls in display.go and this websocket subscription share one cached token/login.
+	nekoEvents, err := nekoclient.NewEventTracker(nekoBaseURL, svc.getNekoToken, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create neko event tracker: %w", err)
+	}
+	nekoEvents.OnChange = func(activeViewers int) {
+		svc.publishDisplayEvent(oapi.DisplayEvent{Type: "viewers", ActiveViewers: &activeViewers})
+		svc.setNekoViewerLease(activeViewers > 0)
+	}
+	nekoEvents.Start(context.Background())
+	svc.nekoEvents = nekoEvents
+
+	svc.displayMode, svc.displayDriver = svc.selectDisplayDriver()
+
+	return svc, nil
+}
+
+// selectDisplayDriver picks the display.Driver this process will use for the rest of its
+// life: Neko if WebRTC live view is enabled (it owns the X server itself), Xvfb if its
+// supervisor program is configured (headless mode), otherwise Xorg. This replaces the old
+// per-request detectDisplayMode, which shelled out to supervisorctl on every PatchDisplay
+// call to answer a question that can't change after the container has started.
+func (s *ApiService) selectDisplayDriver() (string, display.Driver) {
+	if s.isNekoEnabled() {
+		return "neko", display.NewNekoDriver(nekoBaseURL, s.getNekoToken, s.clearNekoToken)
+	}
+
+	if _, err := os.Stat(xvfbSupervisorConfigPath); err == nil {
+		driver, err := display.NewXvfbDriver(xvfbSupervisorConfigPath, "xvfb", xvfbSupervisorSocketPath)
+		if err == nil {
+			return "xvfb", driver
+		}
+	}
+
+	driver, err := display.NewXorgDriver(s.resolveDisplayFromEnv())
+	if err != nil {
+		// Xorg is the fallback of last resort; keep the process up and let PatchDisplay
+		// surface the real connection error on first use rather than failing construction.
+		return "xorg", &unavailableDisplayDriver{err: err}
+	}
+	return "xorg", driver
 }
 
 func (s *ApiService) StartRecording(ctx context.Context, req oapi.StartRecordingRequestObject) (oapi.StartRecordingResponseObject, error) {
@@ -40,6 +359,12 @@ func (s *ApiService) StartRecording(ctx context.Context, req oapi.StartRecording
 		params.FrameRate = req.Body.Framerate
 		params.MaxSizeInMB = req.Body.MaxFileSizeInMB
 		params.MaxDurationInSeconds = req.Body.MaxDurationInSeconds
+		params.HLS.Enabled = req.Body.HlsEnabled
+		params.HLS.SegmentSeconds = req.Body.HlsSegmentSeconds
+		params.HLS.KeepSegments = req.Body.HlsKeepSegments
+		if req.Body.Encoder != nil {
+			params.Encoder = recorder.Encoder(*req.Body.Encoder)
+		}
 	}
 
 	// Determine recorder ID (use default if none provided)
@@ -70,6 +395,8 @@ func (s *ApiService) StartRecording(ctx context.Context, req oapi.StartRecording
 		return oapi.StartRecording500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to start recording"}}, nil
 	}
 
+	s.acquireRecordingLease(ctx, recorderID)
+
 	return oapi.StartRecording201Response{}, nil
 }
 
@@ -110,6 +437,8 @@ func (s *ApiService) StopRecording(ctx context.Context, req oapi.StopRecordingRe
 		log.Error("error occurred while stopping recording", "err", err, "force", forceStop)
 	}
 
+	s.releaseRecordingLease(recorderID)
+
 	return oapi.StopRecording200Response{}, nil
 }
 
@@ -139,6 +468,17 @@ func (s *ApiService) DownloadRecording(ctx context.Context, req oapi.DownloadRec
 		return oapi.DownloadRecording500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to get recording"}}, nil
 	}
 
+	// recorders configured with an UploadSink return a URL instead of a local file once the
+	// multipart upload completes; redirect rather than re-streaming the object through us.
+	if meta.URL != "" {
+		log.Info("redirecting to uploaded recording", "url", meta.URL)
+		return oapi.DownloadRecording302Response{
+			Headers: oapi.DownloadRecording302ResponseHeaders{
+				Location: meta.URL,
+			},
+		}, nil
+	}
+
 	// short-circuit if the recording is still in progress and the file is arbitrary small
 	if rec.IsRecording(ctx) && meta.Size <= minRecordingSizeInBytes {
 		return oapi.DownloadRecording202Response{
@@ -160,5 +500,37 @@ func (s *ApiService) DownloadRecording(ctx context.Context, req oapi.DownloadRec
 }
 
 func (s *ApiService) Shutdown(ctx context.Context) error {
+	s.nekoEvents.Stop()
+	if err := s.proofSessions.Close(); err != nil {
+		logger.FromContext(ctx).Error("failed to close proof session store", "err", err)
+	}
+	s.reclaimAuth.Close()
 	return s.recordManager.StopAll(ctx)
 }
+
+// unavailableDisplayDriver stands in for a driver that failed to connect at startup (e.g. no
+// X server yet listening on the configured display), returning the original connection error
+// from every call instead of leaving s.displayDriver nil.
+type unavailableDisplayDriver struct {
+	err error
+}
+
+func (d *unavailableDisplayDriver) GetMode(ctx context.Context, output string) (display.Mode, error) {
+	return display.Mode{}, d.err
+}
+
+func (d *unavailableDisplayDriver) SetMode(ctx context.Context, output string, mode display.Mode) error {
+	return d.err
+}
+
+func (d *unavailableDisplayDriver) ListModes(ctx context.Context, output string) ([]display.Mode, error) {
+	return nil, d.err
+}
+
+func (d *unavailableDisplayDriver) AddMode(ctx context.Context, output string, mode display.Mode) error {
+	return d.err
+}
+
+func (d *unavailableDisplayDriver) Restart(ctx context.Context) error {
+	return d.err
+}
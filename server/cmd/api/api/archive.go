@@ -0,0 +1,253 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/vfs"
+)
+
+// DownloadArchive streams req.Body.Paths - one or more files and/or directories, each resolved
+// through resolveMount independently so a single archive can span multiple mounts - as a single
+// zip or tar.gz, written directly to the response body as entries are walked rather than staged
+// in a temp file or buffered in memory, the same "build the stream as you go" approach
+// CreateProfileSnapshot (profile_snapshot.go) uses via io.Pipe. If a per-entry stat fails partway
+// through, the archive is truncated and the error logged rather than silently producing a
+// corrupt file; there is no lower-level access to the HTTP response here to append a trailer, so
+// an early client-visible EOF is the best signal this layer can give.
+func (s *ApiService) DownloadArchive(ctx context.Context, req oapi.DownloadArchiveRequestObject) (oapi.DownloadArchiveResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || len(req.Body.Paths) == 0 {
+		return oapi.DownloadArchive400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "paths cannot be empty"}}, nil
+	}
+	format := oapi.ArchiveFormatZip
+	if req.Body.ArchiveFormat != nil {
+		format = *req.Body.ArchiveFormat
+	}
+
+	pr, filename, err := s.buildArchiveStream(ctx, log, req.Body.Paths, format)
+	if err != nil {
+		return oapi.DownloadArchive400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	return oapi.DownloadArchive200ApplicationoctetStreamResponse{
+		Body: pr,
+		Headers: oapi.DownloadArchive200ResponseHeaders{
+			ContentDisposition: fmt.Sprintf(`attachment; filename="%s"`, filename),
+		},
+	}, nil
+}
+
+// DownloadArchiveGet is DownloadArchive's repeatable-query-param variant, for pulling an archive
+// directly from a browser address bar rather than issuing a POST with a JSON body.
+func (s *ApiService) DownloadArchiveGet(ctx context.Context, req oapi.DownloadArchiveGetRequestObject) (oapi.DownloadArchiveGetResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if len(req.Params.Path) == 0 {
+		return oapi.DownloadArchiveGet400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "at least one path query parameter is required"}}, nil
+	}
+	format := oapi.ArchiveFormatZip
+	if req.Params.ArchiveFormat != nil {
+		format = *req.Params.ArchiveFormat
+	}
+
+	entries := make([]oapi.ArchiveEntry, len(req.Params.Path))
+	for i, p := range req.Params.Path {
+		entries[i] = oapi.ArchiveEntry{Path: p}
+	}
+
+	pr, filename, err := s.buildArchiveStream(ctx, log, entries, format)
+	if err != nil {
+		return oapi.DownloadArchiveGet400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	return oapi.DownloadArchiveGet200ApplicationoctetStreamResponse{
+		Body: pr,
+		Headers: oapi.DownloadArchiveGet200ResponseHeaders{
+			ContentDisposition: fmt.Sprintf(`attachment; filename="%s"`, filename),
+		},
+	}, nil
+}
+
+// buildArchiveStream resolves each requested entry to a backend, then walks and writes it into a
+// zip or tar.gz on a background goroutine, returning the read end of the pipe immediately so the
+// handler can hand it back as the response body without buffering. filename is a synthetic name
+// for Content-Disposition; it has no bearing on what ends up inside the archive.
+func (s *ApiService) buildArchiveStream(ctx context.Context, log *slog.Logger, entries []oapi.ArchiveEntry, format oapi.ArchiveFormat) (io.ReadCloser, string, error) {
+	resolved := make([]archiveSource, 0, len(entries))
+	for _, e := range entries {
+		if e.Path == "" {
+			return nil, "", fmt.Errorf("path cannot be empty")
+		}
+		backend, relPath, err := s.resolveMount(e.Path, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		recursive := true
+		if e.Recursive != nil {
+			recursive = *e.Recursive
+		}
+		followSymlinks := false
+		if e.FollowSymlinks != nil {
+			followSymlinks = *e.FollowSymlinks
+		}
+		resolved = append(resolved, archiveSource{
+			backend:        backend,
+			path:           e.Path,
+			relPath:        relPath,
+			recursive:      recursive,
+			followSymlinks: followSymlinks,
+		})
+	}
+
+	filename := "archive.zip"
+	if format == oapi.ArchiveFormatTarGz {
+		filename = "archive.tar.gz"
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := writeArchive(ctx, pw, resolved, format, log)
+		if err != nil {
+			log.Error("archive stream aborted", "err", err)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, filename, nil
+}
+
+// archiveSource is one already-resolved entry DownloadArchive/DownloadArchiveGet asked for.
+type archiveSource struct {
+	backend        vfs.VFS
+	path           string // original request path, used as the archive entry name root
+	relPath        string // path relative to backend's root, used for Open/Stat/ReadDir calls
+	recursive      bool
+	followSymlinks bool
+}
+
+func writeArchive(ctx context.Context, w io.Writer, sources []archiveSource, format oapi.ArchiveFormat, log *slog.Logger) error {
+	switch format {
+	case oapi.ArchiveFormatTarGz:
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		if err := writeArchiveEntries(ctx, sources, log, func(name string, fi os.FileInfo) (io.Writer, error) {
+			hdr, err := tar.FileInfoHeader(fi, "")
+			if err != nil {
+				return nil, err
+			}
+			hdr.Name = name
+			if fi.IsDir() {
+				hdr.Name += "/"
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, err
+			}
+			if fi.IsDir() {
+				return io.Discard, nil
+			}
+			return tw, nil
+		}); err != nil {
+			tw.Close()
+			gz.Close()
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gz.Close()
+
+	default: // oapi.ArchiveFormatZip
+		zw := zip.NewWriter(w)
+		if err := writeArchiveEntries(ctx, sources, log, func(name string, fi os.FileInfo) (io.Writer, error) {
+			hdr, err := zip.FileInfoHeader(fi)
+			if err != nil {
+				return nil, err
+			}
+			hdr.Name = name
+			if fi.IsDir() {
+				hdr.Name += "/"
+				hdr.Method = zip.Store
+			} else {
+				hdr.Method = zip.Deflate
+			}
+			return zw.CreateHeader(hdr)
+		}); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+	}
+}
+
+// writeArchiveEntries walks every source (recursing into directories per its own recursive
+// flag), deduplicating entries so overlapping requested paths (e.g. both a directory and a file
+// inside it) only appear once, and calls create for each file/directory found. create returns
+// where to copy the file's bytes (io.Discard for directories, which carry no body).
+func writeArchiveEntries(ctx context.Context, sources []archiveSource, log *slog.Logger, create func(name string, fi os.FileInfo) (io.Writer, error)) error {
+	seen := make(map[string]bool)
+
+	for _, src := range sources {
+		stat, err := src.backend.Stat(ctx, src.relPath)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", src.path, err)
+		}
+		name := filepath.Base(filepath.Clean(src.path))
+		if err := writeArchiveNode(ctx, src, src.relPath, name, stat, seen, create, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeArchiveNode(ctx context.Context, src archiveSource, relPath, name string, fi os.FileInfo, seen map[string]bool, create func(string, os.FileInfo) (io.Writer, error), log *slog.Logger) error {
+	dedupKey := fmt.Sprintf("%p:%s", src.backend, relPath)
+	if seen[dedupKey] {
+		return nil
+	}
+	seen[dedupKey] = true
+
+	if fi.Mode()&os.ModeSymlink != 0 && !src.followSymlinks {
+		log.Warn("skipping symlink in archive", "path", name)
+		return nil
+	}
+
+	if !fi.IsDir() {
+		dst, err := create(name, fi)
+		if err != nil {
+			return err
+		}
+		f, err := src.backend.Open(ctx, relPath)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", name, err)
+		}
+		defer f.Close()
+		_, err = io.Copy(dst, f)
+		return err
+	}
+
+	if _, err := create(name, fi); err != nil {
+		return err
+	}
+	if !src.recursive {
+		return nil
+	}
+
+	entries, err := src.backend.ReadDir(ctx, relPath)
+	if err != nil {
+		return fmt.Errorf("read dir %s: %w", name, err)
+	}
+	for _, entry := range entries {
+		childRel := filepath.Join(relPath, entry.Name())
+		childName := filepath.Join(name, entry.Name())
+		if err := writeArchiveNode(ctx, src, childRel, childName, entry, seen, create, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/recorder"
+)
+
+// broadcastCapable is implemented by recorders that can attach a live RTMP/SRT sink to an
+// already-running recording (today, only FFmpegRecorder). AddRecordingBroadcastTarget
+// type-asserts against it rather than widening the Recorder interface, the same pattern
+// hls.go and recording_progress.go use for their own optional capabilities.
+type broadcastCapable interface {
+	AddBroadcastTarget(ctx context.Context, target recorder.BroadcastTarget) error
+}
+
+// AddRecordingBroadcastTarget attaches an RTMP/SRT push target to an already-running
+// recording. Since a running tee muxer can't gain an output, this restarts ffmpeg with the
+// new output set; FFmpegRecorder.AddBroadcastTarget renames the in-progress segment aside
+// first so nothing already captured is lost.
+func (s *ApiService) AddRecordingBroadcastTarget(ctx context.Context, req oapi.AddRecordingBroadcastTargetRequestObject) (oapi.AddRecordingBroadcastTargetResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Body == nil || req.Body.Url == "" {
+		return oapi.AddRecordingBroadcastTarget400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "url is required"}}, nil
+	}
+
+	recorderID := s.defaultRecorderID
+	if req.Params.Id != nil && *req.Params.Id != "" {
+		recorderID = *req.Params.Id
+	}
+
+	rec, exists := s.recordManager.GetRecorder(recorderID)
+	if !exists {
+		return oapi.AddRecordingBroadcastTarget404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "no recording found"}}, nil
+	}
+	bc, ok := rec.(broadcastCapable)
+	if !ok {
+		return oapi.AddRecordingBroadcastTarget404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "broadcast not supported for this recording"}}, nil
+	}
+
+	format := "flv"
+	if req.Body.Format != nil && *req.Body.Format != "" {
+		format = *req.Body.Format
+	}
+
+	log.Info("attaching broadcast target", "recorder_id", recorderID, "format", format)
+	if err := bc.AddBroadcastTarget(ctx, recorder.BroadcastTarget{
+		URL:     req.Body.Url,
+		Format:  format,
+		Bitrate: req.Body.Bitrate,
+	}); err != nil {
+		log.Error("failed to attach broadcast target", "err", err)
+		return oapi.AddRecordingBroadcastTarget500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	return oapi.AddRecordingBroadcastTarget201Response{}, nil
+}
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,11 +16,17 @@ import (
 	"time"
 
 	"github.com/onkernel/kernel-images/server/lib/chromiumflags"
+	"github.com/onkernel/kernel-images/server/lib/extensionmanifest"
+	"github.com/onkernel/kernel-images/server/lib/exttrust"
 	"github.com/onkernel/kernel-images/server/lib/logger"
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
 	"github.com/onkernel/kernel-images/server/lib/ziputil"
 )
 
+// sseProgressEveryBytes bounds how often countingPartReader pushes an upload_bytes event per
+// part, so a multi-GB extension upload doesn't flood the stream with one event per TCP read.
+const sseProgressEveryBytes = 1 << 20 // 1MB
+
 var nameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,255}$`)
 
 // UploadExtensionsAndRestart handles multipart upload of one or more extension zips, extracts
@@ -54,28 +63,34 @@ func (s *ApiService) UploadExtensionsAndRestart(ctx context.Context, request oap
 		zipTemp     string
 		name        string
 		zipReceived bool
+		signature   []byte
 	}
-	// Process consecutive pairs of fields:
+	// Process consecutive groups of fields:
 	//   extensions.name (text)
 	//   extensions.zip_file (file)
-	// Order may be name then zip or zip then name, but they must be consecutive.
+	//   extensions.signature (file, optional) - detached signature over the raw zip bytes
+	// Order within a group is flexible, but a new extensions.name starts the next group.
 	items := []pending{}
 	var current *pending
 
 	for {
 		part, err := mr.NextPart()
 		if err == io.EOF {
+			if current != nil {
+				items = append(items, *current)
+				current = nil
+			}
 			break
 		}
 		if err != nil {
 			log.Error("read form part", "error", err)
 			return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to read form part"}}, nil
 		}
-		if current == nil {
-			current = &pending{}
-		}
 		switch part.FormName() {
 		case "extensions.zip_file":
+			if current == nil {
+				current = &pending{}
+			}
 			tmp, err := os.CreateTemp("", "ext-*.zip")
 			if err != nil {
 				log.Error("failed to create temporary file", "error", err)
@@ -92,7 +107,7 @@ func (s *ApiService) UploadExtensionsAndRestart(ctx context.Context, request oap
 				return oapi.UploadExtensionsAndRestart500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "internal error"}}, nil
 			}
 			if current.zipReceived {
-				return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "duplicate zip_file in pair"}}, nil
+				return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "duplicate zip_file in group"}}, nil
 			}
 			current.zipTemp = tmp.Name()
 			current.zipReceived = true
@@ -106,28 +121,53 @@ func (s *ApiService) UploadExtensionsAndRestart(ctx context.Context, request oap
 			if name == "" || !nameRegex.MatchString(name) {
 				return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "invalid extension name"}}, nil
 			}
-			if current.name != "" {
-				return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "duplicate name in pair"}}, nil
+			if current != nil && current.name != "" {
+				// This name belongs to the next extension; flush what we have so far.
+				items = append(items, *current)
+				current = nil
+			}
+			if current == nil {
+				current = &pending{}
 			}
 			current.name = name
+		case "extensions.signature":
+			if current == nil {
+				current = &pending{}
+			}
+			sig, err := io.ReadAll(part)
+			if err != nil {
+				log.Error("failed to read signature", "error", err)
+				return oapi.UploadExtensionsAndRestart500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to read signature"}}, nil
+			}
+			current.signature = sig
 		default:
 			return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("invalid field: %s", part.FormName())}}, nil
 		}
-		// If we have both fields, finalize this item
-		if current != nil && current.zipReceived && current.name != "" {
-			items = append(items, *current)
-			current = nil
-		}
-	}
-
-	// If the last pair is incomplete, reject the request
-	if current != nil && (!current.zipReceived || current.name == "") {
-		return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "each extension must include consecutive name and zip_file"}}, nil
 	}
 
 	if len(items) == 0 {
 		return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "no extensions provided"}}, nil
 	}
+	for _, p := range items {
+		if !p.zipReceived || p.name == "" {
+			return oapi.UploadExtensionsAndRestart400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "each extension must include a name and zip_file"}}, nil
+		}
+	}
+
+	verifications := make(map[string]exttrust.VerificationResult, len(items))
+	for _, p := range items {
+		zipBytes, err := os.ReadFile(p.zipTemp)
+		if err != nil {
+			log.Error("failed to read uploaded zip for verification", "name", p.name, "error", err)
+			return oapi.UploadExtensionsAndRestart500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to read uploaded zip"}}, nil
+		}
+		result := s.trustPolicy.VerifyZipSignature(zipBytes, p.signature)
+		result.ExtensionID = p.name
+		if err := s.trustPolicy.Evaluate(result); err != nil {
+			return oapi.UploadExtensionsAndRestart403JSONResponse{ForbiddenErrorJSONResponse: oapi.ForbiddenErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		verifications[p.name] = result
+	}
 
 	// Materialize uploads
 	extBase := "/home/kernel/extensions"
@@ -149,7 +189,29 @@ func (s *ApiService) UploadExtensionsAndRestart(ctx context.Context, request oap
 			log.Error("failed to chown extension dir", "error", err)
 			return oapi.UploadExtensionsAndRestart500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to chown extension dir"}}, nil
 		}
-		log.Info("installed extension", "name", p.name)
+
+		sum, err := sha256SumFile(p.zipTemp)
+		if err != nil {
+			log.Error("failed to checksum uploaded zip", "name", p.name, "error", err)
+			return oapi.UploadExtensionsAndRestart500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to checksum uploaded zip"}}, nil
+		}
+		size := int64(0)
+		if fi, err := os.Stat(p.zipTemp); err == nil {
+			size = fi.Size()
+		}
+		if err := s.extensions.Put(extensionmanifest.Entry{
+			Name:         p.name,
+			InstalledAt:  time.Now(),
+			Source:       extensionmanifest.SourceUpload,
+			SHA256:       sum,
+			SizeBytes:    size,
+			Enabled:      true,
+			Verification: verifications[p.name],
+		}); err != nil {
+			log.Error("failed to update extension manifest", "name", p.name, "error", err)
+			return oapi.UploadExtensionsAndRestart500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to update extension manifest"}}, nil
+		}
+		log.Info("installed extension", "name", p.name, "verified", verifications[p.name].Verified)
 	}
 
 	// Build flags overlay file in /chromium/flags, merging with existing flags
@@ -219,3 +281,322 @@ func (s *ApiService) UploadExtensionsAndRestart(ctx context.Context, request oap
 		return oapi.UploadExtensionsAndRestart500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "devtools not ready in time"}}, nil
 	}
 }
+
+// writeSSEEvent writes one Server-Sent Event frame and flushes it immediately, so the client
+// sees it as soon as it's written rather than once the handler's write buffer fills.
+func writeSSEEvent(w http.ResponseWriter, flusher http.Flusher, event string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		b = []byte(`{}`)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	flusher.Flush()
+}
+
+// countingPartReader wraps a multipart.Part and calls onProgress every sseProgressEveryBytes, so
+// HandleUploadExtensionsSSE can emit upload_bytes events while io.Copy is still reading a part,
+// instead of only learning the final size once the copy completes.
+type countingPartReader struct {
+	io.Reader
+	read         int64
+	lastReported int64
+	onProgress   func(total int64)
+}
+
+func (cr *countingPartReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	if n > 0 {
+		cr.read += int64(n)
+		if cr.read-cr.lastReported >= sseProgressEveryBytes {
+			cr.lastReported = cr.read
+			cr.onProgress(cr.read)
+		}
+	}
+	return n, err
+}
+
+// HandleUploadExtensionsSSE is UploadExtensionsAndRestart's streaming counterpart: a client that
+// sends Accept: text/event-stream against the same route is routed here (see
+// main.go's content-negotiation middleware) instead of the oapi strict handler, since SSE needs
+// the raw http.ResponseWriter a strict handler never exposes. It mirrors
+// UploadExtensionsAndRestart's phases exactly, emitting one event per phase instead of returning
+// only a terminal status.
+func (s *ApiService) HandleUploadExtensionsSSE(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+	start := time.Now()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	s.stz.Disable(ctx)
+	defer s.stz.Enable(ctx)
+
+	fail := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		log.Error("upload extensions (sse): " + msg)
+		writeSSEEvent(w, flusher, "error", map[string]string{"message": msg})
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		fail("failed to read multipart body: %v", err)
+		return
+	}
+
+	temps := []string{}
+	defer func() {
+		for _, p := range temps {
+			_ = os.Remove(p)
+		}
+	}()
+
+	type pending struct {
+		zipTemp     string
+		name        string
+		zipReceived bool
+		signature   []byte
+	}
+	items := []pending{}
+	var current *pending
+
+	writeSSEEvent(w, flusher, "upload_started", map[string]any{})
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			if current != nil {
+				items = append(items, *current)
+				current = nil
+			}
+			break
+		}
+		if err != nil {
+			fail("failed to read form part: %v", err)
+			return
+		}
+		switch part.FormName() {
+		case "extensions.zip_file":
+			if current == nil {
+				current = &pending{}
+			}
+			tmp, err := os.CreateTemp("", "ext-*.zip")
+			if err != nil {
+				fail("failed to create temporary file: %v", err)
+				return
+			}
+			temps = append(temps, tmp.Name())
+			partName := part.FileName()
+			counting := &countingPartReader{
+				Reader: part,
+				onProgress: func(total int64) {
+					writeSSEEvent(w, flusher, "upload_bytes", map[string]any{"part": partName, "bytes": total})
+				},
+			}
+			if _, err := io.Copy(tmp, counting); err != nil {
+				tmp.Close()
+				fail("failed to read zip file: %v", err)
+				return
+			}
+			if err := tmp.Close(); err != nil {
+				fail("failed to finalize temporary file: %v", err)
+				return
+			}
+			writeSSEEvent(w, flusher, "upload_bytes", map[string]any{"part": partName, "bytes": counting.read})
+			if current.zipReceived {
+				fail("duplicate zip_file in group")
+				return
+			}
+			current.zipTemp = tmp.Name()
+			current.zipReceived = true
+		case "extensions.name":
+			b, err := io.ReadAll(part)
+			if err != nil {
+				fail("failed to read name: %v", err)
+				return
+			}
+			name := strings.TrimSpace(string(b))
+			if name == "" || !nameRegex.MatchString(name) {
+				fail("invalid extension name")
+				return
+			}
+			if current != nil && current.name != "" {
+				items = append(items, *current)
+				current = nil
+			}
+			if current == nil {
+				current = &pending{}
+			}
+			current.name = name
+		case "extensions.signature":
+			if current == nil {
+				current = &pending{}
+			}
+			sig, err := io.ReadAll(part)
+			if err != nil {
+				fail("failed to read signature: %v", err)
+				return
+			}
+			current.signature = sig
+		default:
+			fail("invalid field: %s", part.FormName())
+			return
+		}
+	}
+
+	if len(items) == 0 {
+		fail("no extensions provided")
+		return
+	}
+	for _, p := range items {
+		if !p.zipReceived || p.name == "" {
+			fail("each extension must include a name and zip_file")
+			return
+		}
+	}
+
+	verifications := make(map[string]exttrust.VerificationResult, len(items))
+	for _, p := range items {
+		zipBytes, err := os.ReadFile(p.zipTemp)
+		if err != nil {
+			fail("failed to read uploaded zip for verification: %v", err)
+			return
+		}
+		result := s.trustPolicy.VerifyZipSignature(zipBytes, p.signature)
+		result.ExtensionID = p.name
+		if err := s.trustPolicy.Evaluate(result); err != nil {
+			fail("%v", err)
+			return
+		}
+		verifications[p.name] = result
+		writeSSEEvent(w, flusher, "verification", map[string]any{"name": p.name, "signed": result.Signed, "verified": result.Verified})
+	}
+
+	extBase := "/home/kernel/extensions"
+	for i, p := range items {
+		dest := filepath.Join(extBase, p.name)
+		if err := os.MkdirAll(dest, 0o755); err != nil {
+			fail("failed to create extension dir: %v", err)
+			return
+		}
+		if err := ziputil.Unzip(p.zipTemp, dest); err != nil {
+			fail("invalid zip file: %v", err)
+			return
+		}
+		if err := exec.Command("chown", "-R", "kernel:kernel", dest).Run(); err != nil {
+			fail("failed to chown extension dir: %v", err)
+			return
+		}
+		sum, err := sha256SumFile(p.zipTemp)
+		if err != nil {
+			fail("failed to checksum uploaded zip: %v", err)
+			return
+		}
+		size := int64(0)
+		if fi, err := os.Stat(p.zipTemp); err == nil {
+			size = fi.Size()
+		}
+		if err := s.extensions.Put(extensionmanifest.Entry{
+			Name:         p.name,
+			InstalledAt:  time.Now(),
+			Source:       extensionmanifest.SourceUpload,
+			SHA256:       sum,
+			SizeBytes:    size,
+			Enabled:      true,
+			Verification: verifications[p.name],
+		}); err != nil {
+			fail("failed to update extension manifest: %v", err)
+			return
+		}
+		writeSSEEvent(w, flusher, "unzip_progress", map[string]any{"name": p.name, "index": i + 1, "total": len(items)})
+		writeSSEEvent(w, flusher, "chown_done", map[string]string{"name": p.name})
+	}
+
+	var paths []string
+	for _, p := range items {
+		paths = append(paths, filepath.Join(extBase, p.name))
+	}
+
+	const flagsPath = "/chromium/flags"
+	existingTokens, err := chromiumflags.ReadOptionalFlagFile(flagsPath)
+	if err != nil {
+		fail("failed to read existing flags: %v", err)
+		return
+	}
+	newTokens := []string{
+		fmt.Sprintf("--disable-extensions-except=%s", strings.Join(paths, ",")),
+		fmt.Sprintf("--load-extension=%s", strings.Join(paths, ",")),
+	}
+	mergedTokens := chromiumflags.MergeFlags(existingTokens, newTokens)
+
+	if err := os.MkdirAll("/chromium", 0o755); err != nil {
+		fail("failed to create chromium dir: %v", err)
+		return
+	}
+	if err := chromiumflags.WriteFlagFile(flagsPath, mergedTokens); err != nil {
+		fail("failed to write overlay flags: %v", err)
+		return
+	}
+	writeSSEEvent(w, flusher, "flags_written", map[string]any{"paths": paths})
+
+	updates, cancelSub := s.upstreamMgr.Subscribe()
+	defer cancelSub()
+
+	cmdCtx, cancelCmd := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancelCmd()
+
+	outReader, outWriter := io.Pipe()
+	lines := make(chan string, 16)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	restartErrCh := make(chan error, 1)
+	log.Info("restarting chromium via supervisorctl")
+	writeSSEEvent(w, flusher, "chromium_restarting", map[string]any{})
+	cmd := exec.CommandContext(cmdCtx, "supervisorctl", "-c", "/etc/supervisor/supervisord.conf", "restart", "chromium")
+	cmd.Stdout = outWriter
+	cmd.Stderr = outWriter
+	go func() {
+		err := cmd.Run()
+		outWriter.Close()
+		restartErrCh <- err
+	}()
+
+	timeout := time.NewTimer(15 * time.Second)
+	defer timeout.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			writeSSEEvent(w, flusher, "supervisorctl_output", map[string]string{"line": line})
+		case err := <-restartErrCh:
+			if err != nil {
+				log.Error("failed to restart chromium", "error", err)
+				fail("supervisorctl restart failed: %v", err)
+				return
+			}
+		case <-updates:
+			log.Info("devtools ready", "elapsed", time.Since(start).String())
+			writeSSEEvent(w, flusher, "devtools_ready", map[string]any{"elapsed": time.Since(start).String()})
+			return
+		case <-timeout.C:
+			log.Info("devtools not ready in time", "elapsed", time.Since(start).String())
+			fail("devtools not ready in time")
+			return
+		}
+	}
+}
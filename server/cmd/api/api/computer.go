@@ -10,6 +10,16 @@ import (
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
 )
 
+// clickMouseButtonMap maps the oapi click button enum to the xdotool button code both ClickMouse
+// and the batch action compiler in computer_batch.go need, so the two stay in sync.
+var clickMouseButtonMap = map[oapi.ClickMouseRequestButton]string{
+	oapi.Left:    "1",
+	oapi.Middle:  "2",
+	oapi.Right:   "3",
+	oapi.Back:    "8",
+	oapi.Forward: "9",
+}
+
 func (s *ApiService) ClickMouse(ctx context.Context, request oapi.ClickMouseRequestObject) (oapi.ClickMouseResponseObject, error) {
 	log := logger.FromContext(ctx)
 	log.Info("ClickMouse", "request", request)
@@ -25,15 +35,7 @@ func (s *ApiService) ClickMouse(ctx context.Context, request oapi.ClickMouseRequ
 		return oapi.ClickMouse400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "coordinates must be non-negative"}}, nil
 	}
 
-	// Map button enum to xdotool button code
-	buttonMap := map[oapi.ClickMouseRequestButton]string{
-		oapi.Left:    "1",
-		oapi.Middle:  "2",
-		oapi.Right:   "3",
-		oapi.Back:    "8",
-		oapi.Forward: "9",
-	}
-	btn, ok := buttonMap[body.Button]
+	btn, ok := clickMouseButtonMap[body.Button]
 	if !ok {
 		return oapi.ClickMouse400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("unsupported button: %s", body.Button)}}, nil
 	}
@@ -0,0 +1,445 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// computerActionTimeout bounds how long xdotoolManager.run waits for xdotool to report completion
+// of a single compiled action, on top of that action's own expected duration (see
+// compileComputerAction's extraWait return), before treating it - and every action after it in the
+// same batch - as failed and killing the worker so the next batch gets a fresh one instead of one
+// left in an unknown, possibly wedged state.
+const computerActionTimeout = 5 * time.Second
+
+// ComputerAction is one step of a POST /computer/actions/batch request. Only the fields relevant
+// to Type are read. This doesn't go through the oapi strict handler - a batch's per-action
+// partial-failure reporting doesn't fit that single-typed-response model, the same reason the
+// resumable upload and SSE/websocket routes in this package are raw handlers too - so there's no
+// generated request/response pair to reuse; these types are hand-written instead.
+type ComputerAction struct {
+	Type string `json:"type"`
+
+	// move, click
+	X *int `json:"x,omitempty"`
+	Y *int `json:"y,omitempty"`
+
+	// click
+	Button    *oapi.ClickMouseRequestButton    `json:"button,omitempty"`
+	ClickType *oapi.ClickMouseRequestClickType `json:"click_type,omitempty"`
+	NumClicks *int                             `json:"num_clicks,omitempty"`
+	HoldKeys  *[]string                        `json:"hold_keys,omitempty"`
+
+	// key
+	Keys []string `json:"keys,omitempty"`
+
+	// type
+	Text string `json:"text,omitempty"`
+
+	// scroll
+	DX *int `json:"dx,omitempty"`
+	DY *int `json:"dy,omitempty"`
+
+	// sleep
+	DurationMs *int `json:"duration_ms,omitempty"`
+
+	// wait_image
+	ImagePath *string `json:"image_path,omitempty"`
+	TimeoutMs *int    `json:"timeout_ms,omitempty"`
+}
+
+// ComputerActionResult reports the outcome of one ComputerAction from a batch, at the same index
+// it appeared in the request, so a caller scripting a form-fill sequence can tell exactly which
+// step (if any) failed without the whole batch aborting on the first error.
+type ComputerActionResult struct {
+	Index   int    `json:"index"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BatchComputerActionsResult is the JSON body HandleBatchComputerActions returns.
+type BatchComputerActionsResult struct {
+	Results []ComputerActionResult `json:"results"`
+}
+
+// compileComputerAction translates a into the xdotool script-mode lines that perform it, reusing
+// clickMouseButtonMap so a "click" batch action behaves identically to ClickMouse. It also returns
+// how long the action itself is expected to run beyond the usual xdotool round-trip - only "sleep"
+// has one - so run can size that action's awaitMarker timeout accordingly instead of applying a
+// single fixed timeout to every action regardless of how long it legitimately takes.
+//
+// wait_image isn't compiled here: xdotool has no image-matching verb, so it's handled as a
+// separate branch in run that polls screenshots via awaitImage instead of writing an xdotool
+// script (see computer_wait_image.go).
+func compileComputerAction(a ComputerAction) ([]string, time.Duration, error) {
+	switch a.Type {
+	case "move":
+		if a.X == nil || a.Y == nil {
+			return nil, 0, fmt.Errorf("move requires x and y")
+		}
+		return []string{fmt.Sprintf("mousemove --sync %d %d", *a.X, *a.Y)}, 0, nil
+
+	case "click":
+		if a.X == nil || a.Y == nil {
+			return nil, 0, fmt.Errorf("click requires x and y")
+		}
+		if *a.X < 0 || *a.Y < 0 {
+			return nil, 0, fmt.Errorf("coordinates must be non-negative")
+		}
+
+		button := oapi.Left
+		if a.Button != nil {
+			button = *a.Button
+		}
+		btn, ok := clickMouseButtonMap[button]
+		if !ok {
+			return nil, 0, fmt.Errorf("unsupported button: %s", button)
+		}
+
+		clickType := oapi.Click
+		if a.ClickType != nil {
+			clickType = *a.ClickType
+		}
+		numClicks := 1
+		if a.NumClicks != nil && *a.NumClicks > 0 {
+			numClicks = *a.NumClicks
+		}
+
+		var lines []string
+		if a.HoldKeys != nil {
+			for _, key := range *a.HoldKeys {
+				lines = append(lines, "keydown "+key)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("mousemove --sync %d %d", *a.X, *a.Y))
+		switch clickType {
+		case oapi.Down:
+			lines = append(lines, "mousedown "+btn)
+		case oapi.Up:
+			lines = append(lines, "mouseup "+btn)
+		case oapi.Click:
+			line := "click"
+			if numClicks > 1 {
+				line += fmt.Sprintf(" --repeat %d", numClicks)
+			}
+			lines = append(lines, line+" "+btn)
+		default:
+			return nil, 0, fmt.Errorf("unsupported click type: %s", clickType)
+		}
+		if a.HoldKeys != nil {
+			for _, key := range *a.HoldKeys {
+				lines = append(lines, "keyup "+key)
+			}
+		}
+		return lines, 0, nil
+
+	case "key":
+		if len(a.Keys) == 0 {
+			return nil, 0, fmt.Errorf("key requires keys")
+		}
+		return []string{"key " + strings.Join(a.Keys, "+")}, 0, nil
+
+	case "type":
+		if a.Text == "" {
+			return nil, 0, fmt.Errorf("type requires text")
+		}
+		return []string{"type " + xdotoolScriptQuote(a.Text)}, 0, nil
+
+	case "scroll":
+		if a.DX == nil && a.DY == nil {
+			return nil, 0, fmt.Errorf("scroll requires dx and/or dy")
+		}
+		var lines []string
+		if a.DY != nil && *a.DY != 0 {
+			button := "4" // up
+			if *a.DY > 0 {
+				button = "5" // down
+			}
+			lines = append(lines, fmt.Sprintf("click --repeat %d %s", intAbs(*a.DY), button))
+		}
+		if a.DX != nil && *a.DX != 0 {
+			button := "6" // left
+			if *a.DX > 0 {
+				button = "7" // right
+			}
+			lines = append(lines, fmt.Sprintf("click --repeat %d %s", intAbs(*a.DX), button))
+		}
+		return lines, 0, nil
+
+	case "sleep":
+		ms := 0
+		if a.DurationMs != nil {
+			ms = *a.DurationMs
+		}
+		if ms <= 0 {
+			return nil, 0, fmt.Errorf("sleep requires a positive duration_ms")
+		}
+		return []string{fmt.Sprintf("sleep %.3f", float64(ms)/1000)}, time.Duration(ms) * time.Millisecond, nil
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported action type: %q", a.Type)
+	}
+}
+
+// xdotoolScriptQuote double-quotes s for use as a single xdotool script-mode argument, escaping
+// the characters that would otherwise end the quoted span or be treated as an escape themselves.
+func xdotoolScriptQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func intAbs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// xdotoolManager owns one long-lived `xdotool -` process (script mode, reading commands from
+// stdin) so a batch of actions - and every batch after it - reuses the same process instead of
+// paying xdotool's ~10-30ms process-launch overhead once per action, the way ClickMouse's single
+// exec.Command per click does.
+type xdotoolManager struct {
+	mu sync.Mutex // serializes script execution so concurrent batch requests don't interleave
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string   // stdout lines, closed when the reader goroutine sees EOF
+	done  chan struct{} // closed once the worker process has exited
+
+	// displayFn returns the X display (e.g. ":1") wait_image actions capture screenshots
+	// against - the same display xdotool itself drives. It's a func rather than a plain string
+	// since newXdotoolManager runs before the *ApiService it belongs to is fully constructed.
+	displayFn func() string
+}
+
+// newXdotoolManager returns a manager with no worker spawned yet - spawning eagerly here would
+// make ApiService.New fail if Xvfb/X isn't up yet at construction time, before it's needed. The
+// first call to run spawns it lazily. displayFn is consulted by wait_image actions; see
+// xdotoolManager.displayFn.
+func newXdotoolManager(displayFn func() string) *xdotoolManager {
+	done := make(chan struct{})
+	close(done)
+	return &xdotoolManager{done: done, displayFn: displayFn}
+}
+
+func (m *xdotoolManager) spawn() error {
+	cmd := exec.Command("xdotool", "-")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open xdotool stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open xdotool stdout: %w", err)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start xdotool: %w", err)
+	}
+
+	done := make(chan struct{})
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+		close(lines)
+	}()
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+
+	m.cmd = cmd
+	m.stdin = stdin
+	m.lines = lines
+	m.done = done
+	return nil
+}
+
+// run compiles and executes each of actions in order against m's worker, respawning it first if
+// the previous one has exited. Each action's script is followed by a uniquely marked `exec echo`
+// line so run can tell when xdotool has finished consuming it - xdotool script mode otherwise
+// gives no feedback about where it is in the stream. Once any action fails (a compile error, a
+// write error, or a timeout waiting for its marker), every action after it in the batch is
+// reported as skipped rather than attempted, since the worker's position in a failed script is no
+// longer trustworthy.
+//
+// wait_image is handled before compileComputerAction ever sees it: it has no xdotool equivalent,
+// so it polls screenshots via awaitImage instead of writing to the worker's stdin. It still
+// participates in the same abort-on-failure sequencing as every other action.
+func (m *xdotoolManager) run(ctx context.Context, actions []ComputerAction) []ComputerActionResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]ComputerActionResult, len(actions))
+	for i := range results {
+		results[i].Index = i
+	}
+
+	select {
+	case <-m.done:
+		if err := m.spawn(); err != nil {
+			for i := range results {
+				results[i].Error = fmt.Sprintf("xdotool unavailable: %v", err)
+			}
+			return results
+		}
+	default:
+	}
+
+	aborted := false
+	for i, action := range actions {
+		if aborted {
+			results[i].Error = "skipped: a prior action in this batch failed"
+			continue
+		}
+
+		if action.Type == "wait_image" {
+			if err := m.runWaitImage(ctx, action); err != nil {
+				results[i].Error = err.Error()
+				aborted = true
+				continue
+			}
+			results[i].Success = true
+			continue
+		}
+
+		lines, extraWait, err := compileComputerAction(action)
+		if err != nil {
+			results[i].Error = err.Error()
+			aborted = true
+			continue
+		}
+
+		marker := fmt.Sprintf("__kernel_batch_%s__", uuid.New().String())
+		script := strings.Join(lines, "\n") + "\nexec echo " + marker + "\n"
+
+		if _, err := io.WriteString(m.stdin, script); err != nil {
+			results[i].Error = fmt.Sprintf("failed to write to xdotool: %v", err)
+			aborted = true
+			continue
+		}
+		if err := m.awaitMarker(marker, computerActionTimeout+extraWait); err != nil {
+			results[i].Error = err.Error()
+			aborted = true
+			continue
+		}
+		results[i].Success = true
+	}
+	return results
+}
+
+// runWaitImage validates a's wait_image fields and polls for its template via awaitImage,
+// against m.displayFn() - the same display xdotool itself drives.
+func (m *xdotoolManager) runWaitImage(ctx context.Context, a ComputerAction) error {
+	if a.ImagePath == nil || *a.ImagePath == "" {
+		return fmt.Errorf("wait_image requires image_path")
+	}
+	timeout := waitImageDefaultTimeout
+	if a.TimeoutMs != nil {
+		if *a.TimeoutMs <= 0 {
+			return fmt.Errorf("timeout_ms must be positive")
+		}
+		timeout = time.Duration(*a.TimeoutMs) * time.Millisecond
+	}
+	return awaitImage(ctx, m.displayFn(), *a.ImagePath, timeout)
+}
+
+// awaitMarker blocks until marker appears on m's stdout, the worker exits, or timeout elapses -
+// killing the worker in the timeout case so the next run respawns a clean one instead of reusing
+// one whose place in the script stream is unknown. timeout is computerActionTimeout plus whatever
+// extra time the action itself is expected to take (e.g. a "sleep" action's own duration_ms), so a
+// long-but-valid sleep doesn't get killed out from under the batch mid-wait.
+func (m *xdotoolManager) awaitMarker(marker string, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case line, ok := <-m.lines:
+			if !ok {
+				return fmt.Errorf("xdotool exited before completing action")
+			}
+			if strings.TrimSpace(line) == marker {
+				return nil
+			}
+		case <-timer.C:
+			if m.cmd.Process != nil {
+				_ = m.cmd.Process.Kill()
+			}
+			return fmt.Errorf("timed out waiting for xdotool to complete action")
+		}
+	}
+}
+
+// batchComputerActionsRequest is the JSON body HandleBatchComputerActions accepts.
+type batchComputerActionsRequest struct {
+	Actions []ComputerAction `json:"actions"`
+}
+
+// HandleBatchComputerActions handles POST /computer/actions/batch: it compiles an ordered list of
+// typed actions into a single xdotool script and runs it against s.xdotoolMgr's persistent worker
+// instead of spawning one short-lived xdotool process per action, which matters for an automation
+// sequence of dozens of moves/clicks/keypresses where that per-action process-launch overhead
+// would otherwise add up. Each action's outcome is reported independently in the response.
+func (s *ApiService) HandleBatchComputerActions(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	done, ok := s.checkExecRateLimit(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+
+	var req batchComputerActionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Actions) == 0 {
+		http.Error(w, "actions is required", http.StatusBadRequest)
+		return
+	}
+
+	results := s.xdotoolMgr.run(r.Context(), req.Actions)
+
+	for _, res := range results {
+		if !res.Success {
+			log.Error("batch computer actions had at least one failure", "results", results)
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(BatchComputerActionsResult{Results: results})
+}
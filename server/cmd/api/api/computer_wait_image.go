@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// waitImagePollInterval is how often awaitImage takes a new screenshot while polling for a
+// template match.
+const waitImagePollInterval = 250 * time.Millisecond
+
+// waitImageDefaultTimeout is how long a "wait_image" batch action waits for its template to
+// appear on screen when the request doesn't set timeout_ms.
+const waitImageDefaultTimeout = 5 * time.Second
+
+// captureScreenshotPNG grabs a single frame of display (e.g. ":1") via ffmpeg's x11grab input,
+// the same capture path recorder.ffmpegArgs uses for recordings, and decodes it. ffmpeg is
+// already a hard runtime dependency of this binary (see server/lib/recorder), so reusing it here
+// avoids pulling in a second screen-capture tool just for a one-off frame.
+func captureScreenshotPNG(ctx context.Context, display string) (image.Image, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-f", "x11grab",
+		"-i", display,
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-vcodec", "png",
+		"-y",
+		"-",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to capture screenshot: %w: %s", err, stderr.String())
+	}
+
+	img, err := png.Decode(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	return img, nil
+}
+
+// loadTemplatePNG reads and decodes the template image at path for locateTemplate to search for.
+func loadTemplatePNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open template image: %w", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode template image: %w", err)
+	}
+	return img, nil
+}
+
+// locateTemplate does a brute-force search for needle's top-left corner within haystack,
+// returning the first position (scanning top-to-bottom, left-to-right) where every pixel of
+// needle is within a small tolerance of haystack's pixel at that offset. This is simple
+// per-pixel matching, not feature-based template matching - good enough for the wait_image use
+// case of polling for a known UI element (an icon, a loaded page's static content) to render
+// on-screen, and doesn't require pulling in an image-processing library this repo doesn't
+// otherwise depend on.
+func locateTemplate(haystack, needle image.Image) (x, y int, found bool) {
+	hb := haystack.Bounds()
+	nb := needle.Bounds()
+	nw, nh := nb.Dx(), nb.Dy()
+	if nw == 0 || nh == 0 || nw > hb.Dx() || nh > hb.Dy() {
+		return 0, 0, false
+	}
+
+	const tolerance = 16 // out of 0xff per channel (scaled to RGBA's 16-bit range in pixelsMatch); tolerates lossy capture/compression noise
+
+	for oy := hb.Min.Y; oy <= hb.Max.Y-nh; oy++ {
+		for ox := hb.Min.X; ox <= hb.Max.X-nw; ox++ {
+			if pixelsMatch(haystack, needle, ox, oy, tolerance) {
+				return ox, oy, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// pixelsMatch reports whether every pixel of needle matches haystack within tolerance, assuming
+// needle's top-left corner is placed at (ox, oy) in haystack.
+func pixelsMatch(haystack, needle image.Image, ox, oy, tolerance int) bool {
+	nb := needle.Bounds()
+	for ny := nb.Min.Y; ny < nb.Max.Y; ny++ {
+		for nx := nb.Min.X; nx < nb.Max.X; nx++ {
+			hr, hg, hb, _ := haystack.At(ox+nx-nb.Min.X, oy+ny-nb.Min.Y).RGBA()
+			nr, ng, nbl, _ := needle.At(nx, ny).RGBA()
+			if absDiff(hr, nr) > uint32(tolerance)<<8 ||
+				absDiff(hg, ng) > uint32(tolerance)<<8 ||
+				absDiff(hb, nbl) > uint32(tolerance)<<8 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// awaitImage polls display (via captureScreenshotPNG) for the template at imagePath to appear
+// on-screen, checking every waitImagePollInterval until it's found or timeout elapses.
+func awaitImage(ctx context.Context, display, imagePath string, timeout time.Duration) error {
+	template, err := loadTemplatePNG(imagePath)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		screenshot, err := captureScreenshotPNG(ctx, display)
+		if err != nil {
+			return err
+		}
+		if _, _, found := locateTemplate(screenshot, template); found {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for image %q to appear on screen", imagePath)
+		}
+
+		timer := time.NewTimer(waitImagePollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
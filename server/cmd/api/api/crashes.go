@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/onkernel/kernel-images/server/lib/crashreport"
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/profilesnapshot"
+)
+
+// crashInstanceIDEnvVar names the kernel instance crashreport.Collect tags every report it
+// captures with, if the deployment sets it - there's no other notion of "which instance is this"
+// anywhere in this server, so crash reports are the first thing that needs one.
+const crashInstanceIDEnvVar = "KERNEL_INSTANCE_ID"
+
+// ListCrashes returns every crash report crashStore has captured, most recent first. See
+// crashreport.Collect (wired into restartChromium in display_layout.go) for how reports get here.
+func (s *ApiService) ListCrashes(ctx context.Context, req oapi.ListCrashesRequestObject) (oapi.ListCrashesResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	reports, err := s.crashStore.List()
+	if err != nil {
+		log.Error("failed to list crash reports", "err", err)
+		return oapi.ListCrashes500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	crashes := make([]oapi.CrashReport, len(reports))
+	for i, r := range reports {
+		crashes[i] = crashReportToOapi(r)
+	}
+	return oapi.ListCrashes200JSONResponse{Crashes: crashes}, nil
+}
+
+// GetCrash returns req.Id's metadata, or 404 if crashStore has no report under that ID.
+func (s *ApiService) GetCrash(ctx context.Context, req oapi.GetCrashRequestObject) (oapi.GetCrashResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	report, err := s.crashStore.Get(req.Id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return oapi.GetCrash404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "crash report not found"}}, nil
+		}
+		log.Error("failed to read crash report", "id", req.Id, "err", err)
+		return oapi.GetCrash500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	return oapi.GetCrash200JSONResponse(crashReportToOapi(report)), nil
+}
+
+// DownloadCrash streams req.Id's captured minidump, the same octet-stream shape ReadFile (fs.go)
+// returns for a plain file download.
+func (s *ApiService) DownloadCrash(ctx context.Context, req oapi.DownloadCrashRequestObject) (oapi.DownloadCrashResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	report, err := s.crashStore.Get(req.Id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return oapi.DownloadCrash404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "crash report not found"}}, nil
+		}
+		log.Error("failed to read crash report", "id", req.Id, "err", err)
+		return oapi.DownloadCrash500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	if report.MinidumpSize == 0 {
+		return oapi.DownloadCrash404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "no minidump was captured for this crash"}}, nil
+	}
+
+	f, err := s.crashStore.OpenMinidump(req.Id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return oapi.DownloadCrash404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "no minidump was captured for this crash"}}, nil
+		}
+		log.Error("failed to open minidump", "id", req.Id, "err", err)
+		return oapi.DownloadCrash500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	return oapi.DownloadCrash200ApplicationoctetStreamResponse{
+		Body:          f,
+		ContentLength: report.MinidumpSize,
+	}, nil
+}
+
+// collectCrashes runs crashreport.Collect against the profile restartChromium is about to
+// restart out from under - called before that restart so a crashed Chromium's minidump is
+// captured before anything in userDataDir gets touched again. profileSnapshotID is whatever
+// checkpoint/snapshot was just restored onto this instance, if any (see restartChromium's doc).
+func (s *ApiService) collectCrashes(ctx context.Context, log *slog.Logger, profileSnapshotID string) {
+	meta := crashreport.Metadata{
+		InstanceID:        os.Getenv(crashInstanceIDEnvVar),
+		ChromiumVersion:   profilesnapshot.ChromiumBuildID(),
+		ProfileSnapshotID: profileSnapshotID,
+	}
+
+	captured, err := crashreport.Collect(ctx, s.crashStore, s.crashSinks, userDataDir, chromiumLogPath, meta, log)
+	if err != nil {
+		log.Error("failed to collect chromium crash reports", "err", err)
+		return
+	}
+	for _, r := range captured {
+		log.Warn("captured chromium crash report", "id", r.ID, "minidump_size", r.MinidumpSize)
+	}
+}
+
+func crashReportToOapi(r crashreport.Report) oapi.CrashReport {
+	return oapi.CrashReport{
+		Id:                r.ID,
+		InstanceId:        r.InstanceID,
+		ChromiumVersion:   r.ChromiumVersion,
+		ProfileSnapshotId: r.ProfileSnapshotID,
+		Timestamp:         r.Timestamp,
+		StderrTail:        r.StderrTail,
+		MinidumpSize:      r.MinidumpSize,
+	}
+}
@@ -2,25 +2,24 @@ package api
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/nrednav/cuid2"
+	"github.com/onkernel/kernel-images/server/lib/display"
 	"github.com/onkernel/kernel-images/server/lib/logger"
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
 )
 
 // PatchDisplay updates the display configuration. When require_idle
 // is true (default), it refuses to resize while live view or recording/replay is active.
-// This method automatically detects whether the system is running with Xorg (headful)
-// or Xvfb (headless) and uses the appropriate method to change resolution.
+// The actual resolution change is delegated to s.displayDriver, selected once at startup
+// based on whether the system runs Xorg (headful), Xvfb (headless), or Neko.
 func (s *ApiService) PatchDisplay(ctx context.Context, req oapi.PatchDisplayRequestObject) (oapi.PatchDisplayResponseObject, error) {
 	log := logger.FromContext(ctx)
 	if req.Body == nil {
@@ -60,49 +59,41 @@ func (s *ApiService) PatchDisplay(ctx context.Context, req oapi.PatchDisplayRequ
 		requireIdle = *req.Body.RequireIdle
 	}
 
-	// Check if resize is safe (no active sessions or recordings)
+	// Acquiring the write lease blocks new read leases (a viewer connecting, a recording
+	// starting) and waits for existing ones to drain, closing the race a one-shot "is
+	// anything active?" sample would leave between this check and the SetMode call below.
+	// requireIdle=false skips the lease entirely, same as it skipped the old point-in-time
+	// check: the caller is explicitly asking to resize regardless of what's active.
 	if requireIdle {
-		live := s.getActiveNekoSessions(ctx)
-		isRecording := s.anyRecordingActive(ctx)
-		isReplaying := false // replay not currently implemented
-		resizableNow := (live == 0) && !isRecording && !isReplaying
-
-		log.Info("checking if resize is safe", "live_sessions", live, "is_recording", isRecording, "is_replaying", isReplaying, "resizable", resizableNow)
+		// TryLock treats a timeout <= 0 as "wait indefinitely", so without a positive
+		// wait_for_idle_seconds a minimal timeout is used: the resize is refused at once if
+		// anything holds a read lease.
+		waitFor := time.Nanosecond
+		if req.Body.WaitForIdleSeconds != nil && *req.Body.WaitForIdleSeconds > 0 {
+			waitFor = time.Duration(*req.Body.WaitForIdleSeconds) * time.Second
+		}
 
-		if !resizableNow {
+		log.Info("acquiring display write lease", "wait_for_idle_seconds", waitFor.Seconds())
+		release, err := s.displayLock.TryLock(ctx, waitFor)
+		if err != nil {
+			log.Info("resize refused: could not acquire display write lease", "error", err)
 			return oapi.PatchDisplay409JSONResponse{
 				ConflictErrorJSONResponse: oapi.ConflictErrorJSONResponse{
 					Message: "resize refused: live view or recording/replay active",
 				},
 			}, nil
 		}
+		defer release()
 	}
 
-	// Detect display mode (xorg or xvfb)
-	displayMode := s.detectDisplayMode(ctx)
-
-	// Parse restartChromium flag (default depends on mode)
-	restartChrome := (displayMode == "xvfb") // default true for xvfb, false for xorg
+	// restartChromium defaults to true for Xvfb (whose own restart tears chromium's X
+	// connection down anyway) and false for Xorg/Neko, which apply changes live.
+	restartChrome := s.displayMode == "xvfb"
 	if req.Body.RestartChromium != nil {
 		restartChrome = *req.Body.RestartChromium
 	}
 
-	// Route to appropriate resolution change handler
-	var err error
-	if displayMode == "xorg" {
-		if s.isNekoEnabled() {
-			log.Info("using Neko API for Xorg resolution change")
-			err = s.setResolutionXorgViaNeko(ctx, width, height, refreshRate, restartChrome)
-		} else {
-			log.Info("using xrandr for Xorg resolution change (Neko disabled)")
-			err = s.setResolutionXorgViaXrandr(ctx, width, height, refreshRate, restartChrome)
-		}
-	} else {
-		log.Info("using Xvfb restart for resolution change")
-		err = s.setResolutionXvfb(ctx, width, height, restartChrome)
-	}
-
-	if err != nil {
+	if err := s.displayDriver.SetMode(ctx, "", display.Mode{Width: width, Height: height, RefreshRate: refreshRate}); err != nil {
 		log.Error("failed to change resolution", "error", err)
 		return oapi.PatchDisplay500JSONResponse{
 			InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{
@@ -111,6 +102,12 @@ func (s *ApiService) PatchDisplay(ctx context.Context, req oapi.PatchDisplayRequ
 		}, nil
 	}
 
+	if restartChrome {
+		s.restartChromium(ctx, log, "")
+	}
+
+	s.publishDisplayEvent(oapi.DisplayEvent{Type: "resolution", Width: &width, Height: &height})
+
 	// Return success with the new dimensions
 	return oapi.PatchDisplay200JSONResponse{
 		Width:  &width,
@@ -118,187 +115,6 @@ func (s *ApiService) PatchDisplay(ctx context.Context, req oapi.PatchDisplayRequ
 	}, nil
 }
 
-// detectDisplayMode detects whether we're running Xorg (headful) or Xvfb (headless)
-func (s *ApiService) detectDisplayMode(ctx context.Context) string {
-	log := logger.FromContext(ctx)
-	checkCmd := []string{"-lc", "supervisorctl status xvfb >/dev/null 2>&1 && echo 'xvfb' || echo 'xorg'"}
-	checkReq := oapi.ProcessExecRequest{Command: "bash", Args: &checkCmd}
-	checkResp, _ := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &checkReq})
-
-	if execResp, ok := checkResp.(oapi.ProcessExec200JSONResponse); ok {
-		if execResp.StdoutB64 != nil {
-			if output, err := base64.StdEncoding.DecodeString(*execResp.StdoutB64); err == nil {
-				outputStr := strings.TrimSpace(string(output))
-				if outputStr == "xvfb" {
-					log.Info("detected Xvfb display (headless mode)")
-					return "xvfb"
-				}
-			}
-		}
-	}
-	log.Info("detected Xorg display (headful mode)")
-	return "xorg"
-}
-
-// setResolutionXorgViaNeko changes resolution for Xorg using Neko API
-func (s *ApiService) setResolutionXorgViaNeko(ctx context.Context, width, height, refreshRate int, restartChrome bool) error {
-	log := logger.FromContext(ctx)
-
-	if err := s.setResolutionViaNeko(ctx, width, height, refreshRate); err != nil {
-		return fmt.Errorf("failed to change resolution via Neko API: %w", err)
-	}
-
-	if restartChrome {
-		log.Info("restarting chromium after resolution change")
-		restartCmd := []string{"-lc", "supervisorctl restart chromium"}
-		restartReq := oapi.ProcessExecRequest{Command: "bash", Args: &restartCmd}
-		if restartResp, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &restartReq}); err != nil {
-			log.Error("failed to restart chromium", "error", err)
-		} else if execResp, ok := restartResp.(oapi.ProcessExec200JSONResponse); ok {
-			if execResp.ExitCode != nil && *execResp.ExitCode != 0 {
-				log.Error("chromium restart failed", "exit_code", *execResp.ExitCode)
-			}
-		}
-	}
-
-	return nil
-}
-
-// setResolutionXorgViaXrandr changes resolution for Xorg using xrandr (fallback when Neko is disabled)
-func (s *ApiService) setResolutionXorgViaXrandr(ctx context.Context, width, height, refreshRate int, restartChrome bool) error {
-	log := logger.FromContext(ctx)
-	display := s.resolveDisplayFromEnv()
-
-	// Build xrandr command - if refresh rate is specified, use the specific modeline
-	var xrandrCmd string
-	if refreshRate > 0 {
-		modeName := fmt.Sprintf("%dx%d_%d.00", width, height, refreshRate)
-		xrandrCmd = fmt.Sprintf("xrandr --output default --mode %s", modeName)
-		log.Info("using specific modeline", "mode", modeName)
-	} else {
-		xrandrCmd = fmt.Sprintf("xrandr -s %dx%d", width, height)
-	}
-
-	args := []string{"-lc", xrandrCmd}
-	env := map[string]string{"DISPLAY": display}
-	execReq := oapi.ProcessExecRequest{Command: "bash", Args: &args, Env: &env}
-	resp, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &execReq})
-	if err != nil {
-		return fmt.Errorf("failed to execute xrandr: %w", err)
-	}
-
-	switch r := resp.(type) {
-	case oapi.ProcessExec200JSONResponse:
-		if r.ExitCode != nil && *r.ExitCode != 0 {
-			var stderr string
-			if r.StderrB64 != nil {
-				if b, decErr := base64.StdEncoding.DecodeString(*r.StderrB64); decErr == nil {
-					stderr = strings.TrimSpace(string(b))
-				}
-			}
-			if stderr == "" {
-				stderr = "xrandr returned non-zero exit code"
-			}
-			return fmt.Errorf("xrandr failed: %s", stderr)
-		}
-		log.Info("resolution updated via xrandr", "display", display, "width", width, "height", height)
-
-		if restartChrome {
-			log.Info("restarting chromium after resolution change")
-			restartCmd := []string{"-lc", "supervisorctl restart chromium"}
-			restartReq := oapi.ProcessExecRequest{Command: "bash", Args: &restartCmd}
-			if restartResp, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &restartReq}); err != nil {
-				log.Error("failed to restart chromium", "error", err)
-			} else if execResp, ok := restartResp.(oapi.ProcessExec200JSONResponse); ok {
-				if execResp.ExitCode != nil && *execResp.ExitCode != 0 {
-					log.Error("chromium restart failed", "exit_code", *execResp.ExitCode)
-				}
-			}
-		}
-		return nil
-	case oapi.ProcessExec400JSONResponse:
-		return fmt.Errorf("bad request: %s", r.Message)
-	case oapi.ProcessExec500JSONResponse:
-		return fmt.Errorf("internal error: %s", r.Message)
-	default:
-		return fmt.Errorf("unexpected response from process exec")
-	}
-}
-
-// setResolutionXvfb changes resolution for Xvfb by updating config and restarting services
-func (s *ApiService) setResolutionXvfb(ctx context.Context, width, height int, restartChrome bool) error {
-	log := logger.FromContext(ctx)
-	log.Info("updating Xvfb resolution requires restart", "width", width, "height", height)
-
-	// Update supervisor config to include environment variables
-	log.Info("updating xvfb supervisor config with new dimensions")
-	removeEnvCmd := []string{"-lc", `sed -i '/^environment=/d' /etc/supervisor/conf.d/services/xvfb.conf`}
-	removeEnvReq := oapi.ProcessExecRequest{Command: "bash", Args: &removeEnvCmd}
-	s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &removeEnvReq})
-
-	// Add the environment line with WIDTH and HEIGHT
-	addEnvCmd := []string{"-lc", fmt.Sprintf(`sed -i '/\[program:xvfb\]/a environment=WIDTH="%d",HEIGHT="%d",DPI="96",DISPLAY=":1"' /etc/supervisor/conf.d/services/xvfb.conf`, width, height)}
-	addEnvReq := oapi.ProcessExecRequest{Command: "bash", Args: &addEnvCmd}
-	configResp, configErr := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &addEnvReq})
-	if configErr != nil {
-		return fmt.Errorf("failed to update xvfb config: %w", configErr)
-	}
-
-	// Check if config update succeeded
-	if execResp, ok := configResp.(oapi.ProcessExec200JSONResponse); ok {
-		if execResp.ExitCode != nil && *execResp.ExitCode != 0 {
-			log.Error("failed to update xvfb config", "exit_code", *execResp.ExitCode)
-			return fmt.Errorf("failed to update xvfb config")
-		}
-	}
-
-	// Reload supervisor configuration
-	log.Info("reloading supervisor configuration")
-	reloadCmd := []string{"-lc", "supervisorctl reread && supervisorctl update"}
-	reloadReq := oapi.ProcessExecRequest{Command: "bash", Args: &reloadCmd}
-	if _, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &reloadReq}); err != nil {
-		log.Error("failed to reload supervisor config", "error", err)
-	}
-
-	// Restart xvfb with new configuration
-	log.Info("restarting xvfb with new resolution")
-	restartXvfbCmd := []string{"-lc", "supervisorctl restart xvfb"}
-	restartXvfbReq := oapi.ProcessExecRequest{Command: "bash", Args: &restartXvfbCmd}
-	xvfbResp, xvfbErr := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &restartXvfbReq})
-	if xvfbErr != nil {
-		return fmt.Errorf("failed to restart Xvfb: %w", xvfbErr)
-	}
-
-	// Check if Xvfb restart succeeded
-	if execResp, ok := xvfbResp.(oapi.ProcessExec200JSONResponse); ok {
-		if execResp.ExitCode != nil && *execResp.ExitCode != 0 {
-			return fmt.Errorf("Xvfb restart failed")
-		}
-	}
-
-	// Wait for Xvfb to be ready
-	log.Info("waiting for Xvfb to be ready")
-	waitCmd := []string{"-lc", "sleep 2"}
-	waitReq := oapi.ProcessExecRequest{Command: "bash", Args: &waitCmd}
-	s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &waitReq})
-
-	if restartChrome {
-		log.Info("restarting chromium after Xvfb restart")
-		restartChromeCmd := []string{"-lc", "supervisorctl restart chromium"}
-		restartChromeReq := oapi.ProcessExecRequest{Command: "bash", Args: &restartChromeCmd}
-		if chromeResp, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &restartChromeReq}); err != nil {
-			log.Error("failed to restart chromium", "error", err)
-		} else if execResp, ok := chromeResp.(oapi.ProcessExec200JSONResponse); ok {
-			if execResp.ExitCode != nil && *execResp.ExitCode != 0 {
-				log.Error("chromium restart failed", "exit_code", *execResp.ExitCode)
-			}
-		}
-	}
-
-	log.Info("Xvfb resolution updated", "width", width, "height", height)
-	return nil
-}
-
 // anyRecordingActive returns true if any registered recorder is currently recording.
 func (s *ApiService) anyRecordingActive(ctx context.Context) bool {
 	for _, r := range s.recordManager.ListActiveRecorders(ctx) {
@@ -309,78 +125,26 @@ func (s *ApiService) anyRecordingActive(ctx context.Context) bool {
 	return false
 }
 
-// getActiveNekoSessions queries the Neko API for active viewer sessions.
+// getActiveNekoSessions returns the number of connected+watching Neko viewer sessions.
+// This used to poll Neko's REST /api/sessions endpoint on every call; it now reads off
+// nekoEvents, which maintains the count from Neko's websocket event stream, so a resize
+// check never blocks on (or races) a point-in-time HTTP round trip.
 func (s *ApiService) getActiveNekoSessions(ctx context.Context) int {
-	log := logger.FromContext(ctx)
-
-	// Get authentication token
-	token, err := s.getNekoToken(ctx)
-	if err != nil {
-		log.Debug("failed to get Neko token", "error", err)
-		return 0
-	}
-
-	// Create HTTP client with short timeout
-	client := &http.Client{
-		Timeout: 500 * time.Millisecond,
-	}
-
-	// Query Neko sessions API
-	req, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/api/sessions", nil)
-	if err != nil {
-		log.Debug("failed to create Neko API request", "error", err)
-		return 0
-	}
-
-	// Add Bearer token authentication
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Debug("failed to query Neko API", "error", err)
-		return 0
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode == http.StatusUnauthorized {
-		log.Warn("Neko API returned 401, clearing cached token")
-		s.clearNekoToken()
-		return 0
-	}
-	if resp.StatusCode != http.StatusOK {
-		log.Warn("Neko API returned non-OK status", "status", resp.StatusCode)
-		return 0
-	}
-
-	// Parse response
-	var sessions []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
-		log.Error("failed to parse Neko API response", "error", err)
-		return 0
-	}
-
-	// Debug: log each session to understand what we're counting
-	live := 0
-	for i, session := range sessions {
-		log.Info("neko session details", "index", i, "session", session)
-		if stRaw, ok := session["state"]; ok {
-			if st, ok := stRaw.(map[string]interface{}); ok {
-				connected, _ := st["is_connected"].(bool)
-				watching, _ := st["is_watching"].(bool)
-				if connected && watching {
-					live++
-				}
-			}
-		}
-	}
-
-	log.Info("successfully queried Neko API", "active_sessions", live)
+	live := s.nekoEvents.ActiveViewers(ctx)
+	logger.FromContext(ctx).Info("active neko sessions", "active_sessions", live)
 	return live
 }
 
 // resolveDisplayFromEnv returns the X display string, defaulting to ":1".
 func (s *ApiService) resolveDisplayFromEnv() string {
+	return displayFromEnv()
+}
+
+// displayFromEnv is resolveDisplayFromEnv's underlying logic, free of the *ApiService receiver so
+// it can also be handed to newXdotoolManager as a display supplier (wait_image's screenshot
+// capture needs the same display string xdotool itself runs against, but xdotoolManager is
+// constructed before any *ApiService exists to call a method on).
+func displayFromEnv() string {
 	// Prefer KERNEL_IMAGES_API_DISPLAY_NUM, fallback to DISPLAY_NUM, default 1
 	if v := strings.TrimSpace(os.Getenv("KERNEL_IMAGES_API_DISPLAY_NUM")); v != "" {
 		return ":" + v
@@ -391,54 +155,38 @@ func (s *ApiService) resolveDisplayFromEnv() string {
 	return ":1"
 }
 
-// getCurrentResolution returns the current display resolution and refresh rate by querying xrandr
+// getCurrentResolution returns the primary monitor's width, height and refresh rate. It's the
+// single-monitor case of the more general getCurrentLayout (display_layout.go), kept around
+// because PatchDisplay only ever reads/writes one (the primary) monitor.
 func (s *ApiService) getCurrentResolution(ctx context.Context) (int, int, int) {
 	log := logger.FromContext(ctx)
-	display := s.resolveDisplayFromEnv()
-
-	// Use xrandr to get current resolution
-	cmd := exec.CommandContext(ctx, "bash", "-lc", "xrandr | grep -E '\\*' | awk '{print $1}'")
-	cmd.Env = append(os.Environ(), fmt.Sprintf("DISPLAY=%s", display))
-
-	out, err := cmd.Output()
-	if err != nil {
-		log.Error("failed to get current resolution", "error", err)
-		// Return default resolution on error
-		return 1024, 768, 60
-	}
+	monitors := s.getCurrentLayout(ctx)
 
-	resStr := strings.TrimSpace(string(out))
-	parts := strings.Split(resStr, "x")
-	if len(parts) != 2 {
-		log.Error("unexpected xrandr output format", "output", resStr)
+	primary := primaryOrFirst(monitors)
+	if primary == nil {
+		log.Error("no current layout detected, using default resolution")
 		return 1024, 768, 60
 	}
 
-	width, err := strconv.Atoi(parts[0])
-	if err != nil {
-		log.Error("failed to parse width", "error", err, "value", parts[0])
-		return 1024, 768, 60
+	refreshRate := 60
+	if primary.RefreshRate != nil {
+		refreshRate = *primary.RefreshRate
 	}
+	return primary.Width, primary.Height, refreshRate
+}
 
-	// Parse height and refresh rate (e.g., "1080_60.00" -> height=1080, rate=60)
-	heightStr := parts[1]
-	refreshRate := 60 // default
-	if idx := strings.Index(heightStr, "_"); idx != -1 {
-		rateStr := heightStr[idx+1:]
-		heightStr = heightStr[:idx]
-		// Parse the refresh rate (e.g., "60.00" -> 60)
-		if rateFloat, err := strconv.ParseFloat(rateStr, 64); err == nil {
-			refreshRate = int(rateFloat)
+// primaryOrFirst returns the monitor marked primary, or the first monitor if none is, or nil
+// if monitors is empty.
+func primaryOrFirst(monitors []oapi.Monitor) *oapi.Monitor {
+	for i, m := range monitors {
+		if m.Primary != nil && *m.Primary {
+			return &monitors[i]
 		}
 	}
-
-	height, err := strconv.Atoi(heightStr)
-	if err != nil {
-		log.Error("failed to parse height", "error", err, "value", heightStr)
-		return 1024, 768, 60
+	if len(monitors) > 0 {
+		return &monitors[0]
 	}
-
-	return width, height, refreshRate
+	return nil
 }
 
 // isNekoEnabled checks if Neko service is enabled
@@ -539,89 +287,57 @@ func (s *ApiService) clearNekoToken() {
 	s.nekoToken = ""
 }
 
-// setResolutionViaNeko delegates resolution change to Neko API
-func (s *ApiService) setResolutionViaNeko(ctx context.Context, width, height, refreshRate int) error {
-	log := logger.FromContext(ctx)
-
-	// Get authentication token
-	token, err := s.getNekoToken(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get Neko token: %w", err)
-	}
-
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	// Use default refresh rate if not specified
-	if refreshRate <= 0 {
-		refreshRate = 60
-	}
-
-	// Prepare request body for Neko's screen API
-	screenConfig := map[string]interface{}{
-		"width":  width,
-		"height": height,
-		"rate":   refreshRate,
-	}
-
-	body, err := json.Marshal(screenConfig)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "POST",
-		"http://localhost:8080/api/room/screen",
-		strings.NewReader(string(body)))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	// Add Bearer token authentication
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	// Execute request
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to call Neko API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Handle 401 by clearing token and retrying once
-	if resp.StatusCode == http.StatusUnauthorized {
-		log.Warn("Neko API returned 401, clearing cached token and retrying")
-		s.clearNekoToken()
-
-		// Get fresh token
-		token, err = s.getNekoToken(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get fresh Neko token: %w", err)
+// publishDisplayEvent fans out evt to every subscriber registered via StreamDisplayEvents.
+// Slow or gone subscribers are skipped rather than blocking the caller (resolution changes
+// and viewer-count updates fire from hot paths, not something worth backpressuring on).
+func (s *ApiService) publishDisplayEvent(evt oapi.DisplayEvent) {
+	s.displayEventMu.RLock()
+	defer s.displayEventMu.RUnlock()
+	for _, ch := range s.displayEventSubs {
+		select {
+		case ch <- evt:
+		default:
 		}
+	}
+}
 
-		// Retry the request with fresh token
-		req, err = http.NewRequestWithContext(ctx, "POST",
-			"http://localhost:8080/api/room/screen",
-			strings.NewReader(string(body)))
-		if err != nil {
-			return fmt.Errorf("failed to create retry request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+// StreamDisplayEvents serves an SSE stream of resolution and viewer-count changes, so
+// clients no longer need to poll PatchDisplay/GetDisplay to notice a change made elsewhere.
+func (s *ApiService) StreamDisplayEvents(ctx context.Context, req oapi.StreamDisplayEventsRequestObject) (oapi.StreamDisplayEventsResponseObject, error) {
+	log := logger.FromContext(ctx)
 
-		resp, err = client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to retry Neko API call: %w", err)
+	subID := cuid2.Generate()
+	ch := make(chan oapi.DisplayEvent, 16)
+	s.displayEventMu.Lock()
+	s.displayEventSubs[subID] = ch
+	s.displayEventMu.Unlock()
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer pw.Close()
+		defer func() {
+			s.displayEventMu.Lock()
+			delete(s.displayEventSubs, subID)
+			s.displayEventMu.Unlock()
+		}()
+
+		enc := json.NewEncoder(pw)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-ch:
+				// Write SSE formatted event: data: <json>\n\n
+				pw.Write([]byte("data: "))
+				if err := enc.Encode(evt); err != nil {
+					log.Error("failed to encode display event", "err", err)
+					return
+				}
+				pw.Write([]byte("\n"))
+			}
 		}
-		defer resp.Body.Close()
-	}
+	}()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Neko API returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	log.Info("successfully changed resolution via Neko API", "width", width, "height", height, "refresh_rate", refreshRate)
-	return nil
+	headers := oapi.StreamDisplayEvents200ResponseHeaders{XSSEContentType: "application/json"}
+	return oapi.StreamDisplayEvents200TexteventStreamResponse{Body: pr, Headers: headers, ContentLength: 0}, nil
 }
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// maxLayoutWidth/maxLayoutHeight bound the combined virtual framebuffer we'll ask Xorg/Xvfb
+// to allocate. Both Xorg's default max CRTC size and Xvfb's -screen geometry comfortably fit
+// within this; it exists to reject layouts that would otherwise fail deep inside X with an
+// opaque error.
+const (
+	maxLayoutWidth  = 8192
+	maxLayoutHeight = 8192
+)
+
+// PutDisplayLayout replaces the full multi-monitor layout. Like PatchDisplay, it refuses to
+// apply changes while live view or recording/replay is active unless require_idle is false.
+func (s *ApiService) PutDisplayLayout(ctx context.Context, req oapi.PutDisplayLayoutRequestObject) (oapi.PutDisplayLayoutResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || len(req.Body.Monitors) == 0 {
+		return oapi.PutDisplayLayout400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "at least one monitor is required"}}, nil
+	}
+
+	if err := validateLayout(req.Body.Monitors); err != nil {
+		return oapi.PutDisplayLayout400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	requireIdle := true
+	if req.Body.RequireIdle != nil {
+		requireIdle = *req.Body.RequireIdle
+	}
+	if requireIdle {
+		live := s.getActiveNekoSessions(ctx)
+		isRecording := s.anyRecordingActive(ctx)
+		if live > 0 || isRecording {
+			log.Info("refusing layout change", "live_sessions", live, "is_recording", isRecording)
+			return oapi.PutDisplayLayout409JSONResponse{
+				ConflictErrorJSONResponse: oapi.ConflictErrorJSONResponse{Message: "layout change refused: live view or recording/replay active"},
+			}, nil
+		}
+	}
+
+	displayMode := s.displayMode
+	restartChrome := (displayMode == "xvfb")
+	if req.Body.RestartChromium != nil {
+		restartChrome = *req.Body.RestartChromium
+	}
+
+	var err error
+	if displayMode != "xvfb" {
+		err = s.setLayoutXorg(ctx, req.Body.Monitors, restartChrome)
+	} else {
+		err = s.setLayoutXvfb(ctx, req.Body.Monitors, restartChrome)
+	}
+	if err != nil {
+		log.Error("failed to apply display layout", "error", err)
+		return oapi.PutDisplayLayout500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: fmt.Sprintf("failed to apply layout: %s", err.Error())}}, nil
+	}
+
+	return oapi.PutDisplayLayout200JSONResponse{Monitors: &req.Body.Monitors}, nil
+}
+
+// validateLayout rejects monitor sets whose output rectangles overlap, or whose combined
+// framebuffer exceeds maxLayoutWidth/maxLayoutHeight, or that name zero/more than one primary.
+func validateLayout(monitors []oapi.Monitor) error {
+	primaries := 0
+	maxX, maxY := 0, 0
+	for i, m := range monitors {
+		if m.Name == "" {
+			return fmt.Errorf("monitor %d: name is required", i)
+		}
+		if m.Width <= 0 || m.Height <= 0 {
+			return fmt.Errorf("monitor %q: width/height must be positive", m.Name)
+		}
+		if m.Primary != nil && *m.Primary {
+			primaries++
+		}
+		if right := m.X + m.Width; right > maxX {
+			maxX = right
+		}
+		if bottom := m.Y + m.Height; bottom > maxY {
+			maxY = bottom
+		}
+		for j := i + 1; j < len(monitors); j++ {
+			if rectsOverlap(m, monitors[j]) {
+				return fmt.Errorf("monitors %q and %q overlap", m.Name, monitors[j].Name)
+			}
+		}
+	}
+	if primaries != 1 {
+		return fmt.Errorf("exactly one monitor must be marked primary, got %d", primaries)
+	}
+	if maxX > maxLayoutWidth || maxY > maxLayoutHeight {
+		return fmt.Errorf("combined framebuffer %dx%d exceeds max %dx%d", maxX, maxY, maxLayoutWidth, maxLayoutHeight)
+	}
+	return nil
+}
+
+// rectsOverlap reports whether two monitors' output areas intersect.
+func rectsOverlap(a, b oapi.Monitor) bool {
+	return a.X < b.X+b.Width && b.X < a.X+a.Width &&
+		a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
+
+// setLayoutXorg programs each monitor as a distinct xrandr output, positioning secondary
+// outputs relative to the primary via --pos and marking exactly one --primary.
+func (s *ApiService) setLayoutXorg(ctx context.Context, monitors []oapi.Monitor, restartChrome bool) error {
+	log := logger.FromContext(ctx)
+	display := s.resolveDisplayFromEnv()
+
+	var cmds []string
+	for _, m := range monitors {
+		modeName := fmt.Sprintf("%dx%d", m.Width, m.Height)
+		if m.RefreshRate != nil && *m.RefreshRate > 0 {
+			modeName = fmt.Sprintf("%s_%d.00", modeName, *m.RefreshRate)
+		}
+		cmd := fmt.Sprintf("xrandr --output %s --mode %s --pos %dx%d", m.Name, modeName, m.X, m.Y)
+		if m.Primary != nil && *m.Primary {
+			cmd += " --primary"
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	args := []string{"-lc", strings.Join(cmds, " && ")}
+	env := map[string]string{"DISPLAY": display}
+	execReq := oapi.ProcessExecRequest{Command: "bash", Args: &args, Env: &env}
+	resp, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &execReq})
+	if err != nil {
+		return fmt.Errorf("failed to execute xrandr: %w", err)
+	}
+
+	switch r := resp.(type) {
+	case oapi.ProcessExec200JSONResponse:
+		if r.ExitCode != nil && *r.ExitCode != 0 {
+			var stderr string
+			if r.StderrB64 != nil {
+				if b, decErr := base64.StdEncoding.DecodeString(*r.StderrB64); decErr == nil {
+					stderr = strings.TrimSpace(string(b))
+				}
+			}
+			if stderr == "" {
+				stderr = "xrandr returned non-zero exit code"
+			}
+			return fmt.Errorf("xrandr failed: %s", stderr)
+		}
+	case oapi.ProcessExec400JSONResponse:
+		return fmt.Errorf("bad request: %s", r.Message)
+	case oapi.ProcessExec500JSONResponse:
+		return fmt.Errorf("internal error: %s", r.Message)
+	default:
+		return fmt.Errorf("unexpected response from process exec")
+	}
+
+	log.Info("layout updated via xrandr", "display", display, "monitors", len(monitors))
+	if restartChrome {
+		s.restartChromium(ctx, log, "")
+	}
+	return nil
+}
+
+// setLayoutXvfb rewrites xvfb.conf with one -screen argument per monitor (Xvfb doesn't
+// support per-output positioning, so secondary monitors appear as additional X screens
+// rather than a single contiguous framebuffer) and restarts Xvfb.
+func (s *ApiService) setLayoutXvfb(ctx context.Context, monitors []oapi.Monitor, restartChrome bool) error {
+	log := logger.FromContext(ctx)
+
+	var screenArgs strings.Builder
+	for i, m := range monitors {
+		fmt.Fprintf(&screenArgs, " -screen %d %dx%dx24", i, m.Width, m.Height)
+	}
+
+	removeEnvCmd := []string{"-lc", `sed -i '/^environment=/d' /etc/supervisor/conf.d/services/xvfb.conf`}
+	removeEnvReq := oapi.ProcessExecRequest{Command: "bash", Args: &removeEnvCmd}
+	s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &removeEnvReq})
+
+	addEnvCmd := []string{"-lc", fmt.Sprintf(`sed -i '/\[program:xvfb\]/a environment=XVFB_SCREEN_ARGS="%s",DPI="96",DISPLAY=":1"' /etc/supervisor/conf.d/services/xvfb.conf`, strings.TrimSpace(screenArgs.String()))}
+	addEnvReq := oapi.ProcessExecRequest{Command: "bash", Args: &addEnvCmd}
+	if _, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &addEnvReq}); err != nil {
+		return fmt.Errorf("failed to update xvfb config: %w", err)
+	}
+
+	reloadCmd := []string{"-lc", "supervisorctl reread && supervisorctl update"}
+	reloadReq := oapi.ProcessExecRequest{Command: "bash", Args: &reloadCmd}
+	if _, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &reloadReq}); err != nil {
+		log.Error("failed to reload supervisor config", "error", err)
+	}
+
+	restartCmd := []string{"-lc", "supervisorctl restart xvfb"}
+	restartReq := oapi.ProcessExecRequest{Command: "bash", Args: &restartCmd}
+	resp, err := s.ProcessExec(ctx, oapi.ProcessExecRequestObject{Body: &restartReq})
+	if err != nil {
+		return fmt.Errorf("failed to restart Xvfb: %w", err)
+	}
+	if execResp, ok := resp.(oapi.ProcessExec200JSONResponse); ok {
+		if execResp.ExitCode != nil && *execResp.ExitCode != 0 {
+			return fmt.Errorf("Xvfb restart failed")
+		}
+	}
+
+	log.Info("layout updated via Xvfb restart", "monitors", len(monitors))
+	if restartChrome {
+		s.restartChromium(ctx, log, "")
+	}
+	return nil
+}
+
+// restartChromium is the shared "restart the chromium supervisor program" step used after a
+// resolution, layout or profile change. It speaks supervisord's XML-RPC API directly via supervisorClient instead of shelling
+// out to supervisorctl, so a failed restart surfaces supervisord's own fault instead of a shell
+// exit code. Before restarting, it gives s.collectCrashes a chance to capture whatever minidump
+// the Chromium instance being replaced left behind - profileSnapshotID is the checkpoint/snapshot
+// that was just restored, if any, so the resulting crash report (if Chromium had in fact crashed,
+// rather than this being a routine layout-driven restart) can be correlated back to it; pass ""
+// when no restore preceded this restart.
+func (s *ApiService) restartChromium(ctx context.Context, log *slog.Logger, profileSnapshotID string) {
+	s.collectCrashes(ctx, log, profileSnapshotID)
+
+	log.Info("restarting chromium after display change")
+	if err := s.supervisorClient.RestartProcess(ctx, "chromium"); err != nil {
+		log.Error("failed to restart chromium", "error", err)
+	}
+}
+
+// getCurrentLayout returns every active CRTC by parsing `xrandr --query`: one Monitor per
+// "connected" output line (name, geometry, primary), with RefreshRate filled in from that
+// output's starred (active) mode line underneath it. getCurrentResolution (display.go) is
+// just this generalized down to the primary monitor.
+func (s *ApiService) getCurrentLayout(ctx context.Context) []oapi.Monitor {
+	log := logger.FromContext(ctx)
+	display := s.resolveDisplayFromEnv()
+
+	cmd := exec.CommandContext(ctx, "xrandr", "--query")
+	cmd.Env = append(os.Environ(), fmt.Sprintf("DISPLAY=%s", display))
+
+	out, err := cmd.Output()
+	if err != nil {
+		log.Error("failed to query current layout", "error", err)
+		return nil
+	}
+
+	var monitors []oapi.Monitor
+	var current *oapi.Monitor
+	flush := func() {
+		if current != nil {
+			monitors = append(monitors, *current)
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) >= 2 && fields[1] == "connected" {
+			flush()
+			primary := len(fields) > 2 && fields[2] == "primary"
+			var geomField string
+			for _, f := range fields[2:] {
+				if strings.Contains(f, "x") && strings.Contains(f, "+") {
+					geomField = f
+					break
+				}
+			}
+			m, ok := parseGeometry(geomField)
+			if !ok {
+				continue
+			}
+			m.Name = fields[0]
+			m.Primary = &primary
+			current = &m
+			continue
+		}
+		if len(fields) >= 2 && fields[1] == "disconnected" {
+			flush()
+			continue
+		}
+		if current != nil && strings.Contains(line, "*") {
+			for _, f := range fields[1:] {
+				if !strings.Contains(f, "*") {
+					continue
+				}
+				if rateFloat, err := strconv.ParseFloat(strings.TrimRight(f, "*+"), 64); err == nil {
+					r := int(rateFloat)
+					current.RefreshRate = &r
+				}
+				break
+			}
+		}
+	}
+	flush()
+	return monitors
+}
+
+// parseGeometry parses an xrandr geometry field of the form "WIDTHxHEIGHT+X+Y".
+func parseGeometry(field string) (oapi.Monitor, bool) {
+	plusParts := strings.SplitN(field, "+", 3)
+	if len(plusParts) != 3 {
+		return oapi.Monitor{}, false
+	}
+	dims := strings.SplitN(plusParts[0], "x", 2)
+	if len(dims) != 2 {
+		return oapi.Monitor{}, false
+	}
+	width, err := strconv.Atoi(dims[0])
+	if err != nil {
+		return oapi.Monitor{}, false
+	}
+	height, err := strconv.Atoi(dims[1])
+	if err != nil {
+		return oapi.Monitor{}, false
+	}
+	x, err := strconv.Atoi(plusParts[1])
+	if err != nil {
+		return oapi.Monitor{}, false
+	}
+	y, err := strconv.Atoi(plusParts[2])
+	if err != nil {
+		return oapi.Monitor{}, false
+	}
+	return oapi.Monitor{Width: width, Height: height, X: x, Y: y}, true
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// setNekoViewerLease acquires or releases the single read lease representing "at least one
+// Neko viewer is connected". It's called from nekoEvents.OnChange, so it only ever toggles on
+// the 0-to-nonzero and nonzero-to-0 transitions of the active viewer count.
+func (s *ApiService) setNekoViewerLease(active bool) {
+	s.nekoViewerLeaseMu.Lock()
+	defer s.nekoViewerLeaseMu.Unlock()
+
+	switch {
+	case active && s.nekoViewerLeaseRelease == nil:
+		release, err := s.displayLock.AcquireRead(context.Background(), "neko-viewers")
+		if err != nil {
+			// Only returns an error if ctx is canceled, and context.Background() never is.
+			return
+		}
+		s.nekoViewerLeaseRelease = release
+	case !active && s.nekoViewerLeaseRelease != nil:
+		s.nekoViewerLeaseRelease()
+		s.nekoViewerLeaseRelease = nil
+	}
+}
+
+// acquireRecordingLease takes a read lease for recorderID, so a resize started while it
+// records waits (or is refused) instead of racing the recording.
+func (s *ApiService) acquireRecordingLease(ctx context.Context, recorderID string) {
+	release, err := s.displayLock.AcquireRead(ctx, "recording:"+recorderID)
+	if err != nil {
+		return
+	}
+	s.recordingLeaseMu.Lock()
+	s.recordingLeases[recorderID] = release
+	s.recordingLeaseMu.Unlock()
+}
+
+// releaseRecordingLease releases the lease acquired by acquireRecordingLease, if any. It's
+// safe to call even if no lease was ever acquired for recorderID.
+func (s *ApiService) releaseRecordingLease(recorderID string) {
+	s.recordingLeaseMu.Lock()
+	release, ok := s.recordingLeases[recorderID]
+	delete(s.recordingLeases, recorderID)
+	s.recordingLeaseMu.Unlock()
+
+	if ok {
+		release()
+	}
+}
+
+// GetDisplayLock reports every outstanding read/write lease, for debugging why a resize is
+// blocked or what a resize is currently blocking.
+func (s *ApiService) GetDisplayLock(ctx context.Context, req oapi.GetDisplayLockRequestObject) (oapi.GetDisplayLockResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	holders := s.displayLock.Holders()
+	out := make([]oapi.DisplayLockHolder, 0, len(holders))
+	for _, h := range holders {
+		acquiredAt := h.AcquiredAt
+		out = append(out, oapi.DisplayLockHolder{
+			Kind:       h.Kind,
+			Label:      h.Label,
+			AcquiredAt: &acquiredAt,
+		})
+	}
+
+	log.Debug("display lock holders requested", "count", len(out))
+	return oapi.GetDisplayLock200JSONResponse{Holders: &out}, nil
+}
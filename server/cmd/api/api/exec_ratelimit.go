@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/ratelimit"
+)
+
+// execRateLimitedOperations are the oapi operationIDs guarded by RateLimitStrictMiddleware. They
+// share one execRateLimiter (and so one RATE_LIMIT_EXEC_* config) with the raw playwright stream
+// and batch computer action handlers below, since all four either spawn a tsx process or drive
+// xdotool and are the routes unbounded clients could use to exhaust CPU/memory on the sandbox.
+var execRateLimitedOperations = map[string]bool{
+	"ClickMouse":            true,
+	"ExecutePlaywrightCode": true,
+}
+
+// execRateLimiter hands out a token bucket per client key (API key if the caller sent one,
+// otherwise remote IP), all configured from the same ratelimit.Limits, so one noisy client can't
+// starve another's share of the exec budget.
+type execRateLimiter struct {
+	limits ratelimit.Limits
+
+	mu      sync.Mutex
+	clients map[string]*execClientBucket
+}
+
+// execClientBucket pairs a client's limiter with when it was last used, so StartEvictionSweep can
+// reclaim buckets a client has stopped using instead of growing e.clients without bound for the
+// life of the process - a real risk for the IP-keyed fallback, where a client that rotates source
+// addresses never reuses a key.
+type execClientBucket struct {
+	limiter  *ratelimit.Limiter
+	lastSeen time.Time
+}
+
+// newExecRateLimiter returns an execRateLimiter that lazily creates a per-client bucket the
+// first time that client is seen, each configured with limits.
+func newExecRateLimiter(limits ratelimit.Limits) *execRateLimiter {
+	return &execRateLimiter{
+		limits:  limits,
+		clients: make(map[string]*execClientBucket),
+	}
+}
+
+func (e *execRateLimiter) limiterFor(key string) *ratelimit.Limiter {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	b, ok := e.clients[key]
+	if !ok {
+		b = &execClientBucket{limiter: ratelimit.NewFromLimits(e.limits)}
+		e.clients[key] = b
+	}
+	b.lastSeen = time.Now()
+	return b.limiter
+}
+
+// execClientBucketMaxIdle is how long a client's bucket survives with no requests before
+// StartEvictionSweep reclaims it.
+const execClientBucketMaxIdle = 10 * time.Minute
+
+// execClientBucketSweepInterval is how often StartEvictionSweep checks for idle buckets to evict.
+const execClientBucketSweepInterval = time.Minute
+
+// evictIdle removes every client bucket whose lastSeen is older than execClientBucketMaxIdle.
+func (e *execRateLimiter) evictIdle(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, b := range e.clients {
+		if now.Sub(b.lastSeen) > execClientBucketMaxIdle {
+			delete(e.clients, key)
+		}
+	}
+}
+
+// StartEvictionSweep runs until ctx is done, periodically reclaiming client buckets that have
+// gone idle. The ticker loop mirrors FFmpegManager.StartIdleReaper's pattern for the same kind of
+// "garbage collect what the client abandoned" housekeeping.
+func (e *execRateLimiter) StartEvictionSweep(ctx context.Context) {
+	ticker := time.NewTicker(execClientBucketSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.evictIdle(time.Now())
+		}
+	}
+}
+
+// allow checks out a slot for the caller identified by r, returning ok=false (and writing
+// nothing) if the caller is out of budget. On ok=true, the caller must call done when the
+// request finishes.
+func (e *execRateLimiter) allow(r *http.Request) (done func(), retryAfter time.Duration, ok bool) {
+	l := e.limiterFor(execClientKey(r))
+	if allowed, done := l.Allow(); allowed {
+		return done, 0, true
+	}
+	return nil, l.RetryAfter(), false
+}
+
+// execClientKey identifies the caller for per-client bucketing: the API key header if the
+// caller sent one, otherwise their remote IP (chi's RealIP middleware, registered in main.go,
+// resolves this to the original client behind any proxy).
+func execClientKey(r *http.Request) string {
+	if key := r.Header.Get("X-Api-Key"); key != "" {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// writeTooManyRequests writes a 429 with a Retry-After header (in whole seconds, rounded up so
+// a client that waits exactly that long is never turned away early).
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// RateLimitStrictMiddleware enforces s.execLimiter against the strict-handler operations listed
+// in execRateLimitedOperations (ClickMouse, ExecutePlaywrightCode), rejecting over-budget calls
+// with 429 before request decoding and the handler body ever run. It's installed via
+// oapi.NewStrictHandler's middleware list in main.go, which is the only point in the strict
+// handler chain with access to the raw *http.Request a per-client, per-route limiter needs.
+func (s *ApiService) RateLimitStrictMiddleware() oapi.StrictMiddlewareFunc {
+	return func(f oapi.StrictHandlerFunc, operationID string) oapi.StrictHandlerFunc {
+		if !execRateLimitedOperations[operationID] {
+			return f
+		}
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+			done, retryAfter, ok := s.execLimiter.allow(r)
+			if !ok {
+				logger.FromContext(ctx).Info("rate limited exec operation", "operation", operationID, "retry_after", retryAfter)
+				writeTooManyRequests(w, retryAfter)
+				return nil, nil
+			}
+			defer done()
+			return f(ctx, w, r, request)
+		}
+	}
+}
+
+// StartExecRateLimiterEviction runs s.execLimiter's idle-bucket sweep in the background until ctx
+// is done. Call once at startup, alongside StartSupervisorFatalWatcher.
+func (s *ApiService) StartExecRateLimiterEviction(ctx context.Context) {
+	go s.execLimiter.StartEvictionSweep(ctx)
+}
+
+// checkExecRateLimit is RateLimitStrictMiddleware's counterpart for the raw (non-oapi) exec
+// routes - HandleExecutePlaywrightCodeStream and HandleBatchComputerActions - which never pass
+// through the strict handler chain the middleware above wraps. On ok=false it has already
+// written the 429 response and the caller must return without writing anything further.
+func (s *ApiService) checkExecRateLimit(w http.ResponseWriter, r *http.Request) (done func(), ok bool) {
+	done, retryAfter, ok := s.execLimiter.allow(r)
+	if !ok {
+		writeTooManyRequests(w, retryAfter)
+		return nil, false
+	}
+	return done, true
+}
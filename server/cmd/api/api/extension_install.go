@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/extensionmanifest"
+	"github.com/onkernel/kernel-images/server/lib/exttrust"
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/ziputil"
+)
+
+// maxExtensionArtifactBytes bounds how large a single fetched zip/CRX can be, so a misconfigured
+// or malicious source URL can't exhaust disk.
+const maxExtensionArtifactBytes = 200 << 20 // 200MB
+
+// extensionFetchClient is used by InstallExtensionsFromSource to download zips and CRX files. A
+// timeout is set since a hanging source shouldn't be able to wedge the request indefinitely; the
+// default redirect policy is kept so Chrome Web Store's update2 URL (which 302s to the actual
+// CRX) works unmodified.
+var extensionFetchClient = &http.Client{Timeout: 2 * time.Minute}
+
+// crx3Magic is the 4-byte signature at the start of every CRX3 package, per the CRX3 format:
+// https://chromium.googlesource.com/chromium/src/+/main/components/crx_file/crx3.proto
+var crx3Magic = [4]byte{'C', 'r', '2', '4'}
+
+// InstallExtensionsFromSource installs one or more extensions by fetching them from a URL, a
+// direct CRX URL, or a Chrome Web Store id, rather than requiring a pre-packaged multipart
+// upload. Each source is downloaded, auto-detected as zip or CRX3, sha256-verified if a checksum
+// was declared, then installed through the same unzip/chown/manifest/flags/restart pipeline as
+// every other extension-installing endpoint.
+func (s *ApiService) InstallExtensionsFromSource(ctx context.Context, request oapi.InstallExtensionsFromSourceRequestObject) (oapi.InstallExtensionsFromSourceResponseObject, error) {
+	log := logger.FromContext(ctx)
+	start := time.Now()
+
+	if request.Body == nil || len(request.Body.Sources) == 0 {
+		return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "at least one source is required"}}, nil
+	}
+
+	s.stz.Disable(ctx)
+	defer s.stz.Enable(ctx)
+
+	temps := []string{}
+	defer func() {
+		for _, p := range temps {
+			_ = os.Remove(p)
+		}
+	}()
+
+	for _, src := range request.Body.Sources {
+		if src.Name == "" || !nameRegex.MatchString(src.Name) {
+			return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("invalid extension name: %q", src.Name)}}, nil
+		}
+
+		fetchURL, err := extensionSourceURL(src)
+		if err != nil {
+			return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+		}
+
+		artifactPath, sum, err := downloadExtensionArtifact(ctx, fetchURL)
+		if err != nil {
+			log.Error("failed to download extension artifact", "name", src.Name, "url", fetchURL, "err", err)
+			return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("failed to fetch %s: %v", src.Name, err)}}, nil
+		}
+		temps = append(temps, artifactPath)
+
+		if src.Sha256 != nil && *src.Sha256 != "" && sum != *src.Sha256 {
+			return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("sha256 mismatch for %s", src.Name)}}, nil
+		}
+
+		zipPath := artifactPath
+		isCRX, err := isCRX3(artifactPath)
+		if err != nil {
+			log.Error("failed to inspect downloaded artifact", "name", src.Name, "err", err)
+			return oapi.InstallExtensionsFromSource500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to inspect downloaded artifact"}}, nil
+		}
+		var result exttrust.VerificationResult
+		if isCRX {
+			artifactBytes, err := os.ReadFile(artifactPath)
+			if err != nil {
+				log.Error("failed to read downloaded crx", "name", src.Name, "err", err)
+				return oapi.InstallExtensionsFromSource500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to read downloaded crx"}}, nil
+			}
+			result, err = s.trustPolicy.VerifyCRX3(artifactBytes)
+			if err != nil {
+				log.Error("failed to verify crx", "name", src.Name, "err", err)
+				return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("invalid crx file: %v", err)}}, nil
+			}
+			if result.ExtensionID == "" {
+				result.ExtensionID = src.Name
+			}
+
+			converted, err := crxToZip(artifactPath)
+			if err != nil {
+				log.Error("failed to convert crx to zip", "name", src.Name, "err", err)
+				return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("invalid crx file: %v", err)}}, nil
+			}
+			temps = append(temps, converted)
+			zipPath = converted
+		} else {
+			result = s.trustPolicy.VerifyZipSignature(nil, nil)
+			result.ExtensionID = src.Name
+		}
+		if err := s.trustPolicy.Evaluate(result); err != nil {
+			return oapi.InstallExtensionsFromSource403JSONResponse{ForbiddenErrorJSONResponse: oapi.ForbiddenErrorJSONResponse{Message: err.Error()}}, nil
+		}
+
+		dest := filepath.Join(extensionsDir, src.Name)
+		if err := os.MkdirAll(dest, 0o755); err != nil {
+			log.Error("failed to create extension dir", "name", src.Name, "err", err)
+			return oapi.InstallExtensionsFromSource500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to create extension dir"}}, nil
+		}
+		if err := ziputil.Unzip(zipPath, dest); err != nil {
+			log.Error("failed to unzip extension", "name", src.Name, "err", err)
+			return oapi.InstallExtensionsFromSource400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("invalid zip/crx file: %v", err)}}, nil
+		}
+		if err := exec.Command("chown", "-R", "kernel:kernel", dest).Run(); err != nil {
+			log.Error("failed to chown extension dir", "name", src.Name, "err", err)
+			return oapi.InstallExtensionsFromSource500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to chown extension dir"}}, nil
+		}
+
+		size := int64(0)
+		if fi, err := os.Stat(zipPath); err == nil {
+			size = fi.Size()
+		}
+		entry := extensionmanifest.Entry{
+			Name:         src.Name,
+			InstalledAt:  time.Now(),
+			Source:       extensionmanifest.SourceCRXURL,
+			SourceURL:    fetchURL,
+			SHA256:       sum,
+			SizeBytes:    size,
+			Enabled:      true,
+			Verification: result,
+		}
+		if src.Url != nil {
+			entry.Source = extensionmanifest.SourceUpload
+		}
+		if err := s.extensions.Put(entry); err != nil {
+			log.Error("failed to update extension manifest", "name", src.Name, "err", err)
+			return oapi.InstallExtensionsFromSource500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to update extension manifest"}}, nil
+		}
+		log.Info("installed extension", "name", src.Name, "source", entry.Source)
+	}
+
+	if err := s.applyExtensionFlagsAndRestart(ctx); err != nil {
+		log.Error("failed to restart chromium after installing extensions", "err", err)
+		return oapi.InstallExtensionsFromSource500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("devtools ready", "elapsed", time.Since(start).String())
+	return oapi.InstallExtensionsFromSource201Response{}, nil
+}
+
+// extensionSourceURL resolves a single source's Url/CrxUrl/CwsId (exactly one must be set) to the
+// URL to fetch.
+func extensionSourceURL(src oapi.ExtensionSource) (string, error) {
+	set := 0
+	for _, v := range []bool{src.Url != nil, src.CrxUrl != nil, src.CwsId != nil} {
+		if v {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", fmt.Errorf("exactly one of url, crx_url, cws_id is required for %q", src.Name)
+	}
+	switch {
+	case src.Url != nil:
+		return *src.Url, nil
+	case src.CrxUrl != nil:
+		return *src.CrxUrl, nil
+	default:
+		return chromeWebStoreUpdateURL(*src.CwsId), nil
+	}
+}
+
+// chromeWebStoreUpdateURL builds the standard Chrome Web Store "update check" URL that, when
+// fetched, redirects to the CRX for the given extension id.
+func chromeWebStoreUpdateURL(id string) string {
+	q := url.Values{}
+	q.Set("response", "redirect")
+	q.Set("acceptformat", "crx2,crx3")
+	q.Set("prodversion", "120.0.0.0")
+	q.Set("x", fmt.Sprintf("id=%s&installsource=ondemand&uc", id))
+	return "https://clients2.google.com/service/update2/crx?" + q.Encode()
+}
+
+// downloadExtensionArtifact fetches url into a temp file, enforcing maxExtensionArtifactBytes,
+// and returns the temp file's path and its sha256 hex digest.
+func downloadExtensionArtifact(ctx context.Context, fetchURL string) (path string, sha256Hex string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fetchURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid url: %w", err)
+	}
+	resp, err := extensionFetchClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp("", "ext-src-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer tmp.Close()
+
+	limited := io.LimitReader(resp.Body, maxExtensionArtifactBytes+1)
+	n, err := io.Copy(tmp, limited)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("failed to download: %w", err)
+	}
+	if n > maxExtensionArtifactBytes {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("artifact exceeds %d byte limit", maxExtensionArtifactBytes)
+	}
+
+	sum, err := sha256SumFile(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("failed to checksum download: %w", err)
+	}
+	return tmp.Name(), sum, nil
+}
+
+// isCRX3 reports whether path starts with the CRX3 magic bytes, to distinguish a downloaded
+// artifact from a plain zip without relying on the source URL's extension.
+func isCRX3(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var magic [4]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return magic == crx3Magic, nil
+}
+
+// crxToZip strips a CRX3 container's header (magic, version, header-length, protobuf header) off
+// path, leaving the zip archive that follows it, and writes that remainder to a new temp file.
+// CRX3 layout: 4-byte magic "Cr24", 4-byte LE version (3), 4-byte LE header length, header bytes,
+// then the zip payload.
+func crxToZip(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var prefix [12]byte
+	if _, err := io.ReadFull(f, prefix[:]); err != nil {
+		return "", fmt.Errorf("truncated crx header: %w", err)
+	}
+	if [4]byte{prefix[0], prefix[1], prefix[2], prefix[3]} != crx3Magic {
+		return "", fmt.Errorf("not a CRX3 file")
+	}
+	headerLen := int64(prefix[8]) | int64(prefix[9])<<8 | int64(prefix[10])<<16 | int64(prefix[11])<<24
+	if _, err := f.Seek(headerLen, io.SeekCurrent); err != nil {
+		return "", fmt.Errorf("invalid crx header length: %w", err)
+	}
+
+	out, err := os.CreateTemp("", "ext-src-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, f); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("failed to extract zip payload: %w", err)
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/onkernel/kernel-images/server/lib/extsnapshot"
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// extensionSnapshotDir holds the tarballs CreateExtensionSnapshot produces and GetExtensionSnapshot
+// serves back. Unlike extensionsDir this isn't under /home/kernel, since snapshots are an
+// artifact of the extension state rather than part of it.
+const extensionSnapshotDir = "/var/lib/kernel/extension-snapshots"
+
+// restoreTimeout bounds how long HandleRestoreExtensionSnapshot waits for a new DevTools upstream
+// after restarting chromium with restored state before rolling back to the previous state.
+const restoreTimeout = 20 * time.Second
+
+func (s *ApiService) extensionSnapshotSources() extsnapshot.Sources {
+	return extsnapshot.Sources{
+		ExtensionsDir: extensionsDir,
+		FlagsPath:     "/chromium/flags",
+		ManifestPath:  extensionManifestPath,
+	}
+}
+
+// CreateExtensionSnapshot archives the current extensions directory, chromium flags, and
+// manifest into a single tarball under extensionSnapshotDir, so it can later be downloaded via
+// GetExtensionSnapshot and replayed onto another kernel-image instance via
+// HandleRestoreExtensionSnapshot.
+func (s *ApiService) CreateExtensionSnapshot(ctx context.Context, req oapi.CreateExtensionSnapshotRequestObject) (oapi.CreateExtensionSnapshotResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if err := os.MkdirAll(extensionSnapshotDir, 0o755); err != nil {
+		log.Error("failed to create extension snapshot dir", "err", err)
+		return oapi.CreateExtensionSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to create snapshot dir"}}, nil
+	}
+
+	id := uuid.New().String()
+	path := extensionSnapshotPath(id)
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		log.Error("failed to create extension snapshot file", "err", err)
+		return oapi.CreateExtensionSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to create snapshot file"}}, nil
+	}
+	if err := extsnapshot.Create(s.extensionSnapshotSources(), f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		log.Error("failed to archive extension state", "err", err)
+		return oapi.CreateExtensionSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to archive extension state"}}, nil
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		log.Error("failed to finalize extension snapshot file", "err", err)
+		return oapi.CreateExtensionSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to finalize snapshot file"}}, nil
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		log.Error("failed to finalize extension snapshot file", "err", err)
+		return oapi.CreateExtensionSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to finalize snapshot file"}}, nil
+	}
+
+	log.Info("created extension snapshot", "id", id)
+	return oapi.CreateExtensionSnapshot201JSONResponse{
+		Id:          id,
+		DownloadUrl: fmt.Sprintf("/extensions/snapshots/%s", id),
+	}, nil
+}
+
+// GetExtensionSnapshot streams a previously created snapshot tarball back to the caller, the same
+// way DownloadRecording streams a recording file.
+func (s *ApiService) GetExtensionSnapshot(ctx context.Context, req oapi.GetExtensionSnapshotRequestObject) (oapi.GetExtensionSnapshotResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	f, err := os.Open(extensionSnapshotPath(req.Id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return oapi.GetExtensionSnapshot404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "snapshot not found"}}, nil
+		}
+		log.Error("failed to open extension snapshot", "id", req.Id, "err", err)
+		return oapi.GetExtensionSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to open snapshot"}}, nil
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		log.Error("failed to stat extension snapshot", "id", req.Id, "err", err)
+		return oapi.GetExtensionSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to stat snapshot"}}, nil
+	}
+
+	return oapi.GetExtensionSnapshot200ApplicationzstdResponse{
+		Body:          f,
+		ContentLength: fi.Size(),
+	}, nil
+}
+
+func extensionSnapshotPath(id string) string {
+	return filepath.Join(extensionSnapshotDir, id+".tar.zst")
+}
+
+// HandleRestoreExtensionSnapshot atomically replaces the extensions directory, chromium flags,
+// and manifest with the contents of an uploaded or URL-fetched snapshot tarball, then restarts
+// chromium. It isn't part of the OpenAPI spec because it has to accept either a multipart file
+// upload or a JSON {"url": "..."} body on the same route, which the strict handler's one
+// request-shape-per-operation model can't represent - the same reason the tus-style upload
+// endpoints in extension_upload.go are raw routes.
+//
+// The restore is staged rather than applied in place: the tarball is unpacked into a sibling
+// staging directory first, and only swapped in via os.Rename once unpacking succeeds, so a
+// truncated or corrupt upload never corrupts the live state. The previous extensions dir, flags
+// file, and manifest are kept under a ".prev" suffix so a DevTools readiness timeout after the
+// restart can roll back automatically.
+func (s *ApiService) HandleRestoreExtensionSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	archivePath, err := s.materializeSnapshotArchive(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(archivePath)
+
+	s.stz.Disable(ctx)
+	defer s.stz.Enable(ctx)
+
+	stagingDir, err := os.MkdirTemp(filepath.Dir(extensionsDir), "extensions-restore-*")
+	if err != nil {
+		log.Error("failed to create snapshot restore staging dir", "err", err)
+		http.Error(w, "failed to create staging dir", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(stagingDir)
+
+	af, err := os.Open(archivePath)
+	if err != nil {
+		log.Error("failed to open snapshot archive", "err", err)
+		http.Error(w, "failed to open snapshot archive", http.StatusInternalServerError)
+		return
+	}
+	err = extsnapshot.Restore(af, stagingDir)
+	af.Close()
+	if err != nil {
+		log.Error("failed to unpack snapshot archive", "err", err)
+		http.Error(w, fmt.Sprintf("invalid snapshot archive: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	prevExtensionsDir := extensionsDir + ".prev"
+	prevFlagsPath := "/chromium/flags.prev"
+	prevManifestPath := extensionManifestPath + ".prev"
+	os.RemoveAll(prevExtensionsDir)
+	os.Remove(prevFlagsPath)
+	os.Remove(prevManifestPath)
+
+	if err := swapIfExists(extensionsDir, prevExtensionsDir); err != nil {
+		log.Error("failed to back up current extensions dir before restore", "err", err)
+		http.Error(w, "failed to back up current state", http.StatusInternalServerError)
+		return
+	}
+	swapIfExists("/chromium/flags", prevFlagsPath)
+	swapIfExists(extensionManifestPath, prevManifestPath)
+
+	rollback := func(cause error) {
+		log.Error("rolling back extension snapshot restore", "err", cause)
+		os.RemoveAll(extensionsDir)
+		os.Remove("/chromium/flags")
+		os.Remove(extensionManifestPath)
+		swapIfExists(prevExtensionsDir, extensionsDir)
+		swapIfExists(prevFlagsPath, "/chromium/flags")
+		swapIfExists(prevManifestPath, extensionManifestPath)
+		if err := s.extensions.ReloadFrom(extensionManifestPath); err != nil {
+			log.Error("failed to reload extension manifest after rollback", "err", err)
+		}
+		if out, err := exec.Command("supervisorctl", "-c", "/etc/supervisor/supervisord.conf", "restart", "chromium").CombinedOutput(); err != nil {
+			log.Error("failed to restart chromium after rollback", "err", err, "output", string(out))
+		}
+	}
+
+	if err := os.Rename(filepath.Join(stagingDir, "extensions"), extensionsDir); err != nil {
+		rollback(fmt.Errorf("failed to swap in restored extensions dir: %w", err))
+		http.Error(w, "failed to swap in restored state", http.StatusInternalServerError)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(stagingDir, "flags")); err == nil {
+		if err := os.Rename(filepath.Join(stagingDir, "flags"), "/chromium/flags"); err != nil {
+			rollback(fmt.Errorf("failed to swap in restored flags: %w", err))
+			http.Error(w, "failed to swap in restored state", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := os.Stat(filepath.Join(stagingDir, "manifest.json")); err == nil {
+		if err := os.Rename(filepath.Join(stagingDir, "manifest.json"), extensionManifestPath); err != nil {
+			rollback(fmt.Errorf("failed to swap in restored manifest: %w", err))
+			http.Error(w, "failed to swap in restored state", http.StatusInternalServerError)
+			return
+		}
+	}
+	if out, err := exec.Command("chown", "-R", "kernel:kernel", extensionsDir).CombinedOutput(); err != nil {
+		log.Warn("failed to chown restored extensions dir", "err", err, "output", string(out))
+	}
+	if err := s.extensions.ReloadFrom(extensionManifestPath); err != nil {
+		rollback(fmt.Errorf("failed to reload restored manifest: %w", err))
+		http.Error(w, "failed to reload restored manifest", http.StatusInternalServerError)
+		return
+	}
+
+	updates, cancelSub := s.upstreamMgr.Subscribe()
+	defer cancelSub()
+
+	cmdCtx, cancelCmd := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancelCmd()
+	errCh := make(chan error, 1)
+	log.Info("restarting chromium via supervisorctl after snapshot restore")
+	go func() {
+		out, err := exec.CommandContext(cmdCtx, "supervisorctl", "-c", "/etc/supervisor/supervisord.conf", "restart", "chromium").CombinedOutput()
+		if err != nil {
+			errCh <- fmt.Errorf("supervisorctl restart failed: %w: %s", err, out)
+		}
+	}()
+
+	timeout := time.NewTimer(restoreTimeout)
+	defer timeout.Stop()
+	select {
+	case <-updates:
+		log.Info("devtools ready after snapshot restore")
+		w.WriteHeader(http.StatusNoContent)
+	case err := <-errCh:
+		rollback(err)
+		http.Error(w, fmt.Sprintf("restart failed, rolled back to previous state: %v", err), http.StatusInternalServerError)
+	case <-timeout.C:
+		rollback(fmt.Errorf("devtools not ready within %s", restoreTimeout))
+		http.Error(w, "devtools not ready after restore, rolled back to previous state", http.StatusInternalServerError)
+	}
+}
+
+// swapIfExists renames src to dst if src exists, doing nothing otherwise - used to stash the
+// previous extensions dir/flags/manifest before swapping in a restored snapshot, since any of
+// them may legitimately be absent (e.g. flags before the first extension install).
+func swapIfExists(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return os.Rename(src, dst)
+}
+
+// materializeSnapshotArchive accepts either a multipart "snapshot" file part or a JSON
+// {"url": "..."} body and returns the path of a local temp file containing the archive bytes.
+// The caller is responsible for removing it.
+func (s *ApiService) materializeSnapshotArchive(r *http.Request) (string, error) {
+	tmp, err := os.CreateTemp("", "extension-snapshot-restore-*.tar.zst")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer tmp.Close()
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := copySnapshotPart(r, tmp); err != nil {
+			os.Remove(tmp.Name())
+			return "", err
+		}
+		return tmp.Name(), nil
+	}
+
+	var body struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.URL == "" {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("request body must be multipart with a 'snapshot' part, or JSON with a 'url'")
+	}
+	if err := downloadSnapshotURL(r.Context(), body.URL, tmp); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func copySnapshotPart(r *http.Request, tmp *os.File) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return fmt.Errorf("failed to read multipart body: %w", err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return fmt.Errorf("multipart body missing a 'snapshot' part")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read form part: %w", err)
+		}
+		if part.FormName() != "snapshot" {
+			continue
+		}
+		if _, err := io.Copy(tmp, part); err != nil {
+			return fmt.Errorf("failed to read snapshot part: %w", err)
+		}
+		return nil
+	}
+}
+
+func downloadSnapshotURL(ctx context.Context, url string, tmp *os.File) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	resp, err := extensionFetchClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status fetching snapshot: %s", resp.Status)
+	}
+	if _, err := io.Copy(tmp, io.LimitReader(resp.Body, maxExtensionArtifactBytes+1)); err != nil {
+		return fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/onkernel/kernel-images/server/lib/chromiumflags"
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	"github.com/onkernel/kernel-images/server/lib/ziputil"
+)
+
+// This file implements a tus-style resumable upload protocol alongside
+// UploadExtensionsAndRestart, for extension bundles too large to comfortably retry as a single
+// multipart request: POST /extensions/uploads creates a session, PATCH
+// /extensions/uploads/{id} appends one chunk each (Content-Range: bytes X-Y/Z, Upload-Offset),
+// HEAD /extensions/uploads/{id} reports the current offset for resume, and POST
+// /extensions/uploads/{id}/commit verifies the declared SHA-256 and installs the extension the
+// same way UploadExtensionsAndRestart does. Session state is persisted by uploadsession.Store so
+// a client can resume after a network blip or a server restart.
+
+type createUploadSessionRequest struct {
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	SHA256         string `json:"sha256"`
+	ChunkSizeBytes int64  `json:"chunk_size_bytes,omitempty"`
+}
+
+// defaultUploadChunkSizeBytes is handed back to a client that doesn't request a specific chunk
+// size, chosen to keep a single PATCH request comfortably under typical reverse-proxy body-size
+// limits while still being large enough that a multi-hundred-MB bundle doesn't need thousands of
+// round trips.
+const defaultUploadChunkSizeBytes = 8 << 20 // 8MB
+
+// HandleCreateUploadSession starts a new resumable upload session and returns its id and the
+// chunk size the client should use for each subsequent PATCH.
+func (s *ApiService) HandleCreateUploadSession(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	var req createUploadSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || !nameRegex.MatchString(req.Name) {
+		http.Error(w, "invalid extension name", http.StatusBadRequest)
+		return
+	}
+	if req.Size <= 0 {
+		http.Error(w, "size must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.SHA256 == "" {
+		http.Error(w, "sha256 is required", http.StatusBadRequest)
+		return
+	}
+
+	chunkSize := req.ChunkSizeBytes
+	if chunkSize <= 0 {
+		chunkSize = defaultUploadChunkSizeBytes
+	}
+
+	sess, err := s.uploadSessions.Create(req.Name, req.Size, strings.ToLower(req.SHA256), chunkSize)
+	if err != nil {
+		log.Error("failed to create upload session", "err", err)
+		http.Error(w, "failed to create upload session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("created extension upload session", "id", sess.ID, "name", sess.Name, "size", sess.DeclaredSize)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":               sess.ID,
+		"chunk_size_bytes": sess.ChunkSize,
+		"offset":           sess.Offset,
+	})
+}
+
+// HandleUploadSessionStatus reports a session's current offset via the Upload-Offset header, so
+// a client resuming after a network blip or server restart knows where to continue from.
+func (s *ApiService) HandleUploadSessionStatus(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.uploadSessions.Load(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "upload session not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleUploadChunk appends one chunk to a session. The caller must set Content-Range to
+// "bytes X-Y/Z" (Z is the total declared size) and Upload-Offset to X, matching the session's
+// current offset exactly; a mismatch means the client's view of the upload has fallen out of
+// sync (e.g. a retried chunk after a response was lost) and is rejected rather than silently
+// reordered.
+func (s *ApiService) HandleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	sess, err := s.uploadSessions.Load(id)
+	if err != nil {
+		http.Error(w, "upload session not found", http.StatusNotFound)
+		return
+	}
+
+	start, end, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid Content-Range: %v", err), http.StatusBadRequest)
+		return
+	}
+	offsetHeader := r.Header.Get("Upload-Offset")
+	offset, err := strconv.ParseInt(offsetHeader, 10, 64)
+	if err != nil || offset != start {
+		http.Error(w, "Upload-Offset must match Content-Range start", http.StatusBadRequest)
+		return
+	}
+	if total != sess.DeclaredSize {
+		http.Error(w, "Content-Range total does not match declared size", http.StatusBadRequest)
+		return
+	}
+	if start != sess.Offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+		http.Error(w, fmt.Sprintf("chunk starts at %d, expected %d", start, sess.Offset), http.StatusConflict)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, end-start+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read chunk body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) != end-start+1 {
+		http.Error(w, "chunk body shorter than Content-Range declared", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.uploadSessions.AppendChunk(sess, offset, data); err != nil {
+		log.Error("failed to append upload chunk", "id", id, "err", err)
+		http.Error(w, "failed to append chunk", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleCommitUpload finalizes a session once all chunks have arrived: verifies the declared
+// size and SHA-256, then installs the extension the same way UploadExtensionsAndRestart does
+// (unzip under /home/kernel/extensions/<name>, chown, merge chromium flags, restart chromium).
+func (s *ApiService) HandleCommitUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+	id := chi.URLParam(r, "id")
+
+	sess, err := s.uploadSessions.Load(id)
+	if err != nil {
+		http.Error(w, "upload session not found", http.StatusNotFound)
+		return
+	}
+
+	if sess.Offset != sess.DeclaredSize {
+		http.Error(w, fmt.Sprintf("upload incomplete: have %d of %d bytes", sess.Offset, sess.DeclaredSize), http.StatusConflict)
+		return
+	}
+
+	sum, err := sha256SumFile(sess.TempPath)
+	if err != nil {
+		log.Error("failed to checksum upload", "id", id, "err", err)
+		http.Error(w, "failed to checksum upload", http.StatusInternalServerError)
+		return
+	}
+	if sum != sess.DeclaredSHA256 {
+		http.Error(w, "sha256 mismatch", http.StatusConflict)
+		return
+	}
+
+	extBase := "/home/kernel/extensions"
+	dest := filepath.Join(extBase, sess.Name)
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		log.Error("failed to create extension dir", "err", err)
+		http.Error(w, "failed to create extension dir", http.StatusInternalServerError)
+		return
+	}
+	if err := ziputil.Unzip(sess.TempPath, dest); err != nil {
+		log.Error("failed to unzip upload", "err", err)
+		http.Error(w, "invalid zip file", http.StatusBadRequest)
+		return
+	}
+	if err := exec.Command("chown", "-R", "kernel:kernel", dest).Run(); err != nil {
+		log.Error("failed to chown extension dir", "err", err)
+		http.Error(w, "failed to chown extension dir", http.StatusInternalServerError)
+		return
+	}
+
+	const flagsPath = "/chromium/flags"
+	existingTokens, err := chromiumflags.ReadOptionalFlagFile(flagsPath)
+	if err != nil {
+		log.Error("failed to read existing flags", "err", err)
+		http.Error(w, "failed to read existing flags", http.StatusInternalServerError)
+		return
+	}
+	newTokens := []string{
+		fmt.Sprintf("--disable-extensions-except=%s", dest),
+		fmt.Sprintf("--load-extension=%s", dest),
+	}
+	mergedTokens := chromiumflags.MergeFlags(existingTokens, newTokens)
+	if err := os.MkdirAll("/chromium", 0o755); err != nil {
+		log.Error("failed to create chromium dir", "err", err)
+		http.Error(w, "failed to create chromium dir", http.StatusInternalServerError)
+		return
+	}
+	if err := chromiumflags.WriteFlagFile(flagsPath, mergedTokens); err != nil {
+		log.Error("failed to write overlay flags", "err", err)
+		http.Error(w, "failed to write overlay flags", http.StatusInternalServerError)
+		return
+	}
+
+	if err := exec.Command("supervisorctl", "-c", "/etc/supervisor/supervisord.conf", "restart", "chromium").Run(); err != nil {
+		log.Error("failed to restart chromium", "err", err)
+		http.Error(w, "failed to restart chromium", http.StatusInternalServerError)
+		return
+	}
+
+	s.uploadSessions.Delete(id)
+	log.Info("committed extension upload", "id", id, "name", sess.Name)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// parseContentRange parses a "bytes X-Y/Z" Content-Range header into its start, end (inclusive),
+// and total components.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	const prefix = "bytes "
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, 0, fmt.Errorf("missing %q prefix", prefix)
+	}
+	rangeAndTotal := strings.SplitN(strings.TrimPrefix(header, prefix), "/", 2)
+	if len(rangeAndTotal) != 2 {
+		return 0, 0, 0, fmt.Errorf("expected range/total")
+	}
+	startEnd := strings.SplitN(rangeAndTotal[0], "-", 2)
+	if len(startEnd) != 2 {
+		return 0, 0, 0, fmt.Errorf("expected start-end")
+	}
+	if start, err = strconv.ParseInt(startEnd[0], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid start: %w", err)
+	}
+	if end, err = strconv.ParseInt(startEnd[1], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid end: %w", err)
+	}
+	if total, err = strconv.ParseInt(rangeAndTotal[1], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid total: %w", err)
+	}
+	if end < start {
+		return 0, 0, 0, fmt.Errorf("end before start")
+	}
+	return start, end, total, nil
+}
+
+func sha256SumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
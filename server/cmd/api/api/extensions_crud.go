@@ -0,0 +1,236 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/chromiumflags"
+	"github.com/onkernel/kernel-images/server/lib/extensionmanifest"
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// chromeManifest is the subset of an extension's own manifest.json that GetExtension surfaces
+// alongside extensionmanifest.Entry's install bookkeeping. Chrome never writes an "id" into
+// manifest.json itself - it's derived from the install path or the "key" field at load time -
+// so Id stays unpopulated for the common unpacked/dev-extension case rather than being guessed.
+type chromeManifest struct {
+	Version         string   `json:"version"`
+	Permissions     []string `json:"permissions"`
+	HostPermissions []string `json:"host_permissions"`
+}
+
+// parseChromeManifest reads dir/manifest.json (the extension's own Chrome manifest, not our
+// extensionmanifest.Manifest) and extracts the fields GetExtension reports.
+func parseChromeManifest(dir string) (chromeManifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return chromeManifest{}, fmt.Errorf("failed to read manifest.json: %w", err)
+	}
+	var cm chromeManifest
+	if err := json.Unmarshal(b, &cm); err != nil {
+		return chromeManifest{}, fmt.Errorf("failed to parse manifest.json: %w", err)
+	}
+	return cm, nil
+}
+
+// ListExtensions returns every installed extension tracked in the manifest.
+func (s *ApiService) ListExtensions(ctx context.Context, req oapi.ListExtensionsRequestObject) (oapi.ListExtensionsResponseObject, error) {
+	entries := s.extensions.List()
+
+	items := make([]oapi.ExtensionSummary, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, extensionSummaryFromEntry(e))
+	}
+	return oapi.ListExtensions200JSONResponse{Extensions: items}, nil
+}
+
+// GetExtension returns one extension's manifest record plus fields read live from its own
+// manifest.json (version, permissions, host_permissions).
+func (s *ApiService) GetExtension(ctx context.Context, req oapi.GetExtensionRequestObject) (oapi.GetExtensionResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	entry, ok := s.extensions.Get(req.Name)
+	if !ok {
+		return oapi.GetExtension404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "extension not found"}}, nil
+	}
+
+	cm, err := parseChromeManifest(filepath.Join(extensionsDir, req.Name))
+	if err != nil {
+		log.Warn("failed to parse extension's own manifest.json", "name", req.Name, "err", err)
+	}
+
+	return oapi.GetExtension200JSONResponse{
+		ExtensionSummary: extensionSummaryFromEntry(entry),
+		Version:          &cm.Version,
+		Permissions:      &cm.Permissions,
+		HostPermissions:  &cm.HostPermissions,
+	}, nil
+}
+
+// DeleteExtension removes an extension's files and manifest entry, then reapplies chromium flags
+// from the remaining enabled extensions and restarts chromium - unless the deleted extension was
+// already disabled, in which case the running flags don't reference it and no restart is needed.
+func (s *ApiService) DeleteExtension(ctx context.Context, req oapi.DeleteExtensionRequestObject) (oapi.DeleteExtensionResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	entry, ok := s.extensions.Get(req.Name)
+	if !ok {
+		return oapi.DeleteExtension404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "extension not found"}}, nil
+	}
+
+	dest := filepath.Join(extensionsDir, req.Name)
+	if err := os.RemoveAll(dest); err != nil {
+		log.Error("failed to remove extension dir", "name", req.Name, "err", err)
+		return oapi.DeleteExtension500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to remove extension dir"}}, nil
+	}
+	if err := s.extensions.Delete(req.Name); err != nil {
+		log.Error("failed to update extension manifest", "name", req.Name, "err", err)
+		return oapi.DeleteExtension500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to update extension manifest"}}, nil
+	}
+
+	if entry.Enabled {
+		s.stz.Disable(ctx)
+		defer s.stz.Enable(ctx)
+		if err := s.applyExtensionFlagsAndRestart(ctx); err != nil {
+			log.Error("failed to restart chromium after deleting extension", "name", req.Name, "err", err)
+			return oapi.DeleteExtension500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+		}
+	}
+
+	log.Info("deleted extension", "name", req.Name)
+	return oapi.DeleteExtension204Response{}, nil
+}
+
+// EnableExtension marks an extension enabled, reapplies chromium flags from the manifest's now
+// current set of enabled extensions, and restarts chromium.
+func (s *ApiService) EnableExtension(ctx context.Context, req oapi.EnableExtensionRequestObject) (oapi.EnableExtensionResponseObject, error) {
+	entry, err := s.setExtensionEnabled(ctx, req.Name, true)
+	if err != nil {
+		return extensionEnableErrorResponse(err)
+	}
+	return oapi.EnableExtension200JSONResponse(extensionSummaryFromEntry(entry)), nil
+}
+
+// DisableExtension marks an extension disabled, reapplies chromium flags from the manifest's now
+// current set of enabled extensions, and restarts chromium.
+func (s *ApiService) DisableExtension(ctx context.Context, req oapi.DisableExtensionRequestObject) (oapi.DisableExtensionResponseObject, error) {
+	entry, err := s.setExtensionEnabled(ctx, req.Name, false)
+	if err != nil {
+		return extensionDisableErrorResponse(err)
+	}
+	return oapi.DisableExtension200JSONResponse(extensionSummaryFromEntry(entry)), nil
+}
+
+// setExtensionEnabled is the shared body of EnableExtension/DisableExtension: flip the manifest
+// flag, then reapply flags/restart the same way every other mutating extension endpoint does.
+func (s *ApiService) setExtensionEnabled(ctx context.Context, name string, enabled bool) (extensionmanifest.Entry, error) {
+	log := logger.FromContext(ctx)
+
+	entry, err := s.extensions.SetEnabled(name, enabled)
+	if err != nil {
+		return extensionmanifest.Entry{}, err
+	}
+
+	s.stz.Disable(ctx)
+	defer s.stz.Enable(ctx)
+	if err := s.applyExtensionFlagsAndRestart(ctx); err != nil {
+		log.Error("failed to restart chromium after toggling extension", "name", name, "enabled", enabled, "err", err)
+		return extensionmanifest.Entry{}, err
+	}
+
+	log.Info("toggled extension", "name", name, "enabled", enabled)
+	return entry, nil
+}
+
+func extensionEnableErrorResponse(err error) (oapi.EnableExtensionResponseObject, error) {
+	if strings.Contains(err.Error(), "not found") {
+		return oapi.EnableExtension404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	return oapi.EnableExtension500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+}
+
+func extensionDisableErrorResponse(err error) (oapi.DisableExtensionResponseObject, error) {
+	if strings.Contains(err.Error(), "not found") {
+		return oapi.DisableExtension404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	return oapi.DisableExtension500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+}
+
+// applyExtensionFlagsAndRestart recomputes --disable-extensions-except=/--load-extension= from
+// the manifest's currently enabled entries, merges them into /chromium/flags, restarts chromium
+// via supervisorctl, and waits for UpstreamManager to see a new DevTools upstream - the same
+// restart/wait sequence UploadExtensionsAndRestart uses, reused here since every mutating
+// extension endpoint needs it.
+func (s *ApiService) applyExtensionFlagsAndRestart(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+
+	enabledPaths := s.extensions.EnabledPaths(extensionsDir)
+
+	const flagsPath = "/chromium/flags"
+	existingTokens, err := chromiumflags.ReadOptionalFlagFile(flagsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing flags: %w", err)
+	}
+
+	var newTokens []string
+	if len(enabledPaths) > 0 {
+		newTokens = []string{
+			fmt.Sprintf("--disable-extensions-except=%s", strings.Join(enabledPaths, ",")),
+			fmt.Sprintf("--load-extension=%s", strings.Join(enabledPaths, ",")),
+		}
+	}
+	mergedTokens := chromiumflags.MergeFlags(existingTokens, newTokens)
+
+	if err := os.MkdirAll("/chromium", 0o755); err != nil {
+		return fmt.Errorf("failed to create chromium dir: %w", err)
+	}
+	if err := chromiumflags.WriteFlagFile(flagsPath, mergedTokens); err != nil {
+		return fmt.Errorf("failed to write overlay flags: %w", err)
+	}
+
+	updates, cancelSub := s.upstreamMgr.Subscribe()
+	defer cancelSub()
+
+	cmdCtx, cancelCmd := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancelCmd()
+	errCh := make(chan error, 1)
+	log.Info("restarting chromium via supervisorctl")
+	go func() {
+		out, err := exec.CommandContext(cmdCtx, "supervisorctl", "-c", "/etc/supervisor/supervisord.conf", "restart", "chromium").CombinedOutput()
+		if err != nil {
+			errCh <- fmt.Errorf("supervisorctl restart failed: %w: %s", err, out)
+		}
+	}()
+
+	timeout := time.NewTimer(15 * time.Second)
+	defer timeout.Stop()
+	select {
+	case <-updates:
+		return nil
+	case err := <-errCh:
+		return err
+	case <-timeout.C:
+		return fmt.Errorf("devtools not ready in time")
+	}
+}
+
+func extensionSummaryFromEntry(e extensionmanifest.Entry) oapi.ExtensionSummary {
+	return oapi.ExtensionSummary{
+		Name:        e.Name,
+		InstalledAt: e.InstalledAt,
+		Source:      string(e.Source),
+		SourceUrl:   &e.SourceURL,
+		Sha256:      e.SHA256,
+		SizeBytes:   e.SizeBytes,
+		Enabled:     e.Enabled,
+		Signed:      &e.Verification.Signed,
+		Verified:    &e.Verification.Verified,
+	}
+}
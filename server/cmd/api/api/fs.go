@@ -1,13 +1,20 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"os/user"
 
@@ -15,14 +22,32 @@ import (
 	"github.com/nrednav/cuid2"
 	"github.com/onkernel/kernel-images/server/lib/logger"
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/vfs"
 )
 
+// resolveMount looks up the VFS backend a path addresses - local disk unless the path is
+// scheme-prefixed (e.g. "s3://bucket/key") or mountHeader names a registered mount - and returns
+// it along with the path relative to that backend.
+func (s *ApiService) resolveMount(path string, mountHeader *string) (vfs.VFS, string, error) {
+	header := ""
+	if mountHeader != nil {
+		header = *mountHeader
+	}
+	// An ApiService built without New (as the package's tests do) has no registry yet; give it a
+	// local-only one so unprefixed paths resolve to local disk.
+	if s.mounts == nil {
+		s.mounts = vfs.NewRegistry()
+	}
+	return s.mounts.Resolve(path, header)
+}
+
 // fsWatch represents an in-memory directory watch.
 type fsWatch struct {
 	path      string
 	recursive bool
 	events    chan oapi.FileSystemEvent
 	watcher   *fsnotify.Watcher
+	pipeline  *fsEventPipeline
 }
 
 // addRecursive walks the directory and registers all subdirectories when recursive=true.
@@ -38,7 +63,14 @@ func addRecursive(w *fsnotify.Watcher, root string) error {
 	})
 }
 
-// ReadFile returns the contents of a file specified by the path param.
+// ReadFile returns the contents of a file specified by the path param. It supports RFC 7233 byte
+// ranges via the Range request header (single range: 206 with Content-Range; multiple ranges: 206
+// with a multipart/byteranges body) and conditional GETs via If-None-Match/If-Modified-Since
+// against a weak ETag derived from the file's mtime and size, so a client resuming a download of a
+// large recording or log can avoid re-transferring bytes it already has. Every 200 and 206
+// response also carries Accept-Ranges and Last-Modified (a 304 carries only the ETag), and a Range is honored only if If-Range (when present)
+// still matches the current ETag - otherwise the file has changed since the client's last partial
+// fetch, and the full, current body is returned instead of stitching old and new bytes together.
 func (s *ApiService) ReadFile(ctx context.Context, req oapi.ReadFileRequestObject) (oapi.ReadFileResponseObject, error) {
 	log := logger.FromContext(ctx)
 	path := req.Params.Path
@@ -46,7 +78,12 @@ func (s *ApiService) ReadFile(ctx context.Context, req oapi.ReadFileRequestObjec
 		return oapi.ReadFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
 	}
 
-	f, err := os.Open(path)
+	backend, relPath, err := s.resolveMount(path, req.Params.Mount)
+	if err != nil {
+		return oapi.ReadFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	f, err := backend.Open(ctx, relPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return oapi.ReadFile404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "file not found"}}, nil
@@ -61,14 +98,253 @@ func (s *ApiService) ReadFile(ctx context.Context, req oapi.ReadFileRequestObjec
 		log.Error("failed to stat file", "err", err, "path", path)
 		return oapi.ReadFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to stat file"}}, nil
 	}
+	etag := weakETag(stat)
+	lastModified := stat.ModTime().UTC().Format(http.TimeFormat)
+
+	if notModified(req.Params, stat, etag) {
+		f.Close()
+		return oapi.ReadFile304Response{Headers: oapi.ReadFile304ResponseHeaders{ETag: etag}}, nil
+	}
 
-	return oapi.ReadFile200ApplicationoctetStreamResponse{
-		Body:          f,
-		ContentLength: stat.Size(),
+	rangeHeader := req.Params.Range
+	if rangeHeader != nil && !ifRangeSatisfied(req.Params, stat, etag) {
+		rangeHeader = nil
+	}
+
+	if rangeHeader == nil {
+		return oapi.ReadFile200ApplicationoctetStreamResponse{
+			Body:          f,
+			ContentLength: stat.Size(),
+			Headers:       oapi.ReadFile200ResponseHeaders{ETag: etag, AcceptRanges: "bytes", LastModified: lastModified},
+		}, nil
+	}
+
+	ranges, err := parseByteRanges(*rangeHeader, stat.Size())
+	if err != nil {
+		if errors.Is(err, errRangeNotSatisfiable) {
+			f.Close()
+			return oapi.ReadFile416JSONResponse{
+				RangeNotSatisfiableErrorJSONResponse: oapi.RangeNotSatisfiableErrorJSONResponse{Message: "requested range not satisfiable"},
+				Headers:                              oapi.ReadFile416ResponseHeaders{ContentRange: fmt.Sprintf("bytes */%d", stat.Size())},
+			}, nil
+		}
+		// A syntactically invalid Range header is ignored per RFC 7233 §3.1: serve the full body.
+		return oapi.ReadFile200ApplicationoctetStreamResponse{
+			Body:          f,
+			ContentLength: stat.Size(),
+			Headers:       oapi.ReadFile200ResponseHeaders{ETag: etag, AcceptRanges: "bytes", LastModified: lastModified},
+		}, nil
+	}
+
+	ra, err := fileReaderAt(f)
+	if err != nil {
+		f.Close()
+		log.Error("failed to prepare range read", "err", err, "path", path)
+		return oapi.ReadFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to read file for range request"}}, nil
+	}
+
+	if len(ranges) == 1 {
+		br := ranges[0]
+		return oapi.ReadFile206ApplicationoctetStreamResponse{
+			Body:          io.NewSectionReader(ra, br.start, br.length()),
+			ContentLength: br.length(),
+			Headers: oapi.ReadFile206ResponseHeaders{
+				ETag:         etag,
+				ContentRange: fmt.Sprintf("bytes %d-%d/%d", br.start, br.end, stat.Size()),
+				AcceptRanges: "bytes",
+				LastModified: lastModified,
+			},
+		}, nil
+	}
+
+	body, contentType, contentLength, err := buildMultipartByteRanges(ra, ranges, stat.Size())
+	f.Close()
+	if err != nil {
+		log.Error("failed to build multipart byteranges response", "err", err, "path", path)
+		return oapi.ReadFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to build range response"}}, nil
+	}
+	return oapi.ReadFile206MultipartByterangesResponse{
+		Body:          body,
+		ContentLength: contentLength,
+		Headers: oapi.ReadFile206MultipartByterangesResponseHeaders{
+			ETag: etag, ContentType: contentType, AcceptRanges: "bytes", LastModified: lastModified,
+		},
 	}, nil
 }
 
-// WriteFile creates or overwrites a file with the supplied data stream.
+// fileReaderAt returns an io.ReaderAt over f's contents for Range support. Local files and
+// sftp.File implement io.ReaderAt natively; s3FS/webdavFS's in-memory Files do too (see
+// s3File.ReadAt/webdavFile.ReadAt). The fallback only matters for a hypothetical backend whose
+// File is sequential-only.
+func fileReaderAt(f vfs.File) (io.ReaderAt, error) {
+	if ra, ok := f.(io.ReaderAt); ok {
+		return ra, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// weakETag derives a weak validator from path's mtime and size: cheap to compute and stable
+// across reads, which is all a client resuming or cache-validating a download needs.
+func weakETag(stat os.FileInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+}
+
+// notModified reports whether the request's conditional headers indicate the client's cached copy
+// is still current. If-None-Match takes precedence over If-Modified-Since per RFC 7232 §6.
+func notModified(params oapi.ReadFileParams, stat os.FileInfo, etag string) bool {
+	if params.IfNoneMatch != nil {
+		return etagMatchesAny(etag, *params.IfNoneMatch)
+	}
+	if params.IfModifiedSince != nil {
+		since, err := http.ParseTime(*params.IfModifiedSince)
+		if err != nil {
+			return false
+		}
+		return !stat.ModTime().Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// ifRangeSatisfied reports whether a Range header should still be honored given an If-Range
+// validator, per RFC 7233 §3.2. Absent If-Range, Range always applies. If-Range may hold either
+// an ETag (compared for an exact strong-or-weak match against etag) or an HTTP-date (compared
+// against the file's mtime, truncated to the second HTTP-date precision allows); either mismatch
+// means the representation changed since the client fetched its earlier ranges, so the full,
+// current body should be served instead.
+func ifRangeSatisfied(params oapi.ReadFileParams, stat os.FileInfo, etag string) bool {
+	if params.IfRange == nil {
+		return true
+	}
+	validator := strings.TrimSpace(*params.IfRange)
+	if strings.HasPrefix(validator, `"`) || strings.HasPrefix(validator, `W/"`) {
+		return validator == etag
+	}
+	since, err := http.ParseTime(validator)
+	if err != nil {
+		return false
+	}
+	return stat.ModTime().Truncate(time.Second).Equal(since)
+}
+
+// etagMatchesAny reports whether etag appears (or "*" appears) in the comma-separated header
+// value of an If-None-Match request header.
+func etagMatchesAny(etag, header string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// errRangeNotSatisfiable is returned by parseByteRanges when the header is syntactically valid but
+// every range it describes falls entirely outside the resource, per RFC 7233 §4.4.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange is an inclusive [start, end] byte range, already clamped to a resource of a known size.
+type byteRange struct {
+	start, end int64
+}
+
+func (br byteRange) length() int64 { return br.end - br.start + 1 }
+
+// parseByteRanges parses an RFC 7233 Range header value (e.g. "bytes=0-499,-500") against a
+// resource of the given size. A syntactically invalid header returns an error; one that's
+// well-formed but unsatisfiable against size returns errRangeNotSatisfiable.
+func parseByteRanges(header string, size int64) ([]byteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, fmt.Errorf("unsupported range unit in %q", header)
+	}
+
+	var ranges []byteRange
+	for _, spec := range strings.Split(strings.TrimPrefix(header, prefix), ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		dash := strings.IndexByte(spec, '-')
+		if dash < 0 {
+			return nil, fmt.Errorf("malformed range %q", spec)
+		}
+		startStr, endStr := spec[:dash], spec[dash+1:]
+
+		var br byteRange
+		if startStr == "" {
+			// Suffix range: the last N bytes of the resource.
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("malformed suffix range %q", spec)
+			}
+			if n > size {
+				n = size
+			}
+			br = byteRange{start: size - n, end: size - 1}
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed range %q", spec)
+			}
+			end := size - 1
+			if endStr != "" {
+				if end, err = strconv.ParseInt(endStr, 10, 64); err != nil {
+					return nil, fmt.Errorf("malformed range %q", spec)
+				}
+			}
+			br = byteRange{start: start, end: end}
+		}
+
+		if br.start < 0 || br.start > br.end || br.start >= size {
+			continue // unsatisfiable spec; RFC 7233 §2.1 says to ignore it, not fail the whole header
+		}
+		if br.end >= size {
+			br.end = size - 1
+		}
+		ranges = append(ranges, br)
+	}
+	if len(ranges) == 0 {
+		return nil, errRangeNotSatisfiable
+	}
+	return ranges, nil
+}
+
+// buildMultipartByteRanges assembles a multipart/byteranges body for a multi-range request. The
+// body is built into an in-memory buffer (unlike the single-range case, which streams directly
+// from f) so the response's Content-Length can be known upfront, matching how every other handler
+// in this file reports ContentLength.
+func buildMultipartByteRanges(f io.ReaderAt, ranges []byteRange, size int64) (io.Reader, string, int64, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, br := range ranges {
+		part, err := mw.CreatePart(map[string][]string{
+			"Content-Type":  {"application/octet-stream"},
+			"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", br.start, br.end, size)},
+		})
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("failed to create range part: %w", err)
+		}
+		if _, err := io.Copy(part, io.NewSectionReader(f, br.start, br.length())); err != nil {
+			return nil, "", 0, fmt.Errorf("failed to write range part: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", 0, fmt.Errorf("failed to finalize multipart body: %w", err)
+	}
+	return &buf, "multipart/byteranges; boundary=" + mw.Boundary(), int64(buf.Len()), nil
+}
+
+// WriteFile creates or overwrites a file with the supplied data stream. A client that doesn't
+// want to use the CreateUpload/UploadChunk session protocol in fs_upload.go can still write a
+// single byte range directly by setting a Content-Range: bytes X-Y/Z request header; WriteFile
+// then writes at offset X into the file (creating it if needed and sizing it to Z first) instead
+// of replacing the whole thing.
 func (s *ApiService) WriteFile(ctx context.Context, req oapi.WriteFileRequestObject) (oapi.WriteFileResponseObject, error) {
 	log := logger.FromContext(ctx)
 	path := req.Params.Path
@@ -79,10 +355,22 @@ func (s *ApiService) WriteFile(ctx context.Context, req oapi.WriteFileRequestObj
 		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "empty request body"}}, nil
 	}
 
+	backend, relPath, err := s.resolveMount(path, req.Params.Mount)
+	if err != nil {
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
 	// create parent directories if necessary
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		log.Error("failed to create directories", "err", err, "path", path)
-		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to create directories"}}, nil
+	if backend == s.mounts.Local {
+		if err := os.MkdirAll(filepath.Dir(relPath), 0o755); err != nil {
+			log.Error("failed to create directories", "err", err, "path", path)
+			return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to create directories"}}, nil
+		}
+	} else if dir := filepath.Dir(relPath); dir != "." && dir != "/" {
+		if err := backend.Mkdir(ctx, dir, 0o755); err != nil {
+			log.Error("failed to create directories", "err", err, "path", path)
+			return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to create directories"}}, nil
+		}
 	}
 
 	// determine desired file mode (default 0o644)
@@ -93,8 +381,14 @@ func (s *ApiService) WriteFile(ctx context.Context, req oapi.WriteFileRequestObj
 		}
 	}
 
-	// open the file with the specified permissions
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if req.Params.ContentRange != nil {
+		if backend == s.mounts.Local {
+			return s.writeFileRange(log, relPath, perm, *req.Params.ContentRange, req.Body)
+		}
+		return s.writeFileRangeRemote(ctx, log, backend, relPath, perm, *req.Params.ContentRange, req.Body)
+	}
+
+	f, err := backend.Create(ctx, relPath, perm)
 	if err != nil {
 		log.Error("failed to create file", "err", err, "path", path)
 		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to create file"}}, nil
@@ -109,6 +403,87 @@ func (s *ApiService) WriteFile(ctx context.Context, req oapi.WriteFileRequestObj
 	return oapi.WriteFile201Response{}, nil
 }
 
+// writeFileRange is WriteFile's Content-Range fallback for the local backend: it writes body at
+// the range's start offset without disturbing other bytes below the range's declared total. Every
+// call sizes the file to that total (extending or truncating it), so a client sending chunks out of
+// order still ends up with a correctly-sized file once every range has been written.
+func (s *ApiService) writeFileRange(log *slog.Logger, path string, perm os.FileMode, contentRange string, body io.Reader) (oapi.WriteFileResponseObject, error) {
+	start, end, total, err := parseContentRange(contentRange)
+	if err != nil {
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("invalid Content-Range: %v", err)}}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		log.Error("failed to open file for range write", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to open file"}}, nil
+	}
+	defer f.Close()
+
+	if err := f.Truncate(total); err != nil {
+		log.Error("failed to size file for range write", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to size file"}}, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, end-start+1))
+	if err != nil {
+		log.Error("failed to read range body", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to read data"}}, nil
+	}
+	if int64(len(data)) != end-start+1 {
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "body shorter than Content-Range declared"}}, nil
+	}
+	if _, err := f.WriteAt(data, start); err != nil {
+		log.Error("failed to write range", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to write data"}}, nil
+	}
+
+	return oapi.WriteFile201Response{}, nil
+}
+
+// writeFileRangeRemote is writeFileRange's equivalent for non-local backends. None of them expose
+// an in-place partial write against existing object contents (s3File/webdavFile's WriteAt mutates
+// an in-memory buffer, not the stored object itself), so this reads whatever already exists at
+// path, merges the new range into it in memory, and writes the whole result back in one Create.
+func (s *ApiService) writeFileRangeRemote(ctx context.Context, log *slog.Logger, backend vfs.VFS, path string, perm os.FileMode, contentRange string, body io.Reader) (oapi.WriteFileResponseObject, error) {
+	start, end, total, err := parseContentRange(contentRange)
+	if err != nil {
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("invalid Content-Range: %v", err)}}, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, end-start+1))
+	if err != nil {
+		log.Error("failed to read range body", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to read data"}}, nil
+	}
+	if int64(len(data)) != end-start+1 {
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "body shorter than Content-Range declared"}}, nil
+	}
+
+	existing := make([]byte, total)
+	if f, err := backend.Open(ctx, path); err == nil {
+		io.ReadFull(f, existing) // best-effort: a shorter or missing previous upload just leaves zero bytes
+		f.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Error("failed to open existing file for range write", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to open file"}}, nil
+	}
+	copy(existing[start:end+1], data)
+
+	f, err := backend.Create(ctx, path, perm)
+	if err != nil {
+		log.Error("failed to create file for range write", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to create file"}}, nil
+	}
+	defer f.Close()
+	if _, err := f.Write(existing); err != nil {
+		log.Error("failed to write range", "err", err, "path", path)
+		return oapi.WriteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to write data"}}, nil
+	}
+
+	return oapi.WriteFile201Response{}, nil
+}
+
 // CreateDirectory creates a new directory (recursively) with an optional mode.
 func (s *ApiService) CreateDirectory(ctx context.Context, req oapi.CreateDirectoryRequestObject) (oapi.CreateDirectoryResponseObject, error) {
 	log := logger.FromContext(ctx)
@@ -119,6 +494,10 @@ func (s *ApiService) CreateDirectory(ctx context.Context, req oapi.CreateDirecto
 	if path == "" {
 		return oapi.CreateDirectory400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
 	}
+	backend, relPath, err := s.resolveMount(path, req.Body.Mount)
+	if err != nil {
+		return oapi.CreateDirectory400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
 	// default to 0o755
 	perm := os.FileMode(0o755)
 	if req.Body.Mode != nil {
@@ -126,7 +505,7 @@ func (s *ApiService) CreateDirectory(ctx context.Context, req oapi.CreateDirecto
 			perm = os.FileMode(v)
 		}
 	}
-	if err := os.MkdirAll(path, perm); err != nil {
+	if err := backend.Mkdir(ctx, relPath, perm); err != nil {
 		log.Error("failed to create directory", "err", err, "path", path)
 		return oapi.CreateDirectory500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to create directory"}}, nil
 	}
@@ -143,7 +522,11 @@ func (s *ApiService) DeleteFile(ctx context.Context, req oapi.DeleteFileRequestO
 	if path == "" {
 		return oapi.DeleteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
 	}
-	if err := os.Remove(path); err != nil {
+	backend, relPath, err := s.resolveMount(path, req.Body.Mount)
+	if err != nil {
+		return oapi.DeleteFile400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	if err := backend.Remove(ctx, relPath); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return oapi.DeleteFile404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "file not found"}}, nil
 		}
@@ -163,7 +546,11 @@ func (s *ApiService) DeleteDirectory(ctx context.Context, req oapi.DeleteDirecto
 	if path == "" {
 		return oapi.DeleteDirectory400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
 	}
-	if err := os.RemoveAll(path); err != nil {
+	backend, relPath, err := s.resolveMount(path, req.Body.Mount)
+	if err != nil {
+		return oapi.DeleteDirectory400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	if err := backend.RemoveAll(ctx, relPath); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return oapi.DeleteDirectory404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "directory not found"}}, nil
 		}
@@ -180,7 +567,11 @@ func (s *ApiService) ListFiles(ctx context.Context, req oapi.ListFilesRequestObj
 	if path == "" {
 		return oapi.ListFiles400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
 	}
-	entries, err := os.ReadDir(path)
+	backend, relPath, err := s.resolveMount(path, req.Params.Mount)
+	if err != nil {
+		return oapi.ListFiles400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	entries, err := backend.ReadDir(ctx, relPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return oapi.ListFiles404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "directory not found"}}, nil
@@ -189,16 +580,7 @@ func (s *ApiService) ListFiles(ctx context.Context, req oapi.ListFilesRequestObj
 		return oapi.ListFiles500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to read directory"}}, nil
 	}
 	var list oapi.ListFiles
-	for _, entry := range entries {
-		// Retrieve FileInfo for each entry. If this fails (e.g. broken symlink, permission
-		// error) we surface the failure to the client instead of silently ignoring it so
-		// that consumers do not unknowingly operate on incomplete or unreliable metadata.
-		info, err := entry.Info()
-		if err != nil {
-			log.Error("failed to stat directory entry", "err", err, "dir", path, "entry", entry.Name())
-			return oapi.ListFiles500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to stat directory entry"}}, nil
-		}
-
+	for _, info := range entries {
 		// By specification SizeBytes should be 0 for directories.
 		size := 0
 		if !info.IsDir() {
@@ -206,9 +588,9 @@ func (s *ApiService) ListFiles(ctx context.Context, req oapi.ListFilesRequestObj
 		}
 
 		fi := oapi.FileInfo{
-			Name:      entry.Name(),
-			Path:      filepath.Join(path, entry.Name()),
-			IsDir:     entry.IsDir(),
+			Name:      info.Name(),
+			Path:      filepath.Join(path, info.Name()),
+			IsDir:     info.IsDir(),
 			SizeBytes: size,
 			ModTime:   info.ModTime(),
 			Mode:      info.Mode().String(),
@@ -226,7 +608,11 @@ func (s *ApiService) FileInfo(ctx context.Context, req oapi.FileInfoRequestObjec
 	if path == "" {
 		return oapi.FileInfo400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
 	}
-	stat, err := os.Stat(path)
+	backend, relPath, err := s.resolveMount(path, req.Params.Mount)
+	if err != nil {
+		return oapi.FileInfo400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	stat, err := backend.Stat(ctx, relPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return oapi.FileInfo404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "path not found"}}, nil
@@ -245,7 +631,8 @@ func (s *ApiService) FileInfo(ctx context.Context, req oapi.FileInfoRequestObjec
 	return oapi.FileInfo200JSONResponse(fi), nil
 }
 
-// MovePath renames or moves a file/directory.
+// MovePath renames or moves a file/directory. Both paths must resolve to the same backend: moving
+// across backends would require a copy-then-delete that none of the handlers below do today.
 func (s *ApiService) MovePath(ctx context.Context, req oapi.MovePathRequestObject) (oapi.MovePathResponseObject, error) {
 	log := logger.FromContext(ctx)
 	if req.Body == nil {
@@ -256,7 +643,18 @@ func (s *ApiService) MovePath(ctx context.Context, req oapi.MovePathRequestObjec
 	if src == "" || dst == "" {
 		return oapi.MovePath400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "src_path and dest_path required"}}, nil
 	}
-	if err := os.Rename(src, dst); err != nil {
+	srcBackend, relSrc, err := s.resolveMount(src, req.Body.Mount)
+	if err != nil {
+		return oapi.MovePath400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	dstBackend, relDst, err := s.resolveMount(dst, req.Body.Mount)
+	if err != nil {
+		return oapi.MovePath400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	if srcBackend != dstBackend {
+		return oapi.MovePath400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "src_path and dest_path must resolve to the same mount"}}, nil
+	}
+	if err := srcBackend.Rename(ctx, relSrc, relDst); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return oapi.MovePath404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "source not found"}}, nil
 		}
@@ -266,7 +664,9 @@ func (s *ApiService) MovePath(ctx context.Context, req oapi.MovePathRequestObjec
 	return oapi.MovePath200Response{}, nil
 }
 
-// SetFilePermissions changes mode (and optionally owner/group) of a path.
+// SetFilePermissions changes mode (and optionally owner/group) of a path. Backends with no POSIX
+// permission model (S3, WebDAV) return 501, since there is nothing honest to report back as having
+// succeeded.
 func (s *ApiService) SetFilePermissions(ctx context.Context, req oapi.SetFilePermissionsRequestObject) (oapi.SetFilePermissionsResponseObject, error) {
 	log := logger.FromContext(ctx)
 	if req.Body == nil {
@@ -276,15 +676,22 @@ func (s *ApiService) SetFilePermissions(ctx context.Context, req oapi.SetFilePer
 	if path == "" {
 		return oapi.SetFilePermissions400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
 	}
+	backend, relPath, err := s.resolveMount(path, req.Body.Mount)
+	if err != nil {
+		return oapi.SetFilePermissions400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
 	// parse mode
 	modeVal, err := strconv.ParseUint(req.Body.Mode, 8, 32)
 	if err != nil {
 		return oapi.SetFilePermissions400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "invalid mode"}}, nil
 	}
-	if err := os.Chmod(path, os.FileMode(modeVal)); err != nil {
+	if err := backend.Chmod(ctx, relPath, os.FileMode(modeVal)); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return oapi.SetFilePermissions404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "path not found"}}, nil
 		}
+		if errors.Is(err, vfs.ErrNotSupported) {
+			return oapi.SetFilePermissions501JSONResponse{NotImplementedErrorJSONResponse: oapi.NotImplementedErrorJSONResponse{Message: "this mount has no POSIX permission model"}}, nil
+		}
 		log.Error("failed to chmod", "err", err, "path", path)
 		return oapi.SetFilePermissions500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to chmod"}}, nil
 	}
@@ -325,13 +732,28 @@ func (s *ApiService) SetFilePermissions(ctx context.Context, req oapi.SetFilePer
 		}
 		// only attempt if at least one resolved
 		if uid != -1 || gid != -1 {
-			_ = os.Chown(path, uid, gid) // ignore error (likely EPERM) to keep API simpler
+			_ = backend.Chown(ctx, relPath, uid, gid) // ignore error (likely EPERM, or ErrNotSupported) to keep API simpler
 		}
 	}
 	return oapi.SetFilePermissions200Response{}, nil
 }
 
-// StartFsWatch is not implemented in this basic filesystem handler. It returns a 400 error to the client.
+// ListMounts returns every non-local storage backend registered at startup (see
+// vfs.LoadMountsFromEnv), so a client can discover what mount names or scheme prefixes it can pass
+// to the other filesystem handlers.
+func (s *ApiService) ListMounts(ctx context.Context, req oapi.ListMountsRequestObject) (oapi.ListMountsResponseObject, error) {
+	var mounts oapi.ListMounts
+	for _, m := range s.mounts.List() {
+		mounts = append(mounts, oapi.MountInfo{Name: m.Name, Scheme: m.Scheme})
+	}
+	return oapi.ListMounts200JSONResponse(mounts), nil
+}
+
+// StartFsWatch begins watching path (optionally recursively) for filesystem changes, returning a
+// watch_id to pass to StreamFsEvents/StopFsWatch. Raw fsnotify events pass through an
+// fsEventPipeline (fs_watch_pipeline.go) that applies the request's include/exclude glob filters,
+// debounces/coalesces WRITEs and RENAME+CREATE pairs, and enforces the requested backpressure
+// policy before events reach the watch's channel.
 func (s *ApiService) StartFsWatch(ctx context.Context, req oapi.StartFsWatchRequestObject) (oapi.StartFsWatchResponseObject, error) {
 	log := logger.FromContext(ctx)
 	if req.Body == nil {
@@ -371,12 +793,28 @@ func (s *ApiService) StartFsWatch(ctx context.Context, req oapi.StartFsWatchRequ
 		}
 	}
 
+	debounce := defaultFsWatchDebounce
+	if req.Body.DebounceMs != nil && *req.Body.DebounceMs > 0 {
+		debounce = time.Duration(*req.Body.DebounceMs) * time.Millisecond
+	}
+	backpressureTimeout := defaultFsWatchBackpressureTimeout
+	if req.Body.BackpressureTimeoutMs != nil && *req.Body.BackpressureTimeoutMs > 0 {
+		backpressureTimeout = time.Duration(*req.Body.BackpressureTimeoutMs) * time.Millisecond
+	}
+	mode := oapi.FsWatchBackpressureDropNewest
+	if req.Body.BackpressureMode != nil {
+		mode = *req.Body.BackpressureMode
+	}
+	filter := fsWatchFilter{include: req.Body.Include, exclude: req.Body.Exclude}
+
 	watchID := cuid2.Generate()
+	eventsCh := make(chan oapi.FileSystemEvent, 100)
 	w := &fsWatch{
 		path:      path,
 		recursive: recursive,
-		events:    make(chan oapi.FileSystemEvent, 100),
+		events:    eventsCh,
 		watcher:   watcher,
+		pipeline:  newFsEventPipeline(eventsCh, filter, debounce, backpressureTimeout, mode),
 	}
 
 	// Register the watch before starting the forwarding goroutine to avoid a
@@ -394,6 +832,7 @@ func (s *ApiService) StartFsWatch(ctx context.Context, req oapi.StartFsWatchRequ
 		defer func() {
 			// Best-effort close (idempotent).
 			watcher.Close()
+			w.pipeline.Close()
 
 			// Remove stale entry to avoid map/chan leak if the watch stops on
 			// its own (e.g. underlying fs error, watcher overflow, etc.). It
@@ -427,13 +866,10 @@ func (s *ApiService) StartFsWatch(ctx context.Context, req oapi.StartFsWatchRequ
 				info, _ := os.Stat(ev.Name)
 				isDir := info != nil && info.IsDir()
 				name := filepath.Base(ev.Name)
-				// Attempt a non-blocking send so that event production never blocks
-				// even if the consumer is slow or absent. When the buffer is full we
-				// simply drop the event, preferring liveness over completeness.
-				select {
-				case w.events <- oapi.FileSystemEvent{Type: evType, Path: ev.Name, Name: &name, IsDir: &isDir}:
-				default:
-				}
+				// w.pipeline applies the include/exclude filters, debounces/coalesces the raw
+				// op, and only then delivers to w.events per the watch's configured
+				// backpressure policy - see fs_watch_pipeline.go.
+				w.pipeline.Ingest(oapi.FileSystemEvent{Type: evType, Path: ev.Name, Name: &name, IsDir: &isDir})
 
 				// If recursive and new directory created, add watch.
 				if recursive && evType == "CREATE" && isDir {
@@ -488,14 +924,28 @@ func (s *ApiService) StreamFsEvents(ctx context.Context, req oapi.StreamFsEvents
 	go func() {
 		defer pw.Close()
 		enc := json.NewEncoder(pw)
-		for ev := range w.events {
-			// Write SSE formatted event: data: <json>\n\n
-			pw.Write([]byte("data: "))
-			if err := enc.Encode(ev); err != nil {
-				log.Error("failed to encode fs event", "err", err)
-				return
+		heartbeat := time.NewTicker(fsWatchHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case ev, ok := <-w.events:
+				if !ok {
+					return
+				}
+				// Write SSE formatted event: data: <json>\n\n
+				pw.Write([]byte("data: "))
+				if err := enc.Encode(ev); err != nil {
+					log.Error("failed to encode fs event", "err", err)
+					return
+				}
+				pw.Write([]byte("\n"))
+			case <-heartbeat.C:
+				// SSE comment line: keeps idle connections alive through proxies that kill
+				// connections with no traffic, without the client mistaking it for an event.
+				if _, err := pw.Write([]byte(": heartbeat\n\n")); err != nil {
+					return
+				}
 			}
-			pw.Write([]byte("\n"))
 		}
 	}()
 
@@ -5,11 +5,14 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"mime"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
 )
@@ -84,6 +87,208 @@ func TestWriteReadDownloadFile(t *testing.T) {
 	}
 }
 
+// TestWriteFileContentRange verifies the Content-Range fallback writes a single byte range
+// without disturbing the rest of the file, including when ranges arrive out of order.
+func TestWriteFileContentRange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := &ApiService{defaultRecorderID: "default"}
+
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "range.bin")
+	want := "hello world"
+
+	rangeSecond := "bytes 6-10/11"
+	if resp, err := svc.WriteFile(ctx, oapi.WriteFileRequestObject{
+		Params: oapi.WriteFileParams{Path: filePath, ContentRange: &rangeSecond},
+		Body:   strings.NewReader("world"),
+	}); err != nil {
+		t.Fatalf("WriteFile (second range) returned error: %v", err)
+	} else if _, ok := resp.(oapi.WriteFile201Response); !ok {
+		t.Fatalf("unexpected response type from WriteFile: %T", resp)
+	}
+
+	rangeFirst := "bytes 0-5/11"
+	if resp, err := svc.WriteFile(ctx, oapi.WriteFileRequestObject{
+		Params: oapi.WriteFileParams{Path: filePath, ContentRange: &rangeFirst},
+		Body:   strings.NewReader("hello "),
+	}); err != nil {
+		t.Fatalf("WriteFile (first range) returned error: %v", err)
+	} else if _, ok := resp.(oapi.WriteFile201Response); !ok {
+		t.Fatalf("unexpected response type from WriteFile: %T", resp)
+	}
+
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read range-written file: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("range-written file mismatch: got %q want %q", got, want)
+	}
+}
+
+// TestReadFileRangesAndConditionalGET verifies RFC 7233 range handling (single range, suffix
+// range, multi-range, and out-of-range 416) and conditional GET handling (If-None-Match and
+// If-Modified-Since) on ReadFile.
+func TestReadFileRangesAndConditionalGET(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := &ApiService{defaultRecorderID: "default"}
+
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "test.txt")
+	content := "the quick brown fox jumps over the lazy dog"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+
+	read := func(params oapi.ReadFileParams) oapi.ReadFileResponseObject {
+		params.Path = filePath
+		resp, err := svc.ReadFile(ctx, oapi.ReadFileRequestObject{Params: params})
+		if err != nil {
+			t.Fatalf("ReadFile returned error: %v", err)
+		}
+		return resp
+	}
+
+	// Single range.
+	singleRange := "bytes=4-8"
+	resp := read(oapi.ReadFileParams{Range: &singleRange})
+	r206, ok := resp.(oapi.ReadFile206ApplicationoctetStreamResponse)
+	if !ok {
+		t.Fatalf("unexpected response type for single range: %T", resp)
+	}
+	data, _ := io.ReadAll(r206.Body)
+	if got, want := string(data), content[4:9]; got != want {
+		t.Fatalf("single range content mismatch: got %q want %q", got, want)
+	}
+	if got, want := r206.Headers.ContentRange, "bytes 4-8/43"; got != want {
+		t.Fatalf("unexpected Content-Range: got %q want %q", got, want)
+	}
+
+	// Suffix range: the last 8 bytes ("lazy dog").
+	suffixRange := "bytes=-8"
+	resp = read(oapi.ReadFileParams{Range: &suffixRange})
+	r206, ok = resp.(oapi.ReadFile206ApplicationoctetStreamResponse)
+	if !ok {
+		t.Fatalf("unexpected response type for suffix range: %T", resp)
+	}
+	data, _ = io.ReadAll(r206.Body)
+	if got, want := string(data), "lazy dog"; got != want {
+		t.Fatalf("suffix range content mismatch: got %q want %q", got, want)
+	}
+
+	// Multi-range request yields a multipart/byteranges body.
+	multiRange := "bytes=0-2,4-8"
+	resp = read(oapi.ReadFileParams{Range: &multiRange})
+	rMulti, ok := resp.(oapi.ReadFile206MultipartByterangesResponse)
+	if !ok {
+		t.Fatalf("unexpected response type for multi-range: %T", resp)
+	}
+	_, params, err := mime.ParseMediaType(rMulti.Headers.ContentType)
+	if err != nil {
+		t.Fatalf("failed to parse multipart content type: %v", err)
+	}
+	mr := multipart.NewReader(rMulti.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read multipart part: %v", err)
+		}
+		b, _ := io.ReadAll(part)
+		parts = append(parts, string(b))
+	}
+	if want := []string{content[0:3], content[4:9]}; !equalStringSlices(parts, want) {
+		t.Fatalf("multipart byteranges mismatch: got %v want %v", parts, want)
+	}
+
+	// Out-of-range request is rejected with 416 and a Content-Range giving the resource's size.
+	outOfRange := "bytes=1000-2000"
+	resp = read(oapi.ReadFileParams{Range: &outOfRange})
+	r416, ok := resp.(oapi.ReadFile416JSONResponse)
+	if !ok {
+		t.Fatalf("unexpected response type for out-of-range request: %T", resp)
+	}
+	if got, want := r416.Headers.ContentRange, "bytes */43"; got != want {
+		t.Fatalf("unexpected 416 Content-Range: got %q want %q", got, want)
+	}
+
+	// If-None-Match with the current ETag short-circuits to 304.
+	full := read(oapi.ReadFileParams{})
+	r200, ok := full.(oapi.ReadFile200ApplicationoctetStreamResponse)
+	if !ok {
+		t.Fatalf("unexpected response type for unconditional GET: %T", full)
+	}
+	etag := r200.Headers.ETag
+
+	resp = read(oapi.ReadFileParams{IfNoneMatch: &etag})
+	if _, ok := resp.(oapi.ReadFile304Response); !ok {
+		t.Fatalf("expected 304 for matching If-None-Match, got %T", resp)
+	}
+
+	// A mismatched ETag still serves the full body.
+	staleETag := `W/"stale-etag"`
+	resp = read(oapi.ReadFileParams{IfNoneMatch: &staleETag})
+	if _, ok := resp.(oapi.ReadFile200ApplicationoctetStreamResponse); !ok {
+		t.Fatalf("expected 200 for mismatched If-None-Match, got %T", resp)
+	}
+
+	// If-Modified-Since in the future also short-circuits to 304.
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	resp = read(oapi.ReadFileParams{IfModifiedSince: &future})
+	if _, ok := resp.(oapi.ReadFile304Response); !ok {
+		t.Fatalf("expected 304 for future If-Modified-Since, got %T", resp)
+	}
+
+	// Every response carries Accept-Ranges and Last-Modified.
+	if got, want := r200.Headers.AcceptRanges, "bytes"; got != want {
+		t.Fatalf("unexpected Accept-Ranges: got %q want %q", got, want)
+	}
+	if r200.Headers.LastModified == "" {
+		t.Fatalf("expected Last-Modified to be set")
+	}
+	if got, want := r206.Headers.AcceptRanges, "bytes"; got != want {
+		t.Fatalf("unexpected Accept-Ranges on range response: got %q want %q", got, want)
+	}
+
+	// A Range request with a matching If-Range is honored.
+	resp = read(oapi.ReadFileParams{Range: &singleRange, IfRange: &etag})
+	if _, ok := resp.(oapi.ReadFile206ApplicationoctetStreamResponse); !ok {
+		t.Fatalf("expected 206 for matching If-Range, got %T", resp)
+	}
+
+	// A Range request with a stale If-Range ETag falls back to the full, current body.
+	resp = read(oapi.ReadFileParams{Range: &singleRange, IfRange: &staleETag})
+	if _, ok := resp.(oapi.ReadFile200ApplicationoctetStreamResponse); !ok {
+		t.Fatalf("expected 200 for mismatched If-Range, got %T", resp)
+	}
+
+	// A Range request with an If-Range date in the past (before the file's mtime) also falls back.
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	resp = read(oapi.ReadFileParams{Range: &singleRange, IfRange: &past})
+	if _, ok := resp.(oapi.ReadFile200ApplicationoctetStreamResponse); !ok {
+		t.Fatalf("expected 200 for stale If-Range date, got %T", resp)
+	}
+}
+
+func equalStringSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // TestUploadFiles verifies multipart upload and filesystem watch event generation.
 func TestUploadFilesAndWatch(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+)
+
+// This file implements a tus-style resumable upload protocol for arbitrary filesystem writes,
+// the same shape as extension_upload.go's extension bundle uploads: POST /fs/uploads creates a
+// session for a declared destination path, size, and sha256; PUT /fs/uploads/{id} appends one
+// byte-range chunk each (offset/length as query params, with an optional per-chunk sha256 to
+// catch corruption before it's durably written); GET /fs/uploads/{id} reports the session's
+// current offset for resume after a disconnect; and POST /fs/uploads/{id}/complete verifies the
+// overall declared sha256 and moves the assembled temp file into place (a rename, or a
+// copy-then-remove when the destination is on another filesystem). Session state
+// is persisted by uploadsession.Store (the same package extension uploads use), so a client can
+// resume after a network blip or a server restart.
+
+// defaultFsUploadChunkSizeBytes is handed back to a client that doesn't request a specific chunk
+// size. maxFsUploadChunkSizeBytes bounds how large a single chunk a client may declare, so one
+// PUT can't force the server to buffer an unbounded amount of memory before writing it out.
+const (
+	defaultFsUploadChunkSizeBytes = 8 << 20  // 8MB
+	maxFsUploadChunkSizeBytes     = 64 << 20 // 64MB
+)
+
+// fsUploadSessionTTL/fsUploadJanitorInterval govern the background janitor (started from main
+// via StartFsUploadJanitor) that GCs sessions an uploading client abandoned without calling CompleteFsUpload.
+const (
+	fsUploadSessionTTL      = 24 * time.Hour
+	fsUploadJanitorInterval = 10 * time.Minute
+)
+
+type createFsUploadRequest struct {
+	Path           string `json:"path"`
+	Size           int64  `json:"size"`
+	SHA256         string `json:"sha256"`
+	Mode           string `json:"mode,omitempty"`
+	ChunkSizeBytes int64  `json:"chunk_size_bytes,omitempty"`
+}
+
+// HandleCreateFsUpload starts a new resumable filesystem upload session and returns its id and
+// the chunk size the client should use for each subsequent PUT.
+func (s *ApiService) HandleCreateFsUpload(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	var req createFsUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" {
+		http.Error(w, "path cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if req.Size <= 0 {
+		http.Error(w, "size must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.SHA256 == "" {
+		http.Error(w, "sha256 is required", http.StatusBadRequest)
+		return
+	}
+
+	chunkSize := req.ChunkSizeBytes
+	if chunkSize <= 0 {
+		chunkSize = defaultFsUploadChunkSizeBytes
+	}
+	if chunkSize > maxFsUploadChunkSizeBytes {
+		http.Error(w, fmt.Sprintf("chunk_size_bytes exceeds %d byte limit", maxFsUploadChunkSizeBytes), http.StatusBadRequest)
+		return
+	}
+
+	if req.Mode != "" {
+		if _, err := strconv.ParseUint(req.Mode, 8, 32); err != nil {
+			http.Error(w, "invalid mode", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(req.Path), 0o755); err != nil {
+		log.Error("failed to create destination directories", "err", err, "path", req.Path)
+		http.Error(w, "unable to create destination directories", http.StatusBadRequest)
+		return
+	}
+
+	sess, err := s.fsUploadSessions.CreateWithMode(req.Path, req.Size, req.SHA256, chunkSize, req.Mode)
+	if err != nil {
+		log.Error("failed to create fs upload session", "err", err)
+		http.Error(w, "failed to create upload session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("created fs upload session", "id", sess.ID, "path", sess.Name, "size", sess.DeclaredSize)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":               sess.ID,
+		"chunk_size_bytes": sess.ChunkSize,
+		"offset":           sess.Offset,
+	})
+}
+
+// HandleFsUploadStatus reports a session's progress, so a client resuming after a network blip
+// or server restart knows where to continue from.
+func (s *ApiService) HandleFsUploadStatus(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.fsUploadSessions.Load(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "upload session not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":            sess.ID,
+		"path":          sess.Name,
+		"offset":        sess.Offset,
+		"declared_size": sess.DeclaredSize,
+		"chunk_size":    sess.ChunkSize,
+		"complete":      sess.Offset == sess.DeclaredSize,
+	})
+}
+
+// HandleUploadFsChunk appends one chunk to a session. offset and length are required query
+// params; offset must match the session's current offset exactly, and length must match the
+// request body's actual length, so a short or reordered upload is rejected rather than silently
+// accepted. An optional sha256 query param, if present, is checked against the chunk's own
+// digest before it's written, catching corruption in flight instead of only at CompleteFsUpload.
+func (s *ApiService) HandleUploadFsChunk(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	sess, err := s.fsUploadSessions.Load(id)
+	if err != nil {
+		http.Error(w, "upload session not found", http.StatusNotFound)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "offset query param must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.URL.Query().Get("length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "length query param must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	if offset != sess.Offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+		http.Error(w, fmt.Sprintf("chunk starts at %d, expected %d", offset, sess.Offset), http.StatusConflict)
+		return
+	}
+	if offset+length > sess.DeclaredSize {
+		http.Error(w, "chunk would extend past declared size", http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, length+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read chunk body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) != length {
+		http.Error(w, "chunk body does not match declared length", http.StatusBadRequest)
+		return
+	}
+
+	if wantSHA256 := r.URL.Query().Get("sha256"); wantSHA256 != "" {
+		if got := sha256Hex(data); got != wantSHA256 {
+			http.Error(w, "chunk sha256 mismatch", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := s.fsUploadSessions.AppendChunk(sess, offset, data); err != nil {
+		log.Error("failed to append fs upload chunk", "id", id, "err", err)
+		http.Error(w, "failed to append chunk", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleCompleteFsUpload finalizes a session once all chunks have arrived: verifies the declared
+// size and sha256, then moves the assembled temp file into place at sess.Name via moveFile,
+// applying sess.Mode if one was declared at creation.
+func (s *ApiService) HandleCompleteFsUpload(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	sess, err := s.fsUploadSessions.Load(id)
+	if err != nil {
+		http.Error(w, "upload session not found", http.StatusNotFound)
+		return
+	}
+	if sess.Offset != sess.DeclaredSize {
+		http.Error(w, fmt.Sprintf("upload incomplete: have %d of %d bytes", sess.Offset, sess.DeclaredSize), http.StatusConflict)
+		return
+	}
+
+	sum, err := sha256SumFile(sess.TempPath)
+	if err != nil {
+		log.Error("failed to checksum fs upload", "id", id, "err", err)
+		http.Error(w, "failed to checksum upload", http.StatusInternalServerError)
+		return
+	}
+	if sum != sess.DeclaredSHA256 {
+		http.Error(w, "sha256 mismatch", http.StatusConflict)
+		return
+	}
+
+	perm := os.FileMode(0o644)
+	if sess.Mode != "" {
+		if v, err := strconv.ParseUint(sess.Mode, 8, 32); err == nil {
+			perm = os.FileMode(v)
+		}
+	}
+	if err := os.Chmod(sess.TempPath, perm); err != nil {
+		log.Error("failed to chmod assembled upload", "id", id, "err", err)
+		http.Error(w, "failed to set file mode", http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sess.Name), 0o755); err != nil {
+		log.Error("failed to create destination directories", "id", id, "err", err)
+		http.Error(w, "failed to create destination directories", http.StatusInternalServerError)
+		return
+	}
+	if err := moveFile(sess.TempPath, sess.Name); err != nil {
+		log.Error("failed to finalize fs upload", "id", id, "err", err)
+		http.Error(w, "failed to move upload into place", http.StatusInternalServerError)
+		return
+	}
+
+	s.fsUploadSessions.Delete(id)
+	log.Info("committed fs upload", "id", id, "path", sess.Name)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// moveFile renames src to dst, falling back to a copy-then-remove when they're on different
+// filesystems (os.Rename returns EXDEV), which is routine for /tmp-backed upload staging vs an
+// arbitrary destination path.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to finalize destination file: %w", err)
+	}
+	_ = os.Remove(src)
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// StartFsUploadJanitor GCs abandoned fs upload sessions (and their temp files) older than
+// fsUploadSessionTTL, on an fsUploadJanitorInterval tick, until ctx is canceled. Called once from
+// main at startup, the same way devtoolsproxy.UpstreamManager.Start is.
+func (s *ApiService) StartFsUploadJanitor(ctx context.Context) {
+	s.fsUploadSessions.StartJanitor(ctx, fsUploadSessionTTL, fsUploadJanitorInterval)
+}
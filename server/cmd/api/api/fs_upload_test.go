@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/onkernel/kernel-images/server/lib/uploadsession"
+)
+
+func newFsUploadTestService(t *testing.T) *ApiService {
+	t.Helper()
+	store, err := uploadsession.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore error: %v", err)
+	}
+	return &ApiService{defaultRecorderID: "default", fsUploadSessions: store}
+}
+
+func newFsUploadRouter(svc *ApiService) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/fs/uploads", svc.HandleCreateFsUpload)
+	r.Put("/fs/uploads/{id}", svc.HandleUploadFsChunk)
+	r.Get("/fs/uploads/{id}", svc.HandleFsUploadStatus)
+	r.Post("/fs/uploads/{id}/complete", svc.HandleCompleteFsUpload)
+	return r
+}
+
+func createFsUpload(t *testing.T, r http.Handler, path string, data []byte, chunkSize int64) string {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	body, _ := json.Marshal(createFsUploadRequest{
+		Path:           path,
+		Size:           int64(len(data)),
+		SHA256:         hex.EncodeToString(sum[:]),
+		ChunkSizeBytes: chunkSize,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/fs/uploads", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateFsUpload: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode CreateFsUpload response: %v", err)
+	}
+	return resp["id"].(string)
+}
+
+func putFsChunk(r http.Handler, id string, offset int64, data []byte) *httptest.ResponseRecorder {
+	url := fmt.Sprintf("/fs/uploads/%s?offset=%d&length=%d", id, offset, len(data))
+	req := httptest.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestFsChunkedUploadResume verifies a file can be assembled from two out-of-process chunks and
+// that HandleFsUploadStatus reports progress in between, covering the "resume" case described in the
+// backlog request.
+func TestFsChunkedUploadResume(t *testing.T) {
+	t.Parallel()
+
+	svc := newFsUploadTestService(t)
+	r := newFsUploadRouter(svc)
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "nested", "out.bin")
+	content := []byte("the quick brown fox jumps over the lazy dog")
+	split := 20
+
+	id := createFsUpload(t, r, dest, content, 0)
+
+	// Status before any chunk has arrived.
+	statusReq := httptest.NewRequest(http.MethodGet, "/fs/uploads/"+id, nil)
+	statusRec := httptest.NewRecorder()
+	r.ServeHTTP(statusRec, statusReq)
+	var status map[string]any
+	if err := json.Unmarshal(statusRec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if status["offset"].(float64) != 0 {
+		t.Fatalf("expected offset 0 before any chunk, got %v", status["offset"])
+	}
+
+	if rec := putFsChunk(r, id, 0, content[:split]); rec.Code != http.StatusNoContent {
+		t.Fatalf("first chunk: expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Simulate a disconnect and resume: re-check status, then send the remaining bytes.
+	statusRec = httptest.NewRecorder()
+	r.ServeHTTP(statusRec, httptest.NewRequest(http.MethodGet, "/fs/uploads/"+id, nil))
+	status = nil
+	if err := json.Unmarshal(statusRec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if got := int(status["offset"].(float64)); got != split {
+		t.Fatalf("expected offset %d after first chunk, got %d", split, got)
+	}
+
+	if rec := putFsChunk(r, id, int64(split), content[split:]); rec.Code != http.StatusNoContent {
+		t.Fatalf("second chunk: expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	completeRec := httptest.NewRecorder()
+	r.ServeHTTP(completeRec, httptest.NewRequest(http.MethodPost, "/fs/uploads/"+id+"/complete", nil))
+	if completeRec.Code != http.StatusCreated {
+		t.Fatalf("CompleteFsUpload: expected 201, got %d: %s", completeRec.Code, completeRec.Body.String())
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("failed to read assembled file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("assembled file mismatch: got %q want %q", got, content)
+	}
+}
+
+// TestFsChunkedUploadOffsetMismatch verifies a chunk sent at the wrong offset is rejected rather
+// than silently reordered.
+func TestFsChunkedUploadOffsetMismatch(t *testing.T) {
+	t.Parallel()
+
+	svc := newFsUploadTestService(t)
+	r := newFsUploadRouter(svc)
+
+	dest := filepath.Join(t.TempDir(), "out.bin")
+	content := []byte("hello world")
+	id := createFsUpload(t, r, dest, content, 0)
+
+	rec := putFsChunk(r, id, 5, content)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for offset mismatch, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Upload-Offset"); got != "0" {
+		t.Fatalf("expected Upload-Offset header 0, got %q", got)
+	}
+}
+
+// TestFsChunkedUploadCorruption verifies a chunk whose content doesn't match its declared
+// per-chunk sha256 is rejected, and that CompleteFsUpload rejects an upload whose assembled bytes
+// don't match the overall declared sha256.
+func TestFsChunkedUploadCorruption(t *testing.T) {
+	t.Parallel()
+
+	svc := newFsUploadTestService(t)
+	r := newFsUploadRouter(svc)
+
+	dest := filepath.Join(t.TempDir(), "out.bin")
+	content := []byte("hello world")
+	id := createFsUpload(t, r, dest, content, 0)
+
+	// Wrong per-chunk sha256 should be rejected before anything is written.
+	url := fmt.Sprintf("/fs/uploads/%s?offset=0&length=%d&sha256=%s", id, len(content), "deadbeef")
+	req := httptest.NewRequest(http.MethodPut, url, bytes.NewReader(content))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for chunk sha256 mismatch, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Now corrupt the overall declared hash instead: create a fresh session whose declared
+	// sha256 doesn't match the bytes that will actually be uploaded.
+	corruptID := createFsUpload(t, r, dest, []byte("HELLO WORLD"), 0)
+	if rec := putFsChunk(r, corruptID, 0, content); rec.Code != http.StatusNoContent {
+		t.Fatalf("chunk upload: expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	completeRec := httptest.NewRecorder()
+	r.ServeHTTP(completeRec, httptest.NewRequest(http.MethodPost, "/fs/uploads/"+corruptID+"/complete", nil))
+	if completeRec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for overall sha256 mismatch, got %d: %s", completeRec.Code, completeRec.Body.String())
+	}
+}
+
+// TestFsUploadGC verifies the janitor removes sessions older than its TTL and leaves fresh ones
+// alone.
+func TestFsUploadGC(t *testing.T) {
+	t.Parallel()
+
+	svc := newFsUploadTestService(t)
+	r := newFsUploadRouter(svc)
+
+	dest := filepath.Join(t.TempDir(), "out.bin")
+	staleID := createFsUpload(t, r, dest, []byte("stale"), 0)
+	freshID := createFsUpload(t, r, dest, []byte("fresh"), 0)
+
+	stale, err := svc.fsUploadSessions.Load(staleID)
+	if err != nil {
+		t.Fatalf("failed to load stale session: %v", err)
+	}
+	stale.CreatedAt = stale.CreatedAt.Add(-2 * fsUploadSessionTTL)
+	if err := svc.fsUploadSessions.Save(stale); err != nil {
+		t.Fatalf("failed to backdate stale session: %v", err)
+	}
+
+	removed, err := svc.fsUploadSessions.GC(fsUploadSessionTTL)
+	if err != nil {
+		t.Fatalf("GC error: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("expected 1 session removed, got %d", removed)
+	}
+	if _, err := svc.fsUploadSessions.Load(staleID); err == nil {
+		t.Fatalf("expected stale session to be gone")
+	}
+	if _, err := svc.fsUploadSessions.Load(freshID); err != nil {
+		t.Fatalf("expected fresh session to survive GC: %v", err)
+	}
+}
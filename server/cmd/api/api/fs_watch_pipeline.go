@@ -0,0 +1,222 @@
+package api
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// This file implements the event pipeline StartFsWatch hands raw fsnotify events through before
+// they reach a watch's events channel: glob include/exclude filtering, WRITE debouncing and
+// RENAME+CREATE-to-MOVE coalescing (so an editor's save-via-rename doesn't fan out into a burst
+// the caller has to de-dupe itself), and an explicit backpressure policy so a slow SSE consumer
+// loses fidelity in a documented way instead of silently.
+
+// defaultFsWatchDebounce is used when a StartFsWatch request doesn't specify one.
+const defaultFsWatchDebounce = 200 * time.Millisecond
+
+// defaultFsWatchBackpressureTimeout is used by the block_with_timeout mode when a request doesn't
+// specify one.
+const defaultFsWatchBackpressureTimeout = 2 * time.Second
+
+// fsWatchHeartbeatInterval governs how often StreamFsEvents writes an SSE comment line to keep
+// idle connections alive through proxies that time out connections with no traffic.
+const fsWatchHeartbeatInterval = 15 * time.Second
+
+// fsWatchFilter reports whether a path should be delivered to the caller, based on the
+// include/exclude glob lists given to StartFsWatch. Exclude takes precedence over include; an
+// empty include list matches everything.
+type fsWatchFilter struct {
+	include []string
+	exclude []string
+}
+
+func (f fsWatchFilter) allows(path string) bool {
+	name := filepath.Base(path)
+	for _, pat := range f.exclude {
+		if ok, _ := filepath.Match(pat, name); ok {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pat := range f.include {
+		if ok, _ := filepath.Match(pat, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fsEventPipeline coalesces and rate-limits raw fsnotify-derived events for a single watch before
+// they reach its events channel. Ingest is safe to call from the watch's single forwarding
+// goroutine only; debounce timers fire on their own goroutines and are the only other writer, so
+// both take mu.
+type fsEventPipeline struct {
+	filter              fsWatchFilter
+	debounce            time.Duration
+	backpressure        oapi.FsWatchBackpressureMode
+	backpressureTimeout time.Duration
+	out                 chan oapi.FileSystemEvent
+	dropped             int64 // atomic
+
+	mu            sync.Mutex
+	pendingWrites map[string]*time.Timer
+	pendingRename *oapi.FileSystemEvent
+	renameTimer   *time.Timer
+}
+
+func newFsEventPipeline(out chan oapi.FileSystemEvent, filter fsWatchFilter, debounce, backpressureTimeout time.Duration, mode oapi.FsWatchBackpressureMode) *fsEventPipeline {
+	return &fsEventPipeline{
+		filter:              filter,
+		debounce:            debounce,
+		backpressure:        mode,
+		backpressureTimeout: backpressureTimeout,
+		out:                 out,
+		pendingWrites:       make(map[string]*time.Timer),
+	}
+}
+
+// droppedCount returns the number of events lost to backpressure so far.
+func (p *fsEventPipeline) droppedCount() int64 {
+	return atomic.LoadInt64(&p.dropped)
+}
+
+// Ingest applies filtering and coalescing to one raw event and, once an event is ready to
+// deliver, hands it to deliver.
+func (p *fsEventPipeline) Ingest(ev oapi.FileSystemEvent) {
+	if !p.filter.allows(ev.Path) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch ev.Type {
+	case "RENAME":
+		// A rename's destination arrives as a separate CREATE; hold this one until either that
+		// CREATE shows up (collapsing into a MOVE) or the debounce window elapses (flush as-is).
+		if p.renameTimer != nil {
+			p.renameTimer.Stop()
+			p.flushLocked(*p.pendingRename)
+		}
+		renameEv := ev
+		p.pendingRename = &renameEv
+		p.renameTimer = time.AfterFunc(p.debounce, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.pendingRename == nil {
+				return
+			}
+			flushed := *p.pendingRename
+			p.pendingRename = nil
+			p.renameTimer = nil
+			p.flushLocked(flushed)
+		})
+		return
+
+	case "CREATE":
+		if p.pendingRename != nil {
+			from := p.pendingRename.Path
+			p.renameTimer.Stop()
+			p.pendingRename = nil
+			p.renameTimer = nil
+			moveEv := ev
+			moveEv.Type = "MOVE"
+			moveEv.From = &from
+			to := ev.Path
+			moveEv.To = &to
+			p.flushLocked(moveEv)
+			return
+		}
+		p.flushLocked(ev)
+		return
+
+	case "WRITE":
+		if t, ok := p.pendingWrites[ev.Path]; ok {
+			t.Stop()
+		}
+		evCopy := ev
+		p.pendingWrites[ev.Path] = time.AfterFunc(p.debounce, func() {
+			p.mu.Lock()
+			delete(p.pendingWrites, evCopy.Path)
+			p.mu.Unlock()
+			p.deliver(evCopy)
+		})
+		return
+
+	default:
+		p.flushLocked(ev)
+	}
+}
+
+// flushLocked delivers ev immediately; callers must hold p.mu. deliver itself does not need
+// p.mu, so it's called after unlocking where the caller is a standalone goroutine (the debounce
+// timers above), and directly here where the lock is already held - deliver never touches
+// pendingWrites/pendingRename, only the channel and the drop counter.
+func (p *fsEventPipeline) flushLocked(ev oapi.FileSystemEvent) {
+	p.deliver(ev)
+}
+
+// deliver applies the configured backpressure policy to send ev (or a synthetic OVERFLOW event)
+// to the watch's events channel.
+func (p *fsEventPipeline) deliver(ev oapi.FileSystemEvent) {
+	switch p.backpressure {
+	case oapi.FsWatchBackpressureDropOldest:
+		for {
+			select {
+			case p.out <- ev:
+				return
+			default:
+			}
+			select {
+			case <-p.out:
+				atomic.AddInt64(&p.dropped, 1)
+			default:
+				// Raced with a consumer draining the channel; retry the send.
+			}
+		}
+	case oapi.FsWatchBackpressureBlockWithTimeout:
+		select {
+		case p.out <- ev:
+		case <-time.After(p.backpressureTimeout):
+			atomic.AddInt64(&p.dropped, 1)
+			p.emitOverflow()
+		}
+	default: // drop_newest
+		select {
+		case p.out <- ev:
+		default:
+			atomic.AddInt64(&p.dropped, 1)
+			p.emitOverflow()
+		}
+	}
+}
+
+// emitOverflow best-effort notifies the consumer of the current total dropped count. It never
+// blocks: if the channel has no room for the notification either, the consumer will still see an
+// accurate dropped_count on the next OVERFLOW event that does get through.
+func (p *fsEventPipeline) emitOverflow() {
+	count := int(atomic.LoadInt64(&p.dropped))
+	select {
+	case p.out <- oapi.FileSystemEvent{Type: "OVERFLOW", DroppedCount: &count}:
+	default:
+	}
+}
+
+// Close cancels any in-flight debounce timers so they don't fire (and leak a goroutine reference)
+// after the watch has been stopped.
+func (p *fsEventPipeline) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.pendingWrites {
+		t.Stop()
+	}
+	if p.renameTimer != nil {
+		p.renameTimer.Stop()
+	}
+}
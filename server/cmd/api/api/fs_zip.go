@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/ziputil"
+)
+
+// ExtractZip extracts a zip file already on local disk (e.g. one written via WriteFile) into
+// req.Params.Target, replacing the /tmp-staged-zip-plus-`unzip`-ProcessExec dance the profile
+// restore e2e flow used (see server/e2e/e2e_persist_login_test.go's restoreUserDataDir). Entry
+// names are validated against path traversal before anything is written; see
+// ziputil.entryDestPath.
+func (s *ApiService) ExtractZip(ctx context.Context, req oapi.ExtractZipRequestObject) (oapi.ExtractZipResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Params.Path == "" || req.Params.Target == "" {
+		return oapi.ExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path and target are required"}}, nil
+	}
+
+	opts, err := extractOptionsFromParams(req.Params.Target, req.Params.Overwrite, req.Params.Uid, req.Params.Gid, req.Params.Mode)
+	if err != nil {
+		return oapi.ExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	written, err := ziputil.Extract(req.Params.Path, opts)
+	if err != nil {
+		log.Error("failed to extract zip", "path", req.Params.Path, "target", req.Params.Target, "err", err)
+		return oapi.ExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("extracted zip", "path", req.Params.Path, "target", req.Params.Target, "num_files", len(written))
+	return oapi.ExtractZip200JSONResponse{ExtractedFiles: written}, nil
+}
+
+// UploadAndExtractZip is ExtractZip's streaming counterpart: the client POSTs the zip bytes
+// directly instead of writing them to a path first via a separate WriteFile call. A zip's
+// central directory lives at the end of the archive, so the upload is still buffered to a temp
+// file under os.CreateTemp before archive/zip can read it - the "streaming" part is that the
+// caller only ever makes one request instead of upload-then-extract, not that extraction begins
+// before the upload finishes.
+func (s *ApiService) UploadAndExtractZip(ctx context.Context, req oapi.UploadAndExtractZipRequestObject) (oapi.UploadAndExtractZipResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Params.Target == "" {
+		return oapi.UploadAndExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "target is required"}}, nil
+	}
+	if req.Body == nil {
+		return oapi.UploadAndExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "empty request body"}}, nil
+	}
+
+	opts, err := extractOptionsFromParams(req.Params.Target, req.Params.Overwrite, req.Params.Uid, req.Params.Gid, req.Params.Mode)
+	if err != nil {
+		return oapi.UploadAndExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "upload-and-extract-*.zip")
+	if err != nil {
+		log.Error("failed to create temp file", "err", err)
+		return oapi.UploadAndExtractZip500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to stage upload"}}, nil
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.ReadFrom(req.Body); err != nil {
+		log.Error("failed to stage zip upload", "err", err)
+		return oapi.UploadAndExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "failed to read upload"}}, nil
+	}
+
+	written, err := ziputil.Extract(tmp.Name(), opts)
+	if err != nil {
+		log.Error("failed to extract uploaded zip", "target", req.Params.Target, "err", err)
+		return oapi.UploadAndExtractZip400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("extracted uploaded zip", "target", req.Params.Target, "num_files", len(written))
+	return oapi.UploadAndExtractZip200JSONResponse{ExtractedFiles: written}, nil
+}
+
+// StreamZipEntry streams req.Params.Entry out of the zip at req.Params.Path without extracting
+// the rest of the archive, so a caller inspecting a snapshot (e.g. the e2e suite's
+// logZipContents helper) doesn't need to download or unpack the whole thing first.
+func (s *ApiService) StreamZipEntry(ctx context.Context, req oapi.StreamZipEntryRequestObject) (oapi.StreamZipEntryResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Params.Path == "" || req.Params.Entry == "" {
+		return oapi.StreamZipEntry400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path and entry are required"}}, nil
+	}
+
+	f, err := os.Open(req.Params.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return oapi.StreamZipEntry404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "zip not found"}}, nil
+		}
+		log.Error("failed to open zip", "path", req.Params.Path, "err", err)
+		return oapi.StreamZipEntry400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to open zip"}}, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		log.Error("failed to stat zip", "path", req.Params.Path, "err", err)
+		return oapi.StreamZipEntry400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unable to stat zip"}}, nil
+	}
+
+	rc, size, err := ziputil.StreamEntry(f, info.Size(), req.Params.Entry)
+	if err != nil {
+		f.Close()
+		return oapi.StreamZipEntry404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("streaming zip entry", "path", req.Params.Path, "entry", req.Params.Entry)
+	return oapi.StreamZipEntry200ApplicationoctetStreamResponse{Body: closeBoth{rc, f}, ContentLength: size}, nil
+}
+
+// closeBoth wraps an entry's ReadCloser so closing it also closes the underlying zip file handle
+// (archive/zip.File.Open's ReadCloser only closes the entry's own decompressor).
+type closeBoth struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (c closeBoth) Close() error {
+	err := c.ReadCloser.Close()
+	if fErr := c.file.Close(); err == nil {
+		err = fErr
+	}
+	return err
+}
+
+// extractOptionsFromParams builds a ziputil.ExtractOptions from the query params shared by
+// ExtractZip and UploadAndExtractZip.
+func extractOptionsFromParams(target string, overwrite *string, uid, gid *int, mode *string) (ziputil.ExtractOptions, error) {
+	opts := ziputil.ExtractOptions{TargetDir: target, UID: uid, GID: gid}
+
+	if overwrite != nil {
+		switch ziputil.OverwritePolicy(*overwrite) {
+		case ziputil.OverwriteReplace, ziputil.OverwriteSkip, ziputil.OverwriteError:
+			opts.Overwrite = ziputil.OverwritePolicy(*overwrite)
+		default:
+			return opts, fmt.Errorf("invalid overwrite policy %q", *overwrite)
+		}
+	}
+
+	if (uid == nil) != (gid == nil) {
+		return opts, fmt.Errorf("uid and gid must be set together")
+	}
+
+	if mode != nil {
+		v, err := strconv.ParseUint(*mode, 8, 32)
+		if err != nil {
+			return opts, fmt.Errorf("invalid mode %q", *mode)
+		}
+		m := os.FileMode(v)
+		opts.Mode = &m
+	}
+
+	return opts, nil
+}
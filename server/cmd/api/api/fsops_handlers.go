@@ -0,0 +1,339 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/fsops"
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// Stat returns req.Body.Path's metadata, following a symlink at that path rather than reporting
+// the link itself (see Lstat for the non-following form).
+func (s *ApiService) Stat(ctx context.Context, req oapi.StatRequestObject) (oapi.StatResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.Stat400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+	st, err := fsops.Stat(req.Body.Path)
+	if err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("stat failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.Stat403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 404:
+			return oapi.Stat404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Stat500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Stat200JSONResponse(statToOapi(st)), nil
+}
+
+// Lstat is Stat but reports the symlink itself at req.Body.Path rather than following it.
+func (s *ApiService) Lstat(ctx context.Context, req oapi.LstatRequestObject) (oapi.LstatResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.Lstat400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+	st, err := fsops.Lstat(req.Body.Path)
+	if err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("lstat failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.Lstat403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 404:
+			return oapi.Lstat404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Lstat500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Lstat200JSONResponse(statToOapi(st)), nil
+}
+
+// Chmod changes req.Body.Path's permission bits to req.Body.Mode, an octal string (e.g. "0755"),
+// the same representation SetFilePermissions (fs.go) uses for its mode field.
+func (s *ApiService) Chmod(ctx context.Context, req oapi.ChmodRequestObject) (oapi.ChmodResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.Chmod400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+	modeVal, err := strconv.ParseUint(req.Body.Mode, 8, 32)
+	if err != nil {
+		return oapi.Chmod400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "invalid mode"}}, nil
+	}
+	if err := fsops.Chmod(req.Body.Path, os.FileMode(modeVal)); err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("chmod failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.Chmod403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 404:
+			return oapi.Chmod404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Chmod500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Chmod200Response{}, nil
+}
+
+// Chown changes req.Body.Path's owning user/group, accepting either a numeric id or a name for
+// each of req.Body.Owner/req.Body.Group - the same owner/group resolution SetFilePermissions
+// (fs.go) uses. Unlike that handler's best-effort Chown, a failing or unresolvable Chown here is
+// reported back to the caller rather than silently ignored, since this endpoint's whole purpose
+// is precise syscall-level control.
+func (s *ApiService) Chown(ctx context.Context, req oapi.ChownRequestObject) (oapi.ChownResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.Chown400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+	uid, gid, err := resolveOwnerGroup(req.Body.Owner, req.Body.Group)
+	if err != nil {
+		return oapi.Chown400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	if err := fsops.Chown(req.Body.Path, uid, gid); err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("chown failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.Chown403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 404:
+			return oapi.Chown404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Chown500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Chown200Response{}, nil
+}
+
+// Mkdir creates req.Body.Path. req.Body.Mode defaults to "0755" if unset; req.Body.Recursive, if
+// true, also creates any missing parent directories (mkdir -p) instead of requiring they already
+// exist.
+func (s *ApiService) Mkdir(ctx context.Context, req oapi.MkdirRequestObject) (oapi.MkdirResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.Mkdir400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+	mode := "0755"
+	if req.Body.Mode != nil {
+		mode = *req.Body.Mode
+	}
+	modeVal, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return oapi.Mkdir400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "invalid mode"}}, nil
+	}
+	recursive := req.Body.Recursive != nil && *req.Body.Recursive
+	if err := fsops.Mkdir(req.Body.Path, os.FileMode(modeVal), recursive); err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("mkdir failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.Mkdir403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 409:
+			return oapi.Mkdir409JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Mkdir500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Mkdir201Response{}, nil
+}
+
+// Rename moves req.Body.SrcPath to req.Body.DestPath. Unlike MovePath (fs.go), which resolves
+// both paths through a VFS mount and refuses cross-backend moves up front, this operates directly
+// on local paths and simply surfaces the os.Rename/rename(2) result - including EXDEV if the two
+// paths turn out to be on different filesystems.
+func (s *ApiService) Rename(ctx context.Context, req oapi.RenameRequestObject) (oapi.RenameResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.SrcPath == "" || req.Body.DestPath == "" {
+		return oapi.Rename400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "src_path and dest_path are required"}}, nil
+	}
+	if err := fsops.Rename(req.Body.SrcPath, req.Body.DestPath); err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("rename failed", "src", req.Body.SrcPath, "dest", req.Body.DestPath, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 400:
+			return oapi.Rename400JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 403:
+			return oapi.Rename403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 404:
+			return oapi.Rename404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Rename500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Rename200Response{}, nil
+}
+
+// Remove deletes req.Body.Path. req.Body.Recursive, if true, removes a directory and everything
+// under it (rm -rf); otherwise a non-empty directory is refused with ENOTEMPTY.
+func (s *ApiService) Remove(ctx context.Context, req oapi.RemoveRequestObject) (oapi.RemoveResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.Remove400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+	recursive := req.Body.Recursive != nil && *req.Body.Recursive
+	if err := fsops.Remove(req.Body.Path, recursive); err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("remove failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 404:
+			return oapi.Remove404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 409:
+			return oapi.Remove409JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Remove500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Remove200Response{}, nil
+}
+
+// Symlink creates req.Body.Path as a symlink pointing at req.Body.Target.
+func (s *ApiService) Symlink(ctx context.Context, req oapi.SymlinkRequestObject) (oapi.SymlinkResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" || req.Body.Target == "" {
+		return oapi.Symlink400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path and target are required"}}, nil
+	}
+	if err := fsops.Symlink(req.Body.Target, req.Body.Path); err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("symlink failed", "path", req.Body.Path, "target", req.Body.Target, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.Symlink403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 409:
+			return oapi.Symlink409JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Symlink500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Symlink201Response{}, nil
+}
+
+// Readlink returns the target req.Body.Path points at.
+func (s *ApiService) Readlink(ctx context.Context, req oapi.ReadlinkRequestObject) (oapi.ReadlinkResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.Readlink400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+	target, err := fsops.Readlink(req.Body.Path)
+	if err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("readlink failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 404:
+			return oapi.Readlink404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.Readlink500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.Readlink200JSONResponse{Target: target}, nil
+}
+
+// SetUTimes updates req.Body.Path's access and modification times. Either of req.Body.AtimeNs/
+// req.Body.MtimeNs may be omitted, in which case that timestamp is left as whatever fsops.Lstat
+// currently reports rather than being zeroed out.
+func (s *ApiService) SetUTimes(ctx context.Context, req oapi.SetUTimesRequestObject) (oapi.SetUTimesResponseObject, error) {
+	log := logger.FromContext(ctx)
+	if req.Body == nil || req.Body.Path == "" {
+		return oapi.SetUTimes400JSONResponse{FsOpErrorJSONResponse: oapi.FsOpErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+
+	current, err := fsops.Lstat(req.Body.Path)
+	if err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("setutimes failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.SetUTimes403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 404:
+			return oapi.SetUTimes404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.SetUTimes500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+
+	atime := time.Unix(0, current.AtimeNs)
+	mtime := time.Unix(0, current.MtimeNs)
+	if req.Body.AtimeNs != nil {
+		atime = time.Unix(0, *req.Body.AtimeNs)
+	}
+	if req.Body.MtimeNs != nil {
+		mtime = time.Unix(0, *req.Body.MtimeNs)
+	}
+
+	if err := fsops.SetUTimes(req.Body.Path, atime, mtime); err != nil {
+		opErr := fsops.TranslateError(err)
+		log.Error("setutimes failed", "path", req.Body.Path, "errno", opErr.Errno, "err", err)
+		body := oapi.FsOpErrorJSONResponse{Message: opErr.Error(), Errno: string(opErr.Errno)}
+		switch opErr.HTTPStatus {
+		case 403:
+			return oapi.SetUTimes403JSONResponse{FsOpErrorJSONResponse: body}, nil
+		case 404:
+			return oapi.SetUTimes404JSONResponse{FsOpErrorJSONResponse: body}, nil
+		default:
+			return oapi.SetUTimes500JSONResponse{FsOpErrorJSONResponse: body}, nil
+		}
+	}
+	return oapi.SetUTimes200Response{}, nil
+}
+
+func statToOapi(st fsops.Stat) oapi.FileStat {
+	return oapi.FileStat{
+		Mode:      st.Mode.String(),
+		Size:      st.Size,
+		Uid:       st.Uid,
+		Gid:       st.Gid,
+		AtimeNs:   st.AtimeNs,
+		MtimeNs:   st.MtimeNs,
+		CtimeNs:   st.CtimeNs,
+		Inode:     st.Inode,
+		Nlink:     st.Nlink,
+		Dev:       st.Dev,
+		IsSymlink: st.IsSymlink,
+	}
+}
+
+// resolveOwnerGroup parses owner/group the same way SetFilePermissions (fs.go) does: each may be
+// a numeric id or a name to look up, and either may be omitted to leave that id unchanged (-1).
+func resolveOwnerGroup(owner, group *string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner != nil {
+		if id, err := strconv.Atoi(*owner); err == nil && id >= 0 {
+			uid = id
+		} else if u, err := user.Lookup(*owner); err == nil {
+			if id, err := strconv.Atoi(u.Uid); err == nil && id >= 0 {
+				uid = id
+			}
+		} else {
+			return 0, 0, fmt.Errorf("unknown owner %q", *owner)
+		}
+	}
+	if group != nil {
+		if id, err := strconv.Atoi(*group); err == nil && id >= 0 {
+			gid = id
+		} else if g, err := user.LookupGroup(*group); err == nil {
+			if id, err := strconv.Atoi(g.Gid); err == nil && id >= 0 {
+				gid = id
+			}
+		} else {
+			return 0, 0, fmt.Errorf("unknown group %q", *group)
+		}
+	}
+	return uid, gid, nil
+}
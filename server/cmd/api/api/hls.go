@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+var (
+	errNoSuchRecording = errors.New("no recording found")
+	errHLSNotEnabled   = errors.New("HLS playback not enabled for this recording")
+)
+
+// hlsCapable is implemented by recorders that can emit a live HLS ladder alongside their
+// primary recording (today, only FFmpegRecorder with HLS enabled). GetRecordingPlaylist and
+// GetRecordingSegment type-assert against it rather than widening the Recorder interface, so
+// recorder implementations without HLS support need no changes.
+type hlsCapable interface {
+	HLSPlaylistPath() (string, bool)
+	HLSSegmentPath(name string) (string, bool)
+}
+
+// GetRecordingPlaylist serves the live HLS master playlist for an in-progress (or just
+// finished) recording, so a client can attach a player instead of waiting for
+// DownloadRecording. It 404s if the recorder doesn't exist or wasn't started with HLS enabled.
+func (s *ApiService) GetRecordingPlaylist(ctx context.Context, req oapi.GetRecordingPlaylistRequestObject) (oapi.GetRecordingPlaylistResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	recorderID := s.defaultRecorderID
+	if req.Params.Id != nil && *req.Params.Id != "" {
+		recorderID = *req.Params.Id
+	}
+
+	path, err := s.hlsPlaylistPath(recorderID)
+	if err != nil {
+		return oapi.GetRecordingPlaylist404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	file, finfo, err := openForServing(path)
+	if err != nil {
+		log.Info("hls playlist not yet available", "recorder_id", recorderID, "err", err)
+		return oapi.GetRecordingPlaylist404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "playlist not yet available"}}, nil
+	}
+
+	return oapi.GetRecordingPlaylist200ApplicationvndapplempegurlResponse{Body: file, ContentLength: finfo.Size()}, nil
+}
+
+// GetRecordingSegment serves a single HLS segment referenced by the master playlist.
+func (s *ApiService) GetRecordingSegment(ctx context.Context, req oapi.GetRecordingSegmentRequestObject) (oapi.GetRecordingSegmentResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	recorderID := s.defaultRecorderID
+	if req.Params.Id != nil && *req.Params.Id != "" {
+		recorderID = *req.Params.Id
+	}
+
+	rec, exists := s.recordManager.GetRecorder(recorderID)
+	if !exists {
+		return oapi.GetRecordingSegment404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "no recording found"}}, nil
+	}
+	hc, ok := rec.(hlsCapable)
+	if !ok {
+		return oapi.GetRecordingSegment404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "HLS playback not enabled for this recording"}}, nil
+	}
+	path, ok := hc.HLSSegmentPath(req.Params.Segment)
+	if !ok {
+		return oapi.GetRecordingSegment404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "segment not found"}}, nil
+	}
+
+	file, finfo, err := openForServing(path)
+	if err != nil {
+		log.Info("hls segment not found", "recorder_id", recorderID, "segment", req.Params.Segment, "err", err)
+		return oapi.GetRecordingSegment404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "segment not found"}}, nil
+	}
+
+	return oapi.GetRecordingSegment200Videomp2tResponse{Body: file, ContentLength: finfo.Size()}, nil
+}
+
+// hlsPlaylistPath looks up recorderID and returns its master playlist path, or an error
+// describing why it isn't available (no such recorder, or HLS wasn't enabled for it).
+func (s *ApiService) hlsPlaylistPath(recorderID string) (string, error) {
+	rec, exists := s.recordManager.GetRecorder(recorderID)
+	if !exists {
+		return "", errNoSuchRecording
+	}
+	hc, ok := rec.(hlsCapable)
+	if !ok {
+		return "", errHLSNotEnabled
+	}
+	path, enabled := hc.HLSPlaylistPath()
+	if !enabled {
+		return "", errHLSNotEnabled
+	}
+	return path, nil
+}
+
+// openForServing opens path and stats it, closing the file if stat fails so the descriptor
+// isn't leaked, mirroring DownloadRecording's Recording() error handling.
+func openForServing(path string) (*os.File, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	finfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, finfo, nil
+}
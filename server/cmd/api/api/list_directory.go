@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/vfs"
+)
+
+// ListDirectory returns req.Params.Path's contents, optionally recursing depth levels deep
+// (0 = shallow, -1 = unlimited), filtered by a case-insensitive glob, sorted by name/size/mtime,
+// and paginated via limit/offset - unlike the simpler ListFiles, which always returns a whole
+// directory's immediate children with no filtering. A client negotiating "Accept: text/html"
+// gets a minimal HTML table instead of JSON, so this endpoint can be opened directly in a browser
+// while debugging a running instance.
+func (s *ApiService) ListDirectory(ctx context.Context, req oapi.ListDirectoryRequestObject) (oapi.ListDirectoryResponseObject, error) {
+	log := logger.FromContext(ctx)
+	path := req.Params.Path
+	if path == "" {
+		return oapi.ListDirectory400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "path cannot be empty"}}, nil
+	}
+
+	backend, relPath, err := s.resolveMount(path, req.Params.Mount)
+	if err != nil {
+		return oapi.ListDirectory400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	depth := 0
+	if req.Params.Depth != nil {
+		depth = *req.Params.Depth
+	}
+	entries, err := walkDirEntries(ctx, backend, relPath, "", depth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return oapi.ListDirectory404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "directory not found"}}, nil
+		}
+		log.Error("failed to list directory", "err", err, "path", path)
+		return oapi.ListDirectory500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to list directory"}}, nil
+	}
+
+	if req.Params.Glob != nil && *req.Params.Glob != "" {
+		entries = filterDirEntriesByGlob(entries, *req.Params.Glob)
+	}
+	sortDirEntries(entries, req.Params.Sort, req.Params.Order)
+
+	total := len(entries)
+	offset := 0
+	if req.Params.Offset != nil {
+		offset = *req.Params.Offset
+	}
+	if offset > total {
+		offset = total
+	}
+	entries = entries[offset:]
+	truncated := false
+	var nextOffset int
+	if req.Params.Limit != nil && *req.Params.Limit < len(entries) {
+		truncated = true
+		nextOffset = offset + *req.Params.Limit
+		entries = entries[:*req.Params.Limit]
+	}
+
+	listing := oapi.DirectoryListing{Entries: entries, Truncated: truncated}
+
+	var link *string
+	if truncated {
+		l := nextPageLink(req.Params, nextOffset)
+		link = &l
+	}
+
+	if wantsHTML(req.Params.Accept) {
+		return oapi.ListDirectory200TexthtmlResponse{
+			Body:    renderDirListingHTML(path, listing),
+			Headers: oapi.ListDirectory200ResponseHeaders{Link: link},
+		}, nil
+	}
+	return oapi.ListDirectory200JSONResponse{
+		Body:    listing,
+		Headers: oapi.ListDirectory200ResponseHeaders{Link: link},
+	}, nil
+}
+
+// walkDirEntries lists relPath's children, recursing up to depth additional levels (-1 for
+// unlimited, 0 for none). prefix is prepended to each entry's Name so nested results carry a
+// path relative to the original request, e.g. "sub/file.txt".
+func walkDirEntries(ctx context.Context, backend vfs.VFS, relPath, prefix string, depth int) ([]oapi.DirEntry, error) {
+	infos, err := backend.ReadDir(ctx, relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]oapi.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if prefix != "" {
+			name = filepath.Join(prefix, name)
+		}
+		entry := dirEntryFromInfo(backend, filepath.Join(relPath, info.Name()), name, info)
+		entries = append(entries, entry)
+
+		if info.IsDir() && depth != 0 {
+			childDepth := depth - 1
+			if depth < 0 {
+				childDepth = depth
+			}
+			children, err := walkDirEntries(ctx, backend, filepath.Join(relPath, info.Name()), name, childDepth)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, children...)
+		}
+	}
+	return entries, nil
+}
+
+// dirEntryFromInfo builds a DirEntry from a directory listing's os.FileInfo. SymlinkTarget is
+// only resolved for the local backend (vfs.LocalFS), since VFS has no generic Readlink - other
+// backends simply report IsSymlink with an empty target.
+func dirEntryFromInfo(backend vfs.VFS, fullRelPath, name string, info os.FileInfo) oapi.DirEntry {
+	isSymlink := info.Mode()&os.ModeSymlink != 0
+	entry := oapi.DirEntry{
+		Name:      name,
+		Size:      info.Size(),
+		Mode:      info.Mode().String(),
+		IsDir:     info.IsDir(),
+		IsSymlink: isSymlink,
+		Mtime:     info.ModTime(),
+	}
+	if isSymlink {
+		if _, ok := backend.(vfs.LocalFS); ok {
+			if target, err := os.Readlink(fullRelPath); err == nil {
+				entry.SymlinkTarget = target
+			}
+		}
+	}
+	if !info.IsDir() {
+		entry.MimeType = mimeTypeForName(name)
+	}
+	return entry
+}
+
+func mimeTypeForName(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func filterDirEntriesByGlob(entries []oapi.DirEntry, pattern string) []oapi.DirEntry {
+	pattern = strings.ToLower(pattern)
+	filtered := entries[:0]
+	for _, e := range entries {
+		if ok, _ := filepath.Match(pattern, strings.ToLower(filepath.Base(e.Name))); ok {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+func sortDirEntries(entries []oapi.DirEntry, sortBy, order *string) {
+	by := "name"
+	if sortBy != nil {
+		by = *sortBy
+	}
+	desc := order != nil && *order == "desc"
+
+	less := func(i, j int) bool {
+		switch by {
+		case "size":
+			return entries[i].Size < entries[j].Size
+		case "mtime":
+			return entries[i].Mtime.Before(entries[j].Mtime)
+		default:
+			return entries[i].Name < entries[j].Name
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
+
+func wantsHTML(accept *string) bool {
+	return accept != nil && strings.Contains(*accept, "text/html")
+}
+
+// nextPageLink builds the Link: rel="next" header's target for cursor-style pagination, by
+// replaying the current query parameters with offset advanced past the page just returned.
+func nextPageLink(params oapi.ListDirectoryParams, nextOffset int) string {
+	q := url.Values{}
+	q.Set("path", params.Path)
+	if params.Sort != nil {
+		q.Set("sort", *params.Sort)
+	}
+	if params.Order != nil {
+		q.Set("order", *params.Order)
+	}
+	if params.Limit != nil {
+		q.Set("limit", fmt.Sprintf("%d", *params.Limit))
+	}
+	if params.Glob != nil {
+		q.Set("glob", *params.Glob)
+	}
+	if params.Depth != nil {
+		q.Set("depth", fmt.Sprintf("%d", *params.Depth))
+	}
+	q.Set("offset", fmt.Sprintf("%d", nextOffset))
+	return fmt.Sprintf(`</fs/list?%s>; rel="next"`, q.Encode())
+}
+
+func renderDirListingHTML(path string, listing oapi.DirectoryListing) *bytes.Reader {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<!doctype html><html><head><title>%s</title></head><body>\n", html.EscapeString(path))
+	fmt.Fprintf(&buf, "<h1>%s</h1>\n<table><tr><th>Name</th><th>Size</th><th>Mode</th><th>Modified</th></tr>\n", html.EscapeString(path))
+	for _, e := range listing.Entries {
+		name := e.Name
+		if e.IsDir {
+			name += "/"
+		}
+		fmt.Fprintf(&buf, "<tr><td>%s</td><td>%d</td><td>%s</td><td>%s</td></tr>\n",
+			html.EscapeString(name), e.Size, html.EscapeString(e.Mode), e.Mtime.Format(time.RFC3339))
+	}
+	buf.WriteString("</table>\n")
+	if listing.Truncated {
+		buf.WriteString("<p><em>results truncated</em></p>\n")
+	}
+	buf.WriteString("</body></html>\n")
+	return bytes.NewReader(buf.Bytes())
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// TestListDirectorySortLimitGlob verifies sort/order, limit+truncated, and glob filtering against
+// a flat directory of files.
+func TestListDirectorySortLimitGlob(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := &ApiService{defaultRecorderID: "default"}
+
+	tmpDir := t.TempDir()
+	names := []string{"b.txt", "a.log", "c.txt"}
+	for i, name := range names {
+		content := make([]byte, (i+1)*10)
+		if err := os.WriteFile(filepath.Join(tmpDir, name), content, 0o644); err != nil {
+			t.Fatalf("failed to seed file %s: %v", name, err)
+		}
+	}
+
+	// Sorted by name ascending.
+	resp, err := svc.ListDirectory(ctx, oapi.ListDirectoryRequestObject{Params: oapi.ListDirectoryParams{Path: tmpDir}})
+	if err != nil {
+		t.Fatalf("ListDirectory returned error: %v", err)
+	}
+	r200, ok := resp.(oapi.ListDirectory200JSONResponse)
+	if !ok {
+		t.Fatalf("unexpected response type: %T", resp)
+	}
+	if got, want := dirEntryNames(r200.Body.Entries), []string{"a.log", "b.txt", "c.txt"}; !equalStringSlices(got, want) {
+		t.Fatalf("name-sorted listing mismatch: got %v want %v", got, want)
+	}
+
+	// Glob restricted to *.txt, case-insensitive.
+	glob := "*.TXT"
+	resp, err = svc.ListDirectory(ctx, oapi.ListDirectoryRequestObject{Params: oapi.ListDirectoryParams{Path: tmpDir, Glob: &glob}})
+	if err != nil {
+		t.Fatalf("ListDirectory (glob) returned error: %v", err)
+	}
+	r200 = resp.(oapi.ListDirectory200JSONResponse)
+	if got, want := dirEntryNames(r200.Body.Entries), []string{"b.txt", "c.txt"}; !equalStringSlices(got, want) {
+		t.Fatalf("glob-filtered listing mismatch: got %v want %v", got, want)
+	}
+
+	// Sort by size descending, limited to 2 entries, which should report truncated and a Link header.
+	sortBy, order, limit := "size", "desc", 2
+	resp, err = svc.ListDirectory(ctx, oapi.ListDirectoryRequestObject{Params: oapi.ListDirectoryParams{Path: tmpDir, Sort: &sortBy, Order: &order, Limit: &limit}})
+	if err != nil {
+		t.Fatalf("ListDirectory (sort/limit) returned error: %v", err)
+	}
+	r200 = resp.(oapi.ListDirectory200JSONResponse)
+	if !r200.Body.Truncated {
+		t.Fatalf("expected truncated=true when limit cuts results")
+	}
+	if got, want := dirEntryNames(r200.Body.Entries), []string{"c.txt", "a.log"}; !equalStringSlices(got, want) {
+		t.Fatalf("sorted/limited listing mismatch: got %v want %v", got, want)
+	}
+	if r200.Headers.Link == nil || *r200.Headers.Link == "" {
+		t.Fatalf("expected a Link header when results are truncated")
+	}
+}
+
+// TestListDirectoryRecursiveSymlink verifies that depth=-1 recurses into subdirectories and that
+// a symlink is reported with is_symlink/symlink_target rather than followed.
+func TestListDirectoryRecursiveSymlink(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := &ApiService{defaultRecorderID: "default"}
+
+	tmpDir := t.TempDir()
+	subDir := filepath.Join(tmpDir, "sub")
+	if err := os.Mkdir(subDir, 0o755); err != nil {
+		t.Fatalf("failed to create subdir: %v", err)
+	}
+	nestedFile := filepath.Join(subDir, "nested.txt")
+	if err := os.WriteFile(nestedFile, []byte("nested"), 0o644); err != nil {
+		t.Fatalf("failed to seed nested file: %v", err)
+	}
+	linkPath := filepath.Join(tmpDir, "link.txt")
+	if err := os.Symlink(nestedFile, linkPath); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	depth := -1
+	resp, err := svc.ListDirectory(ctx, oapi.ListDirectoryRequestObject{Params: oapi.ListDirectoryParams{Path: tmpDir, Depth: &depth}})
+	if err != nil {
+		t.Fatalf("ListDirectory returned error: %v", err)
+	}
+	r200, ok := resp.(oapi.ListDirectory200JSONResponse)
+	if !ok {
+		t.Fatalf("unexpected response type: %T", resp)
+	}
+
+	var foundNested, foundLink bool
+	for _, e := range r200.Body.Entries {
+		if e.Name == filepath.Join("sub", "nested.txt") {
+			foundNested = true
+		}
+		if e.Name == "link.txt" {
+			foundLink = true
+			if !e.IsSymlink {
+				t.Fatalf("expected link.txt to be reported as a symlink")
+			}
+			if e.SymlinkTarget != nestedFile {
+				t.Fatalf("unexpected symlink target: got %q want %q", e.SymlinkTarget, nestedFile)
+			}
+		}
+	}
+	if !foundNested {
+		t.Fatalf("expected recursive listing to include sub/nested.txt")
+	}
+	if !foundLink {
+		t.Fatalf("expected recursive listing to include link.txt")
+	}
+}
+
+func dirEntryNames(entries []oapi.DirEntry) []string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return names
+}
@@ -1,21 +1,54 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os/exec"
+	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
 	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
 )
 
+// playwrightExecutorPath is the script ExecutePlaywrightCode and its streaming counterpart run
+// req.Code against, baked into the container image alongside the tsx runtime it's invoked with.
+const playwrightExecutorPath = "/usr/local/lib/playwright-executor.ts"
+
+// playwrightDefaultTimeout bounds how long a run is allowed before it's treated the same as an
+// explicit cancel, for a caller that doesn't set TimeoutSec.
+const playwrightDefaultTimeout = 30 * time.Second
+
+// playwrightHeartbeatInterval is how often HandleExecutePlaywrightCodeStream emits a heartbeat
+// event while the executor is otherwise silent, so a client watching the stream can tell "still
+// running" apart from "connection stalled" without guessing how long a script might legitimately
+// take.
+const playwrightHeartbeatInterval = 10 * time.Second
+
+// ExecutePlaywrightRequest is the JSON body both ExecutePlaywrightCode and
+// HandleExecutePlaywrightCodeStream accept. It mirrors oapi.ExecutePlaywrightCodeRequest's shape
+// so the streaming handler - which, being a raw http.HandlerFunc rather than a strict-server
+// operation, doesn't get a pre-decoded oapi request type - can still reuse the same field names.
 type ExecutePlaywrightRequest struct {
 	Code       string `json:"code"`
 	TimeoutSec *int   `json:"timeout_sec,omitempty"`
+
+	// SessionID, if set, dispatches Code to the worker behind that ID (see
+	// playwright_session.go) instead of spawning a fresh executor process, so the call reuses
+	// that worker's Playwright BrowserContext - and its cookies, storage state, and open pages -
+	// across requests.
+	SessionID string `json:"session_id,omitempty"`
 }
 
+// ExecutePlaywrightResult is the JSON the executor script prints on its last line of output, and
+// the shape both ExecutePlaywrightCode's JSON response and each streamed "result" event carry.
 type ExecutePlaywrightResult struct {
 	Success bool        `json:"success"`
 	Result  interface{} `json:"result,omitempty"`
@@ -24,75 +57,348 @@ type ExecutePlaywrightResult struct {
 	Stderr  string      `json:"stderr,omitempty"`
 }
 
-func (s *Service) ExecutePlaywrightCode(w http.ResponseWriter, r *http.Request) {
-	log := logger.FromContext(r.Context())
+// playwrightExecution tracks one in-flight run so CancelPlaywrightExecution can abort it without
+// the client dropping the HTTP connection the original request came in on. Closing cancel is what
+// actually unblocks the run - a shared channel closed exactly once, mirroring the cancel-channel
+// netstack's gonet pairs with a deadline timer - rather than relying on context cancellation
+// alone, so an explicit cancel request and TimeoutSec firing share one code path.
+type playwrightExecution struct {
+	cancel    chan struct{}
+	closeOnce sync.Once
+	timer     *time.Timer
+	timedOut  bool
+}
 
-	var req ExecutePlaywrightRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
-		return
+func newPlaywrightExecution(timeout time.Duration) *playwrightExecution {
+	e := &playwrightExecution{cancel: make(chan struct{})}
+	e.timer = time.AfterFunc(timeout, func() {
+		e.timedOut = true
+		e.Cancel()
+	})
+	return e
+}
+
+// Cancel closes e's cancel channel, unblocking anything selecting on it. Safe to call more than
+// once; only the first call has any effect.
+func (e *playwrightExecution) Cancel() {
+	e.closeOnce.Do(func() { close(e.cancel) })
+}
+
+// stop releases e's timeout timer once the run it tracks has finished, so a long TimeoutSec
+// doesn't keep the timer (and e) alive after there's nothing left to cancel.
+func (e *playwrightExecution) stop() {
+	e.timer.Stop()
+}
+
+// playwrightExecutionRegistry tracks every in-flight ExecutePlaywrightCode/
+// HandleExecutePlaywrightCodeStream run by the execution ID CancelPlaywrightExecution targets,
+// mirroring reclaimEventHub's mutex-plus-map shape for a similarly short-lived, per-request set
+// of entries.
+type playwrightExecutionRegistry struct {
+	mu   sync.Mutex
+	runs map[string]*playwrightExecution
+}
+
+func newPlaywrightExecutionRegistry() *playwrightExecutionRegistry {
+	return &playwrightExecutionRegistry{runs: make(map[string]*playwrightExecution)}
+}
+
+// register creates and records a playwrightExecution for id, returning it. The caller must call
+// unregister(id) once the run finishes, whether it succeeded, errored, or was canceled.
+func (reg *playwrightExecutionRegistry) register(id string, timeout time.Duration) *playwrightExecution {
+	exec := newPlaywrightExecution(timeout)
+	reg.mu.Lock()
+	reg.runs[id] = exec
+	reg.mu.Unlock()
+	return exec
+}
+
+func (reg *playwrightExecutionRegistry) unregister(id string) {
+	reg.mu.Lock()
+	exec, ok := reg.runs[id]
+	delete(reg.runs, id)
+	reg.mu.Unlock()
+	if ok {
+		exec.stop()
 	}
+}
 
-	if req.Code == "" {
-		http.Error(w, "code is required", http.StatusBadRequest)
+func (reg *playwrightExecutionRegistry) cancel(id string) bool {
+	reg.mu.Lock()
+	exec, ok := reg.runs[id]
+	reg.mu.Unlock()
+	if !ok {
+		return false
+	}
+	exec.Cancel()
+	return true
+}
+
+// CancelPlaywrightExecution handles POST /playwright/executions/{id}/cancel: it signals the named
+// execution's cancel channel, which both ExecutePlaywrightCode and
+// HandleExecutePlaywrightCodeStream select on alongside the executor's context, so a stuck script
+// can be aborted without dropping the HTTP connection the original request came in on.
+func (s *ApiService) CancelPlaywrightExecution(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !s.playwrightExecutions.cancel(id) {
+		http.Error(w, "execution not found", http.StatusNotFound)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	timeout := 30 * time.Second
-	if req.TimeoutSec != nil && *req.TimeoutSec > 0 {
-		timeout = time.Duration(*req.TimeoutSec) * time.Second
+// runPlaywrightExecutor registers a freshly generated execution ID with s.playwrightExecutions so
+// CancelPlaywrightExecution can reach it, and returns a context derived from ctx that's canceled
+// either by an explicit cancel request or by timeout firing first, whichever happens first. The
+// caller must call the returned cleanup func once the run has finished.
+func (s *ApiService) runPlaywrightExecutor(ctx context.Context, timeout time.Duration) (runCtx context.Context, id string, cleanup func(), timedOut func() bool) {
+	id = uuid.New().String()
+	execHandle := s.playwrightExecutions.register(id, timeout)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-execHandle.cancel:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	cleanup = func() {
+		close(done)
+		cancel()
+		s.playwrightExecutions.unregister(id)
 	}
+	timedOut = func() bool { return execHandle.timedOut }
+	return runCtx, id, cleanup, timedOut
+}
+
+// ExecutePlaywrightCode runs req.Body.Code against the playwright executor script and returns its
+// reported result as a single JSON response. A client that wants incremental progress instead -
+// e.g. for a long script that would otherwise look hung until it completes - should use
+// HandleExecutePlaywrightCodeStream by sending Accept: application/x-ndjson against the same
+// route.
+func (s *ApiService) ExecutePlaywrightCode(ctx context.Context, req oapi.ExecutePlaywrightCodeRequestObject) (oapi.ExecutePlaywrightCodeResponseObject, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(r.Context(), timeout)
-	defer cancel()
+	if req.Body == nil || req.Body.Code == "" {
+		return oapi.ExecutePlaywrightCode400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "code is required"}}, nil
+	}
+
+	if req.Body.SessionID != nil && *req.Body.SessionID != "" {
+		sess, ok := s.playwrightSessions.get(*req.Body.SessionID)
+		if !ok {
+			return oapi.ExecutePlaywrightCode400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "unknown session id"}}, nil
+		}
+		result, err := sess.execute(req.Body.Code)
+		if err != nil {
+			log.Error("playwright session execution failed", "error", err, "session_id", *req.Body.SessionID)
+			return oapi.ExecutePlaywrightCode500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: fmt.Sprintf("execution failed: %v", err)}}, nil
+		}
+		return oapi.ExecutePlaywrightCode200JSONResponse(result), nil
+	}
+
+	timeout := playwrightDefaultTimeout
+	if req.Body.TimeoutSec != nil && *req.Body.TimeoutSec > 0 {
+		timeout = time.Duration(*req.Body.TimeoutSec) * time.Second
+	}
 
-	cmd := exec.CommandContext(ctx, "tsx", "/usr/local/lib/playwright-executor.ts", req.Code)
+	runCtx, _, cleanup, timedOut := s.runPlaywrightExecutor(ctx, timeout)
+	defer cleanup()
 
+	cmd := exec.CommandContext(runCtx, "tsx", playwrightExecutorPath, req.Body.Code)
 	output, err := cmd.CombinedOutput()
 
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Error("playwright execution timed out", "timeout", timeout)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(ExecutePlaywrightResult{
-				Success: false,
-				Error:   fmt.Sprintf("execution timed out after %v", timeout),
-			})
-			return
+	if runCtx.Err() != nil {
+		result := ExecutePlaywrightResult{Success: false, Error: "execution canceled by client"}
+		if timedOut() {
+			result.Error = fmt.Sprintf("execution timed out after %v", timeout)
 		}
+		return oapi.ExecutePlaywrightCode200JSONResponse(result), nil
+	}
 
-		log.Error("playwright execution failed", "error", err, "output", string(output))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
+	if err != nil {
 		var result ExecutePlaywrightResult
 		if jsonErr := json.Unmarshal(output, &result); jsonErr == nil {
-			json.NewEncoder(w).Encode(result)
-		} else {
-			json.NewEncoder(w).Encode(ExecutePlaywrightResult{
-				Success: false,
-				Error:   fmt.Sprintf("execution failed: %v", err),
-				Stderr:  string(output),
-			})
+			return oapi.ExecutePlaywrightCode200JSONResponse(result), nil
 		}
-		return
+		log.Error("playwright execution failed", "error", err, "output", string(output))
+		return oapi.ExecutePlaywrightCode500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: fmt.Sprintf("execution failed: %v", err)}}, nil
 	}
 
 	var result ExecutePlaywrightResult
 	if err := json.Unmarshal(output, &result); err != nil {
 		log.Error("failed to parse playwright output", "error", err, "output", string(output))
-		w.Header().Set("Content-Type", "application/json")
+		return oapi.ExecutePlaywrightCode500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: fmt.Sprintf("failed to parse output: %v", err)}}, nil
+	}
+
+	return oapi.ExecutePlaywrightCode200JSONResponse(result), nil
+}
+
+// playwrightStreamEvent is one line of the NDJSON stream HandleExecutePlaywrightCodeStream emits:
+// "log" for each stdout line the executor prints as it runs, "heartbeat" on an idle tick so a
+// client watching for liveness doesn't have to guess how long is too long, and exactly one
+// terminal "result" or "error" event once the executor exits.
+type playwrightStreamEvent struct {
+	Type      string      `json:"type"`
+	Line      string      `json:"line,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Execution string      `json:"execution_id,omitempty"`
+}
+
+// HandleExecutePlaywrightCodeStream serves the NDJSON streaming mode of ExecutePlaywrightCode,
+// selected via Accept: application/x-ndjson against the same route - mirroring
+// HandleUploadExtensionsSSE's content-negotiation precedent for an operation that doesn't fit the
+// strict handler's terminal-JSON-response model. It pipes the executor's stdout line by line,
+// wrapping each as a {"type":"log"} event, and reports exactly one terminal {"type":"result"} or
+// {"type":"error"} event once the process exits, flushing after every event so a client sees
+// progress as it happens instead of only at the end.
+func (s *ApiService) HandleExecutePlaywrightCodeStream(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	done, ok := s.checkExecRateLimit(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+
+	var req ExecutePlaywrightRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	if req.SessionID != "" {
+		sess, ok := s.playwrightSessions.get(req.SessionID)
+		if !ok {
+			http.Error(w, "unknown session id", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(ExecutePlaywrightResult{
-			Success: false,
-			Error:   fmt.Sprintf("failed to parse output: %v", err),
-			Stdout:  string(output),
-		})
+		encode := func(ev playwrightStreamEvent) {
+			if err := json.NewEncoder(w).Encode(ev); err != nil {
+				log.Error("failed to encode playwright stream event", "error", err)
+				return
+			}
+			flusher.Flush()
+		}
+
+		result, err := sess.execute(req.Code)
+		if err != nil {
+			log.Error("playwright session execution failed", "error", err, "session_id", req.SessionID)
+			encode(playwrightStreamEvent{Type: "error", Error: fmt.Sprintf("execution failed: %v", err)})
+			return
+		}
+		encode(playwrightStreamEvent{Type: "result", Result: result.Result, Error: result.Error})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	timeout := playwrightDefaultTimeout
+	if req.TimeoutSec != nil && *req.TimeoutSec > 0 {
+		timeout = time.Duration(*req.TimeoutSec) * time.Second
+	}
+
+	runCtx, id, cleanup, timedOut := s.runPlaywrightExecutor(r.Context(), timeout)
+	defer cleanup()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Execution-Id", id)
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(result)
+
+	encode := func(ev playwrightStreamEvent) {
+		ev.Execution = id
+		if err := json.NewEncoder(w).Encode(ev); err != nil {
+			log.Error("failed to encode playwright stream event", "error", err)
+			return
+		}
+		flusher.Flush()
+	}
+
+	var stderrBuf bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "tsx", playwrightExecutorPath, req.Code)
+	cmd.Stderr = &stderrBuf
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		encode(playwrightStreamEvent{Type: "error", Error: fmt.Sprintf("failed to open executor stdout: %v", err)})
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		encode(playwrightStreamEvent{Type: "error", Error: fmt.Sprintf("failed to start executor: %v", err)})
+		return
+	}
+
+	lines := make(chan string)
+	scanDone := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		scanDone <- scanner.Err()
+		close(lines)
+	}()
+
+	heartbeat := time.NewTicker(playwrightHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var lastLine string
+readLoop:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				break readLoop
+			}
+			lastLine = line
+			encode(playwrightStreamEvent{Type: "log", Line: line})
+			heartbeat.Reset(playwrightHeartbeatInterval)
+		case <-heartbeat.C:
+			encode(playwrightStreamEvent{Type: "heartbeat"})
+		}
+	}
+
+	scanErr := <-scanDone
+	waitErr := cmd.Wait()
+
+	switch {
+	case runCtx.Err() != nil:
+		reason := "execution canceled by client"
+		if timedOut() {
+			reason = fmt.Sprintf("execution timed out after %v", timeout)
+		}
+		encode(playwrightStreamEvent{Type: "error", Error: reason})
+	case waitErr != nil:
+		var result ExecutePlaywrightResult
+		if jsonErr := json.Unmarshal([]byte(lastLine), &result); jsonErr == nil {
+			encode(playwrightStreamEvent{Type: "result", Result: result.Result, Error: result.Error})
+			return
+		}
+		log.Error("playwright streaming execution failed", "error", waitErr, "stderr", stderrBuf.String())
+		encode(playwrightStreamEvent{Type: "error", Error: fmt.Sprintf("execution failed: %v", waitErr)})
+	case scanErr != nil:
+		encode(playwrightStreamEvent{Type: "error", Error: fmt.Sprintf("failed to read executor output: %v", scanErr)})
+	default:
+		var result ExecutePlaywrightResult
+		if jsonErr := json.Unmarshal([]byte(lastLine), &result); jsonErr != nil {
+			encode(playwrightStreamEvent{Type: "error", Error: fmt.Sprintf("failed to parse executor output: %v", jsonErr)})
+			return
+		}
+		encode(playwrightStreamEvent{Type: "result", Result: result.Result, Error: result.Error})
+	}
 }
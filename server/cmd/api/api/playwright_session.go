@@ -0,0 +1,292 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+)
+
+// playwrightSessionIdleTimeout is how long a session worker is kept alive without a request
+// before playwrightSessionRegistry evicts it, bounding how many idle Playwright BrowserContexts -
+// and the Chromium processes behind them - can accumulate from clients that create a session and
+// never tear it down.
+const playwrightSessionIdleTimeout = 10 * time.Minute
+
+// playwrightSessionShutdownGrace is how long playwrightSession.close waits for the worker to exit
+// on its own after SIGTERM before it's killed outright.
+const playwrightSessionShutdownGrace = 5 * time.Second
+
+// playwrightRPCRequest/playwrightRPCResponse are the line-delimited JSON messages a session
+// worker speaks over stdin/stdout: one response line out per request line in, keyed by ID so a
+// response can't be misattributed if the worker ever has more than one line buffered.
+type playwrightRPCRequest struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+}
+
+type playwrightRPCResponse struct {
+	ID string `json:"id"`
+	ExecutePlaywrightResult
+}
+
+// playwrightSession owns one long-lived `tsx .../playwright-executor.ts --worker` process
+// speaking line-delimited JSON over stdin/stdout, so repeated ExecutePlaywrightCode calls against
+// the same SessionID reuse a single Playwright BrowserContext instead of paying cold browser
+// startup on every request and losing cookies, storage state, and open pages between calls.
+type playwrightSession struct {
+	id  string
+	cmd *exec.Cmd
+
+	mu     sync.Mutex // serializes requests against this session's single worker process
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+
+	createdAt time.Time
+	lastUsed  atomicTime
+
+	idleTimer *time.Timer
+
+	closeOnce sync.Once
+	done      chan struct{} // closed once the worker process has exited
+}
+
+// atomicTime is a tiny sync.Mutex-backed box for a time.Time read/written from both the request
+// path (execute) and the listing path (playwrightSessionRegistry.list), without pulling in
+// atomic.Value's interface{}-assertion boilerplate for a single concrete type.
+type atomicTime struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (a *atomicTime) Store(t time.Time) {
+	a.mu.Lock()
+	a.t = t
+	a.mu.Unlock()
+}
+
+func (a *atomicTime) Load() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.t
+}
+
+func newPlaywrightSession(id string) (*playwrightSession, error) {
+	cmd := exec.Command("tsx", playwrightExecutorPath, "--worker")
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worker stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worker stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start worker: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	sess := &playwrightSession{
+		id:        id,
+		cmd:       cmd,
+		stdin:     stdin,
+		stdout:    scanner,
+		createdAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	sess.lastUsed.Store(sess.createdAt)
+
+	go func() {
+		_ = cmd.Wait()
+		close(sess.done)
+	}()
+
+	return sess, nil
+}
+
+// execute sends code to sess's worker and waits for its response, serialized against any other
+// execute call on the same session since the worker only ever processes one request at a time.
+func (sess *playwrightSession) execute(code string) (ExecutePlaywrightResult, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	select {
+	case <-sess.done:
+		return ExecutePlaywrightResult{}, fmt.Errorf("session worker has exited")
+	default:
+	}
+
+	sess.lastUsed.Store(time.Now())
+	sess.idleTimer.Reset(playwrightSessionIdleTimeout)
+
+	reqID := uuid.New().String()
+	line, err := json.Marshal(playwrightRPCRequest{ID: reqID, Code: code})
+	if err != nil {
+		return ExecutePlaywrightResult{}, fmt.Errorf("failed to encode worker request: %w", err)
+	}
+	if _, err := sess.stdin.Write(append(line, '\n')); err != nil {
+		return ExecutePlaywrightResult{}, fmt.Errorf("failed to write to worker stdin: %w", err)
+	}
+
+	for sess.stdout.Scan() {
+		var resp playwrightRPCResponse
+		if err := json.Unmarshal(sess.stdout.Bytes(), &resp); err != nil {
+			return ExecutePlaywrightResult{}, fmt.Errorf("failed to decode worker response: %w", err)
+		}
+		if resp.ID != reqID {
+			// A response to a request this session no longer cares about (e.g. a prior call
+			// whose HTTP request already gave up); keep reading for ours.
+			continue
+		}
+		return resp.ExecutePlaywrightResult, nil
+	}
+	if err := sess.stdout.Err(); err != nil {
+		return ExecutePlaywrightResult{}, fmt.Errorf("worker stdout closed: %w", err)
+	}
+	return ExecutePlaywrightResult{}, fmt.Errorf("worker exited before responding")
+}
+
+// close tears down sess's worker: closing stdin first so a well-behaved worker can exit on its
+// own, then SIGTERM, then a SIGKILL if it hasn't exited within playwrightSessionShutdownGrace.
+func (sess *playwrightSession) close() {
+	sess.closeOnce.Do(func() {
+		sess.idleTimer.Stop()
+		_ = sess.stdin.Close()
+		if sess.cmd.Process != nil {
+			_ = sess.cmd.Process.Signal(syscall.SIGTERM)
+		}
+		select {
+		case <-sess.done:
+		case <-time.After(playwrightSessionShutdownGrace):
+			if sess.cmd.Process != nil {
+				_ = sess.cmd.Process.Kill()
+			}
+		}
+	})
+}
+
+// playwrightSessionInfo is what HandleCreatePlaywrightSession and HandleListPlaywrightSessions
+// report for a session.
+type playwrightSessionInfo struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// playwrightSessionRegistry tracks every live playwrightSession by ID, backing the
+// POST/DELETE/GET /playwright/sessions endpoints below.
+type playwrightSessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*playwrightSession
+}
+
+func newPlaywrightSessionRegistry() *playwrightSessionRegistry {
+	return &playwrightSessionRegistry{sessions: make(map[string]*playwrightSession)}
+}
+
+// create starts a new session and registers it under a freshly generated ID, arming its idle
+// timer to evict it after playwrightSessionIdleTimeout of inactivity.
+func (reg *playwrightSessionRegistry) create() (*playwrightSession, error) {
+	id := uuid.New().String()
+	sess, err := newPlaywrightSession(id)
+	if err != nil {
+		return nil, err
+	}
+	sess.idleTimer = time.AfterFunc(playwrightSessionIdleTimeout, func() {
+		reg.remove(id)
+	})
+
+	reg.mu.Lock()
+	reg.sessions[id] = sess
+	reg.mu.Unlock()
+	return sess, nil
+}
+
+func (reg *playwrightSessionRegistry) get(id string) (*playwrightSession, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	sess, ok := reg.sessions[id]
+	return sess, ok
+}
+
+// remove unregisters and tears down the session named by id, if any. Safe to call more than once
+// or for an id that no longer exists (e.g. the idle timer firing after an explicit delete already
+// raced it out of the map).
+func (reg *playwrightSessionRegistry) remove(id string) {
+	reg.mu.Lock()
+	sess, ok := reg.sessions[id]
+	delete(reg.sessions, id)
+	reg.mu.Unlock()
+	if ok {
+		sess.close()
+	}
+}
+
+func (reg *playwrightSessionRegistry) list() []playwrightSessionInfo {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	infos := make([]playwrightSessionInfo, 0, len(reg.sessions))
+	for _, sess := range reg.sessions {
+		infos = append(infos, playwrightSessionInfo{
+			ID:        sess.id,
+			CreatedAt: sess.createdAt,
+			LastUsed:  sess.lastUsed.Load(),
+		})
+	}
+	return infos
+}
+
+// HandleCreatePlaywrightSession handles POST /playwright/sessions: it starts a new long-lived
+// worker owning its own Playwright BrowserContext, so subsequent ExecutePlaywrightCode calls that
+// set SessionID to the returned ID reuse it instead of paying cold browser startup and losing
+// cookies/storage state/open pages between calls.
+func (s *ApiService) HandleCreatePlaywrightSession(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	sess, err := s.playwrightSessions.create()
+	if err != nil {
+		log.Error("failed to create playwright session", "error", err)
+		http.Error(w, fmt.Sprintf("failed to create session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(playwrightSessionInfo{ID: sess.id, CreatedAt: sess.createdAt, LastUsed: sess.createdAt})
+}
+
+// HandleDeletePlaywrightSession handles DELETE /playwright/sessions/{id}, tearing down that
+// session's worker process (and the BrowserContext it owns) immediately rather than waiting for
+// playwrightSessionIdleTimeout.
+func (s *ApiService) HandleDeletePlaywrightSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := s.playwrightSessions.get(id); !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	s.playwrightSessions.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListPlaywrightSessions handles GET /playwright/sessions, listing every currently live
+// session for observability.
+func (s *ApiService) HandleListPlaywrightSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.playwrightSessions.list())
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/profilesnapshot"
+	"github.com/onkernel/kernel-images/server/lib/storagepartition"
+)
+
+// profileRestoreReadyTimeout bounds how long RestoreProfileSnapshot waits for Chromium to come
+// back up on a new DevTools websocket after the restart it triggers post-restore.
+const profileRestoreReadyTimeout = 30 * time.Second
+
+// CreateProfileSnapshot archives the entire running Chromium profile (unlike
+// ExportStorageForOrigins, which scopes to a set of origins) as a single encrypted, integrity-
+// checked tarball: see server/lib/profilesnapshot for the XChaCha20-Poly1305 sealing and signed
+// manifest this produces. req.Body.EncryptionKey is a base64-encoded 32-byte key the caller
+// supplies and must retain - this server never stores it.
+func (s *ApiService) CreateProfileSnapshot(ctx context.Context, req oapi.CreateProfileSnapshotRequestObject) (oapi.CreateProfileSnapshotResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if s.profileSnapshotSigner == nil {
+		return oapi.CreateProfileSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "profile snapshotting is not configured (PROFILE_SNAPSHOT_SIGNING_KEY not set)"}}, nil
+	}
+	if req.Body == nil || req.Body.EncryptionKey == "" {
+		return oapi.CreateProfileSnapshot400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "encryption_key is required"}}, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(req.Body.EncryptionKey)
+	if err != nil {
+		return oapi.CreateProfileSnapshot400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "encryption_key must be base64-encoded"}}, nil
+	}
+
+	opts := profilesnapshot.SnapshotOptions{
+		EncryptionKey:  key,
+		ManifestSigner: s.profileSnapshotSigner,
+	}
+	if req.Body.IncludeCaches != nil {
+		opts.IncludeCaches = *req.Body.IncludeCaches
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := profilesnapshot.Create(userDataDir, opts, pw)
+		pw.CloseWithError(err)
+	}()
+
+	log.Info("creating profile snapshot", "include_caches", opts.IncludeCaches)
+	return oapi.CreateProfileSnapshot200ApplicationoctetStreamResponse{Body: pr}, nil
+}
+
+// RestoreProfileSnapshot verifies and extracts a snapshot produced by CreateProfileSnapshot into
+// the running profile in place, then clears Chromium's singleton lock files and coordinates a
+// restart so the new profile contents take effect immediately rather than requiring a separate
+// manual supervisorctl/readiness dance (see server/e2e/e2e_persist_login_test.go's
+// restartChromium, which this replaces for callers of this endpoint). A build ID mismatch between
+// the snapshot's manifest and the currently running Chromium is refused unless req.Params.Force
+// is set, since IndexedDB's LevelDB format and the Cookies SQLite schema both break across major
+// Chromium versions.
+func (s *ApiService) RestoreProfileSnapshot(ctx context.Context, req oapi.RestoreProfileSnapshotRequestObject) (oapi.RestoreProfileSnapshotResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if s.profileSnapshotSigner == nil {
+		return oapi.RestoreProfileSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "profile snapshotting is not configured (PROFILE_SNAPSHOT_SIGNING_KEY not set)"}}, nil
+	}
+	if req.Params.EncryptionKey == "" {
+		return oapi.RestoreProfileSnapshot400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "encryption_key is required"}}, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(req.Params.EncryptionKey)
+	if err != nil {
+		return oapi.RestoreProfileSnapshot400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "encryption_key must be base64-encoded"}}, nil
+	}
+
+	opts := profilesnapshot.RestoreOptions{
+		DecryptionKey: key,
+		VerifyKey:     s.profileSnapshotSigner.Public(),
+	}
+	if req.Params.Force != nil {
+		opts.Force = *req.Params.Force
+	}
+
+	if err := profilesnapshot.Restore(userDataDir, opts, req.Body); err != nil {
+		var mismatch *profilesnapshot.BuildIDMismatchError
+		if errors.As(err, &mismatch) {
+			return oapi.RestoreProfileSnapshot409JSONResponse{ConflictErrorJSONResponse: oapi.ConflictErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		log.Error("failed to restore profile snapshot", "err", err)
+		return oapi.RestoreProfileSnapshot400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	if err := storagepartition.ClearSingletonLocks(s.storagePartitionSources()); err != nil {
+		log.Error("failed to clear singleton locks after profile restore", "err", err)
+		return oapi.RestoreProfileSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	prevWSURL := s.upstreamMgr.Current()
+	s.restartChromium(ctx, log, "")
+	if _, err := s.upstreamMgr.WaitForNext(prevWSURL, profileRestoreReadyTimeout); err != nil {
+		log.Error("chromium did not come back up after profile restore", "err", err)
+		return oapi.RestoreProfileSnapshot500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("restored profile snapshot")
+	return oapi.RestoreProfileSnapshot204Response{}, nil
+}
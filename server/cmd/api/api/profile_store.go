@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/profilestore"
+)
+
+// profileCheckpointReadyTimeout bounds how long RestoreProfileCheckpoint waits for Chromium to
+// come back up after the restart it triggers post-restore, mirroring
+// profileRestoreReadyTimeout in profile_snapshot.go.
+const profileCheckpointReadyTimeout = 30 * time.Second
+
+// CreateProfileCheckpoint snapshots the running Chromium profile into server/lib/profilestore's
+// content-addressed chunk store: unlike CreateProfileSnapshot (a single encrypted archive meant
+// to be downloaded and stored by the caller), a checkpoint stays server-side and only uploads
+// chunks the store doesn't already have, so repeated checkpoints of a mostly-unchanged profile
+// are cheap. req.Body.Parent is recorded for bookkeeping only; dedup happens against every chunk
+// the store has ever seen, not just the parent's.
+func (s *ApiService) CreateProfileCheckpoint(ctx context.Context, req oapi.CreateProfileCheckpointRequestObject) (oapi.CreateProfileCheckpointResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	opts := profilestore.SnapshotOptions{}
+	if req.Body != nil {
+		opts.Parent = req.Body.Parent
+		if req.Body.IncludeCaches != nil {
+			opts.IncludeCaches = *req.Body.IncludeCaches
+		}
+	}
+
+	id, err := profilestore.SnapshotProfile(s.profileChunks, s.profileSnapshots, userDataDir, opts, time.Now())
+	if err != nil {
+		log.Error("failed to create profile checkpoint", "err", err)
+		return oapi.CreateProfileCheckpoint500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("created profile checkpoint", "id", id, "parent", opts.Parent)
+	return oapi.CreateProfileCheckpoint200JSONResponse{Id: id}, nil
+}
+
+// ListProfileCheckpoints returns every checkpoint CreateProfileCheckpoint has recorded, most
+// recent first.
+func (s *ApiService) ListProfileCheckpoints(ctx context.Context, req oapi.ListProfileCheckpointsRequestObject) (oapi.ListProfileCheckpointsResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	infos, err := profilestore.ListSnapshots(s.profileSnapshots)
+	if err != nil {
+		log.Error("failed to list profile checkpoints", "err", err)
+		return oapi.ListProfileCheckpoints500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	resp := make([]oapi.ProfileCheckpoint, len(infos))
+	for i, info := range infos {
+		resp[i] = oapi.ProfileCheckpoint{
+			Id:        info.ID,
+			Parent:    info.ParentID,
+			CreatedAt: info.CreatedAt,
+			NumFiles:  info.NumFiles,
+		}
+	}
+	return oapi.ListProfileCheckpoints200JSONResponse(resp), nil
+}
+
+// RestoreProfileCheckpoint reconstructs req.Params.Id's checkpoint into the running profile in
+// place - rewriting every file it lists, pruning anything else - then clears Chromium's
+// singleton locks and coordinates a restart, the same readiness dance RestoreProfileSnapshot
+// does (see profile_snapshot.go; the two share restartChromium/WaitForNext but not the
+// encrypted-archive-vs-chunk-store restore logic itself).
+func (s *ApiService) RestoreProfileCheckpoint(ctx context.Context, req oapi.RestoreProfileCheckpointRequestObject) (oapi.RestoreProfileCheckpointResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if err := profilestore.RestoreProfile(s.profileChunks, s.profileSnapshots, req.Id, userDataDir); err != nil {
+		log.Error("failed to restore profile checkpoint", "id", req.Id, "err", err)
+		return oapi.RestoreProfileCheckpoint400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	prevWSURL := s.upstreamMgr.Current()
+	s.restartChromium(ctx, log, req.Id)
+	if _, err := s.upstreamMgr.WaitForNext(prevWSURL, profileCheckpointReadyTimeout); err != nil {
+		log.Error("chromium did not come back up after profile checkpoint restore", "err", err)
+		return oapi.RestoreProfileCheckpoint500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("restored profile checkpoint", "id", req.Id)
+	return oapi.RestoreProfileCheckpoint204Response{}, nil
+}
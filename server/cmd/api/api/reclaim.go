@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -14,8 +15,14 @@ import (
 	"github.com/onkernel/kernel-images/server/cmd/api/circuits"
 	"github.com/onkernel/kernel-images/server/lib/logger"
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/proofsession"
 )
 
+// reclaimProofTimeout bounds how long a single asynchronous proof run is allowed to take before
+// its session is marked StatusTimeout (a quorum run that hasn't reached k agreeing attestors by
+// then is marked StatusFailed instead, with the quorum error).
+const reclaimProofTimeout = 5 * time.Minute
+
 // Default TEE service URLs
 const (
 	defaultTEEKUrl     = "wss://tk.reclaimprotocol.org/ws"
@@ -28,15 +35,110 @@ type reclaimConfigJSON struct {
 	TEEKUrl     string `json:"teekUrl,omitempty"`
 	TEETUrl     string `json:"teetUrl,omitempty"`
 	AttestorUrl string `json:"attestorUrl,omitempty"`
+
+	// TEEKUrls/TEETUrls/AttestorUrls opt into multi-attestor quorum execution (see
+	// reclaim_quorum.go): one independent protocol run per index, fanned out in parallel and
+	// reconciled by Quorum.K. Whichever of the three is set must all be the same length; any
+	// left empty falls back to the single TEEKUrl/TEETUrl/AttestorUrl (or its default) for
+	// every attestor.
+	TEEKUrls     []string      `json:"teekUrls,omitempty"`
+	TEETUrls     []string      `json:"teetUrls,omitempty"`
+	AttestorUrls []string      `json:"attestorUrls,omitempty"`
+	Quorum       *quorumConfig `json:"quorum,omitempty"`
+}
+
+// quorumConfig is how many attestors to run (n, inferred from the url arrays if omitted) and
+// how many must agree (k, defaults to n).
+type quorumConfig struct {
+	N int `json:"n,omitempty"`
+	K int `json:"k,omitempty"`
+}
+
+// reclaimProtocolClient is the subset of *client.ReclaimClient this package depends on,
+// extracted so quorum execution (reclaim_quorum.go) can be driven by a fake in tests instead of
+// live TEE/attestor endpoints.
+type reclaimProtocolClient interface {
+	ExecuteCompleteProtocol(opts any) (*client.ClaimWithSignatures, error)
+	Close()
+}
+
+var _ reclaimProtocolClient = (*client.ReclaimClient)(nil)
+
+// reclaimExecuteOptions is the opts value passed to reclaimProtocolClient.ExecuteCompleteProtocol
+// so the underlying reclaim-tee client can report TEE/MPC/ZK lifecycle events as they happen
+// (see reclaim_events.go's ProgressReporter), instead of this package only learning whether the
+// protocol succeeded once the call returns. OnProgress is called with one of
+// "tee.k.connected"/"tee.t.connected"/"attestor.connected"/"mpc.round"/"zk.circuit.loaded"/
+// "zk.proof.generated"/"claim.signed"; attestor and round are only set for the event types that
+// carry them.
+type reclaimExecuteOptions struct {
+	OnProgress func(stage string, attestor string, round int)
+}
+
+// executeOptsWithProgress builds the opts argument for ExecuteCompleteProtocol that relays
+// progress through reporter, tagging every event with attestor so a quorum-mode subscriber can
+// tell which attestor an event came from.
+func executeOptsWithProgress(reporter ProgressReporter, attestor string) *reclaimExecuteOptions {
+	return &reclaimExecuteOptions{
+		OnProgress: func(stage string, eventAttestor string, round int) {
+			if eventAttestor == "" {
+				eventAttestor = attestor
+			}
+			reportProgress(reporter, ReclaimProgressEvent{Type: stage, Attestor: eventAttestor, Round: round})
+		},
+	}
 }
 
-// ReclaimProve executes the TEE+MPC proof protocol
+// newReclaimProtocolClient builds a reclaimProtocolClient for one attestor's TEE-K/TEE-T/
+// attestor endpoints. It's used for the single-attestor path and once per attestor in quorum
+// mode.
+func newReclaimProtocolClient(providerParamsJSON string, a quorumAttestor) (reclaimProtocolClient, error) {
+	clientConfigJSON, err := json.Marshal(reclaimConfigJSON{
+		TEEKUrl:     a.TEEKUrl,
+		TEETUrl:     a.TEETUrl,
+		AttestorUrl: a.AttestorUrl,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare client configuration: %w", err)
+	}
+	return client.NewReclaimClientFromJSON(providerParamsJSON, string(clientConfigJSON))
+}
+
+// ReclaimProve enqueues the TEE+MPC proof protocol and returns immediately with a session ID.
+// The protocol itself can run for minutes, well past what a load balancer's idle HTTP timeout
+// tolerates, so the actual run happens in runReclaimProve; callers poll GetReclaimSession or
+// supply a callback_url to be notified on completion.
 func (s *ApiService) ReclaimProve(ctx context.Context, req oapi.ReclaimProveRequestObject) (oapi.ReclaimProveResponseObject, error) {
 	log := logger.FromContext(ctx)
 
+	claims, err := s.reclaimAuth.Authenticate(ctx, bearerToken(req.Params.Authorization))
+	if err != nil {
+		log.Warn("reclaim prove authentication failed", "err", err)
+		if resp, ok := reclaimProveAuthErrorResponse(err); ok {
+			return resp, nil
+		}
+		return oapi.ReclaimProve401JSONResponse{UnauthorizedErrorJSONResponse: oapi.UnauthorizedErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	provider := reclaimProviderName(req.Body.ProviderParamsJson)
+	activeSessions, err := s.proofSessions.CountActiveBySubject(ctx, claims.Subject)
+	if err != nil {
+		log.Error("failed to count active proof sessions", "subject", claims.Subject, "err", err)
+		return oapi.ReclaimProve500JSONResponse{
+			InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to check active session count"},
+		}, nil
+	}
+	if err := s.reclaimAuth.Authorize(claims, provider, activeSessions); err != nil {
+		log.Warn("reclaim prove authorization denied", "subject", claims.Subject, "provider", provider, "err", err)
+		if resp, ok := reclaimProveAuthErrorResponse(err); ok {
+			return resp, nil
+		}
+		return oapi.ReclaimProve403JSONResponse{ForbiddenErrorJSONResponse: oapi.ForbiddenErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
 	// Generate session ID
 	sessionID := uuid.New()
-	log.Info("starting reclaim prove", "session_id", sessionID.String())
+	log.Info("enqueuing reclaim prove session", "session_id", sessionID.String(), "subject", claims.Subject)
 
 	// Setup ZK callback (idempotent, only runs once)
 	circuits.SetupZKCallback()
@@ -47,8 +149,8 @@ func (s *ApiService) ReclaimProve(ctx context.Context, req oapi.ReclaimProveRequ
 	attestorUrl := getEnvOrDefault("ATTESTOR_URL", defaultAttestorUrl)
 
 	// Apply request-level config overrides if provided
+	var cfg reclaimConfigJSON
 	if req.Body.ConfigJson != nil && *req.Body.ConfigJson != "" {
-		var cfg reclaimConfigJSON
 		if err := json.Unmarshal([]byte(*req.Body.ConfigJson), &cfg); err == nil {
 			if cfg.TEEKUrl != "" {
 				teekUrl = cfg.TEEKUrl
@@ -68,26 +170,25 @@ func (s *ApiService) ReclaimProve(ctx context.Context, req oapi.ReclaimProveRequ
 		"attestor_url", attestorUrl,
 	)
 
-	// Build config JSON for the client library
-	clientConfigJSON, err := json.Marshal(reclaimConfigJSON{
-		TEEKUrl:     teekUrl,
-		TEETUrl:     teetUrl,
-		AttestorUrl: attestorUrl,
-	})
-	if err != nil {
-		log.Error("failed to marshal client config", "err", err)
-		return oapi.ReclaimProve500JSONResponse{
-			InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{
-				Message: "failed to prepare client configuration",
-			},
-		}, nil
+	quorumMode := len(cfg.AttestorUrls) > 0 || len(cfg.TEEKUrls) > 0 || len(cfg.TEETUrls) > 0
+	var attestors []quorumAttestor
+	var quorumK int
+	if quorumMode {
+		attestors, quorumK, err = resolveQuorumAttestors(cfg, teekUrl, teetUrl, attestorUrl)
+		if err != nil {
+			return oapi.ReclaimProve400JSONResponse{
+				BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()},
+			}, nil
+		}
+		log.Info("using multi-attestor quorum configuration", "attestors", len(attestors), "k", quorumK)
+	} else {
+		attestors = []quorumAttestor{{TEEKUrl: teekUrl, TEETUrl: teetUrl, AttestorUrl: attestorUrl}}
+		quorumK = 1
 	}
 
-	// Create reclaim client from JSON
-	reclaimClient, err := client.NewReclaimClientFromJSON(
-		req.Body.ProviderParamsJson,
-		string(clientConfigJSON),
-	)
+	// Validate provider params up front against the first attestor, so a malformed request
+	// still gets a synchronous 400 instead of only surfacing as a failed session.
+	validationClient, err := newReclaimProtocolClient(req.Body.ProviderParamsJson, attestors[0])
 	if err != nil {
 		log.Error("failed to create reclaim client", "err", err)
 		return oapi.ReclaimProve400JSONResponse{
@@ -96,11 +197,76 @@ func (s *ApiService) ReclaimProve(ctx context.Context, req oapi.ReclaimProveRequ
 			},
 		}, nil
 	}
+	if quorumMode {
+		validationClient.Close()
+	}
+
+	var callbackURL, callbackSecret string
+	if req.Body.CallbackUrl != nil {
+		callbackURL = *req.Body.CallbackUrl
+	}
+	if req.Body.CallbackSecret != nil {
+		callbackSecret = *req.Body.CallbackSecret
+	}
+
+	now := time.Now()
+	sess := &proofsession.Session{
+		ID:             sessionID,
+		Status:         proofsession.StatusPending,
+		Phase:          proofsession.PhaseTEEHandshake,
+		CallbackURL:    callbackURL,
+		CallbackSecret: callbackSecret,
+		Subject:        claims.Subject,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.proofSessions.Create(ctx, sess); err != nil {
+		if !quorumMode {
+			validationClient.Close()
+		}
+		log.Error("failed to persist proof session", "session_id", sessionID.String(), "err", err)
+		return oapi.ReclaimProve500JSONResponse{
+			InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{
+				Message: "failed to create proof session",
+			},
+		}, nil
+	}
+
+	reporter := s.reclaimEvents.reporterFor(sessionID)
+	if quorumMode {
+		go s.runReclaimProveQuorum(sessionID, req.Body.ProviderParamsJson, attestors, quorumK, reporter)
+	} else {
+		// Reuse the client built to validate provider params above instead of connecting twice.
+		go s.runReclaimProve(sessionID, validationClient, attestorUrl, reporter)
+	}
+
+	return oapi.ReclaimProve202JSONResponse{SessionId: sessionID}, nil
+}
+
+// runReclaimProve runs the TEE+MPC protocol for sess and persists its outcome, delivering a
+// callback afterward if one was requested. It runs detached from the originating request's
+// context so the proof keeps going even if the HTTP client disconnects. reporter receives
+// lifecycle events for anyone subscribed via StreamReclaimSessionEvents/
+// HandleReclaimSessionEventsWS (see reclaim_events.go).
+func (s *ApiService) runReclaimProve(sessionID uuid.UUID, reclaimClient reclaimProtocolClient, attestorUrl string, reporter ProgressReporter) {
 	defer reclaimClient.Close()
 
-	// Create a context with timeout (5 minutes for proof generation)
-	proofCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	proofCtx, cancel := context.WithTimeout(context.Background(), reclaimProofTimeout)
 	defer cancel()
+	log := logger.FromContext(proofCtx)
+
+	reportProgress(reporter, ReclaimProgressEvent{Type: "session.started"})
+
+	sess, err := s.proofSessions.Get(proofCtx, sessionID)
+	if err != nil {
+		log.Error("failed to load proof session before running", "session_id", sessionID.String(), "err", err)
+		return
+	}
+	sess.Status = proofsession.StatusRunning
+	sess.UpdatedAt = time.Now()
+	if err := s.proofSessions.Update(proofCtx, sess); err != nil {
+		log.Error("failed to mark proof session running", "session_id", sessionID.String(), "err", err)
+	}
 
 	// Execute protocol in a goroutine so we can handle context cancellation
 	type result struct {
@@ -110,7 +276,7 @@ func (s *ApiService) ReclaimProve(ctx context.Context, req oapi.ReclaimProveRequ
 	resultCh := make(chan result, 1)
 
 	go func() {
-		claim, err := reclaimClient.ExecuteCompleteProtocol(nil)
+		claim, err := reclaimClient.ExecuteCompleteProtocol(executeOptsWithProgress(reporter, attestorUrl))
 		resultCh <- result{claim: claim, err: err}
 	}()
 
@@ -118,30 +284,80 @@ func (s *ApiService) ReclaimProve(ctx context.Context, req oapi.ReclaimProveRequ
 	select {
 	case <-proofCtx.Done():
 		log.Error("proof execution timed out", "session_id", sessionID.String())
-		return oapi.ReclaimProve500JSONResponse{
-			InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{
-				Message: "proof execution timed out",
-			},
-		}, nil
+		sess.Status = proofsession.StatusTimeout
+		sess.Error = "proof execution timed out"
 	case res := <-resultCh:
 		if res.err != nil {
 			log.Error("proof execution failed", "session_id", sessionID.String(), "err", res.err)
-			return oapi.ReclaimProve500JSONResponse{
-				InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{
-					Message: fmt.Sprintf("proof execution failed: %v", res.err),
-				},
-			}, nil
+			sess.Status = proofsession.StatusFailed
+			sess.Error = res.err.Error()
+		} else {
+			log.Info("proof execution completed", "session_id", sessionID.String(), "identifier", res.claim.Claim.Identifier)
+			claim := mapClaimToOapi(res.claim.Claim)
+			signature := mapSignatureToOapi(res.claim.Signature)
+			sess.Status = proofsession.StatusSucceeded
+			sess.Phase = proofsession.PhaseComplete
+			sess.Claim = &claim
+			sess.Signature = &signature
 		}
+	}
+
+	sess.UpdatedAt = time.Now()
+	if err := s.proofSessions.Update(proofCtx, sess); err != nil {
+		log.Error("failed to persist finished proof session", "session_id", sessionID.String(), "err", err)
+	}
+	s.publishReclaimTerminalEvent(sess)
 
-		log.Info("proof execution completed", "session_id", sessionID.String(), "identifier", res.claim.Claim.Identifier)
+	if sess.CallbackURL != "" {
+		deliverReclaimCallback(context.Background(), log, sess)
+	}
+}
 
-		// Map result to response
-		return oapi.ReclaimProve200JSONResponse{
-			SessionId: sessionID,
-			Claim:     mapClaimToOapi(res.claim.Claim),
-			Signature: mapSignatureToOapi(res.claim.Signature),
+// GetReclaimSession reports the status of a proof session enqueued by ReclaimProve.
+func (s *ApiService) GetReclaimSession(ctx context.Context, req oapi.GetReclaimSessionRequestObject) (oapi.GetReclaimSessionResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	claims, err := s.reclaimAuth.Authenticate(ctx, bearerToken(req.Params.Authorization))
+	if err != nil {
+		log.Warn("reclaim session authentication failed", "err", err)
+		if resp, ok := reclaimSessionAuthErrorResponse(err); ok {
+			return resp, nil
+		}
+		return oapi.GetReclaimSession401JSONResponse{UnauthorizedErrorJSONResponse: oapi.UnauthorizedErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	sess, err := s.proofSessions.Get(ctx, req.Id)
+	if err != nil {
+		if errors.Is(err, proofsession.ErrNotFound) {
+			return oapi.GetReclaimSession404JSONResponse{
+				NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "proof session not found"},
+			}, nil
+		}
+		log.Error("failed to load proof session", "session_id", req.Id.String(), "err", err)
+		return oapi.GetReclaimSession500JSONResponse{
+			InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to load proof session"},
+		}, nil
+	}
+
+	if sess.Subject != "" && claims.Subject != "" && sess.Subject != claims.Subject {
+		log.Warn("reclaim session subject mismatch", "session_id", req.Id.String(), "subject", claims.Subject)
+		return oapi.GetReclaimSession403JSONResponse{
+			ForbiddenErrorJSONResponse: oapi.ForbiddenErrorJSONResponse{Message: "session belongs to a different subject"},
 		}, nil
 	}
+
+	resp := oapi.GetReclaimSession200JSONResponse{
+		SessionId:  sess.ID,
+		Status:     string(sess.Status),
+		Phase:      string(sess.Phase),
+		Claim:      sess.Claim,
+		Signature:  sess.Signature,
+		Signatures: sess.Signatures,
+	}
+	if sess.Error != "" {
+		resp.Error = &sess.Error
+	}
+	return resp, nil
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/reclaimauth"
+)
+
+// This file maps reclaimauth's verification/authorization errors onto the oapi response types
+// for ReclaimProve/GetReclaimSession; the actual verification lives in
+// reclaimauth.Authenticator, loaded once at startup via reclaimauth.LoadAuthenticatorFromEnv.
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header value. It
+// returns "" for a missing or malformed header, which reclaimauth.Authenticate rejects as
+// ErrMissingToken.
+func bearerToken(authorization *string) string {
+	if authorization == nil {
+		return ""
+	}
+	const prefix = "Bearer "
+	if !strings.HasPrefix(*authorization, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(*authorization, prefix)
+}
+
+// reclaimProviderName pulls the "provider" field out of a ReclaimProve request's
+// providerParamsJson, so it can be checked against the caller's IssuerPolicy.AllowedProviders.
+func reclaimProviderName(providerParamsJSON string) string {
+	var params struct {
+		Provider string `json:"provider"`
+	}
+	_ = json.Unmarshal([]byte(providerParamsJSON), &params)
+	return params.Provider
+}
+
+// reclaimProveAuthErrorResponse maps an error from Authenticate/Authorize onto the matching
+// ReclaimProve 401/403 response. ok is false if err doesn't match either case, so the caller
+// falls through to its own generic error handling.
+func reclaimProveAuthErrorResponse(err error) (oapi.ReclaimProveResponseObject, bool) {
+	switch e := err.(type) {
+	case *reclaimauth.ErrUnauthorized:
+		return oapi.ReclaimProve401JSONResponse{UnauthorizedErrorJSONResponse: oapi.UnauthorizedErrorJSONResponse{Message: e.Error()}}, true
+	case *reclaimauth.ErrForbidden:
+		return oapi.ReclaimProve403JSONResponse{ForbiddenErrorJSONResponse: oapi.ForbiddenErrorJSONResponse{Message: e.Error()}}, true
+	default:
+		if err == reclaimauth.ErrMissingToken {
+			return oapi.ReclaimProve401JSONResponse{UnauthorizedErrorJSONResponse: oapi.UnauthorizedErrorJSONResponse{Message: err.Error()}}, true
+		}
+		return nil, false
+	}
+}
+
+// reclaimSessionAuthErrorResponse is reclaimProveAuthErrorResponse's GetReclaimSession
+// counterpart.
+func reclaimSessionAuthErrorResponse(err error) (oapi.GetReclaimSessionResponseObject, bool) {
+	switch e := err.(type) {
+	case *reclaimauth.ErrUnauthorized:
+		return oapi.GetReclaimSession401JSONResponse{UnauthorizedErrorJSONResponse: oapi.UnauthorizedErrorJSONResponse{Message: e.Error()}}, true
+	case *reclaimauth.ErrForbidden:
+		return oapi.GetReclaimSession403JSONResponse{ForbiddenErrorJSONResponse: oapi.ForbiddenErrorJSONResponse{Message: e.Error()}}, true
+	default:
+		if err == reclaimauth.ErrMissingToken {
+			return oapi.GetReclaimSession401JSONResponse{UnauthorizedErrorJSONResponse: oapi.UnauthorizedErrorJSONResponse{Message: err.Error()}}, true
+		}
+		return nil, false
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/proofsession"
+)
+
+// This file delivers the optional webhook a ReclaimProve caller can request via callback_url, so
+// it doesn't have to poll GetReclaimSession to learn when a proof session finishes.
+
+const (
+	reclaimCallbackMaxAttempts = 5
+	reclaimCallbackBaseDelay   = 2 * time.Second
+	reclaimCallbackMaxDelay    = 30 * time.Second
+)
+
+// reclaimCallbackPayload is the JSON body POSTed to a session's callback_url on completion.
+type reclaimCallbackPayload struct {
+	SessionId  uuid.UUID               `json:"session_id"`
+	Status     proofsession.Status     `json:"status"`
+	Claim      *oapi.ReclaimClaim      `json:"claim,omitempty"`
+	Signature  *oapi.ReclaimSignature  `json:"signature,omitempty"`
+	Signatures []oapi.ReclaimSignature `json:"signatures,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+}
+
+// deliverReclaimCallback POSTs sess's outcome to sess.CallbackURL, signing the body with
+// sess.CallbackSecret (if set) and retrying with exponential backoff. It's called after the
+// session has already been persisted, so a delivery failure only affects the webhook, not a
+// caller polling GetReclaimSession directly.
+func deliverReclaimCallback(ctx context.Context, log *slog.Logger, sess *proofsession.Session) {
+	payload := reclaimCallbackPayload{
+		SessionId:  sess.ID,
+		Status:     sess.Status,
+		Claim:      sess.Claim,
+		Signature:  sess.Signature,
+		Signatures: sess.Signatures,
+		Error:      sess.Error,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error("failed to marshal reclaim callback payload", "session_id", sess.ID.String(), "err", err)
+		return
+	}
+	signature := signReclaimCallback(sess.CallbackSecret, body)
+
+	delay := reclaimCallbackBaseDelay
+	for attempt := 1; attempt <= reclaimCallbackMaxAttempts; attempt++ {
+		if err := postReclaimCallback(ctx, sess.CallbackURL, signature, body); err == nil {
+			return
+		} else if attempt == reclaimCallbackMaxAttempts {
+			log.Error("reclaim callback delivery exhausted retries", "session_id", sess.ID.String(), "url", sess.CallbackURL, "err", err)
+			return
+		} else {
+			log.Warn("reclaim callback delivery attempt failed", "session_id", sess.ID.String(), "attempt", attempt, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > reclaimCallbackMaxDelay {
+			delay = reclaimCallbackMaxDelay
+		}
+	}
+}
+
+func postReclaimCallback(ctx context.Context, url, signature string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Kernel-Signature", signature)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("callback request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signReclaimCallback returns the X-Kernel-Signature header value: "sha256=" followed by the hex
+// HMAC-SHA256 of body keyed on secret. An empty secret still produces a (less useful) signature
+// rather than omitting the header, so callers always get a consistent contract to verify against.
+func signReclaimCallback(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/nrednav/cuid2"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/proofsession"
+)
+
+// reclaimEventHeartbeatInterval is how often a subscriber with nothing new to report still gets
+// a "heartbeat" event, so a reverse proxy or idle client doesn't time out the connection during
+// a long TEE handshake or MPC round.
+const reclaimEventHeartbeatInterval = 15 * time.Second
+
+// ReclaimProgressEvent is one structured lifecycle update a ProgressReporter publishes as the
+// reclaim protocol progresses. Attestor and Round are optional detail some event types carry
+// (e.g. "tee.k.connected"/"attestor.connected" set Attestor in quorum mode; "mpc.round" sets
+// Round); leave them zero-valued when not applicable.
+type ReclaimProgressEvent struct {
+	Type     string
+	Attestor string
+	Round    int
+	Message  string
+}
+
+// ProgressReporter receives structured lifecycle events for one proof session as
+// reclaimClient.ExecuteCompleteProtocol runs, so a subscriber of
+// StreamReclaimSessionEvents/HandleReclaimSessionEventsWS can watch the protocol progress
+// instead of only polling GetReclaimSession. The zero value (a nil ProgressReporter) is valid to
+// pass around; reportProgress below treats it as a no-op so callers that don't care about
+// streaming (e.g. existing tests) don't need a fake.
+type ProgressReporter interface {
+	Report(evt ReclaimProgressEvent)
+}
+
+// reportProgress calls r.Report if r is non-nil, so call sites don't need to nil-check before
+// every report.
+func reportProgress(r ProgressReporter, evt ReclaimProgressEvent) {
+	if r != nil {
+		r.Report(evt)
+	}
+}
+
+// reclaimEventHub fans a session's published events out to every subscriber of
+// StreamReclaimSessionEvents/HandleReclaimSessionEventsWS, mirroring displayEventSubs'
+// subscriber-map pattern in display.go but keyed per-session rather than globally, since each
+// reclaim session's events are only relevant to clients watching that session.
+type reclaimEventHub struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[string]chan oapi.ReclaimEvent
+}
+
+func newReclaimEventHub() *reclaimEventHub {
+	return &reclaimEventHub{subs: make(map[uuid.UUID]map[string]chan oapi.ReclaimEvent)}
+}
+
+// subscribe registers a new subscriber channel for sessionID and returns it along with an
+// unsubscribe func the caller must call exactly once when it stops reading.
+func (h *reclaimEventHub) subscribe(sessionID uuid.UUID) (chan oapi.ReclaimEvent, func()) {
+	subID := cuid2.Generate()
+	ch := make(chan oapi.ReclaimEvent, 16)
+
+	h.mu.Lock()
+	if h.subs[sessionID] == nil {
+		h.subs[sessionID] = make(map[string]chan oapi.ReclaimEvent)
+	}
+	h.subs[sessionID][subID] = ch
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[sessionID], subID)
+		if len(h.subs[sessionID]) == 0 {
+			delete(h.subs, sessionID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publish fans evt out to every current subscriber of evt.SessionId. A subscriber whose channel
+// is full is skipped rather than blocked on, since a slow SSE/WS client shouldn't stall the
+// goroutine running the proof protocol.
+func (h *reclaimEventHub) publish(evt oapi.ReclaimEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, ch := range h.subs[evt.SessionId] {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
+
+// reporterFor returns a ProgressReporter that publishes progress events through h under
+// sessionID. It does not publish the terminal session.completed/session.failed events; those
+// carry the persisted claim/signature(s) and are published directly from
+// runReclaimProve/runReclaimProveQuorum via publishReclaimTerminalEvent.
+func (h *reclaimEventHub) reporterFor(sessionID uuid.UUID) ProgressReporter {
+	return &hubReporter{hub: h, sessionID: sessionID}
+}
+
+type hubReporter struct {
+	hub       *reclaimEventHub
+	sessionID uuid.UUID
+}
+
+func (r *hubReporter) Report(evt ReclaimProgressEvent) {
+	oapiEvt := oapi.ReclaimEvent{
+		Type:      evt.Type,
+		SessionId: r.sessionID,
+		Timestamp: time.Now(),
+	}
+	if evt.Attestor != "" {
+		oapiEvt.Attestor = &evt.Attestor
+	}
+	if evt.Round != 0 {
+		oapiEvt.Round = &evt.Round
+	}
+	if evt.Message != "" {
+		oapiEvt.Message = &evt.Message
+	}
+	r.hub.publish(oapiEvt)
+}
+
+// publishReclaimTerminalEvent publishes sess's outcome as a "session.completed" or
+// "session.failed" event, carrying the same claim/signature(s)/error a caller would otherwise
+// only learn by polling GetReclaimSession. It's the last event a subscriber of this session will
+// ever receive.
+func (s *ApiService) publishReclaimTerminalEvent(sess *proofsession.Session) {
+	evt := oapi.ReclaimEvent{
+		SessionId:  sess.ID,
+		Claim:      sess.Claim,
+		Signature:  sess.Signature,
+		Signatures: sess.Signatures,
+		Timestamp:  time.Now(),
+	}
+	if sess.Status == proofsession.StatusSucceeded {
+		evt.Type = "session.completed"
+	} else {
+		evt.Type = "session.failed"
+		if sess.Error != "" {
+			evt.Error = &sess.Error
+		}
+	}
+	s.reclaimEvents.publish(evt)
+}
+
+// authenticateReclaimEventsRequest authenticates bearerToken and, if the session already has an
+// owning subject, checks it matches the caller -- the same rule GetReclaimSession enforces, kept
+// consistent here since this endpoint exposes the same claim/signature data.
+func (s *ApiService) authenticateReclaimEventsRequest(ctx context.Context, bearer string, sess *proofsession.Session) error {
+	claims, err := s.reclaimAuth.Authenticate(ctx, bearer)
+	if err != nil {
+		return err
+	}
+	if sess.Subject != "" && claims.Subject != "" && sess.Subject != claims.Subject {
+		return &reclaimEventForbiddenError{}
+	}
+	return nil
+}
+
+type reclaimEventForbiddenError struct{}
+
+func (e *reclaimEventForbiddenError) Error() string { return "session belongs to a different subject" }
+
+// StreamReclaimSessionEvents serves an SSE stream of a single reclaim proof session's lifecycle
+// events (see ProgressReporter), terminating with a session.completed/session.failed event
+// carrying the finished claim and signature(s) so a client can consume this endpoint standalone
+// instead of also polling GetReclaimSession.
+func (s *ApiService) StreamReclaimSessionEvents(ctx context.Context, req oapi.StreamReclaimSessionEventsRequestObject) (oapi.StreamReclaimSessionEventsResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	sess, err := s.proofSessions.Get(ctx, req.Id)
+	if err != nil {
+		if errors.Is(err, proofsession.ErrNotFound) {
+			return oapi.StreamReclaimSessionEvents404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "proof session not found"}}, nil
+		}
+		log.Error("failed to load proof session", "session_id", req.Id.String(), "err", err)
+		return oapi.StreamReclaimSessionEvents500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "failed to load proof session"}}, nil
+	}
+	if err := s.authenticateReclaimEventsRequest(ctx, bearerToken(req.Params.Authorization), sess); err != nil {
+		var forbidden *reclaimEventForbiddenError
+		if errors.As(err, &forbidden) {
+			return oapi.StreamReclaimSessionEvents403JSONResponse{ForbiddenErrorJSONResponse: oapi.ForbiddenErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		return oapi.StreamReclaimSessionEvents401JSONResponse{UnauthorizedErrorJSONResponse: oapi.UnauthorizedErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	pr, pw := io.Pipe()
+	go s.runReclaimEventStream(sess, func(evt oapi.ReclaimEvent) error {
+		pw.Write([]byte("event: "))
+		pw.Write([]byte(evt.Type))
+		pw.Write([]byte("\ndata: "))
+		if err := json.NewEncoder(pw).Encode(evt); err != nil {
+			return err
+		}
+		_, err := pw.Write([]byte("\n"))
+		return err
+	}, pw)
+
+	headers := oapi.StreamReclaimSessionEvents200ResponseHeaders{XSSEContentType: "application/json"}
+	return oapi.StreamReclaimSessionEvents200TexteventStreamResponse{Body: pr, Headers: headers, ContentLength: 0}, nil
+}
+
+// runReclaimEventStream drives one subscriber's event loop: if sess is already terminal it
+// writes the terminal event immediately and returns, otherwise it subscribes to
+// s.reclaimEvents and relays events (plus a heartbeat every reclaimEventHeartbeatInterval) until
+// a terminal event is written or the writer is closed out from under it.
+func (s *ApiService) runReclaimEventStream(sess *proofsession.Session, write func(oapi.ReclaimEvent) error, closer io.Closer) {
+	defer closer.Close()
+
+	if !sess.Active() {
+		_ = write(terminalEventFor(sess))
+		return
+	}
+
+	ch, unsubscribe := s.reclaimEvents.subscribe(sess.ID)
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(reclaimEventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case evt := <-ch:
+			if err := write(evt); err != nil {
+				return
+			}
+			if evt.Type == "session.completed" || evt.Type == "session.failed" {
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(oapi.ReclaimEvent{Type: "heartbeat", SessionId: sess.ID, Timestamp: time.Now()}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// terminalEventFor builds the session.completed/session.failed event for a session that was
+// already finished by the time a subscriber connected, so it doesn't wait forever for an event
+// that already happened.
+func terminalEventFor(sess *proofsession.Session) oapi.ReclaimEvent {
+	evt := oapi.ReclaimEvent{
+		SessionId:  sess.ID,
+		Claim:      sess.Claim,
+		Signature:  sess.Signature,
+		Signatures: sess.Signatures,
+		Timestamp:  time.Now(),
+	}
+	if sess.Status == proofsession.StatusSucceeded {
+		evt.Type = "session.completed"
+	} else {
+		evt.Type = "session.failed"
+		if sess.Error != "" {
+			evt.Error = &sess.Error
+		}
+	}
+	return evt
+}
+
+// HandleReclaimSessionEventsWS is StreamReclaimSessionEvents' websocket counterpart (see
+// main.go's raw route registration): oapi's strict handler can't hijack the connection a
+// websocket upgrade needs, so this is registered directly on the chi router instead of going
+// through the OpenAPI spec, the same way devtoolsproxy.WebSocketProxyHandler is for the CDP
+// proxy. Auth is taken from the Authorization header if present, falling back to a "token" query
+// parameter since not every WebSocket client library can set a custom header on the upgrade
+// request.
+func (s *ApiService) HandleReclaimSessionEventsWS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+	sess, err := s.proofSessions.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, proofsession.ErrNotFound) {
+			http.Error(w, "proof session not found", http.StatusNotFound)
+			return
+		}
+		log.Error("failed to load proof session", "session_id", sessionID.String(), "err", err)
+		http.Error(w, "failed to load proof session", http.StatusInternalServerError)
+		return
+	}
+
+	bearer := r.Header.Get("Authorization")
+	if bearer == "" && r.URL.Query().Get("token") != "" {
+		bearer = "Bearer " + r.URL.Query().Get("token")
+	}
+	if err := s.authenticateReclaimEventsRequest(ctx, bearerToken(&bearer), sess); err != nil {
+		var forbidden *reclaimEventForbiddenError
+		if errors.As(err, &forbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error("websocket upgrade failed", "session_id", sessionID.String(), "err", err)
+		return
+	}
+
+	s.runReclaimEventStream(sess, func(evt oapi.ReclaimEvent) error {
+		return conn.WriteJSON(evt)
+	}, wsWriteCloser{conn})
+}
+
+// wsWriteCloser adapts *websocket.Conn to io.Closer for runReclaimEventStream's shared
+// defer-close plumbing.
+type wsWriteCloser struct{ conn *websocket.Conn }
+
+func (w wsWriteCloser) Close() error { return w.conn.Close() }
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/proofsession"
+)
+
+// quorumAttestor is one fan-out target in quorum mode: independent TEE-K/TEE-T/attestor
+// endpoints expected to each produce their own signature over the same underlying claim.
+type quorumAttestor struct {
+	TEEKUrl     string
+	TEETUrl     string
+	AttestorUrl string
+}
+
+// resolveQuorumAttestors builds one quorumAttestor per index from cfg's url arrays, falling
+// back to defaultTEEK/defaultTEET/defaultAttestor for any array left unset, and validates
+// cfg.Quorum against the resulting attestor count.
+func resolveQuorumAttestors(cfg reclaimConfigJSON, defaultTEEK, defaultTEET, defaultAttestor string) ([]quorumAttestor, int, error) {
+	n := len(cfg.AttestorUrls)
+	if len(cfg.TEEKUrls) > n {
+		n = len(cfg.TEEKUrls)
+	}
+	if len(cfg.TEETUrls) > n {
+		n = len(cfg.TEETUrls)
+	}
+	if n == 0 {
+		return nil, 0, fmt.Errorf("quorum mode requires at least one of teekUrls/teetUrls/attestorUrls")
+	}
+	if (len(cfg.AttestorUrls) != 0 && len(cfg.AttestorUrls) != n) ||
+		(len(cfg.TEEKUrls) != 0 && len(cfg.TEEKUrls) != n) ||
+		(len(cfg.TEETUrls) != 0 && len(cfg.TEETUrls) != n) {
+		return nil, 0, fmt.Errorf("teekUrls/teetUrls/attestorUrls must all be the same length when set")
+	}
+	if cfg.Quorum != nil && cfg.Quorum.N > 0 && cfg.Quorum.N != n {
+		return nil, 0, fmt.Errorf("quorum.n (%d) does not match the number of attestor endpoints (%d)", cfg.Quorum.N, n)
+	}
+
+	attestors := make([]quorumAttestor, n)
+	for i := 0; i < n; i++ {
+		a := quorumAttestor{TEEKUrl: defaultTEEK, TEETUrl: defaultTEET, AttestorUrl: defaultAttestor}
+		if len(cfg.TEEKUrls) == n {
+			a.TEEKUrl = cfg.TEEKUrls[i]
+		}
+		if len(cfg.TEETUrls) == n {
+			a.TEETUrl = cfg.TEETUrls[i]
+		}
+		if len(cfg.AttestorUrls) == n {
+			a.AttestorUrl = cfg.AttestorUrls[i]
+		}
+		attestors[i] = a
+	}
+
+	k := n
+	if cfg.Quorum != nil && cfg.Quorum.K > 0 {
+		k = cfg.Quorum.K
+	}
+	if k < 1 || k > n {
+		return nil, 0, fmt.Errorf("quorum.k must be between 1 and %d, got %d", n, k)
+	}
+	return attestors, k, nil
+}
+
+// quorumOutcome is one attestor's result from a fan-out run, kept for diagnostics even when it
+// doesn't end up part of the winning quorum.
+type quorumOutcome struct {
+	Attestor   quorumAttestor
+	Identifier string
+	Signature  oapi.ReclaimSignature
+	Err        error
+}
+
+// runQuorumProtocol executes the reclaim protocol once per attestor in attestors concurrently
+// (via newClient, extracted so tests can supply a fake), and returns as soon as k of them agree
+// on a byte-identical claim identifier. Every other in-flight client is closed once a quorum is
+// reached. If ctx is done, or every attestor reports before k agree, it returns an error
+// summarizing each attestor's outcome so a caller can tell which one(s) diverged or failed.
+// reporter (nil-safe, see reportProgress) receives each attestor's "tee.k.connected"/
+// "tee.t.connected"/"attestor.connected"/"mpc.round"/"zk.circuit.loaded"/"zk.proof.generated"/
+// "claim.signed" events as they arrive, tagged with that attestor's URL.
+func runQuorumProtocol(ctx context.Context, newClient func(quorumAttestor) (reclaimProtocolClient, error), attestors []quorumAttestor, k int, reporter ProgressReporter) (*oapi.ReclaimClaim, []oapi.ReclaimSignature, error) {
+	type attempt struct {
+		outcome quorumOutcome
+		claim   *oapi.ReclaimClaim
+	}
+	resultCh := make(chan attempt, len(attestors))
+	clients := make([]reclaimProtocolClient, len(attestors))
+
+	for i, a := range attestors {
+		c, err := newClient(a)
+		if err != nil {
+			resultCh <- attempt{outcome: quorumOutcome{Attestor: a, Err: fmt.Errorf("failed to create client: %w", err)}}
+			continue
+		}
+		clients[i] = c
+		go func(a quorumAttestor, c reclaimProtocolClient) {
+			res, err := c.ExecuteCompleteProtocol(executeOptsWithProgress(reporter, a.AttestorUrl))
+			if err != nil {
+				resultCh <- attempt{outcome: quorumOutcome{Attestor: a, Err: err}}
+				return
+			}
+			claim := mapClaimToOapi(res.Claim)
+			identifier := ""
+			if claim.Identifier != nil {
+				identifier = *claim.Identifier
+			}
+			resultCh <- attempt{
+				outcome: quorumOutcome{Attestor: a, Identifier: identifier, Signature: mapSignatureToOapi(res.Signature)},
+				claim:   &claim,
+			}
+		}(a, c)
+	}
+	defer func() {
+		for _, c := range clients {
+			if c != nil {
+				c.Close()
+			}
+		}
+	}()
+
+	buckets := make(map[string][]attempt)
+	var outcomes []quorumOutcome
+	for i := 0; i < len(attestors); i++ {
+		select {
+		case <-ctx.Done():
+			return nil, nil, quorumError(append(outcomes, quorumOutcome{Err: fmt.Errorf("quorum execution timed out waiting for remaining attestors")}))
+		case res := <-resultCh:
+			if res.outcome.Err == nil && res.outcome.Identifier == "" {
+				res.outcome.Err = fmt.Errorf("attestor returned an empty claim identifier")
+			}
+			outcomes = append(outcomes, res.outcome)
+			if res.outcome.Err != nil {
+				continue
+			}
+			buckets[res.outcome.Identifier] = append(buckets[res.outcome.Identifier], res)
+			if len(buckets[res.outcome.Identifier]) >= k {
+				winning := buckets[res.outcome.Identifier]
+				signatures := make([]oapi.ReclaimSignature, len(winning))
+				for j, w := range winning {
+					signatures[j] = w.outcome.Signature
+				}
+				return winning[0].claim, signatures, nil
+			}
+		}
+	}
+	return nil, nil, quorumError(outcomes)
+}
+
+// quorumError summarizes every attestor's outcome, since fewer than k matching identifiers
+// means no single attestor's error tells the whole story of why the quorum wasn't reached.
+func quorumError(outcomes []quorumOutcome) error {
+	var sb strings.Builder
+	sb.WriteString("quorum not reached: ")
+	for i, o := range outcomes {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		if o.Err != nil {
+			fmt.Fprintf(&sb, "%s: %v", o.Attestor.AttestorUrl, o.Err)
+		} else {
+			fmt.Fprintf(&sb, "%s: identifier=%s", o.Attestor.AttestorUrl, o.Identifier)
+		}
+	}
+	return errors.New(sb.String())
+}
+
+// runReclaimProveQuorum is runReclaimProve's multi-attestor counterpart: it fans the protocol
+// out across attestors and persists the aggregated quorum result (shared claim plus every
+// agreeing attestor's signature) instead of a single attestor's. reporter receives lifecycle
+// events for anyone subscribed via StreamReclaimSessionEvents/HandleReclaimSessionEventsWS (see
+// reclaim_events.go).
+func (s *ApiService) runReclaimProveQuorum(sessionID uuid.UUID, providerParamsJSON string, attestors []quorumAttestor, k int, reporter ProgressReporter) {
+	proofCtx, cancel := context.WithTimeout(context.Background(), reclaimProofTimeout)
+	defer cancel()
+	log := logger.FromContext(proofCtx)
+
+	reportProgress(reporter, ReclaimProgressEvent{Type: "session.started"})
+
+	sess, err := s.proofSessions.Get(proofCtx, sessionID)
+	if err != nil {
+		log.Error("failed to load proof session before running", "session_id", sessionID.String(), "err", err)
+		return
+	}
+	sess.Status = proofsession.StatusRunning
+	sess.UpdatedAt = time.Now()
+	if err := s.proofSessions.Update(proofCtx, sess); err != nil {
+		log.Error("failed to mark proof session running", "session_id", sessionID.String(), "err", err)
+	}
+
+	claim, signatures, err := runQuorumProtocol(proofCtx, func(a quorumAttestor) (reclaimProtocolClient, error) {
+		return newReclaimProtocolClient(providerParamsJSON, a)
+	}, attestors, k, reporter)
+
+	if err != nil {
+		log.Error("quorum proof execution failed", "session_id", sessionID.String(), "err", err)
+		sess.Status = proofsession.StatusFailed
+		sess.Error = err.Error()
+	} else {
+		log.Info("quorum proof execution completed", "session_id", sessionID.String(), "agreeing_attestors", len(signatures))
+		sess.Status = proofsession.StatusSucceeded
+		sess.Phase = proofsession.PhaseComplete
+		sess.Claim = claim
+		sess.Signature = &signatures[0]
+		sess.Signatures = signatures
+	}
+
+	sess.UpdatedAt = time.Now()
+	if err := s.proofSessions.Update(proofCtx, sess); err != nil {
+		log.Error("failed to persist finished proof session", "session_id", sessionID.String(), "err", err)
+	}
+	s.publishReclaimTerminalEvent(sess)
+
+	if sess.CallbackURL != "" {
+		deliverReclaimCallback(context.Background(), log, sess)
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reclaimprotocol/reclaim-tee/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReclaimClient is a reclaimProtocolClient stand-in so quorum logic can be tested without a
+// live TEE/attestor endpoint.
+type fakeReclaimClient struct {
+	claim  *client.ClaimWithSignatures
+	err    error
+	delay  time.Duration
+	closed bool
+}
+
+func (f *fakeReclaimClient) ExecuteCompleteProtocol(opts any) (*client.ClaimWithSignatures, error) {
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.claim, nil
+}
+
+func (f *fakeReclaimClient) Close() { f.closed = true }
+
+// fakeClaimData satisfies mapClaimToOapi's local providerClaimData interface.
+type fakeClaimData struct{ identifier string }
+
+func (f fakeClaimData) GetProvider() string   { return "test-provider" }
+func (f fakeClaimData) GetParameters() string { return "{}" }
+func (f fakeClaimData) GetOwner() string      { return "owner" }
+func (f fakeClaimData) GetTimestampS() uint32 { return 0 }
+func (f fakeClaimData) GetContext() string    { return "" }
+func (f fakeClaimData) GetIdentifier() string { return f.identifier }
+func (f fakeClaimData) GetEpoch() uint32      { return 1 }
+
+// fakeSignatureData satisfies mapSignatureToOapi's local claimSignature interface.
+type fakeSignatureData struct{ attestor string }
+
+func (f fakeSignatureData) GetAttestorAddress() string { return f.attestor }
+func (f fakeSignatureData) GetClaimSignature() []byte  { return []byte("claim-sig-" + f.attestor) }
+func (f fakeSignatureData) GetResultSignature() []byte { return []byte("result-sig-" + f.attestor) }
+
+func fakeResult(identifier, attestor string) *client.ClaimWithSignatures {
+	return &client.ClaimWithSignatures{
+		Claim:     fakeClaimData{identifier: identifier},
+		Signature: fakeSignatureData{attestor: attestor},
+	}
+}
+
+func attestorsFor(urls ...string) []quorumAttestor {
+	out := make([]quorumAttestor, len(urls))
+	for i, u := range urls {
+		out[i] = quorumAttestor{AttestorUrl: u}
+	}
+	return out
+}
+
+func TestRunQuorumProtocolSucceedsWhenKAgree(t *testing.T) {
+	t.Parallel()
+	attestors := attestorsFor("a1", "a2", "a3")
+	fakes := map[string]*fakeReclaimClient{
+		"a1": {claim: fakeResult("claim-x", "a1")},
+		"a2": {claim: fakeResult("claim-x", "a2")},
+		"a3": {claim: fakeResult("claim-y", "a3")},
+	}
+
+	claim, signatures, err := runQuorumProtocol(context.Background(), func(a quorumAttestor) (reclaimProtocolClient, error) {
+		return fakes[a.AttestorUrl], nil
+	}, attestors, 2, nil)
+
+	require.NoError(t, err)
+	require.NotNil(t, claim)
+	assert.Equal(t, "claim-x", *claim.Identifier)
+	assert.Len(t, signatures, 2)
+	gotAttestors := []string{*signatures[0].AttestorAddress, *signatures[1].AttestorAddress}
+	assert.ElementsMatch(t, []string{"a1", "a2"}, gotAttestors)
+}
+
+func TestRunQuorumProtocolFailsWhenFewerThanKAgree(t *testing.T) {
+	t.Parallel()
+	attestors := attestorsFor("a1", "a2", "a3")
+	fakes := map[string]*fakeReclaimClient{
+		"a1": {claim: fakeResult("claim-x", "a1")},
+		"a2": {claim: fakeResult("claim-y", "a2")},
+		"a3": {claim: fakeResult("claim-z", "a3")},
+	}
+
+	claim, signatures, err := runQuorumProtocol(context.Background(), func(a quorumAttestor) (reclaimProtocolClient, error) {
+		return fakes[a.AttestorUrl], nil
+	}, attestors, 2, nil)
+
+	require.Error(t, err)
+	assert.Nil(t, claim)
+	assert.Nil(t, signatures)
+	assert.Contains(t, err.Error(), "quorum not reached")
+}
+
+func TestRunQuorumProtocolToleratesClientCreationFailure(t *testing.T) {
+	t.Parallel()
+	attestors := attestorsFor("a1", "a2", "a3")
+	fakes := map[string]*fakeReclaimClient{
+		"a2": {claim: fakeResult("claim-x", "a2")},
+		"a3": {claim: fakeResult("claim-x", "a3")},
+	}
+
+	claim, signatures, err := runQuorumProtocol(context.Background(), func(a quorumAttestor) (reclaimProtocolClient, error) {
+		f, ok := fakes[a.AttestorUrl]
+		if !ok {
+			return nil, assert.AnError
+		}
+		return f, nil
+	}, attestors, 2, nil)
+
+	require.NoError(t, err)
+	require.NotNil(t, claim)
+	assert.Equal(t, "claim-x", *claim.Identifier)
+	assert.Len(t, signatures, 2)
+}
+
+func TestRunQuorumProtocolClosesLosingClients(t *testing.T) {
+	t.Parallel()
+	attestors := attestorsFor("a1", "a2", "a3")
+	slow := &fakeReclaimClient{claim: fakeResult("claim-y", "a3"), delay: 50 * time.Millisecond}
+	fakes := map[string]*fakeReclaimClient{
+		"a1": {claim: fakeResult("claim-x", "a1")},
+		"a2": {claim: fakeResult("claim-x", "a2")},
+		"a3": slow,
+	}
+
+	_, _, err := runQuorumProtocol(context.Background(), func(a quorumAttestor) (reclaimProtocolClient, error) {
+		return fakes[a.AttestorUrl], nil
+	}, attestors, 2, nil)
+	require.NoError(t, err)
+
+	// give the deferred cleanup inside runQuorumProtocol a moment to run after it returns
+	assert.Eventually(t, func() bool { return slow.closed }, time.Second, 5*time.Millisecond)
+}
+
+func TestResolveQuorumAttestors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fills in defaults for unset url arrays", func(t *testing.T) {
+		cfg := reclaimConfigJSON{AttestorUrls: []string{"x1", "x2"}}
+		attestors, k, err := resolveQuorumAttestors(cfg, "teek", "teet", "attestor")
+		require.NoError(t, err)
+		assert.Equal(t, 2, k)
+		assert.Equal(t, []quorumAttestor{
+			{TEEKUrl: "teek", TEETUrl: "teet", AttestorUrl: "x1"},
+			{TEEKUrl: "teek", TEETUrl: "teet", AttestorUrl: "x2"},
+		}, attestors)
+	})
+
+	t.Run("honors an explicit k", func(t *testing.T) {
+		cfg := reclaimConfigJSON{
+			AttestorUrls: []string{"x1", "x2", "x3"},
+			Quorum:       &quorumConfig{K: 2},
+		}
+		_, k, err := resolveQuorumAttestors(cfg, "teek", "teet", "attestor")
+		require.NoError(t, err)
+		assert.Equal(t, 2, k)
+	})
+
+	t.Run("rejects mismatched array lengths", func(t *testing.T) {
+		cfg := reclaimConfigJSON{
+			AttestorUrls: []string{"x1", "x2"},
+			TEEKUrls:     []string{"k1"},
+		}
+		_, _, err := resolveQuorumAttestors(cfg, "teek", "teet", "attestor")
+		require.Error(t, err)
+	})
+
+	t.Run("rejects k greater than n", func(t *testing.T) {
+		cfg := reclaimConfigJSON{
+			AttestorUrls: []string{"x1", "x2"},
+			Quorum:       &quorumConfig{K: 3},
+		}
+		_, _, err := resolveQuorumAttestors(cfg, "teek", "teet", "attestor")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/recorder"
+)
+
+// progressReporter is implemented by recorders that expose real-time ffmpeg progress (today,
+// only FFmpegRecorder). GetRecordingProgress type-asserts against it rather than widening the
+// Recorder interface, the same pattern hls.go uses for hlsCapable.
+type progressReporter interface {
+	Progress(ctx context.Context) (recorder.RecordingProgress, error)
+}
+
+// GetRecordingProgress reports the latest ffmpeg -progress snapshot for a recorder, so a
+// caller can detect a stall (frame count not advancing across polls), x11grab dropping frames,
+// or an approaching -fs size cap without waiting for DownloadRecording.
+func (s *ApiService) GetRecordingProgress(ctx context.Context, req oapi.GetRecordingProgressRequestObject) (oapi.GetRecordingProgressResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	recorderID := s.defaultRecorderID
+	if req.Params.Id != nil && *req.Params.Id != "" {
+		recorderID = *req.Params.Id
+	}
+
+	rec, exists := s.recordManager.GetRecorder(recorderID)
+	if !exists {
+		return oapi.GetRecordingProgress404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "no recording found"}}, nil
+	}
+	pr, ok := rec.(progressReporter)
+	if !ok {
+		return oapi.GetRecordingProgress404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: "progress not available for this recording"}}, nil
+	}
+
+	progress, err := pr.Progress(ctx)
+	if err != nil {
+		log.Info("progress unavailable", "recorder_id", recorderID, "err", err)
+		return oapi.GetRecordingProgress404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	return oapi.GetRecordingProgress200JSONResponse{
+		Frame:      &progress.Frame,
+		Fps:        &progress.FPS,
+		Bitrate:    &progress.Bitrate,
+		TotalSize:  &progress.TotalSize,
+		OutTimeUs:  &progress.OutTimeUs,
+		DupFrames:  &progress.DupFrames,
+		DropFrames: &progress.DropFrames,
+		Speed:      &progress.Speed,
+		Done:       &progress.Done,
+		UpdatedAt:  &progress.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/storagewatch"
+)
+
+// storageEventHeartbeatInterval mirrors reclaimEventHeartbeatInterval: a subscriber watching an
+// idle profile still gets a heartbeat periodically, so a reverse proxy doesn't time the
+// connection out waiting for the first real mutation.
+const storageEventHeartbeatInterval = 15 * time.Second
+
+// StreamStorageEvents streams live Chromium storage mutations (IndexedDB writes, Cache Storage
+// writes, and cookie writes) for req.Params.Origins as Server-Sent Events, so a caller can watch
+// a profile settle instead of sleeping a fixed amount and hoping Chromium has flushed it to disk
+// by then. See server/lib/storagewatch for the underlying CDP plumbing and
+// WaitForStorageQuiescent for the single-shot equivalent of watching this stream go quiet.
+func (s *ApiService) StreamStorageEvents(ctx context.Context, req oapi.StreamStorageEventsRequestObject) (oapi.StreamStorageEventsResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	wsURL := s.upstreamMgr.Current()
+	if wsURL == "" {
+		return oapi.StreamStorageEvents500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "chromium devtools endpoint not ready"}}, nil
+	}
+
+	watcher, err := storagewatch.Attach(context.Background(), wsURL, req.Params.Origins)
+	if err != nil {
+		log.Error("failed to attach storage watcher", "err", err)
+		return oapi.StreamStorageEvents500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	pr, pw := io.Pipe()
+	go runStorageEventStream(watcher, func(evt storagewatch.Event) error {
+		pw.Write([]byte("event: "))
+		pw.Write([]byte(evt.Kind))
+		pw.Write([]byte("\ndata: "))
+		if err := json.NewEncoder(pw).Encode(evt); err != nil {
+			return err
+		}
+		_, err := pw.Write([]byte("\n"))
+		return err
+	}, pw)
+
+	headers := oapi.StreamStorageEvents200ResponseHeaders{XSSEContentType: "application/json"}
+	return oapi.StreamStorageEvents200TexteventStreamResponse{Body: pr, Headers: headers, ContentLength: 0}, nil
+}
+
+// runStorageEventStream relays watcher's Events (plus a periodic heartbeat, so an idle connection
+// doesn't get timed out by an intermediate proxy) until write fails, closing watcher and closer
+// on the way out - the storage-event counterpart to runReclaimEventStream.
+func runStorageEventStream(watcher *storagewatch.Watcher, write func(storagewatch.Event) error, closer io.Closer) {
+	defer closer.Close()
+	defer watcher.Close()
+
+	heartbeat := time.NewTicker(storageEventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case evt := <-watcher.Events():
+			if err := write(evt); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(storagewatch.Event{Kind: "heartbeat", At: time.Now()}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// HandleStorageEventsWS is StreamStorageEvents' websocket counterpart (see main.go's raw route
+// registration): oapi's strict handler can't hijack the connection a websocket upgrade needs, so
+// this is registered directly on the chi router instead of going through the OpenAPI spec, the
+// same way HandleReclaimSessionEventsWS is. Origins are taken from repeated "origin" query
+// parameters rather than a JSON body, since a WebSocket upgrade request has no body.
+func (s *ApiService) HandleStorageEventsWS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	wsURL := s.upstreamMgr.Current()
+	if wsURL == "" {
+		http.Error(w, "chromium devtools endpoint not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	watcher, err := storagewatch.Attach(context.Background(), wsURL, r.URL.Query()["origin"])
+	if err != nil {
+		log.Error("failed to attach storage watcher", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error("websocket upgrade failed", "err", err)
+		watcher.Close()
+		return
+	}
+
+	runStorageEventStream(watcher, func(evt storagewatch.Event) error {
+		return conn.WriteJSON(evt)
+	}, wsWriteCloser{conn})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"io"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/storagepartition"
+)
+
+// userDataDir is the Chromium profile root this API reads from and writes to. It must match
+// the --user-data-dir flag chromium-launcher starts chromium with.
+const userDataDir = "/home/kernel/user-data"
+
+func (s *ApiService) storagePartitionSources() storagepartition.Sources {
+	return storagepartition.Sources{UserDataDir: userDataDir}
+}
+
+// ExportStorageForOrigins archives the subset of the current Chromium profile's storage
+// partition (cookies, per-origin IndexedDB, and the shared Local Storage/Cache Storage/Service
+// Worker stores) scoped to req.Body.Origins, and returns it as a zstd-compressed tarball. Unlike
+// CreateExtensionSnapshot this doesn't write the archive to disk first, since callers are
+// expected to request small, frequently-changing subsets rather than a full profile.
+func (s *ApiService) ExportStorageForOrigins(ctx context.Context, req oapi.ExportStorageForOriginsRequestObject) (oapi.ExportStorageForOriginsResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Body == nil || len(req.Body.Origins) == 0 {
+		return oapi.ExportStorageForOrigins400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "origins must be a non-empty array"}}, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := storagepartition.ExportStorageForOrigins(s.storagePartitionSources(), req.Body.Origins, pw)
+		pw.CloseWithError(err)
+	}()
+
+	log.Info("exporting storage partition", "origins", req.Body.Origins)
+	return oapi.ExportStorageForOrigins200ApplicationzstdResponse{Body: pr}, nil
+}
+
+// ImportStorage merges an archive produced by ExportStorageForOrigins into the running profile
+// in place, without restarting chromium: cookies/local storage/cache storage/service worker
+// entries are reconciled per req.Params.MergePolicy, and IndexedDB directories are merged one
+// origin at a time. Pages that already have the affected stores open won't see the change until
+// they reload, the same caveat that applies to any on-disk profile mutation performed while
+// chromium keeps it open.
+func (s *ApiService) ImportStorage(ctx context.Context, req oapi.ImportStorageRequestObject) (oapi.ImportStorageResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	policy := storagepartition.MergeReplace
+	if req.Params.MergePolicy != nil {
+		policy = storagepartition.MergePolicy(*req.Params.MergePolicy)
+	}
+
+	if err := storagepartition.ImportStorage(s.storagePartitionSources(), req.Body, policy); err != nil {
+		log.Error("failed to import storage partition", "err", err)
+		return oapi.ImportStorage400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("imported storage partition", "merge_policy", policy)
+	return oapi.ImportStorage204Response{}, nil
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/storagestate"
+)
+
+// IndexedDBStorage/LocalStorageItem/Cookie below are the single-record counterparts to
+// GetStorageState/SetStorageState (storage_state.go): instead of round-tripping the whole
+// storageState JSON, a caller can get/set/delete exactly one key, the same fixturing need
+// server/e2e/e2e_persist_login_test.go's hand-rolled indexedDB.open()/page.evaluate() IIFEs
+// exist to serve today. req.Body.Operation picks which of the three the single POST endpoint
+// performs.
+
+// IndexedDBStorage gets, sets, or deletes one IndexedDB record by origin+database+store+key.
+// "set" runs a page-context write (see storagestate.SetIndexedDBRecord's doc - CDP itself can't
+// write IndexedDB); "get" and "delete" are genuine CDP calls.
+func (s *ApiService) IndexedDBStorage(ctx context.Context, req oapi.IndexedDBStorageRequestObject) (oapi.IndexedDBStorageResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Body == nil || req.Body.Origin == "" || req.Body.Database == "" || req.Body.Store == "" || req.Body.Key == "" {
+		return oapi.IndexedDBStorage400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "origin, database, store, and key are required"}}, nil
+	}
+
+	wsURL := s.upstreamMgr.Current()
+	if wsURL == "" {
+		return oapi.IndexedDBStorage500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "chromium devtools endpoint not ready"}}, nil
+	}
+
+	body := req.Body
+	switch body.Operation {
+	case oapi.IndexedDBOperationGet:
+		value, found, err := storagestate.GetIndexedDBRecord(ctx, wsURL, body.Origin, body.Database, body.Store, body.Key)
+		if err != nil {
+			log.Error("failed to get indexeddb record", "origin", body.Origin, "err", err)
+			return oapi.IndexedDBStorage500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		resp := oapi.IndexedDBStorageResult{Found: found}
+		if found {
+			resp.Value = &value
+		}
+		return oapi.IndexedDBStorage200JSONResponse(resp), nil
+
+	case oapi.IndexedDBOperationSet:
+		if body.Value == nil {
+			return oapi.IndexedDBStorage400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "value is required for operation \"set\""}}, nil
+		}
+		if err := storagestate.SetIndexedDBRecord(ctx, wsURL, body.Origin, body.Database, body.Store, body.Key, *body.Value); err != nil {
+			log.Error("failed to set indexeddb record", "origin", body.Origin, "err", err)
+			return oapi.IndexedDBStorage400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		return oapi.IndexedDBStorage200JSONResponse{Found: true}, nil
+
+	case oapi.IndexedDBOperationDelete:
+		if err := storagestate.DeleteIndexedDBRecord(ctx, wsURL, body.Origin, body.Database, body.Store, body.Key); err != nil {
+			log.Error("failed to delete indexeddb record", "origin", body.Origin, "err", err)
+			return oapi.IndexedDBStorage400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		return oapi.IndexedDBStorage200JSONResponse{Found: true}, nil
+
+	default:
+		return oapi.IndexedDBStorage400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("unsupported operation %q", body.Operation)}}, nil
+	}
+}
+
+// LocalStorageItem gets, sets, or deletes one localStorage key for an origin.
+func (s *ApiService) LocalStorageItem(ctx context.Context, req oapi.LocalStorageItemRequestObject) (oapi.LocalStorageItemResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Body == nil || req.Body.Origin == "" || req.Body.Key == "" {
+		return oapi.LocalStorageItem400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "origin and key are required"}}, nil
+	}
+
+	wsURL := s.upstreamMgr.Current()
+	if wsURL == "" {
+		return oapi.LocalStorageItem500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "chromium devtools endpoint not ready"}}, nil
+	}
+
+	body := req.Body
+	switch body.Operation {
+	case oapi.LocalStorageOperationGet:
+		value, found, err := storagestate.GetLocalStorageItem(ctx, wsURL, body.Origin, body.Key)
+		if err != nil {
+			log.Error("failed to get localstorage item", "origin", body.Origin, "err", err)
+			return oapi.LocalStorageItem500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		resp := oapi.LocalStorageItemResult{Found: found}
+		if found {
+			resp.Value = &value
+		}
+		return oapi.LocalStorageItem200JSONResponse(resp), nil
+
+	case oapi.LocalStorageOperationSet:
+		if body.Value == nil {
+			return oapi.LocalStorageItem400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "value is required for operation \"set\""}}, nil
+		}
+		if err := storagestate.SetLocalStorageItem(ctx, wsURL, body.Origin, body.Key, *body.Value); err != nil {
+			log.Error("failed to set localstorage item", "origin", body.Origin, "err", err)
+			return oapi.LocalStorageItem400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		return oapi.LocalStorageItem200JSONResponse{Found: true}, nil
+
+	case oapi.LocalStorageOperationDelete:
+		if err := storagestate.DeleteLocalStorageItem(ctx, wsURL, body.Origin, body.Key); err != nil {
+			log.Error("failed to delete localstorage item", "origin", body.Origin, "err", err)
+			return oapi.LocalStorageItem400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		return oapi.LocalStorageItem200JSONResponse{Found: true}, nil
+
+	default:
+		return oapi.LocalStorageItem400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("unsupported operation %q", body.Operation)}}, nil
+	}
+}
+
+// Cookie gets, sets, or deletes a single cookie by name, scoped to req.Body.Url.
+func (s *ApiService) Cookie(ctx context.Context, req oapi.CookieRequestObject) (oapi.CookieResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Body == nil || req.Body.Name == "" || req.Body.Url == "" {
+		return oapi.Cookie400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "name and url are required"}}, nil
+	}
+
+	wsURL := s.upstreamMgr.Current()
+	if wsURL == "" {
+		return oapi.Cookie500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "chromium devtools endpoint not ready"}}, nil
+	}
+
+	body := req.Body
+	switch body.Operation {
+	case oapi.CookieOperationGet:
+		cookie, found, err := storagestate.GetCookie(ctx, wsURL, body.Name, body.Url)
+		if err != nil {
+			log.Error("failed to get cookie", "name", body.Name, "err", err)
+			return oapi.Cookie500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		resp := oapi.CookieResult{Found: found}
+		if found {
+			c := oapi.Cookie(*cookie)
+			resp.Cookie = &c
+		}
+		return oapi.Cookie200JSONResponse(resp), nil
+
+	case oapi.CookieOperationSet:
+		if body.Value == nil {
+			return oapi.Cookie400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "value is required for operation \"set\""}}, nil
+		}
+		cookie := storagestate.Cookie{Name: body.Name, Value: *body.Value}
+		if body.Domain != nil {
+			cookie.Domain = *body.Domain
+		}
+		if body.Path != nil {
+			cookie.Path = *body.Path
+		}
+		if err := storagestate.SetCookie(ctx, wsURL, body.Url, cookie); err != nil {
+			log.Error("failed to set cookie", "name", body.Name, "err", err)
+			return oapi.Cookie400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		return oapi.Cookie200JSONResponse{Found: true}, nil
+
+	case oapi.CookieOperationDelete:
+		if err := storagestate.DeleteCookie(ctx, wsURL, body.Name, body.Url); err != nil {
+			log.Error("failed to delete cookie", "name", body.Name, "err", err)
+			return oapi.Cookie400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+		}
+		return oapi.Cookie200JSONResponse{Found: true}, nil
+
+	default:
+		return oapi.Cookie400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("unsupported operation %q", body.Operation)}}, nil
+	}
+}
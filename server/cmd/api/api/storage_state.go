@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/storagestate"
+)
+
+// GetStorageState dumps cookies, req.Params.Origins' localStorage, and (best effort) their
+// IndexedDB contents from the running Chromium, in the same shape Playwright's
+// context.storageState() returns plus an indexedDB extension - see storagestate.State. This
+// replaces hand-rolling the equivalent via ExecutePlaywrightCode, which is fragile to keep in
+// sync with Playwright's own storageState semantics.
+func (s *ApiService) GetStorageState(ctx context.Context, req oapi.GetStorageStateRequestObject) (oapi.GetStorageStateResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	wsURL := s.upstreamMgr.Current()
+	if wsURL == "" {
+		return oapi.GetStorageState500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "chromium devtools endpoint not ready"}}, nil
+	}
+
+	state, err := storagestate.Dump(ctx, wsURL, derefOrigins(req.Params.Origins))
+	if err != nil {
+		log.Error("failed to dump storage state", "err", err)
+		return oapi.GetStorageState500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("dumped storage state", "origins", req.Params.Origins)
+	return oapi.GetStorageState200JSONResponse(*state), nil
+}
+
+// SetStorageState seeds the running Chromium with req.Body's cookies and localStorage via CDP,
+// the server-side counterpart to GetStorageState. It does not restore req.Body.IndexedDB - see
+// storagestate.Restore's doc for why that needs a page-context write this endpoint can't make on
+// the caller's behalf.
+func (s *ApiService) SetStorageState(ctx context.Context, req oapi.SetStorageStateRequestObject) (oapi.SetStorageStateResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	if req.Body == nil {
+		return oapi.SetStorageState400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: "storage state body is required"}}, nil
+	}
+
+	wsURL := s.upstreamMgr.Current()
+	if wsURL == "" {
+		return oapi.SetStorageState500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: "chromium devtools endpoint not ready"}}, nil
+	}
+
+	state := storagestate.State(*req.Body)
+	if err := storagestate.Restore(ctx, wsURL, &state); err != nil {
+		log.Error("failed to restore storage state", "err", err)
+		return oapi.SetStorageState400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("restored storage state")
+	return oapi.SetStorageState204Response{}, nil
+}
+
+func derefOrigins(origins *[]string) []string {
+	if origins == nil {
+		return nil
+	}
+	return *origins
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onkernel/kernel-images/server/lib/logger"
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/supervisorrpc"
+)
+
+// supervisorFaultBadName is supervisord's Faults.BAD_NAME code - req.Name isn't a program
+// supervisord knows about.
+const supervisorFaultBadName = 10
+
+// ControlSupervisorProgram starts, stops, or restarts req.Name (any program supervisord manages,
+// not just chromium) and returns its resulting state, replacing the supervisorctl-over-
+// ProcessExec shell-outs scattered across chromium.go/display_layout.go/extensions_crud.go with
+// one typed, race-free endpoint: the caller gets PID/uptime/exit code back instead of having to
+// poll for them separately.
+func (s *ApiService) ControlSupervisorProgram(ctx context.Context, req oapi.ControlSupervisorProgramRequestObject) (oapi.ControlSupervisorProgramResponseObject, error) {
+	log := logger.FromContext(ctx)
+
+	var err error
+	switch req.Action {
+	case oapi.Start:
+		err = s.supervisorClient.StartProcess(ctx, req.Name)
+	case oapi.Stop:
+		err = s.supervisorClient.StopProcess(ctx, req.Name)
+	case oapi.Restart:
+		err = s.supervisorClient.RestartProcess(ctx, req.Name)
+	default:
+		return oapi.ControlSupervisorProgram400JSONResponse{BadRequestErrorJSONResponse: oapi.BadRequestErrorJSONResponse{Message: fmt.Sprintf("unsupported action %q", req.Action)}}, nil
+	}
+	if err != nil {
+		if fault, ok := err.(*supervisorrpc.Fault); ok && fault.Code == supervisorFaultBadName {
+			return oapi.ControlSupervisorProgram404JSONResponse{NotFoundErrorJSONResponse: oapi.NotFoundErrorJSONResponse{Message: fmt.Sprintf("no supervisor program named %q", req.Name)}}, nil
+		}
+		log.Error("supervisor program control failed", "name", req.Name, "action", req.Action, "err", err)
+		return oapi.ControlSupervisorProgram500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	info, err := s.supervisorClient.GetProcessInfo(ctx, req.Name)
+	if err != nil {
+		log.Error("failed to read supervisor program state", "name", req.Name, "err", err)
+		return oapi.ControlSupervisorProgram500JSONResponse{InternalErrorJSONResponse: oapi.InternalErrorJSONResponse{Message: err.Error()}}, nil
+	}
+
+	log.Info("controlled supervisor program", "name", req.Name, "action", req.Action, "state", info.State)
+	return oapi.ControlSupervisorProgram200JSONResponse(supervisorProgramStatus(info)), nil
+}
+
+// GetBrowserReady reports whether Chromium's DevTools endpoint is accepting connections, by
+// dialing it directly rather than making clients spawn a curl subprocess per attempt. It retries
+// with backoff for up to req.Params.TimeoutSeconds (default browserReadyDefaultTimeout) before
+// giving up, so a caller can block on exactly this one request instead of writing their own
+// polling loop.
+func (s *ApiService) GetBrowserReady(ctx context.Context, req oapi.GetBrowserReadyRequestObject) (oapi.GetBrowserReadyResponseObject, error) {
+	timeout := browserReadyDefaultTimeout
+	if req.Params.TimeoutSeconds != nil {
+		timeout = time.Duration(*req.Params.TimeoutSeconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := waitForBrowserReady(ctx); err != nil {
+		return oapi.GetBrowserReady503JSONResponse{ServiceUnavailableErrorJSONResponse: oapi.ServiceUnavailableErrorJSONResponse{Message: err.Error()}}, nil
+	}
+	return oapi.GetBrowserReady200JSONResponse{Ready: true}, nil
+}
+
+// browserReadyDefaultTimeout bounds GetBrowserReady when the caller doesn't specify one.
+const browserReadyDefaultTimeout = 10 * time.Second
+
+// browserReadyURL is Chromium's own CDP HTTP endpoint (see chromiumDevToolsPort), not
+// devtoolsproxy's public-facing 9222 - GetBrowserReady checks the real browser, not the proxy in
+// front of it.
+var browserReadyURL = "http://localhost:" + chromiumDevToolsPort + "/json/version"
+
+// waitForBrowserReady polls browserReadyURL with exponential backoff (capped at 500ms) until it
+// responds 200 or ctx is done.
+func waitForBrowserReady(ctx context.Context) error {
+	client := &http.Client{Timeout: 1 * time.Second}
+	backoff := 50 * time.Millisecond
+	const maxBackoff = 500 * time.Millisecond
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, browserReadyURL, nil)
+		if err == nil {
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusOK {
+					return nil
+				}
+			}
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("chromium devtools endpoint not ready: %w", ctx.Err())
+		case <-timer.C:
+		}
+		if backoff < maxBackoff {
+			backoff *= 2
+		}
+	}
+}
+
+// HandleListSupervisorPrograms handles GET /supervisor/programs: it isn't part of the OpenAPI
+// spec since ControlSupervisorProgram's generated request/response shapes are scoped to one
+// named program, with no generated type for "every program at once".
+func (s *ApiService) HandleListSupervisorPrograms(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	infos, err := s.supervisorClient.GetAllProcessInfo(r.Context())
+	if err != nil {
+		log.Error("failed to list supervisor programs", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	statuses := make([]oapi.SupervisorProgramStatus, len(infos))
+	for i, info := range infos {
+		statuses[i] = supervisorProgramStatus(info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// supervisorLogPollInterval is how often HandleTailSupervisorProgramLog asks supervisord for new
+// log bytes once it has caught up to the end of the buffer.
+const supervisorLogPollInterval = 500 * time.Millisecond
+
+// supervisorLogChunkSize bounds how much of a program's log HandleTailSupervisorProgramLog reads
+// per poll.
+const supervisorLogChunkSize = 64 * 1024
+
+// HandleTailSupervisorProgramLog handles GET /supervisor/programs/{name}/logs: it streams name's
+// captured stdout as Server-Sent Events, starting from the current end of the log and following
+// new output until the client disconnects. Not part of the OpenAPI spec, the same reason the
+// extension upload SSE mode and the playwright NDJSON stream route are raw handlers: a strict
+// handler never gets the raw http.ResponseWriter a stream needs.
+func (s *ApiService) HandleTailSupervisorProgramLog(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	name := chi.URLParam(r, "name")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// offset 0, length 0 returns no data but reports the log's current end, so the stream starts
+	// from "now" (like `tail -f`) rather than replaying the whole captured log.
+	_, offset, _, err := s.supervisorClient.TailProcessStdoutLog(r.Context(), name, 0, 0)
+	if err != nil {
+		log.Error("failed to read supervisor program log", "name", name, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(supervisorLogPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, next, overflow, err := s.supervisorClient.TailProcessStdoutLog(r.Context(), name, offset, supervisorLogChunkSize)
+		if err != nil {
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
+			flusher.Flush()
+			return
+		}
+		offset = next
+		if overflow {
+			fmt.Fprint(w, "event: overflow\ndata: log buffer overflowed, some output was dropped\n\n")
+		}
+		if data != "" {
+			for _, line := range strings.Split(strings.TrimRight(data, "\n"), "\n") {
+				fmt.Fprintf(w, "data: %s\n\n", line)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// loadSupervisorFatalWebhookURL reads SUPERVISOR_FATAL_WEBHOOK_URL, the webhook
+// StartSupervisorFatalWatcher posts to when a supervised program transitions into FATAL. Empty
+// (the default) disables the watcher.
+func loadSupervisorFatalWebhookURL() string {
+	return os.Getenv("SUPERVISOR_FATAL_WEBHOOK_URL")
+}
+
+// supervisorFatalPollInterval is how often StartSupervisorFatalWatcher samples every program's
+// state to detect a new transition into FATAL.
+const supervisorFatalPollInterval = 2 * time.Second
+
+// StartSupervisorFatalWatcher polls every program supervisord manages and POSTs a JSON payload to
+// s.supervisorFatalWebhookURL the moment one transitions into FATAL - out of restarts and not
+// coming back on its own, e.g. chromium crash-looping past supervisord's startretries. It runs
+// until ctx is done. A program already FATAL when this starts is not reported, only new
+// transitions are, so a restart loop doesn't re-notify on every poll. If no webhook URL is
+// configured it returns immediately without starting a goroutine.
+func (s *ApiService) StartSupervisorFatalWatcher(ctx context.Context) {
+	if s.supervisorFatalWebhookURL == "" {
+		return
+	}
+	log := logger.FromContext(ctx)
+
+	go func() {
+		last := make(map[string]supervisorrpc.ProgramState)
+		ticker := time.NewTicker(supervisorFatalPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			infos, err := s.supervisorClient.GetAllProcessInfo(ctx)
+			if err != nil {
+				log.Error("supervisor fatal watcher: failed to list programs", "err", err)
+				continue
+			}
+			for _, info := range infos {
+				prev, seen := last[info.Name]
+				last[info.Name] = info.State
+				if info.State == supervisorrpc.StateFatal && (!seen || prev != supervisorrpc.StateFatal) {
+					s.notifySupervisorFatal(ctx, log, info)
+				}
+			}
+		}
+	}()
+}
+
+// notifySupervisorFatal POSTs info as JSON to s.supervisorFatalWebhookURL. Delivery failures are
+// logged, not retried - the next poll will notice the program is still FATAL only if it flaps
+// back through a non-FATAL state first, so this intentionally doesn't hold up the watcher loop
+// retrying a single stuck webhook.
+func (s *ApiService) notifySupervisorFatal(ctx context.Context, log *slog.Logger, info *supervisorrpc.ProcessInfo) {
+	log.Error("supervisor program entered FATAL state", "name", info.Name, "exit_status", info.ExitStatus, "spawn_error", info.SpawnError)
+
+	payload, err := json.Marshal(map[string]any{
+		"name":        info.Name,
+		"state":       string(info.State),
+		"exit_status": info.ExitStatus,
+		"spawn_error": info.SpawnError,
+	})
+	if err != nil {
+		log.Error("supervisor fatal watcher: failed to encode webhook payload", "name", info.Name, "err", err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.supervisorFatalWebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Error("supervisor fatal watcher: failed to build webhook request", "name", info.Name, "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Error("supervisor fatal watcher: webhook delivery failed", "name", info.Name, "err", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// supervisorProgramStatus converts a supervisorrpc.ProcessInfo into its oapi wire shape.
+func supervisorProgramStatus(info *supervisorrpc.ProcessInfo) oapi.SupervisorProgramStatus {
+	status := oapi.SupervisorProgramStatus{
+		Name:       info.Name,
+		State:      oapi.SupervisorProgramState(info.State),
+		ExitStatus: info.ExitStatus,
+	}
+	if info.Pid != 0 {
+		status.Pid = &info.Pid
+	}
+	if !info.Start.IsZero() {
+		uptime := int(info.Now.Sub(info.Start).Seconds())
+		status.UptimeSeconds = &uptime
+	}
+	return status
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/onkernel/kernel-images/server/lib/logger"
+)
+
+// updateXMLApp is one <app> entry in Chrome's Omaha-protocol update.xml, describing where to
+// fetch one extension's .crx and which version it's currently at.
+type updateXMLApp struct {
+	AppID       string         `xml:"appid,attr"`
+	UpdateCheck updateXMLCheck `xml:"updatecheck"`
+}
+
+type updateXMLCheck struct {
+	Codebase string `xml:"codebase,attr"`
+	Version  string `xml:"version,attr"`
+}
+
+type updateXMLResponse struct {
+	XMLName  xml.Name       `xml:"gupdate"`
+	Xmlns    string         `xml:"xmlns,attr"`
+	Protocol string         `xml:"protocol,attr"`
+	Apps     []updateXMLApp `xml:"app"`
+}
+
+// HandleUpdateXML serves Chrome's enterprise-policy update.xml, covering every installed
+// extension rather than just the first one found on disk (the static file this used to be served
+// from could only ever hold one extension's worth of <app> entries). Each extension's codebase
+// URL points back at this same server's /{name}.crx fallback route, and its version is read live
+// from the extension's own manifest.json - the manifest we persist in extensionmanifest.Manifest
+// doesn't track it.
+func (s *ApiService) HandleUpdateXML(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	entries := s.extensions.List()
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	resp := updateXMLResponse{Xmlns: "http://www.google.com/update2/response", Protocol: "2.0"}
+	for _, e := range entries {
+		cm, err := parseChromeManifest(filepath.Join(extensionsDir, e.Name))
+		if err != nil {
+			log.Warn("skipping extension in update.xml: failed to parse manifest.json", "name", e.Name, "err", err)
+			continue
+		}
+		resp.Apps = append(resp.Apps, updateXMLApp{
+			AppID: e.Name,
+			UpdateCheck: updateXMLCheck{
+				Codebase: fmt.Sprintf("%s://%s/%s.crx", scheme, r.Host, e.Name),
+				Version:  cm.Version,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("failed to encode update.xml", "err", err)
+	}
+}
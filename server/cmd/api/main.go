@@ -10,21 +10,26 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 
 	serverpkg "github.com/onkernel/kernel-images/server"
 	"github.com/onkernel/kernel-images/server/cmd/api/api"
 	"github.com/onkernel/kernel-images/server/cmd/config"
 	"github.com/onkernel/kernel-images/server/lib/devtoolsproxy"
+	"github.com/onkernel/kernel-images/server/lib/exttrust"
 	"github.com/onkernel/kernel-images/server/lib/logger"
 	"github.com/onkernel/kernel-images/server/lib/nekoclient"
 	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/ratelimit"
+	"github.com/onkernel/kernel-images/server/lib/reclaimauth"
 	"github.com/onkernel/kernel-images/server/lib/recorder"
 	"github.com/onkernel/kernel-images/server/lib/scaletozero"
 )
@@ -50,6 +55,7 @@ func main() {
 	stz := scaletozero.NewDebouncedController(scaletozero.NewUnikraftCloudController())
 	r := chi.NewRouter()
 	r.Use(
+		chiMiddleware.RealIP,
 		chiMiddleware.Logger,
 		chiMiddleware.Recoverer,
 		func(next http.Handler) http.Handler {
@@ -88,19 +94,118 @@ func main() {
 		os.Exit(1)
 	}
 
+	trustPolicy, err := exttrust.LoadPolicyFromEnv()
+	if err != nil {
+		slogger.Error("failed to load extension trust policy", "err", err)
+		os.Exit(1)
+	}
+
+	reclaimAuth, err := reclaimauth.LoadAuthenticatorFromEnv()
+	if err != nil {
+		slogger.Error("failed to load reclaim auth policy", "err", err)
+		os.Exit(1)
+	}
+	reclaimAuth.WatchSIGHUP(ctx, slogger)
+
 	apiService, err := api.New(
 		recorder.NewFFmpegManager(),
 		recorder.NewFFmpegRecorderFactory(config.PathToFFmpeg, defaultParams, stz),
 		upstreamMgr,
 		stz,
 		nekoAuthClient,
+		trustPolicy,
+		reclaimAuth,
+		ratelimit.Limits{
+			RPS:         config.RateLimitExecRPS,
+			Burst:       config.RateLimitExecBurst,
+			MaxInFlight: config.RateLimitExecInflight,
+		},
 	)
 	if err != nil {
 		slogger.Error("failed to create api service", "err", err)
 		os.Exit(1)
 	}
 
-	strictHandler := oapi.NewStrictHandler(apiService, nil)
+	// UploadExtensionsAndRestart supports an SSE mode via content negotiation: a client that
+	// sends Accept: text/event-stream against the same route gets a live stream of
+	// upload/unzip/restart progress instead of the oapi strict handler's terminal JSON
+	// response. This has to be intercepted ahead of oapi.HandlerFromMux, since a strict handler
+	// never gets the raw http.ResponseWriter an SSE stream needs.
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && r.URL.Path == "/extensions/upload" &&
+				strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+				apiService.HandleUploadExtensionsSSE(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	// ExecutePlaywrightCode supports an NDJSON streaming mode via content negotiation: a client
+	// that sends Accept: application/x-ndjson against the same route gets a live stream of the
+	// executor's stdout plus a terminal result/error event, instead of the oapi strict handler's
+	// single terminal JSON response. This has to be intercepted ahead of oapi.HandlerFromMux, the
+	// same as the extensions upload SSE mode above, since a strict handler never gets the raw
+	// http.ResponseWriter a stream needs.
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && r.URL.Path == "/playwright/execute" &&
+				strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+				apiService.HandleExecutePlaywrightCodeStream(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	// CancelPlaywrightExecution signals a running ExecutePlaywrightCode/
+	// HandleExecutePlaywrightCodeStream call to abort: not part of the OpenAPI spec since it
+	// targets an execution ID minted outside it.
+	r.Post("/playwright/executions/{id}/cancel", apiService.CancelPlaywrightExecution)
+
+	// Playwright session lifecycle: not part of the OpenAPI spec since it manages long-lived
+	// worker processes keyed by an ID minted outside it, the same reason the execution cancel
+	// route above is registered directly rather than through the strict handler.
+	r.Post("/playwright/sessions", apiService.HandleCreatePlaywrightSession)
+	r.Get("/playwright/sessions", apiService.HandleListPlaywrightSessions)
+	r.Delete("/playwright/sessions/{id}", apiService.HandleDeletePlaywrightSession)
+
+	// Batched computer actions: not part of the OpenAPI spec since per-action partial
+	// success/failure doesn't fit the strict handler's single-typed-response model.
+	r.Post("/computer/actions/batch", apiService.HandleBatchComputerActions)
+
+	// Supervisor program listing and log tailing: not part of the OpenAPI spec since
+	// ControlSupervisorProgram's generated types are scoped to one named program and a strict
+	// handler never gets the raw http.ResponseWriter the log tail's SSE stream needs.
+	r.Get("/supervisor/programs", apiService.HandleListSupervisorPrograms)
+	r.Get("/supervisor/programs/{name}/logs", apiService.HandleTailSupervisorProgramLog)
+	apiService.StartSupervisorFatalWatcher(ctx)
+
+	apiService.StartExecRateLimiterEviction(ctx)
+
+	// Resumable chunked extension upload protocol (tus-style): not part of the OpenAPI spec
+	// since PATCH/HEAD with Content-Range and Upload-Offset headers don't fit the strict
+	// handler's JSON request/response model.
+	r.Post("/extensions/uploads", apiService.HandleCreateUploadSession)
+	r.Patch("/extensions/uploads/{id}", apiService.HandleUploadChunk)
+	r.Head("/extensions/uploads/{id}", apiService.HandleUploadSessionStatus)
+	r.Post("/extensions/uploads/{id}/commit", apiService.HandleCommitUpload)
+
+	// Snapshot restore accepts either a multipart tarball upload or a JSON {"url": "..."} body
+	// on the same route, which doesn't fit the strict handler's one-shape-per-operation model.
+	r.Post("/extensions/snapshots:restore", apiService.HandleRestoreExtensionSnapshot)
+
+	// Resumable chunked filesystem upload protocol (tus-style), the WriteFile counterpart to
+	// the extension upload endpoints above: query-param offset/length chunking doesn't fit the
+	// strict handler's JSON request/response model either.
+	r.Post("/fs/uploads", apiService.HandleCreateFsUpload)
+	r.Put("/fs/uploads/{id}", apiService.HandleUploadFsChunk)
+	r.Get("/fs/uploads/{id}", apiService.HandleFsUploadStatus)
+	r.Post("/fs/uploads/{id}/complete", apiService.HandleCompleteFsUpload)
+	apiService.StartFsUploadJanitor(ctx)
+
+	strictHandler := oapi.NewStrictHandler(apiService, []oapi.StrictMiddlewareFunc{apiService.RateLimitStrictMiddleware()})
 	oapi.HandlerFromMux(strictHandler, r)
 
 	// endpoints to expose the spec
@@ -124,6 +229,17 @@ func main() {
 		apiService.HandleProcessAttach(w, r, id)
 	})
 
+	// GET /reclaim/sessions/{id}/events is a regular oapi strict-handler route (see
+	// StreamReclaimSessionEvents in reclaim_events.go); this is its websocket counterpart. A
+	// websocket upgrade needs to hijack the connection, which a strict handler can't do, so it's
+	// registered directly here instead, the same way the CDP proxy's WebSocketProxyHandler is.
+	r.Get("/reclaim/sessions/{id}/events/ws", apiService.HandleReclaimSessionEventsWS)
+
+	// GET /storage/events is a regular oapi strict-handler route (see StreamStorageEvents in
+	// storage_events.go); this is its websocket counterpart, registered directly here for the
+	// same reason the reclaim events websocket route above is.
+	r.Get("/storage/events/ws", apiService.HandleStorageEventsWS)
+
 	// Serve extension files for Chrome policy-installed extensions
 	// This allows Chrome to download .crx and update.xml files via HTTP
 	extensionsDir := "/home/kernel/extensions"
@@ -133,28 +249,9 @@ func main() {
 		fs.ServeHTTP(w, r)
 	})
 
-	// Serve update.xml at root for Chrome enterprise policy
-	// This serves the first update.xml found in any extension directory
-	r.Get("/update.xml", func(w http.ResponseWriter, r *http.Request) {
-		// Try to find update.xml in the first extension directory
-		entries, err := os.ReadDir(extensionsDir)
-		if err != nil {
-			http.Error(w, "extensions directory not found", http.StatusNotFound)
-			return
-		}
-
-		for _, entry := range entries {
-			if entry.IsDir() {
-				updateXMLPath := fmt.Sprintf("%s/%s/update.xml", extensionsDir, entry.Name())
-				if _, err := os.Stat(updateXMLPath); err == nil {
-					http.ServeFile(w, r, updateXMLPath)
-					return
-				}
-			}
-		}
-
-		http.Error(w, "update.xml not found", http.StatusNotFound)
-	})
+	// Serve update.xml at root for Chrome enterprise policy, covering every installed extension -
+	// see HandleUpdateXML.
+	r.Get("/update.xml", apiService.HandleUpdateXML)
 
 	// Serve CRX files at root for Chrome enterprise policy
 	// This allows simple codebase URLs like http://host:port/extension-name.crx
@@ -220,6 +317,8 @@ func main() {
 			"webSocketDebuggerUrl": proxyWSURL,
 		})
 	})
+	rDevtools.Handle("/healthz", devtoolsproxy.HealthzHandler(upstreamMgr))
+	rDevtools.Handle("/metrics", promhttp.Handler())
 	rDevtools.Get("/*", func(w http.ResponseWriter, r *http.Request) {
 		devtoolsproxy.WebSocketProxyHandler(upstreamMgr, slogger, config.LogCDPMessages, stz).ServeHTTP(w, r)
 	})
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// bisectConfig controls the delta-debugging search performed by bisectFlags.
+type bisectConfig struct {
+	StatePath     string        // where search progress is persisted so it can resume after a crash
+	ReportPath    string        // where the final minimal failing flag set is written
+	ProbeTimeout  time.Duration // how long to wait for the remote-debugging-port to come up
+	ProbeInterval time.Duration // polling interval while waiting for liveness
+	DebuggingPort string        // port probed for liveness (the launcher's --remote-debugging-port)
+}
+
+func defaultBisectConfig(port string) bisectConfig {
+	return bisectConfig{
+		StatePath:     "/var/log/chromium-bisect-state.json",
+		ReportPath:    "/var/log/chromium-bisect-report.json",
+		ProbeTimeout:  5 * time.Second,
+		ProbeInterval: 200 * time.Millisecond,
+		DebuggingPort: port,
+	}
+}
+
+// bisectState is persisted to StatePath so the search can resume after a relaunch crash.
+type bisectState struct {
+	Candidates []string `json:"candidates"` // runtime-derived flags still under suspicion
+	Round      int      `json:"round"`
+}
+
+// bisectReport is the final output of a bisect run, written to ReportPath and stderr.
+type bisectReport struct {
+	MinimalFailingSet []string `json:"minimal_failing_set"`
+	Rounds            int      `json:"rounds"`
+	Method            string   `json:"method"` // "binary-search" or "linear-sweep"
+}
+
+// bisectFlags binary-searches candidates (the runtime flag overlay) to find the minimal
+// subset that, combined with baseFixed, reproduces an unhealthy Chromium launch. It relaunches
+// chromium once per probed subset and classifies the subset as "failing" if the child never
+// becomes healthy within cfg.ProbeTimeout.
+func bisectFlags(chromiumPath string, baseFixed, candidates []string, env []string, cfg bisectConfig) (*bisectReport, error) {
+	state := loadBisectState(cfg.StatePath)
+	if state == nil || len(state.Candidates) == 0 {
+		state = &bisectState{Candidates: candidates}
+	}
+
+	round := state.Round
+	for len(state.Candidates) > 1 {
+		round++
+		mid := len(state.Candidates) / 2
+		left := state.Candidates[:mid]
+		right := state.Candidates[mid:]
+
+		leftFails, err := probeSubset(chromiumPath, baseFixed, left, env, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if leftFails {
+			state.Candidates = left
+			state.Round = round
+			saveBisectState(cfg.StatePath, state)
+			continue
+		}
+
+		rightFails, err := probeSubset(chromiumPath, baseFixed, right, env, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if rightFails {
+			state.Candidates = right
+			state.Round = round
+			saveBisectState(cfg.StatePath, state)
+			continue
+		}
+
+		// Neither half reproduces alone: fall back to a linear sweep to find the
+		// (possibly multi-flag) minimal combination.
+		failing := linearSweep(chromiumPath, baseFixed, state.Candidates, env, cfg)
+		report := &bisectReport{MinimalFailingSet: failing, Rounds: round, Method: "linear-sweep"}
+		return finishBisect(cfg, report)
+	}
+
+	return finishBisect(cfg, &bisectReport{MinimalFailingSet: state.Candidates, Rounds: round, Method: "binary-search"})
+}
+
+// linearSweep drops one candidate flag at a time and keeps it out of the set if doing so
+// still reproduces the failure, converging on a minimal failing combination.
+func linearSweep(chromiumPath string, baseFixed, candidates []string, env []string, cfg bisectConfig) []string {
+	remaining := append([]string{}, candidates...)
+	for i := 0; i < len(remaining); {
+		without := append(append([]string{}, remaining[:i]...), remaining[i+1:]...)
+		fails, err := probeSubset(chromiumPath, baseFixed, without, env, cfg)
+		if err == nil && fails {
+			remaining = without
+			continue
+		}
+		i++
+	}
+	return remaining
+}
+
+// probeSubset relaunches chromium with baseFixed+subset and reports whether it failed to
+// become healthy (TCP connect to the debugging port) within cfg.ProbeTimeout.
+func probeSubset(chromiumPath string, baseFixed, subset []string, env []string, cfg bisectConfig) (bool, error) {
+	args := append(append([]string{}, baseFixed...), subset...)
+	cmd := exec.Command(chromiumPath, args...)
+	cmd.Env = env
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("start chromium for bisect probe: %w", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	healthy := waitHealthy(cfg.DebuggingPort, cfg.ProbeTimeout, cfg.ProbeInterval)
+	return !healthy, nil
+}
+
+// waitHealthy polls a TCP connection to the debugging port until it succeeds or timeout elapses.
+func waitHealthy(port string, timeout, interval time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, interval)
+		if err == nil {
+			_ = conn.Close()
+			return true
+		}
+		time.Sleep(interval)
+	}
+	return false
+}
+
+func finishBisect(cfg bisectConfig, report *bisectReport) (*bisectReport, error) {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(cfg.ReportPath, b, 0o644); err != nil {
+		return nil, fmt.Errorf("write bisect report: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "BISECT_RESULT: %s\n", string(b))
+	_ = os.Remove(cfg.StatePath)
+	return report, nil
+}
+
+func loadBisectState(path string) *bisectState {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var s bisectState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+func saveBisectState(path string, s *bisectState) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(path, b, 0o644)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWaitHealthy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	if !waitHealthy(port, time.Second, 10*time.Millisecond) {
+		t.Fatalf("expected waitHealthy to succeed against a listening port")
+	}
+	if waitHealthy("0", 100*time.Millisecond, 10*time.Millisecond) {
+		t.Fatalf("expected waitHealthy to fail against a closed port")
+	}
+}
+
+func TestLinearSweep(t *testing.T) {
+	// Fake probe: fails (returns true from probeSubset) whenever "--bad" is present in base+subset.
+	// We can't exec real chromium in unit tests, so exercise the pure reduction logic by
+	// hand-rolling the same algorithm used inside linearSweep against a stub predicate.
+	candidates := []string{"--a", "--bad", "--b"}
+	remaining := append([]string{}, candidates...)
+	stillFails := func(flags []string) bool {
+		for _, f := range flags {
+			if f == "--bad" {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < len(remaining); {
+		without := append(append([]string{}, remaining[:i]...), remaining[i+1:]...)
+		if stillFails(without) {
+			remaining = without
+			continue
+		}
+		i++
+	}
+	want := []string{"--bad"}
+	if !reflect.DeepEqual(remaining, want) {
+		t.Fatalf("reduction mismatch: got %#v want %#v", remaining, want)
+	}
+}
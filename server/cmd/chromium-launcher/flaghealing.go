@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// rejectedSwitchPattern matches the stderr Chromium prints when it refuses to start because
+// of an unknown or malformed command-line switch, e.g. "Unknown option: --foo" or
+// "Unknown switch: --foo".
+var rejectedSwitchPattern = regexp.MustCompile(`Unknown (?:option|switch): (--[A-Za-z0-9][A-Za-z0-9_-]*)`)
+
+// droppedFlag records a flag removed by the self-healing retry, including where it came from,
+// so the provenance can be surfaced in the FINAL_FLAGS diagnostic line.
+type droppedFlag struct {
+	Flag       string
+	Provenance string // "base" or "runtime"
+}
+
+// healFlags launches chromium up to maxRetries+1 times, each time probing for a short window
+// to see whether it immediately rejects an unknown switch. On a rejection it removes the
+// offending flag (and any --flag=value variant) from args and retries. It returns the healed
+// argument list and the flags that were dropped along the way.
+func healFlags(chromiumPath string, args []string, env []string, baseNonExt, runtimeNonExt []string, probeWindow time.Duration, maxRetries int) ([]string, []droppedFlag) {
+	var dropped []droppedFlag
+	current := append([]string{}, args...)
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		rejected, stderrTail, fast := probeForRejection(chromiumPath, current, env, probeWindow)
+		if !fast {
+			// Either it's healthy (still running past the probe window) or it died for an
+			// unrelated reason; nothing more we can heal here.
+			return current, dropped
+		}
+		if rejected == "" {
+			fmt.Fprintf(os.Stderr, "chromium exited quickly during flag-healing probe without a recognizable rejected switch, stderr tail: %q\n", stderrTail)
+			return current, dropped
+		}
+
+		before := len(current)
+		provenance := provenanceOf(rejected, baseNonExt, runtimeNonExt)
+		current = removeFlag(current, rejected)
+		if len(current) == before {
+			// Nothing left to remove; avoid looping forever on the same report.
+			return current, dropped
+		}
+		dropped = append(dropped, droppedFlag{Flag: rejected, Provenance: provenance})
+		fmt.Fprintf(os.Stderr, "FLAG_HEALING: dropped %s (source=%s) after chromium rejected it; retrying (%d/%d)\n", rejected, provenance, attempt+1, maxRetries)
+	}
+
+	return current, dropped
+}
+
+// probeForRejection launches chromium with args and waits up to probeWindow for it to exit.
+// If it exits within the window it is considered a "fast" failure and stderr is scanned for a
+// rejected-switch message. If it's still running at the end of the window, it is killed and
+// fast=false is returned (meaning: didn't look like a flag rejection).
+func probeForRejection(chromiumPath string, args []string, env []string, probeWindow time.Duration) (rejected, stderrTail string, fast bool) {
+	var stderrBuf bytes.Buffer
+	cmd := exec.Command(chromiumPath, args...)
+	cmd.Env = env
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Start(); err != nil {
+		return "", "", false
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case <-done:
+		stderrTail = stderrBuf.String()
+		if m := rejectedSwitchPattern.FindStringSubmatch(stderrTail); m != nil {
+			return m[1], stderrTail, true
+		}
+		return "", stderrTail, true
+	case <-time.After(probeWindow):
+		_ = cmd.Process.Kill()
+		<-done
+		return "", "", false
+	}
+}
+
+// provenanceOf reports whether flag came from the base (CHROMIUM_FLAGS) or runtime overlay set.
+func provenanceOf(flagTok string, baseNonExt, runtimeNonExt []string) string {
+	for _, f := range baseNonExt {
+		if f == flagTok || strings.HasPrefix(f, flagTok+"=") {
+			return "base"
+		}
+	}
+	for _, f := range runtimeNonExt {
+		if f == flagTok || strings.HasPrefix(f, flagTok+"=") {
+			return "runtime"
+		}
+	}
+	return "unknown"
+}
+
+// removeFlag strips flagTok and any "flagTok=value" variant from tokens.
+func removeFlag(tokens []string, flagTok string) []string {
+	out := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if t == flagTok || strings.HasPrefix(t, flagTok+"=") {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
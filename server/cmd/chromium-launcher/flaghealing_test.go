@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRejectedSwitchPattern(t *testing.T) {
+	cases := map[string]string{
+		"Unknown option: --foo\n":         "--foo",
+		"[ERROR] Unknown switch: --bar=1": "--bar",
+		"nothing to see here":             "",
+	}
+	for input, want := range cases {
+		m := rejectedSwitchPattern.FindStringSubmatch(input)
+		got := ""
+		if m != nil {
+			got = m[1]
+		}
+		if got != want {
+			t.Fatalf("input %q: got %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRemoveFlag(t *testing.T) {
+	tokens := []string{"--a", "--foo", "--foo=bar", "--b"}
+	got := removeFlag(tokens, "--foo")
+	want := []string{"--a", "--b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("removeFlag mismatch: got %#v want %#v", got, want)
+	}
+}
+
+func TestProvenanceOf(t *testing.T) {
+	base := []string{"--a", "--base-flag=1"}
+	runtime := []string{"--runtime-flag"}
+	if got := provenanceOf("--base-flag", base, runtime); got != "base" {
+		t.Fatalf("expected base, got %q", got)
+	}
+	if got := provenanceOf("--runtime-flag", base, runtime); got != "runtime" {
+		t.Fatalf("expected runtime, got %q", got)
+	}
+	if got := provenanceOf("--missing", base, runtime); got != "unknown" {
+		t.Fatalf("expected unknown, got %q", got)
+	}
+}
@@ -10,65 +10,10 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
-)
-
-// parseFlags splits a space-delimited string of Chromium flags into tokens.
-// Tokens are expected in the form --flag or --flag=value. Quotes are not supported,
-// matching the previous bash implementation which used simple word-splitting.
-func parseFlags(input string) []string {
-	input = strings.TrimSpace(input)
-	if input == "" {
-		return nil
-	}
-	return strings.Fields(input)
-}
-
-// appendCSVInto appends comma-separated values into dst, skipping empty items.
-func appendCSVInto(dst *[]string, csv string) {
-	for _, part := range strings.Split(csv, ",") {
-		if p := strings.TrimSpace(part); p != "" {
-			*dst = append(*dst, p)
-		}
-	}
-}
+	"time"
 
-// parseTokenStream extracts extension-related flags and collects non-extension flags.
-// It returns the list of non-extension tokens and, via references, fills the buckets for
-// --load-extension, --disable-extensions-except and a possible --disable-extensions token for that stream.
-func parseTokenStream(tokens []string, load, except *[]string, disableAll *string) (nonExt []string) {
-	for _, tok := range tokens {
-		switch {
-		case strings.HasPrefix(tok, "--load-extension="):
-			val := strings.TrimPrefix(tok, "--load-extension=")
-			appendCSVInto(load, val)
-		case strings.HasPrefix(tok, "--disable-extensions-except="):
-			val := strings.TrimPrefix(tok, "--disable-extensions-except=")
-			appendCSVInto(except, val)
-		case tok == "--disable-extensions":
-			*disableAll = tok
-		default:
-			nonExt = append(nonExt, tok)
-		}
-	}
-	return nonExt
-}
-
-// union merges two lists of strings, returning a new list with duplicates removed.
-func union(base, rt []string) []string {
-	seen := map[string]struct{}{}
-	out := []string{}
-	for _, v := range append(append([]string{}, base...), rt...) {
-		if v == "" {
-			continue
-		}
-		if _, ok := seen[v]; ok {
-			continue
-		}
-		seen[v] = struct{}{}
-		out = append(out, v)
-	}
-	return out
-}
+	"github.com/onkernel/kernel-images/server/lib/chromiumflags"
+)
 
 // readOptionalFlagFile returns the file contents with newlines collapsed to single spaces.
 // If the file does not exist, it returns an empty string and a nil error.
@@ -97,11 +42,28 @@ func readOptionalFlagFile(path string) (string, error) {
 }
 
 func main() {
+	printVersion := flag.Bool("version", false, "Print the launcher version and exit")
 	headless := flag.Bool("headless", false, "Run Chromium with headless flags")
-	chromiumPath := flag.String("chromium", "chromium", "Chromium binary path (default: chromium)")
-	runtimeFlagsPath := flag.String("runtime-flags", "/chromium/flags", "Path to runtime flags overlay file")
+	chromiumPath := flag.String("chromium", defaultChromiumPath, "Chromium binary path")
+	runtimeFlagsPath := flag.String("runtime-flags", defaultRuntimeFlags, "Path to runtime flags overlay file")
+	supervise := flag.Bool("supervise", strings.EqualFold(strings.TrimSpace(os.Getenv("CHROMIUM_SUPERVISE")), "true") || os.Getenv("CHROMIUM_SUPERVISE") == "1", "Fork+wait Chromium under a supervisor instead of exec-replacing this process")
+	supervisorLog := flag.String("supervisor-log", envOrDefault("CHROMIUM_SUPERVISOR_LOG", defaultSupervisorConfig().LogPath), "Path to append supervisor JSON run records to")
+	maxRestarts := flag.Int("max-restarts", defaultSupervisorConfig().MaxRestarts, "Max restarts allowed within -restart-window before giving up")
+	restartWindow := flag.Duration("restart-window", defaultSupervisorConfig().Window, "Sliding window over which -max-restarts is enforced")
+	bisect := flag.Bool("bisect", false, "Binary-search the runtime flag overlay to find the flag breaking Chromium's startup, instead of launching normally")
+	bisectReportPath := flag.String("bisect-report", defaultBisectConfig("").ReportPath, "Path to write the bisect result report to")
+	healFlagsEnabled := flag.Bool("heal-flags", false, "Drop flags Chromium rejects at startup and retry instead of failing")
+	maxFlagRetries := flag.Int("max-flag-retries", 3, "Max self-healing retries for -heal-flags")
+	flagHealProbe := flag.Duration("flag-heal-probe", 2*time.Second, "How long to wait during -heal-flags to detect a fast rejection")
+	profilesPath := flag.String("profiles-file", defaultProfilesFile, "Path to a structured (YAML) profiles overlay file")
+	profileName := flag.String("profile", os.Getenv("CHROMIUM_PROFILE"), "Named profile to select from -profiles-file (also settable via CHROMIUM_PROFILE)")
 	flag.Parse()
 
+	if *printVersion {
+		fmt.Println(version)
+		return
+	}
+
 	// Inputs
 	internalPort := strings.TrimSpace(os.Getenv("INTERNAL_PORT"))
 	if internalPort == "" {
@@ -114,61 +76,38 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Tokenize
-	baseTokens := parseFlags(baseFlags)
-	runtimeTokens := parseFlags(runtimeFlags)
-
-	// Buckets
-	var (
-		baseNonExt     []string // Non-extension related flags contained in base
-		runtimeNonExt  []string // Non-extension related flags contained in runtime
-		baseLoad       []string // --load-extension flags contained in base
-		baseExcept     []string // --disable-extensions-except flags for base
-		rtLoad         []string // --load-extension flags contained in runtime
-		rtExcept       []string // --disable-extensions-except flags contained in runtime
-		baseDisableAll string   // --disable-extensions flag contained in base
-		rtDisableAll   string   // --disable-extensions flag contained in runtime
-	)
+	// Tokenize (quote-aware: supports single/double quotes and backslash escapes)
+	baseTokens := chromiumflags.ParseFlags(baseFlags)
+	runtimeTokens := chromiumflags.ParseFlags(runtimeFlags)
 
-	baseNonExt = parseTokenStream(baseTokens, &baseLoad, &baseExcept, &baseDisableAll)
-	runtimeNonExt = parseTokenStream(runtimeTokens, &rtLoad, &rtExcept, &rtDisableAll)
-
-	// Merge extension lists
-	mergedLoad := union(baseLoad, rtLoad)
-	mergedExcept := union(baseExcept, rtExcept)
-
-	// Construct final extension-related flags respecting override semantics:
-	// 1) If runtime specifies --disable-extensions, it overrides everything extension related
-	// 2) Else if base specifies --disable-extensions and runtime does NOT specify any --load-extension, keep base disable
-	// 3) Else, build from merged load/except
-	var extFlags []string
-	if rtDisableAll != "" {
-		extFlags = append(extFlags, rtDisableAll)
-	} else {
-		if baseDisableAll != "" && len(rtLoad) == 0 {
-			extFlags = append(extFlags, baseDisableAll)
-		} else if len(mergedLoad) > 0 {
-			extFlags = append(extFlags, "--load-extension="+strings.Join(mergedLoad, ","))
-		}
-		if len(mergedExcept) > 0 {
-			extFlags = append(extFlags, "--disable-extensions-except="+strings.Join(mergedExcept, ","))
-		}
-	}
+	// Buckets, kept around for bisect/heal-flags provenance reporting.
+	baseNonExt, _, _, _ := chromiumflags.SplitExtensions(baseTokens)
+	runtimeNonExt, _, _, _ := chromiumflags.SplitExtensions(runtimeTokens)
+	extFlags := chromiumflags.ExtensionFlags(baseTokens, runtimeTokens)
+
+	final := chromiumflags.MergeFlags(baseTokens, runtimeTokens)
 
-	// Combine and dedupe (preserving first occurrence)
-	combined := append(append([]string{}, baseNonExt...), runtimeNonExt...)
-	combined = append(combined, extFlags...)
-	seen := make(map[string]struct{}, len(combined))
-	final := make([]string, 0, len(combined))
-	for _, tok := range combined {
-		if tok == "" {
-			continue
+	// Layer a named profile on top, if selected. Profiles let operators define reusable
+	// bundles (headless, debug, record, perf, ...) composed via `inherits:` instead of having
+	// to spell every flag out in CHROMIUM_FLAGS or the runtime overlay.
+	var profileEnv map[string]string
+	if *profileName != "" {
+		profileSet, err := chromiumflags.LoadProfileSet(*profilesPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed loading profiles file: %v\n", err)
+			os.Exit(1)
 		}
-		if _, ok := seen[tok]; ok {
-			continue
+		resolved, err := chromiumflags.ResolveProfile(profileSet, *profileName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed resolving profile %q: %v\n", *profileName, err)
+			os.Exit(1)
 		}
-		seen[tok] = struct{}{}
-		final = append(final, tok)
+		profileEnv = resolved.Env
+		profileTokens := resolved.ResolvedTokens()
+		final = chromiumflags.MergeFlags(final, profileTokens)
+		runtimeNonExt, _, _, _ = chromiumflags.SplitExtensions(append(runtimeTokens, profileTokens...))
+		extFlags = chromiumflags.ExtensionFlags(baseTokens, append(runtimeTokens, profileTokens...))
+		fmt.Printf("PROFILE: %s (resolved flags: %s)\n", *profileName, strings.Join(profileTokens, " "))
 	}
 	finalFlagsJoined := strings.Join(final, " ")
 
@@ -197,18 +136,69 @@ func main() {
 		"DISPLAY=:1",
 		"DBUS_SESSION_BUS_ADDRESS=unix:path=/run/dbus/system_bus_socket",
 	)
+	for k, v := range profileEnv {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
 
-	if runAsRoot {
-		// Replace current process with Chromium
+	if *bisect {
+		p, err := execLookPath(*chromiumPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "chromium binary not found: %v\n", err)
+			os.Exit(1)
+		}
+		baseFixed := append(append([]string{}, chromiumArgs[:len(chromiumArgs)-len(final)]...), baseNonExt...)
+		baseFixed = append(baseFixed, extFlags...)
+		cfg := defaultBisectConfig(internalPort)
+		cfg.ReportPath = *bisectReportPath
+		report, err := bisectFlags(p, baseFixed, runtimeNonExt, env, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bisect failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("BISECT_MINIMAL_FAILING_SET: %v\n", report.MinimalFailingSet)
+		return
+	}
+
+	if *healFlagsEnabled {
 		if p, err := execLookPath(*chromiumPath); err == nil {
-			if err := syscall.Exec(p, append([]string{filepath.Base(p)}, chromiumArgs...), env); err != nil {
-				fmt.Fprintf(os.Stderr, "exec chromium failed: %v\n", err)
-				os.Exit(1)
+			healed, dropped := healFlags(p, chromiumArgs, env, baseNonExt, runtimeNonExt, *flagHealProbe, *maxFlagRetries)
+			chromiumArgs = healed
+			if len(dropped) > 0 {
+				names := make([]string, len(dropped))
+				for i, d := range dropped {
+					names[i] = fmt.Sprintf("%s(%s)", d.Flag, d.Provenance)
+				}
+				fmt.Printf("FINAL_FLAGS: %s\n", strings.Join(chromiumArgs, " "))
+				fmt.Printf("DROPPED_FLAGS: %s\n", strings.Join(names, ", "))
 			}
 		} else {
+			fmt.Fprintf(os.Stderr, "heal-flags: chromium binary not found: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if runAsRoot {
+		p, err := execLookPath(*chromiumPath)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "chromium binary not found: %v\n", err)
 			os.Exit(1)
 		}
+		if *supervise {
+			cfg := defaultSupervisorConfig()
+			cfg.LogPath = *supervisorLog
+			cfg.MaxRestarts = *maxRestarts
+			cfg.Window = *restartWindow
+			if err := runSupervised(p, chromiumArgs, env, cfg); err != nil {
+				fmt.Fprintf(os.Stderr, "supervisor: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		// Replace current process with Chromium
+		if err := syscall.Exec(p, append([]string{filepath.Base(p)}, chromiumArgs...), env); err != nil {
+			fmt.Fprintf(os.Stderr, "exec chromium failed: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
@@ -230,6 +220,19 @@ func main() {
 		*chromiumPath,
 	}
 	inner = append(inner, chromiumArgs...)
+
+	if *supervise {
+		cfg := defaultSupervisorConfig()
+		cfg.LogPath = *supervisorLog
+		cfg.MaxRestarts = *maxRestarts
+		cfg.Window = *restartWindow
+		if err := runSupervised(runuserPath, append([]string{"-u", "kernel", "--"}, inner...), env, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "supervisor: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	argv := append([]string{filepath.Base(runuserPath), "-u", "kernel", "--"}, inner...)
 	if err := syscall.Exec(runuserPath, argv, env); err != nil {
 		fmt.Fprintf(os.Stderr, "exec runuser failed: %v\n", err)
@@ -237,6 +240,14 @@ func main() {
 	}
 }
 
+// envOrDefault returns the named environment variable if set and non-empty, else def.
+func envOrDefault(name, def string) string {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v
+	}
+	return def
+}
+
 // execLookPath helps satisfy syscall.Exec's requirement to pass an absolute path.
 func execLookPath(file string) (string, error) {
 	if strings.ContainsRune(file, os.PathSeparator) {
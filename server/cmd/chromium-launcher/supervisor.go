@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// supervisorConfig controls the restart policy used by runSupervised.
+type supervisorConfig struct {
+	LogPath        string        // path to append JSON run records to
+	MaxRestarts    int           // max restarts allowed within Window before giving up
+	Window         time.Duration // sliding window over which MaxRestarts is enforced
+	FastFailWindow time.Duration // child exits faster than this are treated as fatal config errors
+	BackoffBase    time.Duration // initial backoff delay before a restart
+	BackoffMax     time.Duration // backoff delay ceiling
+}
+
+// defaultSupervisorConfig mirrors the constants used elsewhere in the launcher for
+// sane out-of-the-box behavior when the operator hasn't tuned anything.
+func defaultSupervisorConfig() supervisorConfig {
+	return supervisorConfig{
+		LogPath:        defaultSupervisorLog,
+		MaxRestarts:    5,
+		Window:         10 * time.Minute,
+		FastFailWindow: 3 * time.Second,
+		BackoffBase:    500 * time.Millisecond,
+		BackoffMax:     30 * time.Second,
+	}
+}
+
+// runRecord is the structured JSON record written to LogPath after each run.
+type runRecord struct {
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	WallTimeMs int64     `json:"wall_time_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Signal     string    `json:"signal,omitempty"`
+	UserCPUMs  int64     `json:"user_cpu_ms"`
+	SysCPUMs   int64     `json:"sys_cpu_ms"`
+	MaxRSSKB   int64     `json:"maxrss_kb"`
+	PageFaults int64     `json:"page_faults"`
+	Flags      []string  `json:"flags"`
+	Attempt    int       `json:"attempt"`
+	FastFail   bool      `json:"fast_fail"`
+}
+
+// runSupervised fork+waits the chromium binary at path with args/env repeatedly,
+// applying a crash-restart policy instead of exec-replacing the launcher process.
+// It returns once the child exits cleanly (code 0) or the restart policy gives up.
+func runSupervised(path string, args []string, env []string, cfg supervisorConfig) error {
+	logf, err := os.OpenFile(cfg.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open supervisor log: %w", err)
+	}
+	defer logf.Close()
+
+	var restarts []time.Time
+	attempt := 0
+	for {
+		attempt++
+		started := time.Now()
+		cmd := exec.Command(path, args...)
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("start chromium: %w", err)
+		}
+		waitErr := cmd.Wait()
+		ended := time.Now()
+		wallTime := ended.Sub(started)
+
+		rec := runRecord{
+			StartedAt:  started,
+			EndedAt:    ended,
+			WallTimeMs: wallTime.Milliseconds(),
+			Flags:      args,
+			Attempt:    attempt,
+			FastFail:   wallTime < cfg.FastFailWindow,
+		}
+		if state := cmd.ProcessState; state != nil {
+			rec.ExitCode = state.ExitCode()
+			if rusage, ok := state.SysUsage().(*syscall.Rusage); ok && rusage != nil {
+				rec.UserCPUMs = rusage.Utime.Sec*1000 + int64(rusage.Utime.Usec)/1000
+				rec.SysCPUMs = rusage.Stime.Sec*1000 + int64(rusage.Stime.Usec)/1000
+				rec.MaxRSSKB = rusage.Maxrss
+				rec.PageFaults = rusage.Majflt + rusage.Minflt
+			}
+			if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+				rec.Signal = ws.Signal().String()
+			}
+		}
+		if err := writeRunRecord(logf, rec); err != nil {
+			fmt.Fprintf(os.Stderr, "failed writing supervisor record: %v\n", err)
+		}
+
+		if waitErr == nil && rec.ExitCode == 0 {
+			return nil
+		}
+
+		if rec.FastFail {
+			return fmt.Errorf("chromium exited after %s (attempt %d): fatal config error, not restarting", wallTime, attempt)
+		}
+
+		now := time.Now()
+		restarts = append(restarts, now)
+		restarts = pruneOutsideWindow(restarts, now, cfg.Window)
+		if len(restarts) > cfg.MaxRestarts {
+			return fmt.Errorf("exceeded %d restarts within %s, giving up after attempt %d", cfg.MaxRestarts, cfg.Window, attempt)
+		}
+
+		delay := backoffDelay(cfg.BackoffBase, cfg.BackoffMax, len(restarts))
+		fmt.Fprintf(os.Stderr, "chromium exited (attempt %d, exit=%d, signal=%s); restarting in %s\n", attempt, rec.ExitCode, rec.Signal, delay)
+		time.Sleep(delay)
+	}
+}
+
+// pruneOutsideWindow drops timestamps older than window relative to now.
+func pruneOutsideWindow(ts []time.Time, now time.Time, window time.Duration) []time.Time {
+	out := ts[:0]
+	for _, t := range ts {
+		if now.Sub(t) <= window {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// backoffDelay returns an exponential backoff delay for the given restart count, capped at max.
+func backoffDelay(base, max time.Duration, restartCount int) time.Duration {
+	if restartCount < 1 {
+		restartCount = 1
+	}
+	d := base << (restartCount - 1)
+	if d <= 0 || d > max {
+		return max
+	}
+	return d
+}
+
+func writeRunRecord(w *os.File, rec runRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,15 @@
+package main
+
+// These are build-time defaults, overridden via:
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.defaultChromiumPath=/opt/chromium/chrome"
+//
+// so a given image build can bake in the right binary path/runtime-flags location without
+// requiring every caller to pass them as flags or env vars.
+var (
+	version              = "dev"
+	defaultChromiumPath  = "chromium"
+	defaultRuntimeFlags  = "/chromium/flags"
+	defaultProfilesFile  = "/chromium/profiles.yaml"
+	defaultSupervisorLog = "/var/log/chromium-supervisor.jsonl"
+)
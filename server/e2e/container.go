@@ -1,11 +1,12 @@
 package e2e
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
-	"os/exec"
 	"strings"
 	"testing"
 	"time"
@@ -14,28 +15,35 @@ import (
 	instanceoapi "github.com/onkernel/kernel-images/server/lib/oapi"
 )
 
-// TestContainer manages a Docker container with dynamically allocated ports.
-// This enables parallel test execution by giving each test its own ports.
+// TestContainer manages a container, run through a pluggable Runtime (docker/podman/nerdctl), with
+// dynamically allocated ports. This enables parallel test execution by giving each test its own
+// ports.
 type TestContainer struct {
-	tb      testing.TB // supports both *testing.T and *testing.B
-	Name    string
-	Image   string
-	APIPort int // dynamically allocated host port -> container 10001
-	CDPPort int // dynamically allocated host port -> container 9222
-	cmd     *exec.Cmd
-	exitCh  <-chan error
-	ctx     context.Context
+	tb        testing.TB // supports both *testing.T and *testing.B
+	Name      string
+	Image     string
+	APIPort   int // dynamically allocated host port -> container 10001
+	CDPPort   int // dynamically allocated host port -> container 9222
+	runtime   Runtime
+	handle    Handle
+	exitCh    <-chan error
+	ctx       context.Context
+	logStream io.ReadCloser // open Logs attachment, closed by cleanup
 }
 
 // ContainerConfig holds optional configuration for container startup.
 type ContainerConfig struct {
 	Env        map[string]string
-	HostAccess bool // Add host.docker.internal mapping
+	HostAccess bool              // Add host.docker.internal mapping
+	StreamLogs bool              // forward container stdout/stderr to tb.Log via StreamLogs
+	Volumes    map[string]string // host path -> container path bind mounts
 }
 
-// NewTestContainer creates a new test container with dynamically allocated ports.
-// Works with both *testing.T and *testing.B (any testing.TB).
-func NewTestContainer(tb testing.TB, image string) *TestContainer {
+// NewTestContainer creates a new test container with dynamically allocated ports. Works with both
+// *testing.T and *testing.B (any testing.TB). An explicit runtime can be passed for tests that
+// need to target one specifically; otherwise it's chosen by KERNEL_TEST_RUNTIME or by probing
+// docker, podman, and nerdctl on PATH in that order (see detectRuntime).
+func NewTestContainer(tb testing.TB, image string, runtime ...Runtime) *TestContainer {
 	tb.Helper()
 
 	apiPort, err := findFreePort()
@@ -51,12 +59,23 @@ func NewTestContainer(tb testing.TB, image string) *TestContainer {
 	// Generate unique container name based on test name
 	name := fmt.Sprintf("e2e-%s-%d", sanitizeTestName(tb.Name()), apiPort)
 
+	var rt Runtime
+	if len(runtime) > 0 {
+		rt = runtime[0]
+	} else {
+		rt, err = detectRuntime()
+		if err != nil {
+			tb.Fatalf("failed to detect container runtime: %v", err)
+		}
+	}
+
 	return &TestContainer{
 		tb:      tb,
 		Name:    name,
 		Image:   image,
 		APIPort: apiPort,
 		CDPPort: cdpPort,
+		runtime: rt,
 	}
 }
 
@@ -91,19 +110,6 @@ func (c *TestContainer) Start(ctx context.Context, cfg ContainerConfig) error {
 	// Clean up any existing container with this name
 	_ = c.cleanup(ctx)
 
-	args := []string{
-		"run",
-		"--name", c.Name,
-		"--privileged",
-		"-p", fmt.Sprintf("%d:10001", c.APIPort),
-		"-p", fmt.Sprintf("%d:9222", c.CDPPort),
-		"--tmpfs", "/dev/shm:size=2g,mode=1777",
-	}
-
-	if cfg.HostAccess {
-		args = append(args, "--add-host=host.docker.internal:host-gateway")
-	}
-
 	// Add environment variables
 	// Ensure CHROMIUM_FLAGS includes --no-sandbox for CI
 	envCopy := make(map[string]string)
@@ -116,25 +122,73 @@ func (c *TestContainer) Start(ctx context.Context, cfg ContainerConfig) error {
 		envCopy["CHROMIUM_FLAGS"] = envCopy["CHROMIUM_FLAGS"] + " --no-sandbox"
 	}
 
-	for k, v := range envCopy {
-		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+	spec := RunSpec{
+		Name:         c.Name,
+		Image:        c.Image,
+		Env:          envCopy,
+		Privileged:   true,
+		PortBindings: map[int]int{10001: c.APIPort, 9222: c.CDPPort},
+		Tmpfs:        map[string]string{"/dev/shm": "size=2g,mode=1777"},
+		Volumes:      cfg.Volumes,
+		HostAccess:   cfg.HostAccess,
 	}
-	args = append(args, c.Image)
 
-	logger.Info("[docker]", "action", "run", "container", c.Name, "apiPort", c.APIPort, "cdpPort", c.CDPPort)
+	logger.Info("[runtime]", "runtime", c.runtime.Name(), "action", "run", "container", c.Name, "apiPort", c.APIPort, "cdpPort", c.CDPPort)
 
-	c.cmd = exec.CommandContext(ctx, "docker", args...)
-	if err := c.cmd.Start(); err != nil {
+	handle, err := c.runtime.Run(ctx, spec)
+	if err != nil {
 		return fmt.Errorf("failed to start container: %w", err)
 	}
+	c.handle = handle
 
-	// Create exit channel to detect container crashes
+	// Create exit channel to detect container crashes, mirroring exec.Cmd.Wait's contract: nil
+	// on a clean exit, a non-nil error (carrying the exit code) otherwise.
 	exitCh := make(chan error, 1)
 	go func() {
-		exitCh <- c.cmd.Wait()
+		code, err := c.runtime.Wait(ctx, handle)
+		if err != nil {
+			exitCh <- fmt.Errorf("container wait failed: %w", err)
+			return
+		}
+		if code != 0 {
+			exitCh <- fmt.Errorf("container exited with code %d", code)
+			return
+		}
+		exitCh <- nil
 	}()
 	c.exitCh = exitCh
 
+	if cfg.StreamLogs {
+		if err := c.StreamLogs(ctx, c.tb); err != nil {
+			logger.Warn("failed to attach container log stream", "container", c.Name, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// StreamLogs attaches to the container's stdout/stderr and forwards each line to tb.Log, prefixed
+// with the container name so interleaved output from parallel subtests stays attributable. It's
+// called automatically from Start when ContainerConfig.StreamLogs is set, but can also be called
+// directly (e.g. to start streaming only once a test is about to assert on container behavior).
+// The stream shuts down when ctx is canceled or Stop is called.
+func (c *TestContainer) StreamLogs(ctx context.Context, tb testing.TB) error {
+	tb.Helper()
+
+	out, err := c.runtime.Logs(ctx, c.handle, true)
+	if err != nil {
+		return fmt.Errorf("failed to attach to container logs: %w", err)
+	}
+	c.logStream = out
+
+	go func() {
+		scanner := bufio.NewScanner(out)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			tb.Log(fmt.Sprintf("[%s] %s", c.Name, scanner.Text()))
+		}
+	}()
+
 	return nil
 }
 
@@ -145,13 +199,37 @@ func (c *TestContainer) Stop(ctx context.Context) error {
 
 // cleanup removes the container if it exists.
 func (c *TestContainer) cleanup(ctx context.Context) error {
-	// Kill the container
-	killCmd := exec.CommandContext(ctx, "docker", "kill", c.Name)
-	_ = killCmd.Run() // Ignore errors - container may not exist
+	if c.logStream != nil {
+		c.logStream.Close()
+		c.logStream = nil
+	}
+	if c.runtime == nil {
+		return nil
+	}
+	h := c.handle
+	if h.Name == "" {
+		h = Handle{Name: c.Name}
+	}
+	return c.runtime.Remove(ctx, h)
+}
 
-	// Remove the container
-	rmCmd := exec.CommandContext(ctx, "docker", "rm", "-f", c.Name)
-	return rmCmd.Run()
+// Inspect returns the container's current state, so a test can assert on OOMKilled, ExitCode, or
+// health status instead of guessing at why a container stopped. Only runtimes implementing
+// Inspector support this (DockerRuntime today); Podman/nerdctl return an error.
+func (c *TestContainer) Inspect(ctx context.Context) (ContainerJSON, error) {
+	inspector, ok := c.runtime.(Inspector)
+	if !ok {
+		return ContainerJSON{}, fmt.Errorf("runtime %q does not support Inspect", c.runtime.Name())
+	}
+	raw, err := inspector.Inspect(ctx, c.handle)
+	if err != nil {
+		return ContainerJSON{}, err
+	}
+	info, ok := raw.(ContainerJSON)
+	if !ok {
+		return ContainerJSON{}, fmt.Errorf("runtime %q returned unexpected inspect type %T", c.runtime.Name(), raw)
+	}
+	return info, nil
 }
 
 // APIBaseURL returns the URL for the container's API server.
@@ -33,6 +33,7 @@ import (
 
 	logctx "github.com/onkernel/kernel-images/server/lib/logger"
 	instanceoapi "github.com/onkernel/kernel-images/server/lib/oapi"
+	"github.com/onkernel/kernel-images/server/lib/storagewatch"
 	"github.com/stretchr/testify/require"
 )
 
@@ -220,6 +221,18 @@ func (s *testCookieServer) URL() string {
 	return fmt.Sprintf("http://host.docker.internal:%d", s.port)
 }
 
+// waitForStorageQuiescent blocks until 1 second has passed with no storage mutation observed for
+// origin, via storagewatch.WaitForStorageQuiescent over the container's devtools CDP connection.
+const storageQuiescenceIdle = 1 * time.Second
+
+func waitForStorageQuiescent(t *testing.T, ctx context.Context, origin string) {
+	t.Helper()
+	wsURL, err := waitDevtoolsWS(ctx)
+	require.NoError(t, err, "devtools websocket not reachable")
+	require.NoError(t, storagewatch.WaitForStorageQuiescent(ctx, wsURL, []string{origin}, storageQuiescenceIdle),
+		"failed waiting for storage to quiesce")
+}
+
 
 // TestCookiePersistenceHeadless tests that cookies persist across container restarts for headless image
 func TestCookiePersistenceHeadless(t *testing.T) {
@@ -279,9 +292,12 @@ func testCookiePersistence(t *testing.T, image, name string) {
 	cookies = getCookiesViaPlaywright(t, ctx, client, testServer.URL()+"/get-cookie", logger)
 	require.Equal(t, testCookieValue, cookies[testCookieName], "expected cookie %s=%s, got: %v", testCookieName, testCookieValue, cookies)
 
-	// Step 4: Wait for cookies to flush to disk (1-2 seconds with patched Chromium)
+	// Step 4: Wait for the cookie to flush to disk. Watching for storage mutations to go quiet
+	// is more reliable than a fixed sleep: it resolves as soon as our patched Chromium's ~1s
+	// commit interval has actually elapsed with nothing further happening, instead of gambling
+	// on 3 seconds always being enough.
 	logger.Info("[test]", "phase", "1", "action", "waiting for cookie flush to disk")
-	time.Sleep(3 * time.Second)
+	waitForStorageQuiescent(t, ctx, testServer.URL())
 
 	// Step 5: Download user-data directory
 	logger.Info("[test]", "phase", "1", "action", "downloading user-data directory")
@@ -694,6 +710,19 @@ func setIndexedDBViaPlaywright(t *testing.T, ctx context.Context, client *instan
 	}
 }
 
+// setStorageState calls POST /v1/browser/storage-state to seed the running container with a
+// known auth state (cookies plus per-origin localStorage), without navigating any pages first.
+// This is the convenience wrapper server/lib/storagestate's GetStorageState/SetStorageState
+// endpoints ask callers of the generated SDK to have; it lives here rather than in
+// server/lib/oapi itself since that package is entirely oapi-codegen output and isn't checked
+// into this repo.
+func setStorageState(t *testing.T, ctx context.Context, client *instanceoapi.ClientWithResponses, state instanceoapi.StorageState) {
+	t.Helper()
+	rsp, err := client.SetStorageStateWithResponse(ctx, state)
+	require.NoError(t, err, "set storage state request error")
+	require.Equal(t, http.StatusNoContent, rsp.StatusCode(), "unexpected status: %s", rsp.Status())
+}
+
 // downloadUserDataDir downloads the user-data directory as a zip
 func downloadUserDataDir(t *testing.T, ctx context.Context, client *instanceoapi.ClientWithResponses, logger *slog.Logger) []byte {
 	params := &instanceoapi.DownloadDirZipParams{
@@ -724,51 +753,22 @@ func logZipContents(t *testing.T, zipData []byte, logger *slog.Logger) {
 	logger.Info("[zip]", "contents", strings.Join(files, ", "))
 }
 
-// restoreUserDataDir uploads and extracts user-data directory from a zip
+// restoreUserDataDir uploads and extracts user-data directory from a zip via the native
+// UploadAndExtractZip endpoint, replacing the old WriteFile-to-/tmp-then-ProcessExec("unzip")
+// dance: one request now both stages and extracts the archive, and entries are validated against
+// path traversal server-side instead of trusting whatever `unzip` was handed.
 func restoreUserDataDir(t *testing.T, ctx context.Context, client *instanceoapi.ClientWithResponses, zipData []byte, logger *slog.Logger) {
-	// First, we need to extract the zip and upload files individually
-	// The API has WriteFile but not a direct "upload zip and extract" endpoint
-	// We'll use ProcessExec to extract after uploading
-
-	// Upload the zip file to a temp location
-	zipPath := "/tmp/user-data-restore.zip"
-	params := &instanceoapi.WriteFileParams{
-		Path: zipPath,
+	overwrite := "replace"
+	params := &instanceoapi.UploadAndExtractZipParams{
+		Target:    "/home/kernel/user-data",
+		Overwrite: &overwrite,
 	}
 
-	rsp, err := client.WriteFileWithBodyWithResponse(ctx, params, "application/octet-stream", bytes.NewReader(zipData))
-	require.NoError(t, err, "write file request error")
-	require.Equal(t, http.StatusCreated, rsp.StatusCode(), "unexpected status: %s body=%s", rsp.Status(), string(rsp.Body))
-
-	logger.Info("[restore]", "action", "uploaded zip", "path", zipPath)
-
-	// Extract the zip using unzip command
-	args := []string{"-o", zipPath, "-d", "/home/kernel/user-data"}
-	req := instanceoapi.ProcessExecJSONRequestBody{
-		Command: "unzip",
-		Args:    &args,
-	}
-
-	execRsp, err := client.ProcessExecWithResponse(ctx, req)
-	require.NoError(t, err, "process exec request error")
-	require.Equal(t, http.StatusOK, execRsp.StatusCode(), "unexpected status: %s body=%s", execRsp.Status(), string(execRsp.Body))
-
-	if execRsp.JSON200.ExitCode != nil && *execRsp.JSON200.ExitCode != 0 {
-		var stdout, stderr string
-		if execRsp.JSON200.StdoutB64 != nil {
-			if b, decErr := base64.StdEncoding.DecodeString(*execRsp.JSON200.StdoutB64); decErr == nil {
-				stdout = string(b)
-			}
-		}
-		if execRsp.JSON200.StderrB64 != nil {
-			if b, decErr := base64.StdEncoding.DecodeString(*execRsp.JSON200.StderrB64); decErr == nil {
-				stderr = string(b)
-			}
-		}
-		require.Fail(t, "unzip failed", "exit_code=%d stdout=%s stderr=%s", *execRsp.JSON200.ExitCode, stdout, stderr)
-	}
+	rsp, err := client.UploadAndExtractZipWithBodyWithResponse(ctx, params, "application/octet-stream", bytes.NewReader(zipData))
+	require.NoError(t, err, "upload-and-extract zip request error")
+	require.Equal(t, http.StatusOK, rsp.StatusCode(), "unexpected status: %s body=%s", rsp.Status(), string(rsp.Body))
 
-	logger.Info("[restore]", "action", "extracted zip to user-data")
+	logger.Info("[restore]", "action", "extracted zip to user-data", "num_files", len(rsp.JSON200.ExtractedFiles))
 
 	// Remove lock files that prevent Chromium from starting with restored profile
 	lockFiles := []string{
@@ -0,0 +1,76 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestChromiumPersistenceViaVolume exercises user-data persistence through a bind-mounted host
+// directory instead of the zip download/upload round trip runChromiumPersistenceFlow uses. It
+// gives a regression signal for the Chromium profile's on-disk format itself, independent of the
+// zip upload/download code path (which TestChromiumHeadful/HeadlessPersistence keep covering).
+func TestChromiumPersistenceViaVolume(t *testing.T) {
+	if _, err := detectRuntime(); err != nil {
+		t.Skipf("no container runtime available: %v", err)
+	}
+
+	userDataDir := t.TempDir()
+	cfg := ContainerConfig{
+		Env: map[string]string{
+			"WITH_KERNEL_IMAGES_API": "true",
+			"WITH_DOCKER":            "true",
+			"CHROMIUM_FLAGS":         "--no-sandbox --disable-dev-shm-usage --disable-gpu --start-maximized --disable-software-rasterizer --remote-allow-origins=* --no-zygote --user-data-dir=/home/kernel/user-data",
+		},
+		Volumes: map[string]string{userDataDir: "/home/kernel/user-data"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	c1 := NewTestContainer(t, headlessImage)
+	if err := c1.Start(ctx, cfg); err != nil {
+		t.Fatalf("failed to start first container: %v", err)
+	}
+	if err := c1.WaitReady(ctx); err != nil {
+		t.Fatalf("api not ready: %v", err)
+	}
+	if err := c1.WaitDevTools(ctx); err != nil {
+		t.Fatalf("devtools not ready: %v", err)
+	}
+
+	cookieName := "ki_e2e_volume_cookie"
+	cookieValue := fmt.Sprintf("v_%d", time.Now().UnixNano())
+	if err := setCookieViaDevtools(ctx, c1.CDPURL()+"devtools/browser", cookieName, cookieValue); err != nil {
+		t.Fatalf("failed to set cookie: %v", err)
+	}
+
+	// Give the patched Chromium's faster cookie flush (see e2e_persist_login_test.go) a moment to
+	// hit disk before we stop the container.
+	time.Sleep(2 * time.Second)
+
+	if err := c1.Stop(ctx); err != nil {
+		t.Fatalf("failed to stop first container: %v", err)
+	}
+
+	c2 := NewTestContainer(t, headlessImage)
+	if err := c2.Start(ctx, cfg); err != nil {
+		t.Fatalf("failed to start second container: %v", err)
+	}
+	defer c2.Stop(ctx)
+	if err := c2.WaitReady(ctx); err != nil {
+		t.Fatalf("api not ready on second container: %v", err)
+	}
+	if err := c2.WaitDevTools(ctx); err != nil {
+		t.Fatalf("devtools not ready on second container: %v", err)
+	}
+
+	got, err := getCookieViaDevtools(ctx, c2.CDPURL()+"devtools/browser", cookieName)
+	if err != nil {
+		t.Fatalf("failed to read cookie: %v", err)
+	}
+	if got != cookieValue {
+		t.Fatalf("cookie mismatch after volume-backed restart: got %q want %q", got, cookieValue)
+	}
+}
@@ -0,0 +1,263 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// This file defines the Runtime abstraction TestContainer drives instead of hardcoding the
+// `docker` binary, so the Chromium persistence tests also run on Podman-only or
+// nerdctl/containerd-only dev machines and CI images. DockerRuntime (runtime_docker.go) talks to
+// the Engine API directly; PodmanRuntime/NerdctlRuntime shell out to their CLIs, since neither
+// ships a comparably stable Go client for this repo to depend on.
+
+// RunSpec describes the container a Runtime should start. PortBindings maps container port to
+// host port; Tmpfs maps a container path to its mount options in docker's "size=2g,mode=1777"
+// syntax (each Runtime translates that into whatever its own CLI/API expects).
+type RunSpec struct {
+	Name         string
+	Image        string
+	Env          map[string]string
+	Privileged   bool
+	PortBindings map[int]int
+	Tmpfs        map[string]string
+	Volumes      map[string]string // host path -> container path bind mounts
+	HostAccess   bool              // add a host.docker.internal mapping reachable from inside the container
+}
+
+// Handle identifies a container started by a Runtime. DockerRuntime sets ID from ContainerCreate;
+// the CLI-based runtimes record the ID that `run -d` prints but operate on the Name they passed
+// to `run --name`.
+type Handle struct {
+	ID   string
+	Name string
+}
+
+// Runtime abstracts the container engine TestContainer drives.
+type Runtime interface {
+	// Name identifies the runtime for logging ("docker", "podman", "nerdctl").
+	Name() string
+	Run(ctx context.Context, spec RunSpec) (Handle, error)
+	Kill(ctx context.Context, h Handle) error
+	Remove(ctx context.Context, h Handle) error
+	Exec(ctx context.Context, h Handle, cmd []string) ([]byte, error)
+	// Logs returns the container's demultiplexed stdout+stderr as plain bytes, following if
+	// requested. The caller must Close it.
+	Logs(ctx context.Context, h Handle, follow bool) (io.ReadCloser, error)
+	// Wait blocks until the container exits and reports its exit code.
+	Wait(ctx context.Context, h Handle) (int64, error)
+	// Commit snapshots a container's filesystem into a new image tagged tag, applying labels to
+	// it (used by SnapshotFixture to record the base image digest it was built from).
+	Commit(ctx context.Context, h Handle, tag string, labels map[string]string) error
+	// ImageID returns a stable identifier for the given image reference (its content digest),
+	// or an error if the image doesn't exist locally.
+	ImageID(ctx context.Context, image string) (string, error)
+	// RemoveImage deletes a local image by reference. Unlike Remove, it's not an error for the
+	// image to already be gone.
+	RemoveImage(ctx context.Context, image string) error
+}
+
+// Inspector is implemented by runtimes that can report typed container state. Only DockerRuntime
+// does today - Podman's and nerdctl's CLIs only expose this as their own JSON shape, which isn't
+// worth parsing into types.ContainerJSON for a best-effort debugging aid.
+type Inspector interface {
+	Inspect(ctx context.Context, h Handle) (any, error)
+}
+
+// detectRuntime picks a Runtime per KERNEL_TEST_RUNTIME if set, otherwise probes docker, podman,
+// and nerdctl on PATH in that order.
+func detectRuntime() (Runtime, error) {
+	if name := os.Getenv("KERNEL_TEST_RUNTIME"); name != "" {
+		return newRuntime(name)
+	}
+	for _, name := range []string{"docker", "podman", "nerdctl"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return newRuntime(name)
+		}
+	}
+	return nil, fmt.Errorf("no container runtime found on PATH (looked for docker, podman, nerdctl)")
+}
+
+func newRuntime(name string) (Runtime, error) {
+	switch name {
+	case "docker":
+		return NewDockerRuntime()
+	case "podman":
+		return NewPodmanRuntime(), nil
+	case "nerdctl":
+		return NewNerdctlRuntime(), nil
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q (want docker, podman, or nerdctl)", name)
+	}
+}
+
+// cliRuntime implements Runtime by shelling out to a docker-CLI-compatible binary. hostAccess
+// and tmpfsFlag are set per runtime where their flag syntax diverges from docker's.
+type cliRuntime struct {
+	bin        string
+	name       string
+	hostAccess func() []string
+	tmpfsFlag  func(path, opts string) []string
+}
+
+// NewPodmanRuntime returns a Runtime that drives containers via the podman CLI.
+func NewPodmanRuntime() *cliRuntime {
+	return &cliRuntime{
+		bin:  "podman",
+		name: "podman",
+		hostAccess: func() []string {
+			// Podman's rootless slirp4netns network doesn't route host.docker.internal back to
+			// the host loopback interface without explicitly allowing it.
+			return []string{
+				"--add-host=host.docker.internal:host-gateway",
+				"--network", "slirp4netns:allow_host_loopback=true",
+			}
+		},
+		tmpfsFlag: func(path, opts string) []string {
+			// Podman accepts --tmpfs, but its --mount form needs comma-separated key=value
+			// pairs with a "tmpfs-" prefix rather than docker's bare "size=.../mode=..." keys.
+			var mountOpts []string
+			for _, kv := range strings.Split(opts, ",") {
+				k, v, ok := strings.Cut(kv, "=")
+				if !ok {
+					continue
+				}
+				mountOpts = append(mountOpts, fmt.Sprintf("tmpfs-%s=%s", k, v))
+			}
+			mount := fmt.Sprintf("type=tmpfs,destination=%s", path)
+			if len(mountOpts) > 0 {
+				mount += "," + strings.Join(mountOpts, ",")
+			}
+			return []string{"--mount", mount}
+		},
+	}
+}
+
+// NewNerdctlRuntime returns a Runtime that drives containers via the nerdctl CLI (rootless containerd).
+func NewNerdctlRuntime() *cliRuntime {
+	return &cliRuntime{
+		bin:  "nerdctl",
+		name: "nerdctl",
+		hostAccess: func() []string {
+			return []string{"--add-host=host.docker.internal:host-gateway"}
+		},
+		tmpfsFlag: func(path, opts string) []string {
+			// nerdctl is docker-CLI compatible for --tmpfs.
+			return []string{"--tmpfs", fmt.Sprintf("%s:%s", path, opts)}
+		},
+	}
+}
+
+func (r *cliRuntime) Name() string { return r.name }
+
+func (r *cliRuntime) Run(ctx context.Context, spec RunSpec) (Handle, error) {
+	args := []string{"run", "-d", "--name", spec.Name}
+	if spec.Privileged {
+		args = append(args, "--privileged")
+	}
+	for containerPort, hostPort := range spec.PortBindings {
+		args = append(args, "-p", fmt.Sprintf("%d:%d", hostPort, containerPort))
+	}
+	for path, opts := range spec.Tmpfs {
+		args = append(args, r.tmpfsFlag(path, opts)...)
+	}
+	for hostPath, containerPath := range spec.Volumes {
+		args = append(args, "-v", fmt.Sprintf("%s:%s", hostPath, containerPath))
+	}
+	if spec.HostAccess {
+		args = append(args, r.hostAccess()...)
+	}
+	for k, v := range spec.Env {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+	}
+	args = append(args, spec.Image)
+
+	out, err := exec.CommandContext(ctx, r.bin, args...).Output()
+	if err != nil {
+		return Handle{}, fmt.Errorf("%s run: %w", r.bin, err)
+	}
+	return Handle{ID: strings.TrimSpace(string(out)), Name: spec.Name}, nil
+}
+
+func (r *cliRuntime) Kill(ctx context.Context, h Handle) error {
+	return exec.CommandContext(ctx, r.bin, "kill", h.Name).Run()
+}
+
+func (r *cliRuntime) Remove(ctx context.Context, h Handle) error {
+	return exec.CommandContext(ctx, r.bin, "rm", "-f", h.Name).Run()
+}
+
+func (r *cliRuntime) Exec(ctx context.Context, h Handle, cmd []string) ([]byte, error) {
+	args := append([]string{"exec", h.Name}, cmd...)
+	out, err := exec.CommandContext(ctx, r.bin, args...).CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%s exec: %w", r.bin, err)
+	}
+	return out, nil
+}
+
+func (r *cliRuntime) Logs(ctx context.Context, h Handle, follow bool) (io.ReadCloser, error) {
+	args := []string{"logs"}
+	if follow {
+		args = append(args, "-f")
+	}
+	args = append(args, h.Name)
+
+	cmd := exec.CommandContext(ctx, r.bin, args...)
+	pr, pw := io.Pipe()
+	// Both streams fan into the same pipe writer: io.PipeWriter.Write is safe for concurrent
+	// callers (each call is matched atomically against a reader), so this mirrors what "docker
+	// logs" already does by interleaving stdout/stderr onto the terminal.
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		pw.Close()
+		return nil, fmt.Errorf("%s logs: %w", r.bin, err)
+	}
+	go func() {
+		cmd.Wait()
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+func (r *cliRuntime) Wait(ctx context.Context, h Handle) (int64, error) {
+	out, err := exec.CommandContext(ctx, r.bin, "wait", h.Name).Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s wait: %w", r.bin, err)
+	}
+	code, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse exit code from %s wait: %w", r.bin, err)
+	}
+	return code, nil
+}
+
+func (r *cliRuntime) Commit(ctx context.Context, h Handle, tag string, labels map[string]string) error {
+	args := []string{"commit"}
+	for k, v := range labels {
+		args = append(args, "--change", fmt.Sprintf("LABEL %s=%s", k, v))
+	}
+	args = append(args, h.Name, tag)
+	if err := exec.CommandContext(ctx, r.bin, args...).Run(); err != nil {
+		return fmt.Errorf("%s commit: %w", r.bin, err)
+	}
+	return nil
+}
+
+func (r *cliRuntime) ImageID(ctx context.Context, image string) (string, error) {
+	out, err := exec.CommandContext(ctx, r.bin, "image", "inspect", "--format", "{{.Id}}", image).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s image inspect: %w", r.bin, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (r *cliRuntime) RemoveImage(ctx context.Context, image string) error {
+	return exec.CommandContext(ctx, r.bin, "rmi", "-f", image).Run()
+}
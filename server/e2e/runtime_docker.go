@@ -0,0 +1,187 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+// DockerRuntime drives containers through the Docker Engine API client rather than shelling out
+// to the docker CLI, so callers get typed errors and exit/OOM state instead of parsed CLI output.
+type DockerRuntime struct {
+	client *client.Client
+}
+
+// NewDockerRuntime connects to the local Docker daemon using the standard DOCKER_HOST/TLS
+// environment variables, negotiating the API version the daemon supports.
+func NewDockerRuntime() (*DockerRuntime, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+	return &DockerRuntime{client: cli}, nil
+}
+
+func (r *DockerRuntime) Name() string { return "docker" }
+
+func (r *DockerRuntime) Run(ctx context.Context, spec RunSpec) (Handle, error) {
+	exposed := nat.PortSet{}
+	bindings := nat.PortMap{}
+	for containerPort, hostPort := range spec.PortBindings {
+		p := nat.Port(fmt.Sprintf("%d/tcp", containerPort))
+		exposed[p] = struct{}{}
+		bindings[p] = []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: fmt.Sprintf("%d", hostPort)}}
+	}
+
+	var env []string
+	for k, v := range spec.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	var binds []string
+	for hostPath, containerPath := range spec.Volumes {
+		binds = append(binds, fmt.Sprintf("%s:%s", hostPath, containerPath))
+	}
+
+	hostConfig := &container.HostConfig{
+		Privileged:   spec.Privileged,
+		PortBindings: bindings,
+		Tmpfs:        spec.Tmpfs,
+		Binds:        binds,
+	}
+	if spec.HostAccess {
+		hostConfig.ExtraHosts = []string{"host.docker.internal:host-gateway"}
+	}
+
+	created, err := r.client.ContainerCreate(ctx, &container.Config{
+		Image:        spec.Image,
+		Env:          env,
+		ExposedPorts: exposed,
+	}, hostConfig, nil, nil, spec.Name)
+	if err != nil {
+		return Handle{}, fmt.Errorf("failed to create container: %w", err)
+	}
+	if err := r.client.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return Handle{}, fmt.Errorf("failed to start container: %w", err)
+	}
+	return Handle{ID: created.ID, Name: spec.Name}, nil
+}
+
+// ref prefers the container ID (set once Run has created it) and falls back to the name, so an
+// early cleanup-before-Run call can still remove a stale container left over from a previous run.
+func ref(h Handle) string {
+	if h.ID != "" {
+		return h.ID
+	}
+	return h.Name
+}
+
+func (r *DockerRuntime) Kill(ctx context.Context, h Handle) error {
+	return r.client.ContainerKill(ctx, ref(h), "SIGKILL")
+}
+
+func (r *DockerRuntime) Remove(ctx context.Context, h Handle) error {
+	return r.client.ContainerRemove(ctx, ref(h), container.RemoveOptions{Force: true})
+}
+
+func (r *DockerRuntime) Exec(ctx context.Context, h Handle, cmd []string) ([]byte, error) {
+	created, err := r.client.ContainerExecCreate(ctx, ref(h), container.ExecOptions{
+		Cmd: cmd, AttachStdout: true, AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+	resp, err := r.client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach exec: %w", err)
+	}
+	defer resp.Close()
+
+	var buf []byte
+	out := &sliceWriter{buf: &buf}
+	if _, err := stdcopy.StdCopy(out, out, resp.Reader); err != nil {
+		return nil, fmt.Errorf("failed to read exec output: %w", err)
+	}
+	return buf, nil
+}
+
+// sliceWriter is the simplest io.Writer that accumulates into a byte slice, used here instead of
+// bytes.Buffer only because stdcopy.StdCopy wants two independent io.Writer destinations and
+// Exec's callers want them interleaved into one result.
+type sliceWriter struct{ buf *[]byte }
+
+func (w *sliceWriter) Write(p []byte) (int, error) {
+	*w.buf = append(*w.buf, p...)
+	return len(p), nil
+}
+
+func (r *DockerRuntime) Logs(ctx context.Context, h Handle, follow bool) (io.ReadCloser, error) {
+	raw, err := r.client.ContainerLogs(ctx, ref(h), container.LogsOptions{
+		ShowStdout: true, ShowStderr: true, Follow: follow,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		// ContainerLogs multiplexes stdout/stderr into a single stream framed per Docker's log
+		// protocol; StdCopy demultiplexes it back into plain bytes, matching what Podman's and
+		// nerdctl's CLI `logs` output already give callers of this interface.
+		_, _ = stdcopy.StdCopy(pw, pw, raw)
+		raw.Close()
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+func (r *DockerRuntime) Wait(ctx context.Context, h Handle) (int64, error) {
+	statusCh, errCh := r.client.ContainerWait(ctx, ref(h), container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return 0, err
+	case status := <-statusCh:
+		return status.StatusCode, nil
+	}
+}
+
+// Inspect returns the container's full state (State.OOMKilled, State.ExitCode, health, etc.) for
+// TestContainer.Inspect. It's boxed as `any` to satisfy Runtime-agnostic callers; Podman/nerdctl
+// don't implement Inspector at all today.
+func (r *DockerRuntime) Inspect(ctx context.Context, h Handle) (any, error) {
+	return r.client.ContainerInspect(ctx, ref(h))
+}
+
+var _ Inspector = (*DockerRuntime)(nil)
+
+func (r *DockerRuntime) Commit(ctx context.Context, h Handle, tag string, labels map[string]string) error {
+	_, err := r.client.ContainerCommit(ctx, ref(h), container.CommitOptions{
+		Reference: tag,
+		Config:    &container.Config{Labels: labels},
+	})
+	return err
+}
+
+func (r *DockerRuntime) ImageID(ctx context.Context, img string) (string, error) {
+	inspect, _, err := r.client.ImageInspectWithRaw(ctx, img)
+	if err != nil {
+		return "", err
+	}
+	return inspect.ID, nil
+}
+
+func (r *DockerRuntime) RemoveImage(ctx context.Context, img string) error {
+	_, err := r.client.ImageRemove(ctx, img, image.RemoveOptions{Force: true})
+	return err
+}
+
+// ContainerJSON re-exports types.ContainerJSON for callers of TestContainer.Inspect that want to
+// type-assert its result without importing the Docker API types package themselves.
+type ContainerJSON = types.ContainerJSON
@@ -0,0 +1,120 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// snapshotBaseDigestLabel is the image label SnapshotFixture uses to record the base image it was
+// built from, for operators inspecting a leftover snapshot image by hand.
+const snapshotBaseDigestLabel = "kernel.e2e.base-digest"
+
+// SnapshotFixture amortizes container cold-start cost across a test binary: the first call to
+// Image starts a single golden container, runs an optional warmup against it, then commits it to
+// an image tagged kernel-e2e-snapshot:<base-image-id>. Every later NewTestContainer(tb,
+// fixture.Image()) call launches from that snapshot instead of paying cold-start again. Because
+// the tag is derived from the base image's own content ID, a base image rebuild naturally produces
+// a different tag instead of serving a stale snapshot.
+type SnapshotFixture struct {
+	baseImage string
+	warmup    func(ctx context.Context, tb testing.TB, c *TestContainer) error
+
+	mu      sync.Mutex
+	runtime Runtime
+	image   string
+	built   bool
+}
+
+// NewSnapshotFixture creates a fixture that snapshots baseImage after running warmup once. warmup
+// may be nil if no setup beyond container startup is needed. Constructing a SnapshotFixture does
+// no work; Image does, on its first call.
+func NewSnapshotFixture(baseImage string, warmup func(ctx context.Context, tb testing.TB, c *TestContainer) error) *SnapshotFixture {
+	return &SnapshotFixture{baseImage: baseImage, warmup: warmup}
+}
+
+// Image returns the snapshot image tag, building it on first call. Safe to call from parallel
+// tests: the build itself happens at most once, guarded by f.mu, and a matching snapshot already
+// present on disk (left over from a previous test binary run against the same base image) is
+// reused without re-running warmup.
+func (f *SnapshotFixture) Image(ctx context.Context, tb testing.TB) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tb.Helper()
+
+	if f.built {
+		return f.image, nil
+	}
+
+	if f.runtime == nil {
+		rt, err := detectRuntime()
+		if err != nil {
+			return "", err
+		}
+		f.runtime = rt
+	}
+
+	baseID, err := f.runtime.ImageID(ctx, f.baseImage)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect base image %s: %w", f.baseImage, err)
+	}
+	tag := fmt.Sprintf("kernel-e2e-snapshot:%s", shortImageID(baseID))
+
+	if _, err := f.runtime.ImageID(ctx, tag); err == nil {
+		f.image = tag
+		f.built = true
+		return tag, nil
+	}
+
+	golden := NewTestContainer(tb, f.baseImage, f.runtime)
+	if err := golden.Start(ctx, ContainerConfig{}); err != nil {
+		return "", fmt.Errorf("failed to start golden container: %w", err)
+	}
+	defer golden.Stop(ctx)
+
+	if err := golden.WaitReady(ctx); err != nil {
+		return "", fmt.Errorf("golden container not ready: %w", err)
+	}
+
+	if f.warmup != nil {
+		if err := f.warmup(ctx, tb, golden); err != nil {
+			return "", fmt.Errorf("snapshot warmup failed: %w", err)
+		}
+	}
+
+	if err := f.runtime.Commit(ctx, golden.handle, tag, map[string]string{snapshotBaseDigestLabel: baseID}); err != nil {
+		return "", fmt.Errorf("failed to commit snapshot image: %w", err)
+	}
+
+	f.image = tag
+	f.built = true
+	return tag, nil
+}
+
+// Cleanup removes the snapshot image, if one was built. Call it from TestMain after m.Run() so
+// snapshots don't accumulate across CI runs.
+func (f *SnapshotFixture) Cleanup(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.built || f.runtime == nil {
+		return nil
+	}
+	err := f.runtime.RemoveImage(ctx, f.image)
+	f.built = false
+	f.image = ""
+	return err
+}
+
+// shortImageID truncates an image ID (e.g. "sha256:abcdef...") to a short, tag-safe fragment.
+func shortImageID(id string) string {
+	const n = 12
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		id = id[i+1:]
+	}
+	if len(id) > n {
+		id = id[:n]
+	}
+	return id
+}
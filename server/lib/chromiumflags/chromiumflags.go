@@ -0,0 +1,279 @@
+// Package chromiumflags parses and merges Chromium command-line flag overlays.
+//
+// It is shared between the chromium-launcher binary (which merges CHROMIUM_FLAGS
+// with a runtime overlay file before exec'ing Chromium) and the API server's
+// extension management handlers (which need to recompute --load-extension and
+// --disable-extensions-except tokens whenever the installed extension set changes).
+package chromiumflags
+
+import (
+	"strings"
+)
+
+// quotedCommaMarker is what ParseFlags substitutes for a "," found inside a quoted span, instead
+// of the literal character. A flag value like --load-extension="/a,b","/c" has one comma that's
+// part of the first path and one that separates the two paths; once quoting is stripped the two
+// are indistinguishable from plain text. Carrying the quoted one through as this marker lets
+// appendCSVInto split only on real, unquoted commas and then restore the marker to a literal ","
+// in the resulting value. Any token that never reaches appendCSVInto has its marker restored by
+// restoreQuotedCommas before use, so it's invisible to every other caller.
+const quotedCommaMarker = '\x00'
+
+// writeQuoted appends r to cur as ParseFlags copies the inside of a quoted span, substituting
+// quotedCommaMarker for a literal comma so it survives a later CSV split undisturbed.
+func writeQuoted(cur *strings.Builder, r rune) {
+	if r == ',' {
+		cur.WriteRune(quotedCommaMarker)
+		return
+	}
+	cur.WriteRune(r)
+}
+
+// restoreQuotedCommas replaces quotedCommaMarker back with a literal "," in s. Every token
+// ParseFlags returns must have this applied before use, except the raw value appendCSVInto
+// splits, which restores it per resulting item instead.
+func restoreQuotedCommas(s string) string {
+	return strings.ReplaceAll(s, string(quotedCommaMarker), ",")
+}
+
+// ParseFlags tokenizes a Chromium flags string the way a POSIX shell would: bare words are
+// split on whitespace, and single/double-quoted spans (with backslash escapes inside double
+// quotes) are preserved as a single token with their quoting removed. This allows values like
+// --user-agent="Mozilla/5.0 ..." or --host-resolver-rules='MAP * 127.0.0.1' to be expressed in
+// CHROMIUM_FLAGS or a runtime flag overlay file. A comma inside a quoted span is carried through
+// as quotedCommaMarker rather than emitted literally, so a multi-value flag like
+// --load-extension= can tell a quoted comma (part of one path) from a real one (separating two
+// paths) once appendCSVInto splits it; callers that don't CSV-split a token must run it through
+// restoreQuotedCommas before use.
+func ParseFlags(input string) []string {
+	var tokens []string
+	var cur strings.Builder
+	hasToken := false
+
+	flush := func() {
+		if hasToken {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+			hasToken = false
+		}
+	}
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\'':
+			hasToken = true
+			i++
+			for i < len(runes) && runes[i] != '\'' {
+				writeQuoted(&cur, runes[i])
+				i++
+			}
+		case c == '"':
+			hasToken = true
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				writeQuoted(&cur, runes[i])
+				i++
+			}
+		case c == '\\' && i+1 < len(runes):
+			hasToken = true
+			i++
+			cur.WriteRune(runes[i])
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			hasToken = true
+			cur.WriteRune(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// appendCSVInto splits csv on unquoted commas and appends the resulting values into dst, skipping
+// empty items. csv may contain quotedCommaMarker in place of a comma that was inside quotes when
+// ParseFlags tokenized it; that marker is restored to a literal "," in each resulting value, so a
+// quoted comma never acts as a separator.
+func appendCSVInto(dst *[]string, csv string) {
+	for _, part := range strings.Split(csv, ",") {
+		part = restoreQuotedCommas(part)
+		if p := strings.TrimSpace(part); p != "" {
+			*dst = append(*dst, p)
+		}
+	}
+}
+
+// parseTokenStream extracts extension-related flags and collects non-extension flags.
+// It returns the list of non-extension tokens and, via references, fills the buckets for
+// --load-extension, --disable-extensions-except and a possible --disable-extensions token
+// for that stream.
+func parseTokenStream(tokens []string, load, except *[]string, disableAll *string) (nonExt []string) {
+	for _, tok := range tokens {
+		switch {
+		case strings.HasPrefix(tok, "--load-extension="):
+			appendCSVInto(load, strings.TrimPrefix(tok, "--load-extension="))
+		case strings.HasPrefix(tok, "--disable-extensions-except="):
+			appendCSVInto(except, strings.TrimPrefix(tok, "--disable-extensions-except="))
+		case tok == "--disable-extensions":
+			*disableAll = tok
+		default:
+			nonExt = append(nonExt, restoreQuotedCommas(tok))
+		}
+	}
+	return nonExt
+}
+
+// parseFeatureStream extracts --enable-features=/--disable-features= flags (each split on ",")
+// and collects every other token. Like parseTokenStream's extension buckets, this runs over every
+// token in the stream, so a layer that contains more than one --enable-features= (e.g. a profile
+// whose Flags list already has one and whose features_enable section adds another) still has
+// every value collected rather than only the first or last occurrence.
+func parseFeatureStream(tokens []string, enable, disable *[]string) (nonFeat []string) {
+	for _, tok := range tokens {
+		switch {
+		case strings.HasPrefix(tok, "--enable-features="):
+			appendCSVInto(enable, strings.TrimPrefix(tok, "--enable-features="))
+		case strings.HasPrefix(tok, "--disable-features="):
+			appendCSVInto(disable, strings.TrimPrefix(tok, "--disable-features="))
+		default:
+			nonFeat = append(nonFeat, restoreQuotedCommas(tok))
+		}
+	}
+	return nonFeat
+}
+
+// SplitFeatures partitions tokens into non-feature flags and the --enable-features=/
+// --disable-features= value lists, each already split on "," so a value from one layer can be
+// unioned with another layer's list instead of the two raw tokens colliding under whole-token
+// dedupe and one silently winning.
+func SplitFeatures(tokens []string) (nonFeat, enable, disable []string) {
+	nonFeat = parseFeatureStream(tokens, &enable, &disable)
+	return nonFeat, enable, disable
+}
+
+// FeatureFlags computes the --enable-features=/--disable-features= tokens (if any) that result
+// from merging a base layer with a runtime overlay layer: each layer's values are split on ","
+// and deduped across layers, so a --enable-features=X from one layer and --enable-features=Y from
+// another combine into --enable-features=X,Y instead of the runtime layer's token silently
+// replacing the base layer's the way MergeFlags' flat whole-token dedupe would otherwise do.
+func FeatureFlags(base, rt []string) []string {
+	_, baseEnable, baseDisable := SplitFeatures(base)
+	_, rtEnable, rtDisable := SplitFeatures(rt)
+
+	mergedEnable := union(baseEnable, rtEnable)
+	mergedDisable := union(baseDisable, rtDisable)
+
+	var featFlags []string
+	if len(mergedEnable) > 0 {
+		featFlags = append(featFlags, "--enable-features="+strings.Join(mergedEnable, ","))
+	}
+	if len(mergedDisable) > 0 {
+		featFlags = append(featFlags, "--disable-features="+strings.Join(mergedDisable, ","))
+	}
+	return featFlags
+}
+
+// union merges two lists of strings, returning a new list with duplicates and empties removed.
+func union(base, rt []string) []string {
+	seen := map[string]struct{}{}
+	out := []string{}
+	for _, v := range append(append([]string{}, base...), rt...) {
+		if v == "" {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
+
+// SplitExtensions partitions tokens into non-extension flags and the extension-related
+// buckets (--load-extension, --disable-extensions-except, --disable-extensions). Callers that
+// need provenance of individual flags (e.g. to report which layer a rejected flag came from)
+// use this directly instead of going through MergeFlags.
+func SplitExtensions(tokens []string) (nonExt, load, except []string, disableAll string) {
+	nonExt = parseTokenStream(tokens, &load, &except, &disableAll)
+	return nonExt, load, except, disableAll
+}
+
+// ExtensionFlags computes the extension-related flags (--load-extension,
+// --disable-extensions-except, --disable-extensions) that result from merging a base layer
+// with a runtime overlay layer, applying the override semantics documented on MergeFlags.
+func ExtensionFlags(base, rt []string) []string {
+	_, baseLoad, baseExcept, baseDisableAll := SplitExtensions(base)
+	_, rtLoad, rtExcept, rtDisableAll := SplitExtensions(rt)
+
+	mergedLoad := union(baseLoad, rtLoad)
+	mergedExcept := union(baseExcept, rtExcept)
+
+	var extFlags []string
+	switch {
+	case rtDisableAll != "":
+		extFlags = append(extFlags, rtDisableAll)
+	default:
+		if baseDisableAll != "" && len(rtLoad) == 0 {
+			extFlags = append(extFlags, baseDisableAll)
+		} else if len(mergedLoad) > 0 {
+			extFlags = append(extFlags, "--load-extension="+strings.Join(mergedLoad, ","))
+		}
+		if len(mergedExcept) > 0 {
+			extFlags = append(extFlags, "--disable-extensions-except="+strings.Join(mergedExcept, ","))
+		}
+	}
+	return extFlags
+}
+
+// MergeFlags combines a base flag layer with a runtime overlay layer, applying the same
+// override semantics regardless of caller (the chromium-launcher binary and the extension
+// management API both need this):
+//
+//  1. If the runtime layer specifies --disable-extensions, it overrides everything
+//     extension-related.
+//  2. Else if the base layer specifies --disable-extensions and the runtime layer does not
+//     load any extensions, the base --disable-extensions is kept.
+//  3. Else the --load-extension / --disable-extensions-except lists are unioned across layers.
+//
+// --enable-features= / --disable-features= are handled the same way as the extension flags
+// above: each layer's value is split on "," and unioned with the other layer's, emitting one
+// merged token per flag, rather than letting a flat whole-token dedupe keep only one layer's
+// token and silently drop the other's features.
+//
+// Non-extension, non-feature flags are concatenated (base first, then runtime) and deduplicated,
+// preserving first occurrence.
+func MergeFlags(base, rt []string) []string {
+	baseNonExt, _, _, _ := SplitExtensions(base)
+	rtNonExt, _, _, _ := SplitExtensions(rt)
+	extFlags := ExtensionFlags(base, rt)
+
+	baseNonExt, _, _ = SplitFeatures(baseNonExt)
+	rtNonExt, _, _ = SplitFeatures(rtNonExt)
+	featFlags := FeatureFlags(base, rt)
+
+	combined := append(append([]string{}, baseNonExt...), rtNonExt...)
+	combined = append(combined, extFlags...)
+	combined = append(combined, featFlags...)
+
+	seen := make(map[string]struct{}, len(combined))
+	final := make([]string, 0, len(combined))
+	for _, tok := range combined {
+		if tok == "" {
+			continue
+		}
+		if _, ok := seen[tok]; ok {
+			continue
+		}
+		seen[tok] = struct{}{}
+		final = append(final, tok)
+	}
+	if len(final) == 0 {
+		return nil
+	}
+	return final
+}
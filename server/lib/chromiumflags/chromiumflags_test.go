@@ -1,32 +1,74 @@
 package chromiumflags
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
 	"reflect"
-	"strings"
 	"testing"
 )
 
 func TestParseFlags(t *testing.T) {
-	// Empty input returns nil
-	if got := parseFlags(""); got != nil {
+	if got := ParseFlags(""); got != nil {
 		t.Fatalf("expected nil for empty input, got: %#v", got)
 	}
+	if got := ParseFlags("   "); got != nil {
+		t.Fatalf("expected nil for whitespace-only input, got: %#v", got)
+	}
 
-	input := "  --foo --bar=1\t--baz  "
-	got := parseFlags(input)
+	got := ParseFlags("  --foo --bar=1\t--baz  ")
 	want := []string{"--foo", "--bar=1", "--baz"}
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("parseFlags mismatch:\n got: %#v\nwant: %#v", got, want)
 	}
+}
 
-	// Quotes are not supported; ensure simple word splitting occurs
-	input = `--flag="with space" --qux`
-	got = parseFlags(input)
-	if len(got) != 3 {
-		t.Fatalf("expected 3 tokens due to simple splitting, got %d: %#v", len(got), got)
+func TestParseFlags_Quoting(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "double-quoted value with embedded space",
+			input: `--user-agent="Mozilla/5.0 (X11; Linux x86_64)" --headless`,
+			want:  []string{"--user-agent=Mozilla/5.0 (X11; Linux x86_64)", "--headless"},
+		},
+		{
+			name:  "single-quoted value with embedded space",
+			input: `--host-resolver-rules='MAP * 127.0.0.1' --foo`,
+			want:  []string{"--host-resolver-rules=MAP * 127.0.0.1", "--foo"},
+		},
+		{
+			name:  "quoted commas inside load-extension are preserved as one token",
+			input: `--load-extension="/e1,/e2"`,
+			want:  []string{"--load-extension=/e1" + string(quotedCommaMarker) + "/e2"},
+		},
+		{
+			name:  "backslash-escaped equals sign outside quotes",
+			input: `--foo\=bar --baz`,
+			want:  []string{"--foo=bar", "--baz"},
+		},
+		{
+			name:  "escaped double quote inside a double-quoted value",
+			input: `--title="say \"hi\""`,
+			want:  []string{`--title=say "hi"`},
+		},
+		{
+			name:  "adjacent quoted and unquoted spans concatenate into one token",
+			input: `--foo="a b"c`,
+			want:  []string{"--foo=a bc"},
+		},
+		{
+			name:  "quoted paths with embedded spaces around a top-level comma",
+			input: `--load-extension="/path with space/ext1","/ext2"`,
+			want:  []string{"--load-extension=/path with space/ext1,/ext2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseFlags(tt.input)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFlags(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
 	}
 }
 
@@ -39,208 +81,70 @@ func TestAppendCSVInto(t *testing.T) {
 	}
 }
 
-func TestParseTokenStream_BaseAndRuntime(t *testing.T) {
-	var (
-		baseLoad    []string
-		baseExcept  []string
-		rtLoad      []string
-		rtExcept    []string
-		baseDisable string
-		rtDisable   string
-	)
-
-	baseTokens := []string{
+func TestSplitExtensions(t *testing.T) {
+	tokens := []string{
 		"--load-extension=/e1,/e2",
 		"--disable-extensions-except=/x1",
 		"--other=1",
 		"--disable-extensions",
 	}
-	runtimeTokens := []string{
-		"--disable-extensions-except=/x2,/x3",
-		"--load-extension=/e3",
-		"--disable-extensions",
-		"--foo",
-	}
-
-	baseNonExt := parseTokenStream(baseTokens, &baseLoad, &baseExcept, &baseDisable)
-	runtimeNonExt := parseTokenStream(runtimeTokens, &rtLoad, &rtExcept, &rtDisable)
-
-	if !reflect.DeepEqual(baseLoad, []string{"/e1", "/e2"}) {
-		t.Fatalf("base load-extension parsed incorrectly: %#v", baseLoad)
-	}
-	if !reflect.DeepEqual(baseExcept, []string{"/x1"}) {
-		t.Fatalf("base disable-extensions-except parsed incorrectly: %#v", baseExcept)
-	}
-	if !reflect.DeepEqual(rtLoad, []string{"/e3"}) {
-		t.Fatalf("runtime load-extension parsed incorrectly: %#v", rtLoad)
-	}
-	if !reflect.DeepEqual(rtExcept, []string{"/x2", "/x3"}) {
-		t.Fatalf("runtime disable-extensions-except parsed incorrectly: %#v", rtExcept)
-	}
-	if baseDisable != "--disable-extensions" {
-		t.Fatalf("expected base disable-all captured, got %q", baseDisable)
-	}
-	if rtDisable != "--disable-extensions" {
-		t.Fatalf("expected runtime disable-all captured, got %q", rtDisable)
-	}
-	if !reflect.DeepEqual(baseNonExt, []string{"--other=1"}) {
-		t.Fatalf("unexpected base non-extension tokens: %#v", baseNonExt)
-	}
-	if !reflect.DeepEqual(runtimeNonExt, []string{"--foo"}) {
-		t.Fatalf("unexpected runtime non-extension tokens: %#v", runtimeNonExt)
-	}
-}
-
-func TestMergeUnion(t *testing.T) {
-	base := []string{"a", "b", "a", ""}
-	rt := []string{"b", "c", "", "a"}
-	got := union(base, rt)
-	want := []string{"a", "b", "c"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("mergeUnion mismatch:\n got: %#v\nwant: %#v", got, want)
-	}
-}
-
-func TestOverrideSemantics_DisableBase_LoadRuntime(t *testing.T) {
-	// Base has --disable-extensions, runtime has --load-extension → runtime overrides, no disable-all in final
-	baseFlags := "--disable-extensions"
-	runtimeFlags := "--load-extension=/e1"
-
-	baseTokens := parseFlags(baseFlags)
-	runtimeTokens := parseFlags(runtimeFlags)
-
-	var (
-		baseLoad    []string
-		baseExcept  []string
-		rtLoad      []string
-		rtExcept    []string
-		baseDisable string
-		rtDisable   string
-	)
-
-	_ = parseTokenStream(baseTokens, &baseLoad, &baseExcept, &baseDisable)
-	_ = parseTokenStream(runtimeTokens, &rtLoad, &rtExcept, &rtDisable)
-
-	mergedLoad := union(baseLoad, rtLoad)
-	mergedExcept := union(baseExcept, rtExcept)
-
-	var extFlags []string
-	if rtDisable != "" {
-		extFlags = append(extFlags, rtDisable)
-	} else {
-		if baseDisable != "" && len(rtLoad) == 0 {
-			extFlags = append(extFlags, baseDisable)
-		} else if len(mergedLoad) > 0 {
-			extFlags = append(extFlags, "--load-extension="+strings.Join(mergedLoad, ","))
-		}
-		if len(mergedExcept) > 0 {
-			extFlags = append(extFlags, "--disable-extensions-except="+strings.Join(mergedExcept, ","))
-		}
+	nonExt, load, except, disableAll := SplitExtensions(tokens)
+	if !reflect.DeepEqual(nonExt, []string{"--other=1"}) {
+		t.Fatalf("unexpected non-extension tokens: %#v", nonExt)
 	}
-
-	for _, f := range extFlags {
-		if f == "--disable-extensions" {
-			t.Fatalf("unexpected disable-all in final flags when runtime loads extensions: %#v", extFlags)
-		}
+	if !reflect.DeepEqual(load, []string{"/e1", "/e2"}) {
+		t.Fatalf("unexpected load-extension tokens: %#v", load)
 	}
-}
-
-func TestOverrideSemantics_DisableRuntime_Wins(t *testing.T) {
-	// Runtime has --disable-extensions → overrides everything extension related
-	baseFlags := "--load-extension=/e1 --disable-extensions-except=/x1"
-	runtimeFlags := "--disable-extensions"
-
-	baseTokens := parseFlags(baseFlags)
-	runtimeTokens := parseFlags(runtimeFlags)
-
-	var (
-		baseLoad       []string
-		baseExcept     []string
-		rtLoad         []string
-		rtExcept       []string
-		baseDisable    string
-		runtimeDisable string
-	)
-
-	_ = parseTokenStream(baseTokens, &baseLoad, &baseExcept, &baseDisable)
-	_ = parseTokenStream(runtimeTokens, &rtLoad, &rtExcept, &runtimeDisable)
-
-	var extFlags []string
-	if runtimeDisable != "" {
-		extFlags = append(extFlags, runtimeDisable)
+	if !reflect.DeepEqual(except, []string{"/x1"}) {
+		t.Fatalf("unexpected disable-extensions-except tokens: %#v", except)
 	}
-
-	if len(extFlags) != 1 || extFlags[0] != "--disable-extensions" {
-		t.Fatalf("runtime disable should win exclusively, got: %#v", extFlags)
+	if disableAll != "--disable-extensions" {
+		t.Fatalf("expected disable-all captured, got %q", disableAll)
 	}
 }
 
-func TestReadOptionalFlagFile(t *testing.T) {
-	// Non-existent returns nil slice and nil error
-	if s, err := ReadOptionalFlagFile(filepath.Join(t.TempDir(), "not-there")); err != nil || s != nil {
-		t.Fatalf("expected nil slice and nil error for missing file, got %#v, err=%v", s, err)
-	}
-
-	// Plain text is no longer supported: expect an error
-	dir := t.TempDir()
-	path := filepath.Join(dir, "flags.txt")
-	content := "--foo\n--bar=1"
-	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
-		t.Fatalf("write temp file: %v", err)
-	}
-	if _, err := ReadOptionalFlagFile(path); err == nil {
-		t.Fatalf("expected error for plain text flags file, got nil")
+func TestSplitExtensions_QuotedPathsWithSpaces(t *testing.T) {
+	tokens := ParseFlags(`--load-extension="/path with space/ext1","/ext2"`)
+	_, load, _, _ := SplitExtensions(tokens)
+	want := []string{"/path with space/ext1", "/ext2"}
+	if !reflect.DeepEqual(load, want) {
+		t.Fatalf("unexpected load-extension paths: %#v", load)
 	}
 }
 
-func TestReadOptionalFlagFile_JSON(t *testing.T) {
-	dir := t.TempDir()
-	path := filepath.Join(dir, "flags.json")
-	content := `{"flags":["--one","--two=2","  ","--three"]}`
-	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
-		t.Fatalf("write temp file: %v", err)
-	}
-	got, err := ReadOptionalFlagFile(path)
-	if err != nil {
-		t.Fatalf("ReadOptionalFlagFile error: %v", err)
-	}
-	want := []string{"--one", "--two=2", "--three"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("ReadOptionalFlagFile(JSON) content mismatch:\n got: %#v\nwant: %#v", got, want)
+// TestSplitExtensions_QuotedCommaWithinPath guards against a comma that's part of a single
+// quoted path being mistaken for the top-level comma that separates two paths. Both
+// --load-extension="/a,b","/c" (one path containing a comma, plus a second path) and
+// --load-extension="/a","b","/c" (three distinct paths) tokenize to the same dequoted text if
+// ParseFlags doesn't tell the two kinds of comma apart, so without quotedCommaMarker this would
+// wrongly split the first into three paths instead of two.
+func TestSplitExtensions_QuotedCommaWithinPath(t *testing.T) {
+	tokens := ParseFlags(`--load-extension="/a,b","/c"`)
+	_, load, _, _ := SplitExtensions(tokens)
+	want := []string{"/a,b", "/c"}
+	if !reflect.DeepEqual(load, want) {
+		t.Fatalf("unexpected load-extension paths: %#v", load)
 	}
 }
 
-func TestWriteFlagFileAndReadBack(t *testing.T) {
-	dir := t.TempDir()
-	path := filepath.Join(dir, "flags.json")
-	tokens := []string{" --a ", "", "--b=1"}
-	if err := WriteFlagFile(path, tokens); err != nil {
-		t.Fatalf("WriteFlagFile error: %v", err)
-	}
-	// Read as runtime flags (tokens)
-	got, err := ReadOptionalFlagFile(path)
-	if err != nil {
-		t.Fatalf("ReadOptionalFlagFile error: %v", err)
-	}
-	if !reflect.DeepEqual(got, []string{"--a", "--b=1"}) {
-		t.Fatalf("unexpected merged runtime tokens: %#v", got)
+func TestSplitFeatures(t *testing.T) {
+	tokens := []string{
+		"--enable-features=A,B",
+		"--other=1",
+		"--disable-features=X",
 	}
-	// Validate JSON structure in file
-	raw, err := os.ReadFile(path)
-	if err != nil {
-		t.Fatalf("ReadFile error: %v", err)
+	nonFeat, enable, disable := SplitFeatures(tokens)
+	if !reflect.DeepEqual(nonFeat, []string{"--other=1"}) {
+		t.Fatalf("unexpected non-feature tokens: %#v", nonFeat)
 	}
-	var jf FlagsFile
-	if err := json.Unmarshal(raw, &jf); err != nil {
-		t.Fatalf("json unmarshal error: %v; content=%s", err, string(raw))
+	if !reflect.DeepEqual(enable, []string{"A", "B"}) {
+		t.Fatalf("unexpected enable-features values: %#v", enable)
 	}
-	if !reflect.DeepEqual(jf.Flags, []string{"--a", "--b=1"}) {
-		t.Fatalf("unexpected flags in file: %#v", jf.Flags)
+	if !reflect.DeepEqual(disable, []string{"X"}) {
+		t.Fatalf("unexpected disable-features values: %#v", disable)
 	}
 }
 
-// TestWriteFlagFileFromString removed: callers should use WriteFlagFile with tokens.
-
 func TestMergeFlags(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -248,78 +152,27 @@ func TestMergeFlags(t *testing.T) {
 		runtimeFlags string
 		want         string
 	}{
-		{
-			name:         "empty base and runtime",
-			baseFlags:    "",
-			runtimeFlags: "",
-			want:         "",
-		},
-		{
-			name:         "base only, no runtime",
-			baseFlags:    "--foo --bar=1",
-			runtimeFlags: "",
-			want:         "--foo --bar=1",
-		},
-		{
-			name:         "runtime only, no base",
-			baseFlags:    "",
-			runtimeFlags: "--foo --bar=1",
-			want:         "--foo --bar=1",
-		},
-		{
-			name:         "merge non-extension flags",
-			baseFlags:    "--foo --bar=1",
-			runtimeFlags: "--baz --qux=2",
-			want:         "--foo --bar=1 --baz --qux=2",
-		},
-		{
-			name:         "deduplicate non-extension flags",
-			baseFlags:    "--foo --bar=1",
-			runtimeFlags: "--foo --baz",
-			want:         "--foo --bar=1 --baz",
-		},
-		{
-			name:         "merge load-extension flags",
-			baseFlags:    "--load-extension=/e1",
-			runtimeFlags: "--load-extension=/e2",
-			want:         "--load-extension=/e1,/e2",
-		},
-		{
-			name:         "merge disable-extensions-except flags",
-			baseFlags:    "--disable-extensions-except=/x1",
-			runtimeFlags: "--disable-extensions-except=/x2",
-			want:         "--disable-extensions-except=/x1,/x2",
-		},
-		{
-			name:         "runtime disable-extensions overrides all",
-			baseFlags:    "--load-extension=/e1 --disable-extensions-except=/x1",
-			runtimeFlags: "--disable-extensions",
-			want:         "--disable-extensions",
-		},
-		{
-			name:         "base disable-extensions, runtime load-extension overrides",
-			baseFlags:    "--disable-extensions",
-			runtimeFlags: "--load-extension=/e1",
-			want:         "--load-extension=/e1",
-		},
-		{
-			name:         "base disable-extensions, no runtime load-extension keeps disable",
-			baseFlags:    "--disable-extensions --other=1",
-			runtimeFlags: "--foo",
-			want:         "--other=1 --foo --disable-extensions",
-		},
-		{
-			name:         "complex merge with extensions and non-extensions",
-			baseFlags:    "--foo --load-extension=/e1 --disable-extensions-except=/x1",
-			runtimeFlags: "--bar --load-extension=/e2 --disable-extensions-except=/x2",
-			want:         "--foo --bar --load-extension=/e1,/e2 --disable-extensions-except=/x1,/x2",
-		},
+		{name: "empty base and runtime", baseFlags: "", runtimeFlags: "", want: ""},
+		{name: "base only, no runtime", baseFlags: "--foo --bar=1", runtimeFlags: "", want: "--foo --bar=1"},
+		{name: "runtime only, no base", baseFlags: "", runtimeFlags: "--foo --bar=1", want: "--foo --bar=1"},
+		{name: "merge non-extension flags", baseFlags: "--foo --bar=1", runtimeFlags: "--baz --qux=2", want: "--foo --bar=1 --baz --qux=2"},
+		{name: "deduplicate non-extension flags", baseFlags: "--foo --bar=1", runtimeFlags: "--foo --baz", want: "--foo --bar=1 --baz"},
+		{name: "merge load-extension flags", baseFlags: "--load-extension=/e1", runtimeFlags: "--load-extension=/e2", want: "--load-extension=/e1,/e2"},
+		{name: "merge disable-extensions-except flags", baseFlags: "--disable-extensions-except=/x1", runtimeFlags: "--disable-extensions-except=/x2", want: "--disable-extensions-except=/x1,/x2"},
+		{name: "runtime disable-extensions overrides all", baseFlags: "--load-extension=/e1 --disable-extensions-except=/x1", runtimeFlags: "--disable-extensions", want: "--disable-extensions"},
+		{name: "base disable-extensions, runtime load-extension overrides", baseFlags: "--disable-extensions", runtimeFlags: "--load-extension=/e1", want: "--load-extension=/e1"},
+		{name: "base disable-extensions, no runtime load-extension keeps disable", baseFlags: "--disable-extensions --other=1", runtimeFlags: "--foo", want: "--other=1 --foo --disable-extensions"},
+		{name: "complex merge with extensions and non-extensions", baseFlags: "--foo --load-extension=/e1 --disable-extensions-except=/x1", runtimeFlags: "--bar --load-extension=/e2 --disable-extensions-except=/x2", want: "--foo --bar --load-extension=/e1,/e2 --disable-extensions-except=/x1,/x2"},
+		{name: "merge enable-features flags", baseFlags: "--enable-features=A", runtimeFlags: "--enable-features=B", want: "--enable-features=A,B"},
+		{name: "merge disable-features flags", baseFlags: "--disable-features=A", runtimeFlags: "--disable-features=B", want: "--disable-features=A,B"},
+		{name: "dedupe repeated feature values across layers", baseFlags: "--enable-features=A,B", runtimeFlags: "--enable-features=B,C", want: "--enable-features=A,B,C"},
+		{name: "enable and disable features merge independently", baseFlags: "--foo --enable-features=A --disable-features=X", runtimeFlags: "--bar --enable-features=B --disable-features=Y", want: "--foo --bar --enable-features=A,B --disable-features=X,Y"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := MergeFlags(parseFlags(tt.baseFlags), parseFlags(tt.runtimeFlags))
-			wantTokens := parseFlags(tt.want)
+			got := MergeFlags(ParseFlags(tt.baseFlags), ParseFlags(tt.runtimeFlags))
+			wantTokens := ParseFlags(tt.want)
 			if !reflect.DeepEqual(got, wantTokens) {
 				t.Errorf("MergeFlags() mismatch:\n got: %#v\nwant: %#v", got, wantTokens)
 			}
@@ -0,0 +1,141 @@
+package chromiumflags
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Extensions describes the extension-related overlay sections of a Profile.
+type Extensions struct {
+	Load   []string `json:"load,omitempty"`
+	Except []string `json:"except,omitempty"`
+}
+
+// Profile is a single named overlay in a profiles file, e.g. "headless", "debug", "record".
+// Profiles compose via Inherits: the named parent is resolved first and this profile's
+// settings are layered on top of it using the same override semantics as MergeFlags.
+type Profile struct {
+	Inherits        string            `json:"inherits,omitempty"`
+	Flags           []string          `json:"flags,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`
+	FeaturesEnable  []string          `json:"features_enable,omitempty"`
+	FeaturesDisable []string          `json:"features_disable,omitempty"`
+	Extensions      Extensions        `json:"extensions,omitempty"`
+}
+
+// ProfileSet is the top-level structure of a profiles overlay file: a map of profile name to
+// definition. LoadProfileSet parses the file as YAML (or JSON, which the YAML parser also
+// accepts); TOML is not supported and there is no format detection by extension.
+type ProfileSet map[string]Profile
+
+// LoadProfileSet reads and parses a YAML profiles overlay file. A missing file returns a nil
+// set and a nil error so callers can treat "no profiles configured" as a no-op.
+func LoadProfileSet(path string) (ProfileSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read profiles file: %w", err)
+	}
+	var set ProfileSet
+	if err := yaml.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("parse profiles file %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// ResolveProfile follows the Inherits chain starting at name and flattens it into a single
+// effective Profile, with each layer's flags/env/features merged on top of its parent using
+// the same union-and-dedupe semantics as MergeFlags. A cycle in Inherits is reported as an error.
+func ResolveProfile(set ProfileSet, name string) (Profile, error) {
+	chain, err := inheritanceChain(set, name, nil)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var resolved Profile
+	for _, p := range chain {
+		resolved = layerProfile(resolved, p)
+	}
+	return resolved, nil
+}
+
+func inheritanceChain(set ProfileSet, name string, seen []string) ([]Profile, error) {
+	for _, s := range seen {
+		if s == name {
+			return nil, fmt.Errorf("profile inheritance cycle detected: %s -> %s", strings.Join(seen, " -> "), name)
+		}
+	}
+	p, ok := set[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	seen = append(seen, name)
+	if p.Inherits == "" {
+		return []Profile{p}, nil
+	}
+	parentChain, err := inheritanceChain(set, p.Inherits, seen)
+	if err != nil {
+		return nil, err
+	}
+	return append(parentChain, p), nil
+}
+
+// layerProfile applies child on top of base: flags/features/extensions are unioned, env keys
+// in child override base, consistent with how the runtime flag overlay overrides CHROMIUM_FLAGS.
+func layerProfile(base, child Profile) Profile {
+	out := Profile{
+		Inherits:        child.Inherits,
+		Flags:           union(base.Flags, child.Flags),
+		FeaturesEnable:  union(base.FeaturesEnable, child.FeaturesEnable),
+		FeaturesDisable: union(base.FeaturesDisable, child.FeaturesDisable),
+		Extensions: Extensions{
+			Load:   union(base.Extensions.Load, child.Extensions.Load),
+			Except: union(base.Extensions.Except, child.Extensions.Except),
+		},
+	}
+	out.Env = make(map[string]string, len(base.Env)+len(child.Env))
+	for k, v := range base.Env {
+		out.Env[k] = v
+	}
+	for k, v := range child.Env {
+		out.Env[k] = v
+	}
+	return out
+}
+
+// ResolvedTokens renders a resolved Profile into the Chromium command-line tokens it implies:
+// its own Flags plus a single merged --enable-features=/--disable-features= pair (values from
+// every layer split on "," and deduplicated so they don't silently collide the way a flat
+// dedupe-by-whole-token map would) plus its extension tokens.
+func (p Profile) ResolvedTokens() []string {
+	tokens := append([]string{}, p.Flags...)
+	if len(p.FeaturesEnable) > 0 {
+		tokens = append(tokens, "--enable-features="+strings.Join(dedupeCSV(p.FeaturesEnable), ","))
+	}
+	if len(p.FeaturesDisable) > 0 {
+		tokens = append(tokens, "--disable-features="+strings.Join(dedupeCSV(p.FeaturesDisable), ","))
+	}
+	if len(p.Extensions.Load) > 0 {
+		tokens = append(tokens, "--load-extension="+strings.Join(p.Extensions.Load, ","))
+	}
+	if len(p.Extensions.Except) > 0 {
+		tokens = append(tokens, "--disable-extensions-except="+strings.Join(p.Extensions.Except, ","))
+	}
+	return tokens
+}
+
+// dedupeCSV splits every entry on "," (entries may themselves already be comma-joined, e.g.
+// when a Flags-level --enable-features= from one layer gets promoted into FeaturesEnable),
+// then dedupes while preserving first-seen order.
+func dedupeCSV(values []string) []string {
+	var flat []string
+	for _, v := range values {
+		appendCSVInto(&flat, v)
+	}
+	return union(flat, nil)
+}
@@ -0,0 +1,92 @@
+package chromiumflags
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeProfilesFile(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "profiles.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write profiles file: %v", err)
+	}
+	return path
+}
+
+func TestLoadProfileSet_Missing(t *testing.T) {
+	set, err := LoadProfileSet(filepath.Join(t.TempDir(), "not-there.yaml"))
+	if err != nil || set != nil {
+		t.Fatalf("expected nil set and nil error for missing file, got %#v, err=%v", set, err)
+	}
+}
+
+func TestResolveProfile_Inherits(t *testing.T) {
+	path := writeProfilesFile(t, `
+headless:
+  flags:
+    - --headless=new
+  features_enable:
+    - FeatureA
+debug:
+  inherits: headless
+  flags:
+    - --remote-debugging-port=9222
+  features_enable:
+    - FeatureB
+`)
+	set, err := LoadProfileSet(path)
+	if err != nil {
+		t.Fatalf("LoadProfileSet: %v", err)
+	}
+
+	resolved, err := ResolveProfile(set, "debug")
+	if err != nil {
+		t.Fatalf("ResolveProfile: %v", err)
+	}
+	if !reflect.DeepEqual(resolved.Flags, []string{"--headless=new", "--remote-debugging-port=9222"}) {
+		t.Fatalf("unexpected resolved flags: %#v", resolved.Flags)
+	}
+	if !reflect.DeepEqual(resolved.FeaturesEnable, []string{"FeatureA", "FeatureB"}) {
+		t.Fatalf("unexpected resolved features: %#v", resolved.FeaturesEnable)
+	}
+}
+
+func TestResolveProfile_Cycle(t *testing.T) {
+	path := writeProfilesFile(t, `
+a:
+  inherits: b
+b:
+  inherits: a
+`)
+	set, err := LoadProfileSet(path)
+	if err != nil {
+		t.Fatalf("LoadProfileSet: %v", err)
+	}
+	if _, err := ResolveProfile(set, "a"); err == nil {
+		t.Fatalf("expected cycle error, got nil")
+	}
+}
+
+func TestResolveProfile_Unknown(t *testing.T) {
+	set := ProfileSet{}
+	if _, err := ResolveProfile(set, "missing"); err == nil {
+		t.Fatalf("expected error for unknown profile")
+	}
+}
+
+func TestResolvedTokens_MergesFeaturesAcrossLayers(t *testing.T) {
+	// Regression: features_enable values from different layers must be merged by splitting on
+	// "," and deduping, not collide in a flat token-level dedupe map.
+	p := Profile{
+		FeaturesEnable: []string{"Foo,Bar", "Bar,Baz"},
+	}
+	tokens := p.ResolvedTokens()
+	want := []string{"--enable-features=Foo,Bar,Baz"}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("ResolvedTokens mismatch: got %#v want %#v", tokens, want)
+	}
+}
@@ -0,0 +1,499 @@
+package cookiejar
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	stdjar "net/http/cookiejar"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/storagepartition"
+)
+
+// cookiesEntryPath is where the Cookies database lives inside a zip produced by the instance
+// server's DownloadDirZip (and consumed by restoreUserDataDir in server/e2e), relative to the
+// zip root.
+var cookiesEntryPath = path.Join(storagepartition.DefaultProfileDir, "Cookies")
+
+// cookiesCreateSQL is the schema MergeCookiesIntoProfile writes. It's a deliberately narrowed
+// subset of the columns real Chromium builds have added over the years (no top_frame_site_key,
+// encrypted_value, or partition key), since this package only round-trips cookies it can read
+// and write in plaintext - see the package doc and decodeCookieRow's handling of encrypted rows.
+const cookiesCreateSQL = `CREATE TABLE cookies(creation_utc INTEGER NOT NULL,host_key TEXT NOT NULL,name TEXT NOT NULL,value TEXT NOT NULL,path TEXT NOT NULL,expires_utc INTEGER NOT NULL,is_secure INTEGER NOT NULL,is_httponly INTEGER NOT NULL,last_access_utc INTEGER NOT NULL,has_expires INTEGER NOT NULL,is_persistent INTEGER NOT NULL,priority INTEGER NOT NULL,samesite INTEGER NOT NULL,source_scheme INTEGER NOT NULL,source_port INTEGER NOT NULL)`
+
+var cookieColumns = []string{
+	"creation_utc", "host_key", "name", "value", "path", "expires_utc", "is_secure",
+	"is_httponly", "last_access_utc", "has_expires", "is_persistent", "priority", "samesite",
+	"source_scheme", "source_port",
+}
+
+// metaCreateSQL/metaRows recreate the "meta" table Chromium's SQLitePersistentCookieStore checks
+// on open to decide whether it needs to run schema migrations. The version numbers are a
+// best-effort match for the schema cookiesCreateSQL writes; if the kernel-browser Chromium build
+// this targets moves to a newer cookie schema version, these will need bumping alongside it.
+const metaCreateSQL = `CREATE TABLE meta(key LONGVARCHAR NOT NULL UNIQUE PRIMARY KEY, value LONGVARCHAR)`
+
+const cookiesSchemaVersion = "20"
+
+var metaRows = [][]any{
+	{"version", cookiesSchemaVersion},
+	{"last_compatible_version", cookiesSchemaVersion},
+}
+
+// chromiumEpochOffsetMicros is the number of microseconds between the Windows FILETIME epoch
+// (1601-01-01) that Chromium stores cookie timestamps against and the Unix epoch.
+const chromiumEpochOffsetMicros = 11644473600000000
+
+func fromChromiumTime(v int64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(v - chromiumEpochOffsetMicros).UTC()
+}
+
+func toChromiumTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMicro() + chromiumEpochOffsetMicros
+}
+
+// Chromium's CookieSameSite enum values, as stored in the samesite column.
+const (
+	chromiumSameSiteUnspecified   = -1
+	chromiumSameSiteNoRestriction = 0
+	chromiumSameSiteLax           = 1
+	chromiumSameSiteStrict        = 2
+)
+
+func fromChromiumSameSite(v int64) http.SameSite {
+	switch v {
+	case chromiumSameSiteLax:
+		return http.SameSiteLaxMode
+	case chromiumSameSiteStrict:
+		return http.SameSiteStrictMode
+	case chromiumSameSiteNoRestriction:
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
+
+func toChromiumSameSite(s http.SameSite) int64 {
+	switch s {
+	case http.SameSiteLaxMode:
+		return chromiumSameSiteLax
+	case http.SameSiteStrictMode:
+		return chromiumSameSiteStrict
+	case http.SameSiteNoneMode:
+		return chromiumSameSiteNoRestriction
+	default:
+		return chromiumSameSiteUnspecified
+	}
+}
+
+// readCookiesArchive extracts and parses the Cookies database out of a DownloadDirZip-style
+// zip, returning each row it can decode as an *http.Cookie. Rows with no plaintext value (i.e.
+// value is empty but encrypted_value is set) are skipped, since decrypting them needs
+// Chromium's OSCrypt key material, which this package has no access to; skipped rows are
+// reported via the returned skipped count rather than silently dropped.
+func readCookiesArchive(zipData []byte) (cookies []*http.Cookie, skipped int, err error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open profile zip: %w", err)
+	}
+
+	var cookiesFile *zip.File
+	for _, f := range zr.File {
+		if f.Name == cookiesEntryPath {
+			cookiesFile = f
+			break
+		}
+	}
+	if cookiesFile == nil {
+		return nil, 0, nil
+	}
+
+	rc, err := cookiesFile.Open()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s: %w", cookiesEntryPath, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", cookiesEntryPath, err)
+	}
+
+	return DecodeCookiesDB(data)
+}
+
+// DecodeCookiesDB parses a raw Chromium Cookies sqlite file (not wrapped in a zip), returning
+// each row it can decode as an *http.Cookie. Exported so callers that already have the database
+// on disk (e.g. profilediff, which reads it straight out of the live profile directory rather
+// than a DownloadDirZip archive) don't have to round-trip it through a zip first. See
+// readCookiesArchive's doc for why rows with an encrypted_value are skipped rather than erroring.
+func DecodeCookiesDB(data []byte) (cookies []*http.Cookie, skipped int, err error) {
+	cols, rows, err := readTable(data, "cookies")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse cookies database: %w", err)
+	}
+	idx := make(map[string]int, len(cols))
+	for i, c := range cols {
+		idx[c] = i
+	}
+
+	get := func(row []any, col string) any {
+		i, ok := idx[col]
+		if !ok || i >= len(row) {
+			return nil
+		}
+		return row[i]
+	}
+	asString := func(v any) string {
+		s, _ := v.(string)
+		return s
+	}
+	asInt := func(v any) int64 {
+		n, _ := v.(int64)
+		return n
+	}
+
+	for _, row := range rows {
+		value := asString(get(row, "value"))
+		if value == "" {
+			if enc, ok := get(row, "encrypted_value").([]byte); ok && len(enc) > 0 {
+				skipped++
+				continue
+			}
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name:     asString(get(row, "name")),
+			Value:    value,
+			Domain:   asString(get(row, "host_key")),
+			Path:     asString(get(row, "path")),
+			Expires:  fromChromiumTime(asInt(get(row, "expires_utc"))),
+			Secure:   asInt(get(row, "is_secure")) != 0,
+			HttpOnly: asInt(get(row, "is_httponly")) != 0,
+			SameSite: fromChromiumSameSite(asInt(get(row, "samesite"))),
+		})
+	}
+	return cookies, skipped, nil
+}
+
+// cookieDomainMatches reports whether a cookie stored under host_key applies to host, following
+// Chromium's convention of a leading '.' meaning "this domain and its subdomains".
+func cookieDomainMatches(hostKey, host string) bool {
+	if !strings.HasPrefix(hostKey, ".") {
+		return strings.EqualFold(hostKey, host)
+	}
+	domain := strings.ToLower(strings.TrimPrefix(hostKey, "."))
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func cookiePathMatches(cookiePath, requestPath string) bool {
+	if cookiePath == "" || cookiePath == "/" {
+		return true
+	}
+	return strings.HasPrefix(requestPath, cookiePath)
+}
+
+// CookiesFromChromiumProfile reads the Cookies database out of a DownloadDirZip-style zip of a
+// Chromium user-data directory and returns a net/http/cookiejar.Jar preloaded with the cookies
+// that apply to rawURL (by domain, path, and secure-scheme matching), ready to assign directly
+// to an http.Client's Jar field.
+func CookiesFromChromiumProfile(zipData []byte, rawURL string) (http.CookieJar, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+
+	all, _, err := readCookiesArchive(zipData)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*http.Cookie
+	for _, c := range all {
+		if !cookieDomainMatches(c.Domain, target.Hostname()) {
+			continue
+		}
+		if !cookiePathMatches(c.Path, target.Path) {
+			continue
+		}
+		if c.Secure && target.Scheme != "https" {
+			continue
+		}
+		matched = append(matched, c)
+	}
+
+	jar, err := stdjar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	jar.SetCookies(target, matched)
+	return jar, nil
+}
+
+// cookieKey identifies a cookie row the way Chromium's SQLitePersistentCookieStore does for
+// upserts: (host_key, name, path). Two rows with the same key are the same cookie.
+type cookieKey struct{ host, name, path string }
+
+// buildCookiesDB rebuilds a Cookies sqlite database from existing plus upserts (later entries in
+// either slice for the same key win), dropping any row whose key is in removals. It's the shared
+// core of MergeCookiesIntoProfile and MergeCookiesIntoDB.
+func buildCookiesDB(existing, upserts []*http.Cookie, removals map[cookieKey]bool) ([]byte, error) {
+	merged := make(map[cookieKey]*http.Cookie, len(existing)+len(upserts))
+	var order []cookieKey
+	add := func(c *http.Cookie) {
+		k := cookieKey{c.Domain, c.Name, c.Path}
+		if removals[k] {
+			return
+		}
+		if _, ok := merged[k]; !ok {
+			order = append(order, k)
+		}
+		merged[k] = c
+	}
+	for _, c := range existing {
+		add(c)
+	}
+	for _, c := range upserts {
+		add(c)
+	}
+
+	now := toChromiumTime(time.Now())
+	var rows [][]any
+	for _, k := range order {
+		c := merged[k]
+		// Column order here must match cookieColumns/cookiesCreateSQL.
+		row := []any{
+			now,
+			c.Domain,
+			c.Name,
+			c.Value,
+			c.Path,
+			toChromiumTime(c.Expires),
+			boolToInt(c.Secure),
+			boolToInt(c.HttpOnly),
+			now,
+			int64(1),
+			int64(1),
+			int64(1),
+			toChromiumSameSite(c.SameSite),
+			int64(boolToInt(c.Secure)), // source_scheme: 1 (secure) if the cookie itself requires https, 0 otherwise.
+			int64(-1),
+		}
+		if len(row) != len(cookieColumns) {
+			return nil, fmt.Errorf("internal error: built %d cookie column values, schema has %d", len(row), len(cookieColumns))
+		}
+		rows = append(rows, row)
+	}
+
+	db, err := writeDatabase([]tableSpec{
+		{name: "meta", createSQL: metaCreateSQL, rows: metaRows},
+		{name: "cookies", createSQL: cookiesCreateSQL, rows: rows},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build cookies database: %w", err)
+	}
+	return db, nil
+}
+
+// MergeCookiesIntoProfile merges cookies into the Cookies database inside a DownloadDirZip-style
+// zip, overwriting any existing row with the same (host_key, name, path) and appending the rest,
+// and returns the resulting zip. It doesn't require spinning up Chromium: the merged Cookies
+// database is rebuilt from scratch with writeDatabase and spliced back into the zip archive.
+func MergeCookiesIntoProfile(zipData []byte, cookies []*http.Cookie) ([]byte, error) {
+	existing, _, err := readCookiesArchive(zipData)
+	if err != nil {
+		return nil, err
+	}
+
+	newCookiesDB, err := buildCookiesDB(existing, cookies, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return spliceZipEntry(zipData, cookiesEntryPath, newCookiesDB)
+}
+
+// MergeCookiesIntoDB applies upserts and removed to a raw Cookies sqlite file (as read straight
+// out of a live profile directory, not a zip) and returns the resulting database bytes. removed
+// cookies are matched by (Domain, Name, Path), same as upserts' overwrite key.
+func MergeCookiesIntoDB(dbData []byte, upserts, removed []*http.Cookie) ([]byte, error) {
+	existing, _, err := DecodeCookiesDB(dbData)
+	if err != nil {
+		return nil, err
+	}
+
+	removals := make(map[cookieKey]bool, len(removed))
+	for _, c := range removed {
+		removals[cookieKey{c.Domain, c.Name, c.Path}] = true
+	}
+
+	return buildCookiesDB(existing, upserts, removals)
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// spliceZipEntry returns a copy of zipData with the entry at name replaced by content (or added,
+// if it isn't already present), leaving every other entry untouched.
+func spliceZipEntry(zipData []byte, name string, content []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	replaced := false
+	for _, f := range zr.File {
+		hdr := f.FileHeader
+		fw, err := zw.CreateHeader(&hdr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write zip entry %s: %w", f.Name, err)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Name == name {
+			replaced = true
+			if _, err := fw.Write(content); err != nil {
+				return nil, fmt.Errorf("failed to write replacement for %s: %w", name, err)
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip entry %s: %w", f.Name, err)
+		}
+		_, err = io.Copy(fw, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy zip entry %s: %w", f.Name, err)
+		}
+	}
+	if !replaced {
+		fw, err := zw.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", name, err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize zip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// savedCookie is PersistentJar's on-disk representation: one row per (origin, cookie) pair, in
+// the JSON shape proton-bridge's cookie persister uses so a Save/Load round trip doesn't need
+// Chromium at all.
+type savedCookie struct {
+	Origin   string        `json:"origin"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain"`
+	Path     string        `json:"path"`
+	Expires  time.Time     `json:"expires"`
+	Secure   bool          `json:"secure"`
+	HttpOnly bool          `json:"http_only"`
+	SameSite http.SameSite `json:"same_site"`
+}
+
+// PersistentJar is a net/http/cookiejar.Jar that also remembers every cookie it's been given, so
+// it can be serialized with Save and restored with Load - unlike the stdlib jar, which has no way
+// to enumerate its contents once cookies have been set.
+type PersistentJar struct {
+	mu      sync.Mutex
+	jar     *stdjar.Jar
+	entries map[string][]savedCookie
+}
+
+// NewPersistentJar returns an empty PersistentJar, ready to have cookies set directly via
+// SetCookies or restored from a prior Save via Load.
+func NewPersistentJar() (*PersistentJar, error) {
+	jar, err := stdjar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	return &PersistentJar{jar: jar, entries: make(map[string][]savedCookie)}, nil
+}
+
+// SetCookies implements http.CookieJar, recording cookies against u's origin in addition to
+// delegating to the underlying jar, so Save can later reconstruct them.
+func (p *PersistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	p.jar.SetCookies(u, cookies)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	origin := u.Scheme + "://" + u.Host
+	for _, c := range cookies {
+		p.entries[origin] = append(p.entries[origin], savedCookie{
+			Origin: origin, Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path,
+			Expires: c.Expires, Secure: c.Secure, HttpOnly: c.HttpOnly, SameSite: c.SameSite,
+		})
+	}
+}
+
+// Cookies implements http.CookieJar.
+func (p *PersistentJar) Cookies(u *url.URL) []*http.Cookie {
+	return p.jar.Cookies(u)
+}
+
+// Save returns every cookie PersistentJar has been given since creation (or the last Load), as
+// JSON.
+func (p *PersistentJar) Save() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var all []savedCookie
+	for _, cookies := range p.entries {
+		all = append(all, cookies...)
+	}
+	return json.Marshal(all)
+}
+
+// Load replaces PersistentJar's contents with cookies previously produced by Save.
+func (p *PersistentJar) Load(data []byte) error {
+	var all []savedCookie
+	if err := json.Unmarshal(data, &all); err != nil {
+		return fmt.Errorf("failed to parse saved cookies: %w", err)
+	}
+
+	jar, err := stdjar.New(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jar = jar
+	p.entries = make(map[string][]savedCookie)
+	for _, sc := range all {
+		u, err := url.Parse(sc.Origin)
+		if err != nil {
+			continue
+		}
+		p.jar.SetCookies(u, []*http.Cookie{{
+			Name: sc.Name, Value: sc.Value, Domain: sc.Domain, Path: sc.Path,
+			Expires: sc.Expires, Secure: sc.Secure, HttpOnly: sc.HttpOnly, SameSite: sc.SameSite,
+		}})
+		p.entries[sc.Origin] = append(p.entries[sc.Origin], sc)
+	}
+	return nil
+}
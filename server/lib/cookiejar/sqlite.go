@@ -0,0 +1,325 @@
+// Package cookiejar reads and writes Chromium's "Cookies" SQLite database so a downloaded
+// user-data profile (the zip produced by the instance server's DownloadDirZip, the same one
+// server/e2e/e2e_persist_login_test.go's downloadUserDataDir saves) can be round-tripped through
+// a net/http/cookiejar.Jar instead of a second Chromium launch.
+//
+// There's no SQLite driver in this repo's dependency set (see server/lib/extsnapshot and
+// server/lib/exttrust for the established precedent of hand-rolling a narrow, format-specific
+// reader/writer instead of adding one), so this package implements just enough of the SQLite
+// file format to read and write a single table: simple table b-trees, at most one interior
+// level, no WAL journal and no overflow pages. A row so large it needs an overflow page is
+// rejected with an error rather than silently misread, and writing a table with enough rows to
+// need a second interior level is rejected too - see readLeafCell and layoutTable.
+package cookiejar
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const sqliteHeaderMagic = "SQLite format 3\x00"
+const sqliteFileHeaderSize = 100
+
+const (
+	pageTypeInteriorIndex = 0x02
+	pageTypeInteriorTable = 0x05
+	pageTypeLeafIndex     = 0x0a
+	pageTypeLeafTable     = 0x0d
+)
+
+// db is a read-only view over an in-memory SQLite file sufficient to walk its table b-trees.
+type db struct {
+	data     []byte
+	pageSize int
+}
+
+func openDB(data []byte) (*db, error) {
+	if len(data) < sqliteFileHeaderSize || string(data[:16]) != sqliteHeaderMagic {
+		return nil, fmt.Errorf("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || len(data)%pageSize != 0 {
+		return nil, fmt.Errorf("invalid or unsupported page size %d", pageSize)
+	}
+	reserved := int(data[20])
+	if reserved != 0 {
+		return nil, fmt.Errorf("reserved page space is not supported")
+	}
+	return &db{data: data, pageSize: pageSize}, nil
+}
+
+// page returns the raw bytes of 1-indexed page n.
+func (d *db) page(n int) []byte {
+	return d.data[(n-1)*d.pageSize : n*d.pageSize]
+}
+
+// readVarint decodes a SQLite variable-length integer starting at b[pos], returning its value
+// and the number of bytes consumed (1-9).
+func readVarint(b []byte, pos int) (int64, int) {
+	var v int64
+	for i := 0; i < 8; i++ {
+		c := b[pos+i]
+		v = (v << 7) | int64(c&0x7f)
+		if c&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	v = (v << 8) | int64(b[pos+8])
+	return v, 9
+}
+
+// walkTableRows visits every row stored under the table b-tree rooted at rootPage, in rowid
+// order, calling visit with each row's decoded column values.
+func (d *db) walkTableRows(rootPage int, visit func(values []any) error) error {
+	return d.walkTablePage(rootPage, 0, visit)
+}
+
+// headerOffset is 100 for page 1 (which carries the file header before its b-tree page header)
+// and 0 for every other page.
+func (d *db) walkTablePage(pageNum int, headerOffset int, visit func(values []any) error) error {
+	page := d.page(pageNum)
+	typ := page[headerOffset]
+
+	numCells := int(binary.BigEndian.Uint16(page[headerOffset+3 : headerOffset+5]))
+	cellPtrStart := headerOffset + 8
+	if typ == pageTypeInteriorTable {
+		cellPtrStart = headerOffset + 12
+	}
+
+	switch typ {
+	case pageTypeLeafTable:
+		for i := 0; i < numCells; i++ {
+			cellOffset := int(binary.BigEndian.Uint16(page[cellPtrStart+2*i : cellPtrStart+2*i+2]))
+			values, err := d.readLeafCell(page, cellOffset)
+			if err != nil {
+				return err
+			}
+			if err := visit(values); err != nil {
+				return err
+			}
+		}
+		return nil
+	case pageTypeInteriorTable:
+		for i := 0; i < numCells; i++ {
+			cellOffset := int(binary.BigEndian.Uint16(page[cellPtrStart+2*i : cellPtrStart+2*i+2]))
+			childPage := int(binary.BigEndian.Uint32(page[cellOffset : cellOffset+4]))
+			if err := d.walkTablePage(childPage, 0, visit); err != nil {
+				return err
+			}
+		}
+		rightmost := int(binary.BigEndian.Uint32(page[headerOffset+8 : headerOffset+12]))
+		return d.walkTablePage(rightmost, 0, visit)
+	default:
+		return fmt.Errorf("unsupported b-tree page type 0x%02x", typ)
+	}
+}
+
+// readLeafCell decodes one table-leaf cell's record, rejecting it if its payload spills onto an
+// overflow page (see the package doc for why that's out of scope).
+func (d *db) readLeafCell(page []byte, offset int) ([]any, error) {
+	payloadLen, n := readVarint(page, offset)
+	offset += n
+	_, n = readVarint(page, offset) // rowid, unused: callers read values by column, not rowid.
+	offset += n
+
+	usable := d.pageSize
+	maxLocal := usable - 35
+	if int(payloadLen) > maxLocal {
+		return nil, fmt.Errorf("row payload of %d bytes requires an overflow page, which is unsupported", payloadLen)
+	}
+	return decodeRecord(page[offset : offset+int(payloadLen)])
+}
+
+// decodeRecord parses a SQLite record (header of serial-type varints, followed by the column
+// values they describe) into column-ordered Go values: nil, int64, float64, string, or []byte.
+func decodeRecord(record []byte) ([]any, error) {
+	headerLen, n := readVarint(record, 0)
+	pos := n
+	var serialTypes []int64
+	for pos < int(headerLen) {
+		st, n := readVarint(record, pos)
+		serialTypes = append(serialTypes, st)
+		pos += n
+	}
+
+	body := int(headerLen)
+	values := make([]any, len(serialTypes))
+	for i, st := range serialTypes {
+		v, consumed, err := decodeSerialValue(st, record, body)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+		body += consumed
+	}
+	return values, nil
+}
+
+func decodeSerialValue(serialType int64, b []byte, pos int) (any, int, error) {
+	switch serialType {
+	case 0:
+		return nil, 0, nil
+	case 1:
+		return int64(int8(b[pos])), 1, nil
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(b[pos : pos+2]))), 2, nil
+	case 3:
+		v := int64(b[pos])<<16 | int64(b[pos+1])<<8 | int64(b[pos+2])
+		if b[pos]&0x80 != 0 {
+			v -= 1 << 24
+		}
+		return v, 3, nil
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(b[pos : pos+4]))), 4, nil
+	case 5:
+		v := int64(0)
+		for i := 0; i < 6; i++ {
+			v = v<<8 | int64(b[pos+i])
+		}
+		if b[pos]&0x80 != 0 {
+			v -= 1 << 48
+		}
+		return v, 6, nil
+	case 6:
+		return int64(binary.BigEndian.Uint64(b[pos : pos+8])), 8, nil
+	case 7:
+		return math.Float64frombits(binary.BigEndian.Uint64(b[pos : pos+8])), 8, nil
+	case 8:
+		return int64(0), 0, nil
+	case 9:
+		return int64(1), 0, nil
+	default:
+		if serialType >= 12 && serialType%2 == 0 {
+			length := int((serialType - 12) / 2)
+			return append([]byte(nil), b[pos:pos+length]...), length, nil
+		}
+		if serialType >= 13 && serialType%2 == 1 {
+			length := int((serialType - 13) / 2)
+			return string(b[pos : pos+length]), length, nil
+		}
+		return nil, 0, fmt.Errorf("unsupported serial type %d", serialType)
+	}
+}
+
+// findTable locates tableName in the sqlite_master table (which always lives on page 1) and
+// returns its root page number and CREATE TABLE statement.
+func (d *db) findTable(tableName string) (rootPage int, createSQL string, err error) {
+	found := false
+	err = d.walkTablePage(1, sqliteFileHeaderSize, func(values []any) error {
+		if found || len(values) < 5 {
+			return nil
+		}
+		typ, _ := values[0].(string)
+		name, _ := values[1].(string)
+		if typ != "table" || name != tableName {
+			return nil
+		}
+		rp, _ := values[3].(int64)
+		sql, _ := values[4].(string)
+		rootPage, createSQL, found = int(rp), sql, true
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	if !found {
+		return 0, "", fmt.Errorf("table %q not found", tableName)
+	}
+	return rootPage, createSQL, nil
+}
+
+// columnNames extracts column names, in declaration order, from a CREATE TABLE statement,
+// skipping table-level constraints (UNIQUE/PRIMARY KEY/CHECK/FOREIGN KEY/CONSTRAINT).
+func columnNames(createSQL string) ([]string, error) {
+	open := strings.IndexByte(createSQL, '(')
+	if open < 0 {
+		return nil, fmt.Errorf("malformed CREATE TABLE statement")
+	}
+	depth := 0
+	closeIdx := -1
+	for i := open; i < len(createSQL); i++ {
+		switch createSQL[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				closeIdx = i
+			}
+		}
+		if closeIdx >= 0 {
+			break
+		}
+	}
+	if closeIdx < 0 {
+		return nil, fmt.Errorf("malformed CREATE TABLE statement")
+	}
+
+	var names []string
+	for _, part := range splitTopLevel(createSQL[open+1 : closeIdx]) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		upper := strings.ToUpper(part)
+		if strings.HasPrefix(upper, "PRIMARY ") || strings.HasPrefix(upper, "UNIQUE") ||
+			strings.HasPrefix(upper, "CHECK") || strings.HasPrefix(upper, "FOREIGN") ||
+			strings.HasPrefix(upper, "CONSTRAINT") {
+			continue
+		}
+		field := strings.Fields(part)[0]
+		names = append(names, strings.Trim(field, `"`+"`"+`[]`))
+	}
+	return names, nil
+}
+
+// splitTopLevel splits s on commas that aren't nested inside parentheses.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, s[start:])
+	return parts
+}
+
+// readTable reads every row of tableName out of a SQLite file's bytes, returning its columns (in
+// declared order) and each row's values in that same order.
+func readTable(data []byte, tableName string) (cols []string, rows [][]any, err error) {
+	d, err := openDB(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	rootPage, createSQL, err := d.findTable(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	cols, err = columnNames(createSQL)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = d.walkTableRows(rootPage, func(values []any) error {
+		rows = append(rows, values)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return cols, rows, nil
+}
@@ -0,0 +1,332 @@
+package cookiejar
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// writerPageSize is fixed rather than inherited from an input file, since writeDatabase always
+// produces a brand-new file from scratch.
+const writerPageSize = 4096
+
+// tableSpec describes one table to lay out in a database built by writeDatabase.
+type tableSpec struct {
+	name      string
+	createSQL string
+	rows      [][]any
+}
+
+// leafInfo records where a table's leaf page ended up and the largest rowid it holds, so an
+// interior page can be built to point at it (see renderInteriorPage).
+type leafInfo struct {
+	page     int
+	maxRowid int64
+}
+
+// writeDatabase serializes tables into a minimal single-file SQLite database: a page-1 schema
+// table listing each of tables in order, followed by each table's own pages. Every table is
+// written as a fresh table b-tree with sequentially-assigned rowids, which keeps the b-tree
+// trivially valid to construct: at most one interior page per table, sized generously enough
+// (~450 children) that it isn't expected to run out for any realistic number of cookies - see
+// the package doc for why a second interior level isn't supported.
+func writeDatabase(tables []tableSpec) ([]byte, error) {
+	nextPage := 2 // page 1 is reserved for the schema table laid out last, once roots are known.
+
+	type laidOutTable struct {
+		spec     tableSpec
+		rootPage int
+		pages    map[int][]byte
+	}
+	laidOut := make([]laidOutTable, len(tables))
+	for i, t := range tables {
+		root, pages, newNext, err := layoutTable(nextPage, t.rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lay out table %q: %w", t.name, err)
+		}
+		laidOut[i] = laidOutTable{spec: t, rootPage: root, pages: pages}
+		nextPage = newNext
+	}
+
+	var schemaRows [][]any
+	for _, t := range laidOut {
+		schemaRows = append(schemaRows, []any{"table", t.spec.name, t.spec.name, int64(t.rootPage), t.spec.createSQL})
+	}
+	_, schemaPages, _, err := layoutTableAt(1, schemaRows, sqliteFileHeaderSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lay out schema table: %w", err)
+	}
+	if len(schemaPages) != 1 {
+		// Page 1 must stay exactly one page: its content area is physically bounded by the
+		// 100-byte file header, so a schema that overflows a second page can't simply move there
+		// without colliding with the table pages already laid out starting at page 2.
+		return nil, fmt.Errorf("schema table (%d tables) does not fit on page 1", len(tables))
+	}
+
+	totalPages := nextPage - 1
+	out := make([]byte, totalPages*writerPageSize)
+	writeFileHeader(out, totalPages)
+	for pageNum, content := range schemaPages {
+		copy(out[(pageNum-1)*writerPageSize:pageNum*writerPageSize], content)
+	}
+	for _, t := range laidOut {
+		for pageNum, content := range t.pages {
+			copy(out[(pageNum-1)*writerPageSize:pageNum*writerPageSize], content)
+		}
+	}
+	return out, nil
+}
+
+func writeFileHeader(out []byte, totalPages int) {
+	copy(out[0:16], sqliteHeaderMagic)
+	binary.BigEndian.PutUint16(out[16:18], uint16(writerPageSize))
+	out[18], out[19] = 1, 1 // file format write/read version: legacy (non-WAL)
+	out[21], out[22], out[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(out[28:32], uint32(totalPages))
+	binary.BigEndian.PutUint32(out[44:48], 1) // schema cookie
+	binary.BigEndian.PutUint32(out[96:100], 3045000)
+}
+
+// layoutTable lays rows out into one or more leaf pages (plus one interior page if more than one
+// leaf is needed) starting at startPage, returning the table's root page number, the page
+// contents keyed by page number, and the next unused page number.
+func layoutTable(startPage int, rows [][]any) (rootPage int, pages map[int][]byte, nextPage int, err error) {
+	return layoutTableAt(startPage, rows, 0)
+}
+
+// layoutTableAt is layoutTable with an explicit page-1-style header offset on the first leaf
+// page it emits (100 for the schema table, 0 for every other table).
+func layoutTableAt(startPage int, rows [][]any, firstPageHeaderOffset int) (rootPage int, pages map[int][]byte, nextPage int, err error) {
+	pages = make(map[int][]byte)
+
+	var leaves []leafInfo
+
+	pageNum := startPage
+	headerOffset := firstPageHeaderOffset
+	var cells [][]byte
+	var rowid int64
+
+	flush := func() error {
+		if len(cells) == 0 && headerOffset == 0 {
+			return nil
+		}
+		content, err := renderLeafPage(cells, headerOffset)
+		if err != nil {
+			return err
+		}
+		pages[pageNum] = content
+		leaves = append(leaves, leafInfo{page: pageNum, maxRowid: rowid})
+		pageNum++
+		cells = nil
+		headerOffset = 0
+		return nil
+	}
+
+	usable := writerPageSize
+	for _, row := range rows {
+		rowid++
+		cell, err := encodeLeafCell(rowid, row)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		used := 8 + 2*(len(cells)+1) + sumLen(cells) + len(cell)
+		if headerOffset > 0 {
+			used += headerOffset
+		}
+		if len(cells) > 0 && used > usable {
+			if err := flush(); err != nil {
+				return 0, nil, 0, err
+			}
+		}
+		cells = append(cells, cell)
+	}
+	if err := flush(); err != nil {
+		return 0, nil, 0, err
+	}
+	if len(leaves) == 0 {
+		// An empty table still needs one (empty) leaf page to serve as its root.
+		content, err := renderLeafPage(nil, firstPageHeaderOffset)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		pages[pageNum] = content
+		leaves = append(leaves, leafInfo{page: pageNum})
+		pageNum++
+	}
+
+	if len(leaves) == 1 {
+		return leaves[0].page, pages, pageNum, nil
+	}
+
+	const maxInteriorChildren = 450
+	if len(leaves) > maxInteriorChildren {
+		return 0, nil, 0, fmt.Errorf("%d leaf pages exceeds the %d a single interior page can address", len(leaves), maxInteriorChildren)
+	}
+	interiorPage := pageNum
+	pageNum++
+	content, err := renderInteriorPage(leaves)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	pages[interiorPage] = content
+	return interiorPage, pages, pageNum, nil
+}
+
+func sumLen(bs [][]byte) int {
+	n := 0
+	for _, b := range bs {
+		n += len(b)
+	}
+	return n
+}
+
+// renderLeafPage lays cells out on a single writerPageSize page, cell pointers growing down from
+// headerOffset+8 and cell content growing up from the page's end, exactly like SQLite itself.
+func renderLeafPage(cells [][]byte, headerOffset int) ([]byte, error) {
+	page := make([]byte, writerPageSize)
+	page[headerOffset] = pageTypeLeafTable
+	binary.BigEndian.PutUint16(page[headerOffset+3:headerOffset+5], uint16(len(cells)))
+
+	contentStart := writerPageSize
+	ptrBase := headerOffset + 8
+	for i, cell := range cells {
+		contentStart -= len(cell)
+		if contentStart < ptrBase+2*len(cells) {
+			return nil, fmt.Errorf("page overflow while laying out leaf page")
+		}
+		copy(page[contentStart:], cell)
+		binary.BigEndian.PutUint16(page[ptrBase+2*i:ptrBase+2*i+2], uint16(contentStart))
+	}
+	if contentStart == writerPageSize {
+		contentStart = 0 // SQLite encodes "no cells" as a 0 (rather than 65536) content-start offset.
+	}
+	binary.BigEndian.PutUint16(page[headerOffset+5:headerOffset+7], uint16(contentStart))
+	return page, nil
+}
+
+// renderInteriorPage builds a table-interior page whose cells are ordered (child, maxRowidInChild)
+// pairs for every leaf but the last, with the last leaf referenced via the page's right-most
+// pointer - the standard SQLite table-interior-page layout.
+func renderInteriorPage(leaves []leafInfo) ([]byte, error) {
+	page := make([]byte, writerPageSize)
+	page[0] = pageTypeInteriorTable
+	numCells := len(leaves) - 1
+	binary.BigEndian.PutUint16(page[3:5], uint16(numCells))
+	binary.BigEndian.PutUint32(page[8:12], uint32(leaves[len(leaves)-1].page))
+
+	contentStart := writerPageSize
+	ptrBase := 12
+	for i := 0; i < numCells; i++ {
+		cell := make([]byte, 4, 13)
+		binary.BigEndian.PutUint32(cell, uint32(leaves[i].page))
+		cell = appendVarint(cell, leaves[i].maxRowid)
+		contentStart -= len(cell)
+		copy(page[contentStart:], cell)
+		binary.BigEndian.PutUint16(page[ptrBase+2*i:ptrBase+2*i+2], uint16(contentStart))
+	}
+	binary.BigEndian.PutUint16(page[5:7], uint16(contentStart))
+	return page, nil
+}
+
+// encodeLeafCell encodes one table-leaf cell: varint(payload length), varint(rowid), record.
+func encodeLeafCell(rowid int64, row []any) ([]byte, error) {
+	record, err := encodeRecord(row)
+	if err != nil {
+		return nil, err
+	}
+	cell := appendVarint(nil, int64(len(record)))
+	cell = appendVarint(cell, rowid)
+	return append(cell, record...), nil
+}
+
+// encodeRecord serializes row into a SQLite record: a header of serial-type varints (prefixed by
+// its own varint-encoded length) followed by each value's body bytes.
+func encodeRecord(row []any) ([]byte, error) {
+	var header []byte
+	var bodies [][]byte
+	for _, v := range row {
+		st, body, err := encodeSerialValue(v)
+		if err != nil {
+			return nil, err
+		}
+		header = appendVarint(header, st)
+		bodies = append(bodies, body)
+	}
+
+	// The header includes its own length, which can itself change the header's length - at most
+	// one byte's worth for any record this package ever writes (a handful of cookie columns),
+	// so growing the varint once more after the initial guess is always enough to converge.
+	headerLen := int64(len(header)) + 1
+	for {
+		lenVarint := appendVarint(nil, headerLen)
+		if int64(len(lenVarint)+len(header)) == headerLen {
+			header = append(lenVarint, header...)
+			break
+		}
+		headerLen = int64(len(lenVarint) + len(header))
+	}
+
+	record := header
+	for _, b := range bodies {
+		record = append(record, b...)
+	}
+	return record, nil
+}
+
+func encodeSerialValue(v any) (serialType int64, body []byte, err error) {
+	switch x := v.(type) {
+	case nil:
+		return 0, nil, nil
+	case int64:
+		if x == 0 {
+			return 8, nil, nil
+		}
+		if x == 1 {
+			return 9, nil, nil
+		}
+		body := make([]byte, 8)
+		binary.BigEndian.PutUint64(body, uint64(x))
+		return 6, body, nil
+	case string:
+		return int64(13 + 2*len(x)), []byte(x), nil
+	case []byte:
+		return int64(12 + 2*len(x)), x, nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported column value type %T", v)
+	}
+}
+
+// appendVarint appends v's SQLite varint encoding to b, mirroring sqlite3PutVarint: at most 9
+// bytes, each carrying 7 bits big-endian with a continuation flag in its high bit, except that a
+// 9-byte encoding's final byte carries a full 8 bits instead (7*8=56 bits from the first 8 bytes
+// plus this byte's 8 is exactly 64).
+func appendVarint(b []byte, v int64) []byte {
+	u := uint64(v)
+	if u&0xff00000000000000 != 0 {
+		var p [9]byte
+		p[8] = byte(u)
+		u >>= 8
+		for i := 7; i >= 0; i-- {
+			p[i] = byte(u&0x7f) | 0x80
+			u >>= 7
+		}
+		return append(b, p[:]...)
+	}
+
+	var buf [9]byte
+	n := 0
+	for {
+		buf[n] = byte(u&0x7f) | 0x80
+		u >>= 7
+		n++
+		if u == 0 {
+			break
+		}
+	}
+	buf[0] &^= 0x80 // the least-significant group, emitted last below, terminates the varint.
+
+	out := make([]byte, n)
+	for i, j := 0, n-1; j >= 0; j, i = j-1, i+1 {
+		out[i] = buf[j]
+	}
+	return append(b, out...)
+}
@@ -0,0 +1,258 @@
+// Package crashreport turns a Chromium crash from a silent, wiped-on-next-restore event into a
+// retained artifact: Collect scans Crashpad's on-disk database for minidumps a previous call
+// hasn't already captured, pairs each with a tail of Chromium's supervisord log and caller-
+// supplied metadata (instance ID, Chromium version, the profile snapshot/checkpoint in effect at
+// the time), records it in a Store so GET /browser/crashes can list and download it later, and
+// ships a copy to whichever Sink the deployment is configured with.
+package crashreport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// crashpadCompletedDir is where Crashpad, Chromium's crash handler, leaves minidumps once
+// they're fully written and ready to upload - see crashpad's CrashReportDatabase layout
+// (pending/completed/attachments under the database root, which Chromium places at
+// <user-data-dir>/Crashpad when no --breakpad-dump-location is given; chromium-launcher gives none).
+const crashpadCompletedDir = "Crashpad/completed"
+
+// Report is one captured crash's metadata - the JSON shape GET /browser/crashes and
+// /browser/crashes/{id} return.
+type Report struct {
+	ID                string    `json:"id"`
+	InstanceID        string    `json:"instanceId,omitempty"`
+	ChromiumVersion   string    `json:"chromiumVersion,omitempty"`
+	ProfileSnapshotID string    `json:"profileSnapshotId,omitempty"`
+	Timestamp         time.Time `json:"timestamp"`
+	StderrTail        string    `json:"stderrTail,omitempty"`
+	// MinidumpSize is the captured .dmp's size in bytes, or 0 if Crashpad hadn't produced one
+	// (e.g. Chromium was killed before it could write out a report).
+	MinidumpSize int64 `json:"minidumpSize"`
+}
+
+// Metadata is the caller-supplied context Collect attaches to every report it captures in one
+// call - the parts Collect itself has no way to know.
+type Metadata struct {
+	InstanceID        string
+	ChromiumVersion   string
+	ProfileSnapshotID string
+}
+
+// Store persists Reports and their minidumps to local disk: <dir>/<id>.json for metadata,
+// <dir>/<id>.dmp for the minidump (absent if none was captured). It's always used regardless of
+// which Sink(s) a deployment also ships reports to, since GET /browser/crashes/{id} needs
+// somewhere to read the minidump back from even if the configured Sink is a push-only webhook.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) metaPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *Store) minidumpPath(id string) string {
+	return filepath.Join(s.dir, id+".dmp")
+}
+
+// Save records report and, if minidump is non-nil, copies it into the store under report.ID.
+func (s *Store) Save(report Report, minidump io.Reader) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create crash report dir: %w", err)
+	}
+
+	if minidump != nil {
+		out, err := os.Create(s.minidumpPath(report.ID))
+		if err != nil {
+			return fmt.Errorf("failed to create minidump file: %w", err)
+		}
+		n, err := io.Copy(out, minidump)
+		out.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write minidump file: %w", err)
+		}
+		report.MinidumpSize = n
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal crash report: %w", err)
+	}
+	return os.WriteFile(s.metaPath(report.ID), data, 0o644)
+}
+
+// List returns every report Save has recorded, most recent first.
+func (s *Store) List() ([]Report, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list crash reports: %w", err)
+	}
+
+	var reports []Report
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Timestamp.After(reports[j].Timestamp) })
+	return reports, nil
+}
+
+// Get returns the report recorded under id.
+func (s *Store) Get(id string) (Report, error) {
+	data, err := os.ReadFile(s.metaPath(id))
+	if err != nil {
+		return Report{}, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, fmt.Errorf("failed to parse crash report: %w", err)
+	}
+	return report, nil
+}
+
+// OpenMinidump opens id's captured minidump for reading. The caller must Close it.
+func (s *Store) OpenMinidump(id string) (io.ReadCloser, error) {
+	return os.Open(s.minidumpPath(id))
+}
+
+// Collect scans userDataDir's Crashpad database for minidumps not already present in store,
+// saves a Report for each (built from meta plus a tail of chromiumLogPath), ships it to every
+// sink, and returns the newly captured reports. A failing sink is logged and skipped rather than
+// aborting collection - one bad webhook shouldn't stop the local Store (which GET /browser/crashes
+// reads from) from ever being updated.
+func Collect(ctx context.Context, store *Store, sinks []Sink, userDataDir, chromiumLogPath string, meta Metadata, log *slog.Logger) ([]Report, error) {
+	dumps, err := newMinidumps(store, userDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan crashpad database: %w", err)
+	}
+	if len(dumps) == 0 {
+		return nil, nil
+	}
+
+	tail := tailFile(chromiumLogPath, 4096)
+
+	var captured []Report
+	for _, path := range dumps {
+		base := filepath.Base(path)
+		report := Report{
+			ID:                base[:len(base)-len(filepath.Ext(base))], // Crashpad's own report ID - already a globally unique UUID.
+			InstanceID:        meta.InstanceID,
+			ChromiumVersion:   meta.ChromiumVersion,
+			ProfileSnapshotID: meta.ProfileSnapshotID,
+			Timestamp:         time.Now(),
+			StderrTail:        tail,
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			log.Error("failed to open minidump", "path", path, "err", err)
+			continue
+		}
+		err = store.Save(report, f)
+		f.Close()
+		if err != nil {
+			log.Error("failed to save crash report", "path", path, "err", err)
+			continue
+		}
+
+		for _, sink := range sinks {
+			minidump, err := store.OpenMinidump(report.ID)
+			if err != nil {
+				log.Error("failed to reopen minidump for sink", "id", report.ID, "err", err)
+				continue
+			}
+			err = sink.Ship(ctx, report, minidump)
+			minidump.Close()
+			if err != nil {
+				log.Error("failed to ship crash report", "id", report.ID, "err", err)
+			}
+		}
+
+		captured = append(captured, report)
+	}
+	return captured, nil
+}
+
+// newMinidumps returns the paths of every .dmp file under userDataDir's Crashpad completed
+// directory whose report ID (its filename, sans extension) store hasn't already captured.
+func newMinidumps(store *Store, userDataDir string) ([]string, error) {
+	dir := filepath.Join(userDataDir, crashpadCompletedDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	existing, _ := store.List()
+	for _, r := range existing {
+		seen[r.ID] = true
+	}
+
+	var dumps []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".dmp" {
+			continue
+		}
+		id := e.Name()[:len(e.Name())-len(filepath.Ext(e.Name()))]
+		if !seen[id] {
+			dumps = append(dumps, filepath.Join(dir, e.Name()))
+		}
+	}
+	return dumps, nil
+}
+
+// tailFile returns up to the last maxBytes of path's contents, or "" if it can't be read -
+// chromiumLogPath may not exist yet (Chromium never started) or may have rotated out from under
+// this call, neither of which should fail crash collection itself.
+func tailFile(path string, maxBytes int64) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	offset := int64(0)
+	if info.Size() > maxBytes {
+		offset = info.Size() - maxBytes
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return ""
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
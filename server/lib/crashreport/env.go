@@ -0,0 +1,112 @@
+package crashreport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// sinkSpec is one entry of KERNEL_CRASH_SINKS_JSON, naming the Sink it builds plus that sink's
+// own settings, mirroring vfs.mountSpec's "one scheme-tagged struct per backend" shape.
+type sinkSpec struct {
+	Type string `json:"type"` // "local", "s3", or "webhook"
+
+	// local
+	Dir string `json:"dir,omitempty"`
+
+	// s3
+	Bucket          string `json:"bucket,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"`
+	Prefix          string `json:"prefix,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+
+	// webhook
+	URL string `json:"url,omitempty"`
+}
+
+// LoadSinksFromEnv builds the Sinks Collect ships captured crashes to from KERNEL_CRASH_SINKS_JSON,
+// a JSON array of sinkSpecs. An empty/unset env var yields no sinks - crashes are still recorded
+// in the local Store (so GET /browser/crashes keeps working), they're just not shipped anywhere
+// else until a deployment opts in.
+func LoadSinksFromEnv() ([]Sink, error) {
+	raw := os.Getenv("KERNEL_CRASH_SINKS_JSON")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var specs []sinkSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("invalid KERNEL_CRASH_SINKS_JSON: %w", err)
+	}
+
+	sinks := make([]Sink, 0, len(specs))
+	for i, spec := range specs {
+		sink, err := newSink(spec)
+		if err != nil {
+			return nil, fmt.Errorf("crash sink %d: %w", i, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func newSink(spec sinkSpec) (Sink, error) {
+	switch spec.Type {
+	case "local":
+		if spec.Dir == "" {
+			return nil, fmt.Errorf("local sink missing dir")
+		}
+		return NewLocalSink(spec.Dir), nil
+
+	case "s3":
+		if spec.Bucket == "" {
+			return nil, fmt.Errorf("s3 sink missing bucket")
+		}
+		client, err := newS3Client(spec)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3Sink(client, spec.Bucket, spec.Prefix), nil
+
+	case "webhook":
+		if spec.URL == "" {
+			return nil, fmt.Errorf("webhook sink missing url")
+		}
+		return NewWebhookSink(spec.URL, http.DefaultClient), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported sink type %q", spec.Type)
+	}
+}
+
+func newS3Client(spec sinkSpec) (*s3.Client, error) {
+	ctx := context.Background()
+	var optFns []func(*awsconfig.LoadOptions) error
+	if spec.Region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(spec.Region))
+	}
+	if spec.AccessKeyID != "" {
+		optFns = append(optFns, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(spec.AccessKeyID, spec.SecretAccessKey, "")))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if spec.Endpoint != "" {
+			o.BaseEndpoint = aws.String(spec.Endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
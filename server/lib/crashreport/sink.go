@@ -0,0 +1,165 @@
+package crashreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Sink ships a captured crash report somewhere beyond Store's local disk - a second local
+// directory (e.g. a mounted volume meant to survive this container), an S3-compatible bucket, or
+// an HTTP collector endpoint. minidump is nil if Crashpad hadn't finished writing one out.
+type Sink interface {
+	Ship(ctx context.Context, report Report, minidump io.Reader) error
+}
+
+// LocalSink copies a report's metadata and minidump into a second local directory - useful when
+// that directory is itself a mounted volume, so crashes outlive this container without needing
+// S3 or a webhook collector configured.
+type LocalSink struct {
+	dir string
+}
+
+func NewLocalSink(dir string) *LocalSink {
+	return &LocalSink{dir: dir}
+}
+
+func (l *LocalSink) Ship(ctx context.Context, report Report, minidump io.Reader) error {
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create sink dir: %w", err)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(l.dir, report.ID+".json"), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write report metadata: %w", err)
+	}
+
+	if minidump == nil {
+		return nil
+	}
+	out, err := os.Create(filepath.Join(l.dir, report.ID+".dmp"))
+	if err != nil {
+		return fmt.Errorf("failed to create minidump copy: %w", err)
+	}
+	defer out.Close()
+	_, err = io.Copy(out, minidump)
+	return err
+}
+
+// S3Sink uploads a report's metadata and minidump as two objects keyed by "<Prefix><id>", the
+// same keying profilestore.S3ChunkStore and recorder.S3UploadSink use.
+type S3Sink struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+}
+
+func NewS3Sink(client *s3.Client, bucket, prefix string) *S3Sink {
+	return &S3Sink{Client: client, Bucket: bucket, Prefix: prefix}
+}
+
+func (s *S3Sink) Ship(ctx context.Context, report Report, minidump io.Reader) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.Prefix + report.ID + ".json"),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}); err != nil {
+		return fmt.Errorf("failed to upload report metadata: %w", err)
+	}
+
+	if minidump == nil {
+		return nil
+	}
+	if _, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Prefix + report.ID + ".dmp"),
+		Body:   minidump,
+	}); err != nil {
+		return fmt.Errorf("failed to upload minidump: %w", err)
+	}
+	return nil
+}
+
+// WebhookSink POSTs a report to an HTTP collector as multipart/form-data, with the minidump
+// under field name "upload_file_minidump" - the same field name Crashpad's and Breakpad's own
+// HTTP upload clients use, so an existing crash collector (e.g. a self-hosted Sentry/Tracker)
+// can ingest this without a kernel-specific adapter.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookSink{url: url, client: client}
+}
+
+func (w *WebhookSink) Ship(ctx context.Context, report Report, minidump io.Reader) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	fields := map[string]string{
+		"id":                report.ID,
+		"instanceId":        report.InstanceID,
+		"chromiumVersion":   report.ChromiumVersion,
+		"profileSnapshotId": report.ProfileSnapshotID,
+		"timestamp":         report.Timestamp.Format(http.TimeFormat),
+		"stderrTail":        report.StderrTail,
+	}
+	for k, v := range fields {
+		if v == "" {
+			continue
+		}
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+
+	if minidump != nil {
+		part, err := mw.CreateFormFile("upload_file_minidump", report.ID+".dmp")
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, minidump); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post crash report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("crash report webhook returned %s", resp.Status)
+	}
+	return nil
+}
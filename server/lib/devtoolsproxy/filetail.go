@@ -0,0 +1,150 @@
+package devtoolsproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileTailStatPollInterval is the fallback cadence fileTailer polls the log file on, for
+// filesystems (overlayfs, some container bind mounts) where fsnotify's inotify backend doesn't
+// fire reliably.
+const fileTailStatPollInterval = 2 * time.Second
+
+// FileTailDiscovery returns the DiscoveryFunc NewUpstreamManager has always used by default:
+// watching path for lines matching devtoolsListeningRegexp. Unlike the old `tail -f` subprocess,
+// it's pure Go, watches via fsnotify with a periodic stat poll as a fallback, and reopens path from
+// offset 0 whenever rotation (the inode changes) or truncation (the size shrinks) is detected,
+// instead of silently falling behind a file that's no longer the one it started reading.
+func FileTailDiscovery(path string) DiscoveryFunc {
+	return func(ctx context.Context, emit func(url string)) error {
+		t := &fileTailer{path: path}
+		defer t.close()
+		return t.run(ctx, emit)
+	}
+}
+
+// fileTailer tracks one open log file and how far into it has already been read, across rotation
+// and truncation. It's created fresh for every call a DiscoveryFunc's retry loop makes, so a
+// restart after an error always re-reads the file from the start - the same behavior `tail -f -n
+// +1` had.
+type fileTailer struct {
+	path string
+
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+}
+
+func (t *fileTailer) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
+
+func (t *fileTailer) run(ctx context.Context, emit func(url string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create fsnotify watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// Watch the parent directory rather than the file itself: rotation typically renames the
+	// current file out of the way and creates a new one at the same path, and a watch on the old
+	// inode wouldn't see events for the replacement.
+	dir := filepath.Dir(t.path)
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	ticker := time.NewTicker(fileTailStatPollInterval)
+	defer ticker.Stop()
+
+	// Read whatever is already there before waiting on the first event or tick.
+	if err := t.poll(emit); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("fsnotify watcher closed")
+			}
+			if filepath.Clean(ev.Name) != filepath.Clean(t.path) {
+				continue
+			}
+			if err := t.poll(emit); err != nil {
+				return err
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("fsnotify watcher closed")
+			}
+			return fmt.Errorf("fsnotify error: %w", err)
+		case <-ticker.C:
+			if err := t.poll(emit); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// poll opens t.path if it isn't already open, reopens it from offset 0 if rotation or truncation
+// is detected, reads whatever complete lines have appeared since the last poll, and feeds any that
+// match devtoolsListeningRegexp to emit.
+func (t *fileTailer) poll(emit func(url string)) error {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // rotated out and not yet recreated; the next poll will pick it up
+		}
+		return err
+	}
+
+	if t.file == nil || !os.SameFile(t.info, info) || info.Size() < t.offset {
+		t.close()
+		f, err := os.Open(t.path)
+		if err != nil {
+			return err
+		}
+		t.file = f
+		t.info = info
+		t.offset = 0
+	}
+
+	if _, err := t.file.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(t.file)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(string(data), "\n")
+	// The last element is whatever follows the final newline in data - a partial line the writer
+	// hasn't finished flushing yet, or "" if data ended exactly on a newline. Either way, leave it
+	// unconsumed so the next poll re-reads it complete, along with whatever gets appended after it.
+	complete := lines[:len(lines)-1]
+	t.offset += int64(len(data) - len(lines[len(lines)-1]))
+
+	for _, line := range complete {
+		line = strings.TrimSuffix(line, "\r")
+		if matches := devtoolsListeningRegexp.FindStringSubmatch(line); len(matches) == 2 {
+			emit(matches[1])
+		}
+	}
+	return nil
+}
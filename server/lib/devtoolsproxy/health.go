@@ -0,0 +1,72 @@
+package devtoolsproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HealthzStaleness bounds how long the discovery source can go without producing any activity
+// before HealthzHandler reports unhealthy - long enough to tolerate Chromium being briefly quiet,
+// short enough to catch a wedged discovery pipeline (e.g. a file tailer stuck behind a rotated log
+// file) promptly. A package var rather than a HealthzHandler parameter, matching ReconnectWindow's
+// precedent.
+var HealthzStaleness = 2 * time.Minute
+
+// HealthStatus is the JSON body HealthzHandler returns, reporting enough of UpstreamManager's
+// state for an operator to tell whether its discovery pipeline is still alive.
+type HealthStatus struct {
+	Healthy           bool      `json:"healthy"`
+	UpstreamURL       string    `json:"upstream_url"`
+	DiscoveredAt      time.Time `json:"discovered_at,omitempty"`
+	ActiveConnections int64     `json:"active_connections"`
+	LastActivityAt    time.Time `json:"last_activity_at,omitempty"`
+	LastDiscoveryErr  string    `json:"last_discovery_error,omitempty"`
+}
+
+// health reports u's current state for HealthzHandler. It's considered healthy when the current
+// upstream URL is non-empty and the discovery source has produced activity within
+// HealthzStaleness - the activity check catches a discovery pipeline silently wedged (e.g. behind
+// a rotated log file) even while the last known-good URL is still cached and technically dialable.
+func (u *UpstreamManager) health() HealthStatus {
+	lastActivityAt, _ := u.lastActivityAt.Load().(time.Time)
+	discoveredAt, _ := u.discoveredAt.Load().(time.Time)
+	lastDiscoveryErr, _ := u.lastDiscoveryErr.Load().(string)
+
+	status := HealthStatus{
+		UpstreamURL:       u.Current(),
+		DiscoveredAt:      discoveredAt,
+		ActiveConnections: u.activeConnections.Load(),
+		LastActivityAt:    lastActivityAt,
+		LastDiscoveryErr:  lastDiscoveryErr,
+	}
+	status.Healthy = status.UpstreamURL != "" && !lastActivityAt.IsZero() && time.Since(lastActivityAt) <= HealthzStaleness
+	return status
+}
+
+// urlAge reports how long ago the current upstream URL was discovered, or zero if none has been.
+func (u *UpstreamManager) urlAge() time.Duration {
+	discoveredAt, ok := u.discoveredAt.Load().(time.Time)
+	if !ok || discoveredAt.IsZero() {
+		return 0
+	}
+	return time.Since(discoveredAt)
+}
+
+// HealthzHandler returns an http.Handler reporting whether mgr's upstream discovery pipeline is
+// healthy: a 200 with a HealthStatus JSON body if the current upstream URL is known and the
+// discovery source has produced activity within HealthzStaleness, 503 with the same body
+// otherwise.
+func HealthzHandler(mgr *UpstreamManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := mgr.health()
+
+		w.Header().Set("Content-Type", "application/json")
+		if status.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
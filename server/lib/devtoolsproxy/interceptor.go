@@ -0,0 +1,87 @@
+package devtoolsproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	dirClientToUpstream = "->"
+	dirUpstreamToClient = "<-"
+)
+
+// CDPFrame is a CDP message's top-level envelope fields - every wire message is either a command
+// ({id, method, params}), an event ({method, params, sessionId}), or a response
+// ({id, result/error, sessionId}), so these fields cover all of them.
+type CDPFrame struct {
+	ID        *int64          `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     json.RawMessage `json:"error,omitempty"`
+}
+
+// CDPMessage is one websocket frame moving through the interceptor chain. Raw is what was
+// actually read off the connection (or the previous interceptor's rewritten output); Frame is
+// Raw parsed once via encoding/json, shared by every interceptor and the structured logger
+// instead of each re-deriving it from the raw bytes with its own regex. Parsed is false for
+// frames that aren't a JSON object at all (e.g. a binary websocket message), in which case Frame
+// is zero-valued and should be ignored.
+type CDPMessage struct {
+	Type   int
+	Raw    []byte
+	Frame  CDPFrame
+	Parsed bool
+}
+
+func parseCDPMessage(messageType int, raw []byte) *CDPMessage {
+	msg := &CDPMessage{Type: messageType, Raw: raw}
+	if messageType == websocket.TextMessage {
+		if err := json.Unmarshal(raw, &msg.Frame); err == nil {
+			msg.Parsed = true
+		}
+	}
+	return msg
+}
+
+// CDPInterceptor inspects, rewrites, drops, or synthesizes CDP messages flowing through the
+// devtools proxy in one direction. Returning (nil, nil) drops the message instead of forwarding
+// it; returning a non-nil error tears down the proxied connection the same way a read/write
+// failure on the underlying websocket would.
+type CDPInterceptor interface {
+	OnClientToUpstream(ctx context.Context, msg *CDPMessage) ([]byte, error)
+	OnUpstreamToClient(ctx context.Context, msg *CDPMessage) ([]byte, error)
+}
+
+// runInterceptorChain threads msg through interceptors in order. Each interceptor's output
+// becomes the next one's input; if a rewrite actually changed the bytes, Frame is re-parsed from
+// them before the next interceptor runs, since a rewrite can change method/id/sessionId. A nil
+// result from any interceptor short-circuits the rest of the chain and reports the message as
+// dropped (nil, nil).
+func runInterceptorChain(ctx context.Context, interceptors []CDPInterceptor, msg *CDPMessage, direction string) ([]byte, error) {
+	for _, ic := range interceptors {
+		var (
+			out []byte
+			err error
+		)
+		if direction == dirClientToUpstream {
+			out, err = ic.OnClientToUpstream(ctx, msg)
+		} else {
+			out, err = ic.OnUpstreamToClient(ctx, msg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			return nil, nil
+		}
+		if !bytes.Equal(out, msg.Raw) {
+			*msg = *parseCDPMessage(msg.Type, out)
+		}
+	}
+	return msg.Raw, nil
+}
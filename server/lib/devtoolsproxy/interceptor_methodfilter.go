@@ -0,0 +1,56 @@
+package devtoolsproxy
+
+import "context"
+
+// MethodFilter is a CDPInterceptor that allows or blocks client-issued CDP commands by method
+// name. If Allow is non-empty, only methods in it pass; otherwise every method passes except
+// those in Deny. A blocked command is silently dropped rather than tearing down the connection -
+// one rejected command isn't a connection-ending failure the way a read/write error is. Upstream
+// events and responses are never filtered; only Method being set at all identifies a
+// client-issued command in a CDPFrame.
+type MethodFilter struct {
+	Allow map[string]bool
+	Deny  map[string]bool
+}
+
+// NewMethodFilter builds a MethodFilter from allow/deny lists; either may be nil or empty.
+func NewMethodFilter(allow, deny []string) *MethodFilter {
+	f := &MethodFilter{}
+	if len(allow) > 0 {
+		f.Allow = make(map[string]bool, len(allow))
+		for _, m := range allow {
+			f.Allow[m] = true
+		}
+	}
+	if len(deny) > 0 {
+		f.Deny = make(map[string]bool, len(deny))
+		for _, m := range deny {
+			f.Deny[m] = true
+		}
+	}
+	return f
+}
+
+func (f *MethodFilter) allowed(method string) bool {
+	if method == "" {
+		return true
+	}
+	if f.Allow != nil && !f.Allow[method] {
+		return false
+	}
+	if f.Deny != nil && f.Deny[method] {
+		return false
+	}
+	return true
+}
+
+func (f *MethodFilter) OnClientToUpstream(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	if !f.allowed(msg.Frame.Method) {
+		return nil, nil
+	}
+	return msg.Raw, nil
+}
+
+func (f *MethodFilter) OnUpstreamToClient(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	return msg.Raw, nil
+}
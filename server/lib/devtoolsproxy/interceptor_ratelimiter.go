@@ -0,0 +1,56 @@
+package devtoolsproxy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a CDPInterceptor that caps how many times per window a client may issue any one
+// CDP method, dropping commands issued over the limit rather than forwarding them upstream.
+// Responses and events flowing back to the client are never limited.
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	counts map[string]*rateLimitWindow
+}
+
+type rateLimitWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter allows up to limit calls to any one CDP method per window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window, counts: make(map[string]*rateLimitWindow)}
+}
+
+func (r *RateLimiter) allow(method string, now time.Time) bool {
+	if method == "" {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.counts[method]
+	if !ok || now.Sub(w.start) >= r.window {
+		w = &rateLimitWindow{start: now}
+		r.counts[method] = w
+	}
+	w.count++
+	return w.count <= r.limit
+}
+
+func (r *RateLimiter) OnClientToUpstream(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	if !r.allow(msg.Frame.Method, time.Now()) {
+		return nil, nil
+	}
+	return msg.Raw, nil
+}
+
+func (r *RateLimiter) OnUpstreamToClient(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	return msg.Raw, nil
+}
@@ -0,0 +1,119 @@
+package devtoolsproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordedFrame is one line of a SessionRecorder's JSONL output.
+type recordedFrame struct {
+	Timestamp time.Time `json:"ts"`
+	Direction string    `json:"dir"`
+	SessionID string    `json:"sessionId,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	ID        *int64    `json:"id,omitempty"`
+}
+
+// SessionRecorder is a CDPInterceptor that writes every parsed JSON frame it sees (frames with
+// Parsed false are not recorded), in both directions, to a
+// size-rotated JSONL file so a session can be replayed later - the same role a session-recording
+// middleware plays for an SSH proxy. It never rewrites or drops a message; recording is purely an
+// observer sitting in the chain.
+type SessionRecorder struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	size     int64
+}
+
+// NewSessionRecorder opens (or creates) path for appending, rotating the current file to
+// path+".1" once it reaches maxBytes. A maxBytes of 0 disables rotation.
+func NewSessionRecorder(path string, maxBytes int64) (*SessionRecorder, error) {
+	r := &SessionRecorder{path: path, maxBytes: maxBytes}
+	if err := r.openCurrent(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *SessionRecorder) openCurrent() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open session recording %q: %w", r.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat session recording %q: %w", r.path, err)
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// rotateIfNeeded renames the current file aside (overwriting any previous backup) and reopens a
+// fresh one, once the current file has reached maxBytes. Must be called with mu held.
+func (r *SessionRecorder) rotateIfNeeded() error {
+	if r.maxBytes <= 0 || r.size < r.maxBytes {
+		return nil
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close session recording before rotation: %w", err)
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate session recording: %w", err)
+	}
+	return r.openCurrent()
+}
+
+func (r *SessionRecorder) record(direction string, msg *CDPMessage) error {
+	if !msg.Parsed {
+		return nil
+	}
+	line, err := json.Marshal(recordedFrame{
+		Timestamp: time.Now(),
+		Direction: direction,
+		SessionID: msg.Frame.SessionID,
+		Method:    msg.Frame.Method,
+		ID:        msg.Frame.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal recorded frame: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.rotateIfNeeded(); err != nil {
+		return err
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+func (r *SessionRecorder) OnClientToUpstream(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	if err := r.record(dirClientToUpstream, msg); err != nil {
+		return nil, err
+	}
+	return msg.Raw, nil
+}
+
+func (r *SessionRecorder) OnUpstreamToClient(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	if err := r.record(dirUpstreamToClient, msg); err != nil {
+		return nil, err
+	}
+	return msg.Raw, nil
+}
+
+// Close closes the recorder's underlying file.
+func (r *SessionRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,62 @@
+package devtoolsproxy
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// redactedNetworkParamFields lists the Network.* event params that carry cookies or header
+// values worth stripping before a session recording or any other downstream consumer sees them.
+var redactedNetworkParamFields = []string{
+	"headers", "requestHeaders", "responseHeaders", "cookies", "associatedCookies", "blockedCookies",
+}
+
+// Redactor is a CDPInterceptor that strips cookie and header values out of Network.* events
+// flowing from the browser to the client, so nothing downstream of the proxy - a session
+// recording, a rate limiter's logs, anything else in the chain - ever sees credentials that
+// passed through the page. Every other message is left untouched.
+type Redactor struct{}
+
+func (Redactor) OnClientToUpstream(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	return msg.Raw, nil
+}
+
+func (Redactor) OnUpstreamToClient(ctx context.Context, msg *CDPMessage) ([]byte, error) {
+	if !msg.Parsed || !strings.HasPrefix(msg.Frame.Method, "Network.") || len(msg.Frame.Params) == 0 {
+		return msg.Raw, nil
+	}
+
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Frame.Params, &params); err != nil {
+		return msg.Raw, nil
+	}
+
+	redactedValue, err := json.Marshal("[redacted]")
+	if err != nil {
+		return msg.Raw, nil
+	}
+	changed := false
+	for _, field := range redactedNetworkParamFields {
+		if _, ok := params[field]; ok {
+			params[field] = redactedValue
+			changed = true
+		}
+	}
+	if !changed {
+		return msg.Raw, nil
+	}
+
+	newParams, err := json.Marshal(params)
+	if err != nil {
+		return msg.Raw, nil
+	}
+
+	frame := msg.Frame
+	frame.Params = newParams
+	out, err := json.Marshal(frame)
+	if err != nil {
+		return msg.Raw, nil
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package devtoolsproxy
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// trackedManager is the UpstreamManager that upstreamURLAgeSeconds reports on. There's
+// one UpstreamManager per process - the default file-based discovery source already assumes a
+// single supervisord log to tail - so the last one constructed is the one the gauge tracks.
+var trackedManager atomic.Pointer[UpstreamManager]
+
+var (
+	connectionsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "devtools_proxy_connections_active",
+		Help: "Number of devtools proxy client connections currently open.",
+	})
+
+	messagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "devtools_proxy_messages_total",
+		Help: "Total number of CDP messages forwarded by the devtools proxy.",
+	}, []string{"dir", "method"})
+
+	messageBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "devtools_proxy_message_bytes",
+		Help:    "Size in bytes of CDP messages forwarded by the devtools proxy.",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+	}, []string{"dir"})
+
+	upstreamReconnectsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "devtools_proxy_upstream_reconnects_total",
+		Help: "Total number of times the devtools proxy re-dialed a new upstream connection after the previous one broke.",
+	})
+
+	upgradeFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "devtools_proxy_upgrade_failures_total",
+		Help: "Total number of devtools websocket upgrade attempts that failed, by reason.",
+	}, []string{"reason"})
+
+	upstreamURLAgeSeconds = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "devtools_proxy_upstream_url_age_seconds",
+		Help: "Seconds since the current upstream devtools URL was discovered. Reports 0 if no URL has been discovered yet.",
+	}, func() float64 {
+		um := trackedManager.Load()
+		if um == nil {
+			return 0
+		}
+		return um.urlAge().Seconds()
+	})
+)
+
+// recordMessage updates the messages/bytes metrics for one forwarded CDP message. method is empty
+// for messages whose method couldn't be determined (e.g. a non-JSON binary frame), which still
+// counts toward devtools_proxy_message_bytes but groups under an empty method label rather than
+// being dropped from devtools_proxy_messages_total entirely.
+func recordMessage(direction, method string, size int) {
+	messagesTotal.WithLabelValues(direction, method).Inc()
+	messageBytes.WithLabelValues(direction).Observe(float64(size))
+}
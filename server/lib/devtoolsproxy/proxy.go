@@ -1,59 +1,141 @@
 package devtoolsproxy
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
-	"os/exec"
 	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/onkernel/kernel-images/server/lib/scaletozero"
 )
 
 var devtoolsListeningRegexp = regexp.MustCompile(`DevTools listening on (ws://\S+)`)
 
-// UpstreamManager tails the Chromium supervisord log and extracts the current DevTools
-// websocket URL, updating it whenever Chromium restarts and emits a new line.
+// cdpHeaderPeekLimit bounds how many leading bytes of a CDP frame proxyWebSocket buffers in order
+// to extract its id/method/sessionId header and run it through the interceptor chain. A frame that
+// fits within the limit is handled exactly as before; a frame that doesn't is piped directly
+// between the two websocket connections via io.Copy, bypassing the interceptor chain, so large
+// Page.captureScreenshot, Network.getResponseBody, and Target.attachedToTarget payloads never sit
+// fully buffered in Go's heap a second time on top of the 100 MB per-connection read limit.
+const cdpHeaderPeekLimit = 64 * 1024
+
+// Discovery is one upstream devtools URL UpstreamManager has learned about, with the time it was
+// first seen.
+type Discovery struct {
+	URL  string
+	Time time.Time
+}
+
+// DiscoveryFunc feeds UpstreamManager discovered upstream URLs by calling emit, until ctx is done
+// or it returns an error - in which case the discovery loop retries it with backoff, the same way
+// it always retried the file tailer. emit may be called with the same URL more than once; only an
+// actual change is recorded as a new Discovery. FileTailDiscovery is the default implementation,
+// used by NewUpstreamManager; WithDiscovery lets a caller plug in another source (a Unix socket, an
+// HTTP /json/version poll, the stdout of a spawned chromium --remote-debugging-port=0) without
+// touching UpstreamManager itself.
+type DiscoveryFunc func(ctx context.Context, emit func(url string)) error
+
+// UpstreamManager runs a DiscoveryFunc to extract the current DevTools websocket URL, updating it
+// whenever Chromium restarts and a new one is discovered.
 type UpstreamManager struct {
-	logFilePath string
-	logger      *slog.Logger
+	discover DiscoveryFunc
+	logger   *slog.Logger
+
+	currentURL   atomic.Value // string
+	discoveredAt atomic.Value // time.Time, when currentURL was last set to a new value
+
+	historyMu sync.Mutex
+	history   []Discovery
 
-	currentURL atomic.Value // string
+	startOnce    sync.Once
+	stopOnce     sync.Once
+	cancelDiscov context.CancelFunc
 
-	startOnce  sync.Once
-	stopOnce   sync.Once
-	cancelTail context.CancelFunc
+	interceptorsMu sync.RWMutex
+	interceptors   []CDPInterceptor
+
+	bytesInFlight     atomic.Int64
+	activeConnections atomic.Int64
+
+	lastActivityAt   atomic.Value // time.Time, updated whenever the discovery source produces anything
+	lastDiscoveryErr atomic.Value // string, the most recent discovery failure, if any
+
+	subsMu      sync.Mutex
+	subscribers []chan string
 }
 
-func NewUpstreamManager(logFilePath string, logger *slog.Logger) *UpstreamManager {
-	um := &UpstreamManager{logFilePath: logFilePath, logger: logger}
+// NewUpstreamManager constructs an UpstreamManager that discovers the upstream URL by tailing the
+// Chromium supervisord log at logFilePath, optionally composing its CDP interceptor chain right
+// away - the common case, since most interceptors (a SessionRecorder's open file, a MethodFilter's
+// allow/deny lists) are fixed for the process's lifetime. Callers that need to add one later (e.g.
+// a test wiring one up after construction) can use RegisterInterceptor instead.
+func NewUpstreamManager(logFilePath string, logger *slog.Logger, interceptors ...CDPInterceptor) *UpstreamManager {
+	return NewUpstreamManagerWithDiscovery(FileTailDiscovery(logFilePath), logger, interceptors...)
+}
+
+// NewUpstreamManagerWithDiscovery constructs an UpstreamManager that discovers the upstream URL
+// via discover instead of the default file tailer - for environments that don't run Chromium under
+// supervisord, or that learn the devtools URL some other way entirely.
+func NewUpstreamManagerWithDiscovery(discover DiscoveryFunc, logger *slog.Logger, interceptors ...CDPInterceptor) *UpstreamManager {
+	um := &UpstreamManager{discover: discover, logger: logger, interceptors: interceptors}
 	um.currentURL.Store("")
+	trackedManager.Store(um)
 	return um
 }
 
-// Start begins background tailing and updating the upstream URL until ctx is done.
+// RegisterInterceptor appends ic to the end of the chain WebSocketProxyHandler runs every CDP
+// message through. Safe for concurrent use, including while a proxied connection is active -
+// that connection picks up the new interceptor on its next message.
+func (u *UpstreamManager) RegisterInterceptor(ic CDPInterceptor) {
+	u.interceptorsMu.Lock()
+	defer u.interceptorsMu.Unlock()
+	u.interceptors = append(u.interceptors, ic)
+}
+
+// Interceptors returns a snapshot of the currently registered CDP interceptor chain, in
+// registration order.
+func (u *UpstreamManager) Interceptors() []CDPInterceptor {
+	u.interceptorsMu.RLock()
+	defer u.interceptorsMu.RUnlock()
+	out := make([]CDPInterceptor, len(u.interceptors))
+	copy(out, u.interceptors)
+	return out
+}
+
+// BytesInFlight returns the number of bytes of streamed (over cdpHeaderPeekLimit) CDP payload
+// currently mid-copy between the client and upstream connections, across every active proxied
+// session. It's a point-in-time gauge, not a cumulative counter - it drops back toward zero as
+// large payloads finish forwarding.
+func (u *UpstreamManager) BytesInFlight() int64 {
+	return u.bytesInFlight.Load()
+}
+
+// Start begins running the discovery source in the background, updating the upstream URL until
+// ctx is done.
 func (u *UpstreamManager) Start(ctx context.Context) {
 	u.startOnce.Do(func() {
 		ctx, cancel := context.WithCancel(ctx)
-		u.cancelTail = cancel
-		go u.tailLoop(ctx)
+		u.cancelDiscov = cancel
+		go u.discoveryLoop(ctx)
 	})
 }
 
-// Stop cancels the background tailer.
+// Stop cancels the background discovery loop.
 func (u *UpstreamManager) Stop() {
 	u.stopOnce.Do(func() {
-		if u.cancelTail != nil {
-			u.cancelTail()
+		if u.cancelDiscov != nil {
+			u.cancelDiscov()
 		}
 	})
 }
@@ -72,6 +154,23 @@ func (u *UpstreamManager) WaitForInitial(timeout time.Duration) (string, error)
 	}
 }
 
+// WaitForNext blocks until the upstream URL becomes known and differs from prevURL, or the
+// timeout elapses. Callers that just triggered a Chromium restart (e.g. after restoring a
+// profile snapshot) use this instead of WaitForInitial, since Current() may still read the
+// pre-restart URL for a moment until the tailer sees the new "DevTools listening on" line.
+func (u *UpstreamManager) WaitForNext(prevURL string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if url := u.Current(); url != "" && url != prevURL {
+			return url, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("devtools upstream did not change from %q within %s", prevURL, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 // Current returns the current upstream websocket URL if known, or empty string.
 func (u *UpstreamManager) Current() string {
 	val, _ := u.currentURL.Load().(string)
@@ -81,19 +180,91 @@ func (u *UpstreamManager) Current() string {
 func (u *UpstreamManager) setCurrent(url string) {
 	prev := u.Current()
 	if url != "" && url != prev {
+		now := time.Now()
 		u.logger.Info("devtools upstream updated", slog.String("url", url))
 		u.currentURL.Store(url)
+		u.discoveredAt.Store(now)
+		u.historyMu.Lock()
+		u.history = append(u.history, Discovery{URL: url, Time: now})
+		u.historyMu.Unlock()
+		u.publish(url)
 	}
 }
 
-func (u *UpstreamManager) tailLoop(ctx context.Context) {
+// History returns every upstream URL UpstreamManager has discovered so far, in discovery order,
+// each with the time it was first seen.
+func (u *UpstreamManager) History() []Discovery {
+	u.historyMu.Lock()
+	defer u.historyMu.Unlock()
+	out := make([]Discovery, len(u.history))
+	copy(out, u.history)
+	return out
+}
+
+// Subscribe returns a channel that receives the current upstream URL every time it changes, e.g.
+// after Chromium restarts and the tailer sees a fresh "DevTools listening on" line. The channel
+// is buffered by one and only ever holds the most recent URL - a slow reader misses intermediate
+// values, never stale ones. Callers must Unsubscribe when done, or the channel leaks for the
+// lifetime of the UpstreamManager.
+func (u *UpstreamManager) Subscribe() <-chan string {
+	ch := make(chan string, 1)
+	u.subsMu.Lock()
+	u.subscribers = append(u.subscribers, ch)
+	u.subsMu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes a channel previously returned by Subscribe so setCurrent stops trying to
+// deliver to it.
+func (u *UpstreamManager) Unsubscribe(ch <-chan string) {
+	u.subsMu.Lock()
+	defer u.subsMu.Unlock()
+	for i, c := range u.subscribers {
+		if (<-chan string)(c) == ch {
+			u.subscribers = append(u.subscribers[:i], u.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish delivers url to every subscriber, replacing whatever stale value a slow subscriber's
+// buffered channel is still holding rather than blocking on it.
+func (u *UpstreamManager) publish(url string) {
+	u.subsMu.Lock()
+	defer u.subsMu.Unlock()
+	for _, ch := range u.subscribers {
+		select {
+		case ch <- url:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- url:
+			default:
+			}
+		}
+	}
+}
+
+// discoveryLoop runs u.discover until ctx is done, restarting it with a capped backoff whenever it
+// returns an error - e.g. the default file tailer's log not existing yet, or a custom
+// DiscoveryFunc's upstream socket not accepting connections yet.
+func (u *UpstreamManager) discoveryLoop(ctx context.Context) {
 	backoff := 250 * time.Millisecond
+	emit := func(url string) {
+		u.lastActivityAt.Store(time.Now())
+		u.setCurrent(url)
+	}
 	for {
 		if ctx.Err() != nil {
 			return
 		}
-		// Run one tail session. If it exits, retry with a small backoff.
-		u.runTailOnce(ctx)
+		if err := u.discover(ctx, emit); err != nil && !errors.Is(err, context.Canceled) {
+			u.logger.Error("devtools upstream discovery error", slog.String("err", err.Error()))
+			u.lastDiscoveryErr.Store(err.Error())
+		}
 		select {
 		case <-ctx.Done():
 			return
@@ -106,61 +277,70 @@ func (u *UpstreamManager) tailLoop(ctx context.Context) {
 	}
 }
 
-func (u *UpstreamManager) runTailOnce(ctx context.Context) {
-	cmd := exec.CommandContext(ctx, "tail", "-f", "-n", "+1", u.logFilePath)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		u.logger.Error("failed to open tail stdout", slog.String("err", err.Error()))
-		return
+// ReconnectWindow bounds how long WebSocketProxyHandler waits for UpstreamManager to publish a
+// new upstream URL after the current upstream connection breaks (e.g. Chromium restarting) before
+// giving up and closing the client connection. A package var rather than a parameter so it can be
+// tuned (tests, a deployment with a known-slower restart path) without changing
+// WebSocketProxyHandler's signature.
+var ReconnectWindow = 30 * time.Second
+
+// dialUpstream opens a new websocket connection to a devtools upstream URL with the settings
+// every proxied connection uses, regardless of whether it's the first dial or a reconnect.
+func dialUpstream(logger *slog.Logger, upstreamURL string) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{
+		ReadBufferSize:   65536,
+		WriteBufferSize:  65536,
+		HandshakeTimeout: 30 * time.Second,
 	}
-	if err := cmd.Start(); err != nil {
-		// Common when file does not exist yet; log at debug level
-		if strings.Contains(err.Error(), "No such file or directory") {
-			u.logger.Debug("supervisord log not found yet; will retry", slog.String("path", u.logFilePath))
-		} else {
-			u.logger.Error("failed to start tail", slog.String("err", err.Error()))
-		}
-		return
+	conn, _, err := dialer.Dial(upstreamURL, nil)
+	if err != nil {
+		return nil, err
 	}
-	defer func() {
-		_ = cmd.Process.Kill()
-		_, _ = cmd.Process.Wait()
-	}()
+	conn.SetReadLimit(100 * 1024 * 1024) // 100 MB. Effectively no maximum size of message from upstream
+	conn.EnableWriteCompression(true)
+	conn.SetCompressionLevel(6)
+	conn.SetReadDeadline(time.Time{})  // no timeout
+	conn.SetWriteDeadline(time.Time{}) // no timeout
+	logger.Debug("dialed devtools upstream", slog.String("url", upstreamURL))
+	return conn, nil
+}
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-		line := scanner.Text()
-		if matches := devtoolsListeningRegexp.FindStringSubmatch(line); len(matches) == 2 {
-			u.setCurrent(matches[1])
-		}
+// currentUpstreamURL resolves mgr's current upstream URL into the full dial target, ignoring
+// whatever path and query the incoming client request carried.
+func currentUpstreamURL(mgr *UpstreamManager) (string, error) {
+	current := mgr.Current()
+	if current == "" {
+		return "", fmt.Errorf("upstream not ready")
 	}
-	if err := scanner.Err(); err != nil && !errors.Is(err, context.Canceled) {
-		u.logger.Error("tail scanner error", slog.String("err", err.Error()))
+	parsed, err := url.Parse(current)
+	if err != nil {
+		return "", fmt.Errorf("invalid upstream: %w", err)
 	}
+	return (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: parsed.Path, RawQuery: parsed.RawQuery}).String(), nil
 }
 
 // WebSocketProxyHandler returns an http.Handler that upgrades incoming connections and
 // proxies them to the current upstream websocket URL. It expects only websocket requests.
-// If logCDPMessages is true, all CDP messages will be logged with their direction.
-func WebSocketProxyHandler(mgr *UpstreamManager, logger *slog.Logger, logCDPMessages bool) http.Handler {
+// If logCDPMessages is true, all CDP messages will be logged with their direction. Every message
+// is additionally run through mgr's registered CDP interceptor chain (see
+// UpstreamManager.RegisterInterceptor) in both directions. stz, if non-nil, is held disabled for
+// as long as this connection is open - the same holder pattern the extension endpoints use
+// around a chromium restart - so a live devtools session isn't scaled to zero out from under it.
+//
+// If the upstream connection breaks (most commonly Chromium restarting), the client connection is
+// not torn down with it: the handler waits up to ReconnectWindow for mgr to publish a new upstream
+// URL, re-dials, and replays the client's enabled domains, attached targets, and sticky
+// configuration commands so the client doesn't have to notice the swap and redo its own setup.
+// Commands still awaiting a response when the break happened receive a synthesized error instead,
+// since that response is never coming on a connection that no longer exists.
+func WebSocketProxyHandler(mgr *UpstreamManager, logger *slog.Logger, logCDPMessages bool, stz scaletozero.ScaleToZeroer) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		upstreamCurrent := mgr.Current()
-		if upstreamCurrent == "" {
-			http.Error(w, "upstream not ready", http.StatusServiceUnavailable)
-			return
-		}
-		parsed, err := url.Parse(upstreamCurrent)
+		upstreamURL, err := currentUpstreamURL(mgr)
 		if err != nil {
-			http.Error(w, "invalid upstream", http.StatusInternalServerError)
+			upgradeFailuresTotal.WithLabelValues("upstream_not_ready").Inc()
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
 			return
 		}
-		// Always use the full upstream path and query, ignoring the client's request path/query
-		upstreamURL := (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: parsed.Path, RawQuery: parsed.RawQuery}).String()
 		upgrader := websocket.Upgrader{
 			ReadBufferSize:    65536,
 			WriteBufferSize:   65536,
@@ -170,6 +350,7 @@ func WebSocketProxyHandler(mgr *UpstreamManager, logger *slog.Logger, logCDPMess
 		logger.Info("upgrader config", slog.Any("upgrader", upgrader))
 		clientConn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
+			upgradeFailuresTotal.WithLabelValues("upgrade_failed").Inc()
 			logger.Error("websocket upgrade failed", slog.String("err", err.Error()))
 			return
 		}
@@ -179,139 +360,266 @@ func WebSocketProxyHandler(mgr *UpstreamManager, logger *slog.Logger, logCDPMess
 		clientConn.EnableWriteCompression(true)
 		clientConn.SetCompressionLevel(6)
 
-		dialer := websocket.Dialer{
-			ReadBufferSize:   65536,
-			WriteBufferSize:  65536,
-			HandshakeTimeout: 30 * time.Second,
-		}
-		logger.Info("dialer config", slog.Any("dialer", dialer))
-		upstreamConn, _, err := dialer.Dial(upstreamURL, nil)
+		upstreamConn, err := dialUpstream(logger, upstreamURL)
 		if err != nil {
+			upgradeFailuresTotal.WithLabelValues("upstream_dial_failed").Inc()
 			logger.Error("dial upstream failed", slog.String("err", err.Error()), slog.String("url", upstreamURL))
 			_ = clientConn.Close()
 			return
 		}
-		upstreamConn.SetReadLimit(100 * 1024 * 1024) // 100 MB. Effectively no maximum size of message from upstream
-		upstreamConn.EnableWriteCompression(true)
-		upstreamConn.SetCompressionLevel(6)
-		upstreamConn.SetReadDeadline(time.Time{})  // no timeout
-		upstreamConn.SetWriteDeadline(time.Time{}) // no timeout
 		logger.Debug("proxying devtools websocket", slog.String("url", upstreamURL))
 
+		mgr.activeConnections.Add(1)
+		connectionsActive.Inc()
+		defer func() {
+			mgr.activeConnections.Add(-1)
+			connectionsActive.Dec()
+		}()
+
+		if stz != nil {
+			if err := stz.Disable(r.Context()); err != nil {
+				logger.Warn("failed to disable scale-to-zero for devtools session", slog.String("err", err.Error()))
+			}
+			defer func() {
+				if err := stz.Enable(context.Background()); err != nil {
+					logger.Warn("failed to re-enable scale-to-zero after devtools session", slog.String("err", err.Error()))
+				}
+			}()
+		}
+
 		var once sync.Once
 		cleanup := func() {
 			once.Do(func() {
-				_ = upstreamConn.Close()
 				_ = clientConn.Close()
 			})
 		}
-		proxyWebSocket(r.Context(), clientConn, upstreamConn, cleanup, logger, logCDPMessages)
+		runReconnectingProxy(r.Context(), clientConn, upstreamConn, cleanup, logger, logCDPMessages, mgr.Interceptors(), &mgr.bytesInFlight, mgr, ReconnectWindow)
 	})
 }
 
 type wsConn interface {
-	ReadMessage() (messageType int, p []byte, err error)
-	WriteMessage(messageType int, data []byte) error
+	NextReader() (messageType int, r io.Reader, err error)
+	NextWriter(messageType int) (io.WriteCloser, error)
 	Close() error
 }
 
-// logCDPMessage logs a CDP message with its direction if logging is enabled
-func logCDPMessage(logger *slog.Logger, direction string, mt int, msg []byte) {
-	if mt != websocket.TextMessage {
-		return // Only log text messages (CDP messages)
+// logCDPFrame logs a CDP message with its direction and size, using the single encoding/json
+// parse every message already went through (full or header-only) to build its CDPMessage rather
+// than re-deriving fields with regexes of its own.
+func logCDPFrame(logger *slog.Logger, direction string, msg *CDPMessage, size int) {
+	if !msg.Parsed {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("dir", direction)}
+	if msg.Frame.SessionID != "" {
+		attrs = append(attrs, slog.String("sessionId", msg.Frame.SessionID))
+	}
+	if msg.Frame.ID != nil {
+		attrs = append(attrs, slog.Int64("id", *msg.Frame.ID))
 	}
+	if msg.Frame.Method != "" {
+		attrs = append(attrs, slog.String("method", msg.Frame.Method))
+	}
+	attrs = append(attrs, slog.Int("raw_length", size))
 
-	// Extract fields using regex from raw message
-	rawMsg := string(msg)
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	logger.Info("cdp", args...)
+}
 
-	// Regex patterns to match "key":"val" or "key": "val" for string values
-	extractStringField := func(key string) string {
-		pattern := fmt.Sprintf(`"%s"\s*:\s*"([^"]*)"`, key)
-		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(rawMsg)
-		if len(matches) > 1 {
-			return matches[1]
-		}
-		return ""
+// peekHeader reads up to len(head) bytes from r into head, returning how many bytes it actually
+// got and whether r was exhausted within that limit. When eof is true, head holds the entire
+// message; when it's false, r still has more to give and the caller must continue reading it (a
+// io.MultiReader of head plus the now-advanced r replays the whole message from the start).
+func peekHeader(r io.Reader, head []byte) (n int, eof bool, err error) {
+	n, err = io.ReadFull(r, head)
+	switch {
+	case err == nil:
+		return n, false, nil
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return n, true, nil
+	default:
+		return n, false, err
 	}
+}
 
-	// Regex pattern to match "key": number for numeric id
-	extractNumberField := func(key string) interface{} {
-		pattern := fmt.Sprintf(`"%s"\s*:\s*(\d+)`, key)
-		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(rawMsg)
-		if len(matches) > 1 {
-			// Try to parse as int first
-			if val, err := strconv.Atoi(matches[1]); err == nil {
-				return val
+// decodeCDPHeader extracts the id/method/sessionId keys from the start of a JSON object using a
+// streaming token scan, so a multi-megabyte params or result value later in the same object never
+// needs to be buffered just to read its header. head may be a truncated prefix of the full
+// message - decodeCDPHeader returns whatever header fields it managed to see before running out
+// of input or hitting a key whose value it can't skip without more bytes.
+func decodeCDPHeader(head []byte) CDPFrame {
+	var frame CDPFrame
+	dec := json.NewDecoder(bytes.NewReader(head))
+	if tok, err := dec.Token(); err != nil {
+		return frame
+	} else if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return frame
+	}
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return frame
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return frame
+		}
+		switch key {
+		case "id":
+			var id int64
+			if err := dec.Decode(&id); err != nil {
+				return frame
+			}
+			frame.ID = &id
+		case "method":
+			var method string
+			if err := dec.Decode(&method); err != nil {
+				return frame
 			}
-			// Fall back to float64
-			if val, err := strconv.ParseFloat(matches[1], 64); err == nil {
-				return val
+			frame.Method = method
+		case "sessionId":
+			var sessionID string
+			if err := dec.Decode(&sessionID); err != nil {
+				return frame
+			}
+			frame.SessionID = sessionID
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return frame
 			}
 		}
-		return nil
 	}
+	return frame
+}
 
-	// Extract fields using regex
-	method := extractStringField("method")
-	id := extractNumberField("id")
-	sessionId := extractStringField("sessionId")
-	targetId := extractStringField("targetId")
-	frameId := extractStringField("frameId")
+// countingWriter adds every byte written through it to a shared in-flight gauge, then gives the
+// whole total back so the caller can subtract it once the copy is done. It exists so
+// UpstreamManager.BytesInFlight reflects streamed payloads that are actively mid-copy rather than
+// growing without bound.
+type countingWriter struct {
+	w             io.Writer
+	bytesInFlight *atomic.Int64
+	total         int64
+}
 
-	// Build log attributes, only including non-empty values
-	attrs := []slog.Attr{
-		slog.String("dir", direction),
-	}
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.total += int64(n)
+	c.bytesInFlight.Add(int64(n))
+	return n, err
+}
 
-	if sessionId != "" {
-		attrs = append(attrs, slog.String("sessionId", sessionId))
-	}
-	if targetId != "" {
-		attrs = append(attrs, slog.String("targetId", targetId))
-	}
-	if id != nil {
-		attrs = append(attrs, slog.Any("id", id))
-	}
-	if frameId != "" {
-		attrs = append(attrs, slog.String("frameId", frameId))
+// sideOfDirection reports which connection is the source and which is the destination for a
+// given forwarding direction, so an error encountered while reading src or writing dst can be
+// tagged with the connection actually responsible for it rather than the goroutine that happened
+// to notice.
+func sideOfDirection(direction string) (src, dst string) {
+	if direction == dirClientToUpstream {
+		return "client", "upstream"
 	}
+	return "upstream", "client"
+}
+
+// sideError tags an error from forwardOnce with which connection - "client" or "upstream" -
+// produced it, so a caller juggling both can tell a dead client apart from a dead upstream
+// without caring which direction's goroutine happened to be the one to notice.
+type sideError struct {
+	side string
+	err  error
+}
 
-	if method != "" {
-		attrs = append(attrs, slog.String("method", method))
+func (e *sideError) Error() string { return e.err.Error() }
+func (e *sideError) Unwrap() error { return e.err }
+
+// forwardOnce reads one CDP frame from src and writes it to dst, in the given direction. Frames
+// that fit within cdpHeaderPeekLimit are parsed and run through the interceptor chain exactly as
+// before. Larger frames have only their header decoded (best-effort, from the leading
+// cdpHeaderPeekLimit bytes) for logging purposes and are piped directly to dst via io.Copy,
+// bypassing the interceptor chain entirely - rewriting or dropping a frame too big to buffer isn't
+// something any of the current interceptors need to do. If onMessage is non-nil, it's called with
+// every message that wasn't dropped, full or header-only, after it's forwarded.
+func forwardOnce(ctx context.Context, src, dst wsConn, direction string, interceptors []CDPInterceptor, logger *slog.Logger, logCDPMessages bool, bytesInFlight *atomic.Int64, onMessage func(*CDPMessage)) error {
+	srcSide, dstSide := sideOfDirection(direction)
+
+	mt, r, err := src.NextReader()
+	if err != nil {
+		return &sideError{side: srcSide, err: err}
 	}
 
-	attrs = append(attrs, slog.Int("raw_length", len(msg)))
+	head := make([]byte, cdpHeaderPeekLimit)
+	n, eof, err := peekHeader(r, head)
+	if err != nil {
+		return &sideError{side: srcSide, err: err}
+	}
+	head = head[:n]
 
-	// Convert attrs to individual slog.Attr arguments
-	args := make([]any, len(attrs))
-	for i, attr := range attrs {
-		args[i] = attr
+	if eof {
+		msg := parseCDPMessage(mt, head)
+		out, err := runInterceptorChain(ctx, interceptors, msg, direction)
+		if err != nil {
+			return &sideError{side: dstSide, err: fmt.Errorf("interceptor rejected %s message: %w", direction, err)}
+		}
+		if out == nil {
+			return nil // dropped by an interceptor
+		}
+		if logCDPMessages {
+			logCDPFrame(logger, direction, msg, len(out))
+		}
+		recordMessage(direction, msg.Frame.Method, len(out))
+		w, err := dst.NextWriter(mt)
+		if err != nil {
+			return &sideError{side: dstSide, err: err}
+		}
+		if _, err := w.Write(out); err != nil {
+			_ = w.Close()
+			return &sideError{side: dstSide, err: err}
+		}
+		if err := w.Close(); err != nil {
+			return &sideError{side: dstSide, err: err}
+		}
+		if onMessage != nil {
+			onMessage(msg)
+		}
+		return nil
 	}
 
-	logger.Info("cdp", args...)
+	w, err := dst.NextWriter(mt)
+	if err != nil {
+		return &sideError{side: dstSide, err: err}
+	}
+	cw := &countingWriter{w: w, bytesInFlight: bytesInFlight}
+	_, copyErr := io.Copy(cw, io.MultiReader(bytes.NewReader(head), r))
+	bytesInFlight.Add(-cw.total)
+	if copyErr != nil {
+		_ = w.Close()
+		return &sideError{side: dstSide, err: copyErr}
+	}
+	if err := w.Close(); err != nil {
+		return &sideError{side: dstSide, err: err}
+	}
+	streamedMsg := &CDPMessage{Type: mt, Frame: decodeCDPHeader(head), Parsed: true}
+	if logCDPMessages {
+		logCDPFrame(logger, direction, streamedMsg, int(cw.total))
+	}
+	recordMessage(direction, streamedMsg.Frame.Method, int(cw.total))
+	if onMessage != nil {
+		onMessage(streamedMsg)
+	}
+	return nil
 }
 
-func proxyWebSocket(ctx context.Context, clientConn, upstreamConn wsConn, onClose func(), logger *slog.Logger, logCDPMessages bool) {
+func proxyWebSocket(ctx context.Context, clientConn, upstreamConn wsConn, onClose func(), logger *slog.Logger, logCDPMessages bool, interceptors []CDPInterceptor, bytesInFlight *atomic.Int64) {
 	errChan := make(chan error, 2)
 
 	go func() {
 		for {
-			mt, msg, err := clientConn.ReadMessage()
-			if err != nil {
-				logger.Error("client read error", slog.String("err", err.Error()))
-				errChan <- err
-				break
-			}
-
-			// Log CDP messages if enabled
-			if logCDPMessages {
-				logCDPMessage(logger, "->", mt, msg)
-			}
-
-			if err := upstreamConn.WriteMessage(mt, msg); err != nil {
-				logger.Error("upstream write error", slog.String("err", err.Error()))
+			if err := forwardOnce(ctx, clientConn, upstreamConn, dirClientToUpstream, interceptors, logger, logCDPMessages, bytesInFlight, nil); err != nil {
+				logger.Error("client->upstream forward error", slog.String("err", err.Error()))
 				errChan <- err
 				break
 			}
@@ -319,20 +627,8 @@ func proxyWebSocket(ctx context.Context, clientConn, upstreamConn wsConn, onClos
 	}()
 	go func() {
 		for {
-			mt, msg, err := upstreamConn.ReadMessage()
-			if err != nil {
-				logger.Error("upstream read error", slog.String("err", err.Error()))
-				errChan <- err
-				break
-			}
-
-			// Log CDP messages if enabled
-			if logCDPMessages {
-				logCDPMessage(logger, "<-", mt, msg)
-			}
-
-			if err := clientConn.WriteMessage(mt, msg); err != nil {
-				logger.Error("client write error", slog.String("err", err.Error()))
+			if err := forwardOnce(ctx, upstreamConn, clientConn, dirUpstreamToClient, interceptors, logger, logCDPMessages, bytesInFlight, nil); err != nil {
+				logger.Error("upstream->client forward error", slog.String("err", err.Error()))
 				errChan <- err
 				break
 			}
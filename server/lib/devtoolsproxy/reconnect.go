@@ -0,0 +1,319 @@
+package devtoolsproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// errCodeUpstreamReconnected is the CDP error code synthesized for any client command that was
+// still awaiting a response when the upstream connection broke. It falls inside JSON-RPC's
+// reserved server-error range (-32000 to -32099) but isn't one Chromium itself ever sends, so a
+// client can distinguish "the upstream connection was replaced before this came back" from a real
+// CDP error and decide whether to just retry the command.
+const errCodeUpstreamReconnected = -32050
+
+// stickyMethods are one-off client configuration commands, other than the generic "*.enable"
+// family, that need to be reissued after a reconnect for the session to keep behaving the way the
+// client set it up.
+var stickyMethods = map[string]bool{
+	"Network.setRequestInterception": true,
+}
+
+// sessionState tracks the minimum client-issued CDP state needed to restore a devtools session
+// after its upstream connection is replaced: which domains the client had enabled, which sticky
+// one-off configuration commands it had issued, which targets it had attached to, and which of
+// its commands are still waiting on a response.
+type sessionState struct {
+	mu sync.Mutex
+
+	enabled  map[string]json.RawMessage // "*.enable" method -> last params
+	sticky   map[string]json.RawMessage // other sticky config method -> last params
+	attached map[string]json.RawMessage // targetId -> Target.attachToTarget params
+	inFlight map[int64]string           // client command id -> method, while awaiting a response
+}
+
+func newSessionState() *sessionState {
+	return &sessionState{
+		enabled:  make(map[string]json.RawMessage),
+		sticky:   make(map[string]json.RawMessage),
+		attached: make(map[string]json.RawMessage),
+		inFlight: make(map[int64]string),
+	}
+}
+
+// onClientCommand is forwardOnce's per-message hook for the client->upstream direction: it
+// records enable/attach/sticky-config commands for later replay and marks the command in-flight
+// until a matching response comes back through onUpstreamMessage.
+func (s *sessionState) onClientCommand(msg *CDPMessage) {
+	if !msg.Parsed || msg.Frame.ID == nil {
+		return
+	}
+	method := msg.Frame.Method
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight[*msg.Frame.ID] = method
+
+	switch {
+	case strings.HasSuffix(method, ".enable"):
+		s.enabled[method] = msg.Frame.Params
+	case stickyMethods[method]:
+		s.sticky[method] = msg.Frame.Params
+	case method == "Target.attachToTarget":
+		if targetID := paramsField(msg.Frame.Params, "targetId"); targetID != "" {
+			s.attached[targetID] = msg.Frame.Params
+		}
+	}
+}
+
+// onUpstreamMessage is forwardOnce's per-message hook for the upstream->client direction: it
+// clears a command from the in-flight set once its response arrives.
+func (s *sessionState) onUpstreamMessage(msg *CDPMessage) {
+	if !msg.Parsed || msg.Frame.ID == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.inFlight, *msg.Frame.ID)
+	s.mu.Unlock()
+}
+
+// replayIDCounter hands out ids for commands sessionState.replay reissues on the client's behalf.
+// They count down from -1 - CDP commands in practice never use a negative id - so a response that
+// happens to reach the client for one of these is identifiable as synthetic rather than something
+// it asked for itself.
+var replayIDCounter atomic.Int64
+
+// replay reissues the sticky session state recorded before an upstream break over the new
+// upstream connection, so the client doesn't have to notice the swap and redo its own
+// Page.enable/Target.attachToTarget/Fetch.enable-style setup.
+func (s *sessionState) replay(upstream wsConn, logger *slog.Logger) {
+	s.mu.Lock()
+	commands := make([]json.RawMessage, 0, len(s.enabled)+len(s.sticky)+len(s.attached))
+	for method, params := range s.enabled {
+		commands = append(commands, replayCommand(method, params))
+	}
+	for method, params := range s.sticky {
+		commands = append(commands, replayCommand(method, params))
+	}
+	for _, params := range s.attached {
+		commands = append(commands, replayCommand("Target.attachToTarget", params))
+	}
+	s.mu.Unlock()
+
+	for _, cmd := range commands {
+		w, err := upstream.NextWriter(websocket.TextMessage)
+		if err != nil {
+			logger.Error("devtools proxy: failed to replay session state", slog.String("err", err.Error()))
+			return
+		}
+		if _, err := w.Write(cmd); err != nil {
+			_ = w.Close()
+			logger.Error("devtools proxy: failed to replay session state", slog.String("err", err.Error()))
+			return
+		}
+		if err := w.Close(); err != nil {
+			logger.Error("devtools proxy: failed to replay session state", slog.String("err", err.Error()))
+			return
+		}
+	}
+}
+
+func replayCommand(method string, params json.RawMessage) json.RawMessage {
+	id := replayIDCounter.Add(-1)
+	frame := CDPFrame{ID: &id, Method: method, Params: params}
+	out, err := json.Marshal(frame)
+	if err != nil {
+		// Params that fail to round-trip aren't worth retrying with; reissuing the bare method
+		// with no params is still better than silently dropping it.
+		frame.Params = nil
+		out, _ = json.Marshal(frame)
+	}
+	return out
+}
+
+// failInFlight synthesizes an errCodeUpstreamReconnected error response for every client command
+// that was still awaiting a reply when the upstream connection broke, since that reply is never
+// coming on a connection that no longer exists, and clears the in-flight set.
+func (s *sessionState) failInFlight(clientConn wsConn, logger *slog.Logger) {
+	s.mu.Lock()
+	inFlight := s.inFlight
+	s.inFlight = make(map[int64]string)
+	s.mu.Unlock()
+
+	for id, method := range inFlight {
+		resp := struct {
+			ID    int64 `json:"id"`
+			Error struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}{ID: id}
+		resp.Error.Code = errCodeUpstreamReconnected
+		resp.Error.Message = fmt.Sprintf("devtools upstream connection was replaced before %s received a response; retry the command", method)
+
+		out, err := json.Marshal(resp)
+		if err != nil {
+			logger.Error("devtools proxy: failed to synthesize in-flight error response", slog.String("err", err.Error()))
+			continue
+		}
+		w, err := clientConn.NextWriter(websocket.TextMessage)
+		if err != nil {
+			logger.Error("devtools proxy: failed to write synthesized error response", slog.String("err", err.Error()))
+			return
+		}
+		if _, err := w.Write(out); err != nil {
+			_ = w.Close()
+			logger.Error("devtools proxy: failed to write synthesized error response", slog.String("err", err.Error()))
+			return
+		}
+		if err := w.Close(); err != nil {
+			logger.Error("devtools proxy: failed to write synthesized error response", slog.String("err", err.Error()))
+			return
+		}
+	}
+}
+
+// paramsField extracts a top-level string field from a CDP command's params.
+func paramsField(params json.RawMessage, field string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(params, &m); err != nil {
+		return ""
+	}
+	raw, ok := m[field]
+	if !ok {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return ""
+	}
+	return s
+}
+
+// taggedErr is what runSession reports when one of its two forwarding goroutines gives up: which
+// connection caused it, and why.
+type taggedErr struct {
+	side string
+	err  error
+}
+
+func sideOf(err error, fallback string) string {
+	var se *sideError
+	if errors.As(err, &se) {
+		return se.side
+	}
+	return fallback
+}
+
+// runSession runs the two-directional CDP forwarding loop between clientConn and upstreamConn,
+// feeding every forwarded message through state so it can be replayed or failed later. It returns
+// a channel that receives exactly one taggedErr per forwarding goroutine once that goroutine
+// stops - callers that want to reuse clientConn with a different upstreamConn should close the old
+// upstreamConn and drain both entries before starting a new session.
+func runSession(ctx context.Context, clientConn, upstreamConn wsConn, logger *slog.Logger, logCDPMessages bool, interceptors []CDPInterceptor, bytesInFlight *atomic.Int64, state *sessionState) <-chan taggedErr {
+	done := make(chan taggedErr, 2)
+
+	go func() {
+		for {
+			if err := forwardOnce(ctx, clientConn, upstreamConn, dirClientToUpstream, interceptors, logger, logCDPMessages, bytesInFlight, state.onClientCommand); err != nil {
+				done <- taggedErr{side: sideOf(err, "client"), err: err}
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			if err := forwardOnce(ctx, upstreamConn, clientConn, dirUpstreamToClient, interceptors, logger, logCDPMessages, bytesInFlight, state.onUpstreamMessage); err != nil {
+				done <- taggedErr{side: sideOf(err, "upstream"), err: err}
+				return
+			}
+		}
+	}()
+
+	return done
+}
+
+// waitForNewUpstream subscribes to mgr and blocks until it publishes a new upstream URL, window
+// elapses, or ctx is done.
+func waitForNewUpstream(ctx context.Context, mgr *UpstreamManager, window time.Duration) (string, bool) {
+	sub := mgr.Subscribe()
+	defer mgr.Unsubscribe(sub)
+
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+
+	select {
+	case url, ok := <-sub:
+		return url, ok && url != ""
+	case <-timer.C:
+		return "", false
+	case <-ctx.Done():
+		return "", false
+	}
+}
+
+// runReconnectingProxy drives one client connection across possibly many upstream connections. As
+// long as it's the upstream side that breaks, the client connection survives: runReconnectingProxy
+// waits up to window for mgr to publish a new upstream URL, re-dials it, fails any command still
+// in-flight from before the break, and replays the session state the client had built up. It gives
+// up - closing the client connection via onClose - only once the client side itself errors, or no
+// new upstream URL shows up within window.
+func runReconnectingProxy(ctx context.Context, clientConn, upstreamConn wsConn, onClose func(), logger *slog.Logger, logCDPMessages bool, interceptors []CDPInterceptor, bytesInFlight *atomic.Int64, mgr *UpstreamManager, window time.Duration) {
+	defer onClose()
+	defer func() { _ = upstreamConn.Close() }()
+
+	state := newSessionState()
+
+	for {
+		done := runSession(ctx, clientConn, upstreamConn, logger, logCDPMessages, interceptors, bytesInFlight, state)
+
+		var first taggedErr
+		select {
+		case first = <-done:
+		case <-ctx.Done():
+			return
+		}
+
+		_ = upstreamConn.Close() // unblocks whichever of the two forwarding goroutines hasn't noticed yet
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			logger.Warn("devtools proxy: timed out waiting for forwarding goroutines to stop")
+		}
+
+		if first.side == "client" {
+			logger.Debug("devtools proxy: client connection ended", slog.String("err", first.err.Error()))
+			return
+		}
+
+		logger.Warn("devtools proxy: upstream connection broke; attempting reconnect", slog.String("err", first.err.Error()))
+		state.failInFlight(clientConn, logger)
+
+		newURL, ok := waitForNewUpstream(ctx, mgr, window)
+		if !ok {
+			logger.Error("devtools proxy: no new upstream URL within reconnect window; giving up", slog.Duration("window", window))
+			return
+		}
+		newConn, err := dialUpstream(logger, newURL)
+		if err != nil {
+			logger.Error("devtools proxy: re-dial failed; giving up", slog.String("err", err.Error()))
+			return
+		}
+		upstreamReconnectsTotal.Inc()
+		upstreamConn = newConn
+		state.replay(upstreamConn, logger)
+	}
+}
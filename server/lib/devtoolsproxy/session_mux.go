@@ -0,0 +1,425 @@
+package devtoolsproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// muxClient is one client connection sharing a SessionMux's upstream connection.
+type muxClient struct {
+	id   string
+	conn wsConn
+
+	writeMu sync.Mutex
+
+	sessions map[string]bool // CDP sessionIds this client owns, via Target.attachToTarget
+}
+
+func (c *muxClient) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// pendingCall records enough about a client's in-flight command to route its response back to
+// the right client under its original id, and, for Target.attachToTarget, to learn which CDP
+// session the client just became the owner of once the response carries a sessionId.
+type pendingCall struct {
+	clientID string
+	origID   int64
+	method   string
+}
+
+// SessionMux multiplexes several independent clients over one persistent upstream DevTools
+// connection, so multiple agents can drive the same Chromium without each opening a competing
+// upstream connection and fighting over command ids. Every client-issued command id is rewritten
+// to a globally unique counter before it goes upstream, with a map back to the client and its
+// original id; global (sessionId-less) events are broadcast to every client, and
+// sessionId-scoped events are routed only to whichever client owns that session, i.e. the one
+// whose Target.attachToTarget call produced it.
+type SessionMux struct {
+	logger *slog.Logger
+
+	upstream   wsConn
+	upstreamMu sync.Mutex // guards writes to upstream; reads happen only from readUpstreamLoop
+
+	nextID atomic.Int64
+
+	mu            sync.Mutex
+	clients       map[string]*muxClient
+	pending       map[int64]pendingCall
+	sessionOwners map[string]string // CDP sessionId -> owning clientID
+}
+
+// NewSessionMux builds a SessionMux fronting the given persistent upstream connection and starts
+// reading from it. Reconnecting across a Chromium restart isn't this type's job - a caller using
+// UpstreamManager.WaitForNext to notice a new upstream URL constructs a fresh SessionMux for it.
+func NewSessionMux(upstream wsConn, logger *slog.Logger) *SessionMux {
+	m := &SessionMux{
+		logger:        logger,
+		upstream:      upstream,
+		clients:       make(map[string]*muxClient),
+		pending:       make(map[int64]pendingCall),
+		sessionOwners: make(map[string]string),
+	}
+	go m.readUpstreamLoop()
+	return m
+}
+
+// NewSessionMuxFromManager dials mgr's current upstream DevTools URL and returns a SessionMux
+// fronting it.
+func NewSessionMuxFromManager(mgr *UpstreamManager, logger *slog.Logger) (*SessionMux, error) {
+	upstreamURL := mgr.Current()
+	if upstreamURL == "" {
+		return nil, fmt.Errorf("sessionmux: no upstream URL available yet")
+	}
+	dialer := websocket.Dialer{
+		ReadBufferSize:   65536,
+		WriteBufferSize:  65536,
+		HandshakeTimeout: 30 * time.Second,
+	}
+	conn, _, err := dialer.Dial(upstreamURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sessionmux: failed to dial upstream %q: %w", upstreamURL, err)
+	}
+	conn.SetReadLimit(100 * 1024 * 1024)
+	return NewSessionMux(conn, logger), nil
+}
+
+var muxClientSeq atomic.Int64
+
+// SessionMuxHandler returns an http.Handler that upgrades incoming connections and registers each
+// one as a client of mux, so any number of callers can share mux's single upstream connection
+// instead of each dialing Chromium's devtools endpoint on their own.
+func SessionMuxHandler(mux *SessionMux, logger *slog.Logger) http.Handler {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:    65536,
+		WriteBufferSize:   65536,
+		EnableCompression: true,
+		CheckOrigin:       func(r *http.Request) bool { return true },
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Error("sessionmux: websocket upgrade failed", slog.String("err", err.Error()))
+			return
+		}
+		conn.SetReadLimit(100 * 1024 * 1024)
+		conn.EnableWriteCompression(true)
+		conn.SetCompressionLevel(6)
+
+		clientID := fmt.Sprintf("%s-%d", r.RemoteAddr, muxClientSeq.Add(1))
+		mux.AddClient(r.Context(), clientID, conn)
+	})
+}
+
+// AddClient registers conn as clientID and reads commands from it until it disconnects, rewriting
+// and forwarding each one upstream. It blocks for the connection's lifetime, so callers run it in
+// its own goroutine per client (SessionMuxHandler does this via its per-request handler).
+func (m *SessionMux) AddClient(ctx context.Context, clientID string, conn wsConn) {
+	c := &muxClient{id: clientID, conn: conn, sessions: make(map[string]bool)}
+
+	m.mu.Lock()
+	m.clients[clientID] = c
+	m.mu.Unlock()
+
+	defer m.removeClient(clientID)
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		mt, r, err := conn.NextReader()
+		if err != nil {
+			return
+		}
+		if mt != websocket.TextMessage {
+			continue // CDP is JSON text; nothing else is a command we can route
+		}
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return
+		}
+		m.handleClientMessage(c, raw)
+	}
+}
+
+func (m *SessionMux) handleClientMessage(c *muxClient, raw []byte) {
+	var frame CDPFrame
+	if err := json.Unmarshal(raw, &frame); err != nil {
+		m.logger.Warn("sessionmux: dropping unparseable client message", slog.String("client", c.id), slog.String("err", err.Error()))
+		return
+	}
+	if frame.ID == nil {
+		// Commands always carry an id; forward anything that doesn't unmodified, since there's
+		// no response to route back.
+		if err := m.writeUpstream(raw); err != nil {
+			m.logger.Error("sessionmux: upstream write failed", slog.String("err", err.Error()))
+		}
+		return
+	}
+
+	newID := m.nextID.Add(1)
+	m.mu.Lock()
+	m.pending[newID] = pendingCall{clientID: c.id, origID: *frame.ID, method: frame.Method}
+	m.mu.Unlock()
+
+	frame.ID = &newID
+	out, err := json.Marshal(frame)
+	if err != nil {
+		m.logger.Error("sessionmux: failed to re-marshal client command", slog.String("client", c.id), slog.String("err", err.Error()))
+		return
+	}
+	if err := m.writeUpstream(out); err != nil {
+		m.logger.Error("sessionmux: upstream write failed", slog.String("err", err.Error()))
+	}
+}
+
+func (m *SessionMux) writeUpstream(data []byte) error {
+	m.upstreamMu.Lock()
+	defer m.upstreamMu.Unlock()
+	w, err := m.upstream.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (m *SessionMux) readUpstreamLoop() {
+	for {
+		mt, r, err := m.upstream.NextReader()
+		if err != nil {
+			m.logger.Error("sessionmux: upstream read error", slog.String("err", err.Error()))
+			return
+		}
+		if mt != websocket.TextMessage {
+			continue
+		}
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			m.logger.Error("sessionmux: failed to read upstream message", slog.String("err", err.Error()))
+			return
+		}
+		m.handleUpstreamMessage(raw)
+	}
+}
+
+func (m *SessionMux) handleUpstreamMessage(raw []byte) {
+	var frame CDPFrame
+	if err := json.Unmarshal(raw, &frame); err != nil {
+		m.logger.Warn("sessionmux: dropping unparseable upstream message", slog.String("err", err.Error()))
+		return
+	}
+
+	if frame.ID != nil {
+		m.routeResponse(frame)
+		return
+	}
+	m.routeEvent(frame, raw)
+}
+
+// routeResponse delivers a response to whichever client issued the command it answers, restoring
+// that client's original id. A successful Target.attachToTarget response additionally records the
+// new sessionId as owned by that client, so later sessionId-scoped events reach it.
+func (m *SessionMux) routeResponse(frame CDPFrame) {
+	m.mu.Lock()
+	call, ok := m.pending[*frame.ID]
+	if ok {
+		delete(m.pending, *frame.ID)
+	}
+	m.mu.Unlock()
+	if !ok {
+		m.logger.Warn("sessionmux: response for unknown id", slog.Int64("id", *frame.ID))
+		return
+	}
+
+	if call.method == "Target.attachToTarget" && frame.Error == nil {
+		if sessionID := resultSessionID(frame.Result); sessionID != "" {
+			m.mu.Lock()
+			m.sessionOwners[sessionID] = call.clientID
+			if c, ok := m.clients[call.clientID]; ok {
+				c.sessions[sessionID] = true
+			}
+			m.mu.Unlock()
+		}
+	}
+
+	if call.clientID == "" {
+		return // a mux-originated command (e.g. a detach sent after the client disconnected)
+	}
+
+	frame.ID = &call.origID
+	out, err := json.Marshal(frame)
+	if err != nil {
+		m.logger.Error("sessionmux: failed to re-marshal response", slog.String("err", err.Error()))
+		return
+	}
+
+	m.mu.Lock()
+	client, ok := m.clients[call.clientID]
+	m.mu.Unlock()
+	if !ok {
+		return // client disconnected before its response arrived
+	}
+	if err := client.write(out); err != nil {
+		m.logger.Error("sessionmux: client write failed", slog.String("client", call.clientID), slog.String("err", err.Error()))
+	}
+}
+
+// routeEvent delivers an event to every client (if it's global) or to the client owning its
+// sessionId (if it's session-scoped), and forgets a session once Chromium reports it detached.
+func (m *SessionMux) routeEvent(frame CDPFrame, raw []byte) {
+	if frame.Method == "Target.detachedFromTarget" {
+		m.forgetSession(paramsSessionID(frame.Params))
+	}
+
+	if frame.SessionID == "" {
+		m.broadcast(raw)
+		return
+	}
+
+	m.mu.Lock()
+	clientID, owned := m.sessionOwners[frame.SessionID]
+	var client *muxClient
+	if owned {
+		client = m.clients[clientID]
+	}
+	m.mu.Unlock()
+
+	if client == nil {
+		// No known owner - e.g. a session that attached via setAutoAttach before any client
+		// explicitly asked for it. Broadcasting is safer than silently dropping CDP traffic.
+		m.broadcast(raw)
+		return
+	}
+	if err := client.write(raw); err != nil {
+		m.logger.Error("sessionmux: client write failed", slog.String("client", clientID), slog.String("err", err.Error()))
+	}
+}
+
+func (m *SessionMux) broadcast(raw []byte) {
+	m.mu.Lock()
+	clients := make([]*muxClient, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.Unlock()
+
+	for _, c := range clients {
+		if err := c.write(raw); err != nil {
+			m.logger.Error("sessionmux: broadcast write failed", slog.String("client", c.id), slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (m *SessionMux) forgetSession(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if clientID, ok := m.sessionOwners[sessionID]; ok {
+		delete(m.sessionOwners, sessionID)
+		if c, ok := m.clients[clientID]; ok {
+			delete(c.sessions, sessionID)
+		}
+	}
+}
+
+// removeClient unregisters clientID and sends Target.detachFromTarget upstream for every CDP
+// session it owned, so Chromium doesn't keep driving a target on behalf of a client that's no
+// longer listening for its events.
+func (m *SessionMux) removeClient(clientID string) {
+	m.mu.Lock()
+	c, ok := m.clients[clientID]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.clients, clientID)
+	sessions := make([]string, 0, len(c.sessions))
+	for sessionID := range c.sessions {
+		sessions = append(sessions, sessionID)
+		delete(m.sessionOwners, sessionID)
+	}
+	m.mu.Unlock()
+
+	for _, sessionID := range sessions {
+		m.detachSession(sessionID)
+	}
+}
+
+func (m *SessionMux) detachSession(sessionID string) {
+	newID := m.nextID.Add(1)
+	m.mu.Lock()
+	m.pending[newID] = pendingCall{clientID: "", origID: newID, method: "Target.detachFromTarget"}
+	m.mu.Unlock()
+
+	cmd := struct {
+		ID     int64  `json:"id"`
+		Method string `json:"method"`
+		Params struct {
+			SessionID string `json:"sessionId"`
+		} `json:"params"`
+	}{ID: newID, Method: "Target.detachFromTarget"}
+	cmd.Params.SessionID = sessionID
+
+	out, err := json.Marshal(cmd)
+	if err != nil {
+		m.logger.Error("sessionmux: failed to marshal detach command", slog.String("err", err.Error()))
+		return
+	}
+	if err := m.writeUpstream(out); err != nil {
+		m.logger.Error("sessionmux: failed to send detach command", slog.String("session", sessionID), slog.String("err", err.Error()))
+	}
+}
+
+// resultSessionID reads the sessionId field out of a Target.attachToTarget response's result.
+func resultSessionID(result json.RawMessage) string {
+	if len(result) == 0 {
+		return ""
+	}
+	var v struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.Unmarshal(result, &v); err != nil {
+		return ""
+	}
+	return v.SessionID
+}
+
+// paramsSessionID reads the sessionId field out of an event's params, e.g.
+// Target.detachedFromTarget.
+func paramsSessionID(params json.RawMessage) string {
+	if len(params) == 0 {
+		return ""
+	}
+	var v struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.Unmarshal(params, &v); err != nil {
+		return ""
+	}
+	return v.SessionID
+}
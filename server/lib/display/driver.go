@@ -0,0 +1,31 @@
+// Package display abstracts the backend that actually owns screen geometry — Xorg (via the
+// XRANDR X11 extension), Xvfb (via its supervisord-managed process), or Neko (via its REST
+// screen-configuration API) — behind one Driver interface. It exists so callers pick a
+// backend once, at startup, instead of re-detecting it (and shelling out to xrandr/
+// supervisorctl to do so) on every request.
+package display
+
+import "context"
+
+// Mode is a single output resolution/timing.
+type Mode struct {
+	Width       int
+	Height      int
+	RefreshRate int
+}
+
+// Driver is implemented by each concrete display backend.
+type Driver interface {
+	// GetMode returns the currently active mode for output.
+	GetMode(ctx context.Context, output string) (Mode, error)
+	// SetMode switches output to mode, registering it first via AddMode if the backend
+	// requires modes to be known before they can be activated.
+	SetMode(ctx context.Context, output string, mode Mode) error
+	// ListModes returns every mode currently known to the backend for output.
+	ListModes(ctx context.Context, output string) ([]Mode, error)
+	// AddMode registers a new mode with the backend without activating it.
+	AddMode(ctx context.Context, output string, mode Mode) error
+	// Restart restarts whatever process must restart for a mode change to take visual
+	// effect. It's a no-op for backends (Xorg, Neko) that apply changes live.
+	Restart(ctx context.Context) error
+}
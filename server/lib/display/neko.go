@@ -0,0 +1,123 @@
+package display
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// nekoDriver wraps Neko's REST screen-configuration API (POST /api/room/screen). Neko owns
+// the X server itself in this mode, so this is really a thin HTTP client rather than a real
+// hardware driver.
+type nekoDriver struct {
+	baseURL    string
+	tokenFunc  func(ctx context.Context) (string, error)
+	clearToken func()
+	httpClient *http.Client
+
+	// modes caches AddMode calls since Neko's screen API has no separate "register without
+	// activating" concept, the same limitation xvfbDriver has.
+	modes map[string][]Mode
+}
+
+// NewNekoDriver returns a Driver backed by baseURL's Neko instance. tokenFunc should return a
+// cached bearer token (logging in if necessary); clearToken is called to force a re-login
+// after a 401, mirroring the retry-once behavior Neko callers already use.
+func NewNekoDriver(baseURL string, tokenFunc func(ctx context.Context) (string, error), clearToken func()) Driver {
+	return &nekoDriver{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		tokenFunc:  tokenFunc,
+		clearToken: clearToken,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		modes:      make(map[string][]Mode),
+	}
+}
+
+// GetMode is unsupported: Neko's API exposes a "set screen" endpoint but no "get current
+// screen" endpoint, so there's nothing to query beyond what a caller last requested.
+func (d *nekoDriver) GetMode(ctx context.Context, output string) (Mode, error) {
+	return Mode{}, fmt.Errorf("neko driver does not support reading the current mode")
+}
+
+func (d *nekoDriver) SetMode(ctx context.Context, output string, mode Mode) error {
+	rate := mode.RefreshRate
+	if rate <= 0 {
+		rate = 60
+	}
+	body, err := json.Marshal(map[string]any{"width": mode.Width, "height": mode.Height, "rate": rate})
+	if err != nil {
+		return fmt.Errorf("marshal screen config: %w", err)
+	}
+
+	if err := d.postScreen(ctx, body); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *nekoDriver) ListModes(ctx context.Context, output string) ([]Mode, error) {
+	return d.modes[output], nil
+}
+
+func (d *nekoDriver) AddMode(ctx context.Context, output string, mode Mode) error {
+	d.modes[output] = append(d.modes[output], mode)
+	return nil
+}
+
+// Restart is a no-op: Neko applies screen changes live, the same as xorgDriver.
+func (d *nekoDriver) Restart(ctx context.Context) error {
+	return nil
+}
+
+// postScreen sends body to Neko's screen API, retrying once with a fresh token on a 401 —
+// the same retry-once pattern the pre-driver display.go code used.
+func (d *nekoDriver) postScreen(ctx context.Context, body []byte) error {
+	token, err := d.tokenFunc(ctx)
+	if err != nil {
+		return fmt.Errorf("get neko token: %w", err)
+	}
+
+	resp, err := d.doPostScreen(ctx, body, token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		d.clearToken()
+		token, err = d.tokenFunc(ctx)
+		if err != nil {
+			return fmt.Errorf("get fresh neko token: %w", err)
+		}
+		resp, err = d.doPostScreen(ctx, body, token)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("neko API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func (d *nekoDriver) doPostScreen(ctx context.Context, body []byte, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/api/room/screen", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("create neko screen request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("call neko screen API: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,131 @@
+package display
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// supervisorRPCClient speaks just enough of supervisord's XML-RPC interface (methodCall /
+// methodResponse over HTTP, here tunneled through a unix socket) to call its process-control
+// methods. It deliberately doesn't implement the full XML-RPC value spec (structs, arrays,
+// nested members) since every method this package calls takes a string and a bool and
+// returns a bool.
+type supervisorRPCClient struct {
+	socketPath string
+}
+
+func newSupervisorRPCClient(socketPath string) *supervisorRPCClient {
+	return &supervisorRPCClient{socketPath: socketPath}
+}
+
+// call invokes method on supervisord with args (each either a string or a bool) and returns
+// an error if the RPC transport fails or supervisord responds with a <fault>.
+func (c *supervisorRPCClient) call(ctx context.Context, method string, args ...any) error {
+	body, err := marshalMethodCall(method, args)
+	if err != nil {
+		return err
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		return fmt.Errorf("dial supervisord socket %s: %w", c.socketPath, err)
+	}
+	defer conn.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://supervisor/RPC2", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("write rpc request: %w", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fmt.Errorf("read rpc response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed methodResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("decode rpc response: %w", err)
+	}
+	if parsed.Fault != nil {
+		return fmt.Errorf("supervisord fault: %s (code %d)", parsed.Fault.stringMember(), parsed.Fault.intMember())
+	}
+	return nil
+}
+
+// methodResponse mirrors the subset of XML-RPC's <methodResponse> this client needs: either
+// a <params> success payload (ignored — callers only care whether a fault occurred) or a
+// <fault><value><struct> with faultCode/faultString members.
+type methodResponse struct {
+	XMLName xml.Name    `xml:"methodResponse"`
+	Fault   *faultValue `xml:"fault>value>struct"`
+}
+
+type faultValue struct {
+	Members []struct {
+		Name  string `xml:"name"`
+		Value struct {
+			Int    *int   `xml:"int"`
+			String string `xml:"string"`
+		} `xml:"value"`
+	} `xml:"member"`
+}
+
+func (f *faultValue) stringMember() string {
+	for _, m := range f.Members {
+		if m.Name == "faultString" {
+			return m.Value.String
+		}
+	}
+	return ""
+}
+
+func (f *faultValue) intMember() int {
+	for _, m := range f.Members {
+		if m.Name == "faultCode" && m.Value.Int != nil {
+			return *m.Value.Int
+		}
+	}
+	return 0
+}
+
+// marshalMethodCall renders method and args as an XML-RPC <methodCall> document. Supported
+// arg types are string and bool, which is all supervisord's process-control API needs.
+func marshalMethodCall(method string, args []any) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<methodCall><methodName>")
+	xml.EscapeText(&b, []byte(method))
+	b.WriteString("</methodName><params>")
+	for _, a := range args {
+		b.WriteString("<param><value>")
+		switch v := a.(type) {
+		case string:
+			b.WriteString("<string>")
+			xml.EscapeText(&b, []byte(v))
+			b.WriteString("</string>")
+		case bool:
+			if v {
+				b.WriteString("<boolean>1</boolean>")
+			} else {
+				b.WriteString("<boolean>0</boolean>")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported xml-rpc arg type %T", a)
+		}
+		b.WriteString("</value></param>")
+	}
+	b.WriteString("</params></methodCall>")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,231 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/randr"
+	"github.com/jezek/xgb/xproto"
+	"github.com/onkernel/kernel-images/server/lib/modeline"
+)
+
+// xorgDriver talks to the X server directly over the XRANDR extension instead of forking
+// xrandr(1) and scraping its text output.
+type xorgDriver struct {
+	conn *xgb.Conn
+	root xproto.Window
+
+	// modeIDsMu/modeIDs cache the RandR ModeId created for each generated modeline name, so
+	// repeated SetMode calls for the same resolution don't round-trip CreateMode again.
+	modeIDsMu sync.Mutex
+	modeIDs   map[string]uint32
+}
+
+// NewXorgDriver opens an X connection to displayAddr (e.g. ":1") and verifies the RandR
+// extension is present.
+func NewXorgDriver(displayAddr string) (Driver, error) {
+	conn, err := xgb.NewConnDisplay(displayAddr)
+	if err != nil {
+		return nil, fmt.Errorf("connect to X display %s: %w", displayAddr, err)
+	}
+	if err := randr.Init(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("init randr extension: %w", err)
+	}
+
+	setup := xproto.Setup(conn)
+	root := setup.DefaultScreen(conn).Root
+
+	return &xorgDriver{conn: conn, root: root, modeIDs: make(map[string]uint32)}, nil
+}
+
+// primaryOutputAndCrtc resolves the output (matching name, or the first connected output if
+// name is empty) and its currently assigned CRTC.
+func (d *xorgDriver) primaryOutputAndCrtc(name string) (randr.Output, randr.Crtc, *randr.GetScreenResourcesReply, error) {
+	res, err := randr.GetScreenResources(d.conn, d.root).Reply()
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("get screen resources: %w", err)
+	}
+
+	for _, out := range res.Outputs {
+		info, err := randr.GetOutputInfo(d.conn, out, res.ConfigTimestamp).Reply()
+		if err != nil || info.Connection != randr.ConnectionConnected {
+			continue
+		}
+		if name != "" && string(info.Name) != name {
+			continue
+		}
+		if info.Crtc == 0 {
+			continue
+		}
+		return out, info.Crtc, res, nil
+	}
+	return 0, 0, res, fmt.Errorf("no connected output found matching %q", name)
+}
+
+func (d *xorgDriver) GetMode(ctx context.Context, output string) (Mode, error) {
+	_, crtc, res, err := d.primaryOutputAndCrtc(output)
+	if err != nil {
+		return Mode{}, err
+	}
+	crtcInfo, err := randr.GetCrtcInfo(d.conn, crtc, res.ConfigTimestamp).Reply()
+	if err != nil {
+		return Mode{}, fmt.Errorf("get crtc info: %w", err)
+	}
+	for _, m := range res.Modes {
+		if randr.Mode(m.Id) == crtcInfo.Mode {
+			return Mode{Width: int(m.Width), Height: int(m.Height), RefreshRate: refreshRateOf(m)}, nil
+		}
+	}
+	return Mode{Width: int(crtcInfo.Width), Height: int(crtcInfo.Height)}, nil
+}
+
+func (d *xorgDriver) SetMode(ctx context.Context, output string, mode Mode) error {
+	out, crtc, res, err := d.primaryOutputAndCrtc(output)
+	if err != nil {
+		return err
+	}
+
+	modeID, err := d.findOrCreateMode(res, output, mode)
+	if err != nil {
+		return err
+	}
+
+	crtcInfo, err := randr.GetCrtcInfo(d.conn, crtc, res.ConfigTimestamp).Reply()
+	if err != nil {
+		return fmt.Errorf("get crtc info: %w", err)
+	}
+
+	cookie := randr.SetCrtcConfig(d.conn, crtc, xproto.TimeCurrentTime, res.ConfigTimestamp,
+		crtcInfo.X, crtcInfo.Y, randr.Mode(modeID), crtcInfo.Rotation, []randr.Output{out})
+	if _, err := cookie.Reply(); err != nil {
+		return fmt.Errorf("set crtc config: %w", err)
+	}
+	return nil
+}
+
+func (d *xorgDriver) ListModes(ctx context.Context, output string) ([]Mode, error) {
+	_, crtc, res, err := d.primaryOutputAndCrtc(output)
+	if err != nil {
+		return nil, err
+	}
+	crtcInfo, err := randr.GetCrtcInfo(d.conn, crtc, res.ConfigTimestamp).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("get crtc info: %w", err)
+	}
+
+	byID := make(map[randr.Mode]randr.ModeInfo, len(res.Modes))
+	for _, m := range res.Modes {
+		byID[randr.Mode(m.Id)] = m
+	}
+
+	modes := make([]Mode, 0, len(crtcInfo.Possible))
+	for _, id := range crtcInfo.Possible {
+		if info, ok := byID[id]; ok {
+			modes = append(modes, Mode{Width: int(info.Width), Height: int(info.Height), RefreshRate: refreshRateOf(info)})
+		}
+	}
+	return modes, nil
+}
+
+func (d *xorgDriver) AddMode(ctx context.Context, output string, mode Mode) error {
+	out, _, _, err := d.primaryOutputAndCrtc(output)
+	if err != nil {
+		return err
+	}
+	refresh := mode.RefreshRate
+	if refresh == 0 {
+		refresh = 60
+	}
+	name := modeline.ComputeReducedBlanking(mode.Width, mode.Height, refresh).Name
+	_, err = d.createMode(out, name, mode.Width, mode.Height, refresh)
+	return err
+}
+
+// findOrCreateMode returns the ModeId of an existing mode matching mode's geometry/rate, or
+// creates one (and attaches it to output) if none exists yet. It consults d.modeIDs first so
+// a resolution this driver has already generated a CVT modeline for doesn't pay for another
+// CreateMode/AddOutputMode round trip on every SetMode.
+func (d *xorgDriver) findOrCreateMode(res *randr.GetScreenResourcesReply, output string, mode Mode) (uint32, error) {
+	refresh := mode.RefreshRate
+	if refresh == 0 {
+		refresh = 60
+	}
+	name := modeline.ComputeReducedBlanking(mode.Width, mode.Height, refresh).Name
+
+	d.modeIDsMu.Lock()
+	if id, ok := d.modeIDs[name]; ok {
+		d.modeIDsMu.Unlock()
+		return id, nil
+	}
+	d.modeIDsMu.Unlock()
+
+	for _, m := range res.Modes {
+		if int(m.Width) == mode.Width && int(m.Height) == mode.Height && refreshRateOf(m) == refresh {
+			d.cacheModeID(name, m.Id)
+			return m.Id, nil
+		}
+	}
+
+	out, _, _, err := d.primaryOutputAndCrtc(output)
+	if err != nil {
+		return 0, err
+	}
+	return d.createMode(out, name, mode.Width, mode.Height, refresh)
+}
+
+// createMode computes a CVT reduced-blanking modeline for width x height @ refreshHz,
+// registers it with the X server (the RandR equivalent of `xrandr --newmode`), and attaches
+// it to out (`xrandr --addmode`) so it becomes selectable by SetCrtcConfig.
+func (d *xorgDriver) createMode(out randr.Output, name string, width, height, refreshHz int) (uint32, error) {
+	cvt := modeline.ComputeReducedBlanking(width, height, refreshHz)
+
+	info := randr.ModeInfo{
+		Width:      uint16(cvt.HActive),
+		Height:     uint16(cvt.VActive),
+		DotClock:   cvt.PixelClockHz,
+		HTotal:     uint16(cvt.HTotal),
+		HSyncStart: uint16(cvt.HSyncStart),
+		HSyncEnd:   uint16(cvt.HSyncEnd),
+		VTotal:     uint16(cvt.VTotal),
+		VSyncStart: uint16(cvt.VSyncStart),
+		VSyncEnd:   uint16(cvt.VSyncEnd),
+		Name:       []byte(cvt.Name),
+		NameLen:    uint16(len(cvt.Name)),
+	}
+
+	reply, err := randr.CreateMode(d.conn, d.root, info).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("create mode %s: %w", cvt.Name, err)
+	}
+	if err := randr.AddOutputModeChecked(d.conn, out, randr.Mode(reply.Mode)).Check(); err != nil {
+		return 0, fmt.Errorf("attach mode %s to output: %w", cvt.Name, err)
+	}
+
+	d.cacheModeID(name, reply.Mode)
+	return reply.Mode, nil
+}
+
+func (d *xorgDriver) cacheModeID(name string, id uint32) {
+	d.modeIDsMu.Lock()
+	d.modeIDs[name] = id
+	d.modeIDsMu.Unlock()
+}
+
+// Restart is a no-op: RandR mode changes apply live, unlike Xvfb which must be restarted to
+// pick up new -screen geometry.
+func (d *xorgDriver) Restart(ctx context.Context) error {
+	return nil
+}
+
+// refreshRateOf computes the rounded vertical refresh rate of a RandR ModeInfo from its
+// pixel clock and timing totals, mirroring what `xrandr --verbose` reports.
+func refreshRateOf(m randr.ModeInfo) int {
+	if m.HTotal == 0 || m.VTotal == 0 {
+		return 0
+	}
+	rate := float64(m.DotClock) / (float64(m.HTotal) * float64(m.VTotal))
+	return int(rate + 0.5)
+}
@@ -0,0 +1,256 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// xvfbDriver manages an Xvfb instance's virtual screens by rewriting its supervisor program
+// config (one "-screen i WxHx24" argument per screen) and asking supervisord, over its RPC
+// unix socket, to restart the program so it picks up the new geometry.
+type xvfbDriver struct {
+	configPath string
+	program    string
+	supervisor *supervisorRPCClient
+
+	// modes caches AddMode calls per output until the next SetMode/Restart, since Xvfb has no
+	// notion of "registered but inactive" modes the way RandR does.
+	modes map[string][]Mode
+}
+
+// NewXvfbDriver opens an RPC connection to supervisord over socketPath and returns a driver
+// that rewrites program's "command" directive in configPath to change screen geometry.
+func NewXvfbDriver(configPath, program, socketPath string) (Driver, error) {
+	return &xvfbDriver{
+		configPath: configPath,
+		program:    program,
+		supervisor: newSupervisorRPCClient(socketPath),
+		modes:      make(map[string][]Mode),
+	}, nil
+}
+
+func (d *xvfbDriver) GetMode(ctx context.Context, output string) (Mode, error) {
+	cfg, err := readINI(d.configPath)
+	if err != nil {
+		return Mode{}, fmt.Errorf("read xvfb config: %w", err)
+	}
+	screens := parseScreenArgs(cfg.get(d.program, "command"))
+	idx, err := screenIndex(output)
+	if err != nil {
+		return Mode{}, err
+	}
+	if idx >= len(screens) {
+		return Mode{}, fmt.Errorf("no screen %d configured", idx)
+	}
+	return screens[idx], nil
+}
+
+func (d *xvfbDriver) SetMode(ctx context.Context, output string, mode Mode) error {
+	idx, err := screenIndex(output)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := readINI(d.configPath)
+	if err != nil {
+		return fmt.Errorf("read xvfb config: %w", err)
+	}
+
+	command := cfg.get(d.program, "command")
+	screens := parseScreenArgs(command)
+	for len(screens) <= idx {
+		screens = append(screens, Mode{Width: 1024, Height: 768})
+	}
+	screens[idx] = mode
+
+	cfg.set(d.program, "command", rewriteScreenArgs(command, screens))
+	if err := writeINI(d.configPath, cfg); err != nil {
+		return fmt.Errorf("write xvfb config: %w", err)
+	}
+
+	return d.Restart(ctx)
+}
+
+func (d *xvfbDriver) ListModes(ctx context.Context, output string) ([]Mode, error) {
+	active, err := d.GetMode(ctx, output)
+	if err != nil {
+		return nil, err
+	}
+	modes := append([]Mode{active}, d.modes[output]...)
+	return modes, nil
+}
+
+// AddMode just caches mode for ListModes; Xvfb has no way to register a resolution without
+// also switching to it, unlike RandR's separate CreateMode/SetCrtcConfig steps.
+func (d *xvfbDriver) AddMode(ctx context.Context, output string, mode Mode) error {
+	d.modes[output] = append(d.modes[output], mode)
+	return nil
+}
+
+// Restart tells supervisord to stop and start d.program so it re-execs Xvfb with the
+// rewritten -screen arguments; supervisorctl's own "restart" does the same two RPC calls.
+func (d *xvfbDriver) Restart(ctx context.Context) error {
+	if err := d.supervisor.call(ctx, "supervisor.stopProcess", d.program, true); err != nil {
+		return fmt.Errorf("stop %s: %w", d.program, err)
+	}
+	if err := d.supervisor.call(ctx, "supervisor.startProcess", d.program, true); err != nil {
+		return fmt.Errorf("start %s: %w", d.program, err)
+	}
+	return nil
+}
+
+// screenIndex maps an output name like "screen0" to its numeric Xvfb screen index. An empty
+// output name addresses screen 0, the only screen most callers ever configure.
+func screenIndex(output string) (int, error) {
+	if output == "" {
+		return 0, nil
+	}
+	var idx int
+	if _, err := fmt.Sscanf(output, "screen%d", &idx); err != nil {
+		return 0, fmt.Errorf("invalid xvfb output %q, expected screenN", output)
+	}
+	return idx, nil
+}
+
+// parseScreenArgs extracts each "-screen i WxHx24" triple from an Xvfb command line, in
+// screen-index order.
+func parseScreenArgs(command string) []Mode {
+	fields := strings.Fields(command)
+	var modes []Mode
+	for i := 0; i < len(fields)-2; i++ {
+		if fields[i] != "-screen" {
+			continue
+		}
+		geom := fields[i+2]
+		parts := strings.SplitN(geom, "x", 3)
+		if len(parts) < 2 {
+			continue
+		}
+		var w, h int
+		if _, err := fmt.Sscanf(parts[0]+"x"+parts[1], "%dx%d", &w, &h); err == nil {
+			modes = append(modes, Mode{Width: w, Height: h})
+		}
+	}
+	return modes
+}
+
+// rewriteScreenArgs replaces every "-screen i WxHx24" triple in command with the geometry
+// from screens, preserving everything else on the command line (the Xvfb binary path, :1
+// display argument, -nolisten tcp, etc).
+func rewriteScreenArgs(command string, screens []Mode) string {
+	fields := strings.Fields(command)
+	var kept []string
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "-screen" && i+2 < len(fields) {
+			i += 2
+			continue
+		}
+		kept = append(kept, fields[i])
+	}
+	for i, m := range screens {
+		kept = append(kept, "-screen", fmt.Sprintf("%d", i), fmt.Sprintf("%dx%dx24", m.Width, m.Height))
+	}
+	return strings.Join(kept, " ")
+}
+
+// iniFile is a minimal, order-preserving representation of a supervisor .conf file: enough
+// structure to replace a single directive's value without reformatting the rest of the file.
+type iniFile struct {
+	sections []iniSection
+}
+
+type iniSection struct {
+	name  string
+	lines []string // raw lines, including "key = value" directives and comments/blanks
+}
+
+func readINI(path string) (*iniFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &iniFile{}
+	var cur *iniSection
+	for _, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			f.sections = append(f.sections, iniSection{name: trimmed[1 : len(trimmed)-1]})
+			cur = &f.sections[len(f.sections)-1]
+			continue
+		}
+		if cur == nil {
+			f.sections = append(f.sections, iniSection{name: ""})
+			cur = &f.sections[len(f.sections)-1]
+		}
+		cur.lines = append(cur.lines, line)
+	}
+	return f, nil
+}
+
+func (f *iniFile) get(section, key string) string {
+	for _, s := range f.sections {
+		if s.name != section {
+			continue
+		}
+		for _, line := range s.lines {
+			k, v, ok := splitDirective(line)
+			if ok && k == key {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+func (f *iniFile) set(section, key, value string) {
+	for i := range f.sections {
+		if f.sections[i].name != section {
+			continue
+		}
+		for j, line := range f.sections[i].lines {
+			k, _, ok := splitDirective(line)
+			if ok && k == key {
+				f.sections[i].lines[j] = fmt.Sprintf("%s = %s", key, value)
+				return
+			}
+		}
+		f.sections[i].lines = append(f.sections[i].lines, fmt.Sprintf("%s = %s", key, value))
+		return
+	}
+	f.sections = append(f.sections, iniSection{name: section, lines: []string{fmt.Sprintf("%s = %s", key, value)}})
+}
+
+func splitDirective(line string) (key, value string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+		return "", "", false
+	}
+	idx := strings.IndexAny(trimmed, "=")
+	if idx < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(trimmed[:idx]), strings.TrimSpace(trimmed[idx+1:]), true
+}
+
+func writeINI(path string, f *iniFile) error {
+	var buf bytes.Buffer
+	for _, s := range f.sections {
+		if s.name != "" {
+			fmt.Fprintf(&buf, "[%s]\n", s.name)
+		}
+		for _, line := range s.lines {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Clean(path))
+}
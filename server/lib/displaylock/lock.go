@@ -0,0 +1,153 @@
+// Package displaylock coordinates display changes (resolution/layout) against the activity
+// that a change would otherwise race with: live viewers, in-progress recordings, replays.
+// Holders of a read lease represent "something is using the display as it is right now";
+// PatchDisplay (or any other mutator) takes a write lease, which blocks new read leases and
+// waits for existing ones to drain, closing the check-then-act race a one-shot "is anything
+// active?" sample leaves open.
+package displaylock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nrednav/cuid2"
+)
+
+// Holder describes one read or write lease currently outstanding, for the debug listing.
+type Holder struct {
+	ID         string
+	Kind       string // "read" or "write"
+	Label      string
+	AcquiredAt time.Time
+}
+
+// DisplayLock is a single-writer/multi-reader lock with leases identified by an opaque ID,
+// rather than by goroutine, since readers and the writer are typically released from a
+// different goroutine (an HTTP handler, an event callback) than the one that acquired them.
+type DisplayLock struct {
+	mu      sync.Mutex
+	readers map[string]Holder
+	writer  *Holder
+	waitCh  chan struct{}
+}
+
+// New returns a DisplayLock with no outstanding leases.
+func New() *DisplayLock {
+	return &DisplayLock{
+		readers: make(map[string]Holder),
+		waitCh:  make(chan struct{}),
+	}
+}
+
+// wake must be called with l.mu held; it unblocks every goroutine parked in wait.
+func (l *DisplayLock) wake() {
+	close(l.waitCh)
+	l.waitCh = make(chan struct{})
+}
+
+// wait blocks until the next call to wake, or ctx is done.
+func (l *DisplayLock) wait(ctx context.Context) error {
+	l.mu.Lock()
+	ch := l.waitCh
+	l.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AcquireRead blocks until no write lease is held (or active), then registers a read lease
+// labeled for the debug listing. It returns a release func the caller must call exactly once
+// when the activity it represents (a recording, a connected viewer, ...) ends.
+func (l *DisplayLock) AcquireRead(ctx context.Context, label string) (release func(), err error) {
+	for {
+		l.mu.Lock()
+		if l.writer == nil {
+			id := cuid2.Generate()
+			l.readers[id] = Holder{ID: id, Kind: "read", Label: label, AcquiredAt: time.Now()}
+			l.mu.Unlock()
+			return func() { l.releaseRead(id) }, nil
+		}
+		l.mu.Unlock()
+
+		if err := l.wait(ctx); err != nil {
+			return nil, fmt.Errorf("acquire read lease %q: %w", label, err)
+		}
+	}
+}
+
+func (l *DisplayLock) releaseRead(id string) {
+	l.mu.Lock()
+	delete(l.readers, id)
+	l.wake()
+	l.mu.Unlock()
+}
+
+// TryLock acquires the write lease: it immediately blocks new read leases from being granted,
+// then waits up to timeout for every already-outstanding read lease to release before
+// returning. A timeout <= 0 waits indefinitely (bounded only by ctx). Only one write lease can
+// be outstanding at a time; a second TryLock call blocks behind the first the same way a read
+// lease would.
+func (l *DisplayLock) TryLock(ctx context.Context, timeout time.Duration) (release func(), err error) {
+	for {
+		l.mu.Lock()
+		if l.writer == nil {
+			id := cuid2.Generate()
+			l.writer = &Holder{ID: id, Kind: "write", Label: "display-change", AcquiredAt: time.Now()}
+			l.mu.Unlock()
+			break
+		}
+		l.mu.Unlock()
+		if err := l.wait(ctx); err != nil {
+			return nil, fmt.Errorf("acquire write lease: %w", err)
+		}
+	}
+
+	drainCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for {
+		l.mu.Lock()
+		if len(l.readers) == 0 {
+			l.mu.Unlock()
+			return l.releaseWrite, nil
+		}
+		l.mu.Unlock()
+
+		if err := l.wait(drainCtx); err != nil {
+			l.releaseWrite()
+			return nil, fmt.Errorf("wait for readers to drain: %w", err)
+		}
+	}
+}
+
+func (l *DisplayLock) releaseWrite() {
+	l.mu.Lock()
+	l.writer = nil
+	l.wake()
+	l.mu.Unlock()
+}
+
+// Holders returns every outstanding lease (read and write, if any), for the GET /display/lock
+// debug endpoint. The returned slice is a snapshot; it's not updated as leases change.
+func (l *DisplayLock) Holders() []Holder {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	holders := make([]Holder, 0, len(l.readers)+1)
+	for _, h := range l.readers {
+		holders = append(holders, h)
+	}
+	if l.writer != nil {
+		holders = append(holders, *l.writer)
+	}
+	return holders
+}
@@ -0,0 +1,91 @@
+package displaylock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireRead_MultipleConcurrentReaders(t *testing.T) {
+	l := New()
+	ctx := context.Background()
+
+	release1, err := l.AcquireRead(ctx, "reader-1")
+	if err != nil {
+		t.Fatalf("AcquireRead: %v", err)
+	}
+	release2, err := l.AcquireRead(ctx, "reader-2")
+	if err != nil {
+		t.Fatalf("AcquireRead: %v", err)
+	}
+
+	if got := len(l.Holders()); got != 2 {
+		t.Fatalf("Holders() len = %d, want 2", got)
+	}
+
+	release1()
+	release2()
+
+	if got := len(l.Holders()); got != 0 {
+		t.Fatalf("Holders() len after release = %d, want 0", got)
+	}
+}
+
+func TestTryLock_WaitsForReadersToDrain(t *testing.T) {
+	l := New()
+	ctx := context.Background()
+
+	release, err := l.AcquireRead(ctx, "recording")
+	if err != nil {
+		t.Fatalf("AcquireRead: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		writeRelease, err := l.TryLock(ctx, time.Second)
+		if err == nil {
+			writeRelease()
+		}
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	release()
+
+	if err := <-done; err != nil {
+		t.Fatalf("TryLock did not succeed after reader released: %v", err)
+	}
+}
+
+func TestTryLock_TimesOutWhileReaderHeld(t *testing.T) {
+	l := New()
+	ctx := context.Background()
+
+	release, err := l.AcquireRead(ctx, "recording")
+	if err != nil {
+		t.Fatalf("AcquireRead: %v", err)
+	}
+	defer release()
+
+	if _, err := l.TryLock(ctx, 20*time.Millisecond); err == nil {
+		t.Fatal("expected TryLock to time out while a reader is held")
+	}
+}
+
+func TestTryLock_BlocksNewReaders(t *testing.T) {
+	l := New()
+	ctx := context.Background()
+
+	writeRelease, err := l.TryLock(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.AcquireRead(readCtx, "viewer"); err == nil {
+		t.Fatal("expected AcquireRead to block while a write lease is held")
+	}
+
+	writeRelease()
+}
@@ -0,0 +1,182 @@
+// Package extensionmanifest persists the set of installed Chromium extensions (name, install
+// time, source, checksum, size, enabled flag) to a JSON manifest alongside
+// /home/kernel/extensions, so the extension CRUD endpoints in api have somewhere to enumerate
+// and toggle extensions without re-deriving that state from directory listings.
+package extensionmanifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/exttrust"
+)
+
+// Source records how an extension was installed, so GET responses can explain provenance.
+type Source string
+
+const (
+	SourceUpload Source = "upload"
+	SourceCRXURL Source = "crx_url"
+)
+
+// Entry is one installed extension's manifest record.
+type Entry struct {
+	Name        string    `json:"name"`
+	InstalledAt time.Time `json:"installed_at"`
+	Source      Source    `json:"source"`
+	// SourceURL is set when Source is SourceCRXURL; empty for an uploaded zip.
+	SourceURL string `json:"source_url,omitempty"`
+	SHA256    string `json:"sha256"`
+	SizeBytes int64  `json:"size_bytes"`
+	Enabled   bool   `json:"enabled"`
+	// Verification is the trust policy's result for this install, so GET /extensions/{name} can
+	// show whether the bundle was signed and whether that signature verified.
+	Verification exttrust.VerificationResult `json:"verification"`
+}
+
+// Manifest is the in-memory, disk-backed set of installed extensions. All methods are safe for
+// concurrent use.
+type Manifest struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]Entry
+}
+
+// Load reads path if it exists, or starts an empty manifest if it doesn't (the first extension
+// ever installed won't have one yet).
+func Load(path string) (*Manifest, error) {
+	m := &Manifest{path: path, entries: make(map[string]Entry)}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extension manifest: %w", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse extension manifest: %w", err)
+	}
+	for _, e := range entries {
+		m.entries[e.Name] = e
+	}
+	return m, nil
+}
+
+// ReloadFrom discards the in-memory entries and re-reads path, replacing m's path too. Used by
+// the snapshot-restore flow in extension_snapshot.go after swapping in a restored manifest.json,
+// so the running Manifest reflects the restored state instead of the one it was constructed with.
+func (m *Manifest) ReloadFrom(path string) error {
+	reloaded, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.path = path
+	m.entries = reloaded.entries
+	return nil
+}
+
+// List returns all entries, sorted by name for a stable GET /extensions response.
+func (m *Manifest) List() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+func (m *Manifest) Get(name string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[name]
+	return e, ok
+}
+
+// Put upserts an entry and persists the manifest.
+func (m *Manifest) Put(e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e.Name] = e
+	return m.save()
+}
+
+// Delete removes an entry (a no-op if it's already absent) and persists the manifest.
+func (m *Manifest) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, name)
+	return m.save()
+}
+
+// SetEnabled flips an entry's Enabled flag and persists the manifest.
+func (m *Manifest) SetEnabled(name string, enabled bool) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[name]
+	if !ok {
+		return Entry{}, fmt.Errorf("extension %q not found", name)
+	}
+	e.Enabled = enabled
+	m.entries[name] = e
+	return e, m.save()
+}
+
+// EnabledPaths returns extBase-joined paths for every enabled entry, sorted by name, in the form
+// the --disable-extensions-except= / --load-extension= flag values need.
+func (m *Manifest) EnabledPaths(extBase string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var names []string
+	for name, e := range m.entries {
+		if e.Enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(extBase, name)
+	}
+	return paths
+}
+
+// save writes the manifest to a temp file and renames it over m.path, so a crash mid-write never
+// leaves a truncated manifest behind. Callers must hold m.mu.
+func (m *Manifest) save() error {
+	entries := make([]Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal extension manifest: %w", err)
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write extension manifest: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to finalize extension manifest: %w", err)
+	}
+	return nil
+}
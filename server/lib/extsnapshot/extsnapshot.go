@@ -0,0 +1,201 @@
+// Package extsnapshot archives and restores the full on-disk extension state - the unpacked
+// extension directories, the merged chromium flags file, and the manifest tracking them - as a
+// single zstd-compressed tarball. This lets a "browser profile" be baked once and replayed onto
+// other kernel-image instances instead of re-installing every extension from scratch on boot.
+package extsnapshot
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Sources locates the on-disk state a snapshot captures and, on restore, recreates.
+type Sources struct {
+	ExtensionsDir string
+	FlagsPath     string
+	ManifestPath  string
+}
+
+// Tar entry names under which Create stores each source, so Restore knows where to recreate them
+// relative to its destination dir regardless of the absolute paths Sources used when it was
+// written.
+const (
+	extensionsEntryPrefix = "extensions/"
+	flagsEntryName        = "flags"
+	manifestEntryName     = "manifest.json"
+)
+
+// Create writes a zstd-compressed tarball of src to w. A source path that doesn't exist yet (for
+// example flags before the first extension install) is silently omitted rather than failing the
+// snapshot.
+func Create(src Sources, w io.Writer) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd writer: %w", err)
+	}
+	defer zw.Close()
+
+	tw := tar.NewWriter(zw)
+	defer tw.Close()
+
+	if err := addFile(tw, src.FlagsPath, flagsEntryName); err != nil {
+		return err
+	}
+	if err := addFile(tw, src.ManifestPath, manifestEntryName); err != nil {
+		return err
+	}
+	if err := addDir(tw, src.ExtensionsDir, extensionsEntryPrefix); err != nil {
+		return err
+	}
+	return nil
+}
+
+// addFile tars path under name, or does nothing if path doesn't exist.
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return fmt.Errorf("failed to build tar header for %s: %w", path, err)
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", path, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to write %s into archive: %w", path, err)
+	}
+	return nil
+}
+
+// addDir recursively tars dir's contents under prefix, or does nothing if dir doesn't exist.
+func addDir(tw *tar.Writer, dir, prefix string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("failed to compute relative path for %s: %w", path, err)
+		}
+		if rel == "." {
+			return nil
+		}
+		name := prefix + filepath.ToSlash(rel)
+
+		if fi.IsDir() {
+			hdr, err := tar.FileInfoHeader(fi, "")
+			if err != nil {
+				return fmt.Errorf("failed to build tar header for %s: %w", path, err)
+			}
+			hdr.Name = name + "/"
+			return tw.WriteHeader(hdr)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return fmt.Errorf("failed to build tar header for %s: %w", path, err)
+		}
+		hdr.Name = name
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write tar header for %s: %w", path, err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer f.Close()
+		if _, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("failed to write %s into archive: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// Restore unpacks a tarball produced by Create into dir, recreating dir/extensions, dir/flags,
+// and dir/manifest.json. Callers are expected to stage into an empty dir and swap it into place
+// (e.g. via os.Rename) once Restore returns successfully, rather than unpacking directly over
+// live state.
+func Restore(r io.Reader, dir string) error {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd reader: %w", err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		target := filepath.Join(dir, hdr.Name)
+		if !isWithin(dir, target) {
+			return fmt.Errorf("tar entry %q escapes snapshot directory", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+			}
+			if err := writeRegularFile(tr, target, hdr.FileInfo().Mode()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeRegularFile(r io.Reader, target string, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return nil
+}
+
+// isWithin reports whether target is dir or a descendant of it, guarding Restore against a tar
+// entry with a path-traversing name (e.g. "../../etc/passwd").
+func isWithin(dir, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,197 @@
+// Package fsops implements the POSIX-ish filesystem operations behind the server's
+// /fs/stat, /fs/lstat, /fs/chmod, /fs/chown, /fs/mkdir, /fs/rename, /fs/remove, /fs/symlink,
+// /fs/readlink, and /fs/setutimes endpoints (server/cmd/api/api/fsops_handlers.go). Unlike
+// server/lib/vfs, which abstracts read/write/list across local disk, S3, WebDAV, and SFTP, fsops
+// only ever operates on local paths - symlinks, inode metadata, and utimes have no meaningful
+// analog on an object store, and orchestration code driving this sandboxed browser only ever
+// needs syscall-level control over the container's own local filesystem.
+package fsops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Errno is a stable, platform-independent error class a remote client can branch on, the same
+// way it would branch on a syscall's errno.
+type Errno string
+
+const (
+	ENOENT    Errno = "ENOENT"
+	EACCES    Errno = "EACCES"
+	EEXIST    Errno = "EEXIST"
+	ENOTDIR   Errno = "ENOTDIR"
+	EISDIR    Errno = "EISDIR"
+	ENOTEMPTY Errno = "ENOTEMPTY"
+	EPERM     Errno = "EPERM"
+	EXDEV     Errno = "EXDEV"
+)
+
+// OpError pairs an Errno with the HTTP status a handler should respond with, so the translation
+// from a raw *os.PathError/syscall.Errno to something a remote client can rely on happens once,
+// in TranslateError, rather than being re-derived ad hoc in every handler.
+type OpError struct {
+	Errno      Errno
+	HTTPStatus int
+	err        error
+}
+
+func (e *OpError) Error() string { return e.err.Error() }
+func (e *OpError) Unwrap() error { return e.err }
+
+// TranslateError maps err to the stable Errno/HTTP status pair a remote client can act on. It
+// recognizes the os.ErrX sentinels directly (errors.Is) and falls back to the underlying
+// syscall.Errno a *os.PathError/*os.LinkError carries for the classes os.ErrX has no sentinel
+// for (ENOTDIR, EISDIR, ENOTEMPTY, EXDEV). err itself is returned unwrapped if it matches none
+// of these - callers should treat that as an unclassified error (500).
+func TranslateError(err error) *OpError {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return &OpError{Errno: ENOENT, HTTPStatus: 404, err: err}
+	case errors.Is(err, os.ErrExist):
+		return &OpError{Errno: EEXIST, HTTPStatus: 409, err: err}
+	case errors.Is(err, os.ErrPermission):
+		return &OpError{Errno: EACCES, HTTPStatus: 403, err: err}
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.ENOENT:
+			return &OpError{Errno: ENOENT, HTTPStatus: 404, err: err}
+		case syscall.EACCES:
+			return &OpError{Errno: EACCES, HTTPStatus: 403, err: err}
+		case syscall.EEXIST:
+			return &OpError{Errno: EEXIST, HTTPStatus: 409, err: err}
+		case syscall.ENOTDIR:
+			return &OpError{Errno: ENOTDIR, HTTPStatus: 400, err: err}
+		case syscall.EISDIR:
+			return &OpError{Errno: EISDIR, HTTPStatus: 400, err: err}
+		case syscall.ENOTEMPTY:
+			return &OpError{Errno: ENOTEMPTY, HTTPStatus: 409, err: err}
+		case syscall.EPERM:
+			return &OpError{Errno: EPERM, HTTPStatus: 403, err: err}
+		case syscall.EXDEV:
+			return &OpError{Errno: EXDEV, HTTPStatus: 400, err: err}
+		}
+	}
+
+	return &OpError{Errno: "", HTTPStatus: 500, err: err}
+}
+
+// Stat is what Stat/Lstat report back - the fields a remote caller would otherwise only get by
+// running `stat` itself, surfaced from the platform's underlying syscall.Stat_t.
+type Stat struct {
+	Mode      os.FileMode
+	Size      int64
+	Uid       uint32
+	Gid       uint32
+	AtimeNs   int64
+	MtimeNs   int64
+	CtimeNs   int64
+	Inode     uint64
+	Nlink     uint64
+	Dev       uint64
+	IsSymlink bool
+}
+
+func statFromInfo(info os.FileInfo) Stat {
+	st := Stat{
+		Mode:      info.Mode(),
+		Size:      info.Size(),
+		MtimeNs:   info.ModTime().UnixNano(),
+		IsSymlink: info.Mode()&os.ModeSymlink != 0,
+	}
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		st.Uid = sys.Uid
+		st.Gid = sys.Gid
+		st.Inode = sys.Ino
+		st.Nlink = uint64(sys.Nlink)
+		st.Dev = uint64(sys.Dev)
+		st.AtimeNs = sys.Atim.Sec*int64(time.Second) + sys.Atim.Nsec
+		st.CtimeNs = sys.Ctim.Sec*int64(time.Second) + sys.Ctim.Nsec
+	}
+	return st
+}
+
+// Stat follows symlinks, like the stat(2) syscall.
+func Stat(path string) (Stat, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Stat{}, err
+	}
+	return statFromInfo(info), nil
+}
+
+// Lstat does not follow a symlink at path - it reports the link itself, like lstat(2).
+func Lstat(path string) (Stat, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Stat{}, err
+	}
+	return statFromInfo(info), nil
+}
+
+// Chmod changes path's permission bits.
+func Chmod(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+// Chown changes path's owning uid/gid.
+func Chown(path string, uid, gid int) error {
+	return os.Chown(path, uid, gid)
+}
+
+// Mkdir creates path with the given permission bits. If recursive, missing parent directories
+// are created too (mkdir -p); otherwise the parent must already exist (mkdir).
+func Mkdir(path string, mode os.FileMode, recursive bool) error {
+	if recursive {
+		return os.MkdirAll(path, mode)
+	}
+	return os.Mkdir(path, mode)
+}
+
+// Rename moves oldPath to newPath, like rename(2). Moving across filesystems/mounts returns
+// EXDEV, same as the syscall - callers wanting that need to copy-then-remove instead.
+func Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+// Remove deletes path. If recursive, it removes a directory and everything under it (rm -rf);
+// otherwise a non-empty directory is refused with ENOTEMPTY (rm/rmdir).
+func Remove(path string, recursive bool) error {
+	if recursive {
+		return os.RemoveAll(path)
+	}
+	return os.Remove(path)
+}
+
+// Symlink creates newName as a symlink pointing at target.
+func Symlink(target, newName string) error {
+	return os.Symlink(target, newName)
+}
+
+// Readlink returns the target path symlinkPath points at.
+func Readlink(symlinkPath string) (string, error) {
+	return os.Readlink(symlinkPath)
+}
+
+// SetUTimes updates path's access and modification times, like utimes(2). os.Chtimes has no
+// "leave this one alone" sentinel, so callers wanting to change only one timestamp must pass the
+// existing value for the other.
+func SetUTimes(path string, atime, mtime time.Time) error {
+	return os.Chtimes(path, atime, mtime)
+}
+
+// String mirrors fmt.Stringer for OpError so log lines read naturally, e.g. "ENOENT: open
+// /foo: no such file or directory".
+func (e *OpError) String() string {
+	return fmt.Sprintf("%s: %s", e.Errno, e.err)
+}
@@ -0,0 +1,91 @@
+package fsops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateError(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+
+	_, err := Stat(filepath.Join(tmpDir, "missing"))
+	require.Error(t, err)
+	assert.Equal(t, ENOENT, TranslateError(err).Errno)
+	assert.Equal(t, 404, TranslateError(err).HTTPStatus)
+
+	nested := filepath.Join(tmpDir, "a", "b")
+	require.NoError(t, os.MkdirAll(nested, 0o755))
+	err = Mkdir(nested, 0o755, false)
+	require.Error(t, err)
+	assert.Equal(t, EEXIST, TranslateError(err).Errno)
+
+	notEmptyDir := filepath.Join(tmpDir, "notempty")
+	require.NoError(t, os.Mkdir(notEmptyDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(notEmptyDir, "f"), []byte("x"), 0o644))
+	err = Remove(notEmptyDir, false)
+	require.Error(t, err)
+	assert.Equal(t, ENOTEMPTY, TranslateError(err).Errno)
+}
+
+func TestStatLstatSymlinkReadlink(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target.txt")
+	require.NoError(t, os.WriteFile(target, []byte("hello"), 0o644))
+
+	link := filepath.Join(tmpDir, "link.txt")
+	require.NoError(t, Symlink(target, link))
+
+	got, err := Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, target, got)
+
+	lst, err := Lstat(link)
+	require.NoError(t, err)
+	assert.True(t, lst.IsSymlink)
+
+	st, err := Stat(link)
+	require.NoError(t, err)
+	assert.False(t, st.IsSymlink)
+	assert.EqualValues(t, 5, st.Size)
+}
+
+func TestMkdirRecursiveRenameRemove(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	nested := filepath.Join(tmpDir, "a", "b", "c")
+	require.NoError(t, Mkdir(nested, 0o755, true))
+
+	moved := filepath.Join(tmpDir, "moved")
+	require.NoError(t, Rename(nested, moved))
+	_, err := Stat(moved)
+	require.NoError(t, err)
+
+	require.NoError(t, Remove(moved, true))
+	_, err = Stat(moved)
+	assert.Equal(t, ENOENT, TranslateError(err).Errno)
+}
+
+func TestSetUTimes(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "f.txt")
+	require.NoError(t, os.WriteFile(path, []byte("x"), 0o644))
+
+	mtime := time.Unix(1700000000, 0)
+	require.NoError(t, SetUTimes(path, mtime, mtime))
+
+	st, err := Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, mtime.UnixNano(), st.MtimeNs)
+}
@@ -0,0 +1,72 @@
+// Package modeline computes VESA CVT (Coordinated Video Timings) reduced-blanking modelines
+// purely in Go, so a caller can register an arbitrary resolution with an X server (via
+// RandR's CreateMode, or a legacy "xrandr --newmode") without depending on a modeline already
+// being present in the server's built-in mode pool.
+package modeline
+
+import (
+	"fmt"
+	"math"
+)
+
+// Reduced-blanking v1 constants, per the VESA CVT spec. These are fixed regardless of
+// requested resolution/refresh rate; only the front porch and vertical back porch are
+// computed.
+const (
+	hBlankTotal   = 160 // H active + hBlankTotal = H total
+	hSyncWidth    = 32
+	hBackPorch    = 80
+	vFrontPorch   = 3
+	vSyncWidth    = 4
+	minVBackPorch = 6
+	minVBlankUS   = 460.0 // minimum vertical blanking time, in microseconds
+	clockStepMHz  = 0.25
+)
+
+// Mode is a fully computed modeline: everything xrandr's --newmode (or RandR's CreateMode)
+// needs to register the timing with an X server.
+type Mode struct {
+	Name         string
+	PixelClockHz uint32
+
+	HActive, HSyncStart, HSyncEnd, HTotal int
+	VActive, VSyncStart, VSyncEnd, VTotal int
+}
+
+// ComputeReducedBlanking derives a CVT-RBv1 modeline for width x height @ refreshHz.
+//
+// The recurrence: estimate the horizontal line period from the requested refresh rate and
+// the vertical line count (active lines plus the fixed front porch/sync/margin lines),
+// round the resulting pixel clock up to the nearest 0.25 MHz step, then size the vertical
+// back porch so the total vertical blanking period is at least minVBlankUS.
+func ComputeReducedBlanking(width, height, refreshHz int) Mode {
+	vTotalEstimate := height + vFrontPorch + vSyncWidth + minVBackPorch
+	hPeriodEstimateUS := (1e6/float64(refreshHz) - minVBlankUS) / float64(vTotalEstimate)
+
+	hTotal := width + hBlankTotal
+	pixelClockMHz := math.Round((float64(hTotal)/hPeriodEstimateUS)/clockStepMHz) * clockStepMHz
+
+	hPeriodUS := float64(hTotal) / pixelClockMHz
+
+	vBackPorch := minVBackPorch
+	if needed := int(math.Ceil(minVBlankUS/hPeriodUS)) - vFrontPorch - vSyncWidth; needed > vBackPorch {
+		vBackPorch = needed
+	}
+
+	hFrontPorch := hBlankTotal - hSyncWidth - hBackPorch
+
+	return Mode{
+		Name:         fmt.Sprintf("%dx%d_%d.00", width, height, refreshHz),
+		PixelClockHz: uint32(pixelClockMHz * 1e6),
+
+		HActive:    width,
+		HSyncStart: width + hFrontPorch,
+		HSyncEnd:   width + hFrontPorch + hSyncWidth,
+		HTotal:     hTotal,
+
+		VActive:    height,
+		VSyncStart: height + vFrontPorch,
+		VSyncEnd:   height + vFrontPorch + vSyncWidth,
+		VTotal:     height + vFrontPorch + vSyncWidth + vBackPorch,
+	}
+}
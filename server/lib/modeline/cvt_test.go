@@ -0,0 +1,42 @@
+package modeline
+
+import "testing"
+
+func TestComputeReducedBlanking(t *testing.T) {
+	m := ComputeReducedBlanking(1920, 1080, 60)
+
+	if m.Name != "1920x1080_60.00" {
+		t.Errorf("Name = %q, want %q", m.Name, "1920x1080_60.00")
+	}
+	if m.HActive != 1920 || m.VActive != 1080 {
+		t.Errorf("active area = %dx%d, want 1920x1080", m.HActive, m.VActive)
+	}
+	if m.HTotal != m.HActive+hBlankTotal {
+		t.Errorf("HTotal = %d, want %d", m.HTotal, m.HActive+hBlankTotal)
+	}
+	if m.VTotal <= m.VActive {
+		t.Errorf("VTotal = %d, must exceed VActive = %d", m.VTotal, m.VActive)
+	}
+	if m.PixelClockHz == 0 {
+		t.Error("PixelClockHz must be non-zero")
+	}
+
+	// The resulting clock should reproduce (approximately) the requested refresh rate.
+	gotRefresh := float64(m.PixelClockHz) / (float64(m.HTotal) * float64(m.VTotal))
+	if gotRefresh < 59 || gotRefresh > 61 {
+		t.Errorf("derived refresh rate = %.2f, want ~60", gotRefresh)
+	}
+}
+
+func TestComputeReducedBlanking_DifferentResolutions(t *testing.T) {
+	for _, tc := range []struct{ w, h, rate int }{
+		{1280, 720, 60},
+		{2560, 1440, 60},
+		{3840, 2160, 30},
+	} {
+		m := ComputeReducedBlanking(tc.w, tc.h, tc.rate)
+		if m.HTotal <= m.HActive || m.VTotal <= m.VActive {
+			t.Errorf("%dx%d@%d: invalid timing %+v", tc.w, tc.h, tc.rate, m)
+		}
+	}
+}
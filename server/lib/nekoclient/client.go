@@ -2,23 +2,36 @@ package nekoclient
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	nekooapi "github.com/m1k1o/neko/server/lib/oapi"
+	"golang.org/x/sync/singleflight"
 )
 
-// AuthClient wraps the Neko OpenAPI client and handles authentication automatically.
-// It manages token caching and refresh on 401 responses.
+// tokenRefreshSkew is how far ahead of a cached JWT's expiry Token proactively logs in again,
+// so a call that's about to use the token doesn't race its expiration mid-flight.
+const tokenRefreshSkew = 30 * time.Second
+
+// AuthClient wraps the Neko OpenAPI client and handles authentication automatically. It caches
+// the bearer token between calls, proactively refreshes it shortly before it expires, and falls
+// back to a reactive refresh-and-retry-once if a call gets a 401 anyway.
 type AuthClient struct {
 	client   *nekooapi.Client
-	tokenMu  sync.Mutex
-	token    string
 	username string
 	password string
+
+	tokenMu   sync.RWMutex
+	token     string
+	expiresAt time.Time // zero if the cached token's expiry couldn't be determined
+
+	loginGroup singleflight.Group
 }
 
 // NewAuthClient creates a new authenticated Neko client.
@@ -35,15 +48,33 @@ func NewAuthClient(baseURL, username, password string) (*AuthClient, error) {
 	}, nil
 }
 
-// ensureToken ensures we have a valid token, logging in if necessary.
-// Must be called with tokenMu held.
-func (c *AuthClient) ensureToken(ctx context.Context) error {
-	// Check if we already have a token
-	if c.token != "" {
-		return nil
+// Token returns a valid bearer token, logging in if we don't have one cached or the cached one is
+// within tokenRefreshSkew of expiring. Concurrent callers that all need a fresh token share a
+// single login via loginGroup rather than each performing their own. Exposed for callers (e.g. an
+// event stream subscriber) that need to authenticate something other than the generated client's
+// REST calls.
+func (c *AuthClient) Token(ctx context.Context) (string, error) {
+	c.tokenMu.RLock()
+	token, expiresAt := c.token, c.expiresAt
+	c.tokenMu.RUnlock()
+
+	if token != "" && (expiresAt.IsZero() || time.Now().Before(expiresAt.Add(-tokenRefreshSkew))) {
+		return token, nil
 	}
 
-	// Login to get a new token
+	v, err, _ := c.loginGroup.Do("login", func() (any, error) {
+		return c.login(ctx)
+	})
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+// login calls the Neko login API, caches the resulting token and its parsed expiry, and returns
+// the token. Concurrent calls are coalesced by Token via loginGroup, so this always performs a
+// real login rather than checking the cache itself.
+func (c *AuthClient) login(ctx context.Context) (string, error) {
 	loginReq := nekooapi.SessionLoginRequest{
 		Username: &c.username,
 		Password: &c.password,
@@ -51,137 +82,195 @@ func (c *AuthClient) ensureToken(ctx context.Context) error {
 
 	resp, err := c.client.Login(ctx, loginReq)
 	if err != nil {
-		return fmt.Errorf("failed to call login API: %w", err)
+		return "", fmt.Errorf("failed to call login API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("login API returned status %d: %s", resp.StatusCode, string(respBody))
+		return "", fmt.Errorf("login API returned status %d: %s", resp.StatusCode, string(respBody))
 	}
 
 	var loginResp nekooapi.SessionLoginResponse
 	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
-		return fmt.Errorf("failed to parse login response: %w", err)
+		return "", fmt.Errorf("failed to parse login response: %w", err)
 	}
-
 	if loginResp.Token == nil || *loginResp.Token == "" {
-		return fmt.Errorf("login response did not contain a token")
+		return "", fmt.Errorf("login response did not contain a token")
 	}
+	token := *loginResp.Token
 
-	c.token = *loginResp.Token
-	return nil
-}
+	// A token we can't parse the expiry of is still usable - it just falls back to reactive
+	// 401-triggered refresh instead of a proactive one.
+	expiresAt, err := jwtExpiry(token)
+	if err != nil {
+		expiresAt = time.Time{}
+	}
+
+	c.tokenMu.Lock()
+	c.token = token
+	c.expiresAt = expiresAt
+	c.tokenMu.Unlock()
 
-// clearToken clears the cached token, forcing a new login on next request.
-// Must be called with tokenMu held.
-func (c *AuthClient) clearToken() {
-	c.token = ""
+	return token, nil
 }
 
-// SessionsGet retrieves all active sessions from Neko API.
-func (c *AuthClient) SessionsGet(ctx context.Context) ([]nekooapi.SessionData, error) {
+// clearCachedToken discards the cached token if it's still stale, so the next Token call logs in
+// again. Taking the token a caller observed as stale (rather than unconditionally clearing) keeps
+// a concurrent caller's successful refresh from being thrown away out from under it.
+func (c *AuthClient) clearCachedToken(stale string) {
 	c.tokenMu.Lock()
 	defer c.tokenMu.Unlock()
+	if c.token == stale {
+		c.token = ""
+		c.expiresAt = time.Time{}
+	}
+}
 
-	// Ensure we have a token
-	if err := c.ensureToken(ctx); err != nil {
-		return nil, err
+// jwtExpiry extracts the exp claim from a JWT's payload segment without verifying its signature -
+// login already trusts the token because it just received it from the login API over an
+// authenticated connection; this only decides when to proactively refresh it.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("malformed JWT: expected 3 dot-separated parts, got %d", len(parts))
 	}
 
-	// Create request editor to add Bearer token
-	addAuth := func(ctx context.Context, req *http.Request) error {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to base64-decode JWT payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse JWT claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, fmt.Errorf("JWT has no exp claim")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// bearerEditor returns a nekooapi.RequestEditorFn that sets the Authorization header to token.
+func bearerEditor(token string) nekooapi.RequestEditorFn {
+	return func(ctx context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 		return nil
 	}
+}
 
-	// Make the request
-	resp, err := c.client.SessionsGet(ctx, addAuth)
+// do centralizes bearer token injection, 401-retry-once, status-code checking, and body draining
+// for every AuthClient method. Go doesn't allow type parameters on methods, so this is a free
+// function taking c as its first argument rather than a generic method on AuthClient.
+func do[T any](ctx context.Context, c *AuthClient, call func(context.Context, ...nekooapi.RequestEditorFn) (*http.Response, error), decode func(*http.Response) (T, error)) (T, error) {
+	var zero T
+
+	token, err := c.Token(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query sessions: %w", err)
+		return zero, err
+	}
+
+	resp, err := call(ctx, bearerEditor(token))
+	if err != nil {
+		return zero, fmt.Errorf("neko API request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// Handle 401 by clearing token and retrying once
 	if resp.StatusCode == http.StatusUnauthorized {
-		c.clearToken()
-		if err := c.ensureToken(ctx); err != nil {
-			return nil, err
-		}
+		_ = resp.Body.Close()
+		c.clearCachedToken(token)
 
-		// Retry with fresh token
-		addAuthRetry := func(ctx context.Context, req *http.Request) error {
-			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-			return nil
+		token, err = c.Token(ctx)
+		if err != nil {
+			return zero, err
 		}
-
-		resp, err = c.client.SessionsGet(ctx, addAuthRetry)
+		resp, err = call(ctx, bearerEditor(token))
 		if err != nil {
-			return nil, fmt.Errorf("failed to retry sessions query: %w", err)
+			return zero, fmt.Errorf("neko API request failed on retry: %w", err)
 		}
-		defer resp.Body.Close()
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("sessions API returned status %d: %s", resp.StatusCode, string(respBody))
+		return zero, fmt.Errorf("neko API returned status %d: %s", resp.StatusCode, string(respBody))
 	}
 
-	var sessions []nekooapi.SessionData
-	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
-		return nil, fmt.Errorf("failed to parse sessions response: %w", err)
+	return decode(resp)
+}
+
+// decodeJSON is a do decode func for endpoints that return a JSON body.
+func decodeJSON[T any](resp *http.Response) (T, error) {
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		var zero T
+		return zero, fmt.Errorf("failed to parse response: %w", err)
 	}
+	return v, nil
+}
 
-	return sessions, nil
+// decodeNone is a do decode func for endpoints whose response body carries nothing useful.
+func decodeNone(resp *http.Response) (struct{}, error) {
+	return struct{}{}, nil
+}
+
+// SessionsGet retrieves all active sessions from Neko API.
+func (c *AuthClient) SessionsGet(ctx context.Context) ([]nekooapi.SessionData, error) {
+	return do(ctx, c, c.client.SessionsGet, decodeJSON[[]nekooapi.SessionData])
 }
 
 // ScreenConfigurationChange changes the screen resolution via Neko API.
 func (c *AuthClient) ScreenConfigurationChange(ctx context.Context, config nekooapi.ScreenConfiguration) error {
-	c.tokenMu.Lock()
-	defer c.tokenMu.Unlock()
-
-	// Ensure we have a token
-	if err := c.ensureToken(ctx); err != nil {
-		return err
-	}
+	_, err := do(ctx, c, func(ctx context.Context, editors ...nekooapi.RequestEditorFn) (*http.Response, error) {
+		return c.client.ScreenConfigurationChange(ctx, config, editors...)
+	}, decodeNone)
+	return err
+}
 
-	// Create request editor to add Bearer token
-	addAuth := func(ctx context.Context, req *http.Request) error {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-		return nil
-	}
+// MembersList retrieves every registered member from Neko API.
+func (c *AuthClient) MembersList(ctx context.Context) ([]nekooapi.MemberData, error) {
+	return do(ctx, c, c.client.MembersList, decodeJSON[[]nekooapi.MemberData])
+}
 
-	// Make the request
-	resp, err := c.client.ScreenConfigurationChange(ctx, config, addAuth)
-	if err != nil {
-		return fmt.Errorf("failed to change screen configuration: %w", err)
-	}
-	defer resp.Body.Close()
+// BroadcastStatus retrieves the current RTMP broadcast status from Neko API.
+func (c *AuthClient) BroadcastStatus(ctx context.Context) (nekooapi.BroadcastStatus, error) {
+	return do(ctx, c, c.client.BroadcastStatus, decodeJSON[nekooapi.BroadcastStatus])
+}
 
-	// Handle 401 by clearing token and retrying once
-	if resp.StatusCode == http.StatusUnauthorized {
-		c.clearToken()
-		if err := c.ensureToken(ctx); err != nil {
-			return err
-		}
+// BroadcastStart begins broadcasting the session to rtmpURL.
+func (c *AuthClient) BroadcastStart(ctx context.Context, rtmpURL string) error {
+	req := nekooapi.BroadcastStartRequest{Url: &rtmpURL}
+	_, err := do(ctx, c, func(ctx context.Context, editors ...nekooapi.RequestEditorFn) (*http.Response, error) {
+		return c.client.BroadcastStart(ctx, req, editors...)
+	}, decodeNone)
+	return err
+}
 
-		// Retry with fresh token
-		addAuthRetry := func(ctx context.Context, req *http.Request) error {
-			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-			return nil
-		}
+// BroadcastStop ends the current broadcast, if one is active.
+func (c *AuthClient) BroadcastStop(ctx context.Context) error {
+	_, err := do(ctx, c, c.client.BroadcastStop, decodeNone)
+	return err
+}
 
-		resp, err = c.client.ScreenConfigurationChange(ctx, config, addAuthRetry)
-		if err != nil {
-			return fmt.Errorf("failed to retry screen configuration change: %w", err)
-		}
-		defer resp.Body.Close()
+// ClipboardGetText retrieves the current Neko session clipboard contents.
+func (c *AuthClient) ClipboardGetText(ctx context.Context) (string, error) {
+	clip, err := do(ctx, c, c.client.ClipboardGetText, decodeJSON[nekooapi.ClipboardText])
+	if err != nil {
+		return "", err
 	}
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("screen configuration API returned status %d: %s", resp.StatusCode, string(respBody))
+	if clip.Text == nil {
+		return "", nil
 	}
+	return *clip.Text, nil
+}
 
-	return nil
+// ClipboardSetText sets the Neko session clipboard contents.
+func (c *AuthClient) ClipboardSetText(ctx context.Context, text string) error {
+	body := nekooapi.ClipboardText{Text: &text}
+	_, err := do(ctx, c, func(ctx context.Context, editors ...nekooapi.RequestEditorFn) (*http.Response, error) {
+		return c.client.ClipboardSetText(ctx, body, editors...)
+	}, decodeNone)
+	return err
 }
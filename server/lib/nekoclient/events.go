@@ -0,0 +1,200 @@
+package nekoclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SessionEvent is a decoded Neko websocket event relevant to session/resize coordination.
+// Neko's event protocol nests the payload under "event"/"payload"; we only decode the fields
+// callers of EventTracker care about.
+type SessionEvent struct {
+	Event   string `json:"event"`
+	Payload struct {
+		ID    string `json:"id"`
+		State struct {
+			IsConnected bool `json:"is_connected"`
+			IsWatching  bool `json:"is_watching"`
+		} `json:"state"`
+	} `json:"payload"`
+}
+
+const (
+	eventSessionCreated = "session/created"
+	eventSessionDeleted = "session/deleted"
+	eventStateChange    = "session/state"
+)
+
+// EventTracker keeps a live count of connected+watching Neko sessions by subscribing to the
+// Neko websocket event stream, instead of point-in-time polling the REST sessions endpoint.
+// It reconnects with backoff if the connection drops.
+type EventTracker struct {
+	wsURL  string
+	token  func(ctx context.Context) (string, error)
+	logger *slog.Logger
+
+	// OnChange, if set, is called after every event that changes the active viewer count.
+	// Callers can use it to push a notification (e.g. an SSE event) without polling
+	// ActiveViewers themselves.
+	OnChange func(activeViewers int)
+
+	mu       sync.RWMutex
+	sessions map[string]bool // session id -> connected && watching
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEventTracker creates a tracker that connects to baseURL's websocket event endpoint,
+// authenticating with a token obtained from tokenFunc (called lazily, and again on reconnect).
+func NewEventTracker(baseURL string, tokenFunc func(ctx context.Context) (string, error), logger *slog.Logger) (*EventTracker, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse neko base url: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/ws"
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &EventTracker{
+		wsURL:    u.String(),
+		token:    tokenFunc,
+		logger:   logger,
+		sessions: make(map[string]bool),
+	}, nil
+}
+
+// Start begins consuming the event stream in a background goroutine. Call Stop to shut it down.
+func (t *EventTracker) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	t.cancel = cancel
+	t.done = make(chan struct{})
+	go t.run(ctx)
+}
+
+// Stop terminates the background connection and waits for it to exit.
+func (t *EventTracker) Stop() {
+	if t.cancel != nil {
+		t.cancel()
+		<-t.done
+	}
+}
+
+// ActiveViewers returns the number of sessions currently both connected and watching.
+func (t *EventTracker) ActiveViewers(ctx context.Context) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.activeViewersLocked()
+}
+
+// activeViewersLocked requires t.mu to be held (for reading or writing).
+func (t *EventTracker) activeViewersLocked() int {
+	n := 0
+	for _, watching := range t.sessions {
+		if watching {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *EventTracker) run(ctx context.Context) {
+	defer close(t.done)
+
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := t.connectAndConsume(ctx); err != nil {
+			t.logger.Warn("neko event stream disconnected, retrying", "err", err, "backoff", backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (t *EventTracker) connectAndConsume(ctx context.Context) error {
+	token, err := t.token(ctx)
+	if err != nil {
+		return fmt.Errorf("get neko token: %w", err)
+	}
+
+	u, err := url.Parse(t.wsURL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("dial neko websocket: %w", err)
+	}
+	defer conn.Close()
+
+	// Reset backoff baseline on a successful connect: a fresh connection has no session state
+	// until events arrive, so clear what we had before the drop.
+	t.mu.Lock()
+	t.sessions = make(map[string]bool)
+	t.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var evt SessionEvent
+		if err := json.Unmarshal(raw, &evt); err != nil {
+			continue
+		}
+		t.applyEvent(evt)
+	}
+}
+
+func (t *EventTracker) applyEvent(evt SessionEvent) {
+	t.mu.Lock()
+	before := t.activeViewersLocked()
+	switch evt.Event {
+	case eventSessionDeleted:
+		delete(t.sessions, evt.Payload.ID)
+	case eventSessionCreated, eventStateChange:
+		t.sessions[evt.Payload.ID] = evt.Payload.State.IsConnected && evt.Payload.State.IsWatching
+	}
+	after := t.activeViewersLocked()
+	t.mu.Unlock()
+
+	if after != before && t.OnChange != nil {
+		t.OnChange(after)
+	}
+}
@@ -0,0 +1,55 @@
+package nekoclient
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEventTracker_ApplyEvent(t *testing.T) {
+	var changes []int
+	tr := &EventTracker{
+		sessions: make(map[string]bool),
+		OnChange: func(activeViewers int) { changes = append(changes, activeViewers) },
+	}
+
+	created := SessionEvent{Event: eventSessionCreated}
+	created.Payload.ID = "s1"
+	created.Payload.State.IsConnected = true
+	created.Payload.State.IsWatching = true
+	tr.applyEvent(created)
+	if got := tr.ActiveViewers(context.Background()); got != 1 {
+		t.Fatalf("expected 1 active viewer after create, got %d", got)
+	}
+
+	updated := SessionEvent{Event: eventStateChange}
+	updated.Payload.ID = "s1"
+	updated.Payload.State.IsConnected = true
+	updated.Payload.State.IsWatching = false
+	tr.applyEvent(updated)
+	if got := tr.ActiveViewers(context.Background()); got != 0 {
+		t.Fatalf("expected 0 active viewers after stopping watch, got %d", got)
+	}
+
+	deleted := SessionEvent{Event: eventSessionDeleted}
+	deleted.Payload.ID = "s1"
+	tr.applyEvent(deleted)
+	if _, ok := tr.sessions["s1"]; ok {
+		t.Fatalf("expected session s1 to be removed from tracker")
+	}
+
+	if want := []int{1, 0}; !equalInts(changes, want) {
+		t.Fatalf("expected OnChange calls %v, got %v", want, changes)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
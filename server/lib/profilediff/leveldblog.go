@@ -0,0 +1,306 @@
+package profilediff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// This file implements just enough of LevelDB's write-ahead log format (see leveldb's
+// db/log_format.h/log_reader.cc/log_writer.cc) to read the Put/Delete operations IndexedDB and
+// Local Storage have written since their last compaction, and to append a new log segment of our
+// own. It deliberately does not read compacted ".ldb" SSTables - see the package doc for what
+// that means for Diff's completeness.
+
+const (
+	logBlockSize  = 32768
+	logHeaderSize = 7 // 4-byte masked crc32c + 2-byte length + 1-byte record type
+
+	logRecordZero   = 0
+	logRecordFull   = 1
+	logRecordFirst  = 2
+	logRecordMiddle = 3
+	logRecordLast   = 4
+)
+
+const crc32cMaskDelta = 0xa282ead8
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func maskCRC(crc uint32) uint32 {
+	return ((crc >> 15) | (crc << 17)) + crc32cMaskDelta
+}
+
+// readLogRecords reassembles a .log file's physical blocks into logical records (each one an
+// encoded write batch, see decodeWriteBatch). A record whose checksum doesn't match is dropped,
+// along with the rest of its block - the same "stop at the first corruption" behavior leveldb's
+// own log_reader.cc uses, since a partially-written trailing record is the normal way a log file
+// ends if Chromium was killed mid-write.
+func readLogRecords(data []byte) ([][]byte, error) {
+	var records [][]byte
+	var pending []byte
+	inFragment := false
+
+	for off := 0; off+logHeaderSize <= len(data); {
+		blockEnd := off + logBlockSize
+		if blockEnd > len(data) {
+			blockEnd = len(data)
+		}
+		for off+logHeaderSize <= blockEnd {
+			crc := binary.LittleEndian.Uint32(data[off : off+4])
+			length := int(binary.LittleEndian.Uint16(data[off+4 : off+6]))
+			recType := data[off+6]
+			payloadStart := off + logHeaderSize
+			payloadEnd := payloadStart + length
+			if payloadEnd > len(data) {
+				return records, nil // truncated trailing record; stop here.
+			}
+			payload := data[payloadStart:payloadEnd]
+
+			gotCRC := maskCRC(crc32.Update(crc32.Checksum([]byte{recType}, crc32cTable), crc32cTable, payload))
+			if gotCRC != crc {
+				return records, nil
+			}
+
+			switch recType {
+			case logRecordFull:
+				records = append(records, payload)
+				inFragment = false
+				pending = nil
+			case logRecordFirst:
+				pending = append([]byte(nil), payload...)
+				inFragment = true
+			case logRecordMiddle:
+				if inFragment {
+					pending = append(pending, payload...)
+				}
+			case logRecordLast:
+				if inFragment {
+					pending = append(pending, payload...)
+					records = append(records, pending)
+					pending = nil
+					inFragment = false
+				}
+			case logRecordZero:
+				// padding; ignore.
+			default:
+				return records, fmt.Errorf("unknown log record type %d at offset %d", recType, off)
+			}
+			off = payloadEnd
+		}
+		off = blockEnd
+	}
+	return records, nil
+}
+
+// writeBatchOp is one Put or Delete operation decoded from (or to be encoded into) a write batch.
+type writeBatchOp struct {
+	Delete bool
+	Key    []byte
+	Value  []byte
+}
+
+// decodeWriteBatch parses a leveldb WriteBatch's on-the-wire encoding: an 8-byte sequence number
+// and 4-byte count (both ignored here - Diff only cares about the operations), followed by that
+// many (tag, key[, value]) entries.
+func decodeWriteBatch(record []byte) ([]writeBatchOp, error) {
+	const batchHeaderSize = 12
+	if len(record) < batchHeaderSize {
+		return nil, fmt.Errorf("write batch record too short: %d bytes", len(record))
+	}
+	buf := record[batchHeaderSize:]
+
+	var ops []writeBatchOp
+	for len(buf) > 0 {
+		tag := buf[0]
+		buf = buf[1:]
+		switch tag {
+		case 1: // kTypeValue
+			key, rest, err := getLengthPrefixed(buf)
+			if err != nil {
+				return nil, err
+			}
+			value, rest2, err := getLengthPrefixed(rest)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, writeBatchOp{Key: key, Value: value})
+			buf = rest2
+		case 0: // kTypeDeletion
+			key, rest, err := getLengthPrefixed(buf)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, writeBatchOp{Delete: true, Key: key})
+			buf = rest
+		default:
+			return nil, fmt.Errorf("unknown write batch tag %d", tag)
+		}
+	}
+	return ops, nil
+}
+
+func getLengthPrefixed(buf []byte) (value, rest []byte, err error) {
+	n, consumed := decodeVarint(buf)
+	if consumed == 0 {
+		return nil, nil, fmt.Errorf("truncated varint length")
+	}
+	buf = buf[consumed:]
+	if int(n) > len(buf) {
+		return nil, nil, fmt.Errorf("length-prefixed value overruns record")
+	}
+	return buf[:n], buf[n:], nil
+}
+
+// decodeVarint reads a leveldb-style base-128 varint (least-significant group first), returning
+// the value and the number of bytes consumed (0 on a truncated/overlong encoding).
+func decodeVarint(buf []byte) (uint64, int) {
+	var result uint64
+	for i := 0; i < len(buf) && i < 10; i++ {
+		b := buf[i]
+		result |= uint64(b&0x7f) << (7 * uint(i))
+		if b&0x80 == 0 {
+			return result, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func encodeVarint(v uint64) []byte {
+	var out []byte
+	for v >= 0x80 {
+		out = append(out, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(out, byte(v))
+}
+
+func putLengthPrefixed(dst []byte, v []byte) []byte {
+	dst = append(dst, encodeVarint(uint64(len(v)))...)
+	return append(dst, v...)
+}
+
+// encodeWriteBatch is decodeWriteBatch's inverse, used by appendLogSegment to build the record
+// Apply's synthesized log file carries.
+func encodeWriteBatch(ops []writeBatchOp) []byte {
+	body := make([]byte, 12) // sequence number (0) + count, filled in below.
+	binary.LittleEndian.PutUint32(body[8:], uint32(len(ops)))
+	for _, op := range ops {
+		if op.Delete {
+			body = append(body, 0)
+			body = putLengthPrefixed(body, op.Key)
+		} else {
+			body = append(body, 1)
+			body = putLengthPrefixed(body, op.Key)
+			body = putLengthPrefixed(body, op.Value)
+		}
+	}
+	return body
+}
+
+// writeLogRecord appends record to dst as a sequence of physical log blocks, splitting across
+// logBlockSize boundaries the way leveldb's log_writer.cc does (a record that starts within
+// logHeaderSize bytes of a block's end is deferred to the next block entirely).
+func writeLogRecord(dst []byte, record []byte) []byte {
+	first := true
+	for {
+		leftInBlock := logBlockSize - len(dst)%logBlockSize
+		if leftInBlock < logHeaderSize {
+			dst = append(dst, make([]byte, leftInBlock)...) // pad out the block with zero-type bytes.
+			leftInBlock = logBlockSize
+		}
+		avail := leftInBlock - logHeaderSize
+		n := len(record)
+		fits := n <= avail
+		if !fits {
+			n = avail
+		}
+
+		var recType byte
+		switch {
+		case first && fits:
+			recType = logRecordFull
+		case first:
+			recType = logRecordFirst
+		case fits:
+			recType = logRecordLast
+		default:
+			recType = logRecordMiddle
+		}
+
+		chunk := record[:n]
+		crc := maskCRC(crc32.Update(crc32.Checksum([]byte{recType}, crc32cTable), crc32cTable, chunk))
+		hdr := make([]byte, logHeaderSize)
+		binary.LittleEndian.PutUint32(hdr[0:4], crc)
+		binary.LittleEndian.PutUint16(hdr[4:6], uint16(n))
+		hdr[6] = recType
+		dst = append(dst, hdr...)
+		dst = append(dst, chunk...)
+
+		record = record[n:]
+		first = false
+		if len(record) == 0 {
+			return dst
+		}
+	}
+}
+
+var logFileNameRe = regexp.MustCompile(`^(\d+)\.log$`)
+
+// nextLogFileNumber scans dir for "<number>.log" files and returns one greater than the highest
+// it finds (or 1 if there are none), matching leveldb's file numbering so a freshly written log
+// segment sorts after - and so is recovered after - everything already on disk.
+func nextLogFileNumber(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 1, nil
+		}
+		return 0, err
+	}
+	max := 0
+	for _, e := range entries {
+		m := logFileNameRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err == nil && n > max {
+			max = n
+		}
+	}
+	return max + 1, nil
+}
+
+// appendLogSegment writes ops as a new, correctly-numbered .log file in dir. leveldb's own Open()
+// path discovers stray log files newer than its manifest's recovery point via a directory scan
+// (VersionSet::Recover), so this is picked up and replayed the next time Chromium opens the
+// store - no compaction or manifest rewrite required here.
+func appendLogSegment(dir string, ops []writeBatchOp) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	num, err := nextLogFileNumber(dir)
+	if err != nil {
+		return fmt.Errorf("failed to determine next log file number for %s: %w", dir, err)
+	}
+	var out []byte
+	out = writeLogRecord(out, encodeWriteBatch(ops))
+	path := filepath.Join(dir, fmt.Sprintf("%06d.log", num))
+	return os.WriteFile(path, out, 0o644)
+}
+
+// sortedKeys returns m's keys in a deterministic order, so Diff's output doesn't vary run to run.
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,358 @@
+// Package profilediff computes and applies a minimal patch between two Chromium profile
+// snapshots, instead of round-tripping the whole ~hundreds-of-MB user-data directory the way
+// server/e2e/e2e_persist_login_test.go's downloadUserDataDir/restoreUserDataDir and
+// server/lib/profilesnapshot do today. Diff covers three stores:
+//
+//   - Cookies: parsed from the Cookies sqlite file via server/lib/cookiejar's reader, diffed row
+//     by row.
+//   - IndexedDB: each origin's "<origin>.indexeddb.leveldb" directory (see
+//     storagepartition.IndexedDBDirName) is snapshotted by replaying its LevelDB write-ahead log
+//     files (see leveldblog.go) and diffed key by key.
+//   - Local Storage: the profile's single shared "Local Storage/leveldb" directory, snapshotted
+//     the same way and filtered to keys prefixed with one of the requested origins (see
+//     localStorageKeyPrefix).
+//
+// A significant caveat applies to both LevelDB-backed stores: this package only reads ".log"
+// write-ahead log segments, not compacted ".ldb" SSTable files. LevelDB periodically compacts
+// logs into SSTables as a background maintenance pass, after which their entries are invisible to
+// Diff until the store writes them again. For the short-lived incremental checkpoints this
+// package targets (seconds-to-minutes between Diff calls during an agent run), that's the common
+// case; for a profile whose LevelDB instances have been compacted since the baseline snapshot was
+// taken, Diff will under-report changes rather than erroring, since a partial diff is more useful
+// here than refusing to produce one - callers who need a guaranteed-complete picture should still
+// fall back to a full server/lib/profilesnapshot snapshot.
+package profilediff
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/cookiejar"
+	"github.com/onkernel/kernel-images/server/lib/storagepartition"
+)
+
+// localStorageLevelDBRelPath is where Chromium keeps the profile-wide Local Storage LevelDB
+// instance, relative to the profile directory.
+var localStorageLevelDBRelPath = filepath.Join("Local Storage", "leveldb")
+
+// StoredCookie is one cookie row, independent of net/http.Cookie so Patch's JSON encoding isn't
+// at the mercy of that type's (unexported-field-heavy) default marshaling.
+type StoredCookie struct {
+	Domain   string    `json:"domain"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Value    string    `json:"value"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure"`
+	HTTPOnly bool      `json:"http_only"`
+	SameSite int       `json:"same_site"`
+}
+
+func toStoredCookie(c *http.Cookie) StoredCookie {
+	return StoredCookie{
+		Domain:   c.Domain,
+		Name:     c.Name,
+		Path:     c.Path,
+		Value:    c.Value,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HTTPOnly: c.HttpOnly,
+		SameSite: int(c.SameSite),
+	}
+}
+
+func (c StoredCookie) toHTTPCookie() *http.Cookie {
+	return &http.Cookie{
+		Domain:   c.Domain,
+		Name:     c.Name,
+		Path:     c.Path,
+		Value:    c.Value,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
+		SameSite: http.SameSite(c.SameSite),
+	}
+}
+
+// CookiePatch is Patch's per-cookie section: the rows added, removed, and changed (present in
+// both but with a different value/attributes) between a baseline and current profile.
+type CookiePatch struct {
+	Added   []StoredCookie `json:"added,omitempty"`
+	Removed []StoredCookie `json:"removed,omitempty"`
+	Changed []StoredCookie `json:"changed,omitempty"`
+}
+
+// LevelDBEntry is one changed key in an IndexedDB or Local Storage LevelDB store. Key and Value
+// are hex-encoded, since LevelDB keys/values are arbitrary bytes (IndexedDB in particular encodes
+// database id, object store id and the IndexedDB key itself into the key bytes; this package
+// doesn't decode that internal scheme - see the package doc).
+type LevelDBEntry struct {
+	Origin string `json:"origin"`
+	Key    string `json:"key"`
+	Op     string `json:"op"` // "put" or "delete"
+	Value  string `json:"value,omitempty"`
+}
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// Patch is the JSON envelope Diff produces and Apply consumes.
+type Patch struct {
+	Cookies      CookiePatch    `json:"cookies"`
+	IndexedDB    []LevelDBEntry `json:"indexeddb,omitempty"`
+	LocalStorage []LevelDBEntry `json:"localstorage,omitempty"`
+}
+
+// Diff compares baselineDir and currentDir - two Chromium user-data directories laid out the
+// same way (e.g. one extracted from a server/lib/profilesnapshot snapshot, the other the live
+// profile) - and returns a Patch covering only what changed, scoped to origins.
+func Diff(baselineDir, currentDir string, origins []string) (*Patch, error) {
+	patch := &Patch{}
+
+	cookiePatch, err := diffCookies(baselineDir, currentDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff cookies: %w", err)
+	}
+	patch.Cookies = cookiePatch
+
+	for _, origin := range origins {
+		dirName, err := storagepartition.IndexedDBDirName(origin)
+		if err != nil {
+			continue // an unparseable origin shouldn't block diffing the rest.
+		}
+		entries, err := diffLevelDBDir(
+			filepath.Join(baselineDir, storagepartition.DefaultProfileDir, "IndexedDB", dirName),
+			filepath.Join(currentDir, storagepartition.DefaultProfileDir, "IndexedDB", dirName),
+			origin,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff indexeddb for %s: %w", origin, err)
+		}
+		patch.IndexedDB = append(patch.IndexedDB, entries...)
+	}
+
+	lsEntries, err := diffLevelDBDir(
+		filepath.Join(baselineDir, storagepartition.DefaultProfileDir, localStorageLevelDBRelPath),
+		filepath.Join(currentDir, storagepartition.DefaultProfileDir, localStorageLevelDBRelPath),
+		"",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff localstorage: %w", err)
+	}
+	patch.LocalStorage = filterLocalStorageByOrigin(lsEntries, origins)
+
+	return patch, nil
+}
+
+func diffCookies(baselineDir, currentDir string) (CookiePatch, error) {
+	baseline, err := readCookiesFile(filepath.Join(baselineDir, storagepartition.DefaultProfileDir, "Cookies"))
+	if err != nil {
+		return CookiePatch{}, err
+	}
+	current, err := readCookiesFile(filepath.Join(currentDir, storagepartition.DefaultProfileDir, "Cookies"))
+	if err != nil {
+		return CookiePatch{}, err
+	}
+
+	type key struct{ domain, name, path string }
+	baseMap := make(map[key]*http.Cookie, len(baseline))
+	for _, c := range baseline {
+		baseMap[key{c.Domain, c.Name, c.Path}] = c
+	}
+
+	var patch CookiePatch
+	seen := make(map[key]bool, len(current))
+	for _, c := range current {
+		k := key{c.Domain, c.Name, c.Path}
+		seen[k] = true
+		old, existed := baseMap[k]
+		switch {
+		case !existed:
+			patch.Added = append(patch.Added, toStoredCookie(c))
+		case !cookiesEqual(old, c):
+			patch.Changed = append(patch.Changed, toStoredCookie(c))
+		}
+	}
+	for _, c := range baseline {
+		if !seen[key{c.Domain, c.Name, c.Path}] {
+			patch.Removed = append(patch.Removed, toStoredCookie(c))
+		}
+	}
+	return patch, nil
+}
+
+// cookiesEqual compares the fields Diff cares about (not http.Cookie's Raw/Unparsed, which
+// cookiejar.DecodeCookiesDB never populates in the first place).
+func cookiesEqual(a, b *http.Cookie) bool {
+	return a.Domain == b.Domain && a.Name == b.Name && a.Path == b.Path && a.Value == b.Value &&
+		a.Expires.Equal(b.Expires) && a.Secure == b.Secure && a.HttpOnly == b.HttpOnly && a.SameSite == b.SameSite
+}
+
+func readCookiesFile(path string) ([]*http.Cookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	cookies, _, err := cookiejar.DecodeCookiesDB(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cookies, nil
+}
+
+// diffLevelDBDir snapshots two LevelDB directories (see snapshotLevelDBDir) and reports every key
+// added, removed, or changed between them. origin is attached to every entry produced (Local
+// Storage diffs pass "" here and let filterLocalStorageByOrigin fill it in per entry instead).
+func diffLevelDBDir(baselineDir, currentDir, origin string) ([]LevelDBEntry, error) {
+	baseline, err := snapshotLevelDBDir(baselineDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", baselineDir, err)
+	}
+	current, err := snapshotLevelDBDir(currentDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", currentDir, err)
+	}
+
+	var entries []LevelDBEntry
+	for _, k := range sortedKeys(current) {
+		v := current[k]
+		old, existed := baseline[k]
+		if existed && string(old) == string(v) {
+			continue
+		}
+		entries = append(entries, LevelDBEntry{
+			Origin: origin,
+			Key:    hex.EncodeToString([]byte(k)),
+			Op:     opPut,
+			Value:  hex.EncodeToString(v),
+		})
+	}
+	for _, k := range sortedKeys(baseline) {
+		if _, stillThere := current[k]; stillThere {
+			continue
+		}
+		entries = append(entries, LevelDBEntry{Origin: origin, Key: hex.EncodeToString([]byte(k)), Op: opDelete})
+	}
+	return entries, nil
+}
+
+// localStorageKeyPrefix returns the byte prefix Chromium's dom_storage_database.cc gives every
+// Local Storage key belonging to origin: "_" + origin + NUL. Keys that don't start with one of
+// origins' prefixes (e.g. the store's own "META:" bookkeeping keys) are dropped by
+// filterLocalStorageByOrigin.
+func localStorageKeyPrefix(origin string) string {
+	return "_" + origin + "\x00"
+}
+
+func filterLocalStorageByOrigin(entries []LevelDBEntry, origins []string) []LevelDBEntry {
+	var filtered []LevelDBEntry
+	for _, e := range entries {
+		rawKey, err := hex.DecodeString(e.Key)
+		if err != nil {
+			continue
+		}
+		for _, origin := range origins {
+			if strings.HasPrefix(string(rawKey), localStorageKeyPrefix(origin)) {
+				e.Origin = origin
+				filtered = append(filtered, e)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// Apply merges patch into the Chromium profile rooted at profileDir (a live user-data directory,
+// e.g. server/cmd/api/api's userDataDir - not a baseline snapshot). Cookies are merged directly
+// into the Cookies sqlite file; IndexedDB and Local Storage changes are written as a new LevelDB
+// log segment per store (see appendLogSegment) rather than rewritten SSTables, so Apply never
+// needs to parse or rebuild the compacted portion of either store.
+func Apply(profileDir string, patch *Patch) error {
+	if err := applyCookies(profileDir, patch.Cookies); err != nil {
+		return fmt.Errorf("failed to apply cookie changes: %w", err)
+	}
+
+	indexedDBByOrigin := make(map[string][]LevelDBEntry)
+	for _, e := range patch.IndexedDB {
+		indexedDBByOrigin[e.Origin] = append(indexedDBByOrigin[e.Origin], e)
+	}
+	for origin, entries := range indexedDBByOrigin {
+		dirName, err := storagepartition.IndexedDBDirName(origin)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(profileDir, storagepartition.DefaultProfileDir, "IndexedDB", dirName)
+		if err := appendEntriesAsLogSegment(dir, entries); err != nil {
+			return fmt.Errorf("failed to apply indexeddb changes for %s: %w", origin, err)
+		}
+	}
+
+	lsDir := filepath.Join(profileDir, storagepartition.DefaultProfileDir, localStorageLevelDBRelPath)
+	if err := appendEntriesAsLogSegment(lsDir, patch.LocalStorage); err != nil {
+		return fmt.Errorf("failed to apply localstorage changes: %w", err)
+	}
+
+	return nil
+}
+
+func applyCookies(profileDir string, patch CookiePatch) error {
+	if len(patch.Added) == 0 && len(patch.Removed) == 0 && len(patch.Changed) == 0 {
+		return nil
+	}
+	cookiesPath := filepath.Join(profileDir, storagepartition.DefaultProfileDir, "Cookies")
+	data, err := os.ReadFile(cookiesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", cookiesPath, err)
+	}
+
+	upserts := make([]*http.Cookie, 0, len(patch.Added)+len(patch.Changed))
+	for _, c := range patch.Added {
+		upserts = append(upserts, c.toHTTPCookie())
+	}
+	for _, c := range patch.Changed {
+		upserts = append(upserts, c.toHTTPCookie())
+	}
+	removed := make([]*http.Cookie, 0, len(patch.Removed))
+	for _, c := range patch.Removed {
+		removed = append(removed, c.toHTTPCookie())
+	}
+
+	merged, err := cookiejar.MergeCookiesIntoDB(data, upserts, removed)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cookiesPath, merged, 0o644)
+}
+
+func appendEntriesAsLogSegment(dir string, entries []LevelDBEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ops := make([]writeBatchOp, 0, len(entries))
+	for _, e := range entries {
+		key, err := hex.DecodeString(e.Key)
+		if err != nil {
+			return fmt.Errorf("invalid key %q: %w", e.Key, err)
+		}
+		if e.Op == opDelete {
+			ops = append(ops, writeBatchOp{Delete: true, Key: key})
+			continue
+		}
+		value, err := hex.DecodeString(e.Value)
+		if err != nil {
+			return fmt.Errorf("invalid value for key %q: %w", e.Key, err)
+		}
+		ops = append(ops, writeBatchOp{Key: key, Value: value})
+	}
+	return appendLogSegment(dir, ops)
+}
@@ -0,0 +1,27 @@
+package profilesnapshot
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// LoadSigningKeyFromEnv builds the ed25519 signer Create signs manifests with, from
+// PROFILE_SNAPSHOT_SIGNING_KEY (a base64-encoded 64-byte ed25519 private key). Returns (nil, nil)
+// when unset, meaning profile snapshotting is disabled until a key is configured - the same
+// "off until configured" convention as exttrust.LoadPolicyFromEnv/vfs.LoadMountsFromEnv.
+func LoadSigningKeyFromEnv() (ed25519.PrivateKey, error) {
+	raw := os.Getenv("PROFILE_SNAPSHOT_SIGNING_KEY")
+	if raw == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROFILE_SNAPSHOT_SIGNING_KEY: %w", err)
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("PROFILE_SNAPSHOT_SIGNING_KEY must decode to %d bytes, got %d", ed25519.PrivateKeySize, len(key))
+	}
+	return ed25519.PrivateKey(key), nil
+}
@@ -0,0 +1,373 @@
+// Package profilesnapshot archives and restores an entire Chromium user-data profile - unlike
+// server/lib/storagepartition, which scopes to specific origins - as a single encrypted,
+// integrity-checked archive. A snapshot carries live session cookies and other sensitive profile
+// state, so it's never written or transmitted in the clear: the whole archive is sealed with
+// XChaCha20-Poly1305 under a caller-supplied key (see streamcrypt.go), and a signed Manifest
+// lists every file's SHA-256, size, and the Chromium build ID that produced it, so Restore can
+// refuse to unpack a profile built by an incompatible Chromium version - IndexedDB's LevelDB
+// format and the Cookies SQLite schema both break across major Chromium versions.
+package profilesnapshot
+
+import (
+	"archive/tar"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// manifestEntryName/signatureEntryName are the first two entries Create writes, in that fixed
+// order, so Restore can read and verify them before it extracts a single profile file.
+const (
+	manifestEntryName  = "manifest.json"
+	signatureEntryName = "manifest.sig"
+	profileEntryPrefix = "profile/"
+)
+
+// skipDirNames are profile subdirectories IncludeCaches=false omits: pure caches Chromium
+// repopulates on its own, which can dwarf the rest of a profile without carrying anything a
+// restore actually needs.
+var skipDirNames = map[string]bool{
+	"Cache":      true,
+	"Code Cache": true,
+	"GPUCache":   true,
+}
+
+// ManifestFile is one file's entry in a Manifest.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// Manifest describes a snapshot's contents: every file it carries plus the Chromium build ID
+// that produced them, signed by SnapshotOptions.ManifestSigner so Restore can trust it.
+type Manifest struct {
+	BuildID   string         `json:"build_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []ManifestFile `json:"files"`
+}
+
+// SnapshotOptions configures Create.
+type SnapshotOptions struct {
+	// EncryptionKey is the 32-byte XChaCha20-Poly1305 key the archive is sealed under.
+	EncryptionKey []byte
+	// ManifestSigner signs the manifest (an ed25519.PrivateKey or *rsa.PrivateKey); its
+	// Public() must be what Restore is given as RestoreOptions.VerifyKey.
+	ManifestSigner crypto.Signer
+	// IncludeCaches, if false (the default), omits skipDirNames from the archive.
+	IncludeCaches bool
+}
+
+// RestoreOptions configures Restore.
+type RestoreOptions struct {
+	// DecryptionKey must match the EncryptionKey Create was called with.
+	DecryptionKey []byte
+	// VerifyKey must be the public half of the crypto.Signer Create signed the manifest with.
+	VerifyKey crypto.PublicKey
+	// Force skips the running Chromium build ID check, for restoring a profile onto a
+	// deliberately different build (e.g. a controlled migration).
+	Force bool
+}
+
+// BuildIDMismatchError is returned by Restore when a snapshot's manifest names a Chromium build
+// ID different from the one currently running, and RestoreOptions.Force wasn't set.
+type BuildIDMismatchError struct {
+	Manifest string
+	Running  string
+}
+
+func (e *BuildIDMismatchError) Error() string {
+	return fmt.Sprintf("snapshot was produced by chromium build %q, running build is %q (pass Force to restore anyway)", e.Manifest, e.Running)
+}
+
+// Create walks profileDir, builds a signed Manifest, and writes the profile - encrypted, as a
+// zstd-compressed tar wrapped in XChaCha20-Poly1305 chunks - to w.
+func Create(profileDir string, opts SnapshotOptions, w io.Writer) error {
+	if len(opts.EncryptionKey) != chacha20poly1305.KeySize {
+		return fmt.Errorf("encryption key must be %d bytes", chacha20poly1305.KeySize)
+	}
+	if opts.ManifestSigner == nil {
+		return fmt.Errorf("manifest signer is required")
+	}
+
+	files, err := collectFiles(profileDir, opts.IncludeCaches)
+	if err != nil {
+		return fmt.Errorf("failed to walk profile dir: %w", err)
+	}
+
+	manifest := Manifest{BuildID: ChromiumBuildID(), CreatedAt: time.Now(), Files: make([]ManifestFile, 0, len(files))}
+	for _, rel := range files {
+		sum, size, err := hashFile(filepath.Join(profileDir, rel))
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", rel, err)
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Path: rel, SHA256: sum, Size: size})
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	signature, err := signManifest(opts.ManifestSigner, manifestJSON)
+	if err != nil {
+		return fmt.Errorf("failed to sign manifest: %w", err)
+	}
+
+	ew, err := newEncryptWriter(w, opts.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	zw, err := zstd.NewWriter(ew)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd writer: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	if err := writeTarEntry(tw, manifestEntryName, manifestJSON); err != nil {
+		return fmt.Errorf("failed to write manifest entry: %w", err)
+	}
+	if err := writeTarEntry(tw, signatureEntryName, signature); err != nil {
+		return fmt.Errorf("failed to write signature entry: %w", err)
+	}
+	for _, rel := range files {
+		if err := addProfileFile(tw, profileDir, rel); err != nil {
+			return fmt.Errorf("failed to add %s: %w", rel, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize tar: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize zstd stream: %w", err)
+	}
+	return ew.Close()
+}
+
+// Restore verifies a snapshot produced by Create against opts and extracts it into profileDir,
+// decrypting and checking every file's SHA-256 as it streams in rather than buffering the whole
+// archive first.
+func Restore(profileDir string, opts RestoreOptions, r io.Reader) error {
+	if len(opts.DecryptionKey) != chacha20poly1305.KeySize {
+		return fmt.Errorf("decryption key must be %d bytes", chacha20poly1305.KeySize)
+	}
+	if opts.VerifyKey == nil {
+		return fmt.Errorf("verify key is required")
+	}
+
+	dr, err := newDecryptReader(r, opts.DecryptionKey)
+	if err != nil {
+		return err
+	}
+	zr, err := zstd.NewReader(dr)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd reader: %w", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	manifestJSON, err := readExpectedTarEntry(tr, manifestEntryName)
+	if err != nil {
+		return err
+	}
+	signature, err := readExpectedTarEntry(tr, signatureEntryName)
+	if err != nil {
+		return err
+	}
+	if !verifyManifestSignature(opts.VerifyKey, manifestJSON, signature) {
+		return fmt.Errorf("manifest signature verification failed")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if !opts.Force {
+		if running := ChromiumBuildID(); running != manifest.BuildID {
+			return &BuildIDMismatchError{Manifest: manifest.BuildID, Running: running}
+		}
+	}
+
+	byPath := make(map[string]ManifestFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		byPath[f.Path] = f
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		rel := strings.TrimPrefix(hdr.Name, profileEntryPrefix)
+		expected, ok := byPath[rel]
+		if !ok {
+			return fmt.Errorf("archive entry %q not listed in manifest", hdr.Name)
+		}
+		if err := restoreFile(tr, filepath.Join(profileDir, rel), expected); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+	}
+}
+
+// collectFiles returns every regular file under profileDir, as slash-separated paths relative to
+// it, skipping skipDirNames entirely unless includeCaches is set.
+func collectFiles(profileDir string, includeCaches bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(profileDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !includeCaches && skipDirNames[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(profileDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(path string) (sum string, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(data)), Mode: 0o644}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func addProfileFile(tw *tar.Writer, profileDir, rel string) error {
+	f, err := os.Open(filepath.Join(profileDir, rel))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = profileEntryPrefix + rel
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func readExpectedTarEntry(tr *tar.Reader, name string) ([]byte, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if hdr.Name != name {
+		return nil, fmt.Errorf("expected archive entry %q, got %q", name, hdr.Name)
+	}
+	return io.ReadAll(tr)
+}
+
+// restoreFile writes r to destPath while hashing it, and fails if the result doesn't match
+// expected.SHA256 - the per-file counterpart to the manifest signature check, since the
+// signature only proves the manifest itself wasn't tampered with, not that a given archive entry
+// actually matches what the manifest claims about it.
+func restoreFile(r io.Reader, destPath string, expected ManifestFile) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != expected.SHA256 {
+		return fmt.Errorf("sha256 mismatch: manifest says %s, got %s", expected.SHA256, sum)
+	}
+	return nil
+}
+
+// signManifest signs manifestJSON with signer, following the same ed25519-signs-raw-message/
+// RSA-signs-a-SHA-256-digest split as server/lib/exttrust's signature verification.
+func signManifest(signer crypto.Signer, manifestJSON []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, manifestJSON, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(manifestJSON)
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+func verifyManifestSignature(pub crypto.PublicKey, manifestJSON, signature []byte) bool {
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, manifestJSON, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(manifestJSON)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
+
+// chromiumVersionRe pulls a dotted version number out of `chromium --version`'s output (e.g.
+// "Chromium 120.0.6099.109" -> "120.0.6099.109").
+var chromiumVersionRe = regexp.MustCompile(`[0-9]+(\.[0-9]+){2,3}`)
+
+// ChromiumBuildID returns the version chromium --version reports, or "" if it can't be
+// determined. It's a best-effort stand-in for a dedicated kernel-browser build identifier, which
+// doesn't exist yet - see the package doc for why Restore treats a mismatch (including an
+// undetectable "") as unsafe to proceed with unless RestoreOptions.Force is set. Exported so
+// other callers needing "what Chromium build is this" (e.g. crashreport.Metadata) don't have to
+// shell out to `chromium --version` a second time.
+func ChromiumBuildID() string {
+	out, err := exec.Command("chromium", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return chromiumVersionRe.FindString(string(out))
+}
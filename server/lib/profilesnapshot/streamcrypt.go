@@ -0,0 +1,126 @@
+package profilesnapshot
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// streamChunkSize is how much plaintext each sealed chunk carries. Chunking keeps Create/Restore
+// from ever having to buffer a whole profile (which can be hundreds of megabytes once IndexedDB
+// is involved) in memory just to encrypt or decrypt it.
+const streamChunkSize = 64 * 1024
+
+// encryptWriter buffers writes into streamChunkSize plaintext chunks and seals each
+// independently with XChaCha20-Poly1305 under a fresh random nonce. Each chunk is framed on the
+// wire as [24-byte nonce][4-byte big-endian ciphertext length][ciphertext]; there's no trailing
+// marker; the stream simply ends at the last chunk, which decryptReader's caller observes as
+// io.EOF cleanly on a chunk boundary.
+type encryptWriter struct {
+	aead cipher.AEAD
+	w    io.Writer
+	buf  []byte
+}
+
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize AEAD: %w", err)
+	}
+	return &encryptWriter{aead: aead, w: w}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	e.buf = append(e.buf, p...)
+	for len(e.buf) >= streamChunkSize {
+		if err := e.sealChunk(e.buf[:streamChunkSize]); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[streamChunkSize:]
+	}
+	return n, nil
+}
+
+// Close seals whatever's left in the buffer as a final, possibly short, chunk. It does not close
+// the underlying writer, matching the convention of zstd.Writer/tar.Writer that wrap w here.
+func (e *encryptWriter) Close() error {
+	if len(e.buf) == 0 {
+		return nil
+	}
+	err := e.sealChunk(e.buf)
+	e.buf = nil
+	return err
+}
+
+func (e *encryptWriter) sealChunk(chunk []byte) error {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	ciphertext := e.aead.Seal(nil, nonce, chunk, nil)
+
+	if _, err := e.w.Write(nonce); err != nil {
+		return err
+	}
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(ciphertext)))
+	if _, err := e.w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(ciphertext)
+	return err
+}
+
+// decryptReader is encryptWriter's counterpart: it reads framed chunks from r, opens each, and
+// serves the decrypted bytes back to back through Read.
+type decryptReader struct {
+	aead cipher.AEAD
+	r    io.Reader
+	buf  []byte
+}
+
+func newDecryptReader(r io.Reader, key []byte) (*decryptReader, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize AEAD: %w", err)
+	}
+	return &decryptReader{aead: aead, r: r}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		chunk, err := d.readChunk()
+		if err != nil {
+			return 0, err
+		}
+		d.buf = chunk
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptReader) readChunk() ([]byte, error) {
+	nonce := make([]byte, d.aead.NonceSize())
+	if _, err := io.ReadFull(d.r, nonce); err != nil {
+		return nil, err // io.EOF here means the stream ended cleanly on a chunk boundary.
+	}
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(d.r, lenBuf[:]); err != nil {
+		return nil, fmt.Errorf("truncated chunk length: %w", err)
+	}
+	ciphertext := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+	if _, err := io.ReadFull(d.r, ciphertext); err != nil {
+		return nil, fmt.Errorf("truncated chunk ciphertext: %w", err)
+	}
+	plaintext, err := d.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("chunk authentication failed: %w", err)
+	}
+	return plaintext, nil
+}
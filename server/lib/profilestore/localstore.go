@@ -0,0 +1,113 @@
+package profilestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalChunkStore is a ChunkStore backed by a local directory: chunk <hash> is stored at
+// dir/<hash[:2]>/<hash>, the same two-level fan-out git uses for loose objects, so a snapshot
+// with hundreds of thousands of chunks doesn't put them all in one directory.
+type LocalChunkStore struct {
+	dir string
+}
+
+func NewLocalChunkStore(dir string) *LocalChunkStore {
+	return &LocalChunkStore{dir: dir}
+}
+
+func (s *LocalChunkStore) chunkPath(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+func (s *LocalChunkStore) Has(hash string) (bool, error) {
+	_, err := os.Stat(s.chunkPath(hash))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (s *LocalChunkStore) Put(hash string, data []byte) error {
+	path := s.chunkPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *LocalChunkStore) Get(hash string) ([]byte, error) {
+	return os.ReadFile(s.chunkPath(hash))
+}
+
+// LocalMetaStore is a MetaStore backed by a local directory: each snapshot is one
+// "<id>.json"-named file containing its full Snapshot record.
+type LocalMetaStore struct {
+	dir string
+}
+
+func NewLocalMetaStore(dir string) *LocalMetaStore {
+	return &LocalMetaStore{dir: dir}
+}
+
+func (m *LocalMetaStore) snapshotPath(id string) string {
+	return filepath.Join(m.dir, id+".json")
+}
+
+func (m *LocalMetaStore) SaveSnapshot(snap *Snapshot) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	return os.WriteFile(m.snapshotPath(snap.ID), data, 0o644)
+}
+
+func (m *LocalMetaStore) LoadSnapshot(id string) (*Snapshot, error) {
+	data, err := os.ReadFile(m.snapshotPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot %s: %w", id, err)
+	}
+	return &snap, nil
+}
+
+func (m *LocalMetaStore) ListSnapshots() ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var infos []SnapshotInfo
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		snap, err := m.LoadSnapshot(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load snapshot %s: %w", id, err)
+		}
+		infos = append(infos, SnapshotInfo{
+			ID:        snap.ID,
+			ParentID:  snap.ParentID,
+			CreatedAt: snap.CreatedAt,
+			NumFiles:  len(snap.Files),
+		})
+	}
+	return infos, nil
+}
@@ -0,0 +1,334 @@
+// Package profilestore is an incremental, content-addressed alternative to
+// server/lib/profilesnapshot's single-archive snapshots: a profile tree is split into
+// fixed-size chunks hashed by SHA-256, and a snapshot only uploads chunks its ChunkStore backend
+// doesn't already have. For a Chromium user-data dir - gigabytes in size, dominated by
+// mostly-unchanged Cache/IndexedDB files between runs - this means every snapshot after the
+// first is close to free when little has changed, unlike re-uploading (or re-encrypting) the
+// whole tree each time the way profilesnapshot.Create does.
+//
+// Chunking here is fixed-size (chunkSize), not content-defined (no rolling hash / Rabin
+// fingerprinting the way restic/kopia split files): a single byte inserted near the start of a
+// large file shifts every chunk boundary after it, so insert/delete edits dedup worse than a
+// real CDC splitter would. For the files that dominate a Chromium profile's size - IndexedDB
+// LevelDB SSTables and disk cache entries, both written as whole new files/blocks rather than
+// edited in place - that's an acceptable tradeoff for the much simpler implementation; a
+// content-defined chunker would be a good follow-up if truncate/append-only editing stops being
+// representative of most of the profile's size.
+package profilestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/onkernel/kernel-images/server/lib/storagepartition"
+)
+
+// chunkSize is the fixed size (except for a file's final, possibly short, chunk) every file is
+// split into before hashing.
+const chunkSize = 4 * 1024 * 1024
+
+// ChunkStore is the pluggable backend content-addressed chunk bytes are read from and written
+// to. Implementations: LocalChunkStore (a local directory) and S3ChunkStore (an S3-compatible
+// bucket, e.g. MinIO).
+type ChunkStore interface {
+	// Has reports whether hash is already stored, so SnapshotProfile can skip re-uploading it.
+	Has(hash string) (bool, error)
+	Put(hash string, data []byte) error
+	Get(hash string) ([]byte, error)
+}
+
+// MetaStore persists Snapshot records (manifests plus parent/timestamp bookkeeping), separately
+// from ChunkStore's content-addressed blobs.
+type MetaStore interface {
+	SaveSnapshot(snap *Snapshot) error
+	LoadSnapshot(id string) (*Snapshot, error)
+	ListSnapshots() ([]SnapshotInfo, error)
+}
+
+// FileManifest is one file's entry in a Snapshot: its path relative to the profile root, mode,
+// and the ordered list of chunk hashes that reconstruct it.
+type FileManifest struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Size   int64       `json:"size"`
+	Chunks []string    `json:"chunks"`
+}
+
+// Snapshot is a full record of one profile tree: every file's manifest, plus which snapshot (if
+// any) it was taken relative to.
+type Snapshot struct {
+	ID        string         `json:"id"`
+	ParentID  string         `json:"parent_id,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []FileManifest `json:"files"`
+}
+
+// SnapshotInfo is ListSnapshots' lightweight summary - a Snapshot without its (potentially very
+// long) file list.
+type SnapshotInfo struct {
+	ID        string    `json:"id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	NumFiles  int       `json:"num_files"`
+}
+
+// skipDirNames mirrors extsnapshot's/storagepartition's convention of giving callers a cheap way
+// to exclude genuinely-regenerable state (disk cache) from a snapshot's size, not just its
+// upload cost.
+var skipDirNames = map[string]bool{
+	"Cache":         true,
+	"Code Cache":    true,
+	"GPUCache":      true,
+	"ShaderCache":   true,
+	"GrShaderCache": true,
+}
+
+// SnapshotOptions configures SnapshotProfile.
+type SnapshotOptions struct {
+	// Parent is the snapshot ID this one is taken relative to, for ParentID bookkeeping only -
+	// SnapshotProfile always walks the full current tree; dedup against the parent's chunks (or
+	// any other snapshot's) comes from ChunkStore.Has, not from diffing against Parent's manifest.
+	Parent string
+	// IncludeCaches, if false (the default), omits skipDirNames from the snapshot.
+	IncludeCaches bool
+}
+
+// SnapshotProfile walks profileDir, uploads every chunk store doesn't already have, and saves a
+// new Snapshot to meta. It returns the new snapshot's ID (a random UUID-shaped identifier isn't
+// needed since the snapshot's own manifest hash makes a stable, content-addressed ID).
+func SnapshotProfile(store ChunkStore, meta MetaStore, profileDir string, opts SnapshotOptions, now time.Time) (string, error) {
+	files, err := collectFiles(profileDir, opts.IncludeCaches)
+	if err != nil {
+		return "", fmt.Errorf("failed to walk profile: %w", err)
+	}
+
+	manifests := make([]FileManifest, 0, len(files))
+	for _, rel := range files {
+		fm, err := chunkAndUploadFile(store, profileDir, rel)
+		if err != nil {
+			return "", fmt.Errorf("failed to snapshot %s: %w", rel, err)
+		}
+		manifests = append(manifests, fm)
+	}
+
+	snap := &Snapshot{ParentID: opts.Parent, CreatedAt: now, Files: manifests}
+	snap.ID = manifestID(snap)
+
+	if err := meta.SaveSnapshot(snap); err != nil {
+		return "", fmt.Errorf("failed to save snapshot manifest: %w", err)
+	}
+	return snap.ID, nil
+}
+
+// manifestID derives a stable, content-addressed snapshot ID from the hash of its sorted file
+// manifests, so snapshotting an unchanged tree twice (same parent, same files) yields the same
+// ID rather than a fresh random one.
+func manifestID(snap *Snapshot) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "parent:%s\n", snap.ParentID)
+	for _, fm := range snap.Files {
+		fmt.Fprintf(h, "%s %o %d %v\n", fm.Path, fm.Mode, fm.Size, fm.Chunks)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func chunkAndUploadFile(store ChunkStore, profileDir, rel string) (FileManifest, error) {
+	fullPath := filepath.Join(profileDir, rel)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return FileManifest{}, err
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return FileManifest{}, err
+	}
+	defer f.Close()
+
+	fm := FileManifest{Path: rel, Mode: info.Mode(), Size: info.Size()}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			hash, uploadErr := uploadChunk(store, buf[:n])
+			if uploadErr != nil {
+				return FileManifest{}, uploadErr
+			}
+			fm.Chunks = append(fm.Chunks, hash)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return FileManifest{}, err
+		}
+	}
+	return fm, nil
+}
+
+func uploadChunk(store ChunkStore, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	exists, err := store.Has(hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to check chunk %s: %w", hash, err)
+	}
+	if exists {
+		return hash, nil
+	}
+	if err := store.Put(hash, data); err != nil {
+		return "", fmt.Errorf("failed to upload chunk %s: %w", hash, err)
+	}
+	return hash, nil
+}
+
+// ListSnapshots returns every snapshot meta has recorded, most recent first.
+func ListSnapshots(meta MetaStore) ([]SnapshotInfo, error) {
+	infos, err := meta.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.After(infos[j].CreatedAt) })
+	return infos, nil
+}
+
+// RestoreProfile reconstructs the snapshot id names into profileDir: every file the snapshot
+// lists is rewritten from its chunks, and any file already in profileDir that the snapshot
+// doesn't mention is removed (a prune pass, so a restore to an older snapshot doesn't leave
+// newer files behind). Chromium's singleton lock files are cleared afterward the same way
+// profilesnapshot.Restore's caller does, since a restore is meaningless while the old process
+// still holds them; restarting Chromium itself is left to the caller (see
+// server/cmd/api/api/profile_snapshot.go's RestoreProfileSnapshot for that coordination).
+func RestoreProfile(store ChunkStore, meta MetaStore, id, profileDir string) error {
+	snap, err := meta.LoadSnapshot(id)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot %s: %w", id, err)
+	}
+
+	wanted := make(map[string]bool, len(snap.Files))
+	for _, fm := range snap.Files {
+		wanted[fm.Path] = true
+		if err := restoreFile(store, profileDir, fm); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", fm.Path, err)
+		}
+	}
+
+	if err := pruneUnlisted(profileDir, wanted); err != nil {
+		return fmt.Errorf("failed to prune stale files: %w", err)
+	}
+
+	return storagepartition.ClearSingletonLocks(storagepartition.Sources{UserDataDir: profileDir})
+}
+
+func restoreFile(store ChunkStore, profileDir string, fm FileManifest) error {
+	fullPath := filepath.Join(profileDir, fm.Path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fm.Mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, hash := range fm.Chunks {
+		data, err := store.Get(hash)
+		if err != nil {
+			return fmt.Errorf("failed to fetch chunk %s: %w", hash, err)
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneUnlisted removes every regular file under profileDir whose path (relative to profileDir)
+// isn't in wanted, then removes any directory left empty by that.
+func pruneUnlisted(profileDir string, wanted map[string]bool) error {
+	var toRemove []string
+	err := filepath.Walk(profileDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(profileDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !wanted[rel] {
+			toRemove = append(toRemove, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range toRemove {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return removeEmptyDirs(profileDir)
+}
+
+func removeEmptyDirs(root string) error {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Remove deepest-first so a directory that's only empty once its (now-empty) subdirectories
+	// are gone gets cleaned up too.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		_ = os.Remove(dir) // fails (harmlessly) with ENOTEMPTY for any directory still in use.
+	}
+	return nil
+}
+
+// collectFiles returns every regular file under profileDir, as slash-separated paths relative to
+// it, skipping skipDirNames entirely unless includeCaches is set. This mirrors
+// profilesnapshot.collectFiles; it's not shared between the two packages since each ties it to a
+// different per-file manifest type.
+func collectFiles(profileDir string, includeCaches bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(profileDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !includeCaches && skipDirNames[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(profileDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return files, nil
+}
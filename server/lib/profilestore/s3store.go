@@ -0,0 +1,67 @@
+package profilestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3ChunkStore is a ChunkStore backed by an S3-compatible bucket (e.g. MinIO), keying each chunk
+// by "<prefix><hash>" the same way recorder.S3UploadSink keys recordings.
+type S3ChunkStore struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+}
+
+func NewS3ChunkStore(client *s3.Client, bucket, prefix string) *S3ChunkStore {
+	return &S3ChunkStore{Client: client, Bucket: bucket, Prefix: prefix}
+}
+
+func (s *S3ChunkStore) key(hash string) string {
+	return s.Prefix + hash
+}
+
+func (s *S3ChunkStore) Has(hash string) (bool, error) {
+	_, err := s.Client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(hash)),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to head chunk %s: %w", hash, err)
+	}
+	return true, nil
+}
+
+func (s *S3ChunkStore) Put(hash string, data []byte) error {
+	if _, err := s.Client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(hash)),
+		Body:   bytes.NewReader(data),
+	}); err != nil {
+		return fmt.Errorf("failed to put chunk %s: %w", hash, err)
+	}
+	return nil
+}
+
+func (s *S3ChunkStore) Get(hash string) ([]byte, error) {
+	out, err := s.Client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(hash)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk %s: %w", hash, err)
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
@@ -0,0 +1,97 @@
+package proofsession
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// sessionsBucket holds one JSON-encoded Session per key, keyed by its ID string.
+var sessionsBucket = []byte("proof_sessions")
+
+// BoltStore is a Store backed by a single embedded BoltDB file, so proof sessions survive a
+// server restart without standing up an external database.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating if needed) the BoltDB file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proof session db: %w", err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create proof session bucket: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Create(ctx context.Context, sess *Session) error {
+	return s.put(sess)
+}
+
+func (s *BoltStore) Update(ctx context.Context, sess *Session) error {
+	return s.put(sess)
+}
+
+func (s *BoltStore) put(sess *Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("failed to marshal proof session: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(sess.ID.String()), data)
+	})
+}
+
+func (s *BoltStore) Get(ctx context.Context, id uuid.UUID) (*Session, error) {
+	var sess Session
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(id.String()))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &sess)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proof session: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &sess, nil
+}
+
+func (s *BoltStore) CountActiveBySubject(ctx context.Context, subject string) (int, error) {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(_, data []byte) error {
+			var sess Session
+			if err := json.Unmarshal(data, &sess); err != nil {
+				return err
+			}
+			if sess.Subject == subject && sess.Active() {
+				count++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active proof sessions: %w", err)
+	}
+	return count, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,63 @@
+package proofsession
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-memory Store, useful for tests and single-process dev runs where a proof
+// session doesn't need to survive a restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]*Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[uuid.UUID]*Session)}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, sess *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *sess
+	s.sessions[sess.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id uuid.UUID) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *sess
+	return &cp, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, sess *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[sess.ID]; !ok {
+		return ErrNotFound
+	}
+	cp := *sess
+	s.sessions[sess.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) CountActiveBySubject(ctx context.Context, subject string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	for _, sess := range s.sessions {
+		if sess.Subject == subject && sess.Active() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
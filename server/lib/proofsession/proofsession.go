@@ -0,0 +1,88 @@
+// Package proofsession persists the state of an asynchronous reclaim-protocol proof run, so the
+// ReclaimProve endpoint (server/cmd/api/api/reclaim.go) can hand back a session ID immediately and
+// let a caller poll or receive a webhook once the TEE+MPC protocol finishes, instead of holding
+// the HTTP request open for as long as the proof takes.
+package proofsession
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	oapi "github.com/onkernel/kernel-images/server/lib/oapi"
+)
+
+// ErrNotFound is returned by Store.Get when no session exists for the given ID.
+var ErrNotFound = errors.New("proof session not found")
+
+// Status is the lifecycle state of a proof session.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusTimeout   Status = "timeout"
+)
+
+// Phase tracks where a running session is within the reclaim protocol, independent of Status.
+type Phase string
+
+const (
+	PhaseTEEHandshake Phase = "tee_handshake"
+	PhaseMPC          Phase = "mpc"
+	PhaseZK           Phase = "zk"
+	PhaseComplete     Phase = "complete"
+)
+
+// Session is one ReclaimProve run. Claim and Signature are populated once Status is
+// StatusSucceeded; Error is populated for StatusFailed and StatusTimeout.
+type Session struct {
+	ID        uuid.UUID
+	Status    Status
+	Phase     Phase
+	Claim     *oapi.ReclaimClaim
+	Signature *oapi.ReclaimSignature
+	Error     string
+
+	// Signatures holds every attestor's signature over Claim when this session ran in
+	// multi-attestor quorum mode (see reclaim_quorum.go); Signature is set to Signatures[0] for
+	// callers that only care about a single attestation. Nil for a single-attestor session.
+	Signatures []oapi.ReclaimSignature
+
+	// CallbackURL/CallbackSecret, if set, are used to deliver an HMAC-signed webhook when the
+	// session reaches a terminal status.
+	CallbackURL    string
+	CallbackSecret string
+
+	// Subject is the authenticated caller's JWT subject claim, used to enforce
+	// reclaimauth.IssuerPolicy.MaxConcurrentSessions. Empty when the server runs without
+	// authentication configured.
+	Subject string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Active reports whether sess still counts against its subject's concurrent session limit.
+func (sess *Session) Active() bool {
+	return sess.Status == StatusPending || sess.Status == StatusRunning
+}
+
+// Store persists proof sessions so they survive a server restart while a proof is in flight.
+// Implementations: MemoryStore (tests, single-process dev use) and BoltStore (production).
+type Store interface {
+	// Create persists a new session. The caller must ensure sess.ID is unique.
+	Create(ctx context.Context, sess *Session) error
+	// Get returns the session for id, or ErrNotFound if none exists.
+	Get(ctx context.Context, id uuid.UUID) (*Session, error)
+	// Update overwrites the persisted state for sess.ID, which must already exist.
+	Update(ctx context.Context, sess *Session) error
+	// CountActiveBySubject returns how many sessions with the given subject are still Active,
+	// so a caller can enforce a per-subject concurrency limit before creating another one.
+	CountActiveBySubject(ctx context.Context, subject string) (int, error)
+	Close() error
+}
@@ -0,0 +1,122 @@
+// Package ratelimit implements token-bucket rate limiting and in-flight concurrency caps for
+// expensive HTTP routes (spawning a tsx process, driving xdotool), following the same token-
+// bucket-with-background-refill shape the scraper/automation clients in this codebase use to
+// respect an upstream's rate limit, just turned around to enforce one of our own.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limits bundles the three knobs a caller configures a Limiter with: refill rate, burst size,
+// and the in-flight concurrency cap. It exists mainly so New's callers (and the config layer
+// feeding them) have a single value to pass around instead of three loose numbers.
+type Limits struct {
+	RPS         float64
+	Burst       float64
+	MaxInFlight int
+}
+
+// Limiter is a single token bucket plus an in-flight counter. Tokens refill continuously at
+// Rate per second up to Burst, and are consumed one per call admitted through Wait/Allow;
+// MaxInFlight caps the number of calls simultaneously past that admission check regardless of
+// how many tokens are available, bounding steady-state concurrency (e.g. concurrent tsx
+// processes) independently of burst tolerance.
+type Limiter struct {
+	rate        float64 // tokens added per second
+	burst       float64 // maximum tokens the bucket can hold
+	maxInFlight int     // 0 means unbounded
+
+	mu       sync.Mutex
+	tokens   float64
+	lastFill time.Time
+	inFlight int
+}
+
+// New returns a Limiter that refills at rate tokens/sec up to burst tokens, starting full, and
+// admits at most maxInFlight concurrent callers (0 for no cap).
+func New(rate, burst float64, maxInFlight int) *Limiter {
+	return &Limiter{
+		rate:        rate,
+		burst:       burst,
+		maxInFlight: maxInFlight,
+		tokens:      burst,
+		lastFill:    time.Now(),
+	}
+}
+
+// NewFromLimits is a convenience wrapper around New for callers that already have a Limits
+// value, such as the per-client limiter pools in server/cmd/api/api/exec_ratelimit.go.
+func NewFromLimits(l Limits) *Limiter {
+	return New(l.RPS, l.Burst, l.MaxInFlight)
+}
+
+// refill must be called with l.mu held.
+func (l *Limiter) refill() {
+	now := time.Now()
+	elapsed := now.Sub(l.lastFill).Seconds()
+	l.lastFill = now
+	l.tokens += elapsed * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Allow reports whether a call may proceed right now: a token is available and admitting one
+// more caller would not exceed MaxInFlight. On true, the caller must call Done when finished.
+func (l *Limiter) Allow() (ok bool, done func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	if l.tokens < 1 {
+		return false, nil
+	}
+	if l.maxInFlight > 0 && l.inFlight >= l.maxInFlight {
+		return false, nil
+	}
+
+	l.tokens--
+	l.inFlight++
+	return true, l.release
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+}
+
+// Wait blocks until a token and an in-flight slot are both available, or ctx is done. On
+// success the caller must call done when finished with the unit of work it admitted.
+func (l *Limiter) Wait(ctx context.Context) (done func(), err error) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if ok, done := l.Allow(); ok {
+			return done, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// RetryAfter estimates how long a caller should wait before the next token is likely available,
+// for populating a 429 response's Retry-After header.
+func (l *Limiter) RetryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	if l.tokens >= 1 {
+		return 0
+	}
+	missing := 1 - l.tokens
+	return time.Duration(missing / l.rate * float64(time.Second))
+}
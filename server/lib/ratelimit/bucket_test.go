@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter_AllowRespectsBurst(t *testing.T) {
+	l := New(1, 3, 0)
+
+	for i := 0; i < 3; i++ {
+		ok, done := l.Allow()
+		if !ok {
+			t.Fatalf("call %d: expected allow within burst, got denied", i)
+		}
+		done()
+	}
+
+	if ok, _ := l.Allow(); ok {
+		t.Fatalf("expected call past burst to be denied")
+	}
+}
+
+func TestLimiter_MaxInFlight(t *testing.T) {
+	l := New(100, 100, 2)
+
+	ok1, done1 := l.Allow()
+	ok2, done2 := l.Allow()
+	if !ok1 || !ok2 {
+		t.Fatalf("expected first two calls to be admitted, got %v %v", ok1, ok2)
+	}
+
+	if ok, _ := l.Allow(); ok {
+		t.Fatalf("expected third concurrent call to be denied at MaxInFlight=2")
+	}
+
+	done1()
+	if ok, done := l.Allow(); !ok {
+		t.Fatalf("expected a call to be admitted after one in-flight call finished")
+	} else {
+		done()
+	}
+	done2()
+}
+
+func TestLimiter_WaitReturnsOnceTokenAvailable(t *testing.T) {
+	l := New(1000, 1, 0)
+
+	ok, done := l.Allow()
+	if !ok {
+		t.Fatalf("expected first call to be allowed")
+	}
+	done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := l.Wait(ctx); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+}
+
+func TestLimiter_WaitRespectsContextCancellation(t *testing.T) {
+	l := New(0.001, 1, 0)
+	ok, done := l.Allow()
+	if !ok {
+		t.Fatalf("expected first call to be allowed")
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx); err == nil {
+		t.Fatalf("expected Wait to return an error once ctx expired")
+	}
+}
+
+func TestLimiter_RetryAfterIsZeroWhenTokenAvailable(t *testing.T) {
+	l := New(1, 1, 0)
+	if d := l.RetryAfter(); d != 0 {
+		t.Fatalf("expected zero retry-after with a full bucket, got %v", d)
+	}
+}
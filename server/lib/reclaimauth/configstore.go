@@ -0,0 +1,64 @@
+package reclaimauth
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// ConfigStore holds the current reclaim auth Config and lets it be swapped out at runtime, so a
+// SIGHUP-triggered reload doesn't require restarting the server or racing in-flight requests.
+type ConfigStore struct {
+	path    string
+	current atomic.Pointer[Config]
+}
+
+// NewConfigStore loads path once and returns a ConfigStore serving it. Call WatchSIGHUP to keep
+// it current as the file changes.
+func NewConfigStore(path string) (*ConfigStore, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &ConfigStore{path: path}
+	s.current.Store(cfg)
+	return s, nil
+}
+
+// Current returns the most recently loaded Config. The returned pointer must not be mutated.
+func (s *ConfigStore) Current() *Config {
+	return s.current.Load()
+}
+
+// Reload re-reads s.path and, if it parses successfully, atomically swaps it in. A bad config on
+// disk is logged and left in place rather than taking the server's reclaim auth down.
+func (s *ConfigStore) Reload(log *slog.Logger) {
+	cfg, err := LoadConfig(s.path)
+	if err != nil {
+		log.Error("failed to reload reclaim auth config, keeping previous policy", "path", s.path, "err", err)
+		return
+	}
+	s.current.Store(cfg)
+	log.Info("reloaded reclaim auth config", "path", s.path, "issuers", len(cfg.Issuers))
+}
+
+// WatchSIGHUP reloads s on every SIGHUP until ctx is done, following the same
+// signal.NotifyContext-adjacent pattern main.go uses for shutdown signals.
+func (s *ConfigStore) WatchSIGHUP(ctx context.Context, log *slog.Logger) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sighup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				s.Reload(log)
+			}
+		}
+	}()
+}
@@ -0,0 +1,21 @@
+package reclaimauth
+
+import (
+	"fmt"
+	"os"
+)
+
+// LoadAuthenticatorFromEnv builds an Authenticator from RECLAIM_AUTH_CONFIG_PATH, mirroring
+// exttrust.LoadPolicyFromEnv. With the env var unset, it returns a disabled Authenticator so a
+// deployment that hasn't opted in isn't locked out of ReclaimProve.
+func LoadAuthenticatorFromEnv() (*Authenticator, error) {
+	path := os.Getenv("RECLAIM_AUTH_CONFIG_PATH")
+	if path == "" {
+		return NewDisabledAuthenticator(), nil
+	}
+	store, err := NewConfigStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reclaim auth policy: %w", err)
+	}
+	return NewAuthenticator(store), nil
+}
@@ -0,0 +1,44 @@
+package reclaimauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// JWKSManager lazily builds and caches one issuerKeySource per issuer, so multiple policies
+// sharing an issuer don't each poll its JWKS endpoint independently.
+type JWKSManager struct {
+	mu      sync.Mutex
+	sources map[string]*issuerKeySource
+}
+
+func NewJWKSManager() *JWKSManager {
+	return &JWKSManager{sources: make(map[string]*issuerKeySource)}
+}
+
+// Source returns the issuerKeySource for issuer, creating and starting it (with its own
+// background refresh goroutine) on first use.
+func (m *JWKSManager) Source(ctx context.Context, issuer string, refreshInterval time.Duration) (*issuerKeySource, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sources[issuer]; ok {
+		return s, nil
+	}
+	s, err := newIssuerKeySource(ctx, issuer, refreshInterval)
+	if err != nil {
+		return nil, err
+	}
+	m.sources[issuer] = s
+	return s, nil
+}
+
+// Close stops every issuer's background refresh goroutine.
+func (m *JWKSManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sources {
+		s.close()
+	}
+}
@@ -0,0 +1,81 @@
+package reclaimauth
+
+import (
+	"context"
+	"crypto"
+	"sync/atomic"
+	"time"
+)
+
+// issuerKeySource keeps one issuer's active JWKS in memory, refreshing it on a ticker so a key
+// rotation on the issuer's side is picked up without a server restart. A lookup always checks
+// the most recently synced set; there's no separate "previous set" grace period because the
+// issuer's own JWKS response already keeps a rotated-out key published until its tokens expire.
+type issuerKeySource struct {
+	issuer  string
+	jwksURI string
+
+	keys   atomic.Pointer[map[string]crypto.PublicKey]
+	cancel context.CancelFunc
+}
+
+// newIssuerKeySource discovers issuer's jwks_uri, performs an initial sync, and starts a
+// background goroutine that re-syncs every refreshInterval until the returned source is closed.
+func newIssuerKeySource(ctx context.Context, issuer string, refreshInterval time.Duration) (*issuerKeySource, error) {
+	jwksURI, err := discoverJWKSURI(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	s := &issuerKeySource{issuer: issuer, jwksURI: jwksURI}
+	if err := s.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	rotateCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.rotateLoop(rotateCtx, refreshInterval)
+	return s, nil
+}
+
+// sync fetches the current JWKS and swaps it in as the active set.
+func (s *issuerKeySource) sync(ctx context.Context) error {
+	keys, err := fetchJWKS(ctx, s.jwksURI)
+	if err != nil {
+		return err
+	}
+	s.keys.Store(&keys)
+	return nil
+}
+
+// rotateLoop re-syncs s on every tick, logging nothing of its own: a transient fetch failure
+// just leaves the previous active set in place until the next tick succeeds.
+func (s *issuerKeySource) rotateLoop(ctx context.Context, refreshInterval time.Duration) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			syncCtx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+			_ = s.sync(syncCtx)
+			cancel()
+		}
+	}
+}
+
+// key looks up kid in the currently active set.
+func (s *issuerKeySource) key(kid string) (crypto.PublicKey, bool) {
+	keys := s.keys.Load()
+	if keys == nil {
+		return nil, false
+	}
+	k, ok := (*keys)[kid]
+	return k, ok
+}
+
+func (s *issuerKeySource) close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
@@ -0,0 +1,146 @@
+package reclaimauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// oidcDiscoveryDoc is the subset of a /.well-known/openid-configuration response this package
+// needs.
+type oidcDiscoveryDoc struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jwk is a single entry of a JWKS response, covering the RSA and EC key types issuers actually
+// use for signing (oct/symmetric keys are never valid for a signing JWKS and are ignored).
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksDoc struct {
+	Keys []jwk `json:"keys"`
+}
+
+// discoverJWKSURI fetches issuer's OIDC discovery document and returns its jwks_uri.
+func discoverJWKSURI(ctx context.Context, issuer string) (string, error) {
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	var doc oidcDiscoveryDoc
+	if err := getJSON(ctx, discoveryURL, &doc); err != nil {
+		return "", fmt.Errorf("failed to fetch OIDC discovery document: %w", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery document for %s has no jwks_uri", issuer)
+	}
+	return doc.JWKSURI, nil
+}
+
+// fetchJWKS downloads jwksURI and decodes each signing key into the map this package verifies
+// tokens against, keyed by kid.
+func fetchJWKS(ctx context.Context, jwksURI string) (map[string]crypto.PublicKey, error) {
+	var doc jwksDoc
+	if err := getJSON(ctx, jwksURI, &doc); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := decodeJWK(k)
+		if err != nil {
+			continue // a single unparseable key (e.g. an unsupported kty) shouldn't sink the rest
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func decodeJWK(k jwk) (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		nBytes, err := base64URLDecode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		eBytes, err := base64URLDecode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(new(big.Int).SetBytes(eBytes).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve: %s", k.Crv)
+		}
+		xBytes, err := base64URLDecode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		yBytes, err := base64URLDecode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(xBytes),
+			Y:     new(big.Int).SetBytes(yBytes),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type: %s", k.Kty)
+	}
+}
+
+func base64URLDecode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	httpClient := &http.Client{Timeout: jwksFetchTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+const jwksFetchTimeout = 10 * time.Second
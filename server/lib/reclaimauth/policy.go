@@ -0,0 +1,94 @@
+package reclaimauth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// defaultJWKSRefreshInterval matches what most OIDC providers recommend for key rotation
+// polling: frequent enough to pick up an emergency key rollover within the hour, infrequent
+// enough not to hammer the issuer's JWKS endpoint.
+const defaultJWKSRefreshInterval = 15 * time.Minute
+
+// IssuerPolicy is the authorization policy for every token issued by Issuer for Audience.
+type IssuerPolicy struct {
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+
+	// AllowedProviders whitelists the providerParamsJson.provider values a subject under this
+	// issuer+audience may request. Empty means any provider is allowed.
+	AllowedProviders []string `json:"allowedProviders,omitempty"`
+	// MaxConcurrentSessions caps how many of a subject's proof sessions may be Active at once.
+	// Zero means unlimited.
+	MaxConcurrentSessions int `json:"maxConcurrentSessions,omitempty"`
+	// RequiredScopes lists scopes that must all be present in the token's scope/scp claim.
+	RequiredScopes []string `json:"requiredScopes,omitempty"`
+}
+
+func (p *IssuerPolicy) allowsProvider(provider string) bool {
+	if len(p.AllowedProviders) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedProviders {
+		if allowed == provider {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *IssuerPolicy) missingScopes(granted []string) []string {
+	have := make(map[string]bool, len(granted))
+	for _, s := range granted {
+		have[s] = true
+	}
+	var missing []string
+	for _, want := range p.RequiredScopes {
+		if !have[want] {
+			missing = append(missing, want)
+		}
+	}
+	return missing
+}
+
+// Config is the on-disk shape of the reclaim auth policy file: one IssuerPolicy per
+// issuer+audience pair, plus how often to poll each issuer's JWKS for rotated keys.
+type Config struct {
+	Issuers             []IssuerPolicy `json:"issuers"`
+	JWKSRefreshInterval time.Duration  `json:"jwksRefreshInterval,omitempty"`
+}
+
+func (c *Config) issuer(issuer string) (*IssuerPolicy, bool) {
+	for i := range c.Issuers {
+		if c.Issuers[i].Issuer == issuer {
+			return &c.Issuers[i], true
+		}
+	}
+	return nil, false
+}
+
+// LoadConfig reads a YAML or JSON reclaim auth policy file from path. JSON is valid YAML, so
+// both formats are accepted through the same parser, matching how the rest of this server
+// handles its spec.yaml/spec.json pair.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reclaim auth config %s: %w", path, err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse reclaim auth config %s: %w", path, err)
+	}
+	if cfg.JWKSRefreshInterval <= 0 {
+		cfg.JWKSRefreshInterval = defaultJWKSRefreshInterval
+	}
+	for i := range cfg.Issuers {
+		if cfg.Issuers[i].Issuer == "" {
+			return nil, fmt.Errorf("reclaim auth config %s: issuers[%d] is missing an issuer", path, i)
+		}
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,137 @@
+// Package reclaimauth authenticates and authorizes callers of the ReclaimProve/GetReclaimSession
+// endpoints: a bearer JWT is verified against one or more configured OIDC issuers, then the
+// token's subject, audience, and scopes are checked against a per-issuer+audience Policy before
+// the request is allowed to reach the handler.
+package reclaimauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ErrMissingToken is returned when a request carries no Authorization: Bearer header at all.
+var ErrMissingToken = errors.New("missing bearer token")
+
+// ErrUnauthorized wraps a JWT that failed verification (bad signature, expired, wrong issuer or
+// audience). Callers should respond 401.
+type ErrUnauthorized struct{ Reason string }
+
+func (e *ErrUnauthorized) Error() string { return "unauthorized: " + e.Reason }
+
+// ErrForbidden reports a verified token whose subject isn't permitted to do what it asked.
+// Callers should respond 403.
+type ErrForbidden struct{ Reason string }
+
+func (e *ErrForbidden) Error() string { return "forbidden: " + e.Reason }
+
+// Claims is the subset of a verified ID token this package cares about.
+type Claims struct {
+	Issuer   string
+	Subject  string
+	Audience string
+	Scopes   []string
+}
+
+// Authenticator verifies a bearer token against its issuer's JWKS and authorizes the resulting
+// claims against the matching Policy. It's safe for concurrent use.
+type Authenticator struct {
+	keys   *JWKSManager
+	config *ConfigStore
+
+	// disabled mirrors exttrust's default Off trust mode: with no RECLAIM_AUTH_CONFIG_PATH
+	// configured, every caller is let through rather than the server locking itself out.
+	disabled bool
+}
+
+// NewAuthenticator builds an Authenticator whose JWKS cache covers every issuer named in
+// config's policies and whose authorization decisions are re-read from config on every call, so
+// a SIGHUP-triggered ConfigStore.Reload takes effect without restarting the Authenticator.
+func NewAuthenticator(config *ConfigStore) *Authenticator {
+	return &Authenticator{
+		keys:   NewJWKSManager(),
+		config: config,
+	}
+}
+
+// NewDisabledAuthenticator returns an Authenticator that performs no verification or
+// authorization at all, for deployments that haven't configured RECLAIM_AUTH_CONFIG_PATH.
+func NewDisabledAuthenticator() *Authenticator {
+	return &Authenticator{disabled: true}
+}
+
+// Authenticate verifies bearerToken and returns its claims. It does not check authorization for
+// a specific action; callers use Authorize for that once they know the provider being requested.
+func (a *Authenticator) Authenticate(ctx context.Context, bearerToken string) (*Claims, error) {
+	if a.disabled {
+		return &Claims{}, nil
+	}
+	if bearerToken == "" {
+		return nil, ErrMissingToken
+	}
+
+	unverifiedIssuer, err := peekIssuer(bearerToken)
+	if err != nil {
+		return nil, &ErrUnauthorized{Reason: err.Error()}
+	}
+
+	cfg := a.config.Current()
+	issuerCfg, ok := cfg.issuer(unverifiedIssuer)
+	if !ok {
+		return nil, &ErrUnauthorized{Reason: fmt.Sprintf("issuer %q is not configured", unverifiedIssuer)}
+	}
+
+	source, err := a.keys.Source(ctx, unverifiedIssuer, cfg.JWKSRefreshInterval)
+	if err != nil {
+		return nil, &ErrUnauthorized{Reason: fmt.Sprintf("failed to load issuer keys: %v", err)}
+	}
+
+	claims, err := verifyJWT(bearerToken, source, issuerCfg.Audience)
+	if err != nil {
+		return nil, &ErrUnauthorized{Reason: err.Error()}
+	}
+	return claims, nil
+}
+
+// Authorize checks claims against the Policy configured for claims.Issuer+claims.Audience:
+// whether provider is allow-listed, whether claims carry every required scope, and (via
+// activeSessions, typically proofsession.Store.CountActiveBySubject) whether the subject is
+// already at its concurrent session limit.
+func (a *Authenticator) Authorize(claims *Claims, provider string, activeSessions int) error {
+	if a.disabled {
+		return nil
+	}
+	cfg := a.config.Current()
+	policy, ok := cfg.issuer(claims.Issuer)
+	if !ok || policy.Audience != claims.Audience {
+		return &ErrForbidden{Reason: fmt.Sprintf("no policy for issuer %q audience %q", claims.Issuer, claims.Audience)}
+	}
+	if !policy.allowsProvider(provider) {
+		return &ErrForbidden{Reason: fmt.Sprintf("provider %q is not permitted for this subject", provider)}
+	}
+	if missing := policy.missingScopes(claims.Scopes); len(missing) > 0 {
+		return &ErrForbidden{Reason: fmt.Sprintf("missing required scope(s): %v", missing)}
+	}
+	if policy.MaxConcurrentSessions > 0 && activeSessions >= policy.MaxConcurrentSessions {
+		return &ErrForbidden{Reason: fmt.Sprintf("subject already has %d active proof session(s), limit is %d", activeSessions, policy.MaxConcurrentSessions)}
+	}
+	return nil
+}
+
+// WatchSIGHUP hot-reloads the backing Config on SIGHUP until ctx is done. A no-op when
+// authentication is disabled.
+func (a *Authenticator) WatchSIGHUP(ctx context.Context, log *slog.Logger) {
+	if a.disabled {
+		return
+	}
+	a.config.WatchSIGHUP(ctx, log)
+}
+
+// Close stops the background JWKS refresh goroutines.
+func (a *Authenticator) Close() {
+	if a.disabled {
+		return
+	}
+	a.keys.Close()
+}
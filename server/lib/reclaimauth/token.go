@@ -0,0 +1,184 @@
+package reclaimauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// jwtHeader is the subset of a JWS header this package needs to pick the right verification key.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims is the subset of RFC 7519 registered claims this package validates, plus the scope
+// claim OAuth2 access tokens and some ID tokens carry (either space-separated per RFC 8693, or
+// as a JSON array, depending on issuer).
+type jwtClaims struct {
+	Issuer   string          `json:"iss"`
+	Subject  string          `json:"sub"`
+	Audience json.RawMessage `json:"aud"`
+	Exp      int64           `json:"exp"`
+	Nbf      int64           `json:"nbf"`
+	Scope    string          `json:"scope"`
+	Scp      json.RawMessage `json:"scp"`
+}
+
+// peekIssuer decodes a JWT's payload without verifying its signature, just far enough to know
+// which issuer's JWKS to fetch before real verification happens.
+func peekIssuer(token string) (string, error) {
+	_, claims, _, _, err := decodeJWT(token)
+	if err != nil {
+		return "", err
+	}
+	if claims.Issuer == "" {
+		return "", fmt.Errorf("token has no iss claim")
+	}
+	return claims.Issuer, nil
+}
+
+// verifyJWT validates token's signature against source, then checks exp/nbf/aud and returns its
+// claims. audience is the single audience value this server's Config expects for source's issuer.
+func verifyJWT(token string, source *issuerKeySource, audience string) (*Claims, error) {
+	header, claims, signingInput, sig, err := decodeJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := source.key(header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("no key found for kid %q", header.Kid)
+	}
+	if err := verifySignature(header.Alg, key, signingInput, sig); err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	now := time.Now()
+	if claims.Exp != 0 && now.After(time.Unix(claims.Exp, 0)) {
+		return nil, fmt.Errorf("token expired at %s", time.Unix(claims.Exp, 0))
+	}
+	if claims.Nbf != 0 && now.Before(time.Unix(claims.Nbf, 0)) {
+		return nil, fmt.Errorf("token not valid before %s", time.Unix(claims.Nbf, 0))
+	}
+
+	audiences := decodeAudience(claims.Audience)
+	if !containsString(audiences, audience) {
+		return nil, fmt.Errorf("token audience %v does not include %q", audiences, audience)
+	}
+
+	return &Claims{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Audience: audience,
+		Scopes:   decodeScopes(claims),
+	}, nil
+}
+
+// decodeJWT splits a compact JWS into its header, claims, signing input (the part the signature
+// covers), and raw signature bytes, without checking the signature itself.
+func decodeJWT(token string) (jwtHeader, jwtClaims, string, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtHeader{}, jwtClaims{}, "", nil, fmt.Errorf("malformed JWT: expected 3 parts, got %d", len(parts))
+	}
+
+	headerBytes, err := base64URLDecode(parts[0])
+	if err != nil {
+		return jwtHeader{}, jwtClaims{}, "", nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return jwtHeader{}, jwtClaims{}, "", nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+
+	claimsBytes, err := base64URLDecode(parts[1])
+	if err != nil {
+		return jwtHeader{}, jwtClaims{}, "", nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(claimsBytes, &claims); err != nil {
+		return jwtHeader{}, jwtClaims{}, "", nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+
+	sig, err := base64URLDecode(parts[2])
+	if err != nil {
+		return jwtHeader{}, jwtClaims{}, "", nil, fmt.Errorf("malformed JWT signature: %w", err)
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	return header, claims, signingInput, sig, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an RSA key for alg %s", alg)
+		}
+		return rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], sig)
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an EC key for alg %s", alg)
+		}
+		if len(sig) != 64 {
+			return fmt.Errorf("malformed ES256 signature length %d", len(sig))
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		sVal := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, sVal) {
+			return fmt.Errorf("signature did not verify")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+}
+
+// decodeAudience handles both the common single-string `"aud": "x"` and the RFC 7519
+// multi-valued `"aud": ["x", "y"]` encodings.
+func decodeAudience(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err == nil {
+		return many
+	}
+	return nil
+}
+
+// decodeScopes handles the two conventions issuers use for carrying scopes: a space-delimited
+// "scope" string (OAuth2 access tokens, RFC 8693) or a "scp" array (some ID token issuers).
+func decodeScopes(claims jwtClaims) []string {
+	if claims.Scope != "" {
+		return strings.Fields(claims.Scope)
+	}
+	var scp []string
+	if len(claims.Scp) > 0 {
+		_ = json.Unmarshal(claims.Scp, &scp)
+	}
+	return scp
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
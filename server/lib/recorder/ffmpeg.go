@@ -1,6 +1,7 @@
 package recorder
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -18,6 +20,19 @@ import (
 	"github.com/onkernel/kernel-images/server/lib/logger"
 )
 
+const (
+	// hlsPlaylistName is the master playlist ffmpeg's tee output writes into a recorder's
+	// hlsDir, and the file api.GetRecordingPlaylist serves.
+	hlsPlaylistName          = "master.m3u8"
+	defaultHLSSegmentSeconds = 4
+	defaultHLSKeepSegments   = 6
+)
+
+// hlsSegmentNameRegex bounds api.GetRecordingSegment's path param to exactly the filenames
+// ffmpeg's hls_segment_filename pattern below produces, so it can't be used to read arbitrary
+// files out of hlsDir.
+var hlsSegmentNameRegex = regexp.MustCompile(`^segment_\d{5}\.ts$`)
+
 // FFmpegRecorder encapsulates an FFmpeg recording session with platform-specific screen capture.
 // It manages the lifecycle of a single FFmpeg process and provides thread-safe operations.
 type FFmpegRecorder struct {
@@ -27,11 +42,53 @@ type FFmpegRecorder struct {
 	cmd        *exec.Cmd
 	params     FFmpegRecordingParams
 	outputPath string
-	startTime  time.Time
-	endTime    time.Time
-	ffmpegErr  error
-	exitCode   int
-	exited     chan struct{}
+	// hlsDir is set when params.HLS.Enabled is true: the directory ffmpeg's tee output writes
+	// the live master playlist and segments into, alongside outputPath's mp4. Empty disables HLS.
+	hlsDir    string
+	startTime time.Time
+	endTime   time.Time
+	ffmpegErr error
+	exitCode  int
+	exited    chan struct{}
+	// progress is the latest snapshot parsed off ffmpeg's -progress pipe by readProgress.
+	// It's the zero value until the first progress=continue line arrives.
+	progress RecordingProgress
+
+	// sink, when non-nil, is where tailUpload streams outputPath to as ffmpeg writes it. A nil
+	// sink disables upload entirely; Recording() then serves outputPath directly, as before.
+	sink UploadSink
+	// uploadKey is the object key tailUpload uploads outputPath's bytes under.
+	uploadKey string
+	// uploadURL is set once tailUpload's Complete call returns, and read by Recording() in
+	// place of opening outputPath locally.
+	uploadURL string
+
+	// manager is set by FFmpegManager.RegisterRecorder, so waitForCommand can deregister this
+	// recorder on terminal exit without the caller having to remember to. Nil if this recorder
+	// was never registered with a manager.
+	manager *FFmpegManager
+}
+
+// uploadPartSizeBytes is the buffer size tailUpload accumulates before calling
+// UploadSink.UploadPart, within S3 multipart upload's 5MB-16MB sweet spot.
+const uploadPartSizeBytes = 8 << 20 // 8MB
+
+// RecordingProgress is a snapshot of ffmpeg's `-progress pipe:` output, parsed from the most
+// recent progress=continue|end block. It lets a caller detect a stall (Frame not advancing
+// across polls), x11grab falling behind (DropFrames growing), or an undersized disk before
+// DownloadRecording is even possible.
+type RecordingProgress struct {
+	Frame      int64
+	FPS        float64
+	Bitrate    string
+	TotalSize  int64
+	OutTimeUs  int64
+	DupFrames  int64
+	DropFrames int64
+	Speed      float64
+	// Done is true once progress=end has been seen, i.e. ffmpeg has exited.
+	Done      bool
+	UpdatedAt time.Time
 }
 
 type FFmpegRecordingParams struct {
@@ -39,6 +96,57 @@ type FFmpegRecordingParams struct {
 	DisplayNum  *int
 	MaxSizeInMB *int
 	OutputDir   *string
+	HLS         FFmpegHLSParams
+	// Encoder selects the video encoder backend. The zero value behaves like EncoderAuto.
+	Encoder Encoder
+	// BroadcastTargets are additional live RTMP/SRT sinks muxed from the same encode as the
+	// mp4, via the same tee output HLS uses. A nil slice means none.
+	BroadcastTargets []BroadcastTarget
+	// MaxDuration, if set, bounds how long a single recording may run before
+	// FFmpegManager's idle reaper stops it, regardless of progress activity. Nil means no bound
+	// beyond MaxSizeInMB's own -fs cutoff.
+	MaxDuration *time.Duration
+}
+
+// BroadcastTarget is one live push destination ffmpeg's tee muxer streams to alongside the
+// local recording.
+type BroadcastTarget struct {
+	URL string
+	// Format is ffmpeg's output format name for URL's protocol: "flv" for rtmp://, "mpegts"
+	// for srt://.
+	Format string
+	// Bitrate overrides the encoder's default rate control for this target only, e.g. "2M".
+	Bitrate *string
+}
+
+// Encoder names a video encoder backend ffmpegArgs can target. Hardware backends trade a
+// format/hwupload filter chain and their own rate-control flags for much lower CPU usage than
+// libx264, which matters here since x11grab + libx264 at 30fps easily saturates a modest VM.
+type Encoder string
+
+const (
+	// EncoderAuto picks the best backend available in the container, in priority order
+	// (VAAPI, then NVENC on Linux; VideoToolbox on macOS), falling back to EncoderLibx264.
+	EncoderAuto         Encoder = "auto"
+	EncoderLibx264      Encoder = "libx264"
+	EncoderVAAPI        Encoder = "h264_vaapi"
+	EncoderNVENC        Encoder = "h264_nvenc"
+	EncoderVideoToolbox Encoder = "h264_videotoolbox"
+	// EncoderCopy streams the input codec through unchanged; only meaningful for inputs that
+	// are already encoded, not x11grab/avfoundation's raw frames.
+	EncoderCopy Encoder = "copy"
+)
+
+// FFmpegHLSParams configures an optional live HLS ladder emitted alongside the primary mp4, so
+// a client can attach a player before the recording finishes instead of waiting for
+// DownloadRecording. It's muxed from the same encode as the mp4 via ffmpeg's tee output rather
+// than a second ffmpeg process, and segment pruning is handled by ffmpeg's own
+// hls_flags=delete_segments, the same way MaxSizeInMB is enforced via -fs instead of
+// application-level polling.
+type FFmpegHLSParams struct {
+	Enabled        *bool
+	SegmentSeconds *int
+	KeepSegments   *int
 }
 
 func (p FFmpegRecordingParams) Validate() error {
@@ -60,25 +168,38 @@ func (p FFmpegRecordingParams) Validate() error {
 
 type FFmpegRecorderFactory func(id string, overrides FFmpegRecordingParams) (Recorder, error)
 
-func NewFFmpegRecorderFactory(config FFmpegRecordingParams) FFmpegRecorderFactory {
+// NewFFmpegRecorderFactory builds the factory FFmpegManager uses to construct recorders. sink
+// is optional; when non-nil, every recorder this factory creates streams its mp4 to sink as
+// ffmpeg writes it instead of relying solely on local disk, via tailUpload.
+func NewFFmpegRecorderFactory(config FFmpegRecordingParams, sink UploadSink) FFmpegRecorderFactory {
 	return func(id string, overrides FFmpegRecordingParams) (Recorder, error) {
 		mergedParams := mergeFFmpegRecordingParams(config, overrides)
 
 		filename := filepath.Join(*config.OutputDir, fmt.Sprintf("%s.mp4", id))
-		return &FFmpegRecorder{
+		rec := &FFmpegRecorder{
 			id:         id,
 			outputPath: filename,
 			params:     mergedParams,
-		}, nil
+			sink:       sink,
+			uploadKey:  fmt.Sprintf("%s.mp4", id),
+		}
+		if mergedParams.HLS.Enabled != nil && *mergedParams.HLS.Enabled {
+			rec.hlsDir = filepath.Join(*mergedParams.OutputDir, id)
+		}
+		return rec, nil
 	}
 }
 
 func mergeFFmpegRecordingParams(config FFmpegRecordingParams, overrides FFmpegRecordingParams) FFmpegRecordingParams {
 	merged := FFmpegRecordingParams{
-		FrameRate:   config.FrameRate,
-		DisplayNum:  config.DisplayNum,
-		MaxSizeInMB: config.MaxSizeInMB,
-		OutputDir:   config.OutputDir,
+		FrameRate:        config.FrameRate,
+		DisplayNum:       config.DisplayNum,
+		MaxSizeInMB:      config.MaxSizeInMB,
+		OutputDir:        config.OutputDir,
+		HLS:              config.HLS,
+		Encoder:          config.Encoder,
+		BroadcastTargets: config.BroadcastTargets,
+		MaxDuration:      config.MaxDuration,
 	}
 	if overrides.FrameRate != nil {
 		merged.FrameRate = overrides.FrameRate
@@ -92,6 +213,24 @@ func mergeFFmpegRecordingParams(config FFmpegRecordingParams, overrides FFmpegRe
 	if overrides.OutputDir != nil {
 		merged.OutputDir = overrides.OutputDir
 	}
+	if overrides.HLS.Enabled != nil {
+		merged.HLS.Enabled = overrides.HLS.Enabled
+	}
+	if overrides.HLS.SegmentSeconds != nil {
+		merged.HLS.SegmentSeconds = overrides.HLS.SegmentSeconds
+	}
+	if overrides.HLS.KeepSegments != nil {
+		merged.HLS.KeepSegments = overrides.HLS.KeepSegments
+	}
+	if overrides.Encoder != "" {
+		merged.Encoder = overrides.Encoder
+	}
+	if overrides.BroadcastTargets != nil {
+		merged.BroadcastTargets = overrides.BroadcastTargets
+	}
+	if overrides.MaxDuration != nil {
+		merged.MaxDuration = overrides.MaxDuration
+	}
 
 	return merged
 }
@@ -116,26 +255,58 @@ func (fr *FFmpegRecorder) Start(ctx context.Context) error {
 	fr.startTime = time.Now()
 	fr.exited = make(chan struct{})
 
-	args, err := ffmpegArgs(fr.params, fr.outputPath)
+	if fr.hlsDir != "" {
+		if err := os.MkdirAll(fr.hlsDir, 0o755); err != nil {
+			fr.mu.Unlock()
+			return fmt.Errorf("failed to create hls output dir: %w", err)
+		}
+	}
+
+	args, err := ffmpegArgs(fr.params, fr.outputPath, fr.hlsDir)
 	if err != nil {
 		return err
 	}
 	log.Info(fmt.Sprintf("ffmpeg %s", strings.Join(args, " ")))
 
+	// ffmpeg writes its -progress key=value stream to fd 3, which ExtraFiles[0] below maps to
+	// in the child (0, 1, 2 are already stdin/stdout/stderr).
+	progressReader, progressWriter, err := os.Pipe()
+	if err != nil {
+		fr.mu.Unlock()
+		return fmt.Errorf("failed to create progress pipe: %w", err)
+	}
+
 	cmd := exec.Command("ffmpeg", args...)
 	// create process group to ensure all processes are signaled together
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
+	cmd.ExtraFiles = []*os.File{progressWriter}
 	fr.cmd = cmd
+	fr.progress = RecordingProgress{}
 	fr.mu.Unlock()
 
 	if err := cmd.Start(); err != nil {
+		progressReader.Close()
+		progressWriter.Close()
 		return fmt.Errorf("failed to start ffmpeg process: %w", err)
 	}
+	// The child has its own copy of the write end now; close the parent's so readProgress's
+	// scanner sees EOF once ffmpeg exits instead of blocking forever.
+	progressWriter.Close()
 
 	// Launch background waiter to capture process completion.
 	go fr.waitForCommand(ctx)
+	go fr.readProgress(ctx, progressReader)
+
+	if fr.sink != nil {
+		uploadID, err := fr.sink.Begin(ctx, fr.uploadKey)
+		if err != nil {
+			log.Error("failed to begin recording upload, continuing without it", "err", err)
+		} else {
+			go fr.tailUpload(ctx, uploadID)
+		}
+	}
 
 	// Check for startup errors before returning
 	if err := waitForChan(ctx, 500*time.Millisecond, fr.exited); err == nil {
@@ -194,6 +365,13 @@ func (fr *FFmpegRecorder) Recording(ctx context.Context) (io.ReadCloser, *Record
 		return nil, nil, fmt.Errorf("recording still in progress, please call stop first")
 	}
 
+	fr.mu.Lock()
+	uploadURL, startTime, endTime := fr.uploadURL, fr.startTime, fr.endTime
+	fr.mu.Unlock()
+	if uploadURL != "" {
+		return nil, &RecordingMetadata{StartTime: startTime, EndTime: endTime, URL: uploadURL}, nil
+	}
+
 	file, err := os.Open(fr.outputPath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to open recording file: %w", err)
@@ -215,62 +393,449 @@ func (fr *FFmpegRecorder) Recording(ctx context.Context) (io.ReadCloser, *Record
 	}, nil
 }
 
-// ffmpegArgs generates platform-specific ffmpeg command line arguments.
-func ffmpegArgs(params FFmpegRecordingParams, outputPath string) ([]string, error) {
+// Progress returns the most recent ffmpeg -progress snapshot. It returns an error if the
+// recording was never started, mirroring Recording()'s not-recording error.
+func (fr *FFmpegRecorder) Progress(ctx context.Context) (RecordingProgress, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.cmd == nil {
+		return RecordingProgress{}, fmt.Errorf("no recording in progress")
+	}
+	return fr.progress, nil
+}
+
+// idleSince reports how long it's been since this recorder last showed activity: the most
+// recent progress update if one has arrived, otherwise its start time. StartIdleReaper uses it
+// to detect a recording abandoned by a crashed client.
+func (fr *FFmpegRecorder) idleSince(now time.Time) time.Duration {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	last := fr.startTime
+	if !fr.progress.UpdatedAt.IsZero() {
+		last = fr.progress.UpdatedAt
+	}
+	return now.Sub(last)
+}
+
+// durationExceeded reports whether this recording has run longer than its configured
+// params.MaxDuration, if one was set.
+func (fr *FFmpegRecorder) durationExceeded(now time.Time) bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.params.MaxDuration == nil {
+		return false
+	}
+	return now.Sub(fr.startTime) > *fr.params.MaxDuration
+}
+
+// AddBroadcastTarget attaches target to an already-running recording. A running tee muxer
+// can't gain an output, so this reuses the same phased gracefulShutdown Stop() does, renames
+// the in-progress output file aside so it isn't overwritten, then restarts ffmpeg with target
+// added to the output set.
+func (fr *FFmpegRecorder) AddBroadcastTarget(ctx context.Context, target BroadcastTarget) error {
+	fr.mu.Lock()
+	if fr.cmd == nil {
+		fr.mu.Unlock()
+		return fmt.Errorf("no recording in progress")
+	}
+	fr.mu.Unlock()
+
+	if err := fr.gracefulShutdown(ctx); err != nil {
+		return fmt.Errorf("failed to stop recording before adding broadcast target: %w", err)
+	}
+
+	segmentPath := fr.nextSegmentPath()
+	if err := os.Rename(fr.outputPath, segmentPath); err != nil {
+		return fmt.Errorf("failed to preserve in-progress segment: %w", err)
+	}
+
+	fr.mu.Lock()
+	fr.params.BroadcastTargets = append(fr.params.BroadcastTargets, target)
+	fr.cmd = nil
+	fr.mu.Unlock()
+
+	if err := fr.Start(ctx); err != nil {
+		return fmt.Errorf("failed to restart recording with broadcast target: %w", err)
+	}
+	return nil
+}
+
+// nextSegmentPath returns a timestamped sibling of outputPath, so AddBroadcastTarget can
+// rename the in-progress file aside before Start() begins writing outputPath again from
+// scratch.
+func (fr *FFmpegRecorder) nextSegmentPath() string {
+	ext := filepath.Ext(fr.outputPath)
+	base := strings.TrimSuffix(fr.outputPath, ext)
+	return fmt.Sprintf("%s.%d%s", base, time.Now().Unix(), ext)
+}
+
+// HLSPlaylistPath returns the path to the live HLS master playlist and whether HLS output is
+// enabled for this recorder. It implements the hlsCapable interface api.GetRecordingPlaylist
+// type-asserts Recorder values against, so recorders without HLS support need no changes.
+func (fr *FFmpegRecorder) HLSPlaylistPath() (string, bool) {
+	if fr.hlsDir == "" {
+		return "", false
+	}
+	return filepath.Join(fr.hlsDir, hlsPlaylistName), true
+}
+
+// HLSSegmentPath returns the path to a named HLS segment file. It rejects any name that isn't
+// exactly the segment_NNNNN.ts pattern ffmpeg itself produces, so it can't be used to read
+// arbitrary files out of hlsDir.
+func (fr *FFmpegRecorder) HLSSegmentPath(name string) (string, bool) {
+	if fr.hlsDir == "" || !hlsSegmentNameRegex.MatchString(name) {
+		return "", false
+	}
+	return filepath.Join(fr.hlsDir, name), true
+}
+
+// ffmpegArgs generates platform-specific ffmpeg command line arguments. hlsDir is non-empty
+// when a live HLS ladder should be muxed alongside outputPath's mp4.
+func ffmpegArgs(params FFmpegRecordingParams, outputPath, hlsDir string) ([]string, error) {
+	var input []string
 	switch runtime.GOOS {
 	case "darwin":
-		return []string{
+		input = []string{
 			// Input configuration - Use AVFoundation for macOS screen capture
 			"-f", "avfoundation",
 			"-framerate", strconv.Itoa(*params.FrameRate),
 			"-pixel_format", "nv12",
 			"-i", fmt.Sprintf("%d:none", *params.DisplayNum), // Screen capture, no audio
-
-			// Video encoding
-			"-c:v", "libx264",
-
-			// Timestamp handling for reliable playback
-			"-use_wallclock_as_timestamps", "1", // Use system time instead of input stream time
-			"-reset_timestamps", "1", // Reset timestamps to start from zero
-			"-avoid_negative_ts", "make_zero", // Convert negative timestamps to zero
-
-			// Error handling
-			"-xerror", // Exit on any error
-
-			// Output configuration for data safety
-			"-movflags", "+frag_keyframe+empty_moov", // Enable fragmented MP4 for data safety
-			"-frag_duration", "2000000", // 2-second fragments (in microseconds)
-			"-fs", fmt.Sprintf("%dM", *params.MaxSizeInMB), // File size limit
-			"-y", // Overwrite output file if it exists
-			outputPath,
-		}, nil
+		}
 	case "linux":
-		return []string{
+		input = []string{
 			// Input configuration - Use X11 screen capture for Linux
 			"-f", "x11grab",
 			"-framerate", strconv.Itoa(*params.FrameRate),
 			"-i", fmt.Sprintf(":%d", *params.DisplayNum), // X11 display
+		}
+	default:
+		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+
+	backend := encoderBackendFor(resolveEncoder(params.Encoder))
+
+	// hwaccel flags (e.g. -vaapi_device, -hwaccel cuda) are input options: ffmpeg only
+	// recognizes them ahead of the -i they apply to.
+	args := append(append([]string{}, backend.hwaccelArgs...), input...)
+	args = append(args, backend.codecArgs...)
+	args = append(args,
+		// Timestamp handling for reliable playback
+		"-use_wallclock_as_timestamps", "1", // Use system time instead of input stream time
+		"-reset_timestamps", "1", // Reset timestamps to start from zero
+		"-avoid_negative_ts", "make_zero", // Convert negative timestamps to zero
+
+		// Error handling
+		"-xerror", // Exit on any error
+
+		// Progress reporting - machine-readable key=value lines on fd 3, read by readProgress
+		"-progress", "pipe:3",
+	)
+
+	return append(args, outputArgs(params, outputPath, hlsDir)...), nil
+}
+
+// encoderBackend bundles the ffmpeg arguments one video encoder needs: hwaccelArgs must
+// precede the -i they apply to, codecArgs (the -c:v plus that encoder's own filter chain and
+// rate-control flags) replace the old hardcoded "-c:v libx264".
+type encoderBackend struct {
+	hwaccelArgs []string
+	codecArgs   []string
+}
 
-			// Video encoding
-			"-c:v", "libx264",
+func encoderBackendFor(enc Encoder) encoderBackend {
+	switch enc {
+	case EncoderVAAPI:
+		_, renderNode := probeEncoders()
+		return encoderBackend{
+			hwaccelArgs: []string{"-vaapi_device", renderNode},
+			codecArgs:   []string{"-vf", "format=nv12,hwupload", "-c:v", "h264_vaapi"},
+		}
+	case EncoderNVENC:
+		return encoderBackend{
+			hwaccelArgs: []string{"-hwaccel", "cuda", "-hwaccel_output_format", "cuda"},
+			codecArgs:   []string{"-c:v", "h264_nvenc", "-rc", "vbr", "-cq", "23"},
+		}
+	case EncoderVideoToolbox:
+		return encoderBackend{codecArgs: []string{"-c:v", "h264_videotoolbox", "-b:v", "4M"}}
+	case EncoderCopy:
+		return encoderBackend{codecArgs: []string{"-c:v", "copy"}}
+	default: // EncoderLibx264, and anything resolveEncoder didn't recognize
+		return encoderBackend{codecArgs: []string{"-c:v", "libx264"}}
+	}
+}
 
-			// Timestamp handling for reliable playback
-			"-use_wallclock_as_timestamps", "1", // Use system time instead of input stream time
-			"-reset_timestamps", "1", // Reset timestamps to start from zero
-			"-avoid_negative_ts", "make_zero", // Convert negative timestamps to zero
+// availableEncoders/vaapiRenderNode cache probeEncoders' result for the process lifetime:
+// ffmpeg's encoder list and the container's /dev/dri nodes can't change after it started.
+var (
+	probeEncodersOnce sync.Once
+	availableEncoders map[Encoder]bool
+	vaapiRenderNode   string
+)
 
-			// Error handling
-			"-xerror", // Exit on any error
+// probeEncoders runs `ffmpeg -hide_banner -encoders` to see which hardware encoders this
+// ffmpeg build supports, and checks /dev/dri/renderD* for VAAPI's render node, since having
+// the encoder compiled in isn't enough without a GPU device node passed into the container.
+func probeEncoders() (map[Encoder]bool, string) {
+	probeEncodersOnce.Do(func() {
+		availableEncoders = make(map[Encoder]bool)
 
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-encoders").CombinedOutput()
+		if err != nil {
+			return
+		}
+		listed := string(out)
+
+		nodes, _ := filepath.Glob("/dev/dri/renderD*")
+		if len(nodes) > 0 {
+			vaapiRenderNode = nodes[0]
+		}
+
+		availableEncoders[EncoderVAAPI] = strings.Contains(listed, string(EncoderVAAPI)) && vaapiRenderNode != ""
+		availableEncoders[EncoderNVENC] = strings.Contains(listed, string(EncoderNVENC))
+		availableEncoders[EncoderVideoToolbox] = strings.Contains(listed, string(EncoderVideoToolbox))
+	})
+	return availableEncoders, vaapiRenderNode
+}
+
+// resolveEncoder turns a requested Encoder into the concrete backend to actually use.
+// EncoderAuto (and the zero value) try, in priority order, whichever hardware backend this
+// platform and container actually have available - VAAPI then NVENC on Linux, VideoToolbox on
+// macOS - falling back to EncoderLibx264. An explicit request for a backend that isn't
+// available also falls back to EncoderLibx264 rather than failing the recording outright.
+func resolveEncoder(requested Encoder) Encoder {
+	available, _ := probeEncoders()
+
+	switch requested {
+	case EncoderLibx264, EncoderCopy:
+		return requested
+	case "", EncoderAuto:
+		priority := []Encoder{EncoderVAAPI, EncoderNVENC}
+		if runtime.GOOS == "darwin" {
+			priority = []Encoder{EncoderVideoToolbox}
+		}
+		for _, enc := range priority {
+			if available[enc] {
+				return enc
+			}
+		}
+		return EncoderLibx264
+	default:
+		if available[requested] {
+			return requested
+		}
+		return EncoderLibx264
+	}
+}
+
+// outputArgs builds the trailing "-f ... target" section of the ffmpeg invocation. With
+// neither HLS nor any BroadcastTargets configured this is just the mp4 target, same as before;
+// with either, every target is muxed from the single encode via ffmpeg's tee pseudo-muxer
+// rather than spawning a second ffmpeg process per target.
+func outputArgs(params FFmpegRecordingParams, outputPath, hlsDir string) []string {
+	mp4Opts := fmt.Sprintf("movflags=+frag_keyframe+empty_moov:frag_duration=2000000:fs=%dM", *params.MaxSizeInMB)
+
+	if hlsDir == "" && len(params.BroadcastTargets) == 0 {
+		return []string{
 			// Output configuration for data safety
 			"-movflags", "+frag_keyframe+empty_moov", // Enable fragmented MP4 for data safety
 			"-frag_duration", "2000000", // 2-second fragments (in microseconds)
 			"-fs", fmt.Sprintf("%dM", *params.MaxSizeInMB), // File size limit
 			"-y", // Overwrite output file if it exists
 			outputPath,
-		}, nil
-	default:
-		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+		}
+	}
+
+	targets := []string{fmt.Sprintf("[%s]%s", mp4Opts, outputPath)}
+
+	if hlsDir != "" {
+		segmentSeconds := defaultHLSSegmentSeconds
+		if params.HLS.SegmentSeconds != nil {
+			segmentSeconds = *params.HLS.SegmentSeconds
+		}
+		keepSegments := defaultHLSKeepSegments
+		if params.HLS.KeepSegments != nil {
+			keepSegments = *params.HLS.KeepSegments
+		}
+
+		// hls_flags=delete_segments prunes segment files off disk as they age out of the
+		// hls_list_size window, so Stop()'s clean ffmpeg exit is what writes #EXT-X-ENDLIST.
+		hlsOpts := fmt.Sprintf(
+			"f=hls:hls_time=%d:hls_list_size=%d:hls_flags=delete_segments:hls_segment_filename=%s",
+			segmentSeconds, keepSegments, filepath.Join(hlsDir, "segment_%05d.ts"),
+		)
+		targets = append(targets, fmt.Sprintf("[%s]%s", hlsOpts, filepath.Join(hlsDir, hlsPlaylistName)))
+	}
+
+	for _, bt := range params.BroadcastTargets {
+		opts := fmt.Sprintf("f=%s", bt.Format)
+		if bt.Bitrate != nil {
+			opts += fmt.Sprintf(":b:v=%s", *bt.Bitrate)
+		}
+		targets = append(targets, fmt.Sprintf("[%s]%s", opts, bt.URL))
+	}
+
+	return []string{
+		"-y",
+		"-f", "tee",
+		strings.Join(targets, "|"),
+	}
+}
+
+// readProgress parses ffmpeg's -progress key=value stream, one field per line terminated by a
+// progress=continue|end line, into snapshots stored under fr.mu so Progress() never blocks on
+// the pipe. Each snapshot is also logged at debug level, since this repo surfaces metrics
+// through structured log fields rather than a separate metrics client.
+func (fr *FFmpegRecorder) readProgress(ctx context.Context, r io.ReadCloser) {
+	log := logger.FromContext(ctx)
+	defer r.Close()
+
+	var snap RecordingProgress
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "frame":
+			snap.Frame, _ = strconv.ParseInt(value, 10, 64)
+		case "fps":
+			snap.FPS, _ = strconv.ParseFloat(value, 64)
+		case "bitrate":
+			snap.Bitrate = value
+		case "total_size":
+			snap.TotalSize, _ = strconv.ParseInt(value, 10, 64)
+		case "out_time_us":
+			snap.OutTimeUs, _ = strconv.ParseInt(value, 10, 64)
+		case "dup_frames":
+			snap.DupFrames, _ = strconv.ParseInt(value, 10, 64)
+		case "drop_frames":
+			snap.DropFrames, _ = strconv.ParseInt(value, 10, 64)
+		case "speed":
+			snap.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+		case "progress":
+			snap.Done = value == "end"
+			snap.UpdatedAt = time.Now()
+
+			fr.mu.Lock()
+			fr.progress = snap
+			fr.mu.Unlock()
+
+			log.Debug("ffmpeg progress",
+				"frame", snap.Frame, "fps", snap.FPS, "bitrate", snap.Bitrate,
+				"total_size", snap.TotalSize, "out_time_us", snap.OutTimeUs,
+				"dup_frames", snap.DupFrames, "drop_frames", snap.DropFrames,
+				"speed", snap.Speed, "done", snap.Done,
+			)
+
+			if snap.Done {
+				return
+			}
+			snap = RecordingProgress{}
+		}
+	}
+}
+
+// tailUpload streams outputPath to fr.sink under uploadID as ffmpeg writes it, tail -f style:
+// read whatever bytes are available, and when a read comes up short, either ffmpeg is still
+// running (sleep and retry) or fr.exited has closed (drain what's left and finalize). The
+// fragmented mp4 output (+frag_keyframe+empty_moov) already yields fragments aligned to
+// -frag_duration boundaries, so reading mid-write never splits a part mid-fragment. It completes
+// the multipart upload on a clean ffmpeg exit, or aborts it if ffmpeg exited with an error.
+func (fr *FFmpegRecorder) tailUpload(ctx context.Context, uploadID string) {
+	log := logger.FromContext(ctx)
+
+	f, err := os.Open(fr.outputPath)
+	if err != nil {
+		log.Error("failed to open recording for upload tailing", "err", err)
+		if abortErr := fr.sink.Abort(ctx, fr.uploadKey, uploadID); abortErr != nil {
+			log.Error("failed to abort recording upload", "err", abortErr)
+		}
+		return
+	}
+	defer f.Close()
+
+	var (
+		parts      []UploadedPart
+		partNumber = 1
+		buf        = make([]byte, 0, uploadPartSizeBytes)
+		chunk      = make([]byte, 1<<20)
+	)
+
+	flush := func() {
+		if len(buf) == 0 {
+			return
+		}
+		etag, err := fr.sink.UploadPart(ctx, fr.uploadKey, uploadID, partNumber, buf)
+		if err != nil {
+			log.Error("failed to upload recording part", "part", partNumber, "err", err)
+			return
+		}
+		parts = append(parts, UploadedPart{PartNumber: partNumber, ETag: etag})
+		partNumber++
+		buf = make([]byte, 0, uploadPartSizeBytes)
+	}
+
+	for {
+		n, err := f.Read(chunk)
+		if n > 0 {
+			buf = append(buf, chunk[:n]...)
+			if len(buf) >= uploadPartSizeBytes {
+				flush()
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			log.Error("failed to read recording file for upload", "err", err)
+			if abortErr := fr.sink.Abort(ctx, fr.uploadKey, uploadID); abortErr != nil {
+				log.Error("failed to abort recording upload", "err", abortErr)
+			}
+			return
+		}
+
+		select {
+		case <-fr.exited:
+			for {
+				n, err := f.Read(chunk)
+				if n > 0 {
+					buf = append(buf, chunk[:n]...)
+				}
+				if err != nil {
+					break
+				}
+			}
+			flush()
+
+			fr.mu.Lock()
+			ffmpegErr := fr.ffmpegErr
+			fr.mu.Unlock()
+
+			if ffmpegErr != nil {
+				log.Warn("ffmpeg exited with error, aborting recording upload", "err", ffmpegErr)
+				if abortErr := fr.sink.Abort(ctx, fr.uploadKey, uploadID); abortErr != nil {
+					log.Error("failed to abort recording upload", "err", abortErr)
+				}
+				return
+			}
+
+			url, err := fr.sink.Complete(ctx, fr.uploadKey, uploadID, parts)
+			if err != nil {
+				log.Error("failed to complete recording upload", "err", err)
+				return
+			}
+			fr.mu.Lock()
+			fr.uploadURL = url
+			fr.mu.Unlock()
+			log.Info("completed recording upload", "key", fr.uploadKey, "parts", len(parts))
+			return
+		default:
+			time.Sleep(500 * time.Millisecond)
+		}
 	}
 }
 
@@ -284,16 +849,29 @@ func (fr *FFmpegRecorder) waitForCommand(ctx context.Context) {
 
 	// update internal state and cleanup
 	fr.mu.Lock()
-	defer fr.mu.Unlock()
 	fr.ffmpegErr = err
 	fr.exitCode = fr.cmd.ProcessState.ExitCode()
 	fr.endTime = time.Now()
 	close(fr.exited)
+	exitCode := fr.exitCode
+	manager := fr.manager
+	fr.mu.Unlock()
 
 	if err != nil {
-		log.Info("ffmpeg process completed with error", "err", err, "exitCode", fr.exitCode)
+		log.Info("ffmpeg process completed with error", "err", err, "exitCode", exitCode)
 	} else {
-		log.Info("ffmpeg process completed successfully", "exitCode", fr.exitCode)
+		log.Info("ffmpeg process completed successfully", "exitCode", exitCode)
+	}
+
+	// A recorder registered with a manager deregisters itself on terminal exit, so a client that
+	// crashes between StartRecording and StopRecording doesn't leave a zombie entry in
+	// fm.recorders forever. This must happen with fr.mu released: DeregisterRecorder takes
+	// fm.mu, and reapIdle takes fm.mu then fr.mu, so holding fr.mu here too would let the two
+	// collide on opposite lock orders and deadlock.
+	if manager != nil {
+		if err := manager.DeregisterRecorder(ctx, fr); err != nil {
+			log.Error("failed to deregister recorder on exit", "err", err)
+		}
 	}
 }
 
@@ -419,6 +997,11 @@ func (fm *FFmpegManager) RegisterRecorder(ctx context.Context, recorder Recorder
 		return fmt.Errorf("recorder with id '%s' already exists", recorder.ID())
 	}
 
+	// Let an FFmpegRecorder deregister itself on terminal exit; see waitForCommand.
+	if fr, ok := recorder.(*FFmpegRecorder); ok {
+		fr.manager = fm
+	}
+
 	fm.recorders[recorder.ID()] = recorder
 	log.Info("registered new recorder", "id", recorder.ID())
 	return nil
@@ -448,3 +1031,72 @@ func (fm *FFmpegManager) StopAll(ctx context.Context) error {
 
 	return nil
 }
+
+// idleReapable is implemented by recorders StartIdleReaper knows how to evaluate for
+// abandonment; today only FFmpegRecorder does. A recorder that doesn't implement it is left
+// alone by the reaper, the same optional-capability pattern api.hlsCapable and friends use.
+type idleReapable interface {
+	idleSince(now time.Time) time.Duration
+	durationExceeded(now time.Time) bool
+}
+
+// StartIdleReaper runs until ctx is done, periodically stopping any recorder that looks
+// abandoned: one with no progress activity (or, before the first progress update, no elapsed
+// time since start) within idleAfter, or one that's exceeded its own params.MaxDuration. This is
+// the safety net for a client that crashes between StartRecording and StopRecording: without it
+// the ffmpeg process runs until it hits -fs and its entry in fm.recorders lives forever. The
+// ticker loop mirrors the Stream.Run pattern go-vod uses for the same kind of "garbage collect
+// what the client abandoned" housekeeping.
+func (fm *FFmpegManager) StartIdleReaper(ctx context.Context, interval, idleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fm.reapIdle(ctx, idleAfter)
+		}
+	}
+}
+
+func (fm *FFmpegManager) reapIdle(ctx context.Context, idleAfter time.Duration) {
+	log := logger.FromContext(ctx)
+
+	// Snapshot under fm.mu, then release before touching any recorder: recorder.IsRecording,
+	// idleSince, durationExceeded, and Stop all take fr.mu, and a recorder exiting naturally
+	// during this sweep takes fr.mu then fm.mu (waitForCommand's deregister). Holding fm.mu
+	// across those calls would collide with that order and deadlock, and would also block every
+	// other manager operation for as long as Stop's phased shutdown takes (up to ~8s).
+	fm.mu.Lock()
+	recorders := make(map[string]Recorder, len(fm.recorders))
+	for id, recorder := range fm.recorders {
+		recorders[id] = recorder
+	}
+	fm.mu.Unlock()
+
+	now := time.Now()
+	for id, recorder := range recorders {
+		if !recorder.IsRecording(ctx) {
+			continue
+		}
+		reapable, ok := recorder.(idleReapable)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case reapable.durationExceeded(now):
+			log.Warn("stopping recorder that exceeded its max duration", "id", id)
+		case reapable.idleSince(now) > idleAfter:
+			log.Warn("stopping idle recorder", "id", id, "idle_for", reapable.idleSince(now))
+		default:
+			continue
+		}
+
+		if err := recorder.Stop(ctx); err != nil {
+			log.Error("failed to stop reaped recorder", "id", id, "err", err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3UploadSink implements UploadSink against an S3-compatible bucket, one multipart upload per
+// key. URLSigner produces the URL Complete returns; the client itself only needs PutObject-style
+// permissions plus the multipart upload actions.
+type S3UploadSink struct {
+	Client *s3.Client
+	Bucket string
+	// URLSigner builds the URL Complete returns for key, e.g. a presigned GET or a public CDN
+	// URL, depending on how the bucket is set up.
+	URLSigner func(key string) (string, error)
+}
+
+func NewS3UploadSink(client *s3.Client, bucket string, urlSigner func(key string) (string, error)) *S3UploadSink {
+	return &S3UploadSink{Client: client, Bucket: bucket, URLSigner: urlSigner}
+}
+
+func (s *S3UploadSink) Begin(ctx context.Context, key string) (string, error) {
+	out, err := s.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String("video/mp4"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+func (s *S3UploadSink) UploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (string, error) {
+	out, err := s.Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.Bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(int32(partNumber)),
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+func (s *S3UploadSink) Complete(ctx context.Context, key, uploadID string, parts []UploadedPart) (string, error) {
+	completedParts := make([]types.CompletedPart, len(parts))
+	for i, p := range parts {
+		completedParts[i] = types.CompletedPart{
+			PartNumber: aws.Int32(int32(p.PartNumber)),
+			ETag:       aws.String(p.ETag),
+		}
+	}
+
+	if _, err := s.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: completedParts,
+		},
+	}); err != nil {
+		return "", fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	url, err := s.URLSigner(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign recording url: %w", err)
+	}
+	return url, nil
+}
+
+func (s *S3UploadSink) Abort(ctx context.Context, key, uploadID string) error {
+	if _, err := s.Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
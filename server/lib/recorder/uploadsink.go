@@ -0,0 +1,29 @@
+package recorder
+
+import "context"
+
+// UploadSink streams a recording to object storage as ffmpeg produces it, so a recording's
+// size ceiling is MaxSizeInMB rather than local disk. It maps directly onto a multipart upload
+// API: Begin/UploadPart/Complete/Abort correspond to CreateMultipartUpload/UploadPart/
+// CompleteMultipartUpload/AbortMultipartUpload.
+type UploadSink interface {
+	// Begin starts a new multipart upload for key and returns an uploadID the caller threads
+	// through the rest of the calls below.
+	Begin(ctx context.Context, key string) (uploadID string, err error)
+	// UploadPart pushes one part. Parts must be uploaded in increasing partNumber order,
+	// each at least 5MB except the last.
+	UploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (etag string, err error)
+	// Complete finalizes the multipart upload and returns a URL the finished recording can be
+	// fetched from.
+	Complete(ctx context.Context, key, uploadID string, parts []UploadedPart) (url string, err error)
+	// Abort discards uploadID and any parts already uploaded. Called when the recording fails
+	// or is force-killed mid-upload.
+	Abort(ctx context.Context, key, uploadID string) error
+}
+
+// UploadedPart records one part accepted by UploadPart, in the form Complete needs to
+// reassemble the object.
+type UploadedPart struct {
+	PartNumber int
+	ETag       string
+}
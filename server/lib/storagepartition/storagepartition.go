@@ -0,0 +1,313 @@
+// Package storagepartition exports and imports a subset of a Chromium user-data profile scoped
+// to a set of origins, instead of round-tripping the entire directory via the generic
+// DownloadDirZip/WriteFile+unzip path the e2e persistence tests use today (see
+// server/e2e/e2e_persist_login_test.go's downloadUserDataDir/restoreUserDataDir). Of the stores
+// Chromium keeps under a profile, only IndexedDB is laid out one-directory-per-origin on disk
+// (see IndexedDBDirName), so that's the only store ExportStorageForOrigins can filter precisely
+// today; Cookies, Local Storage, Cache Storage and Service Worker registrations are shared
+// stores (one sqlite/LevelDB instance for every origin) and are carried through whole. See
+// server/lib/cookiejar for per-row Cookies filtering, used to narrow the cookies entry further.
+package storagepartition
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultProfileDir is the Chromium profile directory name used by this repo's launcher (see
+// server/cmd/chromium-launcher/main.go's --user-data-dir; no --profile-directory flag is passed,
+// so Chromium defaults to "Default").
+const DefaultProfileDir = "Default"
+
+// MergePolicy controls how ImportStorage reconciles an archive's entries against whatever is
+// already on disk in the destination profile.
+type MergePolicy string
+
+const (
+	// MergeReplace discards whatever's already on disk for an entry and writes the archive's.
+	MergeReplace MergePolicy = "replace"
+	// MergeNewerWins keeps whichever of the archive's or the on-disk copy of an entry has the
+	// more recent modification time, entry by entry.
+	MergeNewerWins MergePolicy = "merge_newer_wins"
+	// MergeExistingWins keeps the on-disk copy of anything that already exists, only writing
+	// entries the destination profile doesn't have yet.
+	MergeExistingWins MergePolicy = "merge_existing_wins"
+)
+
+// Sources locates the on-disk Chromium profile this package reads from and writes to.
+// ProfileDir defaults to DefaultProfileDir when empty.
+type Sources struct {
+	UserDataDir string
+	ProfileDir  string
+}
+
+func (src Sources) profileDir() string {
+	if src.ProfileDir == "" {
+		return DefaultProfileDir
+	}
+	return src.ProfileDir
+}
+
+// Tar entry names/prefixes under which ExportStorageForOrigins stores each kind of state, so
+// ImportStorage knows where to recreate it relative to a (possibly different) destination
+// profile dir.
+const (
+	cookiesEntryName         = "Cookies"
+	indexedDBEntryPrefix     = "indexeddb/"
+	localStorageEntryPrefix  = "localstorage/"
+	cacheStorageEntryPrefix  = "cachestorage/"
+	serviceWorkerEntryPrefix = "serviceworker/"
+)
+
+// relative paths of each shared store within a Chromium profile directory.
+const (
+	cookiesRelPath       = "Cookies"
+	indexedDBRelPath     = "IndexedDB"
+	localStorageRelPath  = "Local Storage"
+	cacheStorageRelPath  = "Cache Storage"
+	serviceWorkerRelPath = "Service Worker"
+)
+
+// ExportStorageForOrigins writes a zstd-compressed tarball to w containing: the Cookies sqlite
+// file, every IndexedDB per-origin LevelDB directory matching one of origins, and the shared
+// Local Storage/Cache Storage/Service Worker stores in their entirety (see the package doc for
+// why those three can't be filtered by origin). origins must be full origin strings (e.g.
+// "https://example.com"); a malformed origin is skipped rather than failing the whole export,
+// since one bad entry shouldn't block exporting the rest.
+func ExportStorageForOrigins(src Sources, origins []string, w io.Writer) error {
+	profileDir := filepath.Join(src.UserDataDir, src.profileDir())
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd writer: %w", err)
+	}
+	defer zw.Close()
+
+	tw := tar.NewWriter(zw)
+	defer tw.Close()
+
+	if err := addFile(tw, filepath.Join(profileDir, cookiesRelPath), cookiesEntryName); err != nil {
+		return fmt.Errorf("failed to add cookies: %w", err)
+	}
+
+	indexedDBDir := filepath.Join(profileDir, indexedDBRelPath)
+	for _, origin := range origins {
+		dirName, err := IndexedDBDirName(origin)
+		if err != nil {
+			continue
+		}
+		if err := addDir(tw, filepath.Join(indexedDBDir, dirName), indexedDBEntryPrefix+dirName+"/"); err != nil {
+			return fmt.Errorf("failed to add indexeddb for %s: %w", origin, err)
+		}
+	}
+
+	if err := addDir(tw, filepath.Join(profileDir, localStorageRelPath), localStorageEntryPrefix); err != nil {
+		return fmt.Errorf("failed to add local storage: %w", err)
+	}
+	if err := addDir(tw, filepath.Join(profileDir, cacheStorageRelPath), cacheStorageEntryPrefix); err != nil {
+		return fmt.Errorf("failed to add cache storage: %w", err)
+	}
+	if err := addDir(tw, filepath.Join(profileDir, serviceWorkerRelPath), serviceWorkerEntryPrefix); err != nil {
+		return fmt.Errorf("failed to add service worker: %w", err)
+	}
+
+	return nil
+}
+
+// ImportStorage reads a tarball produced by ExportStorageForOrigins and merges it into src's
+// profile according to policy. IndexedDB directories are merged one origin at a time; the
+// shared-store entries (Cookies, Local Storage, Cache Storage, Service Worker) are merged as a
+// single unit each, since policy can't be applied at finer granularity without parsing their
+// internal row/key format (see server/lib/cookiejar for the one exception: Cookies).
+func ImportStorage(src Sources, r io.Reader, policy MergePolicy) error {
+	profileDir := filepath.Join(src.UserDataDir, src.profileDir())
+
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd reader: %w", err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		destRel, err := destPathForEntry(hdr.Name)
+		if err != nil {
+			return fmt.Errorf("failed to map archive entry %q: %w", hdr.Name, err)
+		}
+		destPath := filepath.Join(profileDir, destRel)
+
+		if hdr.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(destPath, 0o755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", destPath, err)
+			}
+			continue
+		}
+
+		if err := writeEntry(tr, hdr, destPath, policy); err != nil {
+			return fmt.Errorf("failed to write %s: %w", destPath, err)
+		}
+	}
+}
+
+// destPathForEntry maps a tar entry name back to its path relative to the Chromium profile dir.
+func destPathForEntry(name string) (string, error) {
+	switch {
+	case name == cookiesEntryName:
+		return cookiesRelPath, nil
+	case strings.HasPrefix(name, indexedDBEntryPrefix):
+		return filepath.Join(indexedDBRelPath, strings.TrimPrefix(name, indexedDBEntryPrefix)), nil
+	case strings.HasPrefix(name, localStorageEntryPrefix):
+		return filepath.Join(localStorageRelPath, strings.TrimPrefix(name, localStorageEntryPrefix)), nil
+	case strings.HasPrefix(name, cacheStorageEntryPrefix):
+		return filepath.Join(cacheStorageRelPath, strings.TrimPrefix(name, cacheStorageEntryPrefix)), nil
+	case strings.HasPrefix(name, serviceWorkerEntryPrefix):
+		return filepath.Join(serviceWorkerRelPath, strings.TrimPrefix(name, serviceWorkerEntryPrefix)), nil
+	default:
+		return "", fmt.Errorf("unrecognized entry")
+	}
+}
+
+// writeEntry applies policy for one file entry: MergeExistingWins skips it entirely if destPath
+// already exists, MergeNewerWins skips it if destPath's mtime is at least as new as the entry's,
+// and MergeReplace (or a destPath that doesn't exist yet) always writes it.
+func writeEntry(r io.Reader, hdr *tar.Header, destPath string, policy MergePolicy) error {
+	if info, err := os.Stat(destPath); err == nil {
+		switch policy {
+		case MergeExistingWins:
+			return nil
+		case MergeNewerWins:
+			if !hdr.ModTime.After(info.ModTime()) {
+				return nil
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// singletonLockFiles are the files Chromium's ProcessSingleton leaves directly under
+// --user-data-dir (not inside a specific profile dir) to detect whether another instance already
+// has it open. They're safe to remove whenever Chromium itself isn't running against the profile
+// (e.g. right before ImportStorage/profilesnapshot.Restore swaps in new profile contents ahead of
+// a restart) - otherwise a stale lock from the pre-restore process makes the freshly started
+// Chromium refuse to open its own profile.
+var singletonLockFiles = []string{"SingletonLock", "SingletonSocket", "SingletonCookie"}
+
+// ClearSingletonLocks removes src.UserDataDir's singletonLockFiles, ignoring any that don't
+// exist.
+func ClearSingletonLocks(src Sources) error {
+	for _, name := range singletonLockFiles {
+		if err := os.Remove(filepath.Join(src.UserDataDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// IndexedDBDirName returns the on-disk directory name Chromium uses for origin's IndexedDB
+// store: "<scheme>_<host>_<port>.indexeddb.leveldb", with port 0 when origin doesn't specify one
+// explicitly (matching Chromium's storage::GetIdentifierFromOrigin). Exported so other packages
+// that need to locate a single origin's store (e.g. profilediff) don't duplicate this mapping.
+func IndexedDBDirName(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid origin %q", origin)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "0"
+	}
+	return fmt.Sprintf("%s_%s_%s.indexeddb.leveldb", u.Scheme, u.Hostname(), port), nil
+}
+
+// addFile tars path under name, or does nothing if path doesn't exist (mirrors
+// extsnapshot.addFile: a store that hasn't been created yet shouldn't fail the export).
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// addDir walks dir and tars every regular file under it with entryPrefix prepended to its
+// relative path, or does nothing if dir doesn't exist.
+func addDir(tw *tar.Writer, dir, entryPrefix string) error {
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = entryPrefix + filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
@@ -0,0 +1,265 @@
+package storagestate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/domstorage"
+	"github.com/chromedp/cdproto/indexeddb"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Dump/Restore above move a whole profile's worth of state at once; the functions in this file
+// are their single-record counterparts, for callers seeding or asserting on one specific key
+// (e.g. a test fixturing one piece of app state) without round-tripping the full storageState
+// JSON. They back POST /browser/storage/{indexeddb,localstorage,cookies} in
+// server/cmd/api/api/storage_records.go.
+
+// GetIndexedDBRecord returns store's value for key in database at origin, and whether it was
+// found. Key is matched as a plain string, the same simplification IndexedDBRecord.Key above
+// makes - IndexedDB keys can be numbers, dates, or arrays, but every caller of this package so
+// far only ever uses string keys.
+func GetIndexedDBRecord(ctx context.Context, devtoolsWSURL string, origin, database, store, key string) (string, bool, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	var value string
+	var found bool
+	err := chromedp.Run(cctx,
+		indexeddb.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			entries, _, err := indexeddb.RequestData(origin, database, store).
+				WithKeyRange(stringKeyRange(key)).
+				Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to read indexeddb record: %w", err)
+			}
+			if len(entries) == 0 {
+				return nil
+			}
+			found = true
+			value = string(entries[0].Value.Value)
+			return nil
+		}),
+	)
+	if err != nil {
+		return "", false, err
+	}
+	return value, found, nil
+}
+
+// SetIndexedDBRecord writes store[key] = value in database at origin. CDP's IndexedDB domain has
+// no write command (see Restore's doc above), so this opens a throwaway page navigated to origin
+// and runs the write through its own indexedDB API instead - the same technique
+// server/e2e/e2e_persist_login_test.go's Playwright helpers use today, but as a reusable,
+// properly-escaped library call instead of a hand-built IIFE string at each call site.
+func SetIndexedDBRecord(ctx context.Context, devtoolsWSURL string, origin, database, store, key, value string) error {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	expr, err := putIndexedDBRecordExpr(database, store, key, value)
+	if err != nil {
+		return err
+	}
+
+	return chromedp.Run(cctx,
+		chromedp.Navigate(origin),
+		chromedp.Evaluate(expr, nil, chromedp.EvalAsPromise),
+	)
+}
+
+// DeleteIndexedDBRecord removes store[key] from database at origin via CDP's
+// IndexedDB.deleteObjectStoreEntries - unlike a single-key write, CDP can make this deletion
+// directly, no page context required.
+func DeleteIndexedDBRecord(ctx context.Context, devtoolsWSURL string, origin, database, store, key string) error {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	return chromedp.Run(cctx,
+		indexeddb.Enable(),
+		indexeddb.DeleteObjectStoreEntries(origin, database, store, stringKeyRange(key)),
+	)
+}
+
+func stringKeyRange(key string) *indexeddb.KeyRange {
+	k := &indexeddb.Key{Type: indexeddb.KeyTypeString, String: key}
+	return &indexeddb.KeyRange{Lower: k, Upper: k, LowerOpen: false, UpperOpen: false}
+}
+
+// putIndexedDBRecordExpr renders the page-context JS SetIndexedDBRecord evaluates: open database
+// (creating it and store if neither exists yet), put value under key in store, and
+// resolve/reject the promise chromedp.EvalAsPromise awaits. database/store/key/value are
+// JSON-encoded into the script rather than interpolated as raw strings, so a value containing a
+// quote or backslash can't break out of its literal. Writing to an existing database that lacks
+// store requires a version-bumped open IndexedDB itself doesn't let a plain put() trigger, so
+// that case is reported as an error rather than silently handled.
+func putIndexedDBRecordExpr(database, store, key, value string) (string, error) {
+	dbJSON, err := json.Marshal(database)
+	if err != nil {
+		return "", err
+	}
+	storeJSON, err := json.Marshal(store)
+	if err != nil {
+		return "", err
+	}
+	keyJSON, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`new Promise((resolve, reject) => {
+  const openReq = indexedDB.open(%[1]s);
+  openReq.onerror = () => reject(openReq.error);
+  openReq.onupgradeneeded = () => {
+    if (!openReq.result.objectStoreNames.contains(%[2]s)) openReq.result.createObjectStore(%[2]s);
+  };
+  openReq.onsuccess = () => {
+    const db = openReq.result;
+    if (!db.objectStoreNames.contains(%[2]s)) {
+      db.close();
+      reject(new Error(%[2]s + ' does not exist in ' + %[1]s));
+      return;
+    }
+    const tx = db.transaction(%[2]s, 'readwrite');
+    tx.objectStore(%[2]s).put(%[3]s, %[4]s);
+    tx.oncomplete = () => { db.close(); resolve(true); };
+    tx.onerror = () => { db.close(); reject(tx.error); };
+  };
+})`, dbJSON, storeJSON, valueJSON, keyJSON), nil
+}
+
+// GetLocalStorageItem returns origin's localStorage[key] and whether it was present.
+func GetLocalStorageItem(ctx context.Context, devtoolsWSURL string, origin, key string) (string, bool, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	var value string
+	var found bool
+	err := chromedp.Run(cctx,
+		domstorage.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			items, err := domstorage.GetDOMStorageItems(&domstorage.StorageID{SecurityOrigin: origin, IsLocalStorage: true}).Do(ctx)
+			if err != nil {
+				return nil // no entries recorded for this origin yet - same as "not found".
+			}
+			for _, item := range items {
+				if len(item) == 2 && item[0] == key {
+					value, found = item[1], true
+					return nil
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return "", false, err
+	}
+	return value, found, nil
+}
+
+// SetLocalStorageItem sets origin's localStorage[key] = value via CDP, no page navigation
+// required (unlike IndexedDB, DOMStorage.setDOMStorageItem is a genuine CDP write command).
+func SetLocalStorageItem(ctx context.Context, devtoolsWSURL string, origin, key, value string) error {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	return chromedp.Run(cctx,
+		domstorage.Enable(),
+		domstorage.SetDOMStorageItem(&domstorage.StorageID{SecurityOrigin: origin, IsLocalStorage: true}, key, value),
+	)
+}
+
+// DeleteLocalStorageItem removes origin's localStorage[key].
+func DeleteLocalStorageItem(ctx context.Context, devtoolsWSURL string, origin, key string) error {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	return chromedp.Run(cctx,
+		domstorage.Enable(),
+		domstorage.RemoveDOMStorageItem(&domstorage.StorageID{SecurityOrigin: origin, IsLocalStorage: true}, key),
+	)
+}
+
+// GetCookie returns the cookie named name visible to url, if any.
+func GetCookie(ctx context.Context, devtoolsWSURL string, name, url string) (*Cookie, bool, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	var found *Cookie
+	err := chromedp.Run(cctx,
+		network.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			cookies, err := network.GetCookies().WithUrls([]string{url}).Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get cookies: %w", err)
+			}
+			for _, c := range cookies {
+				if c.Name == name {
+					found = &Cookie{
+						Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path,
+						Expires: c.Expires, HTTPOnly: c.HTTPOnly, Secure: c.Secure,
+						SameSite: c.SameSite.String(),
+					}
+					return nil
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	return found, found != nil, nil
+}
+
+// SetCookie upserts a single cookie via Network.setCookies. url anchors the cookie to a site the
+// way a browser setting document.cookie would; c.Domain/c.Path, if set, narrow it further.
+func SetCookie(ctx context.Context, devtoolsWSURL string, url string, c Cookie) error {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	return chromedp.Run(cctx,
+		network.Enable(),
+		network.SetCookies([]*network.CookieParam{{
+			Name: c.Name, Value: c.Value, URL: url, Domain: c.Domain, Path: c.Path,
+			Expires: cdp.TimeSinceEpoch(c.Expires), HTTPOnly: c.HTTPOnly, Secure: c.Secure,
+			SameSite: parseSameSite(c.SameSite),
+		}}),
+	)
+}
+
+// DeleteCookie removes the cookie named name scoped to url via Network.deleteCookies.
+func DeleteCookie(ctx context.Context, devtoolsWSURL string, name, url string) error {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	return chromedp.Run(cctx,
+		network.Enable(),
+		network.DeleteCookies(name).WithURL(url),
+	)
+}
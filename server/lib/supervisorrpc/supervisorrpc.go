@@ -0,0 +1,354 @@
+// Package supervisorrpc speaks just enough of supervisord's XML-RPC interface (methodCall/
+// methodResponse over HTTP, tunneled through its unix control socket) to start, stop, and query
+// managed programs without shelling out to supervisorctl and scraping its text output - see
+// api.(*ApiService).restartChromium's ProcessExec-based predecessor in
+// server/cmd/api/api/display_layout.go. server/lib/display also has its own private
+// supervisorRPCClient (server/lib/display/supervisor_rpc.go) predating this package, scoped to
+// the handful of fire-and-forget start/stop calls its Xvfb driver needs; this package is the
+// generic, exported version for callers (like the /supervisor and /browser/ready endpoints in
+// server/cmd/api/api) that also need structured process state back, not just success/fault.
+package supervisorrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls supervisord's XML-RPC API over a unix domain socket (supervisord's
+// [unix_http_server] section).
+type Client struct {
+	socketPath string
+}
+
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath}
+}
+
+// ProgramState mirrors one of supervisord's process states (see
+// http://supervisord.org/subprocess.html#process-states). Every value supervisord itself can
+// report is included, even though callers mostly care about STOPPED/STARTING/RUNNING/FATAL.
+type ProgramState string
+
+const (
+	StateStopped  ProgramState = "STOPPED"
+	StateStarting ProgramState = "STARTING"
+	StateRunning  ProgramState = "RUNNING"
+	StateBackoff  ProgramState = "BACKOFF"
+	StateStopping ProgramState = "STOPPING"
+	StateExited   ProgramState = "EXITED"
+	StateFatal    ProgramState = "FATAL"
+	StateUnknown  ProgramState = "UNKNOWN"
+)
+
+// ProcessInfo is supervisor.getProcessInfo's response, decoded into Go types.
+type ProcessInfo struct {
+	Name        string
+	Group       string
+	Description string
+	State       ProgramState
+	Pid         int
+	// Start/Stop are zero unless the process has been started/stopped at least once. Now is the
+	// supervisord server's clock at the time of the call, useful for computing uptime as
+	// Now.Sub(Start).
+	Start, Stop, Now time.Time
+	ExitStatus       int
+	SpawnError       string
+}
+
+// faultNotRunning is supervisord's Faults.NOT_RUNNING code - stopProcess/restarting an already-
+// stopped program returns this, which RestartProcess treats as success rather than an error.
+const faultNotRunning = 70
+
+// GetProcessInfo returns name's current state via supervisor.getProcessInfo.
+func (c *Client) GetProcessInfo(ctx context.Context, name string) (*ProcessInfo, error) {
+	val, err := c.call(ctx, "supervisor.getProcessInfo", name)
+	if err != nil {
+		return nil, err
+	}
+	return processInfoFromValue(val), nil
+}
+
+// StartProcess starts name, waiting for it to leave the STARTING state before returning (mirrors
+// supervisorctl's own default behavior).
+func (c *Client) StartProcess(ctx context.Context, name string) error {
+	_, err := c.call(ctx, "supervisor.startProcess", name, true)
+	return err
+}
+
+// StopProcess stops name, waiting for it to fully exit before returning.
+func (c *Client) StopProcess(ctx context.Context, name string) error {
+	_, err := c.call(ctx, "supervisor.stopProcess", name, true)
+	return err
+}
+
+// RestartProcess stops then starts name, the same two RPCs supervisorctl's "restart" command
+// issues under the hood (supervisord has no single restart RPC). A NOT_RUNNING fault on the stop
+// half - name was already stopped - is swallowed rather than failing the restart.
+func (c *Client) RestartProcess(ctx context.Context, name string) error {
+	if err := c.StopProcess(ctx, name); err != nil {
+		var fault *Fault
+		if !asFault(err, &fault) || fault.Code != faultNotRunning {
+			return fmt.Errorf("failed to stop %s: %w", name, err)
+		}
+	}
+	if err := c.StartProcess(ctx, name); err != nil {
+		return fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetAllProcessInfo returns every program supervisord manages via supervisor.getAllProcessInfo,
+// backing the GET /supervisor/programs listing endpoint - GetProcessInfo only looks up one
+// program by name, with nothing to enumerate the rest.
+func (c *Client) GetAllProcessInfo(ctx context.Context) ([]*ProcessInfo, error) {
+	val, err := c.call(ctx, "supervisor.getAllProcessInfo")
+	if err != nil {
+		return nil, err
+	}
+	if val.Array == nil {
+		return nil, nil
+	}
+	infos := make([]*ProcessInfo, len(val.Array.Values))
+	for i := range val.Array.Values {
+		infos[i] = processInfoFromValue(&val.Array.Values[i])
+	}
+	return infos, nil
+}
+
+// TailProcessStdoutLog reads up to length bytes of name's captured stdout log starting at offset
+// via supervisor.tailProcessStdoutLog, returning the offset to resume from next and whether
+// supervisord's log buffer overflowed (older bytes were dropped) since offset. Calling it with
+// offset 0 and length 0 returns no data but reports the log's current end, letting a caller
+// start tailing from "now" rather than the beginning - see HandleTailSupervisorProgramLog.
+func (c *Client) TailProcessStdoutLog(ctx context.Context, name string, offset, length int) (data string, nextOffset int, overflow bool, err error) {
+	val, err := c.call(ctx, "supervisor.tailProcessStdoutLog", name, offset, length)
+	if err != nil {
+		return "", 0, false, err
+	}
+	if val.Array == nil || len(val.Array.Values) != 3 {
+		return "", 0, false, fmt.Errorf("unexpected tailProcessStdoutLog response shape")
+	}
+	return val.Array.Values[0].asString(), val.Array.Values[1].asInt(), val.Array.Values[2].asBool(), nil
+}
+
+// Fault is an XML-RPC <fault> supervisord returned instead of a normal response.
+type Fault struct {
+	Code    int
+	Message string
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("supervisord fault: %s (code %d)", f.Message, f.Code)
+}
+
+// asFault reports whether err is a *Fault, writing it into *target on success - a small
+// hand-rolled errors.As since this package only ever needs to check for one error type.
+func asFault(err error, target **Fault) bool {
+	f, ok := err.(*Fault)
+	if ok {
+		*target = f
+	}
+	return ok
+}
+
+// call invokes method on supervisord with args (each a string, bool, or int) and returns the
+// single <param><value> of a successful response.
+func (c *Client) call(ctx context.Context, method string, args ...any) (*xmlValue, error) {
+	body, err := marshalMethodCall(method, args)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("dial supervisord socket %s: %w", c.socketPath, err)
+	}
+	defer conn.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://supervisor/RPC2", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("write rpc request: %w", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return nil, fmt.Errorf("read rpc response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed methodResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode rpc response: %w", err)
+	}
+	if parsed.Fault != nil {
+		return nil, parsed.Fault.toFault()
+	}
+	if len(parsed.Params) != 1 {
+		return nil, fmt.Errorf("expected exactly one return value, got %d", len(parsed.Params))
+	}
+	return &parsed.Params[0].Value, nil
+}
+
+// methodResponse mirrors the subset of XML-RPC's <methodResponse> this client needs: either a
+// <params> success payload or a <fault><value><struct> with faultCode/faultString members.
+type methodResponse struct {
+	XMLName xml.Name `xml:"methodResponse"`
+	Params  []struct {
+		Value xmlValue `xml:"value"`
+	} `xml:"params>param"`
+	Fault *xmlStruct `xml:"fault>value>struct"`
+}
+
+// xmlValue is one XML-RPC <value>: at most one of its fields is populated, matching whichever
+// tag was present in the response.
+type xmlValue struct {
+	Int     *int       `xml:"int"`
+	I4      *int       `xml:"i4"`
+	String  *string    `xml:"string"`
+	Boolean *int       `xml:"boolean"`
+	Struct  *xmlStruct `xml:"struct"`
+	Array   *xmlArray  `xml:"array"`
+}
+
+// xmlArray is an XML-RPC <array>, as returned by supervisor.getAllProcessInfo (an array of
+// structs) and supervisor.tailProcessStdoutLog (a 3-element [data, offset, overflow] array).
+type xmlArray struct {
+	Values []xmlValue `xml:"data>value"`
+}
+
+type xmlStruct struct {
+	Members []xmlMember `xml:"member"`
+}
+
+type xmlMember struct {
+	Name  string   `xml:"name"`
+	Value xmlValue `xml:"value"`
+}
+
+func (s *xmlStruct) member(name string) *xmlValue {
+	for i := range s.Members {
+		if s.Members[i].Name == name {
+			return &s.Members[i].Value
+		}
+	}
+	return nil
+}
+
+func (v *xmlValue) asString() string {
+	if v == nil {
+		return ""
+	}
+	if v.String != nil {
+		return *v.String
+	}
+	return ""
+}
+
+func (v *xmlValue) asInt() int {
+	if v == nil {
+		return 0
+	}
+	if v.Int != nil {
+		return *v.Int
+	}
+	if v.I4 != nil {
+		return *v.I4
+	}
+	return 0
+}
+
+func (v *xmlValue) asBool() bool {
+	if v == nil || v.Boolean == nil {
+		return false
+	}
+	return *v.Boolean != 0
+}
+
+func (s *xmlStruct) toFault() *Fault {
+	return &Fault{Code: s.member("faultCode").asInt(), Message: s.member("faultString").asString()}
+}
+
+// processInfoFromValue decodes a supervisor.getProcessInfo response's <struct> into a
+// ProcessInfo. Unix epoch members (start/stop/now) of 0 decode to the zero time.Time, matching
+// supervisord's own convention for "never happened".
+func processInfoFromValue(v *xmlValue) *ProcessInfo {
+	st := v.Struct
+	if st == nil {
+		return &ProcessInfo{State: StateUnknown}
+	}
+	return &ProcessInfo{
+		Name:        st.member("name").asString(),
+		Group:       st.member("group").asString(),
+		Description: st.member("description").asString(),
+		State:       stateFromName(st.member("statename").asString()),
+		Pid:         st.member("pid").asInt(),
+		Start:       epochToTime(st.member("start").asInt()),
+		Stop:        epochToTime(st.member("stop").asInt()),
+		Now:         epochToTime(st.member("now").asInt()),
+		ExitStatus:  st.member("exitstatus").asInt(),
+		SpawnError:  st.member("spawnerr").asString(),
+	}
+}
+
+func epochToTime(epoch int) time.Time {
+	if epoch == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(epoch), 0).UTC()
+}
+
+func stateFromName(name string) ProgramState {
+	switch ProgramState(strings.ToUpper(name)) {
+	case StateStopped, StateStarting, StateRunning, StateBackoff, StateStopping, StateExited, StateFatal:
+		return ProgramState(strings.ToUpper(name))
+	default:
+		return StateUnknown
+	}
+}
+
+// marshalMethodCall renders method and args as an XML-RPC <methodCall> document. Supported arg
+// types are string and bool, which is all supervisord's process-control API needs.
+func marshalMethodCall(method string, args []any) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<methodCall><methodName>")
+	xml.EscapeText(&b, []byte(method))
+	b.WriteString("</methodName><params>")
+	for _, a := range args {
+		b.WriteString("<param><value>")
+		switch v := a.(type) {
+		case string:
+			b.WriteString("<string>")
+			xml.EscapeText(&b, []byte(v))
+			b.WriteString("</string>")
+		case bool:
+			if v {
+				b.WriteString("<boolean>1</boolean>")
+			} else {
+				b.WriteString("<boolean>0</boolean>")
+			}
+		case int:
+			b.WriteString("<int>")
+			b.WriteString(strconv.Itoa(v))
+			b.WriteString("</int>")
+		default:
+			return nil, fmt.Errorf("unsupported xml-rpc arg type %T", a)
+		}
+		b.WriteString("</value></param>")
+	}
+	b.WriteString("</params></methodCall>")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,252 @@
+// Package uploadsession persists resumable chunked upload state to disk, tus-protocol style, so
+// a client uploading a large extension bundle can resume after a network blip or a server
+// restart without re-sending bytes it already delivered.
+package uploadsession
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session tracks one in-progress resumable upload. HashState is the running sha256 digest,
+// persisted via its encoding.BinaryMarshaler so Hash can resume it mid-stream instead of
+// re-reading TempPath's bytes on every chunk.
+type Session struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	TempPath       string    `json:"temp_path"`
+	DeclaredSize   int64     `json:"declared_size"`
+	DeclaredSHA256 string    `json:"declared_sha256"`
+	ChunkSize      int64     `json:"chunk_size"`
+	Offset         int64     `json:"offset"`
+	HashState      []byte    `json:"hash_state"`
+	CreatedAt      time.Time `json:"created_at"`
+	// Mode is an octal file permission string applied when a caller commits the upload into
+	// place. Unused by the extension upload flow, which always installs under a fixed 0o755
+	// directory; set by the generic filesystem upload flow in fs_upload.go.
+	Mode string `json:"mode,omitempty"`
+}
+
+// Hash returns a sha256 hash.Hash resumed from sess.HashState (the zero hash if none has been
+// written yet), ready to keep summing bytes from Offset onward.
+func (sess *Session) Hash() (hash.Hash, error) {
+	h := sha256.New()
+	if len(sess.HashState) == 0 {
+		return h, nil
+	}
+	unmarshaler, ok := h.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("sha256 hash does not support binary unmarshaling")
+	}
+	if err := unmarshaler.UnmarshalBinary(sess.HashState); err != nil {
+		return nil, fmt.Errorf("failed to resume hash state: %w", err)
+	}
+	return h, nil
+}
+
+// saveHashState marshals h's running state into sess.HashState, so the next chunk (or a restart
+// in between) can resume hashing without re-reading everything written so far.
+func saveHashState(sess *Session, h hash.Hash) error {
+	marshaler, ok := h.(encoding.BinaryMarshaler)
+	if !ok {
+		return fmt.Errorf("sha256 hash does not support binary marshaling")
+	}
+	state, err := marshaler.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal hash state: %w", err)
+	}
+	sess.HashState = state
+	return nil
+}
+
+// Store persists one JSON file and one partial-upload file per session under dir.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload session dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (st *Store) sessionPath(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+func (st *Store) partPath(id string) string {
+	return filepath.Join(st.dir, id+".part")
+}
+
+// Create starts a new session: an empty temp file and a fresh sha256 state, both persisted so
+// the session survives a server restart before any chunk has even arrived.
+func (st *Store) Create(name string, declaredSize int64, declaredSHA256 string, chunkSize int64) (*Session, error) {
+	return st.CreateWithMode(name, declaredSize, declaredSHA256, chunkSize, "")
+}
+
+// CreateWithMode is Create plus a mode to persist into the session, for callers (the filesystem
+// upload flow in fs_upload.go) that need to apply a specific permission when the upload is
+// committed into place.
+func (st *Store) CreateWithMode(name string, declaredSize int64, declaredSHA256 string, chunkSize int64, mode string) (*Session, error) {
+	id := uuid.New().String()
+	sess := &Session{
+		ID:             id,
+		Name:           name,
+		TempPath:       st.partPath(id),
+		DeclaredSize:   declaredSize,
+		DeclaredSHA256: declaredSHA256,
+		ChunkSize:      chunkSize,
+		CreatedAt:      time.Now(),
+		Mode:           mode,
+	}
+
+	f, err := os.Create(sess.TempPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize upload temp file: %w", err)
+	}
+	if err := saveHashState(sess, sha256.New()); err != nil {
+		return nil, err
+	}
+	if err := st.Save(sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// AppendChunk writes data at sess.Offset, advances the resumed hash over it, and persists both
+// the new offset and hash state before returning, so a crash mid-upload loses at most the chunk
+// in flight rather than corrupting what's already been durably written.
+func (st *Store) AppendChunk(sess *Session, offset int64, data []byte) error {
+	if offset != sess.Offset {
+		return fmt.Errorf("chunk offset %d does not match expected offset %d", offset, sess.Offset)
+	}
+
+	f, err := os.OpenFile(sess.TempPath, os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open upload temp file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteAt(data, offset); err != nil {
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	h, err := sess.Hash()
+	if err != nil {
+		return err
+	}
+	if _, err := h.Write(data); err != nil {
+		return fmt.Errorf("failed to hash chunk: %w", err)
+	}
+	if err := saveHashState(sess, h); err != nil {
+		return err
+	}
+
+	sess.Offset += int64(len(data))
+	return st.Save(sess)
+}
+
+func (st *Store) Save(sess *Session) error {
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload session: %w", err)
+	}
+	if err := os.WriteFile(st.sessionPath(sess.ID), b, 0o644); err != nil {
+		return fmt.Errorf("failed to persist upload session: %w", err)
+	}
+	return nil
+}
+
+func (st *Store) Load(id string) (*Session, error) {
+	b, err := os.ReadFile(st.sessionPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload session: %w", err)
+	}
+	var sess Session
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload session: %w", err)
+	}
+	return &sess, nil
+}
+
+// Delete removes a session's state and partial upload file. It's best-effort: a commit has
+// already either succeeded or failed by the time this is called, so a leftover file here isn't
+// worth failing the request over.
+func (st *Store) Delete(id string) {
+	_ = os.Remove(st.sessionPath(id))
+	_ = os.Remove(st.partPath(id))
+}
+
+// List returns every session currently persisted under dir, for GC and diagnostics.
+func (st *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload session dir: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		sess, err := st.Load(id)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
+
+// GC deletes every session older than ttl (by CreatedAt) and returns how many it removed. A
+// client that starts an upload and then disappears would otherwise leak a session file and a
+// partial temp file forever.
+func (st *Store) GC(ttl time.Duration) (int, error) {
+	sessions, err := st.List()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-ttl)
+	for _, sess := range sessions {
+		if sess.CreatedAt.Before(cutoff) {
+			st.Delete(sess.ID)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// StartJanitor runs GC on interval until ctx is canceled, mirroring the background-loop shape of
+// devtoolsproxy.UpstreamManager.Start: a single goroutine ticking until its context is done.
+// GC errors and removal counts aren't surfaced here; wrap this call if a caller needs logging.
+func (st *Store) StartJanitor(ctx context.Context, ttl, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = st.GC(ttl)
+			}
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+package vfs
+
+import (
+	"context"
+	"os"
+)
+
+// LocalFS implements VFS directly against the local filesystem; it's the backend every path
+// resolved without a mount prefix (or with an explicit local:// one) uses, and preserves the
+// server's pre-VFS behavior exactly.
+type LocalFS struct{}
+
+func (LocalFS) Open(_ context.Context, path string) (File, error) {
+	return os.Open(path)
+}
+
+func (LocalFS) Create(_ context.Context, path string, perm os.FileMode) (File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, perm)
+}
+
+func (LocalFS) Stat(_ context.Context, path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (LocalFS) ReadDir(_ context.Context, path string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (LocalFS) Mkdir(_ context.Context, path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (LocalFS) Remove(_ context.Context, path string) error {
+	return os.Remove(path)
+}
+
+func (LocalFS) RemoveAll(_ context.Context, path string) error {
+	return os.RemoveAll(path)
+}
+
+func (LocalFS) Rename(_ context.Context, oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (LocalFS) Chmod(_ context.Context, path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+func (LocalFS) Chown(_ context.Context, path string, uid, gid int) error {
+	return os.Chown(path, uid, gid)
+}
+
+func (LocalFS) Watch(ctx context.Context, path string, recursive bool) (Watcher, error) {
+	return newFsnotifyWatcher(path, recursive)
+}
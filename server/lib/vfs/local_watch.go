@@ -0,0 +1,99 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fsnotifyWatcher adapts an *fsnotify.Watcher to the Watcher interface, translating its raw
+// fsnotify.Event stream into vfs.Event so callers don't need to import fsnotify themselves.
+type fsnotifyWatcher struct {
+	watcher   *fsnotify.Watcher
+	recursive bool
+	events    chan Event
+	errs      chan error
+}
+
+func newFsnotifyWatcher(path string, recursive bool) (Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if recursive {
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return w.Add(p)
+			}
+			return nil
+		})
+	} else {
+		err = w.Add(path)
+	}
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	fw := &fsnotifyWatcher{
+		watcher:   w,
+		recursive: recursive,
+		events:    make(chan Event, 100),
+		errs:      make(chan error, 1),
+	}
+	go fw.forward()
+	return fw, nil
+}
+
+func (fw *fsnotifyWatcher) forward() {
+	defer close(fw.events)
+	defer close(fw.errs)
+	for {
+		select {
+		case ev, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+			var evType string
+			switch {
+			case ev.Op&fsnotify.Create != 0:
+				evType = "CREATE"
+			case ev.Op&fsnotify.Write != 0:
+				evType = "WRITE"
+			case ev.Op&fsnotify.Remove != 0:
+				evType = "DELETE"
+			case ev.Op&fsnotify.Rename != 0:
+				evType = "RENAME"
+			default:
+				continue
+			}
+			info, _ := os.Stat(ev.Name)
+			isDir := info != nil && info.IsDir()
+			if fw.recursive && evType == "CREATE" && isDir {
+				_ = fw.watcher.Add(ev.Name)
+			}
+			select {
+			case fw.events <- Event{Type: evType, Path: ev.Name, Name: filepath.Base(ev.Name), IsDir: isDir}:
+			default:
+			}
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+			select {
+			case fw.errs <- err:
+			default:
+			}
+		}
+	}
+}
+
+func (fw *fsnotifyWatcher) Events() <-chan Event { return fw.events }
+func (fw *fsnotifyWatcher) Errors() <-chan error { return fw.errs }
+func (fw *fsnotifyWatcher) Close() error         { return fw.watcher.Close() }
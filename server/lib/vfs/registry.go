@@ -0,0 +1,153 @@
+package vfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Mount is one registered non-local backend, named so it can be selected via the X-Kernel-Mount
+// header as an alternative to a scheme-prefixed path.
+type Mount struct {
+	Name   string
+	Scheme string
+	FS     VFS
+}
+
+// Registry resolves a path (optionally scheme-prefixed, e.g. "s3://bucket/key") or a mount name
+// to the VFS backend and backend-relative path it addresses. An unprefixed path, or one with no
+// matching mount, falls back to Local so existing callers are unaffected.
+type Registry struct {
+	Local  VFS
+	mounts map[string]Mount // keyed by scheme
+	byName map[string]Mount
+}
+
+// NewRegistry returns a Registry with only the local backend registered; call Register to add
+// S3/WebDAV/SFTP mounts, or use LoadMountsFromEnv to build one from configuration.
+func NewRegistry() *Registry {
+	return &Registry{
+		Local:  LocalFS{},
+		mounts: make(map[string]Mount),
+		byName: make(map[string]Mount),
+	}
+}
+
+// Register adds a named mount addressable by its scheme prefix (e.g. "s3://...") or by name via
+// the X-Kernel-Mount header.
+func (r *Registry) Register(m Mount) {
+	r.mounts[m.Scheme] = m
+	r.byName[m.Name] = m
+}
+
+// Resolve returns the VFS backend path addresses and the path with any scheme/mount prefix
+// stripped. mountHeader, if non-empty, selects a backend by Mount.Name and takes precedence over
+// a scheme prefix embedded in path.
+func (r *Registry) Resolve(path, mountHeader string) (VFS, string, error) {
+	if mountHeader != "" {
+		m, ok := r.byName[mountHeader]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown mount %q", mountHeader)
+		}
+		return m.FS, path, nil
+	}
+
+	scheme, rest, ok := strings.Cut(path, "://")
+	if !ok {
+		return r.Local, path, nil
+	}
+	if scheme == "local" {
+		return r.Local, rest, nil
+	}
+
+	m, ok := r.mounts[scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("no mount registered for scheme %q", scheme)
+	}
+	return m.FS, rest, nil
+}
+
+// MountInfo is what ListMounts surfaces about a registered backend; it deliberately omits
+// credentials.
+type MountInfo struct {
+	Name   string `json:"name"`
+	Scheme string `json:"scheme"`
+}
+
+// List returns every non-local mount registered, for the ListMounts endpoint.
+func (r *Registry) List() []MountInfo {
+	infos := make([]MountInfo, 0, len(r.byName))
+	for _, m := range r.byName {
+		infos = append(infos, MountInfo{Name: m.Name, Scheme: m.Scheme})
+	}
+	return infos
+}
+
+// mountSpec is the JSON shape of one entry in KERNEL_VFS_MOUNTS_JSON.
+type mountSpec struct {
+	Name   string `json:"name"`
+	Scheme string `json:"scheme"` // "s3", "webdav", or "sftp"
+
+	// S3
+	Bucket          string `json:"bucket,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+
+	// WebDAV
+	BaseURL  string `json:"base_url,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// SFTP
+	Host           string `json:"host,omitempty"`
+	Port           int    `json:"port,omitempty"`
+	PrivateKeyPEM  string `json:"private_key_pem,omitempty"`
+	KnownHostsPath string `json:"known_hosts_path,omitempty"`
+	RootDir        string `json:"root_dir,omitempty"`
+}
+
+// LoadMountsFromEnv builds a Registry from KERNEL_VFS_MOUNTS_JSON, a JSON array of mount specs,
+// each naming the scheme it backs ("s3", "webdav", or "sftp") plus that backend's credentials and
+// endpoint. An empty/unset env var yields a Registry with only the local backend, so the
+// filesystem API behaves exactly as before VFS existed for any deployment that doesn't opt in.
+func LoadMountsFromEnv() (*Registry, error) {
+	r := NewRegistry()
+
+	raw := os.Getenv("KERNEL_VFS_MOUNTS_JSON")
+	if raw == "" {
+		return r, nil
+	}
+
+	var specs []mountSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("invalid KERNEL_VFS_MOUNTS_JSON: %w", err)
+	}
+
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("mount spec missing name")
+		}
+		fs, err := newMountFS(spec)
+		if err != nil {
+			return nil, fmt.Errorf("mount %q: %w", spec.Name, err)
+		}
+		r.Register(Mount{Name: spec.Name, Scheme: spec.Scheme, FS: fs})
+	}
+	return r, nil
+}
+
+func newMountFS(spec mountSpec) (VFS, error) {
+	switch spec.Scheme {
+	case "s3":
+		return newS3FS(spec)
+	case "webdav":
+		return newWebDAVFS(spec)
+	case "sftp":
+		return newSFTPFS(spec)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", spec.Scheme)
+	}
+}
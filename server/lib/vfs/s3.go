@@ -0,0 +1,285 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3FS is a VFS backed by an S3-compatible bucket. Objects have no directory structure of their
+// own, so ReadDir/Mkdir fake one the way `aws s3 ls`/`aws s3 sync` do: by listing and creating
+// zero-byte keys under a "/"-delimited prefix. Chmod/Chown have no S3 equivalent and return
+// ErrNotSupported.
+type s3FS struct {
+	client *s3.Client
+	bucket string
+}
+
+func newS3FS(spec mountSpec) (VFS, error) {
+	if spec.Bucket == "" {
+		return nil, fmt.Errorf("s3 mount requires a bucket")
+	}
+
+	ctx := context.Background()
+	var optFns []func(*awsconfig.LoadOptions) error
+	if spec.Region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(spec.Region))
+	}
+	if spec.AccessKeyID != "" {
+		optFns = append(optFns, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(spec.AccessKeyID, spec.SecretAccessKey, "")))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if spec.Endpoint != "" {
+			o.BaseEndpoint = aws.String(spec.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &s3FS{client: client, bucket: spec.Bucket}, nil
+}
+
+func (fs *s3FS) key(path string) string {
+	return strings.TrimPrefix(path, "/")
+}
+
+func (fs *s3FS) Open(ctx context.Context, path string) (File, error) {
+	out, err := fs.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &fs.bucket, Key: aws.String(fs.key(path))})
+	if err != nil {
+		return nil, translateS3Error(err)
+	}
+	data, err := io.ReadAll(out.Body)
+	out.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &s3File{fs: fs, path: path, buf: bytes.NewReader(data), size: int64(len(data)), modTime: derefTime(out.LastModified)}, nil
+}
+
+func (fs *s3FS) Create(ctx context.Context, path string, _ os.FileMode) (File, error) {
+	return &s3File{fs: fs, path: path, write: &bytes.Buffer{}}, nil
+}
+
+func (fs *s3FS) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	out, err := fs.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &fs.bucket, Key: aws.String(fs.key(path))})
+	if err != nil {
+		return nil, translateS3Error(err)
+	}
+	size := int64(0)
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+	return &objectInfo{name: path, size: size, modTime: derefTime(out.LastModified)}, nil
+}
+
+func (fs *s3FS) ReadDir(ctx context.Context, path string) ([]os.FileInfo, error) {
+	prefix := fs.key(path)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	out, err := fs.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket: &fs.bucket, Prefix: &prefix, Delimiter: aws.String("/"),
+	})
+	if err != nil {
+		return nil, translateS3Error(err)
+	}
+
+	var infos []os.FileInfo
+	for _, p := range out.CommonPrefixes {
+		name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(p.Prefix), prefix), "/")
+		infos = append(infos, &objectInfo{name: name, isDir: true})
+	}
+	for _, obj := range out.Contents {
+		name := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+		if name == "" {
+			continue // the directory marker object itself
+		}
+		infos = append(infos, &objectInfo{name: name, size: aws.ToInt64(obj.Size), modTime: derefTime(obj.LastModified)})
+	}
+	return infos, nil
+}
+
+// Mkdir writes a zero-byte marker object so the "directory" shows up in ReadDir, matching how
+// most S3-compatible consoles represent folders.
+func (fs *s3FS) Mkdir(ctx context.Context, path string, _ os.FileMode) error {
+	key := fs.key(path)
+	if !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	_, err := fs.client.PutObject(ctx, &s3.PutObjectInput{Bucket: &fs.bucket, Key: &key, Body: bytes.NewReader(nil)})
+	return translateS3Error(err)
+}
+
+func (fs *s3FS) Remove(ctx context.Context, path string) error {
+	_, err := fs.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &fs.bucket, Key: aws.String(fs.key(path))})
+	return translateS3Error(err)
+}
+
+func (fs *s3FS) RemoveAll(ctx context.Context, path string) error {
+	prefix := fs.key(path)
+	out, err := fs.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: &fs.bucket, Prefix: &prefix})
+	if err != nil {
+		return translateS3Error(err)
+	}
+	for _, obj := range out.Contents {
+		if _, err := fs.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &fs.bucket, Key: obj.Key}); err != nil {
+			return translateS3Error(err)
+		}
+	}
+	return nil
+}
+
+// Rename copies the object under newPath and deletes oldPath; S3 has no atomic rename primitive.
+func (fs *s3FS) Rename(ctx context.Context, oldPath, newPath string) error {
+	source := fs.bucket + "/" + fs.key(oldPath)
+	if _, err := fs.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket: &fs.bucket, Key: aws.String(fs.key(newPath)), CopySource: &source,
+	}); err != nil {
+		return translateS3Error(err)
+	}
+	return fs.Remove(ctx, oldPath)
+}
+
+func (fs *s3FS) Chmod(context.Context, string, os.FileMode) error { return ErrNotSupported }
+func (fs *s3FS) Chown(context.Context, string, int, int) error    { return ErrNotSupported }
+func (fs *s3FS) Watch(context.Context, string, bool) (Watcher, error) {
+	return nil, ErrNotSupported
+}
+
+func translateS3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "NotFound") {
+		return fmt.Errorf("%w: %v", os.ErrNotExist, err)
+	}
+	return err
+}
+
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// s3File buffers a GetObject body (or pending PutObject body) entirely in memory: S3 has no
+// streaming random-access API, so this is the simplest correct adapter to io.ReadWriteCloser.
+type s3File struct {
+	fs      *s3FS
+	path    string
+	buf     *bytes.Reader
+	write   *bytes.Buffer
+	size    int64
+	modTime time.Time
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.buf == nil {
+		return 0, io.EOF
+	}
+	return f.buf.Read(p)
+}
+
+// ReadAt lets callers needing a single byte range (see ReadFile's Range header support in
+// fs.go) avoid buffering the whole object twice; the full body is already in memory in f.buf.
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if f.buf == nil {
+		return 0, io.EOF
+	}
+	return f.buf.ReadAt(p, off)
+}
+
+func (f *s3File) Write(p []byte) (int, error) {
+	if f.write == nil {
+		return 0, fmt.Errorf("file opened read-only")
+	}
+	return f.write.Write(p)
+}
+
+// WriteAt lets writeFileRange (fs.go) write a single byte range against an S3 mount: there's no
+// partial-PUT primitive, so this rewrites the whole in-memory buffer and Close still does one
+// PutObject with the final result, same as a sequential Write would.
+func (f *s3File) WriteAt(p []byte, off int64) (int, error) {
+	if f.write == nil {
+		return 0, fmt.Errorf("file opened read-only")
+	}
+	b := f.write.Bytes()
+	end := off + int64(len(p))
+	if end > int64(len(b)) {
+		grown := make([]byte, end)
+		copy(grown, b)
+		b = grown
+	}
+	copy(b[off:], p)
+	f.write.Reset()
+	f.write.Write(b)
+	return len(p), nil
+}
+
+func (f *s3File) Truncate(size int64) error {
+	if f.write == nil {
+		return fmt.Errorf("file opened read-only")
+	}
+	b := f.write.Bytes()
+	if int64(len(b)) == size {
+		return nil
+	}
+	grown := make([]byte, size)
+	copy(grown, b)
+	f.write.Reset()
+	f.write.Write(grown)
+	return nil
+}
+
+func (f *s3File) Stat() (os.FileInfo, error) {
+	if f.write != nil {
+		return &objectInfo{name: f.path, size: int64(f.write.Len())}, nil
+	}
+	return &objectInfo{name: f.path, size: f.size, modTime: f.modTime}, nil
+}
+
+// Close flushes a pending write as a single PutObject; reads have nothing to flush.
+func (f *s3File) Close() error {
+	if f.write == nil {
+		return nil
+	}
+	_, err := f.fs.client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: &f.fs.bucket, Key: aws.String(f.fs.key(f.path)), Body: bytes.NewReader(f.write.Bytes()),
+	})
+	return translateS3Error(err)
+}
+
+// objectInfo is a minimal os.FileInfo for backends (S3, WebDAV) with no native os.FileInfo.
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (o *objectInfo) Name() string { return o.name }
+func (o *objectInfo) Size() int64  { return o.size }
+func (o *objectInfo) Mode() os.FileMode {
+	if o.isDir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+func (o *objectInfo) ModTime() time.Time { return o.modTime }
+func (o *objectInfo) IsDir() bool        { return o.isDir }
+func (o *objectInfo) Sys() any           { return nil }
@@ -0,0 +1,63 @@
+// Package vfs abstracts the filesystem handlers in server/cmd/api/api/fs.go behind a common
+// interface so a path can resolve to local disk, S3, WebDAV, or SFTP depending on its mount
+// prefix, letting a caller read/write browser session data directly into object storage without
+// a sidecar process.
+package vfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotSupported is returned by a VFS method a backend has no sensible implementation for (e.g.
+// Chmod against S3, which has no POSIX permission model). Handlers translate it to a 501.
+var ErrNotSupported = errors.New("operation not supported by this backend")
+
+// File is what Open/Create return: a stream over a path's contents. ReaderAt/WriterAt are
+// satisfied by local files and sftp.File directly; backends that can only do sequential
+// streaming (WebDAV, S3) leave them nil, and callers fall back to whole-object reads/writes -
+// see rangeReaderAt/rangeWriterAt in server/cmd/api/api/fs.go.
+type File interface {
+	io.ReadWriteCloser
+	Stat() (os.FileInfo, error)
+	Truncate(size int64) error
+}
+
+// Event mirrors the shape of oapi.FileSystemEvent without depending on the oapi package, so vfs
+// stays usable outside the API server.
+type Event struct {
+	Type  string
+	Path  string
+	Name  string
+	IsDir bool
+}
+
+// Watcher streams Events for a watched path until Close is called.
+type Watcher interface {
+	Events() <-chan Event
+	Errors() <-chan error
+	Close() error
+}
+
+// VFS is the storage backend interface every filesystem handler in fs.go operates against.
+// Paths passed to its methods are already relative to the mount (registry.Resolve has stripped
+// the scheme and mount prefix).
+//
+// Watch is part of the interface so every backend has an explicit answer (LocalFS backs it with
+// fsnotify; the others return ErrNotSupported), but StartFsWatch/StopFsWatch/StreamFsEvents in
+// fs.go predate this package and aren't migrated onto it - they only ever watched local paths.
+type VFS interface {
+	Open(ctx context.Context, path string) (File, error)
+	Create(ctx context.Context, path string, perm os.FileMode) (File, error)
+	Stat(ctx context.Context, path string) (os.FileInfo, error)
+	ReadDir(ctx context.Context, path string) ([]os.FileInfo, error)
+	Mkdir(ctx context.Context, path string, perm os.FileMode) error
+	Remove(ctx context.Context, path string) error
+	RemoveAll(ctx context.Context, path string) error
+	Rename(ctx context.Context, oldPath, newPath string) error
+	Chmod(ctx context.Context, path string, mode os.FileMode) error
+	Chown(ctx context.Context, path string, uid, gid int) error
+	Watch(ctx context.Context, path string, recursive bool) (Watcher, error)
+}
@@ -0,0 +1,181 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// webdavFS is a VFS backed by a WebDAV server (e.g. Nextcloud, a NAS). Like s3FS, it has no
+// POSIX permission model, so Chmod/Chown return ErrNotSupported.
+type webdavFS struct {
+	client *gowebdav.Client
+}
+
+func newWebDAVFS(spec mountSpec) (VFS, error) {
+	if spec.BaseURL == "" {
+		return nil, fmt.Errorf("webdav mount requires a base_url")
+	}
+	client := gowebdav.NewClient(spec.BaseURL, spec.Username, spec.Password)
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to WebDAV server: %w", err)
+	}
+	return &webdavFS{client: client}, nil
+}
+
+func (fs *webdavFS) Open(ctx context.Context, p string) (File, error) {
+	data, err := fs.client.ReadStream(p)
+	if err != nil {
+		return nil, translateWebDAVError(err)
+	}
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	info, _ := fs.client.Stat(p)
+	var modTime time.Time
+	if info != nil {
+		modTime = info.ModTime()
+	}
+	return &webdavFile{fs: fs, path: p, buf: bytes.NewReader(body), size: int64(len(body)), modTime: modTime}, nil
+}
+
+func (fs *webdavFS) Create(ctx context.Context, p string, _ os.FileMode) (File, error) {
+	return &webdavFile{fs: fs, path: p, write: &bytes.Buffer{}}, nil
+}
+
+func (fs *webdavFS) Stat(ctx context.Context, p string) (os.FileInfo, error) {
+	info, err := fs.client.Stat(p)
+	if err != nil {
+		return nil, translateWebDAVError(err)
+	}
+	return info, nil
+}
+
+func (fs *webdavFS) ReadDir(ctx context.Context, p string) ([]os.FileInfo, error) {
+	entries, err := fs.client.ReadDir(p)
+	if err != nil {
+		return nil, translateWebDAVError(err)
+	}
+	return entries, nil
+}
+
+func (fs *webdavFS) Mkdir(ctx context.Context, p string, _ os.FileMode) error {
+	return translateWebDAVError(fs.client.MkdirAll(p, 0o755))
+}
+
+func (fs *webdavFS) Remove(ctx context.Context, p string) error {
+	return translateWebDAVError(fs.client.Remove(p))
+}
+
+func (fs *webdavFS) RemoveAll(ctx context.Context, p string) error {
+	return translateWebDAVError(fs.client.RemoveAll(p))
+}
+
+func (fs *webdavFS) Rename(ctx context.Context, oldPath, newPath string) error {
+	return translateWebDAVError(fs.client.Rename(oldPath, newPath, true))
+}
+
+func (fs *webdavFS) Chmod(context.Context, string, os.FileMode) error { return ErrNotSupported }
+func (fs *webdavFS) Chown(context.Context, string, int, int) error    { return ErrNotSupported }
+func (fs *webdavFS) Watch(context.Context, string, bool) (Watcher, error) {
+	return nil, ErrNotSupported
+}
+
+func translateWebDAVError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if os.IsNotExist(err) {
+		return err
+	}
+	return err
+}
+
+// webdavFile buffers the whole object in memory for the same reason s3File does: WebDAV's PUT/GET
+// verbs have no portable partial-content contract across server implementations.
+type webdavFile struct {
+	fs      *webdavFS
+	path    string
+	buf     *bytes.Reader
+	write   *bytes.Buffer
+	size    int64
+	modTime time.Time
+}
+
+func (f *webdavFile) Read(p []byte) (int, error) {
+	if f.buf == nil {
+		return 0, io.EOF
+	}
+	return f.buf.Read(p)
+}
+
+// ReadAt mirrors s3File.ReadAt: the whole object is already buffered in f.buf, so a byte-range
+// read is just a slice of it.
+func (f *webdavFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.buf == nil {
+		return 0, io.EOF
+	}
+	return f.buf.ReadAt(p, off)
+}
+
+func (f *webdavFile) Write(p []byte) (int, error) {
+	if f.write == nil {
+		return 0, fmt.Errorf("file opened read-only")
+	}
+	return f.write.Write(p)
+}
+
+// WriteAt mirrors s3File.WriteAt: WebDAV's PUT has no portable partial-content contract, so a
+// byte-range write rewrites the whole in-memory buffer and Close still does one full PUT.
+func (f *webdavFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.write == nil {
+		return 0, fmt.Errorf("file opened read-only")
+	}
+	b := f.write.Bytes()
+	end := off + int64(len(p))
+	if end > int64(len(b)) {
+		grown := make([]byte, end)
+		copy(grown, b)
+		b = grown
+	}
+	copy(b[off:], p)
+	f.write.Reset()
+	f.write.Write(b)
+	return len(p), nil
+}
+
+func (f *webdavFile) Truncate(size int64) error {
+	if f.write == nil {
+		return fmt.Errorf("file opened read-only")
+	}
+	b := f.write.Bytes()
+	if int64(len(b)) == size {
+		return nil
+	}
+	grown := make([]byte, size)
+	copy(grown, b)
+	f.write.Reset()
+	f.write.Write(grown)
+	return nil
+}
+
+func (f *webdavFile) Stat() (os.FileInfo, error) {
+	if f.write != nil {
+		return &objectInfo{name: path.Base(f.path), size: int64(f.write.Len())}, nil
+	}
+	return &objectInfo{name: path.Base(f.path), size: f.size, modTime: f.modTime}, nil
+}
+
+func (f *webdavFile) Close() error {
+	if f.write == nil {
+		return nil
+	}
+	return translateWebDAVError(f.fs.client.Write(f.path, f.write.Bytes(), 0o644))
+}
@@ -0,0 +1,196 @@
+// Package ziputil extracts zip archives into a target directory without shelling out to the
+// unzip binary, validating every entry against path traversal so a malicious or corrupted
+// archive can't write outside its destination.
+package ziputil
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OverwritePolicy controls what Extract does when an entry's destination path already exists.
+type OverwritePolicy string
+
+const (
+	// OverwriteReplace (the default) truncates and rewrites the existing file.
+	OverwriteReplace OverwritePolicy = "replace"
+	// OverwriteSkip leaves the existing file alone and moves on to the next entry.
+	OverwriteSkip OverwritePolicy = "skip"
+	// OverwriteError fails the whole extraction the first time an entry's destination already
+	// exists.
+	OverwriteError OverwritePolicy = "error"
+)
+
+// ExtractOptions configures Extract/ExtractReader.
+type ExtractOptions struct {
+	// TargetDir is the directory entries are extracted relative to. It's created if it doesn't
+	// exist.
+	TargetDir string
+	// Overwrite controls collision handling; the zero value behaves like OverwriteReplace.
+	Overwrite OverwritePolicy
+	// UID/GID, if both non-nil, chown every extracted file instead of leaving it owned by
+	// whatever user this process runs as.
+	UID, GID *int
+	// Mode, if non-nil, overrides the permission bits every extracted file (not directory) is
+	// created with, instead of using the zip entry's own mode.
+	Mode *os.FileMode
+}
+
+func (o ExtractOptions) overwrite() OverwritePolicy {
+	if o.Overwrite == "" {
+		return OverwriteReplace
+	}
+	return o.Overwrite
+}
+
+// Unzip extracts every entry in the zip file at src into dest, creating dest if it doesn't exist
+// and replacing any files already there. It's a convenience wrapper around Extract for callers
+// that don't need overwrite/ownership control.
+func Unzip(src, dest string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open zip: %w", err)
+	}
+	defer r.Close()
+
+	_, err = extract(&r.Reader, ExtractOptions{TargetDir: dest})
+	return err
+}
+
+// Extract opens the zip file at zipPath and extracts it per opts, returning the destination
+// paths of every file it wrote (skipped entries under OverwriteSkip are not included).
+func Extract(zipPath string, opts ExtractOptions) ([]string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+	defer r.Close()
+	return extract(&r.Reader, opts)
+}
+
+// ExtractReader is Extract for a zip that hasn't been written to disk as a named file - e.g. an
+// upload buffered into a temp file or an in-memory buffer. ra/size are the same pair
+// archive/zip.NewReader itself requires, since a zip's central directory lives at the end of the
+// archive and can't be parsed from a forward-only stream.
+func ExtractReader(ra io.ReaderAt, size int64, opts ExtractOptions) ([]string, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip: %w", err)
+	}
+	return extract(zr, opts)
+}
+
+func extract(zr *zip.Reader, opts ExtractOptions) ([]string, error) {
+	if err := os.MkdirAll(opts.TargetDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create target dir: %w", err)
+	}
+
+	var written []string
+	for _, f := range zr.File {
+		destPath, err := entryDestPath(opts.TargetDir, f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("rejected entry %q: %w", f.Name, err)
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(destPath, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", destPath, err)
+			}
+			continue
+		}
+
+		wrote, err := extractFile(f, destPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", f.Name, err)
+		}
+		if wrote {
+			written = append(written, destPath)
+		}
+	}
+	return written, nil
+}
+
+func extractFile(f *zip.File, destPath string, opts ExtractOptions) (bool, error) {
+	if _, err := os.Lstat(destPath); err == nil {
+		switch opts.overwrite() {
+		case OverwriteSkip:
+			return false, nil
+		case OverwriteError:
+			return false, fmt.Errorf("%s already exists", destPath)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return false, err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+
+	mode := f.Mode()
+	if opts.Mode != nil {
+		mode = *opts.Mode
+	}
+
+	out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, rc); err != nil {
+		return false, err
+	}
+
+	if opts.UID != nil && opts.GID != nil {
+		if err := os.Chown(destPath, *opts.UID, *opts.GID); err != nil {
+			return false, fmt.Errorf("failed to chown: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// entryDestPath resolves a zip entry's name to a path under destDir, rejecting anything that
+// would escape it (absolute paths, "../" components, or a symlink-free escape via a cleaned
+// path that no longer has destDir as a prefix).
+func entryDestPath(destDir, name string) (string, error) {
+	cleaned := filepath.Clean(name)
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path traversal in entry name")
+	}
+
+	destDir = filepath.Clean(destDir)
+	destPath := filepath.Join(destDir, cleaned)
+	if destPath != destDir && !strings.HasPrefix(destPath, destDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("path traversal in entry name")
+	}
+	return destPath, nil
+}
+
+// StreamEntry opens the single entry named entryName within the zip read from ra/size, without
+// extracting anything else - the inverse of Extract, for a caller that wants to inspect or
+// download one file out of a server-side archive cheaply. The caller must Close the returned
+// io.ReadCloser.
+func StreamEntry(ra io.ReaderAt, size int64, entryName string) (io.ReadCloser, int64, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read zip: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.Name == entryName {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to open entry: %w", err)
+			}
+			return rc, int64(f.UncompressedSize64), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("entry %q not found", entryName)
+}